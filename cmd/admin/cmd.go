@@ -0,0 +1,249 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator"
+)
+
+type Config struct {
+	AdminAddr      string
+	RequestTimeout time.Duration
+	RemoveReplicas bool
+}
+
+func NewConfig() Config {
+	return Config{
+		AdminAddr:      fmt.Sprintf("localhost:%d", common.DefaultAdminPort),
+		RequestTimeout: 1 * time.Minute,
+	}
+}
+
+var (
+	config = NewConfig()
+
+	Cmd = &cobra.Command{
+		Use:   "admin",
+		Short: "Administer the shards",
+		Long:  `Operations to inspect the shards and to move their leaders, through the admin service of the coordinator`,
+	}
+
+	listShardsCmd = &cobra.Command{
+		Use:   "list-shards",
+		Short: "List the shards",
+		Long:  `List all the shards, with their term, their leader and the members of their ensemble`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return doRequest(cmd, http.MethodGet, "/admin/shards", nil)
+		},
+	}
+
+	triggerElectionCmd = &cobra.Command{
+		Use:   "trigger-election <shard>",
+		Short: "Elect a new leader for a shard",
+		Long:  `Force the election of a new leader for the shard, even if the current leader is healthy`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			shard, err := parseShard(args[0])
+			if err != nil {
+				return err
+			}
+			return doRequest(cmd, http.MethodPost, fmt.Sprintf("/admin/shards/%d/election", shard), nil)
+		},
+	}
+
+	transferLeaderCmd = &cobra.Command{
+		Use:   "transfer-leader <shard> <new-leader>",
+		Short: "Move the leader of a shard",
+		Long:  `Move the leadership of the shard to an in-sync member of its ensemble, identified by its internal address`,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			shard, err := parseShard(args[0])
+			if err != nil {
+				return err
+			}
+			return doRequest(cmd, http.MethodPost, fmt.Sprintf("/admin/shards/%d/leader", shard),
+				coordinator.TransferLeaderRequest{NewLeader: args[1]})
+		},
+	}
+
+	compactShardCmd = &cobra.Command{
+		Use:   "compact-shard <shard>",
+		Short: "Compact the database of a shard",
+		Long: `Compact the database of the shard on each member of its ensemble, one at a time, dropping the deleted ` +
+			`and the overwritten records. The space reclaimed on each member is printed at the end. The compaction ` +
+			`can take long, so it's not subject to the request timeout.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			shard, err := parseShard(args[0])
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+			return doRequestWithContext(ctx, cmd, http.MethodPost, fmt.Sprintf("/admin/shards/%d/compact", shard), nil)
+		},
+	}
+
+	drainServerCmd = &cobra.Command{
+		Use:   "drain-server <server>",
+		Short: "Move the leaderships and the replicas off a server",
+		Long: `Put the server, identified by its internal address, in maintenance and move all its leaderships to other ` +
+			`servers, one shard at a time. With --remove-replicas, its replicas are moved too. The progress is printed ` +
+			`after each shard. The drain is stopped on interrupt, after the shard that is being moved.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return drainServer(cmd, args[0])
+		},
+	}
+)
+
+func init() {
+	Cmd.PersistentFlags().StringVar(&config.AdminAddr, "admin-address", config.AdminAddr, "Coordinator admin service address")
+	Cmd.PersistentFlags().DurationVar(&config.RequestTimeout, "request-timeout", config.RequestTimeout, "Requests timeout")
+
+	Cmd.AddCommand(listShardsCmd)
+	Cmd.AddCommand(triggerElectionCmd)
+	Cmd.AddCommand(transferLeaderCmd)
+	Cmd.AddCommand(compactShardCmd)
+
+	drainServerCmd.Flags().BoolVar(&config.RemoveReplicas, "remove-replicas", false, "Move the replicas of the server to other servers too")
+	Cmd.AddCommand(drainServerCmd)
+}
+
+func parseShard(s string) (int64, error) {
+	shard, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid shard %q", s)
+	}
+	return shard, nil
+}
+
+// doRequest sends the request to the admin service and prints the response.
+func doRequest(cmd *cobra.Command, method string, path string, body any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.RequestTimeout)
+	defer cancel()
+
+	return doRequestWithContext(ctx, cmd, method, path, body)
+}
+
+func doRequestWithContext(ctx context.Context, cmd *cobra.Command, method string, path string, body any) error {
+	res, err := sendRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return printJSON(cmd, resBody)
+}
+
+// drainServer prints the progress of the drain as it's received. The drain
+// can take long, so it's not subject to the request timeout.
+func drainServer(cmd *cobra.Command, server string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	res, err := sendRequest(ctx, http.MethodPost, fmt.Sprintf("/admin/servers/%s/drain", server),
+		coordinator.DrainServerRequest{RemoveReplicas: config.RemoveReplicas})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	decoder := json.NewDecoder(res.Body)
+	for decoder.More() {
+		var line json.RawMessage
+		if err := decoder.Decode(&line); err != nil {
+			return err
+		}
+		if err := printJSON(cmd, line); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+
+		event := coordinator.DrainEvent{}
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		if event.Done && event.Error != "" {
+			return errors.New(event.Error)
+		}
+	}
+	return nil
+}
+
+// sendRequest sends the request to the admin service and fails if it's not
+// successful.
+func sendRequest(ctx context.Context, method string, path string, body any) (*http.Response, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+config.AdminAddr+path, &reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		resBody, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.Errorf("%s: %s", res.Status, strings.TrimSpace(string(resBody)))
+	}
+	return res, nil
+}
+
+func printJSON(cmd *cobra.Command, value []byte) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, value, "", "  "); err != nil {
+		return err
+	}
+	_, err := fmt.Fprint(cmd.OutOrStdout(), out.String())
+	return err
+}
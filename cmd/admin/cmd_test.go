@@ -0,0 +1,105 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminCmd(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, strings.TrimSpace(string(b))
+
+		if strings.HasPrefix(path, "/admin/shards/1/") {
+			http.Error(w, "shard not found", http.StatusNotFound)
+			return
+		}
+		if strings.HasPrefix(path, "/admin/servers/s4:6649/") {
+			_, _ = w.Write([]byte(`{"done":true,"error":"failed to drain 1 shards"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"shard":0}`))
+	}))
+	defer server.Close()
+
+	address := "--admin-address=" + strings.TrimPrefix(server.URL, "http://")
+
+	for _, test := range []struct {
+		name           string
+		args           []string
+		expectedMethod string
+		expectedPath   string
+		expectedBody   string
+		expectedErr    string
+	}{
+		{"list-shards", []string{"list-shards"}, http.MethodGet, "/admin/shards", "", ""},
+		{"trigger-election", []string{"trigger-election", "0"}, http.MethodPost, "/admin/shards/0/election", "", ""},
+		{"transfer-leader", []string{"transfer-leader", "0", "s2:6649"}, http.MethodPost, "/admin/shards/0/leader",
+			`{"newLeader":"s2:6649"}`, ""},
+		{"unknown shard", []string{"trigger-election", "1"}, http.MethodPost, "/admin/shards/1/election", "",
+			"404 Not Found: shard not found"},
+		{"compact-shard", []string{"compact-shard", "0"}, http.MethodPost, "/admin/shards/0/compact", "", ""},
+		{"compact unknown shard", []string{"compact-shard", "1"}, http.MethodPost, "/admin/shards/1/compact", "",
+			"404 Not Found: shard not found"},
+		{"drain-server", []string{"drain-server", "s3:6649"}, http.MethodPost, "/admin/servers/s3:6649/drain",
+			`{"removeReplicas":false}`, ""},
+		{"drain-server remove replicas", []string{"drain-server", "s3:6649", "--remove-replicas"}, http.MethodPost,
+			"/admin/servers/s3:6649/drain", `{"removeReplicas":true}`, ""},
+		{"drain-server failed", []string{"drain-server", "s4:6649"}, http.MethodPost, "/admin/servers/s4:6649/drain",
+			`{"removeReplicas":false}`, "failed to drain 1 shards"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			config = NewConfig()
+			out := &bytes.Buffer{}
+			Cmd.SetOut(out)
+			Cmd.SetArgs(append(test.args, address))
+
+			err := Cmd.Execute()
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "{\n  \"shard\": 0\n}", strings.TrimSpace(out.String()))
+			}
+
+			assert.Equal(t, test.expectedMethod, method)
+			assert.Equal(t, test.expectedPath, path)
+			assert.Equal(t, test.expectedBody, body)
+		})
+	}
+
+	for _, test := range [][]string{
+		{"trigger-election", "invalid"},
+		{"trigger-election"},
+		{"transfer-leader", "0"},
+		{"compact-shard", "invalid"},
+		{"drain-server"},
+	} {
+		t.Run(strings.Join(test, "_"), func(t *testing.T) {
+			config = NewConfig()
+			Cmd.SetArgs(append(test, address))
+			assert.Error(t, Cmd.Execute())
+		})
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/security"
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/proto"
+)
+
+type Config struct {
+	ServerAddr string
+	Namespace  string
+	Shard      int64
+	Output     string
+
+	TLS              bool
+	TLSTrustedCaFile string
+	TLSCertFile      string
+	TLSKeyFile       string
+}
+
+func NewConfig() Config {
+	return Config{
+		ServerAddr: fmt.Sprintf("localhost:%d", common.DefaultInternalPort),
+		Namespace:  common.DefaultNamespace,
+	}
+}
+
+var (
+	config = NewConfig()
+
+	Cmd = &cobra.Command{
+		Use:   "backup",
+		Short: "Back up a shard",
+		Long: `Take a snapshot of the database of a shard from one of its replicas and store it in a directory, along with a
+manifest that describes it. An interrupted backup is resumed when it's run again with the same output directory,
+without downloading again the files it already has.`,
+		Args:         cobra.NoArgs,
+		RunE:         exec,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	Cmd.Flags().StringVar(&config.ServerAddr, "server-address", config.ServerAddr, "Internal service address of a server with a replica of the shard")
+	Cmd.Flags().StringVarP(&config.Namespace, "namespace", "n", config.Namespace, "Namespace of the shard")
+	Cmd.Flags().Int64Var(&config.Shard, "shard", 0, "Id of the shard")
+	Cmd.Flags().StringVar(&config.Output, "output", "", "Directory where to store the backup")
+	AddTLSFlags(Cmd, &config.TLS, &config.TLSTrustedCaFile, &config.TLSCertFile, &config.TLSKeyFile)
+}
+
+// AddTLSFlags adds the flags to connect to the internal service of the
+// servers, when it requires TLS.
+func AddTLSFlags(cmd *cobra.Command, enabled *bool, trustedCaFile *string, certFile *string, keyFile *string) {
+	cmd.Flags().BoolVar(enabled, "tls", false, "Connect with TLS, verifying the servers with the system CAs if no trusted ca file is set")
+	cmd.Flags().StringVar(trustedCaFile, "tls-trusted-ca-file", "", "Tls trusted ca file")
+	cmd.Flags().StringVar(certFile, "tls-cert-file", "", "Tls client certificate file")
+	cmd.Flags().StringVar(keyFile, "tls-key-file", "", "Tls client key file")
+}
+
+// NewClientPool connects to the servers with TLS, if it's enabled.
+func NewClientPool(enabled bool, trustedCaFile string, certFile string, keyFile string) (common.ClientPool, error) {
+	var tlsConf *tls.Config
+	if enabled || trustedCaFile != "" || certFile != "" {
+		tlsOption := security.TLSOption{
+			TrustedCaFile: trustedCaFile,
+			CertFile:      certFile,
+			KeyFile:       keyFile,
+		}
+
+		var err error
+		if tlsConf, err = tlsOption.MakeClientTLSConf(); err != nil {
+			return nil, errors.Wrap(err, "failed to load the TLS files")
+		}
+	}
+	return common.NewClientPool(tlsConf, nil), nil
+}
+
+func exec(cmd *cobra.Command, _ []string) error {
+	if config.Output == "" {
+		return errors.New("the output directory is required")
+	}
+	if err := CheckDir(config.Output); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(config.Output, ManifestFile)); err == nil {
+		return errors.Errorf("%s already contains a complete backup", config.Output)
+	}
+
+	if err := os.MkdirAll(filepath.Join(config.Output, FilesDir), 0755); err != nil {
+		return err
+	}
+
+	p, err := loadProgress()
+	if err != nil {
+		return err
+	}
+
+	pool, err := NewClientPool(config.TLS, config.TLSTrustedCaFile, config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	rpc, err := pool.GetCoordinationRpc(config.ServerAddr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	skipFiles := make([]string, 0, len(p.Files))
+	for name := range p.Files {
+		skipFiles = append(skipFiles, name)
+	}
+	sort.Strings(skipFiles)
+
+	stream, err := rpc.GetSnapshot(ctx, &proto.GetSnapshotRequest{
+		Namespace: config.Namespace,
+		Shard:     config.Shard,
+		SkipFiles: skipFiles,
+	})
+	if err != nil {
+		return err
+	}
+
+	m, err := receive(stream, p)
+	if err != nil {
+		return err
+	}
+
+	if err = removeStaleFiles(m); err != nil {
+		return err
+	}
+	if err = writeJSON(filepath.Join(config.Output, ManifestFile), m); err != nil {
+		return err
+	}
+	if err = os.Remove(filepath.Join(config.Output, progressFile)); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Backed up shard %d of namespace %s up to entry %d/%d: %d files, %s\n",
+		m.Shard, m.Namespace, m.EntryId.Term, m.EntryId.Offset, len(m.Files), humanize.IBytes(uint64(m.Size)))
+	return nil
+}
+
+// Load the files downloaded by a previous attempt, discarding the ones that
+// were not completely written.
+func loadProgress() (*progress, error) {
+	p := &progress{}
+	path := filepath.Join(config.Output, progressFile)
+	if err := readJSON(path, p); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, errors.Wrap(err, "failed to read the progress of the previous backup")
+		}
+		p.Namespace, p.Shard = config.Namespace, config.Shard
+	}
+
+	if p.Namespace != config.Namespace || p.Shard != config.Shard {
+		return nil, errors.Errorf("%s contains the backup of shard %d of namespace %s", config.Output, p.Shard, p.Namespace)
+	}
+
+	files := map[string]File{}
+	for name, f := range p.Files {
+		if f.verify(filepath.Join(config.Output, FilesDir, name)) == nil {
+			files[name] = f
+		}
+	}
+	p.Files = files
+	return p, nil
+}
+
+// Receive the chunks of the snapshot, checking them as the followers do, and
+// record each file once it's complete.
+func receive(stream proto.OxiaCoordination_GetSnapshotClient, p *progress) (*Manifest, error) {
+	m := &Manifest{
+		Namespace: config.Namespace,
+		Shard:     config.Shard,
+		CreatedAt: time.Now(),
+	}
+
+	var (
+		digest   hash.Hash = sha256.New()
+		file     *os.File
+		count    int
+		complete bool
+	)
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+		}
+	}()
+
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if complete {
+			return nil, errors.New("received a chunk after the end of the snapshot")
+		}
+		if crc := crc32.Checksum(chunk.Content, SnapshotCrcTable); crc != chunk.Crc {
+			return nil, errors.Errorf("chunk %d of file %s failed the checksum verification", chunk.ChunkIndex, chunk.Name)
+		}
+
+		if count == 0 {
+			if chunk.EntryId == nil || chunk.Int32HashRange == nil {
+				return nil, errors.New("the snapshot is missing its entry id or the hash range of the shard")
+			}
+			m.Term = chunk.Term
+			m.EntryId = EntryId{Term: chunk.EntryId.Term, Offset: chunk.EntryId.Offset}
+			m.Int32HashRange = model.Int32HashRange{
+				Min: chunk.Int32HashRange.MinHashInclusive,
+				Max: chunk.Int32HashRange.MaxHashInclusive,
+			}
+		}
+		count++
+
+		// The digest is the same as the one of the snapshots sent to the
+		// followers
+		_, _ = digest.Write([]byte(chunk.Name))
+		_, _ = digest.Write(chunk.Content)
+
+		if chunk.Omitted {
+			if _, ok := p.Files[chunk.Name]; !ok {
+				return nil, errors.Errorf("the content of file %s was omitted, though it was not downloaded", chunk.Name)
+			}
+		} else if file, err = writeChunk(file, chunk, p); err != nil {
+			return nil, err
+		}
+
+		if chunk.ChunkIndex == chunk.ChunkCount-1 {
+			f := p.Files[chunk.Name]
+			m.Files = append(m.Files, f)
+			m.Size += f.Size
+		}
+
+		if chunk.Digest != nil {
+			if !bytes.Equal(digest.Sum(nil), chunk.Digest) {
+				return nil, errors.New("the snapshot failed the digest verification")
+			}
+			complete = true
+		}
+	}
+
+	if !complete {
+		return nil, errors.New("the snapshot stream ended before the digest")
+	}
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Name < m.Files[j].Name
+	})
+	return m, nil
+}
+
+func writeChunk(file *os.File, chunk *proto.SnapshotChunk, p *progress) (*os.File, error) {
+	path := filepath.Join(config.Output, FilesDir, chunk.Name)
+	if chunk.ChunkIndex == 0 {
+		if file != nil {
+			return file, errors.Errorf("file %s started before the previous file was complete", chunk.Name)
+		}
+
+		var err error
+		if file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+			return nil, err
+		}
+	}
+	if file == nil {
+		return nil, errors.Errorf("chunk %d of file %s received before its first chunk", chunk.ChunkIndex, chunk.Name)
+	}
+
+	if _, err := file.Write(chunk.Content); err != nil {
+		return file, err
+	}
+	if chunk.ChunkIndex != chunk.ChunkCount-1 {
+		return file, nil
+	}
+
+	if err := multierr.Combine(file.Sync(), file.Close()); err != nil {
+		return nil, err
+	}
+
+	f, err := newFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p.Files[chunk.Name] = f
+	return nil, writeJSON(filepath.Join(config.Output, progressFile), p)
+}
+
+// Remove the files downloaded by a previous attempt that are not part of the
+// snapshot anymore, eg: after a compaction of the database.
+func removeStaleFiles(m *Manifest) error {
+	files := common.NewSet[string]()
+	for _, f := range m.Files {
+		files.Add(f.Name)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(config.Output, FilesDir))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !files.Contains(e.Name()) {
+			if err = os.Remove(filepath.Join(config.Output, FilesDir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/coordinator/model"
+)
+
+const (
+	// ManifestFile is written once the backup is complete
+	ManifestFile = "manifest.json"
+
+	// FilesDir holds the files of the database of the shard
+	FilesDir = "files"
+
+	// progressFile records the files already downloaded, so that an
+	// interrupted backup can be resumed
+	progressFile = "progress.json"
+)
+
+var SnapshotCrcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Manifest describes the backup of a shard.
+type Manifest struct {
+	Namespace      string               `json:"namespace"`
+	Shard          int64                `json:"shard"`
+	Term           int64                `json:"term"`
+	EntryId        EntryId              `json:"entryId"`
+	Int32HashRange model.Int32HashRange `json:"int32HashRange"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	Size           int64                `json:"size"`
+	Files          []File               `json:"files"`
+}
+
+// EntryId is the last entry included in the backup.
+type EntryId struct {
+	Term   int64 `json:"term"`
+	Offset int64 `json:"offset"`
+}
+
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+type progress struct {
+	Namespace string          `json:"namespace"`
+	Shard     int64           `json:"shard"`
+	Files     map[string]File `json:"files"`
+}
+
+// CheckDir refuses the destinations that are not local directories.
+func CheckDir(dir string) error {
+	if strings.Contains(dir, "://") {
+		return errors.Errorf("unsupported location %s: only local directories are supported", dir)
+	}
+	return nil
+}
+
+func ReadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{}
+	if err := readJSON(filepath.Join(dir, ManifestFile), m); err != nil {
+		return nil, errors.Wrapf(err, "failed to read the manifest of the backup in %s", dir)
+	}
+	return m, nil
+}
+
+// Verify checks the size and the digest of every file of the backup.
+func (m *Manifest) Verify(dir string) error {
+	for _, f := range m.Files {
+		if err := f.verify(filepath.Join(dir, FilesDir, f.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f File) verify(path string) error {
+	actual, err := newFile(path)
+	if err != nil {
+		return err
+	}
+	if actual != f {
+		return errors.Errorf("file %s of the backup is corrupted: expected size %d and sha256 %s, found size %d and sha256 %s",
+			f.Name, f.Size, f.Sha256, actual.Size, actual.Sha256)
+	}
+	return nil
+}
+
+func newFile(path string) (File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer file.Close()
+
+	digest := sha256.New()
+	size, err := io.Copy(digest, file)
+	if err != nil {
+		return File{}, err
+	}
+	return File{
+		Name:   filepath.Base(path),
+		Size:   size,
+		Sha256: hex.EncodeToString(digest.Sum(nil)),
+	}, nil
+}
+
+func readJSON(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Write the file aside and rename it, so that it's never left half written.
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,243 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cliconfig
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvConfig is the environment variable with the path of the config
+	// file, in place of ~/.oxia/config.yaml
+	EnvConfig = "OXIA_CONFIG"
+
+	// EnvContext is the environment variable with the name of the context
+	// to use, in place of the current context of the config file
+	EnvContext = "OXIA_CONTEXT"
+)
+
+var ErrContextNotFound = errors.New("context not found")
+
+type TLS struct {
+	Enabled       bool   `yaml:"enabled,omitempty"`
+	TrustedCaFile string `yaml:"trustedCaFile,omitempty"`
+	CertFile      string `yaml:"certFile,omitempty"`
+	KeyFile       string `yaml:"keyFile,omitempty"`
+}
+
+// Context holds the settings of the commands for a cluster.
+type Context struct {
+	ServiceAddress string `yaml:"serviceAddress,omitempty"`
+	AdminAddress   string `yaml:"adminAddress,omitempty"`
+
+	// Namespace is the default namespace of the client and the operator
+	// commands
+	Namespace string `yaml:"namespace,omitempty"`
+
+	TLS *TLS `yaml:"tls,omitempty"`
+
+	// AuthTokenFile is the file with the auth token, so that the token is
+	// not stored in the config file
+	AuthToken     string `yaml:"authToken,omitempty"`
+	AuthTokenFile string `yaml:"authTokenFile,omitempty"`
+}
+
+// File is the config file of the commands, with the named contexts.
+type File struct {
+	CurrentContext string              `yaml:"currentContext,omitempty"`
+	Contexts       map[string]*Context `yaml:"contexts,omitempty"`
+}
+
+// DefaultPath returns the path of the config file, which is set with
+// OXIA_CONFIG or is ~/.oxia/config.yaml.
+func DefaultPath() (string, error) {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find the home directory")
+	}
+	return filepath.Join(home, ".oxia", "config.yaml"), nil
+}
+
+// Load reads the config file. A missing file is an empty config.
+func Load(path string) (*File, error) {
+	f := &File{Contexts: map[string]*Context{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the config file %s", path)
+	}
+
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the config file %s", path)
+	}
+	if f.Contexts == nil {
+		f.Contexts = map[string]*Context{}
+	}
+	return f, nil
+}
+
+// Save writes the config file, which is only readable by the user since it
+// might hold auth tokens.
+func (f *File) Save(path string) error {
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrapf(err, "failed to create the directory of the config file %s", path)
+	}
+	return errors.Wrapf(os.WriteFile(path, data, 0o600), "failed to write the config file %s", path)
+}
+
+// Context returns the context with the given name, or the current context if
+// the name is empty. It returns nil when no name is given and there is no
+// current context.
+func (f *File) Context(name string) (*Context, error) {
+	if name == "" {
+		name = f.CurrentContext
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	c, ok := f.Contexts[name]
+	if !ok || c == nil {
+		return nil, errors.Wrapf(ErrContextNotFound, "context %q", name)
+	}
+	return c, nil
+}
+
+// ContextNames returns the names of the contexts, sorted.
+func (f *File) ContextNames() []string {
+	names := make([]string, 0, len(f.Contexts))
+	for name := range f.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type setting struct {
+	flag  string
+	env   string
+	value func(c *Context) (string, error)
+}
+
+func tlsValue(f func(t *TLS) string) func(c *Context) (string, error) {
+	return func(c *Context) (string, error) {
+		if c.TLS == nil {
+			return "", nil
+		}
+		return f(c.TLS), nil
+	}
+}
+
+// settings maps the fields of the contexts to the flags of the commands, and
+// to the environment variables that override them.
+var settings = []setting{
+	{"service-address", "OXIA_SERVICE_ADDRESS", func(c *Context) (string, error) { return c.ServiceAddress, nil }},
+	{"admin-address", "OXIA_ADMIN_ADDRESS", func(c *Context) (string, error) { return c.AdminAddress, nil }},
+	{"namespace", "OXIA_NAMESPACE", func(c *Context) (string, error) { return c.Namespace, nil }},
+	{"tls", "OXIA_TLS", tlsValue(func(t *TLS) string {
+		if !t.Enabled {
+			return ""
+		}
+		return strconv.FormatBool(t.Enabled)
+	})},
+	{"tls-trusted-ca-file", "OXIA_TLS_TRUSTED_CA_FILE", tlsValue(func(t *TLS) string { return t.TrustedCaFile })},
+	{"tls-cert-file", "OXIA_TLS_CERT_FILE", tlsValue(func(t *TLS) string { return t.CertFile })},
+	{"tls-key-file", "OXIA_TLS_KEY_FILE", tlsValue(func(t *TLS) string { return t.KeyFile })},
+	{"auth-token", "OXIA_AUTH_TOKEN", authToken},
+}
+
+func authToken(c *Context) (string, error) {
+	if c.AuthToken != "" && c.AuthTokenFile != "" {
+		return "", errors.New("the auth token and the auth token file are both set")
+	}
+	if c.AuthTokenFile == "" {
+		return c.AuthToken, nil
+	}
+
+	data, err := os.ReadFile(c.AuthTokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the auth token file")
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Apply sets the flags that were not set on the command line, from the
+// environment variables or else from the context. The context is the one
+// given, or the one set with OXIA_CONTEXT, or else the current context of
+// the config file. Only the flags that the command has are set.
+func Apply(flags *pflag.FlagSet, path string, contextName string) error {
+	if contextName == "" {
+		contextName = os.Getenv(EnvContext)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		return err
+	}
+	c, err := f.Context(contextName)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range settings {
+		flag := flags.Lookup(s.flag)
+		if flag == nil || flag.Changed {
+			continue
+		}
+
+		value, ok := os.LookupEnv(s.env)
+		if !ok && c != nil {
+			if value, err = s.value(c); err != nil {
+				return errors.Wrapf(err, "invalid context %q", contextOrCurrent(f, contextName))
+			}
+		}
+		if value == "" {
+			continue
+		}
+
+		// The value is set without marking the flag as changed, so that it
+		// can be told apart from the ones on the command line
+		if err := flag.Value.Set(value); err != nil {
+			return errors.Wrapf(err, "invalid value for %s", s.flag)
+		}
+	}
+	return nil
+}
+
+func contextOrCurrent(f *File, name string) string {
+	if name == "" {
+		return f.CurrentContext
+	}
+	return name
+}
@@ -0,0 +1,224 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cliconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flagValues struct {
+	ServiceAddr string
+	Namespace   string
+	TLS         bool
+	CaFile      string
+	AuthToken   string
+}
+
+func newFlags(v *flagValues) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVarP(&v.ServiceAddr, "service-address", "a", "localhost:6648", "")
+	flags.StringVarP(&v.Namespace, "namespace", "n", "default", "")
+	flags.BoolVar(&v.TLS, "tls", false, "")
+	flags.StringVar(&v.CaFile, "tls-trusted-ca-file", "", "")
+	flags.StringVar(&v.AuthToken, "auth-token", "", "")
+	return flags
+}
+
+func writeConfig(t *testing.T, f *File) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, f.Save(path))
+	return path
+}
+
+func testConfig() *File {
+	return &File{
+		CurrentContext: "dev",
+		Contexts: map[string]*Context{
+			"dev": {
+				ServiceAddress: "localhost:7000",
+				Namespace:      "dev-ns",
+			},
+			"prod": {
+				ServiceAddress: "prod:6648",
+				AdminAddress:   "prod:6650",
+				Namespace:      "prod-ns",
+				TLS:            &TLS{Enabled: true, TrustedCaFile: "/etc/oxia/ca.crt"},
+				AuthToken:      "prod-token",
+			},
+		},
+	}
+}
+
+func TestApply_CurrentContext(t *testing.T) {
+	path := writeConfig(t, testConfig())
+
+	v := flagValues{}
+	flags := newFlags(&v)
+	require.NoError(t, flags.Parse(nil))
+	require.NoError(t, Apply(flags, path, ""))
+
+	assert.Equal(t, "localhost:7000", v.ServiceAddr)
+	assert.Equal(t, "dev-ns", v.Namespace)
+	assert.False(t, v.TLS)
+	assert.Empty(t, v.AuthToken)
+
+	// The values from the context are not marked as set on the command line
+	assert.False(t, flags.Changed("service-address"))
+}
+
+func TestApply_Precedence(t *testing.T) {
+	path := writeConfig(t, testConfig())
+	t.Setenv("OXIA_NAMESPACE", "env-ns")
+	t.Setenv("OXIA_AUTH_TOKEN", "env-token")
+
+	v := flagValues{}
+	flags := newFlags(&v)
+	require.NoError(t, flags.Parse([]string{"-n", "flag-ns"}))
+	require.NoError(t, Apply(flags, path, "prod"))
+
+	// Flags, then environment variables, then the context
+	assert.Equal(t, "flag-ns", v.Namespace)
+	assert.Equal(t, "env-token", v.AuthToken)
+	assert.Equal(t, "prod:6648", v.ServiceAddr)
+	assert.True(t, v.TLS)
+	assert.Equal(t, "/etc/oxia/ca.crt", v.CaFile)
+}
+
+func TestApply_ContextFromEnv(t *testing.T) {
+	path := writeConfig(t, testConfig())
+	t.Setenv(EnvContext, "prod")
+
+	v := flagValues{}
+	flags := newFlags(&v)
+	require.NoError(t, flags.Parse(nil))
+	require.NoError(t, Apply(flags, path, ""))
+	assert.Equal(t, "prod:6648", v.ServiceAddr)
+
+	// The --context flag takes precedence
+	v = flagValues{}
+	flags = newFlags(&v)
+	require.NoError(t, flags.Parse(nil))
+	require.NoError(t, Apply(flags, path, "dev"))
+	assert.Equal(t, "localhost:7000", v.ServiceAddr)
+}
+
+func TestApply_MissingContext(t *testing.T) {
+	path := writeConfig(t, testConfig())
+
+	v := flagValues{}
+	flags := newFlags(&v)
+	err := Apply(flags, path, "staging")
+	assert.ErrorIs(t, err, ErrContextNotFound)
+	assert.ErrorContains(t, err, `"staging"`)
+
+	t.Setenv(EnvContext, "staging")
+	assert.ErrorIs(t, Apply(flags, path, ""), ErrContextNotFound)
+
+	// A current context that was removed from the file
+	f := testConfig()
+	f.CurrentContext = "removed"
+	path = writeConfig(t, f)
+	t.Setenv(EnvContext, "")
+	assert.ErrorIs(t, Apply(flags, path, ""), ErrContextNotFound)
+}
+
+func TestApply_NoConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+
+	v := flagValues{}
+	flags := newFlags(&v)
+	require.NoError(t, flags.Parse(nil))
+	require.NoError(t, Apply(flags, path, ""))
+	assert.Equal(t, "localhost:6648", v.ServiceAddr)
+
+	// Without a config file, the environment variables are still applied
+	t.Setenv("OXIA_SERVICE_ADDRESS", "env:6648")
+	require.NoError(t, Apply(flags, path, ""))
+	assert.Equal(t, "env:6648", v.ServiceAddr)
+
+	assert.ErrorIs(t, Apply(flags, path, "dev"), ErrContextNotFound)
+}
+
+func TestApply_AuthTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0o600))
+
+	f := testConfig()
+	f.Contexts["prod"].AuthToken = ""
+	f.Contexts["prod"].AuthTokenFile = tokenFile
+	path := writeConfig(t, f)
+
+	v := flagValues{}
+	flags := newFlags(&v)
+	require.NoError(t, flags.Parse(nil))
+	require.NoError(t, Apply(flags, path, "prod"))
+	assert.Equal(t, "file-token", v.AuthToken)
+
+	// The token file is not read when the token is on the command line
+	require.NoError(t, os.Remove(tokenFile))
+	v = flagValues{}
+	flags = newFlags(&v)
+	require.NoError(t, flags.Parse([]string{"--auth-token", "flag-token"}))
+	require.NoError(t, Apply(flags, path, "prod"))
+	assert.Equal(t, "flag-token", v.AuthToken)
+
+	v = flagValues{}
+	flags = newFlags(&v)
+	require.NoError(t, flags.Parse(nil))
+	assert.ErrorContains(t, Apply(flags, path, "prod"), "failed to read the auth token file")
+
+	f.Contexts["prod"].AuthToken = "inline-token"
+	path = writeConfig(t, f)
+	assert.ErrorContains(t, Apply(flags, path, "prod"), "are both set")
+}
+
+func TestApply_OnlyExistingFlags(t *testing.T) {
+	path := writeConfig(t, testConfig())
+
+	var adminAddr string
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&adminAddr, "admin-address", "localhost:6650", "")
+	require.NoError(t, flags.Parse(nil))
+	require.NoError(t, Apply(flags, path, "prod"))
+	assert.Equal(t, "prod:6650", adminAddr)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("contexts: [\n"), 0o600))
+
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "failed to parse the config file")
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv(EnvConfig, "/tmp/oxia.yaml")
+	path, err := DefaultPath()
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/oxia.yaml", path)
+
+	t.Setenv(EnvConfig, "")
+	t.Setenv("HOME", "/home/oxia")
+	path, err = DefaultPath()
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/oxia/.oxia/config.yaml", path)
+}
@@ -47,6 +47,13 @@ func init() {
 	Cmd.PersistentFlags().StringVarP(&common.Config.Namespace, "namespace", "n", oxia.DefaultNamespace, "The Oxia namespace to use")
 	Cmd.PersistentFlags().DurationVar(&common.Config.RequestTimeout, "request-timeout", oxia.DefaultRequestTimeout, "Requests timeout")
 
+	// TLS and authentication section
+	Cmd.PersistentFlags().BoolVar(&common.Config.TLS, "tls", false, "Connect with TLS, verifying the servers with the system CAs if no trusted ca file is set")
+	Cmd.PersistentFlags().StringVar(&common.Config.TLSTrustedCaFile, "tls-trusted-ca-file", "", "Tls trusted ca file")
+	Cmd.PersistentFlags().StringVar(&common.Config.TLSCertFile, "tls-cert-file", "", "Tls client certificate file")
+	Cmd.PersistentFlags().StringVar(&common.Config.TLSKeyFile, "tls-key-file", "", "Tls client key file")
+	Cmd.PersistentFlags().StringVar(&common.Config.AuthToken, "auth-token", "", "Token sent to authenticate the requests")
+
 	Cmd.AddCommand(put.Cmd)
 	Cmd.AddCommand(del.Cmd)
 	Cmd.AddCommand(get.Cmd)
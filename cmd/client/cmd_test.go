@@ -61,6 +61,10 @@ func TestClientCmd(t *testing.T) {
 		{"list-none", "list --key-min XXX --key-max XXY", "", "", "", false},
 		{"list-all", "list --key-min a --key-max z", "", "k-put\nk-put-stdin\n", "", false},
 		{"list-all-short", "list -s a -e z", "", "k-put\nk-put-stdin\n", "", false},
+		{"put-value-file", "put k-put-file -v -", "test-4", "", "", false},
+		{"get-value-file", "get k-put-file", "", "test-4\n", "", false},
+		{"get-json", "get k-put-file -o json", "", "", "", false},
+		{"list-args", "list a z", "", "k-put\nk-put-file\nk-put-stdin\n", "", false},
 		{"delete", "delete k-put-stdin", "", "", "", false},
 		{"delete-not-exist", "delete does-not-exist", "", "", "Error: key not found", true},
 		{"delete-unexpected-version", "delete k-put -e 9", "", "", "Error: unexpected version id", true},
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/streamnative/oxia/oxia"
+	"github.com/streamnative/oxia/oxia/auth"
 )
 
 var (
@@ -29,6 +30,12 @@ type ClientConfig struct {
 	ServiceAddr    string
 	Namespace      string
 	RequestTimeout time.Duration
+
+	TLS              bool
+	TLSTrustedCaFile string
+	TLSCertFile      string
+	TLSKeyFile       string
+	AuthToken        string
 }
 
 func (ClientConfig) NewClient() (oxia.SyncClient, error) {
@@ -36,8 +43,15 @@ func (ClientConfig) NewClient() (oxia.SyncClient, error) {
 		return MockedClient, nil
 	}
 
-	return oxia.NewSyncClient(Config.ServiceAddr,
+	options := []oxia.ClientOption{
 		oxia.WithRequestTimeout(Config.RequestTimeout),
 		oxia.WithNamespace(Config.Namespace),
-	)
+	}
+	if Config.TLS || Config.TLSTrustedCaFile != "" || Config.TLSCertFile != "" {
+		options = append(options, oxia.WithTLSFromFiles(Config.TLSTrustedCaFile, Config.TLSCertFile, Config.TLSKeyFile))
+	}
+	if Config.AuthToken != "" {
+		options = append(options, oxia.WithAuthentication(auth.NewTokenAuthenticationWithToken(Config.AuthToken, false)))
+	}
+	return oxia.NewSyncClient(Config.ServiceAddr, options...)
 }
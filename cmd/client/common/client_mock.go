@@ -57,7 +57,20 @@ func (m *MockClient) Get(_ context.Context, key string, options ...oxia.GetOptio
 	if !ok {
 		panic("cast failed")
 	}
-	return args.String(0), arg1, oxia.Version{}, args.Error(3)
+	arg2, ok := args.Get(2).(oxia.Version)
+	if !ok {
+		panic("cast failed")
+	}
+	return args.String(0), arg1, arg2, args.Error(3)
+}
+
+func (m *MockClient) GetMany(_ context.Context, keys []string, options ...oxia.GetOption) []oxia.GetResult {
+	args := m.MethodCalled("GetMany", keys, options)
+	arg0, ok := args.Get(0).([]oxia.GetResult)
+	if !ok {
+		panic("cast failed")
+	}
+	return arg0
 }
 
 func (m *MockClient) List(_ context.Context, minKeyInclusive string, maxKeyExclusive string, options ...oxia.ListOption) (keys []string, err error) {
@@ -78,6 +91,6 @@ func (m *MockClient) RangeScan(_ context.Context, minKeyInclusive string, maxKey
 	return arg0
 }
 
-func (*MockClient) GetNotifications() (oxia.Notifications, error) {
+func (*MockClient) GetNotifications(...oxia.NotificationsOption) (oxia.Notifications, error) {
 	return nil, errors.New("not implemented in mock")
 }
@@ -14,7 +14,17 @@
 
 package common
 
-import "time"
+import (
+	"time"
+	"unicode/utf8"
+
+	"github.com/streamnative/oxia/oxia"
+)
+
+const (
+	OutputFormatPlain = "plain"
+	OutputFormatJSON  = "json"
+)
 
 type OutputVersion struct {
 	Key                string    `json:"key"`
@@ -26,6 +36,41 @@ type OutputVersion struct {
 	ClientIdentity     string    `json:"client_identity"`
 }
 
+func NewOutputVersion(key string, version oxia.Version) OutputVersion {
+	return OutputVersion{
+		Key:                key,
+		VersionId:          version.VersionId,
+		CreatedTimestamp:   time.UnixMilli(int64(version.CreatedTimestamp)),
+		ModifiedTimestamp:  time.UnixMilli(int64(version.ModifiedTimestamp)),
+		ModificationsCount: version.ModificationsCount,
+		Ephemeral:          version.Ephemeral,
+		ClientIdentity:     version.ClientIdentity,
+	}
+}
+
+// OutputRecord is a record in JSON format. The values that are not valid
+// UTF-8 text are encoded in base64.
+type OutputRecord struct {
+	Key         string        `json:"key"`
+	Value       *string       `json:"value,omitempty"`
+	ValueBase64 []byte        `json:"value_base64,omitempty"`
+	Version     OutputVersion `json:"version"`
+}
+
+func NewOutputRecord(key string, value []byte, version oxia.Version) OutputRecord {
+	record := OutputRecord{
+		Key:     key,
+		Version: NewOutputVersion(key, version),
+	}
+	if utf8.Valid(value) {
+		v := string(value)
+		record.Value = &v
+	} else {
+		record.ValueBase64 = value
+	}
+	return record
+}
+
 type OutputError struct {
 	Err string `json:"error,omitempty"`
 }
@@ -16,7 +16,6 @@ package get
 
 import (
 	"context"
-	"time"
 
 	"github.com/pkg/errors"
 
@@ -37,6 +36,7 @@ type flags struct {
 	includeVersion bool
 	partitionKey   string
 	comparisonType string
+	output         string
 }
 
 func (flags *flags) Reset() {
@@ -45,6 +45,7 @@ func (flags *flags) Reset() {
 	flags.includeVersion = false
 	flags.partitionKey = ""
 	flags.comparisonType = "equal"
+	flags.output = common.OutputFormatPlain
 }
 
 func init() {
@@ -54,6 +55,8 @@ func init() {
 
 	Cmd.Flags().StringVarP(&Config.comparisonType, "comparison-type", "t", "equal",
 		"The type of get comparison. Allowed value: equal, floor, ceiling, lower, higher")
+	Cmd.Flags().StringVarP(&Config.output, "output", "o", common.OutputFormatPlain,
+		"The output format. Allowed value: plain, json. The json output includes the record version")
 }
 
 var Cmd = &cobra.Command{
@@ -90,12 +93,21 @@ func exec(cmd *cobra.Command, args []string) error {
 		return errors.Errorf("invalid comparison type: %s", Config.comparisonType)
 	}
 
+	if Config.output != common.OutputFormatPlain && Config.output != common.OutputFormatJSON {
+		return errors.Errorf("invalid output format: %s", Config.output)
+	}
+
 	queryKey := args[0]
 	key, value, version, err := client.Get(context.Background(), queryKey, options...)
 	if err != nil {
 		return err
 	}
 
+	if Config.output == common.OutputFormatJSON {
+		common.WriteOutput(cmd.OutOrStdout(), common.NewOutputRecord(key, value, version))
+		return nil
+	}
+
 	if Config.hexDump {
 		common.WriteHexDump(cmd.OutOrStdout(), value)
 	} else {
@@ -104,15 +116,7 @@ func exec(cmd *cobra.Command, args []string) error {
 
 	if Config.includeVersion {
 		_, _ = cmd.OutOrStdout().Write([]byte("---\n"))
-		common.WriteOutput(cmd.OutOrStdout(), common.OutputVersion{
-			Key:                key,
-			VersionId:          version.VersionId,
-			CreatedTimestamp:   time.UnixMilli(int64(version.CreatedTimestamp)),
-			ModifiedTimestamp:  time.UnixMilli(int64(version.ModifiedTimestamp)),
-			ModificationsCount: version.ModificationsCount,
-			Ephemeral:          version.Ephemeral,
-			ClientIdentity:     version.ClientIdentity,
-		})
+		common.WriteOutput(cmd.OutOrStdout(), common.NewOutputVersion(key, version))
 	}
 	return nil
 }
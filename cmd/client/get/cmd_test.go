@@ -16,6 +16,7 @@ package get
 
 import (
 	"bytes"
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -68,3 +69,41 @@ func Test_exec(t *testing.T) {
 		})
 	}
 }
+
+func TestGet_output(t *testing.T) {
+	var emptyOptions []oxia.GetOption
+	version := oxia.Version{VersionId: 3, ModificationsCount: 2}
+	text := "value-x"
+	for _, test := range []struct {
+		name     string
+		args     string
+		value    []byte
+		expected common.OutputRecord
+	}{
+		{"json", "x -o json", []byte(text),
+			common.OutputRecord{Key: "x", Value: &text, Version: common.NewOutputVersion("x", version)}},
+		{"json-binary", "x -o json", []byte{0xff, 0x00},
+			common.OutputRecord{Key: "x", ValueBase64: []byte{0xff, 0x00}, Version: common.NewOutputVersion("x", version)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			common.MockedClient = common.NewMockClient()
+
+			common.MockedClient.On("Get", "x", emptyOptions).Return("x", test.value, version, nil)
+			out, err := runCmd(Cmd, test.args, "")
+			assert.NoError(t, err)
+
+			var record common.OutputRecord
+			assert.NoError(t, json.Unmarshal([]byte(out), &record))
+			assert.Equal(t, test.expected.Key, record.Key)
+			assert.Equal(t, test.expected.Value, record.Value)
+			assert.Equal(t, test.expected.ValueBase64, record.ValueBase64)
+			assert.Equal(t, test.expected.Version.VersionId, record.Version.VersionId)
+			assert.Equal(t, test.expected.Version.ModificationsCount, record.Version.ModificationsCount)
+
+			common.MockedClient.AssertExpectations(t)
+		})
+	}
+
+	_, err := runCmd(Cmd, "x -o yaml", "")
+	assert.EqualError(t, err, "invalid output format: yaml")
+}
@@ -16,6 +16,7 @@ package list
 
 import (
 	"context"
+	"errors"
 
 	"github.com/spf13/cobra"
 
@@ -47,14 +48,24 @@ func init() {
 }
 
 var Cmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [flags] [KEY_MIN [KEY_MAX]]",
 	Short: "List keys",
-	Long:  `List keys that fall within the given key range.`,
-	Args:  cobra.NoArgs,
+	Long:  `List keys that fall within the given key range. The range can be given either as arguments or with the flags.`,
+	Args:  cobra.RangeArgs(0, 2),
 	RunE:  exec,
 }
 
-func exec(cmd *cobra.Command, _ []string) error {
+func exec(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		if Config.keyMin != "" || Config.keyMax != "" {
+			return errors.New("the key range can either be provided as arguments or with the flags")
+		}
+		Config.keyMin = args[0]
+		if len(args) == 2 {
+			Config.keyMax = args[1]
+		}
+	}
+
 	client, err := common.Config.NewClient()
 	if err != nil {
 		return err
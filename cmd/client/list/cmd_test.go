@@ -50,6 +50,8 @@ func TestList_exec(t *testing.T) {
 		{"short", "-s a -e c", []any{"a", "c", emptyOptions}},
 		{"range-no-min", "--key-max c", []any{"", "c", emptyOptions}},
 		{"range-no-max", "--key-min a", []any{"a", "__oxia/", emptyOptions}},
+		{"args", "a c", []any{"a", "c", emptyOptions}},
+		{"args-no-max", "a", []any{"a", "__oxia/", emptyOptions}},
 		{"partition-key", "-s a -e c -p xyz", []any{"a", "c", []oxia.ListOption{oxia.PartitionKey("xyz")}}},
 	} {
 		t.Run(test.name, func(t *testing.T) {
@@ -63,3 +65,12 @@ func TestList_exec(t *testing.T) {
 		})
 	}
 }
+
+func TestList_rangeArgsAndFlags(t *testing.T) {
+	common.MockedClient = common.NewMockClient()
+
+	_, err := runCmd(Cmd, "a c -s a", "")
+	assert.EqualError(t, err, "the key range can either be provided as arguments or with the flags")
+
+	common.MockedClient.AssertExpectations(t)
+}
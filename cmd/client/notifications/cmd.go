@@ -16,6 +16,7 @@ package notifications
 
 import (
 	"log/slog"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -51,6 +52,7 @@ func exec(_ *cobra.Command, _ []string) error {
 			slog.Any("type", notification.Type),
 			slog.String("key", notification.Key),
 			slog.Int64("version-id", notification.VersionId),
+			slog.Time("modified-timestamp", time.UnixMilli(int64(notification.ModifiedTimestamp))),
 		)
 	}
 
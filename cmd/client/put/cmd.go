@@ -18,7 +18,7 @@ import (
 	"context"
 	"errors"
 	"io"
-	"time"
+	"os"
 
 	"github.com/spf13/cobra"
 
@@ -34,6 +34,7 @@ var (
 type flags struct {
 	expectedVersion    int64
 	readValueFromStdIn bool
+	valueFile          string
 	partitionKey       string
 	sequenceKeysDeltas []int64
 }
@@ -41,6 +42,7 @@ type flags struct {
 func (flags *flags) Reset() {
 	flags.expectedVersion = -1
 	flags.readValueFromStdIn = false
+	flags.valueFile = ""
 	flags.partitionKey = ""
 	flags.sequenceKeysDeltas = nil
 }
@@ -48,6 +50,7 @@ func (flags *flags) Reset() {
 func init() {
 	Cmd.Flags().Int64VarP(&Config.expectedVersion, "expected-version", "e", -1, "Version of entry expected to be on the server")
 	Cmd.Flags().BoolVarP(&Config.readValueFromStdIn, "std-in", "c", false, "Read value from stdin")
+	Cmd.Flags().StringVarP(&Config.valueFile, "value-file", "v", "", "Read value from the file, or from stdin if the file is '-'")
 	Cmd.Flags().StringVarP(&Config.partitionKey, "partition-key", "p", "", "Partition Key to be used in override the shard routing")
 	Cmd.Flags().Int64SliceVarP(&Config.sequenceKeysDeltas, "sequence-keys-deltas", "d", nil, "Specify one or more sequence keys deltas to be added to the inserted key")
 }
@@ -68,19 +71,9 @@ func exec(cmd *cobra.Command, args []string) error {
 	}
 
 	key := args[0]
-	var value []byte
-	if len(args) == 2 { //nolint:gocritic
-		// We have a value specified as argument
-		if Config.readValueFromStdIn {
-			return errors.New("the value can either be provided as argument or read from std-in")
-		}
-		value = []byte(args[1])
-	} else if len(args) == 1 && !Config.readValueFromStdIn {
-		return errors.New("no value provided for the record")
-	} else {
-		if value, err = io.ReadAll(cmd.InOrStdin()); err != nil {
-			return err
-		}
+	value, err := readValue(cmd, args)
+	if err != nil {
+		return err
 	}
 
 	key, version, err := client.Put(context.Background(), key, value, getOptions()...)
@@ -88,18 +81,33 @@ func exec(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	common.WriteOutput(cmd.OutOrStdout(), common.OutputVersion{
-		Key:                key,
-		VersionId:          version.VersionId,
-		CreatedTimestamp:   time.UnixMilli(int64(version.CreatedTimestamp)),
-		ModifiedTimestamp:  time.UnixMilli(int64(version.ModifiedTimestamp)),
-		ModificationsCount: version.ModificationsCount,
-		Ephemeral:          version.Ephemeral,
-		ClientIdentity:     version.ClientIdentity,
-	})
+	common.WriteOutput(cmd.OutOrStdout(), common.NewOutputVersion(key, version))
 	return nil
 }
 
+// readValue reads the value from the argument, from the file or from stdin,
+// so that binary values can be put too.
+func readValue(cmd *cobra.Command, args []string) ([]byte, error) {
+	sources := 0
+	for _, set := range []bool{len(args) == 2, Config.readValueFromStdIn, Config.valueFile != ""} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources == 0:
+		return nil, errors.New("no value provided for the record")
+	case sources > 1:
+		return nil, errors.New("the value can either be provided as argument, read from a file or from std-in")
+	case len(args) == 2:
+		return []byte(args[1]), nil
+	case Config.readValueFromStdIn || Config.valueFile == "-":
+		return io.ReadAll(cmd.InOrStdin())
+	default:
+		return os.ReadFile(Config.valueFile)
+	}
+}
+
 func getOptions() []oxia.PutOption {
 	var options []oxia.PutOption
 	if Config.expectedVersion != -1 {
@@ -16,10 +16,13 @@ package put
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
 
 	"github.com/streamnative/oxia/cmd/client/common"
 	"github.com/streamnative/oxia/oxia"
@@ -37,6 +40,9 @@ func runCmd(cmd *cobra.Command, args string, stdin string) (string, error) {
 }
 
 func TestPut_exec(t *testing.T) {
+	valueFile := filepath.Join(t.TempDir(), "value")
+	assert.NoError(t, os.WriteFile(valueFile, []byte{0, 1, 2}, 0600))
+
 	var emptyOptions []oxia.PutOption
 	for _, test := range []struct {
 		name               string
@@ -47,6 +53,8 @@ func TestPut_exec(t *testing.T) {
 		{"entry", "x y", "", []any{"x", []byte("y"), emptyOptions}},
 		{"entry-expected-version", "x y -e 5", "", []any{"x", []byte("y"), []oxia.PutOption{oxia.ExpectedVersionId(5)}}},
 		{"stdin", "x -c -e 5", "my-value", []any{"x", []byte("my-value"), []oxia.PutOption{oxia.ExpectedVersionId(5)}}},
+		{"value-file", "x -v " + valueFile, "", []any{"x", []byte{0, 1, 2}, emptyOptions}},
+		{"value-file-stdin", "x -v -", "my-value", []any{"x", []byte("my-value"), emptyOptions}},
 		{"partition-key", "x y -p abc", "", []any{"x", []byte("y"), []oxia.PutOption{oxia.PartitionKey("abc")}}},
 		{"sequence-keys", "x y -p abc -d 1,2,3", "", []any{"x", []byte("y"),
 			[]oxia.PutOption{oxia.PartitionKey("abc"), oxia.SequenceKeysDeltas(1, 2, 3)}}},
@@ -55,9 +63,23 @@ func TestPut_exec(t *testing.T) {
 			common.MockedClient = common.NewMockClient()
 
 			common.MockedClient.On("Put", test.expectedParameters...).Return(test.expectedParameters[0], oxia.Version{}, nil)
-			_, _ = runCmd(Cmd, test.args, test.stdin)
+			_, err := runCmd(Cmd, test.args, test.stdin)
+			assert.NoError(t, err)
 
 			common.MockedClient.AssertExpectations(t)
 		})
 	}
 }
+
+func TestPut_valueSources(t *testing.T) {
+	common.MockedClient = common.NewMockClient()
+
+	_, err := runCmd(Cmd, "x", "")
+	assert.EqualError(t, err, "no value provided for the record")
+	_, err = runCmd(Cmd, "x y -c", "my-value")
+	assert.EqualError(t, err, "the value can either be provided as argument, read from a file or from std-in")
+	_, err = runCmd(Cmd, "x -c -v -", "my-value")
+	assert.Error(t, err)
+
+	common.MockedClient.AssertExpectations(t)
+}
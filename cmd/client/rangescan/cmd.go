@@ -16,7 +16,6 @@ package rangescan
 
 import (
 	"context"
-	"time"
 
 	"github.com/streamnative/oxia/oxia"
 
@@ -101,16 +100,7 @@ func exec(cmd *cobra.Command, _ []string) error {
 		if Config.includeVersion {
 			_, _ = cmd.OutOrStdout().Write([]byte("---\n"))
 
-			version := result.Version
-			common.WriteOutput(cmd.OutOrStdout(), common.OutputVersion{
-				Key:                result.Key,
-				VersionId:          version.VersionId,
-				CreatedTimestamp:   time.UnixMilli(int64(version.CreatedTimestamp)),
-				ModifiedTimestamp:  time.UnixMilli(int64(version.ModifiedTimestamp)),
-				ModificationsCount: version.ModificationsCount,
-				Ephemeral:          version.Ephemeral,
-				ClientIdentity:     version.ClientIdentity,
-			})
+			common.WriteOutput(cmd.OutOrStdout(), common.NewOutputVersion(result.Key, result.Version))
 		}
 	}
 
@@ -0,0 +1,290 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/security"
+	"github.com/streamnative/oxia/coordinator/impl"
+	"github.com/streamnative/oxia/oxia/auth"
+)
+
+const (
+	outputPlain = "plain"
+	outputJSON  = "json"
+)
+
+type Config struct {
+	AdminAddr      string
+	RequestTimeout time.Duration
+	Watch          bool
+	WatchInterval  time.Duration
+	Output         string
+
+	TLS              bool
+	TLSTrustedCaFile string
+	TLSCertFile      string
+	TLSKeyFile       string
+	AuthToken        string
+}
+
+func NewConfig() Config {
+	return Config{
+		AdminAddr:      fmt.Sprintf("localhost:%d", common.DefaultAdminPort),
+		RequestTimeout: 1 * time.Minute,
+		WatchInterval:  5 * time.Second,
+		Output:         outputPlain,
+	}
+}
+
+var (
+	config = NewConfig()
+
+	Cmd = &cobra.Command{
+		Use:   "cluster",
+		Short: "Cluster utils",
+		Long:  `Operations to check the health of the cluster, through the admin service of the coordinator`,
+	}
+
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Print the status of the cluster",
+		Long: `Print the shards, with their leader and the members of their ensemble, after a summary of the problems of ` +
+			`the cluster. The command fails when a shard has no leader, unless --watch is set, in which case the ` +
+			`status is refreshed until interrupted.`,
+		Args:         cobra.NoArgs,
+		RunE:         exec,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	Cmd.PersistentFlags().StringVar(&config.AdminAddr, "admin-address", config.AdminAddr, "Coordinator admin service address")
+	Cmd.PersistentFlags().DurationVar(&config.RequestTimeout, "request-timeout", config.RequestTimeout, "Requests timeout")
+
+	// TLS and authentication section
+	Cmd.PersistentFlags().BoolVar(&config.TLS, "tls", false, "Connect with TLS, verifying the servers with the system CAs if no trusted ca file is set")
+	Cmd.PersistentFlags().StringVar(&config.TLSTrustedCaFile, "tls-trusted-ca-file", "", "Tls trusted ca file")
+	Cmd.PersistentFlags().StringVar(&config.TLSCertFile, "tls-cert-file", "", "Tls client certificate file")
+	Cmd.PersistentFlags().StringVar(&config.TLSKeyFile, "tls-key-file", "", "Tls client key file")
+	Cmd.PersistentFlags().StringVar(&config.AuthToken, "auth-token", "", "Token sent to authenticate the requests")
+
+	statusCmd.Flags().BoolVarP(&config.Watch, "watch", "w", false, "Refresh the status periodically")
+	statusCmd.Flags().DurationVar(&config.WatchInterval, "watch-interval", config.WatchInterval, "Interval between the refreshes of the status")
+	statusCmd.Flags().StringVarP(&config.Output, "output", "o", config.Output, "Output format. Allowed value: plain, json")
+	Cmd.AddCommand(statusCmd)
+}
+
+// Status is the status of the cluster, in JSON format.
+type Status struct {
+	ShardsWithoutLeader int               `json:"shardsWithoutLeader"`
+	ServersDown         []string          `json:"serversDown"`
+	Shards              []impl.ShardInfo  `json:"shards"`
+	Servers             []impl.ServerInfo `json:"servers"`
+}
+
+func exec(cmd *cobra.Command, _ []string) error {
+	if config.Output != outputPlain && config.Output != outputJSON {
+		return errors.Errorf("invalid output format: %s", config.Output)
+	}
+
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+
+	if !config.Watch {
+		status, err := client.status(context.Background())
+		if err != nil {
+			return err
+		}
+		if err = printStatus(cmd.OutOrStdout(), status); err != nil {
+			return err
+		}
+		if status.ShardsWithoutLeader > 0 {
+			return errors.Errorf("%d shards without leader", status.ShardsWithoutLeader)
+		}
+		return nil
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ticker := time.NewTicker(config.WatchInterval)
+	defer ticker.Stop()
+	for {
+		// The status is refreshed even when the coordinator is not reachable
+		status, err := client.status(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Failed to get the status of the cluster: %v\n", err)
+		default:
+			if config.Output == outputPlain {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "--- %s\n", time.Now().Format(time.RFC3339))
+			}
+			if err = printStatus(cmd.OutOrStdout(), status); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func printStatus(out io.Writer, status *Status) error {
+	if config.Output == outputJSON {
+		return json.NewEncoder(out).Encode(status)
+	}
+
+	if status.ShardsWithoutLeader == 0 && len(status.ServersDown) == 0 {
+		_, _ = fmt.Fprintf(out, "All the %d shards have a leader and all the %d servers are running\n",
+			len(status.Shards), len(status.Servers))
+	}
+	if status.ShardsWithoutLeader > 0 {
+		_, _ = fmt.Fprintf(out, "%d shards without leader\n", status.ShardsWithoutLeader)
+	}
+	if len(status.ServersDown) > 0 {
+		_, _ = fmt.Fprintf(out, "%d servers down: %s\n", len(status.ServersDown), strings.Join(status.ServersDown, ", "))
+	}
+	_, _ = fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAMESPACE\tSHARD\tSTATUS\tTERM\tLEADER\tENSEMBLE")
+	for _, shard := range status.Shards {
+		leader := "-"
+		if shard.Leader != nil {
+			leader = shard.Leader.Internal
+		}
+
+		members := make([]string, 0, len(shard.Ensemble))
+		for _, member := range shard.Ensemble {
+			if member.InSync {
+				members = append(members, member.Internal)
+			} else {
+				members = append(members, member.Internal+" (lagging)")
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%s\n", shard.Namespace, shard.Shard, shard.Status, shard.Term,
+			leader, strings.Join(members, ", "))
+	}
+	return w.Flush()
+}
+
+type adminClient struct {
+	client   *http.Client
+	scheme   string
+	metadata map[string]string
+}
+
+func newAdminClient() (*adminClient, error) {
+	c := &adminClient{
+		client: &http.Client{},
+		scheme: "http",
+	}
+
+	if config.TLS || config.TLSTrustedCaFile != "" || config.TLSCertFile != "" {
+		tlsOption := security.TLSOption{
+			TrustedCaFile: config.TLSTrustedCaFile,
+			CertFile:      config.TLSCertFile,
+			KeyFile:       config.TLSKeyFile,
+		}
+		tlsConf, err := tlsOption.MakeClientTLSConf()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load the TLS files")
+		}
+		c.client.Transport = &http.Transport{TLSClientConfig: tlsConf}
+		c.scheme = "https"
+	}
+
+	if config.AuthToken != "" {
+		var err error
+		if c.metadata, err = auth.NewTokenAuthenticationWithToken(config.AuthToken, false).
+			GetRequestMetadata(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *adminClient) status(ctx context.Context) (*Status, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout)
+	defer cancel()
+
+	status := &Status{ServersDown: []string{}}
+	if err := c.get(ctx, "/admin/shards", &status.Shards); err != nil {
+		return nil, err
+	}
+	if err := c.get(ctx, "/admin/servers", &status.Servers); err != nil {
+		return nil, err
+	}
+
+	for _, shard := range status.Shards {
+		if shard.Leader == nil {
+			status.ShardsWithoutLeader++
+		}
+	}
+	for _, server := range status.Servers {
+		if server.Status == impl.NotRunning.String() {
+			status.ServersDown = append(status.ServersDown, server.Internal)
+		}
+	}
+	return status, nil
+}
+
+func (c *adminClient) get(ctx context.Context, path string, res any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.scheme+"://"+config.AdminAddr+path, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.metadata {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return errors.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/coordinator/impl"
+	"github.com/streamnative/oxia/coordinator/model"
+)
+
+var (
+	s1 = model.ServerAddress{Public: "s1:6648", Internal: "s1:6649"}
+	s2 = model.ServerAddress{Public: "s2:6648", Internal: "s2:6649"}
+	s3 = model.ServerAddress{Public: "s3:6648", Internal: "s3:6649"}
+)
+
+func newCoordinatorStub(t *testing.T, shards []impl.ShardInfo, servers []impl.ServerInfo) (*httptest.Server, *string) {
+	t.Helper()
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("authorization")
+		switch r.URL.Path {
+		case "/admin/shards":
+			assert.NoError(t, json.NewEncoder(w).Encode(shards))
+		case "/admin/servers":
+			assert.NoError(t, json.NewEncoder(w).Encode(servers))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server, &authorization
+}
+
+func runStatus(t *testing.T, server *httptest.Server, args ...string) (string, error) {
+	t.Helper()
+	config = NewConfig()
+	out := &bytes.Buffer{}
+	Cmd.SetOut(out)
+	Cmd.SetArgs(append([]string{"status",
+		"--admin-address=" + strings.TrimPrefix(server.URL, "http://"),
+		"--output=plain",
+		"--auth-token=",
+	}, args...))
+	err := Cmd.Execute()
+	return out.String(), err
+}
+
+func TestClusterStatus_Healthy(t *testing.T) {
+	server, authorization := newCoordinatorStub(t, []impl.ShardInfo{
+		{Namespace: "default", Shard: 0, Status: "SteadyState", Term: 2, Leader: &s1, Ensemble: []impl.ShardMemberInfo{
+			{ServerAddress: s1, InSync: true}, {ServerAddress: s2, InSync: true}, {ServerAddress: s3, InSync: false},
+		}},
+	}, []impl.ServerInfo{
+		{ServerAddress: s1, Status: "Running"},
+		{ServerAddress: s2, Status: "Running"},
+		{ServerAddress: s3, Status: "Running"},
+	})
+	defer server.Close()
+
+	out, err := runStatus(t, server, "--auth-token=my-token")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer my-token", *authorization)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, []string{
+		"All the 1 shards have a leader and all the 3 servers are running",
+		"",
+		"NAMESPACE  SHARD  STATUS       TERM  LEADER   ENSEMBLE",
+		"default    0      SteadyState  2     s1:6649  s1:6649, s2:6649, s3:6649 (lagging)",
+	}, lines)
+}
+
+func TestClusterStatus_Problems(t *testing.T) {
+	server, _ := newCoordinatorStub(t, []impl.ShardInfo{
+		{Namespace: "default", Shard: 0, Status: "SteadyState", Term: 2, Leader: &s1, Ensemble: []impl.ShardMemberInfo{
+			{ServerAddress: s1, InSync: true}, {ServerAddress: s2, InSync: false},
+		}},
+		{Namespace: "default", Shard: 1, Status: "Election", Term: 3, Ensemble: []impl.ShardMemberInfo{
+			{ServerAddress: s2, InSync: false}, {ServerAddress: s3, InSync: true},
+		}},
+	}, []impl.ServerInfo{
+		{ServerAddress: s1, Status: "Running"},
+		{ServerAddress: s2, Status: "NotRunning"},
+		{ServerAddress: s3, Status: "Draining"},
+	})
+	defer server.Close()
+
+	out, err := runStatus(t, server)
+	assert.EqualError(t, err, "1 shards without leader")
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, []string{
+		"1 shards without leader",
+		"1 servers down: s2:6649",
+		"",
+		"NAMESPACE  SHARD  STATUS       TERM  LEADER   ENSEMBLE",
+		"default    0      SteadyState  2     s1:6649  s1:6649, s2:6649 (lagging)",
+		"default    1      Election     3     -        s2:6649 (lagging), s3:6649",
+	}, lines)
+
+	out, err = runStatus(t, server, "-o", "json")
+	assert.Error(t, err)
+
+	status := Status{}
+	assert.NoError(t, json.Unmarshal([]byte(out), &status))
+	assert.Equal(t, 1, status.ShardsWithoutLeader)
+	assert.Equal(t, []string{"s2:6649"}, status.ServersDown)
+	assert.Len(t, status.Shards, 2)
+	assert.Len(t, status.Servers, 3)
+}
+
+func TestClusterStatus_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not the leader coordinator", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := runStatus(t, server)
+	assert.EqualError(t, err, "503 Service Unavailable: not the leader coordinator")
+
+	_, err = runStatus(t, server, "-o", "yaml")
+	assert.EqualError(t, err, "invalid output format: yaml")
+}
@@ -0,0 +1,212 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/streamnative/oxia/cmd/cliconfig"
+)
+
+const redacted = "REDACTED"
+
+var (
+	raw bool
+
+	setValues = struct {
+		ServiceAddress string
+		AdminAddress   string
+		Namespace      string
+		TLS            bool
+		TLSTrustedCa   string
+		TLSCertFile    string
+		TLSKeyFile     string
+		AuthToken      string
+		AuthTokenFile  string
+	}{}
+
+	Cmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manage the contexts of the commands",
+		Long: `Manage the named contexts of the config file, ~/.oxia/config.yaml or the file set with OXIA_CONFIG. ` +
+			`A context holds the addresses, the TLS settings, the auth token and the default namespace of a ` +
+			`cluster, which are used by the commands in place of the flags that are not set.`,
+	}
+
+	viewCmd = &cobra.Command{
+		Use:   "view",
+		Short: "Print the config file",
+		Long:  `Print the config file. The auth tokens are redacted, unless --raw is set`,
+		Args:  cobra.NoArgs,
+		RunE:  view,
+	}
+
+	useContextCmd = &cobra.Command{
+		Use:   "use-context <context>",
+		Short: "Set the current context",
+		Long:  `Set the context that is used by the commands, unless another one is set with --context or OXIA_CONTEXT`,
+		Args:  cobra.ExactArgs(1),
+		RunE:  useContext,
+	}
+
+	setCmd = &cobra.Command{
+		Use:   "set <context>",
+		Short: "Create or update a context",
+		Long: `Set the fields of the context given with the flags, creating the context if it doesn't exist. The ` +
+			`first context that is created becomes the current context. An empty value clears the field.`,
+		Args: cobra.ExactArgs(1),
+		RunE: set,
+	}
+)
+
+func init() {
+	viewCmd.Flags().BoolVar(&raw, "raw", false, "Print the auth tokens")
+	Cmd.AddCommand(viewCmd)
+	Cmd.AddCommand(useContextCmd)
+
+	setCmd.Flags().StringVarP(&setValues.ServiceAddress, "service-address", "a", "", "Service address")
+	setCmd.Flags().StringVar(&setValues.AdminAddress, "admin-address", "", "Coordinator admin service address")
+	setCmd.Flags().StringVarP(&setValues.Namespace, "namespace", "n", "", "Default namespace of the commands")
+	setCmd.Flags().BoolVar(&setValues.TLS, "tls", false, "Connect with TLS, verifying the servers with the system CAs if no trusted ca file is set")
+	setCmd.Flags().StringVar(&setValues.TLSTrustedCa, "tls-trusted-ca-file", "", "Tls trusted ca file")
+	setCmd.Flags().StringVar(&setValues.TLSCertFile, "tls-cert-file", "", "Tls client certificate file")
+	setCmd.Flags().StringVar(&setValues.TLSKeyFile, "tls-key-file", "", "Tls client key file")
+	setCmd.Flags().StringVar(&setValues.AuthToken, "auth-token", "", "Token sent to authenticate the requests, stored in the config file")
+	setCmd.Flags().StringVar(&setValues.AuthTokenFile, "auth-token-file", "", "File with the token sent to authenticate the requests")
+	setCmd.MarkFlagsMutuallyExclusive("auth-token", "auth-token-file")
+	Cmd.AddCommand(setCmd)
+}
+
+func load() (*cliconfig.File, string, error) {
+	path, err := cliconfig.DefaultPath()
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := cliconfig.Load(path)
+	return f, path, err
+}
+
+func view(cmd *cobra.Command, _ []string) error {
+	f, _, err := load()
+	if err != nil {
+		return err
+	}
+
+	if !raw {
+		for _, c := range f.Contexts {
+			if c != nil && c.AuthToken != "" {
+				c.AuthToken = redacted
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+	_, err = cmd.OutOrStdout().Write(data)
+	return err
+}
+
+func useContext(cmd *cobra.Command, args []string) error {
+	f, path, err := load()
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Context(args[0]); err != nil {
+		return err
+	}
+
+	f.CurrentContext = args[0]
+	if err := f.Save(path); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "Switched to context %q\n", args[0])
+	return err
+}
+
+func set(cmd *cobra.Command, args []string) error {
+	f, path, err := load()
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	c, err := f.Context(name)
+	if errors.Is(err, cliconfig.ErrContextNotFound) {
+		c = &cliconfig.Context{}
+		f.Contexts[name] = c
+	} else if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	setString := func(flag string, field *string, value string) {
+		if flags.Changed(flag) {
+			*field = value
+		}
+	}
+	// The files are stored with their absolute path, so that the context
+	// can be used from any directory
+	setFile := func(flag string, field *string, value string) {
+		if abs, err := filepath.Abs(value); err == nil && value != "" {
+			value = abs
+		}
+		setString(flag, field, value)
+	}
+	setString("service-address", &c.ServiceAddress, setValues.ServiceAddress)
+	setString("admin-address", &c.AdminAddress, setValues.AdminAddress)
+	setString("namespace", &c.Namespace, setValues.Namespace)
+
+	if flags.Changed("tls") || flags.Changed("tls-trusted-ca-file") || flags.Changed("tls-cert-file") || flags.Changed("tls-key-file") {
+		if c.TLS == nil {
+			c.TLS = &cliconfig.TLS{}
+		}
+		if flags.Changed("tls") {
+			c.TLS.Enabled = setValues.TLS
+		}
+		setFile("tls-trusted-ca-file", &c.TLS.TrustedCaFile, setValues.TLSTrustedCa)
+		setFile("tls-cert-file", &c.TLS.CertFile, setValues.TLSCertFile)
+		setFile("tls-key-file", &c.TLS.KeyFile, setValues.TLSKeyFile)
+		if *c.TLS == (cliconfig.TLS{}) {
+			c.TLS = nil
+		}
+	}
+
+	// The token and the token file replace each other
+	if flags.Changed("auth-token") {
+		c.AuthToken = setValues.AuthToken
+		c.AuthTokenFile = ""
+	}
+	if flags.Changed("auth-token-file") {
+		setFile("auth-token-file", &c.AuthTokenFile, setValues.AuthTokenFile)
+		c.AuthToken = ""
+	}
+
+	if f.CurrentContext == "" {
+		f.CurrentContext = name
+	}
+	if err := f.Save(path); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "Context %q set\n", name)
+	return err
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/streamnative/oxia/cmd/cliconfig"
+)
+
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	// The flags keep their values between the runs
+	for _, c := range Cmd.Commands() {
+		c.Flags().VisitAll(func(f *pflag.Flag) {
+			_ = f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+	}
+
+	out := &bytes.Buffer{}
+	Cmd.SetOut(out)
+	Cmd.SetArgs(args)
+	err := Cmd.Execute()
+	return out.String(), err
+}
+
+func TestConfig_SetAndUseContext(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "oxia", "config.yaml")
+	t.Setenv(cliconfig.EnvConfig, path)
+
+	out, err := run(t, "set", "dev", "-a", "localhost:6648", "-n", "dev-ns")
+	require.NoError(t, err)
+	assert.Equal(t, "Context \"dev\" set\n", out)
+
+	_, err = run(t, "set", "prod", "-a", "prod:6648", "--admin-address", "prod:6650",
+		"--tls", "--tls-trusted-ca-file", "ca.crt", "--auth-token-file", "token")
+	require.NoError(t, err)
+
+	f, err := cliconfig.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "dev", f.CurrentContext)
+	assert.Equal(t, &cliconfig.Context{ServiceAddress: "localhost:6648", Namespace: "dev-ns"}, f.Contexts["dev"])
+	assert.Equal(t, &cliconfig.Context{
+		ServiceAddress: "prod:6648",
+		AdminAddress:   "prod:6650",
+		TLS:            &cliconfig.TLS{Enabled: true, TrustedCaFile: absPath(t, "ca.crt")},
+		AuthTokenFile:  absPath(t, "token"),
+	}, f.Contexts["prod"])
+
+	// Only the fields that are given are updated
+	_, err = run(t, "set", "prod", "-n", "prod-ns", "--auth-token", "secret", "--tls-trusted-ca-file", "")
+	require.NoError(t, err)
+
+	out, err = run(t, "use-context", "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "Switched to context \"prod\"\n", out)
+
+	f, err = cliconfig.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", f.CurrentContext)
+	assert.Equal(t, &cliconfig.Context{
+		ServiceAddress: "prod:6648",
+		AdminAddress:   "prod:6650",
+		Namespace:      "prod-ns",
+		TLS:            &cliconfig.TLS{Enabled: true},
+		AuthToken:      "secret",
+	}, f.Contexts["prod"])
+
+	_, err = run(t, "use-context", "staging")
+	assert.ErrorIs(t, err, cliconfig.ErrContextNotFound)
+}
+
+func TestConfig_View(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(cliconfig.EnvConfig, path)
+
+	_, err := run(t, "set", "prod", "-a", "prod:6648", "--auth-token", "secret")
+	require.NoError(t, err)
+
+	out, err := run(t, "view")
+	require.NoError(t, err)
+	assert.Equal(t, `currentContext: prod
+contexts:
+    prod:
+        serviceAddress: prod:6648
+        authToken: REDACTED
+`, out)
+
+	out, err = run(t, "view", "--raw")
+	require.NoError(t, err)
+	assert.Contains(t, out, "authToken: secret")
+}
+
+func TestConfig_SetExclusiveToken(t *testing.T) {
+	t.Setenv(cliconfig.EnvConfig, filepath.Join(t.TempDir(), "config.yaml"))
+
+	_, err := run(t, "set", "prod", "--auth-token", "secret", "--auth-token-file", "token")
+	assert.Error(t, err)
+}
+
+func absPath(t *testing.T, path string) string {
+	t.Helper()
+	abs, err := filepath.Abs(path)
+	require.NoError(t, err)
+	return abs
+}
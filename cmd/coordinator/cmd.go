@@ -49,11 +49,22 @@ var (
 func init() {
 	flag.InternalAddr(Cmd, &conf.InternalServiceAddr)
 	flag.MetricsAddr(Cmd, &conf.MetricsServiceAddr)
+	flag.DebugAddr(Cmd, &conf.DebugServiceAddr)
+	flag.GrpcOptions(Cmd, &conf.Grpc)
+	Cmd.Flags().StringVar(&conf.AdminServiceAddr, "admin-addr", conf.AdminServiceAddr, "Admin service bind address. It's not exposed with the other services by default, and it's disabled when empty")
 	Cmd.Flags().Var(&conf.MetadataProviderImpl, "metadata", "Metadata provider implementation: file, configmap or memory")
-	Cmd.Flags().StringVar(&conf.K8SMetadataNamespace, "k8s-namespace", conf.K8SMetadataNamespace, "Kubernetes namespace for oxia config maps")
+	Cmd.Flags().StringVar(&conf.K8SMetadataNamespace, "k8s-namespace", conf.K8SMetadataNamespace, "Kubernetes namespace for oxia config maps and for the servers service")
 	Cmd.Flags().StringVar(&conf.K8SMetadataConfigMapName, "k8s-configmap-name", conf.K8SMetadataConfigMapName, "ConfigMap name for cluster status configmap")
+	Cmd.Flags().StringVar(&conf.K8SClusterName, "k8s-cluster-name", conf.K8SClusterName, "Name of the OxiaCluster resource where the status of the shards is reported, with metadata=configmap")
+	Cmd.Flags().StringVar(&conf.K8SServersService, "k8s-servers-service", conf.K8SServersService, "Headless service of the servers. When set, the servers are discovered from its endpoints, instead of being listed in the cluster config")
 	Cmd.Flags().StringVar(&conf.FileMetadataPath, "file-clusters-status-path", "data/cluster-status.json", "The path where the cluster status is stored when using 'file' provider")
 	Cmd.Flags().StringVarP(&configFile, "conf", "f", "", "Cluster config file")
+	Cmd.Flags().DurationVar(&conf.FailureDetector.ProbeInterval, "failure-detector-probe-interval", conf.FailureDetector.ProbeInterval, "How often the health of each node is checked")
+	Cmd.Flags().DurationVar(&conf.FailureDetector.ProbeTimeout, "failure-detector-probe-timeout", conf.FailureDetector.ProbeTimeout, "The timeout of each health check of a node")
+	Cmd.Flags().IntVar(&conf.FailureDetector.FailureThreshold, "failure-detector-failure-threshold", conf.FailureDetector.FailureThreshold, "The number of consecutive failed health checks after which a node is considered down")
+	Cmd.Flags().DurationVar(&conf.FailureDetector.ElectionBackoff, "leader-election-backoff", conf.FailureDetector.ElectionBackoff, "The initial delay before electing a new leader for a shard whose leader has failed. It's randomized for each shard and grows with the repeated failures")
+	Cmd.Flags().IntVar(&conf.Rebalance.MaxConcurrentMoves, "rebalance-max-concurrent-moves", conf.Rebalance.MaxConcurrentMoves, "The max number of shards whose replicas are moved at the same time when rebalancing the cluster")
+	Cmd.Flags().BoolVar(&conf.Rebalance.ZoneAware, "zone-aware", conf.Rebalance.ZoneAware, "Spread the replicas of each shard across the zones reported by the servers")
 
 	// server TLS section
 	Cmd.Flags().StringVar(&serverTLS.CertFile, "tls-cert-file", "", "Tls certificate file")
@@ -71,10 +82,16 @@ func init() {
 	Cmd.Flags().Uint16Var(&peerTLS.MaxVersion, "peer-tls-max-version", 0, "Peer tls maximum version")
 	Cmd.Flags().StringVar(&peerTLS.TrustedCaFile, "peer-tls-trusted-ca-file", "", "Peer tls trusted ca file")
 	Cmd.Flags().BoolVar(&peerTLS.InsecureSkipVerify, "peer-tls-insecure-skip-verify", false, "Peer tls insecure skip verify")
-	Cmd.Flags().StringVar(&peerTLS.ServerName, "peer-tls-server-name", "", "Peer tls server name")
+	Cmd.Flags().StringVar(&peerTLS.ServerName, "peer-tls-server-name", "", "Peer tls server name. By default, it is the host name of the peer address")
 }
 
 func validate(*cobra.Command, []string) error {
+	if err := conf.FailureDetector.Validate(); err != nil {
+		return err
+	}
+	if err := conf.Rebalance.Validate(); err != nil {
+		return err
+	}
 	if conf.MetadataProviderImpl == coordinator.Configmap {
 		if conf.K8SMetadataNamespace == "" {
 			return errors.New("k8s-namespace must be set with metadata=configmap")
@@ -82,6 +99,11 @@ func validate(*cobra.Command, []string) error {
 		if conf.K8SMetadataConfigMapName == "" {
 			return errors.New("k8s-configmap-name must be set with metadata=configmap")
 		}
+	} else if conf.K8SClusterName != "" {
+		return errors.New("k8s-cluster-name can only be set with metadata=configmap")
+	}
+	if conf.K8SServersService != "" && conf.K8SMetadataNamespace == "" {
+		return errors.New("k8s-namespace must be set with k8s-servers-service")
 	}
 	return nil
 }
@@ -18,6 +18,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 
@@ -27,6 +28,7 @@ import (
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/coordinator"
+	"github.com/streamnative/oxia/coordinator/impl"
 	"github.com/streamnative/oxia/coordinator/model"
 )
 
@@ -56,6 +58,16 @@ func TestCmd(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
+	defaultFailureDetector := impl.FailureDetectorOptions{
+		ProbeInterval:    2 * time.Second,
+		ProbeTimeout:     2 * time.Second,
+		FailureThreshold: 3,
+		ElectionBackoff:  1 * time.Second,
+	}
+	defaultRebalance := impl.RebalanceOptions{
+		MaxConcurrentMoves: 1,
+	}
+
 	for _, test := range []struct {
 		args                []string
 		expectedConf        coordinator.Config
@@ -65,7 +77,10 @@ func TestCmd(t *testing.T) {
 		{[]string{}, coordinator.Config{
 			InternalServiceAddr:  "localhost:6649",
 			MetricsServiceAddr:   "localhost:8080",
+			AdminServiceAddr:     "localhost:6650",
 			MetadataProviderImpl: coordinator.File,
+			FailureDetector:      defaultFailureDetector,
+			Rebalance:            defaultRebalance,
 		}, model.ClusterConfig{
 			Namespaces: []model.NamespaceConfig{{
 				Name:              common.DefaultNamespace,
@@ -81,7 +96,10 @@ func TestCmd(t *testing.T) {
 		{[]string{"-i=localhost:1234"}, coordinator.Config{
 			InternalServiceAddr:  "localhost:1234",
 			MetricsServiceAddr:   "localhost:8080",
+			AdminServiceAddr:     "localhost:6650",
 			MetadataProviderImpl: coordinator.File,
+			FailureDetector:      defaultFailureDetector,
+			Rebalance:            defaultRebalance,
 		}, model.ClusterConfig{
 			Namespaces: []model.NamespaceConfig{{
 				Name:              common.DefaultNamespace,
@@ -97,7 +115,10 @@ func TestCmd(t *testing.T) {
 		{[]string{"-i=0.0.0.0:1234"}, coordinator.Config{
 			InternalServiceAddr:  "0.0.0.0:1234",
 			MetricsServiceAddr:   "localhost:8080",
+			AdminServiceAddr:     "localhost:6650",
 			MetadataProviderImpl: coordinator.File,
+			FailureDetector:      defaultFailureDetector,
+			Rebalance:            defaultRebalance,
 		}, model.ClusterConfig{
 			Namespaces: []model.NamespaceConfig{{
 				Name:              common.DefaultNamespace,
@@ -113,7 +134,29 @@ func TestCmd(t *testing.T) {
 		{[]string{"-m=localhost:1234"}, coordinator.Config{
 			InternalServiceAddr:  "localhost:6649",
 			MetricsServiceAddr:   "localhost:1234",
+			AdminServiceAddr:     "localhost:6650",
+			MetadataProviderImpl: coordinator.File,
+			FailureDetector:      defaultFailureDetector,
+			Rebalance:            defaultRebalance,
+		}, model.ClusterConfig{
+			Namespaces: []model.NamespaceConfig{{
+				Name:              common.DefaultNamespace,
+				ReplicationFactor: 1,
+				InitialShardCount: 2,
+			}},
+			Servers: []model.ServerAddress{{
+				Public:   "public:1234",
+				Internal: "internal:5678",
+			},
+			},
+		}, false},
+		{[]string{"--admin-addr=0.0.0.0:1234"}, coordinator.Config{
+			InternalServiceAddr:  "localhost:6649",
+			MetricsServiceAddr:   "localhost:8080",
+			AdminServiceAddr:     "0.0.0.0:1234",
 			MetadataProviderImpl: coordinator.File,
+			FailureDetector:      defaultFailureDetector,
+			Rebalance:            defaultRebalance,
 		}, model.ClusterConfig{
 			Namespaces: []model.NamespaceConfig{{
 				Name:              common.DefaultNamespace,
@@ -129,7 +172,10 @@ func TestCmd(t *testing.T) {
 		{[]string{"-f=" + name}, coordinator.Config{
 			InternalServiceAddr:  "localhost:6649",
 			MetricsServiceAddr:   "localhost:8080",
+			AdminServiceAddr:     "localhost:6650",
 			MetadataProviderImpl: coordinator.File,
+			FailureDetector:      defaultFailureDetector,
+			Rebalance:            defaultRebalance,
 		}, model.ClusterConfig{
 			Namespaces: []model.NamespaceConfig{{
 				Name:              common.DefaultNamespace,
@@ -145,7 +191,10 @@ func TestCmd(t *testing.T) {
 		{[]string{"-f=invalid.yaml"}, coordinator.Config{
 			InternalServiceAddr:  "localhost:6649",
 			MetricsServiceAddr:   "localhost:8080",
+			AdminServiceAddr:     "localhost:6650",
 			MetadataProviderImpl: coordinator.File,
+			FailureDetector:      defaultFailureDetector,
+			Rebalance:            defaultRebalance,
 		}, model.ClusterConfig{}, true},
 	} {
 		t.Run(strings.Join(test.args, "_"), func(t *testing.T) {
@@ -180,7 +229,16 @@ func TestCmd(t *testing.T) {
 		{[]string{"--metadata=configmap", "--k8s-namespace=foo", "--k8s-configmap-name=bar"}, false},
 		{[]string{"--metadata=configmap", "--k8s-namespace=foo}"}, true},
 		{[]string{"--metadata=configmap", "--k8s-configmap-name=bar"}, true},
+		{[]string{"--metadata=configmap", "--k8s-namespace=foo", "--k8s-configmap-name=bar", "--k8s-cluster-name=oxia"}, false},
+		{[]string{"--metadata=memory", "--k8s-cluster-name=oxia"}, true},
 		{[]string{"--metadata=invalid"}, true},
+		{[]string{"--failure-detector-probe-interval=500ms", "--failure-detector-failure-threshold=5", "--leader-election-backoff=2s"}, false},
+		{[]string{"--failure-detector-failure-threshold=-1"}, true},
+		{[]string{"--rebalance-max-concurrent-moves=4"}, false},
+		{[]string{"--rebalance-max-concurrent-moves=-1"}, true},
+		{[]string{"--zone-aware"}, false},
+		{[]string{"--k8s-namespace=foo", "--k8s-servers-service=oxia-svc"}, false},
+		{[]string{"--k8s-servers-service=oxia-svc"}, true},
 	} {
 		t.Run(strings.Join(test.args, "_"), func(t *testing.T) {
 			conf = coordinator.NewConfig()
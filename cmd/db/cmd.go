@@ -0,0 +1,333 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+)
+
+const (
+	outputPlain = "plain"
+	outputJSON  = "json"
+)
+
+type inspectConfig struct {
+	dataDir   string
+	namespace string
+	shard     int64
+	keyMin    string
+	keyMax    string
+	key       string
+	internal  bool
+	stats     bool
+	output    string
+}
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "db",
+		Short: "Database utils",
+		Long:  `Tools to troubleshoot the key-value stores of the shards, while the server is stopped`,
+	}
+
+	inspectCmd = &cobra.Command{
+		Use:   "inspect",
+		Short: "Inspect the database of a shard",
+		Long: `Print the records of the database of a shard in a key range, a single record with --key, the state of
+the shard with --internal, or the stats of the database with --stats. The database is opened read-only
+and cannot be inspected while it's in use by a server.`,
+		Args:         cobra.NoArgs,
+		RunE:         exec,
+		SilenceUsage: true,
+	}
+
+	config = inspectConfig{}
+)
+
+func init() {
+	inspectCmd.Flags().StringVar(&config.dataDir, "data-dir", "./data/db", "Directory of the databases of the server")
+	inspectCmd.Flags().StringVarP(&config.namespace, "namespace", "n", common.DefaultNamespace, "Namespace of the shard")
+	inspectCmd.Flags().Int64Var(&config.shard, "shard", 0, "Id of the shard")
+	inspectCmd.Flags().StringVarP(&config.keyMin, "key-min", "s", "", "Key range minimum (inclusive)")
+	inspectCmd.Flags().StringVarP(&config.keyMax, "key-max", "e", "", "Key range maximum (exclusive)")
+	inspectCmd.Flags().StringVarP(&config.key, "key", "k", "", "Print a single record, with its value")
+	inspectCmd.Flags().BoolVar(&config.internal, "internal", false, "Print the state of the shard and the keys of the internal records")
+	inspectCmd.Flags().BoolVar(&config.stats, "stats", false, "Print the stats of the database")
+	inspectCmd.Flags().StringVarP(&config.output, "output", "o", outputPlain, "Output format. Allowed value: plain, json")
+
+	Cmd.AddCommand(inspectCmd)
+}
+
+func exec(cmd *cobra.Command, _ []string) error {
+	if config.output != outputPlain && config.output != outputJSON {
+		return errors.Errorf("invalid output format: %s", config.output)
+	}
+	if (config.key != "" && (config.internal || config.stats || config.keyMin != "" || config.keyMax != "")) ||
+		(config.internal && config.stats) {
+		return errors.New("only one of a key, a key range, --internal and --stats can be inspected at a time")
+	}
+
+	inspector, err := kv.NewInspector(config.dataDir, config.namespace, config.shard)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = inspector.Close()
+	}()
+
+	out := cmd.OutOrStdout()
+	switch {
+	case config.key != "":
+		return printRecord(out, inspector)
+	case config.internal:
+		return printInternal(out, inspector)
+	case config.stats:
+		return printStats(out, inspector)
+	default:
+		return printRecords(out, inspector)
+	}
+}
+
+// outputRecord is a record in JSON format. The values that are not valid
+// UTF-8 text are encoded in base64.
+type outputRecord struct {
+	Key                 string     `json:"key"`
+	Value               *string    `json:"value,omitempty"`
+	ValueBase64         []byte     `json:"value_base64,omitempty"`
+	Size                int        `json:"size"`
+	VersionId           int64      `json:"version_id"`
+	ModificationsCount  int64      `json:"modifications_count"`
+	CreatedTimestamp    time.Time  `json:"created_timestamp"`
+	ModifiedTimestamp   time.Time  `json:"modified_timestamp"`
+	SessionId           *int64     `json:"session_id,omitempty"`
+	ClientIdentity      *string    `json:"client_identity,omitempty"`
+	PartitionKey        *string    `json:"partition_key,omitempty"`
+	ExpirationTimestamp *time.Time `json:"expiration_timestamp,omitempty"`
+}
+
+func newOutputRecord(key string, se *proto.StorageEntry, includeValue bool) outputRecord {
+	or := outputRecord{
+		Key:                key,
+		Size:               len(se.Value),
+		VersionId:          se.VersionId,
+		ModificationsCount: se.ModificationsCount,
+		CreatedTimestamp:   time.UnixMilli(int64(se.CreationTimestamp)),
+		ModifiedTimestamp:  time.UnixMilli(int64(se.ModificationTimestamp)),
+		SessionId:          se.SessionId,
+		ClientIdentity:     se.ClientIdentity,
+		PartitionKey:       se.PartitionKey,
+	}
+	if se.ExpirationTimestamp != nil {
+		expiration := time.UnixMilli(int64(*se.ExpirationTimestamp))
+		or.ExpirationTimestamp = &expiration
+	}
+
+	if includeValue {
+		if utf8.Valid(se.Value) {
+			value := string(se.Value)
+			or.Value = &value
+		} else {
+			or.ValueBase64 = se.Value
+		}
+	}
+	return or
+}
+
+func printRecords(out io.Writer, inspector kv.Inspector) error {
+	encoder := json.NewEncoder(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if config.output == outputPlain {
+		_, _ = fmt.Fprintln(w, "KEY\tVERSION_ID\tMODIFICATIONS\tCREATED\tMODIFIED\tSIZE")
+	}
+
+	if err := inspector.Records(config.keyMin, config.keyMax, func(key string, se *proto.StorageEntry) error {
+		or := newOutputRecord(key, se, true)
+		if config.output == outputJSON {
+			return encoder.Encode(or)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%d\n", or.Key, or.VersionId, or.ModificationsCount,
+			or.CreatedTimestamp.Format(time.RFC3339Nano), or.ModifiedTimestamp.Format(time.RFC3339Nano), or.Size)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func printRecord(out io.Writer, inspector kv.Inspector) error {
+	se, err := inspector.Get(config.key)
+	if err != nil {
+		return errors.Wrapf(err, "key %s", config.key)
+	}
+
+	or := newOutputRecord(config.key, se, true)
+	if config.output == outputJSON {
+		return json.NewEncoder(out).Encode(or)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Key:\t%s\n", or.Key)
+	_, _ = fmt.Fprintf(w, "Version id:\t%d\n", or.VersionId)
+	_, _ = fmt.Fprintf(w, "Modifications:\t%d\n", or.ModificationsCount)
+	_, _ = fmt.Fprintf(w, "Created:\t%s\n", or.CreatedTimestamp.Format(time.RFC3339Nano))
+	_, _ = fmt.Fprintf(w, "Modified:\t%s\n", or.ModifiedTimestamp.Format(time.RFC3339Nano))
+	if or.SessionId != nil {
+		_, _ = fmt.Fprintf(w, "Session id:\t%d\n", *or.SessionId)
+	}
+	if or.ClientIdentity != nil {
+		_, _ = fmt.Fprintf(w, "Client identity:\t%s\n", *or.ClientIdentity)
+	}
+	if or.PartitionKey != nil {
+		_, _ = fmt.Fprintf(w, "Partition key:\t%s\n", *or.PartitionKey)
+	}
+	if or.ExpirationTimestamp != nil {
+		_, _ = fmt.Fprintf(w, "Expiration:\t%s\n", or.ExpirationTimestamp.Format(time.RFC3339Nano))
+	}
+	_, _ = fmt.Fprintf(w, "Size:\t%d\n", or.Size)
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(out)
+	_, err = out.Write(se.Value)
+	return err
+}
+
+type outputInternalRecord struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+}
+
+type outputInternal struct {
+	CommitEntryId    json.RawMessage        `json:"commit_entry_id"`
+	Term             int64                  `json:"term"`
+	LastVersionId    int64                  `json:"last_version_id"`
+	ShardSplit       json.RawMessage        `json:"shard_split,omitempty"`
+	UnappliableEntry json.RawMessage        `json:"unappliable_entry,omitempty"`
+	InternalRecords  []outputInternalRecord `json:"internal_records"`
+}
+
+func printInternal(out io.Writer, inspector kv.Inspector) error {
+	markers, err := inspector.Markers()
+	if err != nil {
+		return err
+	}
+
+	oi := outputInternal{
+		Term:            markers.Term,
+		LastVersionId:   markers.LastVersionId,
+		InternalRecords: []outputInternalRecord{},
+	}
+	if oi.CommitEntryId, err = protojson.Marshal(markers.CommitEntryId); err != nil {
+		return err
+	}
+	if markers.ShardSplit != nil {
+		if oi.ShardSplit, err = protojson.Marshal(markers.ShardSplit); err != nil {
+			return err
+		}
+	}
+	if markers.UnappliableEntry != nil {
+		if oi.UnappliableEntry, err = protojson.Marshal(markers.UnappliableEntry); err != nil {
+			return err
+		}
+	}
+
+	if err = inspector.InternalRecords(func(key string, value []byte) error {
+		oi.InternalRecords = append(oi.InternalRecords, outputInternalRecord{Key: key, Size: len(value)})
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if config.output == outputJSON {
+		return json.NewEncoder(out).Encode(oi)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Commit entry id:\tterm %d, offset %d\n", markers.CommitEntryId.Term, markers.CommitEntryId.Offset)
+	_, _ = fmt.Fprintf(w, "Term:\t%d\n", oi.Term)
+	_, _ = fmt.Fprintf(w, "Last version id:\t%d\n", oi.LastVersionId)
+	if oi.ShardSplit != nil {
+		_, _ = fmt.Fprintf(w, "Shard split:\t%s\n", oi.ShardSplit)
+	}
+	if oi.UnappliableEntry != nil {
+		_, _ = fmt.Fprintf(w, "Unappliable entry:\t%s\n", oi.UnappliableEntry)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "KEY\tSIZE")
+	for _, r := range oi.InternalRecords {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", r.Key, r.Size)
+	}
+	return w.Flush()
+}
+
+type outputLevel struct {
+	Level int   `json:"level"`
+	Files int64 `json:"files"`
+	Size  int64 `json:"size"`
+}
+
+type outputStats struct {
+	EstimatedKeys      int64         `json:"estimated_keys"`
+	DiskUsage          int64         `json:"disk_usage"`
+	FormatMajorVersion uint64        `json:"format_major_version"`
+	Levels             []outputLevel `json:"levels"`
+}
+
+func printStats(out io.Writer, inspector kv.Inspector) error {
+	stats, err := inspector.Stats()
+	if err != nil {
+		return err
+	}
+
+	res := outputStats{
+		EstimatedKeys:      stats.EstimatedKeys,
+		DiskUsage:          stats.DiskUsage,
+		FormatMajorVersion: stats.FormatMajorVersion,
+		Levels:             []outputLevel{},
+	}
+	for _, l := range stats.Levels {
+		res.Levels = append(res.Levels, outputLevel{Level: l.Level, Files: l.Files, Size: l.Size})
+	}
+
+	if config.output == outputJSON {
+		return json.NewEncoder(out).Encode(res)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Estimated keys:\t%d\n", res.EstimatedKeys)
+	_, _ = fmt.Fprintf(w, "Disk usage:\t%d bytes\n", res.DiskUsage)
+	_, _ = fmt.Fprintf(w, "Format major version:\t%d\n", res.FormatMajorVersion)
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "LEVEL\tFILES\tSIZE")
+	for _, l := range res.Levels {
+		_, _ = fmt.Fprintf(w, "%d\t%d\t%d\n", l.Level, l.Files, l.Size)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+)
+
+func runInspect(t *testing.T, dataDir string, args ...string) (string, error) {
+	t.Helper()
+
+	// The flags keep their values between the executions
+	stdout := bytes.NewBufferString("")
+	Cmd.SetOut(stdout)
+	Cmd.SetArgs(append([]string{"inspect", "--data-dir", dataDir, "--shard", "1", "--key", "", "--key-min", "",
+		"--key-max", "", "--internal=false", "--stats=false", "-o", "plain"}, args...))
+	err := Cmd.Execute()
+	return stdout.String(), err
+}
+
+func TestInspectCmd(t *testing.T) {
+	dataDir := t.TempDir()
+	factory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: dataDir})
+	assert.NoError(t, err)
+	db, err := kv.NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	for i := int64(0); i < 5; i++ {
+		_, err = db.ProcessWrite(&proto.WriteRequest{
+			Puts: []*proto.PutRequest{{Key: fmt.Sprintf("key-%d", i), Value: []byte(fmt.Sprintf("value-%d", i))}},
+		}, 2, i, 1000, kv.NoOpCallback)
+		assert.NoError(t, err)
+	}
+
+	// The database cannot be inspected while it's in use
+	_, err = runInspect(t, dataDir)
+	assert.ErrorIs(t, err, kv.ErrDbLocked)
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+
+	out, err := runInspect(t, dataDir, "--key-min", "key-1", "--key-max", "key-4", "-o", "json")
+	assert.NoError(t, err)
+	var records []outputRecord
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var record outputRecord
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	assert.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, fmt.Sprintf("key-%d", i+1), record.Key)
+		assert.Equal(t, fmt.Sprintf("value-%d", i+1), *record.Value)
+		assert.EqualValues(t, i+1, record.VersionId)
+	}
+
+	out, err = runInspect(t, dataDir)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 6)
+	assert.Contains(t, lines[0], "VERSION_ID")
+	assert.True(t, strings.HasPrefix(lines[5], "key-4 "))
+
+	out, err = runInspect(t, dataDir, "--key", "key-3")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "Version id:")
+	assert.True(t, strings.HasSuffix(out, "\nvalue-3"))
+
+	_, err = runInspect(t, dataDir, "--key", "non-existing")
+	assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+
+	out, err = runInspect(t, dataDir, "--internal", "-o", "json")
+	assert.NoError(t, err)
+	var internal outputInternal
+	assert.NoError(t, json.Unmarshal([]byte(out), &internal))
+	assert.JSONEq(t, `{"term":"2","offset":"4"}`, string(internal.CommitEntryId))
+	assert.EqualValues(t, 4, internal.LastVersionId)
+	assert.NotEmpty(t, internal.InternalRecords)
+
+	out, err = runInspect(t, dataDir, "--stats", "-o", "json")
+	assert.NoError(t, err)
+	var stats outputStats
+	assert.NoError(t, json.Unmarshal([]byte(out), &stats))
+	assert.Positive(t, stats.DiskUsage)
+	assert.Positive(t, stats.EstimatedKeys)
+
+	_, err = runInspect(t, dataDir, "--key", "key-3", "--stats")
+	assert.Error(t, err)
+	_, err = runInspect(t, dataDir, "-o", "yaml")
+	assert.ErrorContains(t, err, "invalid output format")
+}
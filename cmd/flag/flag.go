@@ -33,3 +33,18 @@ func InternalAddr(cmd *cobra.Command, conf *string) {
 func MetricsAddr(cmd *cobra.Command, conf *string) {
 	cmd.Flags().StringVarP(conf, "metrics-addr", "m", fmt.Sprintf("0.0.0.0:%d", common.DefaultMetricsPort), "Metrics service bind address")
 }
+
+func DebugAddr(cmd *cobra.Command, conf *string) {
+	cmd.Flags().StringVar(conf, "debug-addr", "", "Bind address of the pprof and runtime debug endpoints. It's not exposed with the other services by default, and it's disabled when empty")
+}
+
+func GrpcOptions(cmd *cobra.Command, conf *common.GrpcOptions) {
+	d := common.DefaultGrpcOptions()
+	cmd.Flags().DurationVar(&conf.KeepAliveTime, "grpc-keepalive-time", d.KeepAliveTime, "Idle time after which the gRPC connections are pinged. It must be lower than the idle timeout of the load balancers between the nodes")
+	cmd.Flags().DurationVar(&conf.KeepAliveTimeout, "grpc-keepalive-timeout", d.KeepAliveTimeout, "Time to wait for the ack of a gRPC ping before closing the connection")
+	cmd.Flags().DurationVar(&conf.KeepAliveMinTime, "grpc-keepalive-min-time", d.KeepAliveMinTime, "Shortest interval between the pings of a gRPC client that is accepted")
+	cmd.Flags().BoolVar(&conf.KeepAlivePermitWithoutStream, "grpc-keepalive-permit-without-stream", d.KeepAlivePermitWithoutStream, "Ping the gRPC connections that have no active streams too")
+	cmd.Flags().IntVar(&conf.MaxMessageSize, "grpc-max-message-size", d.MaxMessageSize, "Largest gRPC message that is sent or received, in bytes")
+	cmd.Flags().Int32Var(&conf.InitialWindowSize, "grpc-initial-window-size", 0, "Flow control window of each gRPC stream, in bytes. 0 means the window is sized dynamically")
+	cmd.Flags().Int32Var(&conf.InitialConnWindowSize, "grpc-initial-conn-window-size", 0, "Flow control window of each gRPC connection, in bytes. 0 means the window is sized dynamically")
+}
@@ -16,33 +16,51 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/automaxprocs/maxprocs"
 
+	"github.com/streamnative/oxia/cmd/admin"
+	"github.com/streamnative/oxia/cmd/backup"
+	"github.com/streamnative/oxia/cmd/cliconfig"
 	"github.com/streamnative/oxia/cmd/client"
+	"github.com/streamnative/oxia/cmd/cluster"
+	"github.com/streamnative/oxia/cmd/config"
 	"github.com/streamnative/oxia/cmd/coordinator"
+	"github.com/streamnative/oxia/cmd/db"
 	"github.com/streamnative/oxia/cmd/health"
 	"github.com/streamnative/oxia/cmd/pebble"
 	"github.com/streamnative/oxia/cmd/perf"
+	"github.com/streamnative/oxia/cmd/restore"
 	"github.com/streamnative/oxia/cmd/server"
 	"github.com/streamnative/oxia/cmd/standalone"
+	"github.com/streamnative/oxia/cmd/wal"
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/oxia"
 )
 
 var (
 	logLevelStr string
+	contextName string
 	rootCmd     = &cobra.Command{
 		Use:               "oxia",
 		Short:             "Oxia root command",
 		Long:              `Oxia root command`,
-		PersistentPreRunE: configureLogLevel,
+		PersistentPreRunE: preRun,
 		SilenceUsage:      true,
 	}
 )
 
+// The exit codes tell the scripts why a command has failed.
+const (
+	exitCodeError             = 1
+	exitCodeKeyNotFound       = 2
+	exitCodeUnexpectedVersion = 3
+)
+
 type LogLevelError string
 
 func (l LogLevelError) Error() string {
@@ -54,14 +72,45 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&common.LogJSON, "log-json", "j", false, "Print logs in JSON format")
 	rootCmd.PersistentFlags().BoolVar(&common.PprofEnable, "profile", false, "Enable pprof profiler")
 	rootCmd.PersistentFlags().StringVar(&common.PprofBindAddress, "profile-bind-address", "127.0.0.1:6060", "Bind address for pprof")
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "Context of the config file to use, in place of the current one")
 
+	rootCmd.AddCommand(admin.Cmd)
 	rootCmd.AddCommand(client.Cmd)
+	rootCmd.AddCommand(cluster.Cmd)
+	rootCmd.AddCommand(config.Cmd)
 	rootCmd.AddCommand(coordinator.Cmd)
 	rootCmd.AddCommand(health.Cmd)
 	rootCmd.AddCommand(perf.Cmd)
 	rootCmd.AddCommand(server.Cmd)
 	rootCmd.AddCommand(standalone.Cmd)
 	rootCmd.AddCommand(pebble.Cmd)
+	rootCmd.AddCommand(wal.Cmd)
+	rootCmd.AddCommand(db.Cmd)
+	rootCmd.AddCommand(backup.Cmd)
+	rootCmd.AddCommand(restore.Cmd)
+}
+
+func preRun(cmd *cobra.Command, args []string) error {
+	if err := configureLogLevel(cmd, args); err != nil {
+		return err
+	}
+	return applyContext(cmd)
+}
+
+// applyContext sets the flags that are not on the command line from the
+// context, except for the commands that manage the contexts.
+func applyContext(cmd *cobra.Command) error {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == config.Cmd {
+			return nil
+		}
+	}
+
+	path, err := cliconfig.DefaultPath()
+	if err != nil {
+		return err
+	}
+	return cliconfig.Apply(cmd.Flags(), path, contextName)
 }
 
 func configureLogLevel(_ *cobra.Command, _ []string) error {
@@ -74,6 +123,17 @@ func configureLogLevel(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, oxia.ErrKeyNotFound):
+		return exitCodeKeyNotFound
+	case errors.Is(err, oxia.ErrUnexpectedVersionId):
+		return exitCodeUnexpectedVersion
+	default:
+		return exitCodeError
+	}
+}
+
 func main() {
 	common.DoWithLabels(
 		context.Background(),
@@ -86,7 +146,7 @@ func main() {
 				os.Exit(1)
 			}
 			if err := rootCmd.Execute(); err != nil {
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 		},
 	)
@@ -15,13 +15,21 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/streamnative/oxia/cmd/cliconfig"
+	"github.com/streamnative/oxia/cmd/client"
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/server"
 )
 
 func TestCall_LogLevel_Default(t *testing.T) {
@@ -68,3 +76,69 @@ func TestCall_LogLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestExitCode(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+
+	for _, test := range []struct {
+		name     string
+		args     string
+		exitCode int
+	}{
+		{"key-not-found", "get does-not-exist", exitCodeKeyNotFound},
+		{"unexpected-version", "put k v -e 5", exitCodeUnexpectedVersion},
+		{"transport-error", "get k -a localhost:1 --request-timeout 100ms", exitCodeError},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rootCmd.SetArgs(append([]string{"-l", "info", "client", "-a", serviceAddress}, strings.Split(test.args, " ")...))
+			err := rootCmd.Execute()
+			assert.Error(t, err)
+			assert.Equal(t, test.exitCode, exitCode(err))
+		})
+	}
+
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestContext(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	require.NoError(t, err)
+	defer standaloneServer.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(cliconfig.EnvConfig, path)
+	f := &cliconfig.File{
+		CurrentContext: "local",
+		Contexts: map[string]*cliconfig.Context{
+			"local": {ServiceAddress: fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())},
+		},
+	}
+	require.NoError(t, f.Save(path))
+
+	// The flags keep their values between the runs
+	resetFlags := func(c *cobra.Command) {
+		c.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+			_ = f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+	}
+	resetFlags(client.Cmd)
+	t.Cleanup(func() {
+		contextName = ""
+		resetFlags(client.Cmd)
+	})
+
+	// The service address is taken from the current context
+	rootCmd.SetArgs([]string{"-l", "info", "client", "get", "does-not-exist"})
+	err = rootCmd.Execute()
+	assert.Equal(t, exitCodeKeyNotFound, exitCode(err))
+
+	rootCmd.SetArgs([]string{"-l", "info", "--context", "missing", "client", "get", "k"})
+	assert.ErrorIs(t, rootCmd.Execute(), cliconfig.ErrContextNotFound)
+
+	// The commands that manage the contexts don't need one
+	rootCmd.SetArgs([]string{"-l", "info", "--context", "missing", "config", "view"})
+	assert.NoError(t, rootCmd.Execute())
+}
@@ -17,25 +17,35 @@ package perf
 import (
 	"context"
 	"fmt"
-	"io"
-
-	"github.com/streamnative/oxia/oxia"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/oxia"
 	"github.com/streamnative/oxia/perf"
 )
 
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
 var (
 	Cmd = &cobra.Command{
 		Use:   "perf",
 		Short: "Oxia perf client",
-		Long:  `Oxia tool for basic performance tests`,
-		Run:   exec,
+		Long: `Oxia tool for basic performance tests. The traffic is generated until the duration has elapsed, or until
+the process is interrupted, and the stats of the whole run are printed at the end.`,
+		RunE:         exec,
+		SilenceUsage: true,
 	}
 
 	config = perf.Config{}
+	output string
 )
 
 func init() {
@@ -45,36 +55,42 @@ func init() {
 
 	Cmd.Flags().Float64VarP(&config.RequestRate, "rate", "r", 100.0, "Request rate, ops/s")
 	Cmd.Flags().Float64VarP(&config.ReadPercentage, "read-write-percent", "p", 80.0, "Percentage of read requests, compared to total requests")
-	Cmd.Flags().Uint32Var(&config.KeysCardinality, "keys-cardinality", 1000, "Batch linger in milliseconds")
+	Cmd.Flags().Uint32Var(&config.KeysCardinality, "keys-cardinality", 1000, "Number of distinct keys")
+	Cmd.Flags().StringVar(&config.KeyPrefix, "key-prefix", perf.DefaultKeyPrefix, "Prefix of the keys")
 	Cmd.Flags().Uint32VarP(&config.ValueSize, "value-size", "s", 128, "Size of the values to write")
+	Cmd.Flags().Uint32Var(&config.ValueSizeMax, "value-size-max", 0, "Maximum size of the values to write. The sizes are uniformly distributed between the value size and the maximum")
+	Cmd.Flags().IntVar(&config.Streams, "streams", 1, "Number of clients that generate the traffic in parallel")
+	Cmd.Flags().DurationVarP(&config.Duration, "duration", "d", 0, "Duration of the run, or until interrupted if zero")
+	Cmd.Flags().DurationVar(&config.ReportInterval, "report-interval", perf.DefaultReportInterval, "Interval between the stats reports")
+	Cmd.Flags().IntVar(&config.MaxInFlight, "max-in-flight", perf.DefaultMaxInFlight, "Maximum number of operations awaiting a response. The operations over the limit are throttled")
+	Cmd.Flags().BoolVar(&config.Cleanup, "cleanup", false, "Delete the keys once the run is completed")
+	Cmd.Flags().StringVarP(&output, "output", "o", outputText, "Format of the final stats. Allowed value: text, json")
 
 	Cmd.Flags().DurationVar(&config.BatchLinger, "batch-linger", oxia.DefaultBatchLinger, "Batch linger time")
 	Cmd.Flags().IntVar(&config.MaxRequestsPerBatch, "max-requests-per-batch", oxia.DefaultMaxRequestsPerBatch, "Maximum requests per batch")
 	Cmd.Flags().DurationVar(&config.RequestTimeout, "request-timeout", oxia.DefaultRequestTimeout, "Request timeout")
 }
 
-func exec(*cobra.Command, []string) {
-	common.RunProcess(runPerf)
-}
+func exec(cmd *cobra.Command, _ []string) error {
+	if output != outputText && output != outputJSON {
+		return errors.Errorf("invalid output format: %s", output)
+	}
 
-type closer struct {
-	ctx    context.Context
-	cancel context.CancelFunc
-}
+	profiler := common.RunProfiling()
+	defer func() {
+		_ = profiler.Close()
+	}()
 
-func newCloser(ctx context.Context) *closer {
-	c := &closer{}
-	c.ctx, c.cancel = context.WithCancel(ctx)
-	return c
-}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-func (c *closer) Close() error {
-	c.cancel()
-	return nil
-}
+	result, err := perf.New(config).Run(ctx)
+	if err != nil {
+		return err
+	}
 
-func runPerf() (io.Closer, error) {
-	closer := newCloser(context.Background())
-	go perf.New(config).Run(closer.ctx)
-	return closer, nil
+	if output == outputJSON {
+		return result.WriteJSON(cmd.OutOrStdout())
+	}
+	return result.WriteText(cmd.OutOrStdout())
 }
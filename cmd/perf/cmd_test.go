@@ -0,0 +1,61 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/oxia"
+	"github.com/streamnative/oxia/perf"
+	"github.com/streamnative/oxia/server"
+)
+
+func TestPerfCmd(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+
+	stdout := bytes.NewBufferString("")
+	Cmd.SetOut(stdout)
+	Cmd.SetArgs([]string{"-a", serviceAddress, "--duration", "2s", "--rate", "1000", "--streams", "2",
+		"--value-size", "10", "--value-size-max", "100", "--report-interval", "500ms", "--batch-linger", "1ms",
+		"--cleanup", "-o", "json"})
+	assert.NoError(t, Cmd.Execute())
+
+	var result perf.Result
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	assert.InDelta(t, 2, result.Duration, 1)
+	assert.Positive(t, result.Write.Ops)
+	assert.Positive(t, result.Read.Ops)
+	assert.Zero(t, result.Write.Failed)
+	assert.Zero(t, result.Read.Failed)
+	assert.Positive(t, result.Write.LatencyMs.Max)
+
+	// The keys of the run were deleted
+	client, err := oxia.NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+	keys, err := client.List(context.Background(), perf.DefaultKeyPrefix, perf.DefaultKeyPrefix+"~")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
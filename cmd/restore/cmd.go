@@ -0,0 +1,324 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/streamnative/oxia/cmd/backup"
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+type Config struct {
+	Input      string
+	ServerAddr string
+	DataDir    string
+	WalDir     string
+	Namespace  string
+	Shard      int64
+
+	TLS              bool
+	TLSTrustedCaFile string
+	TLSCertFile      string
+	TLSKeyFile       string
+}
+
+func NewConfig() Config {
+	return Config{
+		Namespace: common.DefaultNamespace,
+	}
+}
+
+var (
+	config = NewConfig()
+
+	Cmd = &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the backup of a shard",
+		Long: `Restore the backup of a shard into one of its replicas. With --server-address, the backup is sent to a follower
+replica through the same path as the snapshots sent by the leaders, eg: a replica that was just assigned to the server,
+and the replica is then brought up to date by the leader. With --data-dir and --wal-dir, the backup replaces the
+database of a replica while its server is stopped, and its WAL is cleared.
+
+The backup must be of the same shard, and a running replica refuses a backup of a different hash range. The files of
+the backup are verified before they are restored, and an interrupted restore can be run again.`,
+		Args:         cobra.NoArgs,
+		RunE:         exec,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	Cmd.Flags().StringVar(&config.Input, "input", "", "Directory of the backup")
+	Cmd.Flags().StringVar(&config.ServerAddr, "server-address", "", "Internal service address of the server with the follower replica to restore")
+	Cmd.Flags().StringVar(&config.DataDir, "data-dir", "", "Directory of the databases of the stopped server to restore")
+	Cmd.Flags().StringVar(&config.WalDir, "wal-dir", "", "Directory of the WALs of the stopped server to restore")
+	Cmd.Flags().StringVarP(&config.Namespace, "namespace", "n", config.Namespace, "Namespace of the shard")
+	Cmd.Flags().Int64Var(&config.Shard, "shard", 0, "Id of the shard")
+	backup.AddTLSFlags(Cmd, &config.TLS, &config.TLSTrustedCaFile, &config.TLSCertFile, &config.TLSKeyFile)
+}
+
+func exec(cmd *cobra.Command, _ []string) error {
+	if config.Input == "" {
+		return errors.New("the input directory is required")
+	}
+	if err := backup.CheckDir(config.Input); err != nil {
+		return err
+	}
+
+	online := config.ServerAddr != ""
+	offline := config.DataDir != "" || config.WalDir != ""
+	switch {
+	case online && offline:
+		return errors.New("--server-address cannot be used with --data-dir and --wal-dir")
+	case !online && (config.DataDir == "" || config.WalDir == ""):
+		return errors.New("either --server-address, or both --data-dir and --wal-dir are required")
+	}
+
+	m, err := backup.ReadManifest(config.Input)
+	if err != nil {
+		return err
+	}
+	if m.Namespace != config.Namespace || m.Shard != config.Shard {
+		return errors.Errorf("the backup is of shard %d of namespace %s, not of shard %d of namespace %s",
+			m.Shard, m.Namespace, config.Shard, config.Namespace)
+	}
+	if err = m.Verify(config.Input); err != nil {
+		return err
+	}
+
+	if online {
+		ackOffset, err := restoreReplica(m)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Restored shard %d of namespace %s on %s up to offset %d\n",
+			m.Shard, m.Namespace, config.ServerAddr, ackOffset)
+		return nil
+	}
+
+	if err = restoreStopped(m); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Restored shard %d of namespace %s in %s up to offset %d\n",
+		m.Shard, m.Namespace, config.DataDir, m.EntryId.Offset)
+	return nil
+}
+
+// Send the backup to a follower replica, in its current term. The follower
+// only replaces its database once the whole snapshot is verified.
+func restoreReplica(m *backup.Manifest) (int64, error) {
+	pool, err := backup.NewClientPool(config.TLS, config.TLSTrustedCaFile, config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return 0, err
+	}
+	defer pool.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	coordinationRpc, err := pool.GetCoordinationRpc(config.ServerAddr)
+	if err != nil {
+		return 0, err
+	}
+	status, err := coordinationRpc.GetStatus(ctx, &proto.GetStatusRequest{Shard: config.Shard})
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get the status of the replica of shard %d", config.Shard)
+	}
+	if status.Status == proto.ServingStatus_LEADER {
+		return 0, errors.Errorf("the replica of shard %d on %s is the leader, only a follower can be restored",
+			config.Shard, config.ServerAddr)
+	}
+
+	replicationRpc, err := pool.GetReplicationRpc(config.ServerAddr)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, common.MetadataNamespace, config.Namespace)
+	ctx = metadata.AppendToOutgoingContext(ctx, common.MetadataShardId, fmt.Sprintf("%d", config.Shard))
+	ctx = metadata.AppendToOutgoingContext(ctx, common.MetadataTerm, fmt.Sprintf("%d", status.Term))
+	stream, err := replicationRpc.SendSnapshot(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	digest := sha256.New()
+	count := 0
+	err = readChunks(m, func(name string, index int32, total int32, content []byte, last bool) error {
+		// The digest is the same as the one of the snapshots sent by the
+		// leaders
+		_, _ = digest.Write([]byte(name))
+		_, _ = digest.Write(content)
+
+		chunk := &proto.SnapshotChunk{
+			Term:       status.Term,
+			Name:       name,
+			Content:    content,
+			ChunkIndex: index,
+			ChunkCount: total,
+			Crc:        crc32.Checksum(content, backup.SnapshotCrcTable),
+		}
+		if count == 0 {
+			chunk.EntryId = &proto.EntryId{Term: m.EntryId.Term, Offset: m.EntryId.Offset}
+			chunk.Int32HashRange = &proto.Int32HashRange{
+				MinHashInclusive: m.Int32HashRange.Min,
+				MaxHashInclusive: m.Int32HashRange.Max,
+			}
+		}
+		if last {
+			chunk.Digest = digest.Sum(nil)
+		}
+		count++
+		return stream.Send(chunk)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return 0, err
+	}
+	return res.AckOffset, nil
+}
+
+// Replace the database of the replica of a stopped server. The WAL is cleared
+// first, since its entries might not follow the backup, and it stays locked
+// until the database is restored, so that the server cannot be started in the
+// meantime. The replica keeps its term, if it had one.
+func restoreStopped(m *backup.Manifest) (err error) {
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{
+		BaseWalDir:  config.WalDir,
+		Retention:   wal.DefaultFactoryOptions.Retention,
+		SegmentSize: wal.DefaultFactoryOptions.SegmentSize,
+		SyncPolicy:  wal.SyncPolicyAlways,
+	})
+	defer func() {
+		err = multierr.Append(err, walFactory.Close())
+	}()
+
+	w, err := walFactory.NewWal(config.Namespace, config.Shard, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = multierr.Append(err, w.Close())
+	}()
+
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
+		DataDir:     config.DataDir,
+		CacheSizeMB: kv.DefaultFactoryOptions.CacheSizeMB,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = multierr.Append(err, kvFactory.Close())
+	}()
+
+	term, err := readTerm(kvFactory)
+	if err != nil {
+		return err
+	}
+
+	if err = w.Clear(); err != nil {
+		return err
+	}
+
+	loader, err := kvFactory.NewSnapshotLoader(config.Namespace, config.Shard)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = multierr.Append(err, loader.Close())
+	}()
+
+	if err = readChunks(m, func(name string, index int32, total int32, content []byte, _ bool) error {
+		return loader.AddChunk(name, index, total, content)
+	}); err != nil {
+		return err
+	}
+	if err = loader.Complete(); err != nil {
+		return err
+	}
+
+	if term == wal.InvalidTerm {
+		return nil
+	}
+
+	db, err := kv.NewDB(config.Namespace, config.Shard, kvFactory, 0, common.SystemClock)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the restored database")
+	}
+	return multierr.Combine(db.UpdateTerm(term), db.Close())
+}
+
+func readTerm(kvFactory kv.Factory) (int64, error) {
+	db, err := kv.NewDB(config.Namespace, config.Shard, kvFactory, 0, common.SystemClock)
+	if err != nil {
+		return wal.InvalidTerm, err
+	}
+
+	term, err := db.ReadTerm()
+	return term, multierr.Append(err, db.Close())
+}
+
+// Read the files of the backup in chunks of the same size as the snapshots.
+func readChunks(m *backup.Manifest, fn func(name string, index int32, total int32, content []byte, last bool) error) error {
+	for i, f := range m.Files {
+		total := int32((f.Size + kv.MaxSnapshotChunkSize - 1) / kv.MaxSnapshotChunkSize)
+		if total == 0 {
+			// Empty file
+			total = 1
+		}
+
+		file, err := os.Open(filepath.Join(config.Input, backup.FilesDir, f.Name))
+		if err != nil {
+			return err
+		}
+
+		for index := int32(0); index < total; index++ {
+			content := make([]byte, min(kv.MaxSnapshotChunkSize, f.Size-int64(index)*kv.MaxSnapshotChunkSize))
+			if _, err = io.ReadFull(file, content); err != nil {
+				break
+			}
+			if err = fn(f.Name, index, total, content, i == len(m.Files)-1 && index == total-1); err != nil {
+				break
+			}
+		}
+
+		if err = multierr.Append(err, file.Close()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
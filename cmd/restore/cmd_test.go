@@ -0,0 +1,190 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/cmd/backup"
+	"github.com/streamnative/oxia/oxia"
+	"github.com/streamnative/oxia/server"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+const keysCount = 100
+
+func runBackup(t *testing.T, standalone *server.Standalone, output string, args ...string) error {
+	t.Helper()
+
+	// The flags keep their values between the executions
+	backup.Cmd.SetOut(&bytes.Buffer{})
+	backup.Cmd.SetArgs(append([]string{"--server-address", fmt.Sprintf("localhost:%d", standalone.InternalPort()),
+		"--shard", "0", "--output", output}, args...))
+	return backup.Cmd.Execute()
+}
+
+func runRestore(t *testing.T, input string, args ...string) error {
+	t.Helper()
+
+	Cmd.SetOut(&bytes.Buffer{})
+	Cmd.SetArgs(append([]string{"--input", input, "--shard", "0", "--server-address", "", "--data-dir", "",
+		"--wal-dir", ""}, args...))
+	return Cmd.Execute()
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	config := server.NewTestConfig(dir)
+	standalone, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	client, err := oxia.NewSyncClient(fmt.Sprintf("localhost:%d", standalone.RpcPort()))
+	assert.NoError(t, err)
+	for i := 0; i < keysCount; i++ {
+		_, _, err = client.Put(context.Background(), fmt.Sprintf("key-%d", i), []byte(fmt.Sprintf("value-%d", i)))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, client.Close())
+
+	output := filepath.Join(t.TempDir(), "backup")
+	assert.NoError(t, runBackup(t, standalone, output))
+	m, err := backup.ReadManifest(output)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, m.Shard)
+	assert.EqualValues(t, keysCount-1, m.EntryId.Offset)
+	assert.EqualValues(t, 0, m.Int32HashRange.Min)
+	assert.EqualValues(t, uint32(1<<32-1), m.Int32HashRange.Max)
+	assert.NoError(t, m.Verify(output))
+
+	// A complete backup is never overwritten
+	assert.ErrorContains(t, runBackup(t, standalone, output), "already contains a complete backup")
+	assert.ErrorContains(t, runBackup(t, standalone, "s3://bucket/backup"), "only local directories are supported")
+
+	// Only a follower replica can be restored online
+	assert.ErrorContains(t, runRestore(t, output, "--server-address", fmt.Sprintf("localhost:%d", standalone.InternalPort())),
+		"only a follower can be restored")
+
+	// The replica of a running server cannot be replaced
+	assert.ErrorIs(t, runRestore(t, output, "--data-dir", config.DataDir, "--wal-dir", config.WalDir), wal.ErrWalLocked)
+
+	// Wipe the shard and restore it
+	assert.NoError(t, standalone.Close())
+	assert.NoError(t, os.RemoveAll(config.DataDir))
+	assert.NoError(t, os.RemoveAll(config.WalDir))
+
+	assert.ErrorContains(t, runRestore(t, output, "--data-dir", config.DataDir, "--wal-dir", config.WalDir, "--shard", "1"),
+		"the backup is of shard 0")
+	assert.NoError(t, runRestore(t, output, "--data-dir", config.DataDir, "--wal-dir", config.WalDir))
+
+	standalone, err = server.NewStandalone(config)
+	assert.NoError(t, err)
+	client, err = oxia.NewSyncClient(fmt.Sprintf("localhost:%d", standalone.RpcPort()))
+	assert.NoError(t, err)
+	for i := 0; i < keysCount; i++ {
+		_, value, _, err := client.Get(context.Background(), fmt.Sprintf("key-%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("value-%d", i), string(value))
+	}
+
+	// The restored shard accepts new writes
+	_, _, err = client.Put(context.Background(), "key-new", []byte("value-new"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standalone.Close())
+
+	// A corrupted backup is never restored
+	f := m.Files[0]
+	assert.NoError(t, os.WriteFile(filepath.Join(output, backup.FilesDir, f.Name), []byte("corrupted"), 0644))
+	assert.ErrorContains(t, runRestore(t, output, "--data-dir", config.DataDir, "--wal-dir", config.WalDir),
+		fmt.Sprintf("file %s of the backup is corrupted", f.Name))
+}
+
+func TestBackupResume(t *testing.T) {
+	standalone, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+
+	client, err := oxia.NewSyncClient(fmt.Sprintf("localhost:%d", standalone.RpcPort()))
+	assert.NoError(t, err)
+	for i := 0; i < keysCount; i++ {
+		_, _, err = client.Put(context.Background(), fmt.Sprintf("key-%d", i), []byte(fmt.Sprintf("value-%d", i)))
+		assert.NoError(t, err)
+	}
+
+	output := filepath.Join(t.TempDir(), "backup")
+	assert.NoError(t, runBackup(t, standalone, output))
+	m, err := backup.ReadManifest(output)
+	assert.NoError(t, err)
+
+	// Turn the backup into one that was interrupted, with a file that was
+	// only partially written
+	files := map[string]backup.File{}
+	var tables, others []string
+	for _, f := range m.Files {
+		files[f.Name] = f
+		if filepath.Ext(f.Name) == ".sst" {
+			tables = append(tables, f.Name)
+		} else {
+			others = append(others, f.Name)
+		}
+	}
+	assert.NotEmpty(t, tables)
+	p, err := json.Marshal(map[string]any{"namespace": "default", "shard": 0, "files": files})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(output, "progress.json"), p, 0644))
+	assert.NoError(t, os.Remove(filepath.Join(output, backup.ManifestFile)))
+	assert.NoError(t, os.Truncate(filepath.Join(output, backup.FilesDir, others[0]), 1))
+
+	// The table files that were complete are not downloaded again
+	_, _, err = client.Put(context.Background(), "key-new", []byte("value-new"))
+	assert.NoError(t, err)
+	kept := map[string]os.FileInfo{}
+	for _, name := range tables {
+		kept[name], err = os.Stat(filepath.Join(output, backup.FilesDir, name))
+		assert.NoError(t, err)
+	}
+
+	assert.ErrorContains(t, runBackup(t, standalone, output, "--shard", "1"), "contains the backup of shard 0")
+	assert.NoError(t, runBackup(t, standalone, output))
+	m, err = backup.ReadManifest(output)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Verify(output))
+	assert.EqualValues(t, keysCount, m.EntryId.Offset)
+	inBackup := map[string]bool{}
+	for _, f := range m.Files {
+		inBackup[f.Name] = true
+	}
+	for name, info := range kept {
+		if !inBackup[name] {
+			// The table was compacted away by the storage engine in the meantime
+			continue
+		}
+		current, err := os.Stat(filepath.Join(output, backup.FilesDir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, info.ModTime(), current.ModTime(), name)
+	}
+	_, err = os.Stat(filepath.Join(output, "progress.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standalone.Close())
+}
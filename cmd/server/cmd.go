@@ -22,9 +22,11 @@ import (
 
 	"github.com/streamnative/oxia/cmd/flag"
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/compression"
 	"github.com/streamnative/oxia/common/security"
 	"github.com/streamnative/oxia/server"
 	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
 )
 
 var (
@@ -48,16 +50,51 @@ func init() {
 	flag.PublicAddr(Cmd, &conf.PublicServiceAddr)
 	flag.InternalAddr(Cmd, &conf.InternalServiceAddr)
 	flag.MetricsAddr(Cmd, &conf.MetricsServiceAddr)
+	flag.DebugAddr(Cmd, &conf.DebugServiceAddr)
+	flag.GrpcOptions(Cmd, &conf.Grpc)
 	Cmd.Flags().StringVar(&conf.DataDir, "data-dir", "./data/db", "Directory where to store data")
 	Cmd.Flags().StringVar(&conf.WalDir, "wal-dir", "./data/wal", "Directory for write-ahead-logs")
 	Cmd.Flags().DurationVar(&conf.WalRetentionTime, "wal-retention-time", 1*time.Hour, "Retention time for the entries in the write-ahead-log")
 	Cmd.Flags().DurationVar(&conf.NotificationsRetentionTime, "notifications-retention-time", 1*time.Hour, "Retention time for the db notifications to clients")
 
-	Cmd.Flags().BoolVar(&conf.WalSyncData, "wal-sync-data", true, "Whether to sync data in write-ahead-log")
+	Cmd.Flags().Var(&conf.WalSyncPolicy, "wal-sync-policy", "When to sync the write-ahead-log on disk: always, before acknowledging the entries, interval, in background at the wal-sync-interval, or never")
+	Cmd.Flags().DurationVar(&conf.WalSyncInterval, "wal-sync-interval", wal.DefaultFactoryOptions.SyncInterval, "Interval for syncing the write-ahead-log in background with the interval sync policy")
 	Cmd.Flags().Int64Var(&conf.DbBlockCacheMB, "db-cache-size-mb", kv.DefaultFactoryOptions.CacheSizeMB,
 		"Max size of the shared DB cache")
-	Cmd.Flags().StringVar(&conf.AuthOptions.ProviderName, "auth-provider-name", "", "Authentication provider name. supported: oidc")
-	Cmd.Flags().StringVar(&conf.AuthOptions.ProviderParams, "auth-provider-params", "", "Authentication provider params. \n oidc: "+"{\"allowedIssueURLs\":\"required1,required2\",\"allowedAudiences\":\"required1,required2\",\"userNameClaim\":\"optional(default:sub)\"}")
+	Cmd.Flags().Int64Var(&conf.DbMemTableSizeMB, "db-memtable-size-mb", kv.DefaultFactoryOptions.MemTableSizeMB,
+		"Size of the DB memtable of each shard")
+	Cmd.Flags().BoolVar(&conf.DbDisableCompression, "db-disable-compression", false, "Whether to store the DB files without compression")
+	Cmd.Flags().IntVar(&conf.DbMaxOpenFiles, "db-max-open-files", kv.DefaultFactoryOptions.MaxOpenFiles,
+		"Max number of files kept open by the DB of each shard")
+	Cmd.Flags().IntVar(&conf.DbMaxConcurrentCompactions, "db-max-concurrent-compactions", kv.DefaultFactoryOptions.MaxConcurrentCompactions,
+		"Max number of compactions run concurrently by the DB of each shard")
+	Cmd.Flags().DurationVar(&conf.DbCompaction.Interval, "db-compaction-interval", 0, "Interval between the compactions of the DB of each shard, which drop the deleted and overwritten records. 0 means disabled")
+	Cmd.Flags().StringVar(&conf.DbCompaction.Window, "db-compaction-window", "", "Time of the day of the DB compactions, as HH:MM-HH:MM in local time (e.g. 01:00-05:00). Empty means any time")
+	Cmd.Flags().IntVar(&conf.DbCompaction.Concurrency, "db-compaction-concurrency", 0, "Max number of shards whose DB is compacted at the same time. 0 means 1")
+	Cmd.Flags().DurationVar(&conf.DiskUsageRefreshInterval, "disk-usage-refresh-interval", server.DefaultDiskUsageRefreshInterval, "Interval for measuring the disk space taken by each shard")
+	Cmd.Flags().IntVar(&conf.ShardRecoveryParallelism, "shard-recovery-parallelism", 0, "Number of shards recovered concurrently at startup. 0 means the number of CPUs")
+	Cmd.Flags().StringVar(&conf.ReplicationCompression, "replication-compression", compression.None, "Compression of the replication streams to the followers: none, gzip or zstd")
+	Cmd.Flags().IntVar(&conf.EntryCompressionThreshold, "entry-compression-threshold", 0, "Size in bytes above which the values of the log entries are compressed. 0 means the entries are never compressed")
+	Cmd.Flags().StringVar(&conf.DiskWatermarks.High, "disk-high-watermark", "", "Disk usage above which the leaders reject the writes, as a percentage of the disk (e.g. 90%) or as the free space left (e.g. 10GiB). Empty means disabled")
+	Cmd.Flags().StringVar(&conf.DiskWatermarks.Low, "disk-low-watermark", "", "Disk usage below which the writes are accepted again. Empty means the high watermark")
+	Cmd.Flags().DurationVar(&conf.DiskWatermarks.CheckInterval, "disk-watermark-check-interval", server.DefaultDiskWatermarkCheckInterval, "Interval for checking the disk usage against the watermarks")
+	Cmd.Flags().IntVar(&conf.WriteSizeLimits.MaxKeySize, "max-key-size", 0, "Max size in bytes of the keys. 0 means the default of 64KiB")
+	Cmd.Flags().IntVar(&conf.WriteSizeLimits.MaxValueSize, "max-value-size", 0, "Max size in bytes of the values. 0 means the max write request size")
+	Cmd.Flags().IntVar(&conf.WriteSizeLimits.MaxWriteRequestSize, "max-write-request-size", 0, "Max size in bytes of a write request. 0 means the largest size allowed by the gRPC max message size")
+	Cmd.Flags().Float64Var(&conf.WriteRateLimit.RequestsPerSecond, "write-rate-limit-requests", 0, "Max number of write requests per second accepted by the server. 0 means no limit")
+	Cmd.Flags().Float64Var(&conf.WriteRateLimit.BytesPerSecond, "write-rate-limit-bytes", 0, "Max number of bytes per second written to the server. 0 means no limit")
+	Cmd.Flags().Float64Var(&conf.WriteRateLimit.ShardRequestsPerSecond, "write-rate-limit-shard-requests", 0, "Max number of write requests per second accepted by each shard. 0 means no limit")
+	Cmd.Flags().Float64Var(&conf.WriteRateLimit.ShardBytesPerSecond, "write-rate-limit-shard-bytes", 0, "Max number of bytes per second written to each shard. 0 means no limit")
+	Cmd.Flags().BoolVar(&conf.GrpcInterceptors.DisablePanicRecovery, "grpc-disable-panic-recovery", false, "Whether to let a panic in a gRPC handler crash the server, instead of failing the request")
+	Cmd.Flags().BoolVar(&conf.GrpcInterceptors.AccessLog, "grpc-access-log", false, "Whether to log every gRPC request at debug level")
+	Cmd.Flags().BoolVar(&conf.Maintenance, "maintenance", false, "Start the server in maintenance mode, where it keeps replicating data but is not elected as leader")
+	Cmd.Flags().StringVar(&conf.Locality.Zone, "locality-zone", "", "The zone where the server runs, reported to the coordinator to spread the replicas of the shards across the zones")
+	Cmd.Flags().StringVar(&conf.Locality.Host, "locality-host", "", "The host where the server runs, reported to the coordinator to avoid placing the replicas of a shard on the same host")
+	Cmd.Flags().BoolVar(&conf.SkipUnappliableEntries, "skip-unappliable-entries", false, "Let the followers skip the committed entries they cannot apply, instead of stopping at them. The skipped entries are lost on the replica")
+	Cmd.Flags().StringVar(&conf.AuthOptions.ProviderName, "auth-provider-name", "", "Authentication provider name. supported: oidc, jwks, static-token")
+	Cmd.Flags().StringVar(&conf.AuthOptions.ProviderParams, "auth-provider-params", "", "Authentication provider params. \n oidc: "+"{\"allowedIssueURLs\":\"required1,required2\",\"allowedAudiences\":\"required1,required2\",\"userNameClaim\":\"optional(default:sub)\"}"+
+		"\n jwks: "+"{\"jwksURL\":\"required\",\"issuer\":\"required\",\"allowedAudiences\":\"required1,required2\",\"userNameClaim\":\"optional(default:sub)\"}"+
+		"\n static-token: "+"{\"tokensFile\":\"required\"}")
 
 	// server TLS section
 	Cmd.Flags().StringVar(&serverTLS.CertFile, "tls-cert-file", "", "Tls certificate file")
@@ -84,7 +121,7 @@ func init() {
 	Cmd.Flags().Uint16Var(&peerTLS.MaxVersion, "peer-tls-max-version", 0, "Peer tls maximum version")
 	Cmd.Flags().StringVar(&peerTLS.TrustedCaFile, "peer-tls-trusted-ca-file", "", "Peer tls trusted ca file")
 	Cmd.Flags().BoolVar(&peerTLS.InsecureSkipVerify, "peer-tls-insecure-skip-verify", false, "Peer tls insecure skip verify")
-	Cmd.Flags().StringVar(&peerTLS.ServerName, "peer-tls-server-name", "", "Peer tls server name")
+	Cmd.Flags().StringVar(&peerTLS.ServerName, "peer-tls-server-name", "", "Peer tls server name. By default, it is the host name of the peer address")
 }
 
 func exec(*cobra.Command, []string) {
@@ -22,7 +22,10 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/container"
 	"github.com/streamnative/oxia/server"
+	"github.com/streamnative/oxia/server/wal"
 )
 
 func TestServerCmd(t *testing.T) {
@@ -38,9 +41,89 @@ func TestServerCmd(t *testing.T) {
 			DataDir:                    "./data/db",
 			WalDir:                     "./data/wal",
 			WalRetentionTime:           1 * time.Hour,
-			WalSyncData:                true,
+			WalSyncInterval:            1 * time.Second,
 			NotificationsRetentionTime: 1 * time.Hour,
 			DbBlockCacheMB:             100,
+			DbMemTableSizeMB:           32,
+			DbMaxOpenFiles:             1000,
+			DbMaxConcurrentCompactions: 1,
+			DiskUsageRefreshInterval:   1 * time.Minute,
+			ReplicationCompression:     "none",
+			DiskWatermarks: server.DiskWatermarkOptions{
+				CheckInterval: 10 * time.Second,
+			},
+			Grpc: common.DefaultGrpcOptions(),
+		}, false},
+		{[]string{"--wal-sync-policy=interval", "--wal-sync-interval=100ms"}, server.Config{
+			PublicServiceAddr:          "0.0.0.0:6648",
+			InternalServiceAddr:        "0.0.0.0:6649",
+			MetricsServiceAddr:         "0.0.0.0:8080",
+			DataDir:                    "./data/db",
+			WalDir:                     "./data/wal",
+			WalRetentionTime:           1 * time.Hour,
+			WalSyncPolicy:              wal.SyncPolicyInterval,
+			WalSyncInterval:            100 * time.Millisecond,
+			NotificationsRetentionTime: 1 * time.Hour,
+			DbBlockCacheMB:             100,
+			DbMemTableSizeMB:           32,
+			DbMaxOpenFiles:             1000,
+			DbMaxConcurrentCompactions: 1,
+			DiskUsageRefreshInterval:   1 * time.Minute,
+			ReplicationCompression:     "none",
+			DiskWatermarks: server.DiskWatermarkOptions{
+				CheckInterval: 10 * time.Second,
+			},
+			Grpc: common.DefaultGrpcOptions(),
+		}, false},
+		{[]string{"--wal-sync-policy=always", "--wal-sync-interval=1s", "--write-rate-limit-requests=1000", "--write-rate-limit-shard-bytes=1048576", "--grpc-access-log", "--maintenance", "--skip-unappliable-entries", "--db-memtable-size-mb=64", "--db-disable-compression", "--db-max-concurrent-compactions=4", "--db-compaction-interval=24h", "--db-compaction-window=22:00-04:00", "--db-compaction-concurrency=2", "--disk-usage-refresh-interval=30s", "--shard-recovery-parallelism=4", "--replication-compression=zstd", "--entry-compression-threshold=1024", "--disk-high-watermark=90%", "--disk-low-watermark=85%", "--disk-watermark-check-interval=1m", "--locality-zone=zone-a", "--locality-host=host-1", "--debug-addr=localhost:6060", "--grpc-keepalive-time=20s", "--grpc-keepalive-timeout=5s", "--grpc-keepalive-permit-without-stream=false", "--grpc-initial-window-size=1048576"}, server.Config{
+			PublicServiceAddr:          "0.0.0.0:6648",
+			InternalServiceAddr:        "0.0.0.0:6649",
+			MetricsServiceAddr:         "0.0.0.0:8080",
+			DebugServiceAddr:           "localhost:6060",
+			DataDir:                    "./data/db",
+			WalDir:                     "./data/wal",
+			WalRetentionTime:           1 * time.Hour,
+			WalSyncInterval:            1 * time.Second,
+			NotificationsRetentionTime: 1 * time.Hour,
+			DbBlockCacheMB:             100,
+			DbMemTableSizeMB:           64,
+			DbDisableCompression:       true,
+			DbMaxOpenFiles:             1000,
+			DbMaxConcurrentCompactions: 4,
+			DbCompaction: server.DbCompactionOptions{
+				Interval:    24 * time.Hour,
+				Window:      "22:00-04:00",
+				Concurrency: 2,
+			},
+			DiskUsageRefreshInterval:  30 * time.Second,
+			ShardRecoveryParallelism:  4,
+			ReplicationCompression:    "zstd",
+			EntryCompressionThreshold: 1024,
+			DiskWatermarks: server.DiskWatermarkOptions{
+				High:          "90%",
+				Low:           "85%",
+				CheckInterval: 1 * time.Minute,
+			},
+			WriteRateLimit: server.WriteRateLimitOptions{
+				RequestsPerSecond:   1000,
+				ShardBytesPerSecond: 1048576,
+			},
+			GrpcInterceptors: container.InterceptorOptions{
+				AccessLog: true,
+			},
+			Grpc: common.GrpcOptions{
+				KeepAliveTime:     20 * time.Second,
+				KeepAliveTimeout:  5 * time.Second,
+				KeepAliveMinTime:  common.DefaultGrpcKeepAliveMinTime,
+				MaxMessageSize:    common.DefaultGrpcMaxMessageSize,
+				InitialWindowSize: 1048576,
+			},
+			Maintenance:            true,
+			SkipUnappliableEntries: true,
+			Locality: server.Locality{
+				Zone: "zone-a",
+				Host: "host-1",
+			},
 		}, false},
 	} {
 		t.Run(strings.Join(test.args, "_"), func(t *testing.T) {
@@ -24,6 +24,7 @@ import (
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/server"
 	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
 )
 
 var (
@@ -40,14 +41,35 @@ var (
 func init() {
 	flag.PublicAddr(Cmd, &conf.PublicServiceAddr)
 	flag.MetricsAddr(Cmd, &conf.MetricsServiceAddr)
+	Cmd.Flags().StringVarP(&conf.InternalServiceAddr, "internal-addr", "i", "", "Internal service bind address, used to take backups of the shards. Empty means disabled")
 	Cmd.Flags().Uint32VarP(&conf.NumShards, "shards", "s", 1, "Number of shards")
 	Cmd.Flags().StringVar(&conf.DataDir, "data-dir", "./data/db", "Directory where to store data")
+	Cmd.Flags().BoolVar(&conf.InMemory, "in-memory", false, "Whether to keep the data in memory. All the data is lost when the process exits")
 	Cmd.Flags().StringVar(&conf.WalDir, "wal-dir", "./data/wal", "Directory for write-ahead-logs")
 	Cmd.Flags().DurationVar(&conf.WalRetentionTime, "wal-retention-time", 1*time.Hour, "Retention time for the entries in the write-ahead-log")
-	Cmd.Flags().BoolVar(&conf.WalSyncData, "wal-sync-data", true, "Whether to sync data in write-ahead-log")
+	Cmd.Flags().Var(&conf.WalSyncPolicy, "wal-sync-policy", "When to sync the write-ahead-log on disk: always, before acknowledging the entries, interval, in background at the wal-sync-interval, or never")
+	Cmd.Flags().DurationVar(&conf.WalSyncInterval, "wal-sync-interval", wal.DefaultFactoryOptions.SyncInterval, "Interval for syncing the write-ahead-log in background with the interval sync policy")
 	Cmd.Flags().DurationVar(&conf.NotificationsRetentionTime, "notifications-retention-time", 1*time.Hour, "Retention time for the db notifications to clients")
 	Cmd.Flags().Int64Var(&conf.DbBlockCacheMB, "db-cache-size-mb", kv.DefaultFactoryOptions.CacheSizeMB,
 		"Max size of the shared DB cache")
+	Cmd.Flags().Int64Var(&conf.DbMemTableSizeMB, "db-memtable-size-mb", kv.DefaultFactoryOptions.MemTableSizeMB,
+		"Size of the DB memtable of each shard")
+	Cmd.Flags().BoolVar(&conf.DbDisableCompression, "db-disable-compression", false, "Whether to store the DB files without compression")
+	Cmd.Flags().IntVar(&conf.DbMaxOpenFiles, "db-max-open-files", kv.DefaultFactoryOptions.MaxOpenFiles,
+		"Max number of files kept open by the DB of each shard")
+	Cmd.Flags().IntVar(&conf.DbMaxConcurrentCompactions, "db-max-concurrent-compactions", kv.DefaultFactoryOptions.MaxConcurrentCompactions,
+		"Max number of compactions run concurrently by the DB of each shard")
+	Cmd.Flags().DurationVar(&conf.DbCompaction.Interval, "db-compaction-interval", 0, "Interval between the compactions of the DB of each shard, which drop the deleted and overwritten records. 0 means disabled")
+	Cmd.Flags().StringVar(&conf.DbCompaction.Window, "db-compaction-window", "", "Time of the day of the DB compactions, as HH:MM-HH:MM in local time (e.g. 01:00-05:00). Empty means any time")
+	Cmd.Flags().IntVar(&conf.DbCompaction.Concurrency, "db-compaction-concurrency", 0, "Max number of shards whose DB is compacted at the same time. 0 means 1")
+	Cmd.Flags().DurationVar(&conf.DiskUsageRefreshInterval, "disk-usage-refresh-interval", server.DefaultDiskUsageRefreshInterval, "Interval for measuring the disk space taken by each shard")
+	Cmd.Flags().IntVar(&conf.ShardRecoveryParallelism, "shard-recovery-parallelism", 0, "Number of shards recovered concurrently at startup. 0 means the number of CPUs")
+	Cmd.Flags().IntVar(&conf.EntryCompressionThreshold, "entry-compression-threshold", 0, "Size in bytes above which the values of the log entries are compressed. 0 means the entries are never compressed")
+	Cmd.Flags().StringVar(&conf.DiskWatermarks.High, "disk-high-watermark", "", "Disk usage above which the leaders reject the writes, as a percentage of the disk (e.g. 90%) or as the free space left (e.g. 10GiB). Empty means disabled")
+	Cmd.Flags().StringVar(&conf.DiskWatermarks.Low, "disk-low-watermark", "", "Disk usage below which the writes are accepted again. Empty means the high watermark")
+	Cmd.Flags().DurationVar(&conf.DiskWatermarks.CheckInterval, "disk-watermark-check-interval", server.DefaultDiskWatermarkCheckInterval, "Interval for checking the disk usage against the watermarks")
+	Cmd.Flags().BoolVar(&conf.GrpcInterceptors.DisablePanicRecovery, "grpc-disable-panic-recovery", false, "Whether to let a panic in a gRPC handler crash the server, instead of failing the request")
+	Cmd.Flags().BoolVar(&conf.GrpcInterceptors.AccessLog, "grpc-access-log", false, "Whether to log every gRPC request at debug level")
 }
 
 func exec(*cobra.Command, []string) {
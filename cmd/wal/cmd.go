@@ -0,0 +1,210 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+type inspectConfig struct {
+	walDir     string
+	namespace  string
+	shard      int64
+	fromOffset int64
+	toOffset   int64
+	decode     bool
+	verify     bool
+	output     string
+}
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "wal",
+		Short: "WAL utils",
+		Long:  `Tools to troubleshoot the write-ahead logs of the shards, while the server is stopped`,
+	}
+
+	inspectCmd = &cobra.Command{
+		Use:   "inspect",
+		Short: "Inspect the WAL of a shard",
+		Long: `Print the entries of the WAL of a shard, or verify all its records with --verify. The WAL is opened
+read-only and cannot be inspected while it's in use by a server.`,
+		Args:         cobra.NoArgs,
+		RunE:         exec,
+		SilenceUsage: true,
+	}
+
+	config = inspectConfig{}
+)
+
+func init() {
+	inspectCmd.Flags().StringVar(&config.walDir, "wal-dir", wal.DefaultFactoryOptions.BaseWalDir, "Directory of the WALs of the server")
+	inspectCmd.Flags().StringVarP(&config.namespace, "namespace", "n", common.DefaultNamespace, "Namespace of the shard")
+	inspectCmd.Flags().Int64Var(&config.shard, "shard", 0, "Id of the shard")
+	inspectCmd.Flags().Int64Var(&config.fromOffset, "from-offset", wal.InvalidOffset, "First offset to print. Default is the first entry")
+	inspectCmd.Flags().Int64Var(&config.toOffset, "to-offset", wal.InvalidOffset, "Last offset to print. Default is the last entry")
+	inspectCmd.Flags().BoolVar(&config.decode, "decode", false, "Print the operations of the entries")
+	inspectCmd.Flags().BoolVar(&config.verify, "verify", false, "Verify the framing and the checksums of all the records, and report the first corruption")
+	inspectCmd.Flags().StringVarP(&config.output, "output", "o", outputText, "Output format. Allowed value: text, json")
+
+	Cmd.AddCommand(inspectCmd)
+}
+
+func exec(cmd *cobra.Command, _ []string) error {
+	if config.output != outputText && config.output != outputJSON {
+		return errors.Errorf("invalid output format: %s", config.output)
+	}
+
+	inspector, err := wal.NewInspector(config.walDir, config.namespace, config.shard)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = inspector.Close()
+	}()
+
+	if config.verify {
+		return verify(cmd.OutOrStdout(), inspector)
+	}
+	return printEntries(cmd.OutOrStdout(), inspector)
+}
+
+type outputEntry struct {
+	Offset    int64           `json:"offset"`
+	Term      int64           `json:"term"`
+	Size      int             `json:"size"`
+	Timestamp time.Time       `json:"timestamp"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+func printEntries(out io.Writer, inspector wal.Inspector) error {
+	fromOffset := max(config.fromOffset, inspector.FirstOffset())
+	toOffset := inspector.LastOffset()
+	if config.toOffset != wal.InvalidOffset {
+		toOffset = min(config.toOffset, toOffset)
+	}
+
+	encoder := json.NewEncoder(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if config.output == outputText {
+		header := "OFFSET\tTERM\tSIZE\tTIMESTAMP"
+		if config.decode {
+			header += "\tVALUE"
+		}
+		_, _ = fmt.Fprintln(w, header)
+	}
+
+	for offset := fromOffset; offset <= toOffset && offset != wal.InvalidOffset; offset++ {
+		entry, err := inspector.Read(offset)
+		if err != nil {
+			return multierr.Append(err, w.Flush())
+		}
+
+		oe, err := newOutputEntry(entry)
+		if err != nil {
+			return multierr.Append(err, w.Flush())
+		}
+
+		if config.output == outputJSON {
+			if err = encoder.Encode(oe); err != nil {
+				return err
+			}
+			continue
+		}
+
+		line := fmt.Sprintf("%d\t%d\t%d\t%s", oe.Offset, oe.Term, oe.Size, oe.Timestamp.Format(time.RFC3339Nano))
+		if config.decode {
+			line += "\t" + string(oe.Value)
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+
+	return w.Flush()
+}
+
+func newOutputEntry(entry *proto.LogEntry) (outputEntry, error) {
+	oe := outputEntry{
+		Offset:    entry.Offset,
+		Term:      entry.Term,
+		Size:      len(entry.Value),
+		Timestamp: time.UnixMilli(int64(entry.Timestamp)),
+	}
+	if !config.decode {
+		return oe, nil
+	}
+
+	value, err := server.DecodeLogEntryValue(entry)
+	if err != nil {
+		return oe, err
+	}
+	if oe.Value, err = protojson.Marshal(value); err != nil {
+		return oe, errors.Wrapf(err, "failed to print the entry at offset %d", entry.Offset)
+	}
+	return oe, nil
+}
+
+type outputVerification struct {
+	Entries        int64  `json:"entries"`
+	IncompleteTail bool   `json:"incomplete_tail"`
+	Error          string `json:"error,omitempty"`
+}
+
+// verify prints the number of valid entries before the first corruption. The
+// corruption is returned, so that the command fails.
+func verify(out io.Writer, inspector wal.Inspector) error {
+	entries, err := inspector.Verify()
+	res := outputVerification{
+		Entries:        entries,
+		IncompleteTail: inspector.IncompleteTail(),
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	if config.output == outputJSON {
+		if encodeErr := json.NewEncoder(out).Encode(res); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "Valid entries: %d\n", res.Entries)
+	if res.IncompleteTail {
+		_, _ = fmt.Fprintln(out, "The last record is incomplete and is discarded when the WAL is opened")
+	}
+	if err == nil {
+		_, _ = fmt.Fprintln(out, "No corruption found")
+	}
+	return err
+}
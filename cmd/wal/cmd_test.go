@@ -0,0 +1,98 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/oxia"
+	"github.com/streamnative/oxia/server"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+func runInspect(t *testing.T, walDir string, args ...string) (string, error) {
+	t.Helper()
+
+	// The flags keep their values between the executions
+	stdout := bytes.NewBufferString("")
+	Cmd.SetOut(stdout)
+	Cmd.SetArgs(append([]string{"inspect", "--wal-dir", walDir, "--from-offset", "-1", "--to-offset", "-1",
+		"--decode=false", "--verify=false", "-o", "text"}, args...))
+	err := Cmd.Execute()
+	return stdout.String(), err
+}
+
+func TestInspectCmd(t *testing.T) {
+	dir := t.TempDir()
+	walDir := filepath.Join(dir, "wal")
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(dir))
+	assert.NoError(t, err)
+
+	client, err := oxia.NewSyncClient(fmt.Sprintf("localhost:%d", standaloneServer.RpcPort()))
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, _, err = client.Put(context.Background(), fmt.Sprintf("key-%d", i), []byte("value"))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, client.Close())
+
+	// The wal cannot be inspected while it's in use
+	_, err = runInspect(t, walDir)
+	assert.ErrorIs(t, err, wal.ErrWalLocked)
+	assert.NoError(t, standaloneServer.Close())
+
+	out, err := runInspect(t, walDir, "--decode", "-o", "json")
+	assert.NoError(t, err)
+
+	var entries []outputEntry
+	scanner := bufio.NewScanner(bytes.NewBufferString(out))
+	for scanner.Scan() {
+		var entry outputEntry
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	assert.GreaterOrEqual(t, len(entries), 3)
+	for i, entry := range entries[len(entries)-3:] {
+		assert.Positive(t, entry.Size)
+		assert.Contains(t, string(entry.Value), fmt.Sprintf(`"key":"key-%d"`, i))
+	}
+	lastOffset := entries[len(entries)-1].Offset
+
+	// The range of offsets
+	out, err = runInspect(t, walDir, "--from-offset", fmt.Sprint(lastOffset-1), "--to-offset", fmt.Sprint(lastOffset))
+	assert.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace([]byte(out)), []byte("\n"))
+	assert.Len(t, lines, 3)
+	assert.Contains(t, string(lines[0]), "OFFSET")
+	assert.Contains(t, string(lines[2]), fmt.Sprint(lastOffset))
+
+	out, err = runInspect(t, walDir, "--verify", "-o", "json")
+	assert.NoError(t, err)
+	var verification outputVerification
+	assert.NoError(t, json.Unmarshal([]byte(out), &verification))
+	assert.EqualValues(t, lastOffset+1, verification.Entries)
+	assert.Empty(t, verification.Error)
+
+	_, err = runInspect(t, walDir, "-o", "yaml")
+	assert.ErrorContains(t, err, "invalid output format")
+}
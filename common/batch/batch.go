@@ -14,6 +14,23 @@
 
 package batch
 
+// FlushReason tells why a batch is sent.
+type FlushReason string
+
+const (
+	// FlushReasonFull is used when the batch can't take the next call.
+	FlushReasonFull FlushReason = "full"
+	// FlushReasonMaxRequests is used when the batch has the maximum number of
+	// requests.
+	FlushReasonMaxRequests FlushReason = "max_requests"
+	// FlushReasonLinger is used when the linger time has elapsed, or right
+	// away when there's no linger time.
+	FlushReasonLinger FlushReason = "linger"
+	// FlushReasonClose is used for the pending batch of a batcher that is
+	// closed.
+	FlushReasonClose FlushReason = "close"
+)
+
 type Batch interface {
 	CanAdd(any) bool
 	Add(any)
@@ -31,16 +31,23 @@ type Batcher interface {
 
 type batcherImpl struct {
 	batchFactory        func() Batch
+	flushed             func(FlushReason)
 	callC               chan any
 	closeC              chan bool
+	doneC               chan bool
 	closed              atomic.Bool
 	linger              time.Duration
 	maxRequestsPerBatch int
 }
 
+// Close completes the pending batch and the calls that were already added,
+// and waits until they are done. The calls added afterward are failed.
 func (b *batcherImpl) Close() error {
-	b.closed.Store(true)
+	if b.closed.Swap(true) {
+		return nil
+	}
 	close(b.closeC)
+	<-b.doneC
 	return nil
 }
 
@@ -59,6 +66,8 @@ func (b *batcherImpl) failCall(call any, err error) {
 }
 
 func (b *batcherImpl) Run() { //nolint:revive
+	defer close(b.doneC)
+
 	var batch Batch
 	var timer *time.Timer
 	var timeout <-chan time.Time
@@ -70,10 +79,13 @@ func (b *batcherImpl) Run() { //nolint:revive
 			timeout = timer.C
 		}
 	}
-	completeBatch := func() {
+	completeBatch := func(reason FlushReason) {
 		if b.linger > 0 {
 			timer.Stop()
 		}
+		if b.flushed != nil {
+			b.flushed(reason)
+		}
 		batch.Complete()
 		batch = nil
 	}
@@ -86,31 +98,39 @@ func (b *batcherImpl) Run() { //nolint:revive
 			}
 			canAdd := batch.CanAdd(call)
 			if !canAdd {
-				completeBatch()
+				completeBatch(FlushReasonFull)
 				newBatch()
 			}
 			batch.Add(call)
-			if batch.Size() == b.maxRequestsPerBatch || b.linger == 0 {
-				completeBatch()
+			if batch.Size() >= b.maxRequestsPerBatch {
+				completeBatch(FlushReasonMaxRequests)
+			} else if b.linger == 0 {
+				completeBatch(FlushReasonLinger)
 			}
 
 		case <-timeout:
 			if batch != nil {
-				timer.Stop()
-				batch.Complete()
-				batch = nil
+				completeBatch(FlushReasonLinger)
 			}
 		case <-b.closeC:
-			if batch != nil {
-				timer.Stop()
-				batch.Fail(ErrShuttingDown)
-				batch = nil
-			}
 			for {
 				select {
 				case call := <-b.callC:
-					b.failCall(call, ErrShuttingDown)
+					if batch == nil {
+						newBatch()
+					}
+					if !batch.CanAdd(call) {
+						completeBatch(FlushReasonFull)
+						newBatch()
+					} else if batch.Size() >= b.maxRequestsPerBatch {
+						completeBatch(FlushReasonMaxRequests)
+						newBatch()
+					}
+					batch.Add(call)
 				default:
+					if batch != nil {
+						completeBatch(FlushReasonClose)
+					}
 					return
 				}
 			}
@@ -26,11 +26,16 @@ type BatcherFactory struct {
 	MaxRequestsPerBatch int
 }
 
-func (b *BatcherFactory) NewBatcher(batchFactory func() Batch) Batcher {
+// NewBatcher creates a batcher of the batches made by the factory. The
+// optional flushed listener is called, from the batcher goroutine, right
+// before each batch is completed.
+func (b *BatcherFactory) NewBatcher(batchFactory func() Batch, flushed func(FlushReason)) Batcher {
 	batcher := &batcherImpl{
 		batchFactory:        batchFactory,
+		flushed:             flushed,
 		callC:               make(chan any, batcherChannelBufferSize),
 		closeC:              make(chan bool),
+		doneC:               make(chan bool),
 		linger:              b.Linger,
 		maxRequestsPerBatch: b.MaxRequestsPerBatch,
 	}
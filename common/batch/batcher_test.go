@@ -62,10 +62,12 @@ func TestBatcher(t *testing.T) {
 		maxSize          int
 		closeImmediately bool
 		expectedErr      error
+		expectedReason   FlushReason
 	}{
-		{"complete on maxRequestsPerBatch", 1 * time.Second, 1, false, nil},
-		{"complete on linger", 1 * time.Millisecond, 2, false, nil},
-		{"fail on close", 1 * time.Second, 2, true, ErrShuttingDown},
+		{"complete on maxRequestsPerBatch", 1 * time.Second, 1, false, nil, FlushReasonMaxRequests},
+		{"complete on linger", 1 * time.Millisecond, 2, false, nil, FlushReasonLinger},
+		{"complete without linger", 0, 2, false, nil, FlushReasonLinger},
+		{"complete on close", 1 * time.Second, 2, true, nil, FlushReasonClose},
 	} {
 		t.Run(item.name, func(t *testing.T) {
 			testBatch := newTestBatch()
@@ -78,7 +80,10 @@ func TestBatcher(t *testing.T) {
 				Linger:              item.linger,
 				MaxRequestsPerBatch: item.maxSize,
 			}
-			batcher := factory.NewBatcher(batchFactory)
+			reasons := make(chan FlushReason, 1)
+			batcher := factory.NewBatcher(batchFactory, func(reason FlushReason) {
+				reasons <- reason
+			})
 			batcher.Add(1)
 
 			if item.closeImmediately {
@@ -87,6 +92,7 @@ func TestBatcher(t *testing.T) {
 			}
 
 			assert.ErrorIs(t, <-testBatch.result, item.expectedErr)
+			assert.Equal(t, item.expectedReason, <-reasons)
 
 			if !item.closeImmediately {
 				err := batcher.Close()
@@ -95,3 +101,24 @@ func TestBatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestBatcher_AddAfterClose(t *testing.T) {
+	factory := &BatcherFactory{
+		Linger:              1 * time.Second,
+		MaxRequestsPerBatch: 2,
+	}
+	batcher := factory.NewBatcher(func() Batch {
+		return newTestBatch()
+	}, nil)
+	assert.NoError(t, batcher.Close())
+	assert.NoError(t, batcher.Close())
+
+	testBatch := newTestBatch()
+	batcher = factory.NewBatcher(func() Batch {
+		return testBatch
+	}, nil)
+	assert.NoError(t, batcher.Close())
+
+	batcher.Add(1)
+	assert.ErrorIs(t, <-testBatch.result, ErrShuttingDown)
+}
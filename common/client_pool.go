@@ -52,6 +52,7 @@ type clientPool struct {
 
 	tls            *tls.Config
 	authentication auth.Authentication
+	dialOptions    []grpc.DialOption
 	log            *slog.Logger
 }
 
@@ -66,6 +67,14 @@ func NewClientPool(tlsConf *tls.Config, authentication auth.Authentication) Clie
 	}
 }
 
+// NewPeerClientPool creates the pool of the connections that the nodes open
+// to each other, with the keepalive and transport settings of the options.
+func NewPeerClientPool(tlsConf *tls.Config, options GrpcOptions) ClientPool {
+	cp := NewClientPool(tlsConf, nil).(*clientPool)
+	cp.dialOptions = options.DialOptions()
+	return cp
+}
+
 func (cp *clientPool) Close() error {
 	cp.Lock()
 	defer cp.Unlock()
@@ -154,6 +163,7 @@ func (cp *clientPool) getConnection(target string) (grpc.ClientConnInterface, er
 	if cp.authentication != nil {
 		options = append(options, grpc.WithPerRPCCredentials(cp.authentication))
 	}
+	options = append(options, cp.dialOptions...)
 	cnx, err := grpc.NewClient(target, options...)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error connecting to %s", target)
@@ -0,0 +1,65 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	None = "none"
+	Gzip = gzip.Name
+	Zstd = "zstd"
+)
+
+// The encoder and the decoder are safe for concurrent use, when going
+// through EncodeAll and DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// ValidateGrpcCompressor checks that the name is one of the compressors
+// available for the gRPC connections. An empty name means no compression.
+func ValidateGrpcCompressor(name string) error {
+	switch name {
+	case "", None, Gzip, Zstd:
+		return nil
+	default:
+		return fmt.Errorf("invalid compression %q. Supported: %s, %s, %s", name, None, Gzip, Zstd)
+	}
+}
+
+// GrpcCompressor returns the name of the compressor to use in the gRPC
+// calls, or an empty string when the calls are not compressed.
+func GrpcCompressor(name string) string {
+	if name == None {
+		return ""
+	}
+	return name
+}
+
+// ZstdCompress appends the compressed src to dst.
+func ZstdCompress(dst, src []byte) []byte {
+	return zstdEncoder.EncodeAll(src, dst)
+}
+
+// ZstdDecompress appends the decompressed src to dst.
+func ZstdDecompress(dst, src []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(src, dst)
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestValidateGrpcCompressor(t *testing.T) {
+	for _, name := range []string{"", None, Gzip, Zstd} {
+		assert.NoError(t, ValidateGrpcCompressor(name))
+	}
+	assert.Error(t, ValidateGrpcCompressor("snappy"))
+
+	assert.Equal(t, "", GrpcCompressor(""))
+	assert.Equal(t, "", GrpcCompressor(None))
+	assert.Equal(t, Zstd, GrpcCompressor(Zstd))
+}
+
+func TestGrpcZstdCompressor(t *testing.T) {
+	c := encoding.GetCompressor(Zstd)
+	assert.NotNil(t, c)
+
+	msg := []byte(strings.Repeat(`{"key":"value"}`, 100))
+
+	buf := &bytes.Buffer{}
+	w, err := c.Compress(buf)
+	assert.NoError(t, err)
+	_, err = w.Write(msg[:10])
+	assert.NoError(t, err)
+	_, err = w.Write(msg[10:])
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Less(t, buf.Len(), len(msg))
+
+	r, err := c.Decompress(buf)
+	assert.NoError(t, err)
+	res, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, res)
+
+	_, err = c.Decompress(bytes.NewReader([]byte("not-compressed")))
+	assert.Error(t, err)
+}
+
+func TestGrpcCompressorNegotiation(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	client := grpc_health_v1.NewHealthClient(conn)
+	for _, name := range []string{Gzip, Zstd} {
+		res, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.UseCompressor(name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, res.Status)
+	}
+}
+
+// The bytes/op is the size of the messages and the "wire-bytes/op" metric
+// the size sent on the connection.
+func BenchmarkGrpcCompressors(b *testing.B) {
+	msg := []byte(strings.Repeat(`{"name":"item","enabled":true,"tags":["a","b","c"]},`, 300))
+
+	for _, name := range []string{Gzip, Zstd} {
+		c := encoding.GetCompressor(name)
+
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(msg)))
+			b.ReportAllocs()
+
+			buf := &bytes.Buffer{}
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				w, _ := c.Compress(buf)
+				_, _ = w.Write(msg)
+				_ = w.Close()
+				wireBytes := buf.Len()
+
+				r, err := c.Decompress(buf)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err = io.Copy(io.Discard, r); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(wireBytes), "wire-bytes/op")
+			}
+		})
+	}
+}
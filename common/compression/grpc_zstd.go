@@ -0,0 +1,70 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// The gzip compressor registers itself when its package is imported. The
+// zstd one is registered here, so that both the servers and the clients
+// can decode the messages compressed with it.
+func init() {
+	encoding.RegisterCompressor(&grpcZstdCompressor{})
+}
+
+// grpcZstdCompressor compresses each gRPC message as a single zstd frame.
+// The messages are already fully buffered by gRPC, so there is no gain in
+// streaming them through the encoder.
+type grpcZstdCompressor struct{}
+
+func (*grpcZstdCompressor) Name() string {
+	return Zstd
+}
+
+func (*grpcZstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &zstdMessageWriter{w: w}, nil
+}
+
+func (*grpcZstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := ZstdDecompress(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(msg), nil
+}
+
+type zstdMessageWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (z *zstdMessageWriter) Write(p []byte) (int, error) {
+	z.buf = append(z.buf, p...)
+	return len(p), nil
+}
+
+func (z *zstdMessageWriter) Close() error {
+	_, err := z.w.Write(ZstdCompress(nil, z.buf))
+	return err
+}
@@ -25,6 +25,7 @@ const (
 	DefaultPublicPort   = 6648
 	DefaultInternalPort = 6649
 	DefaultMetricsPort  = 8080
+	DefaultAdminPort    = 6650
 
 	MaxSessionTimeout = 5 * time.Minute
 	MinSessionTimeout = 2 * time.Second
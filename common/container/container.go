@@ -34,9 +34,19 @@ import (
 )
 
 const (
-	maxGrpcFrameSize = 256 * 1024 * 1024
+	// MaxGrpcFrameSize is the largest message accepted by the gRPC servers,
+	// unless another size is set in the options
+	MaxGrpcFrameSize = common.DefaultGrpcMaxMessageSize
 
 	ReadinessProbeService = "oxia-readiness"
+
+	// LeadershipHealthService is not serving when a node asks for the
+	// leaderships it holds to be moved to other nodes
+	LeadershipHealthService = "oxia-leadership"
+
+	// DiskHealthService is not serving when the disk of a node is above the
+	// high watermark and the node rejects the writes
+	DiskHealthService = "oxia-disk"
 )
 
 type GrpcServer interface {
@@ -49,34 +59,48 @@ type GrpcProvider interface {
 	StartGrpcServer(name, bindAddress string, registerFunc func(grpc.ServiceRegistrar), tlsConf *tls.Config, options *auth.Options) (GrpcServer, error)
 }
 
-var Default = &defaultProvider{}
+var Default = NewGrpcProvider(InterceptorOptions{}, common.GrpcOptions{})
+
+// NewGrpcProvider creates a provider whose servers have the interceptors
+// selected in the options, and the keepalive and transport settings of the
+// grpc options.
+func NewGrpcProvider(interceptors InterceptorOptions, grpcOptions common.GrpcOptions) GrpcProvider {
+	return &defaultProvider{interceptors: interceptors, grpcOptions: grpcOptions}
+}
 
 type defaultProvider struct {
+	interceptors InterceptorOptions
+	grpcOptions  common.GrpcOptions
 }
 
-func (*defaultProvider) StartGrpcServer(name, bindAddress string, registerFunc func(grpc.ServiceRegistrar), tlsConf *tls.Config, options *auth.Options) (GrpcServer, error) {
-	return newDefaultGrpcProvider(name, bindAddress, registerFunc, tlsConf, options)
+func (p *defaultProvider) StartGrpcServer(name, bindAddress string, registerFunc func(grpc.ServiceRegistrar), tlsConf *tls.Config, options *auth.Options) (GrpcServer, error) {
+	return newDefaultGrpcProvider(name, bindAddress, registerFunc, tlsConf, options, p.interceptors, p.grpcOptions)
 }
 
 type defaultGrpcServer struct {
 	io.Closer
-	server *grpc.Server
-	port   int
-	log    *slog.Logger
+	server       *grpc.Server
+	authProvider auth.AuthenticationProvider
+	port         int
+	log          *slog.Logger
 }
 
 func newDefaultGrpcProvider(name, bindAddress string, registerFunc func(grpc.ServiceRegistrar),
-	tlsConf *tls.Config, authOptions *auth.Options) (GrpcServer, error) {
+	tlsConf *tls.Config, authOptions *auth.Options, interceptors InterceptorOptions, grpcOptions common.GrpcOptions) (GrpcServer, error) {
 	tcs := insecure.NewCredentials()
 	if tlsConf != nil {
 		tcs = credentials.NewTLS(tlsConf)
 	}
-	streamInterceptors := []grpc.StreamServerInterceptor{
-		grpcprometheus.StreamServerInterceptor,
-	}
-	unaryInterceptors := []grpc.UnaryServerInterceptor{
-		grpcprometheus.UnaryServerInterceptor,
+	var streamInterceptors []grpc.StreamServerInterceptor
+	var unaryInterceptors []grpc.UnaryServerInterceptor
+	var authProvider auth.AuthenticationProvider
+	if interceptors.AccessLog {
+		al := newAccessLog(name)
+		streamInterceptors = append(streamInterceptors, al.stream)
+		unaryInterceptors = append(unaryInterceptors, al.unary)
 	}
+	streamInterceptors = append(streamInterceptors, grpcprometheus.StreamServerInterceptor)
+	unaryInterceptors = append(unaryInterceptors, grpcprometheus.UnaryServerInterceptor)
 	if authOptions.IsEnabled() {
 		provider, err := auth.NewAuthenticationProvider(context.Background(), *authOptions)
 		if err != nil {
@@ -85,6 +109,7 @@ func newDefaultGrpcProvider(name, bindAddress string, registerFunc func(grpc.Ser
 				slog.Any("error", err))
 			return nil, err
 		}
+		authProvider = provider
 		delegator, err := auth.NewGrpcAuthenticationDelegator(provider)
 		if err != nil {
 			slog.Error("Failed to init grpc authentication delegator",
@@ -96,13 +121,21 @@ func newDefaultGrpcProvider(name, bindAddress string, registerFunc func(grpc.Ser
 		streamInterceptors = append(streamInterceptors, delegator.GetStreamInterceptor())
 	}
 
+	// The panics are recovered closest to the handlers, so that the other
+	// interceptors observe the resulting error
+	if !interceptors.DisablePanicRecovery {
+		pr := newPanicRecovery(name)
+		streamInterceptors = append(streamInterceptors, pr.stream)
+		unaryInterceptors = append(unaryInterceptors, pr.unary)
+	}
+
 	c := &defaultGrpcServer{
-		server: grpc.NewServer(
+		server: grpc.NewServer(append([]grpc.ServerOption{
 			grpc.Creds(tcs),
 			grpc.ChainStreamInterceptor(streamInterceptors...),
 			grpc.ChainUnaryInterceptor(unaryInterceptors...),
-			grpc.MaxRecvMsgSize(maxGrpcFrameSize),
-		),
+		}, grpcOptions.ServerOptions()...)...),
+		authProvider: authProvider,
 	}
 	registerFunc(c.server)
 	grpcprometheus.Register(c.server)
@@ -147,6 +180,12 @@ func (c *defaultGrpcServer) Port() int {
 
 func (c *defaultGrpcServer) Close() error {
 	c.server.GracefulStop()
+	// Some providers refresh their credentials in the background
+	if closer, ok := c.authProvider.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
 	c.log.Info("Stopped Grpc server")
 	return nil
 }
@@ -0,0 +1,153 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/metrics"
+)
+
+// InterceptorOptions selects the optional interceptors installed on the
+// gRPC servers.
+type InterceptorOptions struct {
+	// DisablePanicRecovery lets a panic in a handler crash the process,
+	// instead of failing the call with an Internal error
+	DisablePanicRecovery bool
+
+	// AccessLog logs every call at debug level
+	AccessLog bool
+}
+
+type panicRecovery struct {
+	panics metrics.Counter
+	log    *slog.Logger
+}
+
+func newPanicRecovery(name string) *panicRecovery {
+	return &panicRecovery{
+		panics: metrics.NewCounter("oxia_grpc_server_panics",
+			"The number of panics recovered in the gRPC handlers", "count", map[string]any{"server": name}),
+		log: slog.With(
+			slog.String("component", "grpc-panic-recovery"),
+			slog.String("grpc-server", name),
+		),
+	}
+}
+
+func (p *panicRecovery) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = p.recovered(ctx, req, info.FullMethod, r)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func (p *panicRecovery) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = p.recovered(ss.Context(), nil, info.FullMethod, r)
+		}
+	}()
+
+	return handler(srv, ss)
+}
+
+func (p *panicRecovery) recovered(ctx context.Context, req any, method string, r any) error {
+	p.panics.Inc()
+	p.log.Error(
+		"Recovered panic in gRPC handler",
+		slog.String("method", method),
+		slog.String("shard", shardFromCall(ctx, req)),
+		slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())),
+	)
+	return status.Errorf(codes.Internal, "oxia: internal error in %s", method)
+}
+
+type accessLog struct {
+	log *slog.Logger
+}
+
+func newAccessLog(name string) *accessLog {
+	return &accessLog{
+		log: slog.With(
+			slog.String("component", "grpc-access-log"),
+			slog.String("grpc-server", name),
+		),
+	}
+}
+
+func (a *accessLog) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+	a.record(ctx, req, info.FullMethod, start, err)
+	return res, err
+}
+
+func (a *accessLog) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	a.record(ss.Context(), nil, info.FullMethod, start, err)
+	return err
+}
+
+func (a *accessLog) record(ctx context.Context, req any, method string, start time.Time, err error) {
+	if !a.log.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	peerAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		peerAddr = p.Addr.String()
+	}
+
+	a.log.Debug(
+		"Handled gRPC call",
+		slog.String("method", method),
+		slog.String("peer", peerAddr),
+		slog.String("shard", shardFromCall(ctx, req)),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("code", status.Code(err).String()),
+	)
+}
+
+// The shard is either a field of the request, or it is passed in the
+// metadata of the streams.
+func shardFromCall(ctx context.Context, req any) string {
+	if r, ok := req.(interface{ GetShard() int64 }); ok {
+		return fmt.Sprintf("%d", r.GetShard())
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if shard := md.Get(common.MetadataShardId); len(shard) > 0 {
+			return shard[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/server/auth"
+)
+
+const panickingService = "panic"
+
+// A health server that panics when checking one specific service.
+type panickingHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (*panickingHealthServer) Check(_ context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if req.Service == panickingService {
+		panic("handler failure")
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (*panickingHealthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if req.Service == panickingService {
+		panic("handler failure")
+	}
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+func TestInterceptors_PanicRecovery(t *testing.T) {
+	provider := NewGrpcProvider(InterceptorOptions{AccessLog: true}, common.GrpcOptions{})
+	server, err := provider.StartGrpcServer("test", "localhost:0", func(registrar grpc.ServiceRegistrar) {
+		grpc_health_v1.RegisterHealthServer(registrar, &panickingHealthServer{})
+	}, nil, &auth.Disabled)
+	assert.NoError(t, err)
+
+	cnx, err := grpc.NewClient(fmt.Sprintf("localhost:%d", server.Port()),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	client := grpc_health_v1.NewHealthClient(cnx)
+
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: panickingService})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: panickingService})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// The server is still serving the other requests
+	res, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, res.Status)
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, server.Close())
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/auth"
+)
+
+// A proxy that silently stops forwarding the data of the connections that
+// stay idle for too long, without closing them, like some load balancers
+// do. It can also stop forwarding the data of all the connections, as when
+// the peer is gone.
+type idleDroppingProxy struct {
+	listener    net.Listener
+	target      string
+	idleTimeout time.Duration
+	blackholed  atomic.Bool
+	dropped     atomic.Int64
+
+	sync.Mutex
+	conns []net.Conn
+}
+
+func newIdleDroppingProxy(t *testing.T, target string, idleTimeout time.Duration) *idleDroppingProxy {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	p := &idleDroppingProxy{listener: listener, target: target, idleTimeout: idleTimeout}
+	go p.accept()
+	t.Cleanup(p.close)
+	return p
+}
+
+func (p *idleDroppingProxy) addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *idleDroppingProxy) accept() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			_ = client.Close()
+			continue
+		}
+
+		p.Lock()
+		p.conns = append(p.conns, client, server)
+		p.Unlock()
+
+		var lastActivity atomic.Int64
+		var dropped atomic.Bool
+		lastActivity.Store(time.Now().UnixNano())
+		forward := func(dst, src net.Conn) {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := src.Read(buf)
+				if err != nil {
+					return
+				}
+				if dropped.Load() || p.blackholed.Load() {
+					continue
+				}
+				lastActivity.Store(time.Now().UnixNano())
+				if _, err := dst.Write(buf[:n]); err != nil {
+					return
+				}
+			}
+		}
+		go forward(server, client)
+		go forward(client, server)
+
+		go func() {
+			ticker := time.NewTicker(p.idleTimeout / 10)
+			defer ticker.Stop()
+			for range ticker.C {
+				if time.Since(time.Unix(0, lastActivity.Load())) > p.idleTimeout {
+					dropped.Store(true)
+					p.dropped.Add(1)
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (p *idleDroppingProxy) close() {
+	_ = p.listener.Close()
+	p.Lock()
+	defer p.Unlock()
+	for _, c := range p.conns {
+		_ = c.Close()
+	}
+}
+
+// Acks the appends, and tells when the stream is over.
+type ackingReplicationServer struct {
+	proto.UnimplementedOxiaLogReplicationServer
+	streamDone chan error
+}
+
+func (s *ackingReplicationServer) Replicate(stream proto.OxiaLogReplication_ReplicateServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			s.streamDone <- err
+			return err
+		}
+		if err := stream.Send(&proto.Ack{Offset: req.CommitOffset}); err != nil {
+			s.streamDone <- err
+			return err
+		}
+	}
+}
+
+var testKeepAliveOptions = common.GrpcOptions{
+	// The servers can ping every second, while gRPC doesn't let the clients
+	// ping more often than every 10 seconds
+	KeepAliveTime:                time.Second,
+	KeepAliveTimeout:             time.Second,
+	KeepAliveMinTime:             500 * time.Millisecond,
+	KeepAlivePermitWithoutStream: true,
+}
+
+func startReplicationStream(t *testing.T, proxyIdleTimeout time.Duration) (
+	*idleDroppingProxy, *ackingReplicationServer, proto.OxiaLogReplication_ReplicateClient) {
+	t.Helper()
+
+	rs := &ackingReplicationServer{streamDone: make(chan error, 1)}
+	server, err := NewGrpcProvider(InterceptorOptions{}, testKeepAliveOptions).StartGrpcServer("keepalive", "localhost:0",
+		func(registrar grpc.ServiceRegistrar) {
+			proto.RegisterOxiaLogReplicationServer(registrar, rs)
+		}, nil, &auth.Disabled)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+
+	proxy := newIdleDroppingProxy(t, (&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: server.Port()}).String(), proxyIdleTimeout)
+
+	pool := common.NewPeerClientPool(nil, testKeepAliveOptions)
+	t.Cleanup(func() { _ = pool.Close() })
+	rpc, err := pool.GetReplicationRpc(proxy.addr())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	stream, err := rpc.Replicate(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&proto.Append{CommitOffset: 1}))
+	ack, err := stream.Recv()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, ack.Offset)
+	return proxy, rs, stream
+}
+
+func TestGrpcKeepAlive_IdleStream(t *testing.T) {
+	proxy, _, stream := startReplicationStream(t, 3*time.Second)
+
+	// The pings keep the connection active through the proxy
+	time.Sleep(5 * time.Second)
+	assert.EqualValues(t, 0, proxy.dropped.Load())
+
+	require.NoError(t, stream.Send(&proto.Append{CommitOffset: 2}))
+	ack, err := stream.Recv()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, ack.Offset)
+}
+
+func TestGrpcKeepAlive_DeadPeer(t *testing.T) {
+	proxy, rs, stream := startReplicationStream(t, time.Hour)
+
+	clientErr := make(chan error, 1)
+	go func() {
+		_, err := stream.Recv()
+		clientErr <- err
+	}()
+
+	// Nothing goes through anymore, without the connections being closed
+	proxy.blackholed.Store(true)
+
+	select {
+	case err := <-rs.streamDone:
+		assert.Error(t, err)
+	case <-time.After(testKeepAliveOptions.KeepAliveTime + testKeepAliveOptions.KeepAliveTimeout + 3*time.Second):
+		assert.Fail(t, "the server did not detect the dead connection")
+	}
+
+	select {
+	case err := <-clientErr:
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, io.EOF)
+	case <-time.After(10*time.Second + testKeepAliveOptions.KeepAliveTimeout + 5*time.Second):
+		assert.Fail(t, "the client did not detect the dead connection")
+	}
+}
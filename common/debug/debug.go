@@ -0,0 +1,120 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/common"
+)
+
+var publishOnce sync.Once
+
+// publishRuntimeStats adds the runtime stats to the ones that expvar already
+// publishes, the command line and the memory stats.
+func publishRuntimeStats() {
+	publishOnce.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() any {
+			return runtime.NumGoroutine()
+		}))
+		expvar.Publish("gomaxprocs", expvar.Func(func() any {
+			return runtime.GOMAXPROCS(0)
+		}))
+		expvar.Publish("cgocalls", expvar.Func(func() any {
+			return runtime.NumCgoCall()
+		}))
+	})
+}
+
+// Server serves the pprof profiles, the runtime stats at /debug/vars and the
+// pages of the process, on an http listener that is separate from the
+// metrics one, so that it can be kept private.
+type Server struct {
+	server *http.Server
+	mux    *http.ServeMux
+	port   int
+}
+
+// Start binds the listener and serves the debug endpoints in the background.
+// The pages of the process are added later with Handle, so that the listener
+// is available while the process is still starting.
+func Start(bindAddress string) (*Server, error) {
+	publishRuntimeStats()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	listener, err := net.Listen("tcp", bindAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: time.Second,
+		},
+		mux:  mux,
+		port: listener.Addr().(*net.TCPAddr).Port,
+	}
+
+	slog.Info(fmt.Sprintf("Serving debug endpoints at http://localhost:%d/debug/", s.port))
+
+	go common.DoWithLabels(
+		context.Background(),
+		map[string]string{
+			"oxia": "debug",
+		},
+		func() {
+			if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error(
+					"Failed to serve debug endpoints",
+					slog.Any("error", err),
+				)
+			}
+		},
+	)
+
+	return s, nil
+}
+
+// Handle registers an additional page on the debug server.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) Port() int {
+	return s.port
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, s *Server, path string) (int, []byte) {
+	t.Helper()
+	response, err := http.Get(fmt.Sprintf("http://localhost:%d%s", s.Port(), path))
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return response.StatusCode, body
+}
+
+func TestServer_Handlers(t *testing.T) {
+	s, err := Start("localhost:0")
+	require.NoError(t, err)
+
+	status, body := get(t, s, "/debug/pprof/")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "goroutine")
+
+	status, _ = get(t, s, "/debug/pprof/goroutine?debug=1")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = get(t, s, "/debug/pprof/cmdline")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = get(t, s, "/debug/vars")
+	assert.Equal(t, http.StatusOK, status)
+	vars := map[string]any{}
+	require.NoError(t, json.Unmarshal(body, &vars))
+	assert.Contains(t, vars, "memstats")
+	assert.Contains(t, vars, "goroutines")
+	assert.Contains(t, vars, "gomaxprocs")
+
+	// The pages are added after the server is started
+	status, _ = get(t, s, "/debug/page")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	s.Handle("/debug/page", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("page"))
+	}))
+	status, body = get(t, s, "/debug/page")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "page", string(body))
+
+	assert.NoError(t, s.Close())
+}
+
+func TestServer_StartTwice(t *testing.T) {
+	// The runtime stats are published only once
+	s1, err := Start("localhost:0")
+	require.NoError(t, err)
+	s2, err := Start("localhost:0")
+	require.NoError(t, err)
+
+	assert.NoError(t, s1.Close())
+	assert.NoError(t, s2.Close())
+}
@@ -31,6 +31,19 @@ const (
 	CodeInvalidSession         codes.Code = 108
 	CodeInvalidSessionTimeout  codes.Code = 109
 	CodeNamespaceNotFound      codes.Code = 110
+	CodeInvalidNextOffset      codes.Code = 111
+	CodeWalCorrupted           codes.Code = 112
+	CodeReadBarrierTimeout     codes.Code = 113
+	CodeShardSplit             codes.Code = 114
+	CodeShardAlreadyAssigned   codes.Code = 115
+	CodeEntryTooLarge          codes.Code = 116
+	CodeEntryUnappliable       codes.Code = 117
+	CodeTermNotFenced          codes.Code = 118
+	CodeSnapshotCorrupted      codes.Code = 119
+	CodeSnapshotOutdated       codes.Code = 120
+	CodeSnapshotMismatch       codes.Code = 121
+	CodeShardCatchingUp        codes.Code = 122
+	CodeNotEnoughFollowers     codes.Code = 123
 )
 
 var (
@@ -45,4 +58,17 @@ var (
 	ErrorInvalidSession         = status.Error(CodeInvalidSession, "oxia: session not found")
 	ErrorInvalidSessionTimeout  = status.Error(CodeInvalidSessionTimeout, "oxia: invalid session timeout")
 	ErrorNamespaceNotFound      = status.Error(CodeNamespaceNotFound, "oxia: namespace not found")
+	ErrorInvalidNextOffset      = status.Error(CodeInvalidNextOffset, "oxia: entry does not follow the head offset")
+	ErrorWalCorrupted           = status.Error(CodeWalCorrupted, "oxia: the wal of the shard is corrupted")
+	ErrorReadBarrierTimeout     = status.Error(CodeReadBarrierTimeout, "oxia: timed out waiting for the replica to catch up")
+	ErrorShardSplit             = status.Error(CodeShardSplit, "oxia: the shard was split")
+	ErrorShardAlreadyAssigned   = status.Error(CodeShardAlreadyAssigned, "oxia: the shard is already assigned to the node")
+	ErrorEntryTooLarge          = status.Error(CodeEntryTooLarge, "oxia: the log entry exceeds the max size")
+	ErrorEntryUnappliable       = status.Error(CodeEntryUnappliable, "oxia: the replica is blocked on an entry it cannot apply")
+	ErrorTermNotFenced          = status.Error(CodeTermNotFenced, "oxia: the follower was not fenced into the term")
+	ErrorSnapshotCorrupted      = status.Error(CodeSnapshotCorrupted, "oxia: the snapshot failed the checksum verification")
+	ErrorSnapshotOutdated       = status.Error(CodeSnapshotOutdated, "oxia: the snapshot is behind the entries applied by the follower")
+	ErrorSnapshotMismatch       = status.Error(CodeSnapshotMismatch, "oxia: the snapshot is of a shard with a different hash range")
+	ErrorShardCatchingUp        = status.Error(CodeShardCatchingUp, "oxia: the replica is installing a snapshot or catching up")
+	ErrorNotEnoughFollowers     = status.Error(CodeNotEnoughFollowers, "oxia: not enough followers are reachable to form a quorum")
 )
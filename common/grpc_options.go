@@ -0,0 +1,161 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	// DefaultGrpcKeepAliveTime is below the idle timeout of the common load
+	// balancers, which drop the idle connections after 60 seconds
+	DefaultGrpcKeepAliveTime       = 30 * time.Second
+	DefaultGrpcKeepAliveTimeout    = 10 * time.Second
+	DefaultGrpcKeepAliveMinTime    = 10 * time.Second
+	DefaultGrpcMaxMessageSize      = 256 * 1024 * 1024
+	DefaultGrpcPermitWithoutStream = true
+)
+
+// GrpcOptions are the keepalive, message size and flow control settings of
+// the gRPC servers, and of the connections that the nodes open to each
+// other. The zero value is the default options, and the zero fields are the
+// default values.
+type GrpcOptions struct {
+	// KeepAliveTime is how long a connection stays idle before it's pinged,
+	// both by the servers and by the clients. The pings keep the connection
+	// open through the load balancers and detect the peers that are gone
+	KeepAliveTime time.Duration
+
+	// KeepAliveTimeout is how long to wait for the ack of a ping before
+	// closing the connection, and failing its streams
+	KeepAliveTimeout time.Duration
+
+	// KeepAliveMinTime is the shortest interval between the pings of a
+	// client that the servers accept. The clients that ping more often are
+	// disconnected
+	KeepAliveMinTime time.Duration
+
+	// KeepAlivePermitWithoutStream pings the connections that have no
+	// active streams too, and lets the clients do so
+	KeepAlivePermitWithoutStream bool
+
+	// MaxMessageSize is the largest message that is sent or received
+	MaxMessageSize int
+
+	// InitialWindowSize and InitialConnWindowSize are the flow control
+	// windows of each stream and of each connection. 0 means the windows are
+	// sized dynamically by gRPC
+	InitialWindowSize     int32
+	InitialConnWindowSize int32
+}
+
+// DefaultGrpcOptions returns the options that are used when none are set.
+func DefaultGrpcOptions() GrpcOptions {
+	return GrpcOptions{
+		KeepAliveTime:                DefaultGrpcKeepAliveTime,
+		KeepAliveTimeout:             DefaultGrpcKeepAliveTimeout,
+		KeepAliveMinTime:             DefaultGrpcKeepAliveMinTime,
+		KeepAlivePermitWithoutStream: DefaultGrpcPermitWithoutStream,
+		MaxMessageSize:               DefaultGrpcMaxMessageSize,
+	}
+}
+
+// WithDefaults returns the options with the default values in place of the
+// fields that are not set.
+func (o GrpcOptions) WithDefaults() GrpcOptions {
+	if o == (GrpcOptions{}) {
+		return DefaultGrpcOptions()
+	}
+
+	d := DefaultGrpcOptions()
+	if o.KeepAliveTime == 0 {
+		o.KeepAliveTime = d.KeepAliveTime
+	}
+	if o.KeepAliveTimeout == 0 {
+		o.KeepAliveTimeout = d.KeepAliveTimeout
+	}
+	if o.KeepAliveMinTime == 0 {
+		o.KeepAliveMinTime = d.KeepAliveMinTime
+	}
+	if o.MaxMessageSize == 0 {
+		o.MaxMessageSize = d.MaxMessageSize
+	}
+	return o
+}
+
+func (o GrpcOptions) Validate() error {
+	if o.KeepAliveTime < 0 || o.KeepAliveTimeout < 0 || o.KeepAliveMinTime < 0 {
+		return errors.New("the grpc keepalive durations must not be negative")
+	}
+	if o.MaxMessageSize < 0 {
+		return errors.New("the grpc max message size must not be negative")
+	}
+	if o.InitialWindowSize < 0 || o.InitialConnWindowSize < 0 {
+		return errors.New("the grpc initial window sizes must not be negative")
+	}
+	return nil
+}
+
+// ServerOptions returns the options of the gRPC servers.
+func (o GrpcOptions) ServerOptions() []grpc.ServerOption {
+	o = o.WithDefaults()
+	options := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    o.KeepAliveTime,
+			Timeout: o.KeepAliveTimeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             o.KeepAliveMinTime,
+			PermitWithoutStream: o.KeepAlivePermitWithoutStream,
+		}),
+		grpc.MaxRecvMsgSize(o.MaxMessageSize),
+		grpc.MaxSendMsgSize(o.MaxMessageSize),
+	}
+	if o.InitialWindowSize > 0 {
+		options = append(options, grpc.InitialWindowSize(o.InitialWindowSize))
+	}
+	if o.InitialConnWindowSize > 0 {
+		options = append(options, grpc.InitialConnWindowSize(o.InitialConnWindowSize))
+	}
+	return options
+}
+
+// DialOptions returns the options of the connections between the nodes.
+// gRPC doesn't let the clients ping more often than every 10 seconds.
+func (o GrpcOptions) DialOptions() []grpc.DialOption {
+	o = o.WithDefaults()
+	options := []grpc.DialOption{
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                o.KeepAliveTime,
+			Timeout:             o.KeepAliveTimeout,
+			PermitWithoutStream: o.KeepAlivePermitWithoutStream,
+		}),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(o.MaxMessageSize),
+			grpc.MaxCallSendMsgSize(o.MaxMessageSize),
+		),
+	}
+	if o.InitialWindowSize > 0 {
+		options = append(options, grpc.WithInitialWindowSize(o.InitialWindowSize))
+	}
+	if o.InitialConnWindowSize > 0 {
+		options = append(options, grpc.WithInitialConnWindowSize(o.InitialConnWindowSize))
+	}
+	return options
+}
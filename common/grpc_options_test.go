@@ -0,0 +1,44 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrpcOptions_WithDefaults(t *testing.T) {
+	assert.Equal(t, DefaultGrpcOptions(), GrpcOptions{}.WithDefaults())
+
+	// The fields that are set are kept
+	o := GrpcOptions{KeepAliveTime: time.Minute, InitialWindowSize: 1024}.WithDefaults()
+	assert.Equal(t, GrpcOptions{
+		KeepAliveTime:     time.Minute,
+		KeepAliveTimeout:  DefaultGrpcKeepAliveTimeout,
+		KeepAliveMinTime:  DefaultGrpcKeepAliveMinTime,
+		MaxMessageSize:    DefaultGrpcMaxMessageSize,
+		InitialWindowSize: 1024,
+	}, o)
+}
+
+func TestGrpcOptions_Validate(t *testing.T) {
+	assert.NoError(t, GrpcOptions{}.Validate())
+	assert.NoError(t, DefaultGrpcOptions().Validate())
+	assert.Error(t, GrpcOptions{KeepAliveTimeout: -time.Second}.Validate())
+	assert.Error(t, GrpcOptions{MaxMessageSize: -1}.Validate())
+	assert.Error(t, GrpcOptions{InitialConnWindowSize: -1}.Validate())
+}
@@ -16,6 +16,9 @@ package metrics
 
 import (
 	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel/metric"
 )
@@ -27,8 +30,11 @@ type Counter interface {
 }
 
 type counter struct {
-	sc    metric.Int64Counter
-	attrs metric.MeasurementOption
+	sc metric.Int64Counter
+
+	// The options are kept in a slice, so that they are not allocated again
+	// at each measurement
+	attrs []metric.AddOption
 }
 
 func (c *counter) Inc() {
@@ -36,7 +42,7 @@ func (c *counter) Inc() {
 }
 
 func (c *counter) Add(incr int) {
-	c.sc.Add(context.Background(), int64(incr), c.attrs)
+	c.sc.Add(context.Background(), int64(incr), c.attrs...)
 }
 
 func NewCounter(name string, description string, unit Unit, labels map[string]any) Counter {
@@ -46,10 +52,56 @@ func NewCounter(name string, description string, unit Unit, labels map[string]an
 	fatalOnErr(err, name)
 	return &counter{
 		sc:    sc,
-		attrs: getAttrs(labels),
+		attrs: []metric.AddOption{getAttrs(labels)},
 	}
 }
 
+// RemovableCounter is a counter that stops being reported once it's
+// unregistered, for the entities that can go away, like a shard.
+type RemovableCounter interface {
+	Counter
+	Unregister()
+}
+
+type removableCounter struct {
+	value        atomic.Int64
+	registration metric.Registration
+}
+
+func (c *removableCounter) Inc() {
+	c.Add(1)
+}
+
+func (c *removableCounter) Add(incr int) {
+	c.value.Add(int64(incr))
+}
+
+func (c *removableCounter) Unregister() {
+	if err := c.registration.Unregister(); err != nil {
+		slog.Error(
+			"Failed to unregister counter",
+			slog.Any("error", err),
+		)
+		os.Exit(1)
+	}
+}
+
+func NewRemovableCounter(name string, description string, unit Unit, labels map[string]any) RemovableCounter {
+	oc, err := meter.Int64ObservableCounter(name,
+		metric.WithUnit(string(unit)),
+		metric.WithDescription(description))
+	fatalOnErr(err, name)
+
+	res := &removableCounter{}
+	attrs := getAttrs(labels)
+	res.registration, err = meter.RegisterCallback(func(_ context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(oc, res.value.Load(), attrs)
+		return nil
+	}, oc)
+	fatalOnErr(err, name)
+	return res
+}
+
 // UpDownCounter is a counter that is incremented and decremented
 // to report the current state.
 type UpDownCounter interface {
@@ -60,7 +112,7 @@ type UpDownCounter interface {
 
 type upDownCounter struct {
 	sc    metric.Int64UpDownCounter
-	attrs metric.MeasurementOption
+	attrs []metric.AddOption
 }
 
 func (c *upDownCounter) Inc() {
@@ -68,7 +120,7 @@ func (c *upDownCounter) Inc() {
 }
 
 func (c *upDownCounter) Add(incr int) {
-	c.sc.Add(context.Background(), int64(incr), c.attrs)
+	c.sc.Add(context.Background(), int64(incr), c.attrs...)
 }
 
 func (c *upDownCounter) Dec() {
@@ -86,6 +138,6 @@ func NewUpDownCounter(name string, description string, unit Unit, labels map[str
 	fatalOnErr(err, name)
 	return &upDownCounter{
 		sc:    sc,
-		attrs: getAttrs(labels),
+		attrs: []metric.AddOption{getAttrs(labels)},
 	}
 }
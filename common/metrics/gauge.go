@@ -18,10 +18,17 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"sync"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel/metric"
 )
 
+var (
+	gaugesLock sync.Mutex
+	gauges     = map[*gauge]struct{}{}
+)
+
 type Gauge interface {
 	Unregister()
 }
@@ -31,9 +38,17 @@ type gauge struct {
 	attrs        metric.MeasurementOption
 	callback     func() int64
 	registration metric.Registration
+
+	// The last value returned by the callback
+	value     atomic.Int64
+	refreshed atomic.Bool
 }
 
 func (g *gauge) Unregister() {
+	gaugesLock.Lock()
+	delete(gauges, g)
+	gaugesLock.Unlock()
+
 	if err := g.registration.Unregister(); err != nil {
 		slog.Error(
 			"Failed to unregister gauge",
@@ -60,7 +75,9 @@ func NewGauge(name string, description string, unit Unit, labels map[string]any,
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		obs.ObserveInt64(res.gauge, res.callback(), res.attrs)
+		if res.refreshed.Load() {
+			obs.ObserveInt64(res.gauge, res.value.Load(), res.attrs)
+		}
 		return nil
 	}, g)
 
@@ -71,5 +88,30 @@ func NewGauge(name string, description string, unit Unit, labels map[string]any,
 		)
 		os.Exit(1)
 	}
+
+	gaugesLock.Lock()
+	gauges[res] = struct{}{}
+	gaugesLock.Unlock()
 	return res
 }
+
+// refreshGauges invokes the callbacks of all the gauges, right before the
+// metrics are collected.
+//
+// The callbacks are not invoked during the collection itself, because the
+// collection blocks the creation and the removal of any other metric. A
+// callback that waits on a lock, held by someone that is creating a metric,
+// would otherwise never complete.
+func refreshGauges() {
+	gaugesLock.Lock()
+	list := make([]*gauge, 0, len(gauges))
+	for g := range gauges {
+		list = append(list, g)
+	}
+	gaugesLock.Unlock()
+
+	for _, g := range list {
+		g.value.Store(g.callback())
+		g.refreshed.Store(true)
+	}
+}
@@ -36,11 +36,11 @@ type Histogram interface {
 
 type histogram struct {
 	h     metric.Int64Histogram
-	attrs metric.MeasurementOption
+	attrs []metric.RecordOption
 }
 
 func (t *histogram) Record(size int) {
-	t.h.Record(context.Background(), int64(size), t.attrs)
+	t.h.Record(context.Background(), int64(size), t.attrs...)
 }
 
 func NewCountHistogram(name string, description string, labels map[string]any) Histogram {
@@ -59,5 +59,5 @@ func newHistogram(name string, unit Unit, description string, labels map[string]
 	)
 	fatalOnErr(err, name)
 
-	return &histogram{h: h, attrs: getAttrs(labels)}
+	return &histogram{h: h, attrs: []metric.RecordOption{getAttrs(labels)}}
 }
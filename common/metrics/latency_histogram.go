@@ -31,7 +31,7 @@ type Timer struct {
 }
 
 func (tm Timer) Done() {
-	tm.histo.histo.Record(context.Background(), float64(time.Since(tm.start).Microseconds())/1000.0, tm.histo.attrs)
+	tm.histo.histo.Record(context.Background(), float64(time.Since(tm.start).Microseconds())/1000.0, tm.histo.attrs...)
 }
 
 type LatencyHistogram interface {
@@ -40,7 +40,7 @@ type LatencyHistogram interface {
 
 type latencyHistogram struct {
 	histo metric.Float64Histogram
-	attrs metric.MeasurementOption
+	attrs []metric.RecordOption
 }
 
 func (t *latencyHistogram) Timer() Timer {
@@ -55,5 +55,5 @@ func NewLatencyHistogram(name string, description string, labels map[string]any)
 	)
 	fatalOnErr(err, name)
 
-	return &latencyHistogram{histo: h, attrs: getAttrs(labels)}
+	return &latencyHistogram{histo: h, attrs: []metric.RecordOption{getAttrs(labels)}}
 }
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/sdk/metric"
@@ -33,7 +34,8 @@ import (
 )
 
 func init() {
-	exporter, err := prometheus.New()
+	exporter, err := prometheus.New(prometheus.WithRegisterer(
+		refreshingRegisterer{prometheusclient.DefaultRegisterer}))
 	if err != nil {
 		slog.Error(
 			"Failed to initialize Prometheus metrics exporter",
@@ -89,6 +91,7 @@ type PrometheusMetrics struct {
 	io.Closer
 
 	server *http.Server
+	mux    *http.ServeMux
 	port   int
 }
 
@@ -106,6 +109,7 @@ func Start(bindAddress string) (*PrometheusMetrics, error) {
 			Handler:           mux,
 			ReadHeaderTimeout: time.Second,
 		},
+		mux:  mux,
 		port: listener.Addr().(*net.TCPAddr).Port,
 	}
 
@@ -130,6 +134,12 @@ func Start(bindAddress string) (*PrometheusMetrics, error) {
 	return p, nil
 }
 
+// Handle registers an additional handler on the same http server that
+// serves the metrics.
+func (p *PrometheusMetrics) Handle(pattern string, handler http.Handler) {
+	p.mux.Handle(pattern, handler)
+}
+
 func (p *PrometheusMetrics) Port() int {
 	return p.port
 }
@@ -137,3 +147,30 @@ func (p *PrometheusMetrics) Port() int {
 func (p *PrometheusMetrics) Close() error {
 	return p.server.Close()
 }
+
+// refreshingRegisterer wraps the collector of the exporter, so that the
+// gauges are refreshed before every collection.
+type refreshingRegisterer struct {
+	prometheusclient.Registerer
+}
+
+func (r refreshingRegisterer) Register(c prometheusclient.Collector) error {
+	return r.Registerer.Register(refreshingCollector{c})
+}
+
+func (r refreshingRegisterer) MustRegister(cs ...prometheusclient.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+type refreshingCollector struct {
+	prometheusclient.Collector
+}
+
+func (c refreshingCollector) Collect(ch chan<- prometheusclient.Metric) {
+	refreshGauges()
+	c.Collector.Collect(ch)
+}
@@ -18,8 +18,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -53,3 +56,48 @@ func TestPrometheusMetrics(t *testing.T) {
 		defer response2.Body.Close()
 	}
 }
+
+func TestGaugeCallbackWaitingOnMetricCreation(t *testing.T) {
+	var lock sync.Mutex
+	collecting := make(chan struct{})
+	var once sync.Once
+
+	g := NewGauge("test_gauge_waiting", "", Dimensionless, nil, func() int64 {
+		once.Do(func() { close(collecting) })
+		lock.Lock()
+		defer lock.Unlock()
+		return 5
+	})
+	defer g.Unregister()
+
+	lock.Lock()
+	gathered := make(chan error)
+	go func() {
+		_, err := prometheus.DefaultGatherer.Gather()
+		gathered <- err
+	}()
+
+	// Create and remove a metric while the callback is waiting on the lock
+	<-collecting
+	other := NewGauge("test_gauge_other", "", Dimensionless, nil, func() int64 { return 1 })
+	other.Unregister()
+	lock.Unlock()
+
+	select {
+	case err := <-gathered:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the collection of the metrics did not complete")
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	found := false
+	for _, f := range families {
+		if f.GetName() == "test_gauge_waiting_ratio" {
+			found = true
+			assert.EqualValues(t, 5, f.GetMetric()[0].GetGauge().GetValue())
+		}
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	libtls "crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// reloadGeneration is incremented on every SIGHUP, to force all the
+	// reloaders to read the files again
+	reloadGeneration atomic.Int64
+	reloadSignalOnce sync.Once
+)
+
+func watchReloadSignal() {
+	reloadSignalOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGHUP)
+		go func() {
+			for range ch {
+				slog.Info("Received SIGHUP, reloading the TLS certificates")
+				reloadGeneration.Add(1)
+			}
+		}()
+	})
+}
+
+// certReloader keeps the certificate and the trusted CA of a TLS option in
+// memory, and reads them again from disk when the files are modified or
+// when the process receives a SIGHUP. This lets certificates be rotated
+// without restarting the process.
+type certReloader struct {
+	sync.Mutex
+	option *TLSOption
+
+	generation int64
+	modTimes   []time.Time
+	cert       *libtls.Certificate
+	caPool     *x509.CertPool
+}
+
+func newCertReloader(option *TLSOption) (*certReloader, error) {
+	watchReloadSignal()
+
+	r := &certReloader{option: option}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	var files []string
+	if r.option.CertFile != "" {
+		files = append(files, r.option.CertFile, r.option.KeyFile)
+	}
+	if r.option.TrustedCaFile != "" {
+		files = append(files, r.option.TrustedCaFile)
+	}
+	return files
+}
+
+// The modification times are compared, rather than the content, to keep
+// the cost of a handshake low.
+func (r *certReloader) currentModTimes() []time.Time {
+	files := r.files()
+	modTimes := make([]time.Time, len(files))
+	for i, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+func (r *certReloader) isStale() bool {
+	if r.generation != reloadGeneration.Load() {
+		return true
+	}
+	for i, modTime := range r.currentModTimes() {
+		if !modTime.Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// The files are not read again until they change, even if the reload
+// fails.
+func (r *certReloader) reload() error {
+	r.generation = reloadGeneration.Load()
+	r.modTimes = r.currentModTimes()
+
+	var cert *libtls.Certificate
+	if r.option.CertFile != "" {
+		keyPair, err := libtls.LoadX509KeyPair(r.option.CertFile, r.option.KeyFile)
+		if err != nil {
+			return err
+		}
+		cert = &keyPair
+	}
+
+	var err error
+	var caPool *x509.CertPool
+	if r.option.TrustedCaFile != "" {
+		if caPool, err = r.option.trustedCertPool(); err != nil {
+			return err
+		}
+	}
+
+	r.cert = cert
+	r.caPool = caPool
+	return nil
+}
+
+func (r *certReloader) get() (*libtls.Certificate, *x509.CertPool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.isStale() {
+		if err := r.reload(); err != nil {
+			// Keep serving with the previous certificate, since the files
+			// might be in the middle of being replaced
+			slog.Warn(
+				"Failed to reload the TLS certificates",
+				slog.String("cert-file", r.option.CertFile),
+				slog.Any("error", err),
+			)
+		} else {
+			slog.Info(
+				"Reloaded the TLS certificates",
+				slog.String("cert-file", r.option.CertFile),
+			)
+		}
+	}
+	return r.cert, r.caPool
+}
@@ -17,7 +17,6 @@ package security
 import (
 	libtls "crypto/tls"
 	"crypto/x509"
-	"encoding/pem"
 	"os"
 
 	"github.com/pkg/errors"
@@ -53,18 +52,20 @@ func (tls *TLSOption) IsConfigured() bool {
 	return tls.CertFile != ""
 }
 
-func (tls *TLSOption) makeCommonConfig() (*libtls.Config, error) {
-	if tls.CertFile == "" {
-		return nil, ErrInvalidTLSCertFile
+// makeCommonConfig validates the option and loads its files. The certificate
+// can be omitted by the clients, when the server doesn't require one.
+func (tls *TLSOption) makeCommonConfig(certRequired bool) (*libtls.Config, *certReloader, error) {
+	if tls.CertFile == "" && (certRequired || tls.KeyFile != "") {
+		return nil, nil, ErrInvalidTLSCertFile
 	}
-	if tls.KeyFile == "" {
-		return nil, ErrInvalidTLSKeyFile
+	if tls.KeyFile == "" && (certRequired || tls.CertFile != "") {
+		return nil, nil, ErrInvalidTLSKeyFile
 	}
 
 	// validate it first
-	_, err := libtls.LoadX509KeyPair(tls.CertFile, tls.KeyFile)
+	reloader, err := newCertReloader(tls)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var minVersion uint16 = libtls.VersionTLS12
@@ -83,57 +84,77 @@ func (tls *TLSOption) makeCommonConfig() (*libtls.Config, error) {
 	if len(tls.CipherSuites) > 0 {
 		tlsConf.CipherSuites = tls.CipherSuites
 	}
-	return &tlsConf, nil
+	return &tlsConf, reloader, nil
 }
 
 func (tls *TLSOption) trustedCertPool() (*x509.CertPool, error) {
-	certPool := x509.NewCertPool()
 	bPem, err := os.ReadFile(tls.TrustedCaFile)
 	if err != nil {
 		return nil, err
 	}
-	var block *pem.Block
-	block, _ = pem.Decode(bPem)
-	if block != nil {
-		cert, err := x509.ParseCertificate(block.Bytes)
-		if err != nil {
-			return nil, err
-		}
-		certPool.AddCert(cert)
+
+	// The file can contain multiple CAs, while one is being rotated
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(bPem) {
+		return nil, errors.Errorf("no valid certificate found in %s", tls.TrustedCaFile)
 	}
 	return certPool, nil
 }
 
+// MakeClientTLSConf creates the config to connect to a server. The client
+// certificate is only presented if the cert and key files are set.
 func (tls *TLSOption) MakeClientTLSConf() (*libtls.Config, error) {
-	tlsConf, err := tls.makeCommonConfig()
+	tlsConf, reloader, err := tls.makeCommonConfig(false)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(tls.TrustedCaFile) > 0 {
-		certPool, err := tls.trustedCertPool()
-		if err != nil {
-			return nil, err
-		}
+	if _, certPool := reloader.get(); certPool != nil {
 		tlsConf.RootCAs = certPool
+
+		if !tls.InsecureSkipVerify {
+			// The server certificate is verified against the latest trusted
+			// CA, rather than against the RootCAs captured in the config
+			// #nosec G402
+			tlsConf.InsecureSkipVerify = true
+			tlsConf.VerifyConnection = func(cs libtls.ConnectionState) error {
+				_, certPool := reloader.get()
+				return verifyServerCertificate(cs, certPool)
+			}
+		}
 	}
 
-	tlsConf.GetClientCertificate = func(_ *libtls.CertificateRequestInfo) (cert *libtls.Certificate, err error) {
-		c, err := libtls.LoadX509KeyPair(tls.CertFile, tls.KeyFile)
-		return &c, err
+	if tls.CertFile != "" {
+		tlsConf.GetClientCertificate = func(_ *libtls.CertificateRequestInfo) (*libtls.Certificate, error) {
+			cert, _ := reloader.get()
+			return cert, nil
+		}
 	}
 	return tlsConf, nil
 }
 
+func verifyServerCertificate(cs libtls.ConnectionState, certPool *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("the server did not present a certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         certPool,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
 func (tls *TLSOption) MakeServerTLSConf() (*libtls.Config, error) {
-	tlsConf, err := tls.makeCommonConfig()
+	tlsConf, reloader, err := tls.makeCommonConfig(true)
 	if err != nil {
 		return nil, err
 	}
-	tlsConf.GetCertificate = func(_ *libtls.ClientHelloInfo) (cert *libtls.Certificate, err error) {
-		c, err := libtls.LoadX509KeyPair(tls.CertFile, tls.KeyFile)
-		return &c, err
-	}
 
 	// auth type upgrading
 	authType := libtls.NoClientCert
@@ -145,12 +166,18 @@ func (tls *TLSOption) MakeServerTLSConf() (*libtls.Config, error) {
 	}
 	tlsConf.ClientAuth = authType
 
-	if len(tls.TrustedCaFile) > 0 {
-		certPool, err := tls.trustedCertPool()
-		if err != nil {
-			return nil, err
-		}
-		tlsConf.ClientCAs = certPool
+	cert, certPool := reloader.get()
+	tlsConf.Certificates = []libtls.Certificate{*cert}
+	tlsConf.ClientCAs = certPool
+
+	// Each handshake uses the latest certificate and trusted CA
+	baseConf := tlsConf.Clone()
+	tlsConf.GetConfigForClient = func(_ *libtls.ClientHelloInfo) (*libtls.Config, error) {
+		cert, certPool := reloader.get()
+		conf := baseConf.Clone()
+		conf.Certificates = []libtls.Certificate{*cert}
+		conf.ClientCAs = certPool
+		return conf, nil
 	}
 
 	return tlsConf, nil
@@ -0,0 +1,302 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	libtls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// Writes a certificate for localhost, signed by the CA, together with the
+// CA file, in the directory.
+func (ca *testCA) writeCert(t *testing.T, dir string, serial int64) *TLSOption {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	option := &TLSOption{
+		CertFile:      filepath.Join(dir, "tls.crt"),
+		KeyFile:       filepath.Join(dir, "tls.key"),
+		TrustedCaFile: filepath.Join(dir, "ca.crt"),
+	}
+	writeFile(t, option.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, option.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	writeFile(t, option.TrustedCaFile, ca.pem)
+	return option
+}
+
+var writtenFiles int
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	// Make sure the modification is detected, regardless of the
+	// resolution of the file system timestamps
+	writtenFiles++
+	modTime := time.Now().Add(time.Duration(writtenFiles) * time.Second)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// Runs a handshake and returns the serial of the server certificate.
+func handshake(t *testing.T, serverConf, clientConf *libtls.Config) (int64, error) {
+	t.Helper()
+
+	listener, err := libtls.Listen("tcp", "localhost:0", serverConf)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if err := conn.(*libtls.Conn).Handshake(); err == nil {
+			_, _ = conn.Write([]byte{1})
+		}
+	}()
+
+	conn, err := libtls.Dial("tcp", listener.Addr().String(), clientConf)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// With TLS 1.3 the client certificate is verified after the client
+	// has completed the handshake
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return 0, err
+	}
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestTLS_MutualAuth(t *testing.T) {
+	ca := newTestCA(t, "ca")
+
+	serverOption := ca.writeCert(t, t.TempDir(), 10)
+	serverOption.ClientAuth = true
+	serverConf, err := serverOption.MakeServerTLSConf()
+	require.NoError(t, err)
+
+	clientConf, err := ca.writeCert(t, t.TempDir(), 20).MakeClientTLSConf()
+	require.NoError(t, err)
+
+	serial, err := handshake(t, serverConf, clientConf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, serial)
+}
+
+func TestTLS_ServerAuthOnly(t *testing.T) {
+	ca := newTestCA(t, "ca")
+
+	serverOption := ca.writeCert(t, t.TempDir(), 10)
+	serverConf, err := serverOption.MakeServerTLSConf()
+	require.NoError(t, err)
+
+	// The client only verifies the server, without a certificate of its own
+	clientConf, err := (&TLSOption{TrustedCaFile: serverOption.TrustedCaFile}).MakeClientTLSConf()
+	require.NoError(t, err)
+
+	serial, err := handshake(t, serverConf, clientConf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, serial)
+
+	serverOption.ClientAuth = true
+	serverConf, err = serverOption.MakeServerTLSConf()
+	require.NoError(t, err)
+	_, err = handshake(t, serverConf, clientConf)
+	assert.Error(t, err)
+
+	_, err = (&TLSOption{KeyFile: serverOption.KeyFile}).MakeClientTLSConf()
+	assert.ErrorIs(t, err, ErrInvalidTLSCertFile)
+	_, err = (&TLSOption{TrustedCaFile: serverOption.TrustedCaFile}).MakeServerTLSConf()
+	assert.ErrorIs(t, err, ErrInvalidTLSCertFile)
+}
+
+func TestTLS_WrongCA(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	otherCa := newTestCA(t, "other-ca")
+
+	serverOption := ca.writeCert(t, t.TempDir(), 10)
+	serverOption.ClientAuth = true
+	serverConf, err := serverOption.MakeServerTLSConf()
+	require.NoError(t, err)
+
+	// The client does not trust the server certificate
+	clientOption := ca.writeCert(t, t.TempDir(), 20)
+	writeFile(t, clientOption.TrustedCaFile, otherCa.pem)
+	clientConf, err := clientOption.MakeClientTLSConf()
+	require.NoError(t, err)
+
+	_, err = handshake(t, serverConf, clientConf)
+	assert.Error(t, err)
+
+	// The server does not trust the client certificate
+	clientOption = otherCa.writeCert(t, t.TempDir(), 20)
+	writeFile(t, clientOption.TrustedCaFile, ca.pem)
+	clientConf, err = clientOption.MakeClientTLSConf()
+	require.NoError(t, err)
+
+	_, err = handshake(t, serverConf, clientConf)
+	assert.Error(t, err)
+}
+
+func TestTLS_WrongServerName(t *testing.T) {
+	ca := newTestCA(t, "ca")
+
+	serverConf, err := ca.writeCert(t, t.TempDir(), 10).MakeServerTLSConf()
+	require.NoError(t, err)
+
+	clientOption := ca.writeCert(t, t.TempDir(), 20)
+	clientOption.ServerName = "oxia-0.oxia-svc.default.svc.cluster.local"
+	clientConf, err := clientOption.MakeClientTLSConf()
+	require.NoError(t, err)
+
+	_, err = handshake(t, serverConf, clientConf)
+	assert.Error(t, err)
+}
+
+func TestTLS_ReloadOnFileChange(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	newCa := newTestCA(t, "new-ca")
+
+	serverDir := t.TempDir()
+	serverOption := ca.writeCert(t, serverDir, 10)
+	serverOption.ClientAuth = true
+	serverConf, err := serverOption.MakeServerTLSConf()
+	require.NoError(t, err)
+
+	clientDir := t.TempDir()
+	clientConf, err := ca.writeCert(t, clientDir, 20).MakeClientTLSConf()
+	require.NoError(t, err)
+
+	serial, err := handshake(t, serverConf, clientConf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, serial)
+
+	// Rotate the server certificate
+	ca.writeCert(t, serverDir, 11)
+	serial, err = handshake(t, serverConf, clientConf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 11, serial)
+
+	// Rotate the CA on both sides
+	newCa.writeCert(t, serverDir, 12)
+	_, err = handshake(t, serverConf, clientConf)
+	assert.Error(t, err)
+
+	newCa.writeCert(t, clientDir, 21)
+	serial, err = handshake(t, serverConf, clientConf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12, serial)
+}
+
+func TestTLS_ReloadOnSignal(t *testing.T) {
+	ca := newTestCA(t, "ca")
+
+	serverDir := t.TempDir()
+	serverConf, err := ca.writeCert(t, serverDir, 10).MakeServerTLSConf()
+	require.NoError(t, err)
+
+	clientConf, err := ca.writeCert(t, t.TempDir(), 20).MakeClientTLSConf()
+	require.NoError(t, err)
+
+	// Rotate the certificate, keeping the same modification times
+	files := []string{"tls.crt", "tls.key", "ca.crt"}
+	modTimes := make([]time.Time, len(files))
+	for i, file := range files {
+		info, err := os.Stat(filepath.Join(serverDir, file))
+		require.NoError(t, err)
+		modTimes[i] = info.ModTime()
+	}
+	ca.writeCert(t, serverDir, 11)
+	for i, file := range files {
+		require.NoError(t, os.Chtimes(filepath.Join(serverDir, file), modTimes[i], modTimes[i]))
+	}
+
+	serial, err := handshake(t, serverConf, clientConf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, serial)
+
+	reloadGeneration.Add(1)
+	serial, err = handshake(t, serverConf, clientConf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 11, serial)
+}
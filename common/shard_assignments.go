@@ -0,0 +1,87 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	pb "google.golang.org/protobuf/proto"
+
+	"github.com/streamnative/oxia/proto"
+)
+
+// NextShardAssignments returns the response for a client that is watching
+// the shard assignments of a namespace, given the namespace assignments
+// that were last sent to the client, if any.
+//
+// The first response, and all the responses to the clients that don't
+// accept deltas, contain all the assignments of the namespace. The
+// following ones only contain the assignments that have changed and the
+// shards that were removed, and nil is returned when there is no change
+// for the namespace.
+func NextShardAssignments(last *proto.NamespaceShardsAssignment, assignments *proto.ShardAssignments,
+	namespace string, acceptDeltas bool) *proto.ShardAssignments {
+	current, ok := assignments.Namespaces[namespace]
+	if !ok || last == nil || !acceptDeltas {
+		res := &proto.ShardAssignments{
+			Namespaces: map[string]*proto.NamespaceShardsAssignment{},
+		}
+		if ok {
+			res.Namespaces[namespace] = current
+		}
+		return res
+	}
+
+	delta := shardAssignmentsDelta(last, current)
+	if delta == nil {
+		return nil
+	}
+
+	return &proto.ShardAssignments{
+		Namespaces: map[string]*proto.NamespaceShardsAssignment{
+			namespace: delta,
+		},
+		Delta: true,
+	}
+}
+
+func shardAssignmentsDelta(last *proto.NamespaceShardsAssignment, current *proto.NamespaceShardsAssignment) *proto.NamespaceShardsAssignment {
+	lastAssignments := make(map[int64]*proto.ShardAssignment, len(last.Assignments))
+	for _, a := range last.Assignments {
+		lastAssignments[a.Shard] = a
+	}
+
+	delta := &proto.NamespaceShardsAssignment{
+		Assignments:    make([]*proto.ShardAssignment, 0),
+		ShardKeyRouter: current.ShardKeyRouter,
+	}
+	currentShards := make(map[int64]bool, len(current.Assignments))
+	for _, a := range current.Assignments {
+		currentShards[a.Shard] = true
+		if !pb.Equal(a, lastAssignments[a.Shard]) {
+			delta.Assignments = append(delta.Assignments, a)
+		}
+	}
+
+	for _, a := range last.Assignments {
+		if !currentShards[a.Shard] {
+			delta.RemovedShards = append(delta.RemovedShards, a.Shard)
+		}
+	}
+
+	if len(delta.Assignments) == 0 && len(delta.RemovedShards) == 0 &&
+		current.ShardKeyRouter == last.ShardKeyRouter {
+		return nil
+	}
+	return delta
+}
@@ -28,7 +28,8 @@ type WaitGroup interface {
 	// Done Signals that one party in the group is done
 	Done()
 
-	// Fail Signal that one party has failed in the operation
+	// Fail Signal that one party has failed in the operation. It never blocks:
+	// once all the parties have reported, Wait has already returned
 	Fail(err error)
 }
 
@@ -69,5 +70,8 @@ func (g *waitGroup) Done() {
 }
 
 func (g *waitGroup) Fail(err error) {
-	g.responses <- err
+	select {
+	case g.responses <- err:
+	default:
+	}
 }
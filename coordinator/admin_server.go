@@ -0,0 +1,327 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/metrics"
+	"github.com/streamnative/oxia/coordinator/impl"
+)
+
+const (
+	adminOpListShards      = "list-shards"
+	adminOpListServers     = "list-servers"
+	adminOpTriggerElection = "trigger-election"
+	adminOpTransferLeader  = "transfer-leader"
+	adminOpDrainServer     = "drain-server"
+	adminOpCompactShard    = "compact-shard"
+)
+
+type TransferLeaderRequest struct {
+	// NewLeader is the internal address of the new leader
+	NewLeader string `json:"newLeader"`
+}
+
+type DrainServerRequest struct {
+	// RemoveReplicas moves the replicas of the server to other servers,
+	// after its leaderships
+	RemoveReplicas bool `json:"removeReplicas"`
+}
+
+// DrainEvent is a line of the response to a drain request. The last line has
+// Done set, along with the error that stopped the drain, if any.
+type DrainEvent struct {
+	Progress *impl.DrainProgress `json:"progress,omitempty"`
+	Done     bool                `json:"done,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// adminServer serves the operations that let the operators act on the
+// shards. It listens on its own address, so that it's not exposed along
+// with the services used by the clients and the storage nodes.
+type adminServer struct {
+	coordinator impl.Coordinator
+	server      *http.Server
+	port        int
+	log         *slog.Logger
+
+	// The operations, keyed by name and by whether they have failed
+	operations map[string]map[bool]metrics.Counter
+}
+
+func newAdminServer(bindAddress string, coordinator impl.Coordinator) (*adminServer, error) {
+	listener, err := net.Listen("tcp", bindAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &adminServer{
+		coordinator: coordinator,
+		port:        listener.Addr().(*net.TCPAddr).Port,
+		log: slog.With(
+			slog.String("component", "coordinator-admin-server"),
+		),
+		operations: make(map[string]map[bool]metrics.Counter),
+	}
+
+	for _, op := range []string{adminOpListShards, adminOpListServers, adminOpTriggerElection, adminOpTransferLeader, adminOpDrainServer, adminOpCompactShard} {
+		s.operations[op] = make(map[bool]metrics.Counter)
+		for _, failed := range []bool{false, true} {
+			s.operations[op][failed] = metrics.NewCounter("oxia_coordinator_admin_operations",
+				"The number of operations requested through the admin service", "count",
+				map[string]any{"operation": op, "failed": failed})
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/shards", s.listShards)
+	mux.HandleFunc("GET /admin/servers", s.listServers)
+	mux.HandleFunc("POST /admin/shards/{shard}/election", s.triggerElection)
+	mux.HandleFunc("POST /admin/shards/{shard}/leader", s.transferLeader)
+	mux.HandleFunc("POST /admin/servers/{server}/drain", s.drainServer)
+	mux.HandleFunc("POST /admin/shards/{shard}/compact", s.compactShard)
+
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second,
+	}
+
+	s.log.Info(
+		"Serving the coordinator admin service",
+		slog.String("bind-address", listener.Addr().String()),
+	)
+
+	go common.DoWithLabels(
+		context.Background(),
+		map[string]string{
+			"oxia": "coordinator-admin-server",
+		},
+		func() {
+			if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error(
+					"Failed to serve the admin service",
+					slog.Any("error", err),
+				)
+			}
+		},
+	)
+
+	return s, nil
+}
+
+func (s *adminServer) listShards(w http.ResponseWriter, _ *http.Request) {
+	s.operations[adminOpListShards][false].Inc()
+	s.writeJSON(w, s.coordinator.ListShards())
+}
+
+func (s *adminServer) listServers(w http.ResponseWriter, _ *http.Request) {
+	s.operations[adminOpListServers][false].Inc()
+	s.writeJSON(w, s.coordinator.ListServers())
+}
+
+func (s *adminServer) triggerElection(w http.ResponseWriter, r *http.Request) {
+	shard, err := strconv.ParseInt(r.PathValue("shard"), 10, 64)
+	if err != nil {
+		s.writeError(w, adminOpTriggerElection, errors.Wrap(err, "invalid shard"), http.StatusBadRequest)
+		return
+	}
+
+	s.log.Info(
+		"Received trigger election request",
+		slog.Int64("shard", shard),
+		slog.String("remote-address", r.RemoteAddr),
+	)
+
+	if err := s.coordinator.TriggerElection(shard); err != nil {
+		s.writeError(w, adminOpTriggerElection, err, statusCode(err))
+		return
+	}
+
+	s.operations[adminOpTriggerElection][false].Inc()
+	s.writeShard(w, shard)
+}
+
+func (s *adminServer) transferLeader(w http.ResponseWriter, r *http.Request) {
+	shard, err := strconv.ParseInt(r.PathValue("shard"), 10, 64)
+	if err != nil {
+		s.writeError(w, adminOpTransferLeader, errors.Wrap(err, "invalid shard"), http.StatusBadRequest)
+		return
+	}
+
+	req := TransferLeaderRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.NewLeader == "" {
+		s.writeError(w, adminOpTransferLeader, errors.New("the new leader must be set"), http.StatusBadRequest)
+		return
+	}
+
+	s.log.Info(
+		"Received transfer leader request",
+		slog.Int64("shard", shard),
+		slog.String("new-leader", req.NewLeader),
+		slog.String("remote-address", r.RemoteAddr),
+	)
+
+	if err := s.coordinator.TransferLeader(shard, req.NewLeader); err != nil {
+		s.writeError(w, adminOpTransferLeader, err, statusCode(err))
+		return
+	}
+
+	s.operations[adminOpTransferLeader][false].Inc()
+	s.writeShard(w, shard)
+}
+
+// compactShard responds with the outcome of the compaction on each member of
+// the ensemble. The members that are not compacted yet are skipped when the
+// caller goes away.
+func (s *adminServer) compactShard(w http.ResponseWriter, r *http.Request) {
+	shard, err := strconv.ParseInt(r.PathValue("shard"), 10, 64)
+	if err != nil {
+		s.writeError(w, adminOpCompactShard, errors.Wrap(err, "invalid shard"), http.StatusBadRequest)
+		return
+	}
+
+	s.log.Info(
+		"Received compact shard request",
+		slog.Int64("shard", shard),
+		slog.String("remote-address", r.RemoteAddr),
+	)
+
+	res, err := s.coordinator.CompactShard(r.Context(), shard)
+	if err != nil {
+		s.writeError(w, adminOpCompactShard, err, statusCode(err))
+		return
+	}
+
+	s.operations[adminOpCompactShard][false].Inc()
+	s.writeJSON(w, res)
+}
+
+// drainServer streams the progress of the drain, one JSON object per line, so
+// that the caller can follow the shards that are left. The drain is stopped
+// when the caller goes away.
+func (s *adminServer) drainServer(w http.ResponseWriter, r *http.Request) {
+	server := r.PathValue("server")
+
+	req := DrainServerRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.writeError(w, adminOpDrainServer, errors.Wrap(err, "invalid request"), http.StatusBadRequest)
+		return
+	}
+
+	s.log.Info(
+		"Received drain server request",
+		slog.String("server", server),
+		slog.Bool("remove-replicas", req.RemoveReplicas),
+		slog.String("remote-address", r.RemoteAddr),
+	)
+
+	// The status code is only sent along with the first event, so that
+	// the errors that prevent the drain from starting get their own
+	started := false
+	encoder := json.NewEncoder(w)
+	rc := http.NewResponseController(w)
+	writeEvent := func(event DrainEvent) {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+		if err := encoder.Encode(event); err == nil {
+			_ = rc.Flush()
+		}
+	}
+
+	err := s.coordinator.DrainServer(r.Context(), server, req.RemoveReplicas, func(p impl.DrainProgress) {
+		writeEvent(DrainEvent{Progress: &p})
+	})
+	if err != nil && !started {
+		s.writeError(w, adminOpDrainServer, err, statusCode(err))
+		return
+	}
+
+	if err != nil {
+		s.operations[adminOpDrainServer][true].Inc()
+		s.log.Warn(
+			"Admin operation failed",
+			slog.String("operation", adminOpDrainServer),
+			slog.Any("error", err),
+		)
+		writeEvent(DrainEvent{Done: true, Error: err.Error()})
+		return
+	}
+
+	s.operations[adminOpDrainServer][false].Inc()
+	writeEvent(DrainEvent{Done: true})
+}
+
+// writeShard responds with the status of the shard after the operation.
+func (s *adminServer) writeShard(w http.ResponseWriter, shard int64) {
+	for _, info := range s.coordinator.ListShards() {
+		if info.Shard == shard {
+			s.writeJSON(w, info)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *adminServer) writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		s.log.Warn(
+			"Failed to write the response",
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (s *adminServer) writeError(w http.ResponseWriter, op string, err error, code int) {
+	s.operations[op][true].Inc()
+	s.log.Warn(
+		"Admin operation failed",
+		slog.String("operation", op),
+		slog.Any("error", err),
+	)
+	http.Error(w, err.Error(), code)
+}
+
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, impl.ErrShardNotFound), errors.Is(err, impl.ErrServerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, impl.ErrShardNotAvailable), errors.Is(err, impl.ErrNodeNotInSync):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *adminServer) Port() int {
+	return s.port
+}
+
+func (s *adminServer) Close() error {
+	return s.server.Close()
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/impl"
+	"github.com/streamnative/oxia/coordinator/model"
+)
+
+var (
+	adminS1 = model.ServerAddress{Public: "s1:6648", Internal: "s1:6649"}
+	adminS2 = model.ServerAddress{Public: "s2:6648", Internal: "s2:6649"}
+	adminS3 = model.ServerAddress{Public: "s3:6648", Internal: "s3:6649"}
+)
+
+// testAdminCoordinator is a coordinator of a fake cluster with a single
+// shard, whose third member is not in sync.
+type testAdminCoordinator struct {
+	impl.Coordinator
+	sync.Mutex
+
+	shard impl.ShardInfo
+}
+
+func newTestAdminCoordinator() *testAdminCoordinator {
+	return &testAdminCoordinator{
+		shard: impl.ShardInfo{
+			Namespace: common.DefaultNamespace,
+			Shard:     0,
+			Status:    model.ShardStatusSteadyState.String(),
+			Term:      1,
+			Leader:    &adminS1,
+			Ensemble: []impl.ShardMemberInfo{
+				{ServerAddress: adminS1, InSync: true},
+				{ServerAddress: adminS2, InSync: true},
+				{ServerAddress: adminS3, InSync: false},
+			},
+		},
+	}
+}
+
+func (c *testAdminCoordinator) ListShards() []impl.ShardInfo {
+	c.Lock()
+	defer c.Unlock()
+	return []impl.ShardInfo{c.shard}
+}
+
+func (*testAdminCoordinator) ListServers() []impl.ServerInfo {
+	return []impl.ServerInfo{
+		{ServerAddress: adminS1, Status: impl.Running.String()},
+		{ServerAddress: adminS2, Status: impl.Running.String()},
+		{ServerAddress: adminS3, Status: impl.NotRunning.String()},
+	}
+}
+
+func (c *testAdminCoordinator) TriggerElection(shard int64) error {
+	c.Lock()
+	defer c.Unlock()
+	if shard != c.shard.Shard {
+		return impl.ErrShardNotFound
+	}
+	c.shard.Term++
+	return nil
+}
+
+func (c *testAdminCoordinator) TransferLeader(shard int64, newLeader string) error {
+	c.Lock()
+	defer c.Unlock()
+	if shard != c.shard.Shard {
+		return impl.ErrShardNotFound
+	}
+	for _, member := range c.shard.Ensemble {
+		if member.Internal == newLeader && member.InSync {
+			leader := member.ServerAddress
+			c.shard.Leader = &leader
+			c.shard.Term++
+			return nil
+		}
+	}
+	return errors.Wrapf(impl.ErrNodeNotInSync, "node %s", newLeader)
+}
+
+// DrainServer moves the leadership of the shard away from the server. The
+// replica on the third member can't be moved.
+func (c *testAdminCoordinator) DrainServer(_ context.Context, server string, removeReplicas bool,
+	progress func(impl.DrainProgress)) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if server != adminS1.Internal && server != adminS3.Internal {
+		return errors.Wrapf(impl.ErrServerNotFound, "server %s", server)
+	}
+
+	if c.shard.Leader.Internal == server {
+		c.shard.Leader = &adminS2
+		c.shard.Term++
+		progress(impl.DrainProgress{Shard: 0, Action: impl.DrainActionTransferLeader, To: &adminS2})
+	}
+
+	if removeReplicas {
+		progress(impl.DrainProgress{Shard: 0, Action: impl.DrainActionMoveReplica, Error: impl.ErrNoEligibleServer.Error()})
+		return errors.New("failed to drain 1 shards")
+	}
+	return nil
+}
+
+func (c *testAdminCoordinator) CompactShard(_ context.Context, shard int64) ([]impl.ShardCompaction, error) {
+	c.Lock()
+	defer c.Unlock()
+	if shard != c.shard.Shard {
+		return nil, impl.ErrShardNotFound
+	}
+
+	var res []impl.ShardCompaction
+	for _, member := range c.shard.Ensemble {
+		if member.InSync {
+			res = append(res, impl.ShardCompaction{ServerAddress: member.ServerAddress, ReclaimedBytes: 1024})
+		} else {
+			res = append(res, impl.ShardCompaction{ServerAddress: member.ServerAddress, Error: "server is not running"})
+		}
+	}
+	return res, nil
+}
+
+func TestAdminServer(t *testing.T) {
+	coordinator := newTestAdminCoordinator()
+	server, err := newAdminServer("localhost:0", coordinator)
+	assert.NoError(t, err)
+
+	url := fmt.Sprintf("http://localhost:%d/admin/shards", server.Port())
+	failedTransfers := gatherAdminOperations(t, adminOpTransferLeader, true)
+
+	// List the shards
+	var shards []impl.ShardInfo
+	assert.Equal(t, http.StatusOK, doAdminRequest(t, http.MethodGet, url, nil, &shards))
+	assert.Equal(t, []impl.ShardInfo{coordinator.shard}, shards)
+	assert.Equal(t, http.StatusMethodNotAllowed, doAdminRequest(t, http.MethodDelete, url, nil, nil))
+
+	// List the servers
+	var servers []impl.ServerInfo
+	assert.Equal(t, http.StatusOK, doAdminRequest(t, http.MethodGet,
+		fmt.Sprintf("http://localhost:%d/admin/servers", server.Port()), nil, &servers))
+	assert.Equal(t, coordinator.ListServers(), servers)
+
+	// Trigger an election
+	var shard impl.ShardInfo
+	assert.Equal(t, http.StatusOK, doAdminRequest(t, http.MethodPost, url+"/0/election", nil, &shard))
+	assert.EqualValues(t, 2, shard.Term)
+	assert.Equal(t, http.StatusNotFound, doAdminRequest(t, http.MethodPost, url+"/1/election", nil, nil))
+	assert.Equal(t, http.StatusBadRequest, doAdminRequest(t, http.MethodPost, url+"/invalid/election", nil, nil))
+
+	// Transfer the leadership
+	assert.Equal(t, http.StatusOK, doAdminRequest(t, http.MethodPost, url+"/0/leader",
+		TransferLeaderRequest{NewLeader: adminS2.Internal}, &shard))
+	assert.Equal(t, adminS2, *shard.Leader)
+	assert.EqualValues(t, 3, shard.Term)
+
+	assert.Equal(t, http.StatusConflict, doAdminRequest(t, http.MethodPost, url+"/0/leader",
+		TransferLeaderRequest{NewLeader: adminS3.Internal}, nil))
+	assert.Equal(t, http.StatusNotFound, doAdminRequest(t, http.MethodPost, url+"/1/leader",
+		TransferLeaderRequest{NewLeader: adminS2.Internal}, nil))
+	assert.Equal(t, http.StatusBadRequest, doAdminRequest(t, http.MethodPost, url+"/0/leader",
+		TransferLeaderRequest{}, nil))
+	assert.Equal(t, adminS2, *coordinator.ListShards()[0].Leader)
+
+	assert.EqualValues(t, failedTransfers+3, gatherAdminOperations(t, adminOpTransferLeader, true))
+
+	// Compact the shard
+	var compactions []impl.ShardCompaction
+	assert.Equal(t, http.StatusOK, doAdminRequest(t, http.MethodPost, url+"/0/compact", nil, &compactions))
+	assert.Equal(t, []impl.ShardCompaction{
+		{ServerAddress: adminS1, ReclaimedBytes: 1024},
+		{ServerAddress: adminS2, ReclaimedBytes: 1024},
+		{ServerAddress: adminS3, Error: "server is not running"},
+	}, compactions)
+	assert.Equal(t, http.StatusNotFound, doAdminRequest(t, http.MethodPost, url+"/1/compact", nil, nil))
+	assert.Equal(t, http.StatusBadRequest, doAdminRequest(t, http.MethodPost, url+"/invalid/compact", nil, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, doAdminRequest(t, http.MethodGet, url+"/0/compact", nil, nil))
+
+	assert.NoError(t, server.Close())
+}
+
+func TestAdminServer_DrainServer(t *testing.T) {
+	coordinator := newTestAdminCoordinator()
+	server, err := newAdminServer("localhost:0", coordinator)
+	assert.NoError(t, err)
+
+	url := fmt.Sprintf("http://localhost:%d/admin/servers", server.Port())
+	failedDrains := gatherAdminOperations(t, adminOpDrainServer, true)
+
+	// The leadership is moved away
+	events := doDrainRequest(t, url+"/"+adminS1.Internal+"/drain", DrainServerRequest{})
+	assert.Equal(t, []DrainEvent{
+		{Progress: &impl.DrainProgress{Shard: 0, Action: impl.DrainActionTransferLeader, To: &adminS2}},
+		{Done: true},
+	}, events)
+	assert.Equal(t, adminS2, *coordinator.ListShards()[0].Leader)
+
+	// The failures of the single shards are reported in the stream
+	events = doDrainRequest(t, url+"/"+adminS3.Internal+"/drain", DrainServerRequest{RemoveReplicas: true})
+	assert.Equal(t, []DrainEvent{
+		{Progress: &impl.DrainProgress{Shard: 0, Action: impl.DrainActionMoveReplica, Error: impl.ErrNoEligibleServer.Error()}},
+		{Done: true, Error: "failed to drain 1 shards"},
+	}, events)
+
+	assert.Equal(t, http.StatusNotFound, doAdminRequest(t, http.MethodPost, url+"/unknown:6649/drain", nil, nil))
+	assert.Equal(t, http.StatusBadRequest, doAdminRequest(t, http.MethodPost, url+"/"+adminS1.Internal+"/drain", "invalid", nil))
+
+	assert.EqualValues(t, failedDrains+3, gatherAdminOperations(t, adminOpDrainServer, true))
+
+	assert.NoError(t, server.Close())
+}
+
+func doDrainRequest(t *testing.T, url string, body DrainServerRequest) []DrainEvent {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	assert.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+
+	req, err := http.NewRequest(http.MethodPost, url, &reqBody)
+	assert.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+	var events []DrainEvent
+	decoder := json.NewDecoder(res.Body)
+	for decoder.More() {
+		event := DrainEvent{}
+		assert.NoError(t, decoder.Decode(&event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func doAdminRequest(t *testing.T, method string, url string, body any, response any) int {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		assert.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+
+	req, err := http.NewRequest(method, url, &reqBody)
+	assert.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	if response != nil && res.StatusCode == http.StatusOK {
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(response))
+	}
+	return res.StatusCode
+}
+
+func gatherAdminOperations(t *testing.T, operation string, failed bool) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "oxia_coordinator_admin_operations_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["operation"] == operation && labels["failed"] == fmt.Sprint(failed) {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
@@ -18,11 +18,14 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 
 	"go.uber.org/multierr"
+	"k8s.io/client-go/rest"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/debug"
 	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/coordinator/impl"
 	"github.com/streamnative/oxia/coordinator/model"
@@ -34,12 +37,21 @@ type Config struct {
 	PeerTLS                          *tls.Config
 	ServerTLS                        *tls.Config
 	MetricsServiceAddr               string
+	DebugServiceAddr                 string
+	AdminServiceAddr                 string
 	MetadataProviderImpl             MetadataProviderImpl
 	K8SMetadataNamespace             string
 	K8SMetadataConfigMapName         string
+	K8SClusterName                   string
+	K8SServersService                string
 	FileMetadataPath                 string
 	ClusterConfigProvider            func() (model.ClusterConfig, error)
 	ClusterConfigChangeNotifications chan any
+	FailureDetector                  impl.FailureDetectorOptions
+	Rebalance                        impl.RebalanceOptions
+	// Grpc are the keepalive and transport settings of the gRPC server and
+	// of the connections to the servers
+	Grpc common.GrpcOptions
 }
 
 type MetadataProviderImpl string
@@ -72,15 +84,33 @@ func NewConfig() Config {
 	return Config{
 		InternalServiceAddr:  fmt.Sprintf("localhost:%d", common.DefaultInternalPort),
 		MetricsServiceAddr:   fmt.Sprintf("localhost:%d", common.DefaultMetricsPort),
+		AdminServiceAddr:     fmt.Sprintf("localhost:%d", common.DefaultAdminPort),
 		MetadataProviderImpl: File,
+		FailureDetector: impl.FailureDetectorOptions{
+			ProbeInterval:    impl.DefaultProbeInterval,
+			ProbeTimeout:     impl.DefaultProbeTimeout,
+			FailureThreshold: impl.DefaultFailureThreshold,
+			ElectionBackoff:  impl.DefaultElectionBackoff,
+		},
+		Rebalance: impl.RebalanceOptions{
+			MaxConcurrentMoves: impl.DefaultMaxConcurrentMoves,
+		},
 	}
 }
 
 type Coordinator struct {
 	coordinator impl.Coordinator
 	clientPool  common.ClientPool
+	// statusWriter reports the status of the shards in the OxiaCluster
+	// resource, when running in Kubernetes
+	statusWriter io.Closer
+	// membership discovers the servers, when they are not listed in the
+	// cluster config
+	membership  impl.ServerMembership
 	rpcServer   *rpcServer
+	adminServer *adminServer
 	metrics     *metrics.PrometheusMetrics
+	debug       *debug.Server
 }
 
 func New(config Config) (*Coordinator, error) {
@@ -90,41 +120,92 @@ func New(config Config) (*Coordinator, error) {
 	)
 
 	s := &Coordinator{
-		clientPool: common.NewClientPool(config.PeerTLS, nil),
+		clientPool: common.NewPeerClientPool(config.PeerTLS, config.Grpc),
+	}
+
+	if err := config.Grpc.Validate(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if config.DebugServiceAddr != "" {
+		if s.debug, err = debug.Start(config.DebugServiceAddr); err != nil {
+			return nil, err
+		}
 	}
 
 	var metadataProvider impl.MetadataProvider
+	var k8sConfig *rest.Config
 	switch config.MetadataProviderImpl {
 	case Memory:
 		metadataProvider = impl.NewMetadataProviderMemory()
 	case File:
 		metadataProvider = impl.NewMetadataProviderFile(config.FileMetadataPath)
 	case Configmap:
-		k8sConfig := impl.NewK8SClientConfig()
+		k8sConfig = impl.NewK8SClientConfig()
 		metadataProvider = impl.NewMetadataProviderConfigMap(impl.NewK8SClientset(k8sConfig),
 			config.K8SMetadataNamespace, config.K8SMetadataConfigMapName)
 	}
 
 	rpcClient := impl.NewRpcProvider(s.clientPool)
 
-	var err error
-	if s.coordinator, err = impl.NewCoordinator(metadataProvider, config.ClusterConfigProvider, config.ClusterConfigChangeNotifications, rpcClient); err != nil {
+	clusterConfigProvider := config.ClusterConfigProvider
+	if config.K8SServersService != "" {
+		if k8sConfig == nil {
+			k8sConfig = impl.NewK8SClientConfig()
+		}
+		if s.membership, err = impl.NewK8SServerMembership(impl.NewK8SClientset(k8sConfig), config.K8SMetadataNamespace,
+			config.K8SServersService, impl.DefaultMembershipDebounce, config.ClusterConfigChangeNotifications); err != nil {
+			return nil, err
+		}
+		clusterConfigProvider = impl.MembershipClusterConfigProvider(clusterConfigProvider, s.membership, metadataProvider)
+	}
+
+	if s.coordinator, err = impl.NewCoordinator(metadataProvider, clusterConfigProvider, config.ClusterConfigChangeNotifications, rpcClient, config.FailureDetector, config.Rebalance); err != nil {
 		return nil, err
 	}
 
-	if s.rpcServer, err = newRpcServer(config.InternalServiceAddr, config.ServerTLS); err != nil {
+	if k8sConfig != nil && config.K8SClusterName != "" {
+		s.statusWriter = impl.NewClusterStatusWriter(impl.NewK8SDynamicClient(k8sConfig),
+			config.K8SMetadataNamespace, config.K8SClusterName, s.coordinator)
+	}
+
+	if s.rpcServer, err = newRpcServer(config.InternalServiceAddr, config.ServerTLS, config.Grpc, s.coordinator); err != nil {
 		return nil, err
 	}
 
+	if config.AdminServiceAddr != "" {
+		if s.adminServer, err = newAdminServer(config.AdminServiceAddr, s.coordinator); err != nil {
+			return nil, err
+		}
+	}
+
 	if s.metrics, err = metrics.Start(config.MetricsServiceAddr); err != nil {
 		return nil, err
 	}
 
+	s.metrics.Handle("/admin/rebalance", newRebalancePlanHandler(s.coordinator))
+
 	return s, nil
 }
 
 func (s *Coordinator) Close() error {
+	var err error
+	if s.membership != nil {
+		err = s.membership.Close()
+	}
+	if s.statusWriter != nil {
+		err = multierr.Append(err, s.statusWriter.Close())
+	}
+	if s.adminServer != nil {
+		err = multierr.Append(err, s.adminServer.Close())
+	}
+	if s.debug != nil {
+		err = multierr.Append(err, s.debug.Close())
+	}
+
 	return multierr.Combine(
+		err,
 		s.coordinator.Close(),
 		s.rpcServer.Close(),
 		s.clientPool.Close(),
@@ -16,6 +16,7 @@ package coordinator
 
 import (
 	"crypto/tls"
+	"log/slog"
 
 	"github.com/streamnative/oxia/server/auth"
 
@@ -23,22 +24,36 @@ import (
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/common/container"
+	"github.com/streamnative/oxia/coordinator/impl"
+	"github.com/streamnative/oxia/proto"
 )
 
 type rpcServer struct {
-	grpcServer   container.GrpcServer
-	healthServer *health.Server
+	proto.UnimplementedOxiaClientServer
+
+	grpcServer               container.GrpcServer
+	healthServer             *health.Server
+	shardAssignmentsProvider impl.ShardAssignmentsProvider
+	log                      *slog.Logger
 }
 
-func newRpcServer(bindAddress string, tlsConf *tls.Config) (*rpcServer, error) {
+func newRpcServer(bindAddress string, tlsConf *tls.Config, grpcOptions common.GrpcOptions,
+	shardAssignmentsProvider impl.ShardAssignmentsProvider) (*rpcServer, error) {
 	server := &rpcServer{
-		healthServer: health.NewServer(),
+		healthServer:             health.NewServer(),
+		shardAssignmentsProvider: shardAssignmentsProvider,
+		log: slog.With(
+			slog.String("component", "coordinator-rpc-server"),
+		),
 	}
 
 	var err error
-	server.grpcServer, err = container.Default.StartGrpcServer("coordinator", bindAddress, func(registrar grpc.ServiceRegistrar) {
+	provider := container.NewGrpcProvider(container.InterceptorOptions{}, grpcOptions)
+	server.grpcServer, err = provider.StartGrpcServer("coordinator", bindAddress, func(registrar grpc.ServiceRegistrar) {
 		grpc_health_v1.RegisterHealthServer(registrar, server.healthServer)
+		proto.RegisterOxiaClientServer(registrar, server)
 	}, tlsConf, &auth.Disabled)
 	if err != nil {
 		return nil, err
@@ -47,6 +62,51 @@ func newRpcServer(bindAddress string, tlsConf *tls.Config) (*rpcServer, error) {
 	return server, nil
 }
 
+// GetShardAssignments streams the shard assignments of a namespace, as they
+// get updated by the coordinator, so that the clients can discover the
+// shard leaders without going through a storage server.
+func (s *rpcServer) GetShardAssignments(req *proto.ShardAssignmentsRequest, srv proto.OxiaClient_GetShardAssignmentsServer) error {
+	s.log.Debug(
+		"Shard assignments requests",
+		slog.String("peer", common.GetPeer(srv.Context())),
+	)
+
+	namespace := req.Namespace
+	if namespace == "" {
+		namespace = common.DefaultNamespace
+	}
+
+	var assignments *proto.ShardAssignments
+	var lastSent *proto.NamespaceShardsAssignment
+	for {
+		var err error
+		if assignments, err = s.shardAssignmentsProvider.WaitForNextUpdate(srv.Context(), assignments); err != nil {
+			if srv.Context().Err() != nil {
+				// The client has disconnected or timed out
+				return nil
+			}
+			return err
+		}
+
+		if lastSent == nil {
+			if _, ok := assignments.Namespaces[namespace]; !ok {
+				return common.ErrorNamespaceNotFound
+			}
+		}
+
+		update := common.NextShardAssignments(lastSent, assignments, namespace, req.AcceptDeltas)
+		if update == nil {
+			// No changes in the namespace
+			continue
+		}
+		lastSent = assignments.Namespaces[namespace]
+
+		if err := srv.Send(update); err != nil {
+			return err
+		}
+	}
+}
+
 func (s *rpcServer) Close() error {
 	s.healthServer.Shutdown()
 	return s.grpcServer.Close()
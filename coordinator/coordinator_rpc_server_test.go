@@ -0,0 +1,150 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+type testShardAssignmentsProvider struct {
+	sync.Mutex
+	assignmentsChanged common.ConditionContext
+	assignments        *proto.ShardAssignments
+}
+
+func newTestShardAssignmentsProvider(assignments *proto.ShardAssignments) *testShardAssignmentsProvider {
+	p := &testShardAssignmentsProvider{assignments: assignments}
+	p.assignmentsChanged = common.NewConditionContext(p)
+	return p
+}
+
+func (p *testShardAssignmentsProvider) update(assignments *proto.ShardAssignments) {
+	p.Lock()
+	defer p.Unlock()
+	p.assignments = assignments
+	p.assignmentsChanged.Broadcast()
+}
+
+func (p *testShardAssignmentsProvider) WaitForNextUpdate(ctx context.Context, currentValue *proto.ShardAssignments) (*proto.ShardAssignments, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	for pb.Equal(currentValue, p.assignments) {
+		if err := p.assignmentsChanged.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return p.assignments, nil
+}
+
+func testAssignments(leader1 string, term1 int64) *proto.ShardAssignments {
+	return &proto.ShardAssignments{
+		Namespaces: map[string]*proto.NamespaceShardsAssignment{
+			common.DefaultNamespace: {
+				Assignments: []*proto.ShardAssignment{
+					testShardAssignment(0, "server-0", 1, 0, 100),
+					testShardAssignment(1, leader1, term1, 101, 200),
+				},
+				ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+			},
+		},
+	}
+}
+
+func testShardAssignment(shard int64, leader string, term int64, minHashInclusive uint32, maxHashInclusive uint32) *proto.ShardAssignment {
+	return &proto.ShardAssignment{
+		Shard:  shard,
+		Leader: leader,
+		Term:   term,
+		ShardBoundaries: &proto.ShardAssignment_Int32HashRange{
+			Int32HashRange: &proto.Int32HashRange{
+				MinHashInclusive: minHashInclusive,
+				MaxHashInclusive: maxHashInclusive,
+			},
+		},
+	}
+}
+
+func TestRpcServer_GetShardAssignments(t *testing.T) {
+	provider := newTestShardAssignmentsProvider(testAssignments("server-1", 1))
+	server, err := newRpcServer("localhost:0", nil, common.GrpcOptions{}, provider)
+	assert.NoError(t, err)
+
+	clientPool := common.NewClientPool(nil, nil)
+	rpc, err := clientPool.GetClientRpc(fmt.Sprintf("localhost:%d", server.grpcServer.Port()))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := rpc.GetShardAssignments(ctx, &proto.ShardAssignmentsRequest{AcceptDeltas: true})
+	assert.NoError(t, err)
+
+	// The first response contains all the assignments
+	res, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.False(t, res.Delta)
+	assert.True(t, pb.Equal(testAssignments("server-1", 1), res))
+
+	// A new leader is elected for shard 1, and it's pushed to the open stream
+	provider.update(testAssignments("server-2", 2))
+
+	received := make(chan *proto.ShardAssignments, 1)
+	go func() {
+		res, err := stream.Recv()
+		assert.NoError(t, err)
+		received <- res
+	}()
+
+	select {
+	case res = <-received:
+		assert.True(t, res.Delta)
+		assert.True(t, pb.Equal(&proto.NamespaceShardsAssignment{
+			Assignments: []*proto.ShardAssignment{
+				testShardAssignment(1, "server-2", 2, 101, 200),
+			},
+			ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+		}, res.Namespaces[common.DefaultNamespace]))
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "the leader change was not pushed to the stream")
+	}
+	cancel()
+
+	// After reconnecting, the client gets the full assignments again
+	stream, err = rpc.GetShardAssignments(context.Background(), &proto.ShardAssignmentsRequest{AcceptDeltas: true})
+	assert.NoError(t, err)
+	res, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.False(t, res.Delta)
+	assert.True(t, pb.Equal(testAssignments("server-2", 2), res))
+
+	// Unknown namespace
+	stream, err = rpc.GetShardAssignments(context.Background(), &proto.ShardAssignmentsRequest{Namespace: "non-existing"})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, common.CodeNamespaceNotFound, status.Code(err))
+
+	assert.NoError(t, clientPool.Close())
+	assert.NoError(t, server.Close())
+}
@@ -0,0 +1,250 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/proto"
+)
+
+var (
+	ErrShardNotAvailable = errors.New("shard is being split or deleted")
+	ErrNodeNotInSync     = errors.New("node is not an in-sync member of the ensemble")
+)
+
+type ShardMemberInfo struct {
+	model.ServerAddress
+	InSync bool `json:"inSync"`
+}
+
+// ShardInfo is the view of a shard that is given to the operators.
+type ShardInfo struct {
+	Namespace      string               `json:"namespace"`
+	Shard          int64                `json:"shard"`
+	Status         string               `json:"status"`
+	Term           int64                `json:"term"`
+	Leader         *model.ServerAddress `json:"leader,omitempty"`
+	Ensemble       []ShardMemberInfo    `json:"ensemble"`
+	Int32HashRange model.Int32HashRange `json:"int32HashRange"`
+}
+
+// ServerInfo is the view of a server of the cluster that is given to the
+// operators.
+type ServerInfo struct {
+	model.ServerAddress
+	Status string `json:"status"`
+}
+
+// ShardCompaction is the outcome of the compaction of the database of a shard
+// on a member of its ensemble.
+type ShardCompaction struct {
+	model.ServerAddress
+	ReclaimedBytes int64  `json:"reclaimedBytes"`
+	Error          string `json:"error,omitempty"`
+}
+
+// isInSync tells whether a member of the ensemble is following the leader.
+// The coordinator doesn't track the replication progress of the followers,
+// so the members that are running are considered in sync.
+func isInSync(sm model.ShardMetadata, server model.ServerAddress, nodes map[string]NodeStatus) bool {
+	return sm.Leader != nil && listContains(sm.Ensemble, server) && nodes[server.Internal] == Running
+}
+
+func (c *coordinator) ListShards() []ShardInfo {
+	cs := c.ClusterStatus()
+	nodes := c.NodesStatus()
+
+	res := []ShardInfo{}
+	for namespace, ns := range cs.Namespaces {
+		for shard, sm := range ns.Shards {
+			info := ShardInfo{
+				Namespace:      namespace,
+				Shard:          shard,
+				Status:         sm.Status.String(),
+				Term:           sm.Term,
+				Leader:         sm.Leader,
+				Ensemble:       make([]ShardMemberInfo, 0, len(sm.Ensemble)),
+				Int32HashRange: sm.Int32HashRange,
+			}
+			for _, server := range sm.Ensemble {
+				info.Ensemble = append(info.Ensemble, ShardMemberInfo{
+					ServerAddress: server,
+					InSync:        isInSync(sm, server, nodes),
+				})
+			}
+			res = append(res, info)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Namespace != res[j].Namespace {
+			return res[i].Namespace < res[j].Namespace
+		}
+		return res[i].Shard < res[j].Shard
+	})
+	return res
+}
+
+func (c *coordinator) ListServers() []ServerInfo {
+	c.Lock()
+	servers := append([]model.ServerAddress{}, c.ClusterConfig.Servers...)
+	c.Unlock()
+	nodes := c.NodesStatus()
+
+	res := make([]ServerInfo, 0, len(servers))
+	for _, server := range servers {
+		status, ok := nodes[server.Internal]
+		if !ok {
+			// The server was just added and is not tracked yet
+			status = NotRunning
+		}
+		res = append(res, ServerInfo{ServerAddress: server, Status: status.String()})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Internal < res[j].Internal
+	})
+	return res
+}
+
+func (c *coordinator) TriggerElection(shard int64) error {
+	sc, sm, err := c.getShard(shard)
+	if err != nil {
+		return err
+	}
+
+	if !isMovable(sm) {
+		return errors.Wrapf(ErrShardNotAvailable, "shard %d", shard)
+	}
+
+	c.log.Info(
+		"Triggering leader election",
+		slog.Int64("shard", shard),
+		slog.Any("current-leader", sm.Leader),
+	)
+	return sc.ElectLeader()
+}
+
+func (c *coordinator) TransferLeader(shard int64, newLeader string) error {
+	sc, sm, err := c.getShard(shard)
+	if err != nil {
+		return err
+	}
+
+	if !isMovable(sm) {
+		return errors.Wrapf(ErrShardNotAvailable, "shard %d", shard)
+	}
+
+	var target *model.ServerAddress
+	for _, server := range sm.Ensemble {
+		if server.Internal == newLeader {
+			target = &server
+			break
+		}
+	}
+	if target == nil {
+		return errors.Wrapf(ErrNodeNotInSync, "node %s is not a member of shard %d", newLeader, shard)
+	}
+	if !isInSync(sm, *target, c.NodesStatus()) {
+		return errors.Wrapf(ErrNodeNotInSync, "node %s is not in sync on shard %d", newLeader, shard)
+	}
+
+	c.log.Info(
+		"Transferring leadership",
+		slog.Int64("shard", shard),
+		slog.Any("current-leader", sm.Leader),
+		slog.Any("new-leader", target),
+	)
+	return sc.TransferLeadership(*target)
+}
+
+func (c *coordinator) CompactShard(ctx context.Context, shard int64) ([]ShardCompaction, error) {
+	_, sm, err := c.getShard(shard)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMovable(sm) {
+		return nil, errors.Wrapf(ErrShardNotAvailable, "shard %d", shard)
+	}
+
+	namespace := ""
+	for name, ns := range c.ClusterStatus().Namespaces {
+		if _, ok := ns.Shards[shard]; ok {
+			namespace = name
+			break
+		}
+	}
+
+	c.log.Info(
+		"Compacting shard",
+		slog.Int64("shard", shard),
+		slog.Any("ensemble", sm.Ensemble),
+	)
+
+	// The members are compacted one at a time, so that the others keep
+	// serving the shard at full speed
+	nodes := c.NodesStatus()
+	res := make([]ShardCompaction, 0, len(sm.Ensemble))
+	for _, server := range sm.Ensemble {
+		sc := ShardCompaction{ServerAddress: server}
+		if nodes[server.Internal] != Running {
+			sc.Error = "server is not running"
+			res = append(res, sc)
+			continue
+		}
+
+		resp, err := c.rpc.CompactShard(ctx, server, &proto.CompactShardRequest{
+			Namespace: namespace,
+			Shard:     shard,
+		})
+		if err != nil {
+			c.log.Warn(
+				"Failed to compact shard",
+				slog.Int64("shard", shard),
+				slog.Any("server", server),
+				slog.Any("error", err),
+			)
+			sc.Error = err.Error()
+		} else {
+			sc.ReclaimedBytes = resp.ReclaimedBytes
+		}
+		res = append(res, sc)
+	}
+	return res, nil
+}
+
+func (c *coordinator) getShard(shard int64) (ShardController, model.ShardMetadata, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	sc, ok := c.shardControllers[shard]
+	if !ok {
+		return nil, model.ShardMetadata{}, ErrShardNotFound
+	}
+
+	for _, ns := range c.clusterStatus.Namespaces {
+		if sm, ok := ns.Shards[shard]; ok {
+			return sc, sm.Clone(), nil
+		}
+	}
+	return nil, model.ShardMetadata{}, ErrShardNotFound
+}
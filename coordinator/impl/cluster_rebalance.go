@@ -16,25 +16,255 @@ package impl
 
 import (
 	"log/slog"
+	"slices"
 	"sort"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/coordinator/model"
 )
 
+const (
+	DefaultMaxConcurrentMoves = 1
+
+	// The new replica might not have acknowledged the entries to the leader
+	// yet, right after catching up
+	moveLeadershipTimeout = 30 * time.Second
+)
+
+// RebalanceOptions configures how the coordinator places the shards and
+// moves them when the servers are added or removed. The zero values are replaced with the
+// defaults.
+type RebalanceOptions struct {
+	// MaxConcurrentMoves is the max number of shards whose replicas are
+	// moved at the same time. Each move copies the data of the shard to a
+	// new server
+	MaxConcurrentMoves int
+
+	// ZoneAware spreads the replicas of each shard across the zones
+	// reported by the servers
+	ZoneAware bool
+}
+
+func (o RebalanceOptions) Validate() error {
+	if o.MaxConcurrentMoves < 0 {
+		return errors.New("the max concurrent moves must not be negative")
+	}
+	return nil
+}
+
+func (o RebalanceOptions) withDefaults() RebalanceOptions {
+	if o.MaxConcurrentMoves == 0 {
+		o.MaxConcurrentMoves = DefaultMaxConcurrentMoves
+	}
+	return o
+}
+
 type SwapNodeAction struct {
-	Shard int64
-	From  model.ServerAddress
-	To    model.ServerAddress
+	Shard int64               `json:"shard"`
+	From  model.ServerAddress `json:"from"`
+	To    model.ServerAddress `json:"to"`
+}
+
+// TransferLeadershipAction moves the leadership of a shard to another
+// member of its ensemble.
+type TransferLeadershipAction struct {
+	Shard int64               `json:"shard"`
+	From  model.ServerAddress `json:"from"`
+	To    model.ServerAddress `json:"to"`
+}
+
+// RebalancePlan is the list of actions that spread the replicas and the
+// leaders of the shards evenly across the servers. The leadership transfers
+// are computed on the placement reached after all the replicas are moved.
+type RebalancePlan struct {
+	Moves           []SwapNodeAction           `json:"moves"`
+	LeaderTransfers []TransferLeadershipAction `json:"leaderTransfers"`
+}
+
+// placementConstraints restrict the servers where the replicas and the
+// leaders of the shards can be moved.
+type placementConstraints struct {
+	// The zone of each server, keyed by the internal address. A move never
+	// leaves more replicas of a shard in the new zone than there were in
+	// the old one.
+	zones map[string]string
+
+	// The host of each server, keyed by the internal address. The servers
+	// whose host is not known are considered to be on separate hosts.
+	hosts map[string]string
+
+	// The internal addresses of the servers that can't take new replicas or
+	// leaderships, because they are not running or are in maintenance.
+	unavailable common.Set[string]
+}
+
+// newPlacementConstraints combines the zones in the cluster config with the
+// locality reported by the servers. The zones reported by the servers are
+// only taken into account when the placement is zone-aware, and the ones in
+// the config take precedence.
+func newPlacementConstraints(config model.ClusterConfig, localities map[string]model.ServerLocality,
+	zoneAware bool, unavailable common.Set[string]) placementConstraints {
+	c := placementConstraints{
+		zones:       map[string]string{},
+		hosts:       map[string]string{},
+		unavailable: unavailable,
+	}
+
+	for server, locality := range localities {
+		if zoneAware && locality.Zone != "" {
+			c.zones[server] = locality.Zone
+		}
+		if locality.Host != "" {
+			c.hosts[server] = locality.Host
+		}
+	}
+	for server, zone := range config.ServerZones {
+		c.zones[server] = zone
+	}
+	return c
+}
+
+func (c placementConstraints) isAvailable(server model.ServerAddress) bool {
+	return c.unavailable == nil || !c.unavailable.Contains(server.Internal)
+}
+
+// zone returns the zone of the server. Servers without a zone are
+// considered to be all in the same zone.
+func (c placementConstraints) zone(server model.ServerAddress) string {
+	return c.zones[server.Internal]
+}
+
+func (c placementConstraints) host(server model.ServerAddress) string {
+	if host, ok := c.hosts[server.Internal]; ok {
+		return host
+	}
+	return server.Internal
+}
+
+// canMove checks that moving the replica from one server to the other
+// doesn't increase the number of replicas of the shard in a single zone,
+// nor on a single host.
+func (c placementConstraints) canMove(ensemble []model.ServerAddress, from model.ServerAddress, to model.ServerAddress) bool {
+	return notConcentrated(ensemble, from, to, c.zone) && notConcentrated(ensemble, from, to, c.host)
+}
+
+// notConcentrated checks that moving the replica doesn't leave more
+// replicas of the shard in the group of the new server than there were in
+// the group of the old one.
+func notConcentrated(ensemble []model.ServerAddress, from model.ServerAddress, to model.ServerAddress,
+	group func(model.ServerAddress) string) bool {
+	fromGroup := group(from)
+	toGroup := group(to)
+
+	inFromGroup, inToGroup := 0, 0
+	for _, member := range ensemble {
+		if member == from {
+			continue
+		}
+		switch group(member) {
+		case fromGroup:
+			inFromGroup++
+		case toGroup:
+			inToGroup++
+		}
+	}
+
+	return fromGroup == toGroup || inToGroup <= inFromGroup
+}
+
+func (c placementConstraints) firstMovableShard(shards common.Set[int64], ensembles map[int64][]model.ServerAddress,
+	from model.ServerAddress, to model.ServerAddress) (int64, bool) {
+	for _, shard := range shards.GetSorted() {
+		if c.canMove(ensembles[shard], from, to) {
+			return shard, true
+		}
+	}
+	return 0, false
+}
+
+// selectEnsemble picks the servers for the replicas of a new shard, going
+// through the servers in round-robin order from startIdx. A server is never
+// picked twice. The next replica goes to the first server whose zone, and
+// then whose host, holds the fewest replicas of the shard, so the replicas
+// are in distinct zones whenever there are at least as many zones as
+// replicas, and spread as evenly as possible otherwise. Among those, the
+// servers with fewer replicas in the cluster are preferred, and the count
+// is updated with the picked servers.
+func (c placementConstraints) selectEnsemble(servers []model.ServerAddress, startIdx uint32, count uint32,
+	replicasPerServer map[string]int) []model.ServerAddress {
+	n := len(servers)
+	if int(count) > n {
+		count = uint32(n)
+	}
+
+	res := make([]model.ServerAddress, 0, count)
+	picked := make([]bool, n)
+	inZone := map[string]int{}
+	onHost := map[string]int{}
+
+	for len(res) < int(count) {
+		best := -1
+		for i := 0; i < n; i++ {
+			idx := (int(startIdx) + i) % n
+			if !picked[idx] && (best < 0 || c.isBetterReplica(servers[idx], servers[best], inZone, onHost, replicasPerServer)) {
+				best = idx
+			}
+		}
+
+		server := servers[best]
+		picked[best] = true
+		inZone[c.zone(server)]++
+		onHost[c.host(server)]++
+		replicasPerServer[server.Internal]++
+		res = append(res, server)
+	}
+	return res
+}
+
+func (c placementConstraints) isBetterReplica(s model.ServerAddress, other model.ServerAddress,
+	inZone map[string]int, onHost map[string]int, replicasPerServer map[string]int) bool {
+	if z1, z2 := inZone[c.zone(s)], inZone[c.zone(other)]; z1 != z2 {
+		return z1 < z2
+	}
+	if h1, h2 := onHost[c.host(s)], onHost[c.host(other)]; h1 != h2 {
+		return h1 < h2
+	}
+	return replicasPerServer[s.Internal] < replicasPerServer[other.Internal]
+}
+
+// planRebalance computes the moves of the replicas and the leadership
+// transfers needed to reach an even placement of the shards.
+func planRebalance(servers []model.ServerAddress, currentStatus *model.ClusterStatus, constraints placementConstraints) RebalancePlan {
+	moves := rebalanceCluster(servers, currentStatus, constraints)
+	return RebalancePlan{
+		Moves:           moves,
+		LeaderTransfers: rebalanceLeaders(servers, currentStatus, moves, constraints),
+	}
 }
 
 // Make sure every server is assigned a similar number of shards
 // Output a list of actions to be taken to rebalance the cluster.
-func rebalanceCluster(servers []model.ServerAddress, currentStatus *model.ClusterStatus) []SwapNodeAction { //nolint:revive
+func rebalanceCluster(servers []model.ServerAddress, currentStatus *model.ClusterStatus, //nolint:revive
+	constraints placementConstraints) []SwapNodeAction {
 	res := make([]SwapNodeAction, 0)
 
-	serversCount := len(servers)
 	shardsPerServer, deletedServers := getShardsPerServer(servers, currentStatus)
+	ensembles := getEnsembles(currentStatus)
+
+	// The replicas on the unavailable servers are left where they are, and
+	// no new replica is placed there
+	for server := range shardsPerServer {
+		if !constraints.isAvailable(server) {
+			delete(shardsPerServer, server)
+		}
+	}
+	serversCount := len(shardsPerServer)
+	if serversCount == 0 {
+		return res
+	}
 
 outer:
 	for {
@@ -68,9 +298,9 @@ outer:
 				to := rankings[j]
 				eligibleShards := shards.Complement(to.Shards)
 
-				if !eligibleShards.IsEmpty() {
+				if shard, ok := constraints.firstMovableShard(eligibleShards, ensembles, ds, to.Addr); ok {
 					a := SwapNodeAction{
-						Shard: eligibleShards.GetSorted()[0],
+						Shard: shard,
 						From:  ds,
 						To:    to.Addr,
 					}
@@ -82,6 +312,7 @@ outer:
 						deletedServers[ds] = shards
 					}
 					shardsPerServer[a.To].Add(a.Shard)
+					ensembles[a.Shard] = replaceInList(ensembles[a.Shard], a.From, a.To)
 
 					slog.Debug(
 						"Transfer from removed node",
@@ -99,26 +330,16 @@ outer:
 
 		// Find a shard from the most loaded server that can be moved to the
 		// least loaded server, with the constraint that multiple replicas of
-		// the same shard should not be assigned to one server
-		mostLoaded := rankings[0]
-		leastLoaded := rankings[serversCount-1]
-		if mostLoaded.Shards.Count() <= leastLoaded.Shards.Count()+1 {
-			break
-		}
-
-		eligibleShards := mostLoaded.Shards.Complement(leastLoaded.Shards)
-		if eligibleShards.IsEmpty() {
+		// the same shard should not be assigned to one server. When the zones
+		// prevent it, the next pairs of servers are tried
+		a, ok := findMove(rankings, ensembles, constraints)
+		if !ok {
 			break
 		}
 
-		a := SwapNodeAction{
-			Shard: eligibleShards.GetSorted()[0],
-			From:  mostLoaded.Addr,
-			To:    leastLoaded.Addr,
-		}
-
 		shardsPerServer[a.From].Remove(a.Shard)
 		shardsPerServer[a.To].Add(a.Shard)
+		ensembles[a.Shard] = replaceInList(ensembles[a.Shard], a.From, a.To)
 
 		slog.Debug(
 			"Swapping nodes",
@@ -131,6 +352,29 @@ outer:
 	return res
 }
 
+func findMove(rankings []ServerRank, ensembles map[int64][]model.ServerAddress,
+	constraints placementConstraints) (SwapNodeAction, bool) {
+	for i := 0; i < len(rankings); i++ {
+		mostLoaded := rankings[i]
+		for j := len(rankings) - 1; j > i; j-- {
+			leastLoaded := rankings[j]
+			if mostLoaded.Shards.Count() <= leastLoaded.Shards.Count()+1 {
+				break
+			}
+
+			eligibleShards := mostLoaded.Shards.Complement(leastLoaded.Shards)
+			if shard, ok := constraints.firstMovableShard(eligibleShards, ensembles, mostLoaded.Addr, leastLoaded.Addr); ok {
+				return SwapNodeAction{
+					Shard: shard,
+					From:  mostLoaded.Addr,
+					To:    leastLoaded.Addr,
+				}, true
+			}
+		}
+	}
+	return SwapNodeAction{}, false
+}
+
 func getShardsPerServer(servers []model.ServerAddress, currentStatus *model.ClusterStatus) (
 	existingServers map[model.ServerAddress]common.Set[int64],
 	deletedServers map[model.ServerAddress]common.Set[int64]) {
@@ -143,6 +387,10 @@ func getShardsPerServer(servers []model.ServerAddress, currentStatus *model.Clus
 
 	for _, nss := range currentStatus.Namespaces {
 		for shardId, shard := range nss.Shards {
+			if !isMovable(shard) {
+				continue
+			}
+
 			for _, addr := range shard.Ensemble {
 				if _, ok := existingServers[addr]; ok {
 					existingServers[addr].Add(shardId)
@@ -162,6 +410,169 @@ func getShardsPerServer(servers []model.ServerAddress, currentStatus *model.Clus
 	return existingServers, deletedServers
 }
 
+// isMovable excludes the shards whose ensemble must not change, because
+// they are being split or deleted.
+func isMovable(shard model.ShardMetadata) bool {
+	return shard.Split == nil && shard.Status != model.ShardStatusDeleting
+}
+
+func getEnsembles(currentStatus *model.ClusterStatus) map[int64][]model.ServerAddress {
+	res := map[int64][]model.ServerAddress{}
+	for _, nss := range currentStatus.Namespaces {
+		for shardId, shard := range nss.Shards {
+			if isMovable(shard) {
+				res[shardId] = slices.Clone(shard.Ensemble)
+			}
+		}
+	}
+	return res
+}
+
+// Make sure every server is the leader of a similar number of shards, once
+// the replicas are moved. A replica that is moved away from the leader
+// takes the leadership along to its new server.
+func rebalanceLeaders(servers []model.ServerAddress, currentStatus *model.ClusterStatus,
+	moves []SwapNodeAction, constraints placementConstraints) []TransferLeadershipAction {
+	res := make([]TransferLeadershipAction, 0)
+
+	ensembles := getEnsembles(currentStatus)
+	leaders := map[int64]model.ServerAddress{}
+	for _, nss := range currentStatus.Namespaces {
+		for shardId, shard := range nss.Shards {
+			if isMovable(shard) && shard.Leader != nil {
+				leaders[shardId] = *shard.Leader
+			}
+		}
+	}
+
+	for _, m := range moves {
+		ensembles[m.Shard] = replaceInList(ensembles[m.Shard], m.From, m.To)
+		if leader, ok := leaders[m.Shard]; ok && leader == m.From {
+			leaders[m.Shard] = m.To
+		}
+	}
+
+	leadersPerServer := map[model.ServerAddress]common.Set[int64]{}
+	for _, server := range servers {
+		if constraints.isAvailable(server) {
+			leadersPerServer[server] = common.NewSet[int64]()
+		}
+	}
+	for shard, leader := range leaders {
+		if shards, ok := leadersPerServer[leader]; ok {
+			shards.Add(shard)
+		}
+	}
+
+outer:
+	for {
+		rankings := constraints.rankByZone(getServerRanking(leadersPerServer))
+		if len(rankings) == 0 {
+			break
+		}
+
+		// Move one leadership from the server that has the most to the
+		// least loaded server that is part of the ensemble of the shard
+		mostLoaded := rankings[0]
+		for j := len(rankings) - 1; j > 0; j-- {
+			leastLoaded := rankings[j]
+			if mostLoaded.Shards.Count() <= leastLoaded.Shards.Count()+1 {
+				break
+			}
+
+			for _, shard := range mostLoaded.Shards.GetSorted() {
+				if !listContains(ensembles[shard], leastLoaded.Addr) {
+					continue
+				}
+
+				a := TransferLeadershipAction{
+					Shard: shard,
+					From:  mostLoaded.Addr,
+					To:    leastLoaded.Addr,
+				}
+
+				leadersPerServer[a.From].Remove(a.Shard)
+				leadersPerServer[a.To].Add(a.Shard)
+
+				slog.Debug(
+					"Transferring leadership",
+					slog.Any("transfer-leadership-action", a),
+				)
+
+				res = append(res, a)
+				continue outer
+			}
+		}
+
+		// None of the shards led by the most loaded server can be moved directly
+		// to a less loaded one, though the leaderships can be shifted through the
+		// servers in between
+		leastLoaded := rankings[len(rankings)-1]
+		if mostLoaded.Shards.Count() <= leastLoaded.Shards.Count()+1 {
+			break
+		}
+
+		chain := findLeadershipChain(mostLoaded.Addr, leadersPerServer, ensembles, func(addr model.ServerAddress) bool {
+			return leadersPerServer[addr].Count()+1 < mostLoaded.Shards.Count()
+		})
+		if len(chain) == 0 {
+			break
+		}
+
+		for _, a := range chain {
+			leadersPerServer[a.From].Remove(a.Shard)
+			leadersPerServer[a.To].Add(a.Shard)
+
+			slog.Debug(
+				"Transferring leadership",
+				slog.Any("transfer-leadership-action", a),
+			)
+		}
+		res = append(res, chain...)
+	}
+
+	return res
+}
+
+// findLeadershipChain searches the shortest sequence of leadership transfers
+// that moves one leadership away from the `from` server and one to a server
+// that satisfies `isTarget`, leaving the number of leaderships of the
+// servers in between unchanged.
+func findLeadershipChain(from model.ServerAddress, leadersPerServer map[model.ServerAddress]common.Set[int64],
+	ensembles map[int64][]model.ServerAddress, isTarget func(model.ServerAddress) bool) []TransferLeadershipAction {
+	parents := map[model.ServerAddress]TransferLeadershipAction{}
+	visited := map[model.ServerAddress]bool{from: true}
+	queue := []model.ServerAddress{from}
+
+	for len(queue) > 0 {
+		server := queue[0]
+		queue = queue[1:]
+
+		for _, shard := range leadersPerServer[server].GetSorted() {
+			for _, member := range ensembles[shard] {
+				if _, available := leadersPerServer[member]; !available || visited[member] {
+					continue
+				}
+
+				visited[member] = true
+				parents[member] = TransferLeadershipAction{Shard: shard, From: server, To: member}
+				if !isTarget(member) {
+					queue = append(queue, member)
+					continue
+				}
+
+				var chain []TransferLeadershipAction
+				for addr := member; addr != from; addr = parents[addr].From {
+					chain = append([]TransferLeadershipAction{parents[addr]}, chain...)
+				}
+				return chain
+			}
+		}
+	}
+
+	return nil
+}
+
 type ServerRank struct {
 	Addr   model.ServerAddress
 	Shards common.Set[int64]
@@ -191,6 +602,30 @@ func getServerRanking(shardsPerServer map[model.ServerAddress]common.Set[int64])
 	return res
 }
 
+// rankByZone orders the servers that lead the same number of shards by the
+// number of leaders in their zone, so that the leaderships are moved from
+// the most loaded zones to the least loaded ones first.
+func (c placementConstraints) rankByZone(rankings []ServerRank) []ServerRank {
+	if len(c.zones) == 0 {
+		return rankings
+	}
+
+	leadersPerZone := map[string]int{}
+	for _, r := range rankings {
+		leadersPerZone[c.zone(r.Addr)] += r.Shards.Count()
+	}
+
+	sort.SliceStable(rankings, func(i, j int) bool {
+		c1 := rankings[i].Shards.Count()
+		c2 := rankings[j].Shards.Count()
+		if c1 != c2 {
+			return c1 > c2
+		}
+		return leadersPerZone[c.zone(rankings[i].Addr)] > leadersPerZone[c.zone(rankings[j].Addr)]
+	})
+	return rankings
+}
+
 func getFirstEntry(m map[model.ServerAddress]common.Set[int64]) (model.ServerAddress, common.Set[int64]) {
 	keys := make([]model.ServerAddress, 0, len(m))
 	for k := range m {
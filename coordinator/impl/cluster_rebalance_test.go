@@ -15,9 +15,13 @@
 package impl
 
 import (
+	"context"
 	"log/slog"
 	"math"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -130,7 +134,7 @@ func TestClusterRebalance_Single(t *testing.T) {
 		},
 	}
 
-	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3, s4, s5}, cs)
+	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3, s4, s5}, cs, placementConstraints{})
 	assert.Equal(t, []SwapNodeAction{{
 		Shard: 0,
 		From:  s1,
@@ -153,7 +157,7 @@ func TestClusterRebalance_Multiple(t *testing.T) {
 		},
 	}
 
-	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3, s4, s5}, cs)
+	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3, s4, s5}, cs, placementConstraints{})
 	slog.Info(
 		"actions",
 		slog.Any("actions", actions),
@@ -189,7 +193,7 @@ func TestClusterRebalance_DoubleSize(t *testing.T) {
 		},
 	}
 
-	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3, s4, s5, s6}, cs)
+	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3, s4, s5, s6}, cs, placementConstraints{})
 	slog.Info(
 		"actions",
 		slog.Any("actions", actions),
@@ -249,7 +253,7 @@ func TestClusterRebalance_ShrinkOne(t *testing.T) {
 		},
 	}
 
-	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3, s4, s5}, cs)
+	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3, s4, s5}, cs, placementConstraints{})
 	slog.Info(
 		"actions",
 		slog.Any("actions", actions),
@@ -285,7 +289,7 @@ func TestClusterRebalance_ShrinkToHalf(t *testing.T) {
 		},
 	}
 
-	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3}, cs)
+	actions := rebalanceCluster([]model.ServerAddress{s1, s2, s3}, cs, placementConstraints{})
 	slog.Info(
 		"actions",
 		slog.Any("actions", actions),
@@ -328,3 +332,436 @@ func TestClusterRebalance_ShrinkToHalf(t *testing.T) {
 		To:    s1,
 	}}, actions)
 }
+
+// applyRebalancePlan checks that every action of the plan is valid on the
+// placement reached so far, and returns the final placement.
+func applyRebalancePlan(t *testing.T, cs *model.ClusterStatus, plan RebalancePlan) (
+	ensembles map[int64][]model.ServerAddress, leaders map[int64]model.ServerAddress) {
+	t.Helper()
+
+	ensembles = getEnsembles(cs)
+	leaders = map[int64]model.ServerAddress{}
+	for _, nss := range cs.Namespaces {
+		for shardId, shard := range nss.Shards {
+			if shard.Leader != nil {
+				leaders[shardId] = *shard.Leader
+			}
+		}
+	}
+
+	for _, m := range plan.Moves {
+		assert.True(t, listContains(ensembles[m.Shard], m.From))
+		assert.False(t, listContains(ensembles[m.Shard], m.To))
+		ensembles[m.Shard] = replaceInList(ensembles[m.Shard], m.From, m.To)
+		if leaders[m.Shard] == m.From {
+			leaders[m.Shard] = m.To
+		}
+	}
+
+	for _, a := range plan.LeaderTransfers {
+		assert.Equal(t, leaders[a.Shard], a.From)
+		assert.True(t, listContains(ensembles[a.Shard], a.To))
+		leaders[a.Shard] = a.To
+	}
+
+	return ensembles, leaders
+}
+
+func assertBalanced(t *testing.T, servers []model.ServerAddress, counts map[model.ServerAddress]int) {
+	t.Helper()
+
+	minCount, maxCount := math.MaxInt, 0
+	for _, s := range servers {
+		minCount = min(minCount, counts[s])
+		maxCount = max(maxCount, counts[s])
+	}
+	assert.LessOrEqual(t, maxCount-minCount, 1, "unbalanced: %v", counts)
+}
+
+func TestRebalancePlan_ScaleUp(t *testing.T) {
+	shards := map[int64]model.ShardMetadata{}
+	for i, leader := range []model.ServerAddress{s1, s2, s3, s1, s2, s3} {
+		shards[int64(i)] = model.ShardMetadata{
+			Status:   model.ShardStatusSteadyState,
+			Leader:   &leader,
+			Ensemble: []model.ServerAddress{s1, s2, s3},
+		}
+	}
+	cs := &model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			"ns-1": {ReplicationFactor: 3, Shards: shards},
+		},
+	}
+
+	servers := []model.ServerAddress{s1, s2, s3, s4, s5}
+	plan := planRebalance(servers, cs, placementConstraints{})
+	ensembles, leaders := applyRebalancePlan(t, cs, plan)
+
+	replicas := map[model.ServerAddress]int{}
+	for _, ensemble := range ensembles {
+		members := common.NewSet[string]()
+		for _, s := range ensemble {
+			members.Add(s.Internal)
+			replicas[s]++
+		}
+		assert.Equal(t, 3, members.Count())
+	}
+	leadersCount := map[model.ServerAddress]int{}
+	for _, l := range leaders {
+		leadersCount[l]++
+	}
+
+	assertBalanced(t, servers, replicas)
+	assertBalanced(t, servers, leadersCount)
+	assert.NotZero(t, replicas[s4])
+	assert.NotZero(t, replicas[s5])
+}
+
+func TestRebalancePlan_LoseServer(t *testing.T) {
+	cs := &model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			"ns-1": {
+				ReplicationFactor: 3,
+				Shards: map[int64]model.ShardMetadata{
+					0: {Leader: &s1, Ensemble: []model.ServerAddress{s1, s2, s3}},
+					1: {Leader: &s4, Ensemble: []model.ServerAddress{s4, s1, s2}},
+					2: {Leader: &s3, Ensemble: []model.ServerAddress{s3, s4, s1}},
+					3: {Leader: &s4, Ensemble: []model.ServerAddress{s2, s3, s4}},
+				},
+			},
+		},
+	}
+
+	servers := []model.ServerAddress{s1, s2, s3}
+	plan := planRebalance(servers, cs, placementConstraints{})
+	ensembles, leaders := applyRebalancePlan(t, cs, plan)
+
+	assert.Len(t, plan.Moves, 3)
+	for shard, ensemble := range ensembles {
+		checkServerLists(t, servers, ensemble)
+		assert.NotEqual(t, s4, leaders[shard])
+	}
+
+	leadersCount := map[model.ServerAddress]int{}
+	for _, l := range leaders {
+		leadersCount[l]++
+	}
+	assertBalanced(t, servers, leadersCount)
+}
+
+func TestRebalancePlan_Zones(t *testing.T) {
+	cs := &model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			"ns-1": {
+				ReplicationFactor: 3,
+				Shards: map[int64]model.ShardMetadata{
+					0: {Leader: &s1, Ensemble: []model.ServerAddress{s1, s3, s5}},
+					1: {Leader: &s3, Ensemble: []model.ServerAddress{s1, s3, s5}},
+					2: {Leader: &s5, Ensemble: []model.ServerAddress{s1, s3, s5}},
+					3: {Leader: &s1, Ensemble: []model.ServerAddress{s1, s3, s5}},
+				},
+			},
+		},
+	}
+
+	zones := map[string]string{
+		s1.Internal: "zone-a", s2.Internal: "zone-a",
+		s3.Internal: "zone-b", s4.Internal: "zone-b",
+		s5.Internal: "zone-c", s6.Internal: "zone-c",
+	}
+
+	servers := []model.ServerAddress{s1, s2, s3, s4, s5, s6}
+	plan := planRebalance(servers, cs, placementConstraints{zones: zones})
+	ensembles, _ := applyRebalancePlan(t, cs, plan)
+
+	assert.NotEmpty(t, plan.Moves)
+	replicas := map[model.ServerAddress]int{}
+	for _, ensemble := range ensembles {
+		shardZones := common.NewSet[string]()
+		for _, s := range ensemble {
+			shardZones.Add(zones[s.Internal])
+			replicas[s]++
+		}
+		assert.Equal(t, 3, shardZones.Count())
+	}
+	assertBalanced(t, servers, replicas)
+}
+
+func TestRebalancePlan_Hosts(t *testing.T) {
+	cs := &model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			"ns-1": {
+				ReplicationFactor: 3,
+				Shards: map[int64]model.ShardMetadata{
+					0: {Leader: &s1, Ensemble: []model.ServerAddress{s1, s2, s3}},
+					1: {Leader: &s2, Ensemble: []model.ServerAddress{s1, s2, s3}},
+					2: {Leader: &s3, Ensemble: []model.ServerAddress{s1, s2, s3}},
+					3: {Leader: &s1, Ensemble: []model.ServerAddress{s1, s2, s3}},
+				},
+			},
+		},
+	}
+
+	// s3 and s4 run on the same host, so the replicas of s1 and s2 can't be
+	// moved to s4
+	constraints := placementConstraints{
+		hosts: map[string]string{s3.Internal: "host-3", s4.Internal: "host-3"},
+	}
+
+	plan := planRebalance([]model.ServerAddress{s1, s2, s3, s4}, cs, constraints)
+	ensembles, _ := applyRebalancePlan(t, cs, plan)
+
+	assert.NotEmpty(t, plan.Moves)
+	for _, m := range plan.Moves {
+		assert.Equal(t, s3, m.From)
+		assert.Equal(t, s4, m.To)
+	}
+	for _, ensemble := range ensembles {
+		hosts := common.NewSet[string]()
+		for _, s := range ensemble {
+			hosts.Add(constraints.host(s))
+		}
+		assert.Equal(t, 3, hosts.Count())
+	}
+}
+
+func TestPlacementConstraints_CanMove(t *testing.T) {
+	constraints := placementConstraints{
+		zones: map[string]string{
+			s1.Internal: "zone-a", s2.Internal: "zone-a",
+			s3.Internal: "zone-b", s4.Internal: "zone-c",
+			s6.Internal: "zone-a",
+		},
+		hosts: map[string]string{s3.Internal: "host-3", s5.Internal: "host-3"},
+	}
+	ensemble := []model.ServerAddress{s1, s2, s3}
+
+	// Moving to a new zone spreads the replicas
+	assert.True(t, constraints.canMove(ensemble, s1, s4))
+	// Moving to a zone that already has more replicas doesn't
+	assert.False(t, constraints.canMove(ensemble, s3, s6))
+	// Moving within the same host is always allowed
+	assert.True(t, constraints.canMove(ensemble, s3, s5))
+	// s5 has no zone, though it shares the host with s3
+	assert.False(t, constraints.canMove(ensemble, s2, s5))
+}
+
+func TestRebalanceLeaders_RankByZone(t *testing.T) {
+	leaders := map[model.ServerAddress]common.Set[int64]{
+		s1: common.NewSetFrom([]int64{0}),
+		s2: common.NewSetFrom([]int64{1}),
+		s3: common.NewSetFrom([]int64{2}),
+		s4: common.NewSet[int64](),
+		s5: common.NewSet[int64](),
+	}
+	zones := map[string]string{
+		s1.Internal: "zone-a", s2.Internal: "zone-a",
+		s3.Internal: "zone-b", s5.Internal: "zone-b",
+		s4.Internal: "zone-c",
+	}
+
+	addrs := func(rankings []ServerRank) []model.ServerAddress {
+		var res []model.ServerAddress
+		for _, r := range rankings {
+			res = append(res, r.Addr)
+		}
+		return res
+	}
+
+	assert.Equal(t, []model.ServerAddress{s1, s2, s3, s4, s5},
+		addrs(placementConstraints{}.rankByZone(getServerRanking(leaders))))
+
+	// Among the servers with the same number of leaders, the ones in the
+	// zones with more leaders come first, so the leaderships are moved to
+	// the zone without any leader first
+	assert.Equal(t, []model.ServerAddress{s1, s2, s3, s5, s4},
+		addrs(placementConstraints{zones: zones}.rankByZone(getServerRanking(leaders))))
+}
+
+func TestRebalanceLeaders_Chain(t *testing.T) {
+	cs := &model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			"ns-1": {
+				ReplicationFactor: 2,
+				Shards: map[int64]model.ShardMetadata{
+					0: {Leader: &s1, Ensemble: []model.ServerAddress{s1, s2}},
+					1: {Leader: &s1, Ensemble: []model.ServerAddress{s1, s2}},
+					2: {Leader: &s2, Ensemble: []model.ServerAddress{s2, s3}},
+					3: {Leader: &s3, Ensemble: []model.ServerAddress{s3, s4}},
+				},
+			},
+		},
+	}
+
+	// s4 is not in the ensembles of the shards led by s1, so the leaderships
+	// are shifted through s2 and s3
+	transfers := rebalanceLeaders([]model.ServerAddress{s1, s2, s3, s4}, cs, nil, placementConstraints{})
+	assert.Equal(t, []TransferLeadershipAction{
+		{Shard: 0, From: s1, To: s2},
+		{Shard: 2, From: s2, To: s3},
+		{Shard: 3, From: s3, To: s4},
+	}, transfers)
+}
+
+func TestRebalancePlan_UnavailableServers(t *testing.T) {
+	cs := &model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			"ns-1": {
+				ReplicationFactor: 1,
+				Shards: map[int64]model.ShardMetadata{
+					0: {Leader: &s1, Ensemble: []model.ServerAddress{s1}},
+					1: {Leader: &s1, Ensemble: []model.ServerAddress{s1}},
+					2: {Leader: &s1, Ensemble: []model.ServerAddress{s1}},
+					3: {Leader: &s1, Ensemble: []model.ServerAddress{s1}},
+				},
+			},
+		},
+	}
+
+	// s2 is in maintenance, s3 is down
+	unavailable := common.NewSetFrom([]string{s2.Internal, s3.Internal})
+	plan := planRebalance([]model.ServerAddress{s1, s2, s3, s4}, cs, placementConstraints{unavailable: unavailable})
+
+	assert.Equal(t, []SwapNodeAction{{
+		Shard: 0,
+		From:  s1,
+		To:    s4,
+	}, {
+		Shard: 1,
+		From:  s1,
+		To:    s4,
+	}}, plan.Moves)
+	assert.Empty(t, plan.LeaderTransfers)
+}
+
+func TestRebalancePlan_SkipSplittingShards(t *testing.T) {
+	cs := &model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			"ns-1": {
+				ReplicationFactor: 1,
+				Shards: map[int64]model.ShardMetadata{
+					0: {Leader: &s1, Ensemble: []model.ServerAddress{s1}, Split: &model.SplitMetadata{}},
+					1: {Leader: &s1, Ensemble: []model.ServerAddress{s1}, Status: model.ShardStatusDeleting},
+				},
+			},
+		},
+	}
+
+	plan := planRebalance([]model.ServerAddress{s2}, cs, placementConstraints{})
+	assert.Empty(t, plan.Moves)
+	assert.Empty(t, plan.LeaderTransfers)
+}
+
+// fakeShardController applies the ensemble changes of the rebalancing
+// immediately, but it keeps the added replicas catching up for a while.
+type fakeShardController struct {
+	ShardController
+	sync.Mutex
+
+	ensemble []model.ServerAddress
+	leader   model.ServerAddress
+
+	catchUpTime     time.Duration
+	ongoingMoves    *atomic.Int64
+	maxOngoingMoves *atomic.Int64
+}
+
+func (f *fakeShardController) Status() model.ShardStatus {
+	return model.ShardStatusSteadyState
+}
+
+func (f *fakeShardController) Leader() *model.ServerAddress {
+	f.Lock()
+	defer f.Unlock()
+	leader := f.leader
+	return &leader
+}
+
+func (f *fakeShardController) AddNode(node model.ServerAddress) error {
+	ongoing := f.ongoingMoves.Add(1)
+	defer f.ongoingMoves.Add(-1)
+	for {
+		maxOngoing := f.maxOngoingMoves.Load()
+		if ongoing <= maxOngoing || f.maxOngoingMoves.CompareAndSwap(maxOngoing, ongoing) {
+			break
+		}
+	}
+
+	time.Sleep(f.catchUpTime)
+
+	f.Lock()
+	defer f.Unlock()
+	f.ensemble = append(f.ensemble, node)
+	return nil
+}
+
+func (f *fakeShardController) RemoveNode(node model.ServerAddress) error {
+	f.Lock()
+	defer f.Unlock()
+	f.ensemble = removeFromList(f.ensemble, node)
+	return nil
+}
+
+func (f *fakeShardController) TransferLeadership(newLeader model.ServerAddress) error {
+	f.Lock()
+	defer f.Unlock()
+	f.leader = newLeader
+	return nil
+}
+
+func TestCoordinator_RebalanceConcurrencyCap(t *testing.T) {
+	ongoingMoves := &atomic.Int64{}
+	maxOngoingMoves := &atomic.Int64{}
+
+	shards := map[int64]model.ShardMetadata{}
+	controllers := map[int64]ShardController{}
+	for i := int64(0); i < 8; i++ {
+		shards[i] = model.ShardMetadata{
+			Status:   model.ShardStatusSteadyState,
+			Leader:   &s1,
+			Ensemble: []model.ServerAddress{s1},
+		}
+		controllers[i] = &fakeShardController{
+			ensemble:        []model.ServerAddress{s1},
+			leader:          s1,
+			catchUpTime:     50 * time.Millisecond,
+			ongoingMoves:    ongoingMoves,
+			maxOngoingMoves: maxOngoingMoves,
+		}
+	}
+
+	c := &coordinator{
+		ClusterConfig: model.ClusterConfig{
+			Servers: []model.ServerAddress{s1, s2, s3, s4},
+		},
+		clusterStatus: &model.ClusterStatus{
+			Namespaces: map[string]model.NamespaceStatus{
+				"ns-1": {ReplicationFactor: 1, Shards: shards},
+			},
+		},
+		shardControllers: controllers,
+		rebalance:        RebalanceOptions{MaxConcurrentMoves: 2},
+		log:              slog.Default(),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	defer c.cancel()
+
+	plan := c.RebalancePlan()
+	assert.Len(t, plan.Moves, 6)
+
+	assert.NoError(t, c.rebalanceCluster())
+	assert.EqualValues(t, 2, maxOngoingMoves.Load())
+	assert.EqualValues(t, 0, ongoingMoves.Load())
+
+	replicas := map[model.ServerAddress]int{}
+	leaders := map[model.ServerAddress]int{}
+	for _, sc := range controllers {
+		f := sc.(*fakeShardController)
+		assert.Len(t, f.ensemble, 1)
+		assert.Equal(t, f.ensemble[0], f.leader)
+		replicas[f.ensemble[0]]++
+		leaders[f.leader]++
+	}
+	assert.Equal(t, map[model.ServerAddress]int{s1: 2, s2: 2, s3: 2, s4: 2}, replicas)
+	assert.Equal(t, replicas, leaders)
+}
@@ -0,0 +1,334 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/proto"
+)
+
+var OxiaClusterResource = schema.GroupVersionResource{
+	Group:    "oxia.streamnative.io",
+	Version:  "v1alpha1",
+	Resource: "oxiaclusters",
+}
+
+const (
+	ConditionAllShardsHaveLeaders = "AllShardsHaveLeaders"
+	ConditionDegraded             = "Degraded"
+
+	defaultStatusWriterDebounce = 1 * time.Second
+	defaultStatusWriterResync   = 1 * time.Minute
+)
+
+// ClusterStatusSource is the view of the coordinator needed to report the
+// status of the cluster.
+type ClusterStatusSource interface {
+	ShardAssignmentsProvider
+
+	ClusterStatus() model.ClusterStatus
+	NodesStatus() map[string]NodeStatus
+}
+
+type ShardStatusReport struct {
+	Namespace       string `json:"namespace"`
+	Shard           int64  `json:"shard"`
+	Status          string `json:"status"`
+	Term            int64  `json:"term"`
+	Leader          string `json:"leader,omitempty"`
+	Replicas        int    `json:"replicas"`
+	InSyncFollowers int    `json:"inSyncFollowers"`
+}
+
+type ClusterStatusReport struct {
+	Shards     []ShardStatusReport `json:"shards"`
+	Conditions []metav1.Condition  `json:"conditions"`
+}
+
+// clusterStatusWriter reports the status of the shards in the status
+// subresource of the OxiaCluster resource, whenever the shard assignments
+// change. The changes that come close together, like during an election
+// storm, are written at once.
+//
+// If the resource doesn't exist, as with the deployments that are not
+// managed through it, the writer stops after logging a warning.
+type clusterStatusWriter struct {
+	client          dynamic.Interface
+	namespace, name string
+	source          ClusterStatusSource
+
+	debounceInterval time.Duration
+	resyncInterval   time.Duration
+	now              func() time.Time
+
+	changes chan any
+	// The last status written, to skip the writes that don't change anything
+	// and to keep the transition time of the conditions
+	lastReport *ClusterStatusReport
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	log    *slog.Logger
+}
+
+func NewClusterStatusWriter(client dynamic.Interface, namespace, name string, source ClusterStatusSource) io.Closer {
+	return newClusterStatusWriter(client, namespace, name, source, defaultStatusWriterDebounce, defaultStatusWriterResync)
+}
+
+func newClusterStatusWriter(client dynamic.Interface, namespace, name string, source ClusterStatusSource,
+	debounceInterval time.Duration, resyncInterval time.Duration) *clusterStatusWriter {
+	w := &clusterStatusWriter{
+		client:           client,
+		namespace:        namespace,
+		name:             name,
+		source:           source,
+		debounceInterval: debounceInterval,
+		resyncInterval:   resyncInterval,
+		now:              time.Now,
+		changes:          make(chan any, 1),
+		log: slog.With(
+			slog.String("component", "cluster-status-writer"),
+			slog.String("namespace", namespace),
+			slog.String("name", name),
+		),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	w.wg.Add(2)
+	go common.DoWithLabels(
+		w.ctx,
+		map[string]string{
+			"oxia": "cluster-status-writer-watch",
+		},
+		func() {
+			defer w.wg.Done()
+			w.watchAssignments()
+		},
+	)
+	go common.DoWithLabels(
+		w.ctx,
+		map[string]string{
+			"oxia": "cluster-status-writer",
+		},
+		func() {
+			defer w.wg.Done()
+			w.run()
+		},
+	)
+
+	return w
+}
+
+func (w *clusterStatusWriter) watchAssignments() {
+	var assignments *proto.ShardAssignments
+	for {
+		var err error
+		if assignments, err = w.source.WaitForNextUpdate(w.ctx, assignments); err != nil {
+			return
+		}
+
+		select {
+		case w.changes <- nil:
+		default:
+		}
+	}
+}
+
+func (w *clusterStatusWriter) run() {
+	resync := time.NewTicker(w.resyncInterval)
+	defer resync.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-w.changes:
+		case <-resync.C:
+		}
+
+		// Wait for the other changes that follow closely
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(w.debounceInterval):
+		}
+
+		select {
+		case <-w.changes:
+		default:
+		}
+
+		if err := w.write(); err != nil {
+			if k8serrors.IsNotFound(err) {
+				w.log.Warn(
+					"OxiaCluster resource not found, the status of the shards is not going to be reported",
+					slog.Any("error", err),
+				)
+				return
+			}
+
+			w.log.Warn(
+				"Failed to write the status of the shards",
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+func (w *clusterStatusWriter) write() error {
+	report := w.report()
+	if w.lastReport != nil && reflect.DeepEqual(report, w.lastReport) {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]any{"status": report})
+	if err != nil {
+		return err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, err := w.client.Resource(OxiaClusterResource).Namespace(w.namespace).Patch(w.ctx, w.name,
+			types.MergePatchType, patch, metav1.PatchOptions{FieldManager: fieldManager}, "status")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	w.log.Debug(
+		"Written the status of the shards",
+		slog.Int("shards", len(report.Shards)),
+	)
+	w.lastReport = report
+	return nil
+}
+
+func (w *clusterStatusWriter) report() *ClusterStatusReport {
+	cs := w.source.ClusterStatus()
+	nodes := w.source.NodesStatus()
+
+	report := &ClusterStatusReport{Shards: []ShardStatusReport{}}
+	withoutLeader := 0
+	degraded := 0
+
+	for namespace, ns := range cs.Namespaces {
+		for shard, sm := range ns.Shards {
+			if isSplitChild(shard, sm) || sm.Status == model.ShardStatusDeleting {
+				continue
+			}
+
+			sr := ShardStatusReport{
+				Namespace: namespace,
+				Shard:     shard,
+				Status:    sm.Status.String(),
+				Term:      sm.Term,
+				Replicas:  len(sm.Ensemble),
+			}
+			if sm.Leader != nil {
+				sr.Leader = sm.Leader.Public
+				for _, server := range sm.Ensemble {
+					if server.Internal != sm.Leader.Internal && isInSync(sm, server, nodes) {
+						sr.InSyncFollowers++
+					}
+				}
+			}
+
+			if sr.Leader == "" {
+				withoutLeader++
+			}
+			if sr.Leader == "" || sr.InSyncFollowers < sr.Replicas-1 {
+				degraded++
+			}
+			report.Shards = append(report.Shards, sr)
+		}
+	}
+
+	sort.Slice(report.Shards, func(i, j int) bool {
+		if report.Shards[i].Namespace != report.Shards[j].Namespace {
+			return report.Shards[i].Namespace < report.Shards[j].Namespace
+		}
+		return report.Shards[i].Shard < report.Shards[j].Shard
+	})
+
+	allShardsHaveLeaders := metav1.Condition{
+		Type:    ConditionAllShardsHaveLeaders,
+		Status:  metav1.ConditionTrue,
+		Reason:  "LeadersElected",
+		Message: "All the shards have a leader",
+	}
+	if withoutLeader > 0 {
+		allShardsHaveLeaders.Status = metav1.ConditionFalse
+		allShardsHaveLeaders.Reason = "ShardsWithoutLeader"
+		allShardsHaveLeaders.Message = fmt.Sprintf("%d shards don't have a leader", withoutLeader)
+	}
+
+	degradedCondition := metav1.Condition{
+		Type:    ConditionDegraded,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AllReplicasInSync",
+		Message: "All the replicas of the shards are available",
+	}
+	if degraded > 0 {
+		degradedCondition.Status = metav1.ConditionTrue
+		degradedCondition.Reason = "ReplicasUnavailable"
+		degradedCondition.Message = fmt.Sprintf("%d shards are missing a leader or have unavailable followers", degraded)
+	}
+
+	report.Conditions = []metav1.Condition{
+		w.withTransitionTime(allShardsHaveLeaders),
+		w.withTransitionTime(degradedCondition),
+	}
+	return report
+}
+
+// The transition time is the one of the last change of the condition status.
+func (w *clusterStatusWriter) withTransitionTime(condition metav1.Condition) metav1.Condition {
+	if w.lastReport != nil {
+		for _, last := range w.lastReport.Conditions {
+			if last.Type == condition.Type && last.Status == condition.Status {
+				condition.LastTransitionTime = last.LastTransitionTime
+				return condition
+			}
+		}
+	}
+
+	condition.LastTransitionTime = metav1.NewTime(w.now().Truncate(time.Second))
+	return condition
+}
+
+func (w *clusterStatusWriter) Close() error {
+	w.cancel()
+	w.wg.Wait()
+	return nil
+}
@@ -0,0 +1,303 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/proto"
+)
+
+type fakeClusterStatusSource struct {
+	sync.Mutex
+	changed     common.ConditionContext
+	status      model.ClusterStatus
+	nodes       map[string]NodeStatus
+	assignments *proto.ShardAssignments
+}
+
+func newFakeClusterStatusSource(status model.ClusterStatus, nodes map[string]NodeStatus) *fakeClusterStatusSource {
+	s := &fakeClusterStatusSource{
+		status:      status,
+		nodes:       nodes,
+		assignments: &proto.ShardAssignments{},
+	}
+	s.changed = common.NewConditionContext(s)
+	return s
+}
+
+func (s *fakeClusterStatusSource) update(status model.ClusterStatus, nodes map[string]NodeStatus) {
+	s.Lock()
+	defer s.Unlock()
+	s.status = status
+	s.nodes = nodes
+	s.assignments = &proto.ShardAssignments{}
+	s.changed.Broadcast()
+}
+
+func (s *fakeClusterStatusSource) WaitForNextUpdate(ctx context.Context, currentValue *proto.ShardAssignments) (*proto.ShardAssignments, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for currentValue == s.assignments {
+		if err := s.changed.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s.assignments, nil
+}
+
+func (s *fakeClusterStatusSource) ClusterStatus() model.ClusterStatus {
+	s.Lock()
+	defer s.Unlock()
+	return *s.status.Clone()
+}
+
+func (s *fakeClusterStatusSource) NodesStatus() map[string]NodeStatus {
+	s.Lock()
+	defer s.Unlock()
+	return s.nodes
+}
+
+var (
+	testSa1 = model.ServerAddress{Public: "s1:6648", Internal: "s1:6649"}
+	testSa2 = model.ServerAddress{Public: "s2:6648", Internal: "s2:6649"}
+	testSa3 = model.ServerAddress{Public: "s3:6648", Internal: "s3:6649"}
+)
+
+func newTestReportedStatus(leader0, leader1 *model.ServerAddress) model.ClusterStatus {
+	ensemble := []model.ServerAddress{testSa1, testSa2, testSa3}
+	return model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			common.DefaultNamespace: {
+				ReplicationFactor: 3,
+				Shards: map[int64]model.ShardMetadata{
+					1: {Status: model.ShardStatusSteadyState, Term: 4, Leader: leader1, Ensemble: ensemble},
+					0: {Status: model.ShardStatusSteadyState, Term: 2, Leader: leader0, Ensemble: ensemble},
+					// Not reported
+					2: {Status: model.ShardStatusDeleting, Term: 1, Leader: &testSa1, Ensemble: ensemble},
+					3: {Status: model.ShardStatusUnknown, Term: -1, Ensemble: ensemble, Split: &model.SplitMetadata{ParentShardId: 0}},
+				},
+			},
+		},
+		ShardIdGenerator: 4,
+	}
+}
+
+func newTestOxiaCluster() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "oxia.streamnative.io/v1alpha1",
+		"kind":       "OxiaCluster",
+		"metadata": map[string]any{
+			"namespace": "ns",
+			"name":      "oxia",
+		},
+		"spec": map[string]any{
+			"replicationFactor": int64(3),
+		},
+	}}
+}
+
+func newFakeDynamicClient(objects ...runtime.Object) *fake.FakeDynamicClient {
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{OxiaClusterResource: "OxiaClusterList"}, objects...)
+}
+
+func getReportedStatus(t *testing.T, client *fake.FakeDynamicClient) *ClusterStatusReport {
+	t.Helper()
+
+	obj, err := client.Resource(OxiaClusterResource).Namespace("ns").Get(context.Background(), "oxia", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	// The rest of the resource is left untouched
+	assert.Equal(t, int64(3), obj.Object["spec"].(map[string]any)["replicationFactor"])
+
+	status, ok := obj.Object["status"]
+	if !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(status)
+	assert.NoError(t, err)
+	report := &ClusterStatusReport{}
+	assert.NoError(t, json.Unmarshal(data, report))
+	return report
+}
+
+func countPatches(client *fake.FakeDynamicClient) int {
+	count := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" && action.GetSubresource() == "status" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestClusterStatusWriter(t *testing.T) {
+	client := newFakeDynamicClient(newTestOxiaCluster())
+	source := newFakeClusterStatusSource(newTestReportedStatus(&testSa1, &testSa2),
+		map[string]NodeStatus{testSa1.Internal: Running, testSa2.Internal: Running, testSa3.Internal: Running})
+
+	w := newClusterStatusWriter(client, "ns", "oxia", source, 10*time.Millisecond, time.Hour)
+
+	assert.Eventually(t, func() bool {
+		return countPatches(client) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	report := getReportedStatus(t, client)
+	assert.Equal(t, []ShardStatusReport{
+		{Namespace: common.DefaultNamespace, Shard: 0, Status: "SteadyState", Term: 2, Leader: testSa1.Public, Replicas: 3, InSyncFollowers: 2},
+		{Namespace: common.DefaultNamespace, Shard: 1, Status: "SteadyState", Term: 4, Leader: testSa2.Public, Replicas: 3, InSyncFollowers: 2},
+	}, report.Shards)
+	assert.Len(t, report.Conditions, 2)
+	assert.Equal(t, ConditionAllShardsHaveLeaders, report.Conditions[0].Type)
+	assert.Equal(t, metav1.ConditionTrue, report.Conditions[0].Status)
+	assert.Equal(t, ConditionDegraded, report.Conditions[1].Type)
+	assert.Equal(t, metav1.ConditionFalse, report.Conditions[1].Status)
+
+	// A follower is down and a shard lost its leader
+	source.update(newTestReportedStatus(&testSa1, nil),
+		map[string]NodeStatus{testSa1.Internal: Running, testSa2.Internal: NotRunning, testSa3.Internal: Running})
+
+	assert.Eventually(t, func() bool {
+		return countPatches(client) == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	report = getReportedStatus(t, client)
+	assert.Equal(t, []ShardStatusReport{
+		{Namespace: common.DefaultNamespace, Shard: 0, Status: "SteadyState", Term: 2, Leader: testSa1.Public, Replicas: 3, InSyncFollowers: 1},
+		{Namespace: common.DefaultNamespace, Shard: 1, Status: "SteadyState", Term: 4, Replicas: 3},
+	}, report.Shards)
+	assert.Equal(t, metav1.ConditionFalse, report.Conditions[0].Status)
+	assert.Equal(t, "ShardsWithoutLeader", report.Conditions[0].Reason)
+	assert.Equal(t, metav1.ConditionTrue, report.Conditions[1].Status)
+	assert.Equal(t, "ReplicasUnavailable", report.Conditions[1].Reason)
+
+	// A change that doesn't affect the status is not written
+	source.update(newTestReportedStatus(&testSa1, nil),
+		map[string]NodeStatus{testSa1.Internal: Running, testSa2.Internal: NotRunning, testSa3.Internal: Running})
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, countPatches(client))
+
+	assert.NoError(t, w.Close())
+}
+
+func TestClusterStatusWriter_Debounce(t *testing.T) {
+	client := newFakeDynamicClient(newTestOxiaCluster())
+	nodes := map[string]NodeStatus{testSa1.Internal: Running, testSa2.Internal: Running, testSa3.Internal: Running}
+	source := newFakeClusterStatusSource(newTestReportedStatus(&testSa1, &testSa1), nodes)
+
+	w := newClusterStatusWriter(client, "ns", "oxia", source, 500*time.Millisecond, time.Hour)
+
+	// A burst of changes, like the leader elections after a node failure
+	for _, leader := range []*model.ServerAddress{nil, &testSa2, nil, &testSa3} {
+		source.update(newTestReportedStatus(&testSa1, leader), nodes)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Eventually(t, func() bool {
+		return countPatches(client) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	report := getReportedStatus(t, client)
+	assert.Equal(t, testSa3.Public, report.Shards[1].Leader)
+
+	time.Sleep(1 * time.Second)
+	assert.Equal(t, 1, countPatches(client))
+
+	assert.NoError(t, w.Close())
+}
+
+func TestClusterStatusWriter_RetryOnConflict(t *testing.T) {
+	client := newFakeDynamicClient(newTestOxiaCluster())
+	conflicts := 2
+	client.PrependReactor("patch", "oxiaclusters", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts == 0 {
+			return false, nil, nil
+		}
+		conflicts--
+		return true, nil, k8serrors.NewConflict(OxiaClusterResource.GroupResource(), "oxia", nil)
+	})
+
+	source := newFakeClusterStatusSource(newTestReportedStatus(&testSa1, &testSa2),
+		map[string]NodeStatus{testSa1.Internal: Running, testSa2.Internal: Running, testSa3.Internal: Running})
+	w := newClusterStatusWriter(client, "ns", "oxia", source, 10*time.Millisecond, time.Hour)
+
+	assert.Eventually(t, func() bool {
+		return countPatches(client) == 3
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, w.Close())
+
+	report := getReportedStatus(t, client)
+	assert.Len(t, report.Shards, 2)
+}
+
+func TestClusterStatusWriter_MissingResource(t *testing.T) {
+	client := newFakeDynamicClient()
+	source := newFakeClusterStatusSource(newTestReportedStatus(&testSa1, &testSa2), map[string]NodeStatus{})
+
+	w := newClusterStatusWriter(client, "ns", "oxia", source, 10*time.Millisecond, time.Hour)
+
+	assert.Eventually(t, func() bool {
+		return countPatches(client) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// The writer is stopped, the next changes are not written
+	source.update(newTestReportedStatus(&testSa1, nil), map[string]NodeStatus{})
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, countPatches(client))
+
+	assert.NoError(t, w.Close())
+}
+
+func TestClusterStatusWriter_TransitionTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := newFakeClusterStatusSource(newTestReportedStatus(&testSa1, &testSa2),
+		map[string]NodeStatus{testSa1.Internal: Running, testSa2.Internal: Running, testSa3.Internal: Running})
+	w := &clusterStatusWriter{
+		source: source,
+		now:    func() time.Time { return now },
+	}
+
+	w.lastReport = w.report()
+	assert.Equal(t, now, w.lastReport.Conditions[0].LastTransitionTime.Time)
+	assert.Equal(t, now, w.lastReport.Conditions[1].LastTransitionTime.Time)
+
+	// Only the condition that changed gets a new transition time
+	now = now.Add(time.Minute)
+	source.update(newTestReportedStatus(&testSa1, &testSa2),
+		map[string]NodeStatus{testSa1.Internal: Running, testSa2.Internal: Running, testSa3.Internal: NotRunning})
+	report := w.report()
+	assert.Equal(t, now.Add(-time.Minute), report.Conditions[0].LastTransitionTime.Time)
+	assert.Equal(t, metav1.ConditionTrue, report.Conditions[1].Status)
+	assert.Equal(t, now, report.Conditions[1].LastTransitionTime.Time)
+}
@@ -19,15 +19,6 @@ import (
 	"github.com/streamnative/oxia/coordinator/model"
 )
 
-func getServers(servers []model.ServerAddress, startIdx uint32, count uint32) []model.ServerAddress {
-	n := len(servers)
-	res := make([]model.ServerAddress, count)
-	for i := uint32(0); i < count; i++ {
-		res[i] = servers[int(startIdx+i)%n]
-	}
-	return res
-}
-
 func findNamespaceConfig(config *model.ClusterConfig, ns string) *model.NamespaceConfig {
 	for _, cns := range config.Namespaces {
 		if cns.Name == ns {
@@ -38,7 +29,7 @@ func findNamespaceConfig(config *model.ClusterConfig, ns string) *model.Namespac
 	return nil
 }
 
-func applyClusterChanges(config *model.ClusterConfig, currentStatus *model.ClusterStatus) (
+func applyClusterChanges(config *model.ClusterConfig, currentStatus *model.ClusterStatus, constraints placementConstraints) (
 	newStatus *model.ClusterStatus,
 	shardsToAdd map[int64]string,
 	shardsToDelete []int64) {
@@ -54,6 +45,16 @@ func applyClusterChanges(config *model.ClusterConfig, currentStatus *model.Clust
 		newStatus.Namespaces[k] = v.Clone()
 	}
 
+	// The new shards are placed preferably on the servers with fewer replicas
+	replicasPerServer := map[string]int{}
+	for _, nss := range currentStatus.Namespaces {
+		for _, shard := range nss.Shards {
+			for _, server := range shard.Ensemble {
+				replicasPerServer[server.Internal]++
+			}
+		}
+	}
+
 	// Check for new namespaces
 	for _, nc := range config.Namespaces {
 		nss, existing := currentStatus.Namespaces[nc.Name]
@@ -71,7 +72,7 @@ func applyClusterChanges(config *model.ClusterConfig, currentStatus *model.Clust
 				Status:   model.ShardStatusUnknown,
 				Term:     -1,
 				Leader:   nil,
-				Ensemble: getServers(config.Servers, newStatus.ServerIdx, nc.ReplicationFactor),
+				Ensemble: constraints.selectEnsemble(config.Servers, newStatus.ServerIdx, nc.ReplicationFactor, replicasPerServer),
 				Int32HashRange: model.Int32HashRange{
 					Min: shard.Min,
 					Max: shard.Max,
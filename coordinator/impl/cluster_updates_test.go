@@ -21,6 +21,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/coordinator/model"
 )
 
@@ -45,7 +46,7 @@ func TestClientUpdates_ClusterInit(t *testing.T) {
 			ReplicationFactor: 3,
 		}},
 		Servers: []model.ServerAddress{s1, s2, s3, s4},
-	}, model.NewClusterStatus())
+	}, model.NewClusterStatus(), placementConstraints{})
 
 	assert.Equal(t, &model.ClusterStatus{
 		Namespaces: map[string]model.NamespaceStatus{
@@ -130,7 +131,7 @@ func TestClientUpdates_NamespaceAdded(t *testing.T) {
 			},
 		},
 	}, ShardIdGenerator: 1,
-		ServerIdx: 3})
+		ServerIdx: 3}, placementConstraints{})
 
 	assert.Equal(t, &model.ClusterStatus{
 		Namespaces: map[string]model.NamespaceStatus{
@@ -238,7 +239,7 @@ func TestClientUpdates_NamespaceRemoved(t *testing.T) {
 			},
 		},
 		ShardIdGenerator: 3,
-		ServerIdx:        1})
+		ServerIdx:        1}, placementConstraints{})
 
 	assert.Equal(t, &model.ClusterStatus{
 		Namespaces: map[string]model.NamespaceStatus{
@@ -294,3 +295,147 @@ func TestClientUpdates_NamespaceRemoved(t *testing.T) {
 	assert.Equal(t, []int64{1, 2}, shardsToRemove)
 	assert.Equal(t, map[int64]string{}, shardsAdded)
 }
+
+// assertEnsembles checks the ensemble selected from every position of the
+// rotation. The replicas must always be on distinct servers.
+func assertEnsembles(t *testing.T, constraints placementConstraints, servers []model.ServerAddress,
+	count uint32, check func(ensemble []model.ServerAddress)) {
+	t.Helper()
+
+	for startIdx := range servers {
+		ensemble := constraints.selectEnsemble(servers, uint32(startIdx), count, map[string]int{})
+		unique := common.NewSet[string]()
+		for _, s := range ensemble {
+			unique.Add(s.Internal)
+		}
+		assert.Len(t, ensemble, int(count))
+		assert.Equal(t, len(ensemble), unique.Count(), "start %d: %v", startIdx, ensemble)
+		check(ensemble)
+	}
+}
+
+func countPerZone(zones map[string]string, ensemble []model.ServerAddress) map[string]int {
+	res := map[string]int{}
+	for _, s := range ensemble {
+		res[zones[s.Internal]]++
+	}
+	return res
+}
+
+func TestSelectEnsemble_ThreeZones(t *testing.T) {
+	// The servers of the same zone are next to each other in the rotation
+	servers := []model.ServerAddress{s1, s2, s3, s4, s5, s6}
+	zones := map[string]string{
+		s1.Internal: "zone-a", s2.Internal: "zone-a",
+		s3.Internal: "zone-b", s4.Internal: "zone-b",
+		s5.Internal: "zone-c", s6.Internal: "zone-c",
+	}
+
+	assertEnsembles(t, placementConstraints{zones: zones}, servers, 3, func(ensemble []model.ServerAddress) {
+		assert.Len(t, countPerZone(zones, ensemble), 3, "%v", ensemble)
+	})
+}
+
+func TestSelectEnsemble_TwoZones(t *testing.T) {
+	servers := []model.ServerAddress{s1, s2, s3, s4, s5, s6}
+	zones := map[string]string{
+		s1.Internal: "zone-a", s2.Internal: "zone-a", s3.Internal: "zone-a",
+		s4.Internal: "zone-b", s5.Internal: "zone-b", s6.Internal: "zone-b",
+	}
+
+	assertEnsembles(t, placementConstraints{zones: zones}, servers, 3, func(ensemble []model.ServerAddress) {
+		perZone := countPerZone(zones, ensemble)
+		assert.Len(t, perZone, 2, "%v", ensemble)
+		for _, count := range perZone {
+			assert.LessOrEqual(t, count, 2, "%v", ensemble)
+		}
+	})
+}
+
+func TestSelectEnsemble_NoLocality(t *testing.T) {
+	servers := []model.ServerAddress{s1, s2, s3, s4, s5}
+
+	// Without any locality and with an even load, the servers are picked
+	// in round-robin order
+	startIdx := 0
+	assertEnsembles(t, placementConstraints{}, servers, 3, func(ensemble []model.ServerAddress) {
+		assert.Equal(t, []model.ServerAddress{
+			servers[startIdx%5], servers[(startIdx+1)%5], servers[(startIdx+2)%5],
+		}, ensemble)
+		startIdx++
+	})
+
+	// The servers are never picked twice, even if there are fewer servers
+	// than replicas
+	assert.Equal(t, []model.ServerAddress{s2, s1}, placementConstraints{}.selectEnsemble(
+		[]model.ServerAddress{s1, s2}, 1, 3, map[string]int{}))
+}
+
+func TestSelectEnsemble_Hosts(t *testing.T) {
+	servers := []model.ServerAddress{s1, s2, s3, s4}
+	constraints := placementConstraints{
+		hosts: map[string]string{
+			s1.Internal: "host-1", s2.Internal: "host-1",
+			s3.Internal: "host-2", s4.Internal: "host-3",
+		},
+	}
+
+	assertEnsembles(t, constraints, servers, 3, func(ensemble []model.ServerAddress) {
+		hosts := common.NewSet[string]()
+		for _, s := range ensemble {
+			hosts.Add(constraints.host(s))
+		}
+		assert.Equal(t, 3, hosts.Count(), "%v", ensemble)
+	})
+
+	assert.Equal(t, []model.ServerAddress{s1, s3, s4}, constraints.selectEnsemble(servers, 0, 3, map[string]int{}))
+}
+
+func TestNewPlacementConstraints(t *testing.T) {
+	config := model.ClusterConfig{
+		Servers:     []model.ServerAddress{s1, s2, s3},
+		ServerZones: map[string]string{s1.Internal: "zone-x"},
+	}
+	localities := map[string]model.ServerLocality{
+		s1.Internal: {Zone: "zone-a", Host: "host-1"},
+		s2.Internal: {Zone: "zone-b", Host: "host-2"},
+	}
+
+	// The reported zones are ignored unless the placement is zone-aware
+	c := newPlacementConstraints(config, localities, false, nil)
+	assert.Equal(t, map[string]string{s1.Internal: "zone-x"}, c.zones)
+	assert.Equal(t, "host-2", c.host(s2))
+	assert.Equal(t, s3.Internal, c.host(s3))
+
+	c = newPlacementConstraints(config, localities, true, nil)
+	assert.Equal(t, map[string]string{s1.Internal: "zone-x", s2.Internal: "zone-b"}, c.zones)
+	assert.Equal(t, "", c.zone(s3))
+}
+
+func TestClientUpdates_ZoneAwareInit(t *testing.T) {
+	zones := map[string]string{
+		s1.Internal: "zone-a", s2.Internal: "zone-a",
+		s3.Internal: "zone-b", s4.Internal: "zone-b",
+		s5.Internal: "zone-c", s6.Internal: "zone-c",
+	}
+
+	newStatus, _, _ := applyClusterChanges(&model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              "ns-1",
+			InitialShardCount: 6,
+			ReplicationFactor: 3,
+		}},
+		Servers: []model.ServerAddress{s1, s2, s3, s4, s5, s6},
+	}, model.NewClusterStatus(), placementConstraints{zones: zones})
+
+	replicas := map[model.ServerAddress]int{}
+	for _, shard := range newStatus.Namespaces["ns-1"].Shards {
+		assert.Len(t, countPerZone(zones, shard.Ensemble), 3, "%v", shard.Ensemble)
+		for _, s := range shard.Ensemble {
+			replicas[s]++
+		}
+	}
+	for _, count := range replicas {
+		assert.Equal(t, 3, count)
+	}
+}
@@ -16,23 +16,27 @@ package impl
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
 	"reflect"
 	"sync"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 	pb "google.golang.org/protobuf/proto"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/coordinator/model"
 	"github.com/streamnative/oxia/proto"
 )
 
 var (
 	ErrNamespaceNotFound = errors.New("namespace not found")
+	ErrTermNotIncreasing = errors.New("the new term is not greater than the persisted term")
 )
 
 type ShardAssignmentsProvider interface {
@@ -41,6 +45,10 @@ type ShardAssignmentsProvider interface {
 
 type NodeAvailabilityListener interface {
 	NodeBecameUnavailable(node model.ServerAddress)
+
+	// NodeEnteredMaintenance is called when a node asks for the leaderships
+	// it holds to be moved to other nodes
+	NodeEnteredMaintenance(node model.ServerAddress)
 }
 
 type Coordinator interface {
@@ -48,13 +56,51 @@ type Coordinator interface {
 
 	ShardAssignmentsProvider
 
+	// InitiateLeaderElection persists the term of the new election, before
+	// any of the nodes is fenced with it, so that the term is never reused
+	// after a restart of the coordinator
 	InitiateLeaderElection(namespace string, shard int64, metadata model.ShardMetadata) error
 	ElectedLeader(namespace string, shard int64, metadata model.ShardMetadata) error
 	ShardDeleted(namespace string, shard int64) error
 
+	// SplitShard starts the split of a shard and returns the ids of the two
+	// shards that are going to replace it.
+	SplitShard(namespace string, shard int64) (leftChild int64, rightChild int64, err error)
+
 	NodeAvailabilityListener
 
 	ClusterStatus() model.ClusterStatus
+
+	// NodesStatus returns the status of the nodes in the cluster, keyed by
+	// their internal address.
+	NodesStatus() map[string]NodeStatus
+
+	// RebalancePlan returns the moves that the coordinator would apply to
+	// rebalance the cluster, without applying them.
+	RebalancePlan() RebalancePlan
+
+	// ListShards returns all the shards of the cluster, with the members
+	// of their ensembles.
+	ListShards() []ShardInfo
+
+	// ListServers returns the servers of the cluster, with their status.
+	ListServers() []ServerInfo
+
+	// TriggerElection forces the election of a new leader for the shard.
+	TriggerElection(shard int64) error
+
+	// TransferLeader moves the leadership of the shard to the member of the
+	// ensemble with the given internal address. The member must be in sync.
+	TransferLeader(shard int64, newLeader string) error
+
+	// DrainServer moves all the leaderships away from the server with the
+	// given internal address and, if removeReplicas is set, all its replicas
+	// too. The progress is reported after each shard.
+	DrainServer(ctx context.Context, server string, removeReplicas bool, progress func(DrainProgress)) error
+
+	// CompactShard compacts the database of the shard on each running member
+	// of its ensemble, one at a time, and returns the outcome on each member.
+	CompactShard(ctx context.Context, shard int64) ([]ShardCompaction, error)
 }
 
 type coordinator struct {
@@ -72,13 +118,27 @@ type coordinator struct {
 	// Draining nodes are nodes that were removed from the
 	// nodes list. We keep sending them assignments updates
 	// because they might be still reachable to clients.
-	drainingNodes   map[string]NodeController
+	drainingNodes map[string]NodeController
+
+	// Drained nodes were put in maintenance by a drain, and they don't take
+	// new replicas or leaderships
+	drainedNodes map[string]bool
+
 	clusterStatus   *model.ClusterStatus
 	assignments     *proto.ShardAssignments
 	metadataVersion Version
 	rpc             RpcProvider
+	failureDetector FailureDetectorOptions
+	rebalance       RebalanceOptions
 	log             *slog.Logger
 
+	// The number of times the shard assignments have changed
+	assignmentsGeneration int64
+
+	assignmentsGenerationGauge metrics.Gauge
+	shardsWithoutLeaderGauge   metrics.Gauge
+	nodesDownGauge             metrics.Gauge
+
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -86,21 +146,26 @@ type coordinator struct {
 func NewCoordinator(metadataProvider MetadataProvider,
 	clusterConfigProvider func() (model.ClusterConfig, error),
 	clusterConfigNotificationsCh chan any,
-	rpc RpcProvider) (Coordinator, error) {
+	rpc RpcProvider,
+	failureDetector FailureDetectorOptions,
+	rebalance RebalanceOptions) (Coordinator, error) {
 	initialClusterConf, err := clusterConfigProvider()
 	if err != nil {
 		return nil, err
 	}
 
 	c := &coordinator{
-		MetadataProvider:      metadataProvider,
+		MetadataProvider:      newMetadataProviderWithMetrics(metadataProvider),
 		clusterConfigProvider: clusterConfigProvider,
 		clusterConfigChangeCh: clusterConfigNotificationsCh,
 		ClusterConfig:         initialClusterConf,
 		shardControllers:      make(map[int64]ShardController),
 		nodeControllers:       make(map[string]NodeController),
 		drainingNodes:         make(map[string]NodeController),
+		drainedNodes:          make(map[string]bool),
 		rpc:                   rpc,
+		failureDetector:       failureDetector.withDefaults(),
+		rebalance:             rebalance.withDefaults(),
 		log: slog.With(
 			slog.String("component", "coordinator"),
 		),
@@ -115,8 +180,16 @@ func NewCoordinator(metadataProvider MetadataProvider,
 		return nil, err
 	}
 
+	// The status is checked before any of the shards is fenced with a new
+	// term, so that a corrupted metadata doesn't get acted upon
+	if c.clusterStatus != nil {
+		if err = c.clusterStatus.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid cluster status in the metadata")
+		}
+	}
+
 	for _, sa := range c.ClusterConfig.Servers {
-		c.nodeControllers[sa.Internal] = NewNodeController(sa, c, c, c.rpc)
+		c.nodeControllers[sa.Internal] = NewNodeController(sa, c, c, c.rpc, c.failureDetector)
 	}
 
 	if c.clusterStatus == nil {
@@ -136,6 +209,7 @@ func NewCoordinator(metadataProvider MetadataProvider,
 	}
 
 	c.initialShardController()
+	c.registerMetrics()
 
 	go common.DoWithLabels(
 		c.ctx,
@@ -149,8 +223,15 @@ func NewCoordinator(metadataProvider MetadataProvider,
 }
 
 func (c *coordinator) allUnavailableNodes() []string {
-	nodes := []string{}
+	c.Lock()
+	ctrls := make(map[string]NodeController, len(c.nodeControllers))
 	for nodeName, nc := range c.nodeControllers {
+		ctrls[nodeName] = nc
+	}
+	c.Unlock()
+
+	nodes := []string{}
+	for nodeName, nc := range ctrls {
 		if nc.Status() != Running {
 			nodes = append(nodes, nodeName)
 		}
@@ -159,10 +240,37 @@ func (c *coordinator) allUnavailableNodes() []string {
 	return nodes
 }
 
+// nodesLocality returns the locality reported by the nodes, keyed by their
+// internal address. The nodes whose locality is not known yet are omitted.
+func (c *coordinator) nodesLocality() map[string]model.ServerLocality {
+	c.Lock()
+	ctrls := make(map[string]NodeController, len(c.nodeControllers))
+	for nodeName, nc := range c.nodeControllers {
+		ctrls[nodeName] = nc
+	}
+	c.Unlock()
+
+	localities := map[string]model.ServerLocality{}
+	for nodeName, nc := range ctrls {
+		if locality := nc.Locality(); locality != nil {
+			localities[nodeName] = *locality
+		}
+	}
+	return localities
+}
+
 func (c *coordinator) initialShardController() {
 	for ns, shards := range c.clusterStatus.Namespaces {
 		for shard, shardMetadata := range shards.Shards {
-			c.shardControllers[shard] = NewShardController(ns, shard, shardMetadata, c.rpc, c)
+			if isSplitChild(shard, shardMetadata) {
+				// The controllers of the children are started by the split
+				continue
+			}
+
+			c.shardControllers[shard] = NewShardController(ns, shard, shardMetadata, c.rpc, c, c.failureDetector.ElectionBackoff)
+			if shardMetadata.Split != nil {
+				c.startShardSplit(ns, shard)
+			}
 		}
 	}
 }
@@ -174,8 +282,9 @@ func (c *coordinator) waitForAllNodesToBeAvailable() {
 		case <-time.After(1 * time.Second):
 			c.log.Info("Start to check unavailable nodes")
 
+			// The locality of the nodes is needed to place the replicas
 			unavailableNodes := c.allUnavailableNodes()
-			if len(unavailableNodes) == 0 {
+			if len(unavailableNodes) == 0 && len(c.nodesLocality()) == len(c.ClusterConfig.Servers) {
 				c.log.Info("All nodes are now available")
 				return
 			}
@@ -198,7 +307,8 @@ func (c *coordinator) initialAssignment() error {
 		slog.Any("clusterConfig", c.ClusterConfig),
 	)
 
-	clusterStatus, _, _ := applyClusterChanges(&c.ClusterConfig, model.NewClusterStatus())
+	constraints := newPlacementConstraints(c.ClusterConfig, c.nodesLocality(), c.rebalance.ZoneAware, nil)
+	clusterStatus, _, _ := applyClusterChanges(&c.ClusterConfig, model.NewClusterStatus(), constraints)
 
 	var err error
 	if c.metadataVersion, err = c.MetadataProvider.Store(clusterStatus, MetadataNotExists); err != nil {
@@ -216,7 +326,8 @@ func (c *coordinator) applyNewClusterConfig() error {
 		slog.Any("metadataVersion", c.metadataVersion),
 	)
 
-	clusterStatus, shardsToAdd, shardsToDelete := applyClusterChanges(&c.ClusterConfig, c.clusterStatus)
+	constraints := newPlacementConstraints(c.ClusterConfig, c.nodesLocality(), c.rebalance.ZoneAware, nil)
+	clusterStatus, shardsToAdd, shardsToDelete := applyClusterChanges(&c.ClusterConfig, c.clusterStatus, constraints)
 
 	if len(shardsToAdd) > 0 || len(shardsToDelete) > 0 {
 		var err error
@@ -230,7 +341,47 @@ func (c *coordinator) applyNewClusterConfig() error {
 	return nil
 }
 
+func (c *coordinator) registerMetrics() {
+	c.assignmentsGenerationGauge = metrics.NewGauge("oxia_coordinator_assignments_generation",
+		"The number of times the shard assignments have changed", "count", nil, func() int64 {
+			c.Lock()
+			defer c.Unlock()
+			return c.assignmentsGeneration
+		})
+	c.shardsWithoutLeaderGauge = metrics.NewGauge("oxia_coordinator_shards_without_leader",
+		"The number of shards that have no leader", "count", nil, func() int64 {
+			c.Lock()
+			defer c.Unlock()
+			return int64(c.shardsWithoutLeader())
+		})
+	c.nodesDownGauge = metrics.NewGauge("oxia_coordinator_nodes_down",
+		"The number of nodes that are considered down by the coordinator", "count", nil, func() int64 {
+			return int64(len(c.allUnavailableNodes()))
+		})
+}
+
+// This is called while already holding the lock on the coordinator.
+func (c *coordinator) shardsWithoutLeader() int {
+	count := 0
+	for _, nsa := range c.assignments.GetNamespaces() {
+		for _, a := range nsa.Assignments {
+			if a.Leader == "" {
+				count++
+			}
+		}
+	}
+	return count
+}
+
 func (c *coordinator) Close() error {
+	c.cancel()
+
+	if c.assignmentsGenerationGauge != nil {
+		c.assignmentsGenerationGauge.Unregister()
+		c.shardsWithoutLeaderGauge.Unregister()
+		c.nodesDownGauge.Unregister()
+	}
+
 	var err error
 
 	for _, sc := range c.shardControllers {
@@ -267,6 +418,22 @@ func (c *coordinator) NodeBecameUnavailable(node model.ServerAddress) {
 	}
 }
 
+func (c *coordinator) NodeEnteredMaintenance(node model.ServerAddress) {
+	c.Lock()
+	ctrls := make(map[int64]ShardController)
+	for k, sc := range c.shardControllers {
+		ctrls[k] = sc
+	}
+	c.Unlock()
+
+	// A new leader election will only be triggered in the shards where the
+	// node is the current leader. The node in maintenance will not be
+	// selected again, unless it's the only one with all the entries.
+	for _, sc := range ctrls {
+		sc.HandleNodeFailure(node)
+	}
+}
+
 func (c *coordinator) WaitForNextUpdate(ctx context.Context, currentValue *proto.ShardAssignments) (*proto.ShardAssignments, error) {
 	c.Lock()
 	defer c.Unlock()
@@ -291,14 +458,21 @@ func (c *coordinator) InitiateLeaderElection(namespace string, shard int64, meta
 		return ErrNamespaceNotFound
 	}
 
-	ns.Shards[shard] = metadata
+	if persisted := ns.Shards[shard].Term; metadata.Term <= persisted {
+		return errors.Wrapf(ErrTermNotIncreasing, "shard %d term %d, persisted term %d", shard, metadata.Term, persisted)
+	}
+
+	ns.Shards[shard] = mergeShardMetadata(ns.Shards[shard], metadata)
 
 	newMetadataVersion, err := c.MetadataProvider.Store(cs, c.metadataVersion)
 	if err != nil {
 		return err
 	}
 
+	// The status is updated along with the metadata, otherwise the next
+	// update of another shard would store the previous term again
 	c.metadataVersion = newMetadataVersion
+	c.clusterStatus = cs
 	return nil
 }
 
@@ -312,7 +486,7 @@ func (c *coordinator) ElectedLeader(namespace string, shard int64, metadata mode
 		return ErrNamespaceNotFound
 	}
 
-	ns.Shards[shard] = metadata
+	ns.Shards[shard] = mergeShardMetadata(ns.Shards[shard], metadata)
 
 	newMetadataVersion, err := c.MetadataProvider.Store(cs, c.metadataVersion)
 	if err != nil {
@@ -355,6 +529,7 @@ func (c *coordinator) ShardDeleted(namespace string, shard int64) error {
 
 // This is called while already holding the lock on the coordinator.
 func (c *coordinator) computeNewAssignments() {
+	previous := c.assignments
 	c.assignments = &proto.ShardAssignments{
 		Namespaces: map[string]*proto.NamespaceShardsAssignment{},
 	}
@@ -367,15 +542,27 @@ func (c *coordinator) computeNewAssignments() {
 		}
 
 		for shard, a := range ns.Shards {
+			if isSplitChild(shard, a) {
+				continue
+			}
+
 			var leader string
 			if a.Leader != nil {
 				leader = a.Leader.Public
 			}
+			followers := make([]string, 0, len(a.Ensemble))
+			for _, server := range a.Ensemble {
+				if server.Public != leader {
+					followers = append(followers, server.Public)
+				}
+			}
 			if a.Status != model.ShardStatusDeleting {
 				nsAssignments.Assignments = append(nsAssignments.Assignments,
 					&proto.ShardAssignment{
-						Shard:  shard,
-						Leader: leader,
+						Shard:     shard,
+						Leader:    leader,
+						Followers: followers,
+						Term:      a.Term,
 						ShardBoundaries: &proto.ShardAssignment_Int32HashRange{
 							Int32HashRange: &proto.Int32HashRange{
 								MinHashInclusive: a.Int32HashRange.Min,
@@ -390,6 +577,9 @@ func (c *coordinator) computeNewAssignments() {
 		c.assignments.Namespaces[name] = nsAssignments
 	}
 
+	if !pb.Equal(previous, c.assignments) {
+		c.assignmentsGeneration++
+	}
 	c.assignmentsChanged.Broadcast()
 }
 
@@ -399,6 +589,23 @@ func (c *coordinator) ClusterStatus() model.ClusterStatus {
 	return *c.clusterStatus.Clone()
 }
 
+func (c *coordinator) NodesStatus() map[string]NodeStatus {
+	// The node controllers call the coordinator while holding their lock,
+	// so their status is read after releasing the coordinator lock
+	c.Lock()
+	ctrls := make(map[string]NodeController, len(c.nodeControllers))
+	for addr, nc := range c.nodeControllers {
+		ctrls[addr] = nc
+	}
+	c.Unlock()
+
+	res := make(map[string]NodeStatus, len(ctrls))
+	for addr, nc := range ctrls {
+		res[addr] = nc.Status()
+	}
+	return res
+}
+
 func (c *coordinator) waitForExternalEvents() {
 	for {
 		select {
@@ -425,6 +632,9 @@ func (c *coordinator) waitForExternalEvents() {
 }
 
 func (c *coordinator) handleClusterConfigUpdated() error {
+	// The node controllers must not be called while holding the lock
+	localities := c.nodesLocality()
+
 	c.Lock()
 	defer c.Unlock()
 
@@ -447,11 +657,12 @@ func (c *coordinator) handleClusterConfigUpdated() error {
 
 	c.checkClusterNodeChanges(newClusterConfig)
 
-	clusterStatus, shardsToAdd, shardsToDelete := applyClusterChanges(&newClusterConfig, c.clusterStatus)
+	constraints := newPlacementConstraints(newClusterConfig, localities, c.rebalance.ZoneAware, nil)
+	clusterStatus, shardsToAdd, shardsToDelete := applyClusterChanges(&newClusterConfig, c.clusterStatus, constraints)
 
 	for shard, namespace := range shardsToAdd {
 		shardMetadata := clusterStatus.Namespaces[namespace].Shards[shard]
-		c.shardControllers[shard] = NewShardController(namespace, shard, shardMetadata, c.rpc, c)
+		c.shardControllers[shard] = NewShardController(namespace, shard, shardMetadata, c.rpc, c, c.failureDetector.ElectionBackoff)
 		slog.Info(
 			"Added new shard",
 			slog.Int64("shard", shard),
@@ -474,38 +685,190 @@ func (c *coordinator) handleClusterConfigUpdated() error {
 	return nil
 }
 
-//nolint:unparam
-func (c *coordinator) rebalanceCluster() error {
+func (c *coordinator) RebalancePlan() RebalancePlan {
+	constraints := c.currentPlacementConstraints()
+
 	c.Lock()
-	actions := rebalanceCluster(c.ClusterConfig.Servers, c.clusterStatus)
+	defer c.Unlock()
+	return planRebalance(c.ClusterConfig.Servers, c.clusterStatus, constraints)
+}
+
+// currentPlacementConstraints returns the constraints for the replicas and
+// the leaderships that are moved. They are not placed on the nodes that are
+// down, in maintenance or drained.
+func (c *coordinator) currentPlacementConstraints() placementConstraints {
+	c.Lock()
+	ctrls := make(map[string]NodeController, len(c.nodeControllers))
+	for addr, nc := range c.nodeControllers {
+		ctrls[addr] = nc
+	}
 	c.Unlock()
 
-	for _, swapAction := range actions {
+	unavailable := common.NewSet[string]()
+	localities := map[string]model.ServerLocality{}
+	for addr, nc := range ctrls {
+		if nc.Status() != Running || nc.InMaintenance() {
+			unavailable.Add(addr)
+		}
+		if locality := nc.Locality(); locality != nil {
+			localities[addr] = *locality
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for addr := range c.drainedNodes {
+		unavailable.Add(addr)
+	}
+	return newPlacementConstraints(c.ClusterConfig, localities, c.rebalance.ZoneAware, unavailable)
+}
+
+//nolint:unparam
+func (c *coordinator) rebalanceCluster() error {
+	plan := c.RebalancePlan()
+	if len(plan.Moves) == 0 && len(plan.LeaderTransfers) == 0 {
+		return nil
+	}
+
+	c.log.Info(
+		"Rebalancing the cluster",
+		slog.Any("plan", plan),
+	)
+
+	// The moves of the same shard are applied in order, while the moves of
+	// different shards are applied concurrently, up to the configured limit
+	var shards []int64
+	movesPerShard := map[int64][]SwapNodeAction{}
+	for _, m := range plan.Moves {
+		if _, ok := movesPerShard[m.Shard]; !ok {
+			shards = append(shards, m.Shard)
+		}
+		movesPerShard[m.Shard] = append(movesPerShard[m.Shard], m)
+	}
+
+	c.forEachShard(shards, func(sc ShardController, shard int64) {
+		for _, m := range movesPerShard[shard] {
+			if err := c.moveReplica(sc, m); err != nil {
+				c.log.Warn(
+					"Failed to move replica",
+					slog.Any("error", err),
+					slog.Any("swap-action", m),
+				)
+				return
+			}
+		}
+	})
+
+	shards = nil
+	transfers := map[int64]TransferLeadershipAction{}
+	for _, t := range plan.LeaderTransfers {
+		shards = append(shards, t.Shard)
+		transfers[t.Shard] = t
+	}
+
+	c.forEachShard(shards, func(sc ShardController, shard int64) {
+		t := transfers[shard]
 		c.log.Info(
-			"Applying swap action",
-			slog.Any("swap-action", swapAction),
+			"Applying transfer leadership action",
+			slog.Any("transfer-leadership-action", t),
 		)
+		if err := sc.TransferLeadership(t.To); err != nil {
+			c.log.Warn(
+				"Failed to transfer leadership",
+				slog.Any("error", err),
+				slog.Any("transfer-leadership-action", t),
+			)
+		}
+	})
+
+	return nil
+}
+
+// forEachShard calls the function on the controllers of the shards, with at
+// most MaxConcurrentMoves concurrent calls, and waits for all of them.
+func (c *coordinator) forEachShard(shards []int64, f func(sc ShardController, shard int64)) {
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, c.rebalance.MaxConcurrentMoves)
 
+	for _, shard := range shards {
 		c.Lock()
-		sc, ok := c.shardControllers[swapAction.Shard]
+		sc, ok := c.shardControllers[shard]
 		c.Unlock()
 		if !ok {
 			c.log.Warn(
 				"Shard controller not found",
-				slog.Int64("shard", swapAction.Shard),
+				slog.Int64("shard", shard),
 			)
 			continue
 		}
 
-		if err := sc.SwapNode(swapAction.From, swapAction.To); err != nil {
+		select {
+		case semaphore <- struct{}{}:
+		case <-c.ctx.Done():
+			wg.Wait()
+			return
+		}
+
+		wg.Add(1)
+		go common.DoWithLabels(
+			c.ctx,
+			map[string]string{
+				"oxia":  "coordinator-rebalance",
+				"shard": fmt.Sprintf("%d", shard),
+			},
+			func() {
+				defer func() {
+					<-semaphore
+					wg.Done()
+				}()
+				f(sc, shard)
+			},
+		)
+	}
+
+	wg.Wait()
+}
+
+// moveReplica adds the new replica and waits for it to catch up before
+// removing the old one, so that the shard keeps the same number of
+// replicas in sync during the move. When the old replica is the leader,
+// the leadership is transferred to the new replica first.
+func (c *coordinator) moveReplica(sc ShardController, m SwapNodeAction) error {
+	c.log.Info(
+		"Applying swap action",
+		slog.Any("swap-action", m),
+	)
+
+	// Without a leader the new replica can't catch up, so the ensemble is
+	// changed directly, with a new election
+	if sc.Status() != model.ShardStatusSteadyState {
+		return sc.SwapNode(m.From, m.To)
+	}
+
+	if err := sc.AddNode(m.To); err != nil {
+		return errors.Wrap(err, "failed to add the new replica")
+	}
+
+	if leader := sc.Leader(); leader != nil && *leader == m.From {
+		ctx, cancel := context.WithTimeout(c.ctx, moveLeadershipTimeout)
+		err := backoff.Retry(func() error {
+			return sc.TransferLeadership(m.To)
+		}, common.NewBackOff(ctx))
+		cancel()
+
+		if err != nil {
+			// The leader is elected again when the old replica is removed
 			c.log.Warn(
-				"Failed to swap node",
+				"Failed to transfer the leadership to the new replica",
 				slog.Any("error", err),
-				slog.Any("swap-action", swapAction),
+				slog.Any("swap-action", m),
 			)
 		}
 	}
 
+	if err := sc.RemoveNode(m.From); err != nil {
+		return errors.Wrap(err, "failed to remove the old replica")
+	}
 	return nil
 }
 
@@ -535,7 +898,7 @@ func (c *coordinator) checkClusterNodeChanges(newClusterConfig model.ClusterConf
 			_ = nc.Close()
 			delete(c.drainingNodes, sa.Internal)
 		}
-		c.nodeControllers[sa.Internal] = NewNodeController(sa, c, c, c.rpc)
+		c.nodeControllers[sa.Internal] = NewNodeController(sa, c, c, c.rpc, c.failureDetector)
 	}
 
 	// Check for nodes to remove
@@ -16,21 +16,33 @@ package impl
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/coordinator/model"
 	"github.com/streamnative/oxia/oxia"
+	"github.com/streamnative/oxia/proto"
 	"github.com/streamnative/oxia/server"
 )
 
+// The nodes that are stopped by the tests are detected quickly.
+var testFailureDetector = FailureDetectorOptions{
+	ProbeInterval:    100 * time.Millisecond,
+	ProbeTimeout:     1 * time.Second,
+	FailureThreshold: 3,
+	ElectionBackoff:  50 * time.Millisecond,
+}
+
 func newServer(t *testing.T) (s *server.Server, addr model.ServerAddress) {
 	t.Helper()
 
@@ -70,7 +82,7 @@ func TestCoordinatorE2E(t *testing.T) {
 	}
 	clientPool := common.NewClientPool(nil, nil)
 
-	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool))
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 
 	assert.NoError(t, err)
 
@@ -108,7 +120,7 @@ func TestCoordinatorE2E_ShardsRanges(t *testing.T) {
 	}
 	clientPool := common.NewClientPool(nil, nil)
 
-	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool))
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	cs := coordinator.ClusterStatus()
@@ -161,7 +173,7 @@ func TestCoordinator_LeaderFailover(t *testing.T) {
 	}
 	clientPool := common.NewClientPool(nil, nil)
 
-	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool))
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	nsStatus := coordinator.ClusterStatus().Namespaces[common.DefaultNamespace]
@@ -236,6 +248,311 @@ func TestCoordinator_LeaderFailover(t *testing.T) {
 	}
 }
 
+func TestCoordinator_Metrics(t *testing.T) {
+	s1, sa1 := newServer(t)
+	s2, sa2 := newServer(t)
+	s3, sa3 := newServer(t)
+	servers := map[model.ServerAddress]*server.Server{
+		sa1: s1,
+		sa2: s2,
+		sa3: s3,
+	}
+
+	namespace := "test-metrics"
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              namespace,
+			ReplicationFactor: 3,
+			InitialShardCount: 1,
+		}},
+		Servers: []model.ServerAddress{sa1, sa2, sa3},
+	}
+	clientPool := common.NewClientPool(nil, nil)
+
+	// The counters are not reset across the tests
+	elections := func(reason string) float64 {
+		return gatherMetric(t, "oxia_coordinator_leader_elections_total",
+			map[string]string{"oxia_namespace": namespace, "reason": reason})
+	}
+	startupElections := elections("startup")
+	nodeDownElections := elections("node-down")
+
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		shard := coordinator.ClusterStatus().Namespaces[namespace].Shards[0]
+		return shard.Status == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.EqualValues(t, startupElections+1, elections("startup"))
+	assert.EqualValues(t, nodeDownElections, elections("node-down"))
+	assert.EqualValues(t, 0, gatherMetric(t, "oxia_coordinator_shards_without_leader", nil))
+	assert.EqualValues(t, 0, gatherMetric(t, "oxia_coordinator_nodes_down", nil))
+	generation := gatherMetric(t, "oxia_coordinator_assignments_generation", nil)
+	assert.Positive(t, generation)
+
+	// Stop the leader to cause a leader election
+	leader := *coordinator.ClusterStatus().Namespaces[namespace].Shards[0].Leader
+	assert.NoError(t, servers[leader].Close())
+	delete(servers, leader)
+
+	assert.Eventually(t, func() bool {
+		return gatherMetric(t, "oxia_coordinator_nodes_down", nil) == 1 &&
+			elections("node-down") == nodeDownElections+1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.EqualValues(t, 0, gatherMetric(t, "oxia_coordinator_shards_without_leader", nil))
+	assert.Greater(t, gatherMetric(t, "oxia_coordinator_assignments_generation", nil), generation)
+
+	assert.NoError(t, coordinator.Close())
+	assert.NoError(t, clientPool.Close())
+
+	for _, serverObj := range servers {
+		assert.NoError(t, serverObj.Close())
+	}
+}
+
+func TestCoordinator_AdminOperations(t *testing.T) {
+	s1, sa1 := newServer(t)
+	s2, sa2 := newServer(t)
+	s3, sa3 := newServer(t)
+	servers := map[model.ServerAddress]*server.Server{
+		sa1: s1,
+		sa2: s2,
+		sa3: s3,
+	}
+
+	namespace := "test-admin"
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              namespace,
+			ReplicationFactor: 3,
+			InitialShardCount: 1,
+		}},
+		Servers: []model.ServerAddress{sa1, sa2, sa3},
+	}
+	clientPool := common.NewClientPool(nil, nil)
+
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		shard := coordinator.ClusterStatus().Namespaces[namespace].Shards[0]
+		return shard.Status == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+
+	shards := coordinator.ListShards()
+	assert.Len(t, shards, 1)
+	assert.Len(t, coordinator.ListServers(), 3)
+	assert.Equal(t, namespace, shards[0].Namespace)
+	assert.EqualValues(t, 0, shards[0].Shard)
+	assert.Equal(t, model.ShardStatusSteadyState.String(), shards[0].Status)
+	assert.NotNil(t, shards[0].Leader)
+	assert.Len(t, shards[0].Ensemble, 3)
+	for _, member := range shards[0].Ensemble {
+		assert.True(t, member.InSync)
+	}
+
+	// Force a new election while the leader is healthy
+	term := shards[0].Term
+	assert.NoError(t, coordinator.TriggerElection(0))
+	shard := coordinator.ClusterStatus().Namespaces[namespace].Shards[0]
+	assert.Equal(t, model.ShardStatusSteadyState, shard.Status)
+	assert.Greater(t, shard.Term, term)
+
+	// Move the leadership to one of the followers
+	var follower, other model.ServerAddress
+	for _, sa := range shard.Ensemble {
+		switch {
+		case sa == *shard.Leader:
+		case follower == model.ServerAddress{}:
+			follower = sa
+		default:
+			other = sa
+		}
+	}
+	assert.NoError(t, coordinator.TransferLeader(0, follower.Internal))
+	assert.Equal(t, follower, *coordinator.ClusterStatus().Namespaces[namespace].Shards[0].Leader)
+
+	assert.ErrorIs(t, coordinator.TransferLeader(0, "unknown:1234"), ErrNodeNotInSync)
+	assert.ErrorIs(t, coordinator.TransferLeader(5, follower.Internal), ErrShardNotFound)
+	assert.ErrorIs(t, coordinator.TriggerElection(5), ErrShardNotFound)
+
+	// A member that is down is not in sync
+	assert.NoError(t, servers[other].Close())
+	delete(servers, other)
+
+	assert.Eventually(t, func() bool {
+		return coordinator.NodesStatus()[other.Internal] != Running
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.ErrorIs(t, coordinator.TransferLeader(0, other.Internal), ErrNodeNotInSync)
+
+	// The database of the shard is compacted on the members that are running
+	compactions, err := coordinator.CompactShard(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Len(t, compactions, 3)
+	for _, sc := range compactions {
+		assert.Equal(t, sc.ServerAddress == other, sc.Error != "")
+	}
+	_, err = coordinator.CompactShard(context.Background(), 5)
+	assert.ErrorIs(t, err, ErrShardNotFound)
+
+	for _, member := range coordinator.ListShards()[0].Ensemble {
+		assert.Equal(t, member.ServerAddress != other, member.InSync)
+	}
+	for _, server := range coordinator.ListServers() {
+		if server.ServerAddress == other {
+			assert.Equal(t, NotRunning.String(), server.Status)
+		} else {
+			assert.Equal(t, Running.String(), server.Status)
+		}
+	}
+	assert.Equal(t, follower, *coordinator.ClusterStatus().Namespaces[namespace].Shards[0].Leader)
+
+	assert.NoError(t, coordinator.Close())
+	assert.NoError(t, clientPool.Close())
+
+	for _, serverObj := range servers {
+		assert.NoError(t, serverObj.Close())
+	}
+}
+
+// gatherMetric returns the sum of the values of the series of a counter or
+// a gauge that have all the given labels.
+func gatherMetric(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	value := 0.0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+	nextMetric:
+		for _, m := range family.GetMetric() {
+			for name, expected := range labels {
+				found := false
+				for _, label := range m.GetLabel() {
+					if label.GetName() == name && label.GetValue() == expected {
+						found = true
+					}
+				}
+				if !found {
+					continue nextMetric
+				}
+			}
+
+			value += m.GetCounter().GetValue() + m.GetGauge().GetValue()
+		}
+	}
+	return value
+}
+
+func TestCoordinator_NotificationsLeaderFailover(t *testing.T) {
+	s1, sa1 := newServer(t)
+	s2, sa2 := newServer(t)
+	s3, sa3 := newServer(t)
+	servers := map[model.ServerAddress]*server.Server{
+		sa1: s1,
+		sa2: s2,
+		sa3: s3,
+	}
+
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              common.DefaultNamespace,
+			ReplicationFactor: 3,
+			InitialShardCount: 1,
+		}},
+		Servers: []model.ServerAddress{sa1, sa2, sa3},
+	}
+	clientPool := common.NewClientPool(nil, nil)
+
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		shard := coordinator.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0]
+		return shard.Status == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+
+	leader := *coordinator.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0].Leader
+	var follower model.ServerAddress
+	for serverObj := range servers {
+		if serverObj != leader {
+			follower = serverObj
+			break
+		}
+	}
+
+	client, err := oxia.NewSyncClient(follower.Public)
+	assert.NoError(t, err)
+
+	notifications, err := client.GetNotifications(oxia.KeyPrefix("/a/"))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	for _, key := range []string{"/a/0", "/b/0", "/a/1"} {
+		_, _, err = client.Put(ctx, key, []byte(key))
+		assert.NoError(t, err)
+	}
+
+	n := <-notifications.Ch()
+	assert.Equal(t, "/a/0", n.Key)
+	n = <-notifications.Ch()
+	assert.Equal(t, "/a/1", n.Key)
+
+	// Stop the leader to cause a leader election
+	assert.NoError(t, servers[leader].Close())
+	delete(servers, leader)
+
+	assert.Eventually(t, func() bool {
+		shard := coordinator.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0]
+		return shard.Status == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+
+	for _, key := range []string{"/b/1", "/a/2"} {
+		assert.Eventually(t, func() bool {
+			_, _, err := client.Put(ctx, key, []byte(key))
+			return err == nil
+		}, 10*time.Second, 100*time.Millisecond)
+	}
+
+	// The stream resumes from the last notification received, without
+	// duplicates or gaps
+	select {
+	case n = <-notifications.Ch():
+		assert.Equal(t, "/a/2", n.Key)
+		assert.Equal(t, oxia.KeyCreated, n.Type)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Notification not received after the failover")
+	}
+
+	select {
+	case n = <-notifications.Ch():
+		assert.Fail(t, "Unexpected notification", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, notifications.Close())
+	assert.NoError(t, client.Close())
+
+	assert.NoError(t, coordinator.Close())
+	assert.NoError(t, clientPool.Close())
+
+	for _, serverObj := range servers {
+		assert.NoError(t, serverObj.Close())
+	}
+}
+
 func TestCoordinator_MultipleNamespaces(t *testing.T) {
 	s1, sa1 := newServer(t)
 	s2, sa2 := newServer(t)
@@ -265,7 +582,7 @@ func TestCoordinator_MultipleNamespaces(t *testing.T) {
 	}
 	clientPool := common.NewClientPool(nil, nil)
 
-	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool))
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	nsDefaultStatus := coordinator.ClusterStatus().Namespaces[common.DefaultNamespace]
@@ -356,7 +673,7 @@ func TestCoordinator_DeleteNamespace(t *testing.T) {
 	}
 	clientPool := common.NewClientPool(nil, nil)
 
-	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool))
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	ns1Status := coordinator.ClusterStatus().Namespaces["my-ns-1"]
@@ -401,7 +718,7 @@ func TestCoordinator_DeleteNamespace(t *testing.T) {
 		Servers:    []model.ServerAddress{sa1, sa2, sa3},
 	}
 
-	coordinator, err = NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return newClusterConfig, nil }, nil, NewRpcProvider(clientPool))
+	coordinator, err = NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return newClusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	// Wait for all shards to be deleted
@@ -443,7 +760,7 @@ func TestCoordinator_DynamicallAddNamespace(t *testing.T) {
 		return clusterConfig, nil
 	}
 
-	coordinator, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool))
+	coordinator, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	ns1Status := coordinator.ClusterStatus().Namespaces["my-ns-1"]
@@ -534,7 +851,7 @@ func TestCoordinator_RebalanceCluster(t *testing.T) {
 	}
 
 	configChangesCh := make(chan any)
-	coordinator, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool))
+	coordinator, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	ns1Status := coordinator.ClusterStatus().Namespaces["my-ns-1"]
@@ -571,13 +888,11 @@ func TestCoordinator_RebalanceCluster(t *testing.T) {
 
 	configChangesCh <- nil
 
-	// Wait for all shards to be ready
+	// Wait for the replicas to be moved from `s1` to `s4`
 	assert.Eventually(t, func() bool {
 		for _, ns := range coordinator.ClusterStatus().Namespaces {
 			for _, shard := range ns.Shards {
-				// Use Term to detect for EnsembleChange have done, we cann't use status
-				// because it always be Steady when EnsembleChange.
-				if shard.Term != 1 {
+				if listContains(shard.Ensemble, sa1) || !listContains(shard.Ensemble, sa4) {
 					return false
 				}
 			}
@@ -599,6 +914,103 @@ func TestCoordinator_RebalanceCluster(t *testing.T) {
 	}
 }
 
+func TestCoordinator_RebalanceScaleUp(t *testing.T) {
+	servers := map[model.ServerAddress]*server.Server{}
+	var addrs []model.ServerAddress
+	for i := 0; i < 5; i++ {
+		s, sa := newServer(t)
+		servers[sa] = s
+		addrs = append(addrs, sa)
+	}
+
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              "my-ns-1",
+			ReplicationFactor: 3,
+			InitialShardCount: 5,
+		}},
+		Servers: addrs[:3],
+	}
+	clientPool := common.NewClientPool(nil, nil)
+	mutex := &sync.Mutex{}
+
+	configProvider := func() (model.ClusterConfig, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return clusterConfig, nil
+	}
+
+	configChangesCh := make(chan any)
+	coordinator, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool),
+		testFailureDetector, RebalanceOptions{MaxConcurrentMoves: 2})
+	assert.NoError(t, err)
+
+	// Wait for all shards to be ready
+	assert.Eventually(t, func() bool {
+		for _, ns := range coordinator.ClusterStatus().Namespaces {
+			for _, shard := range ns.Shards {
+				if shard.Status != model.ShardStatusSteadyState {
+					return false
+				}
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+
+	client, err := oxia.NewSyncClient(addrs[0].Public, oxia.WithNamespace("my-ns-1"))
+	assert.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, _, err = client.Put(context.Background(), fmt.Sprintf("key-%d", i), []byte("value"))
+		assert.NoError(t, err)
+	}
+
+	// Add `s4` and `s5` to the cluster config
+	mutex.Lock()
+	clusterConfig.Servers = addrs
+	mutex.Unlock()
+
+	configChangesCh <- nil
+
+	// Wait for the replicas and the leaders to be spread over all the servers
+	assert.Eventually(t, func() bool {
+		replicas := map[model.ServerAddress]int{}
+		leaders := map[model.ServerAddress]int{}
+		for _, shard := range coordinator.ClusterStatus().Namespaces["my-ns-1"].Shards {
+			if shard.Status != model.ShardStatusSteadyState || len(shard.Ensemble) != 3 {
+				return false
+			}
+			for _, sa := range shard.Ensemble {
+				replicas[sa]++
+			}
+			leaders[*shard.Leader]++
+		}
+
+		for _, sa := range addrs {
+			if replicas[sa] != 3 || leaders[sa] != 1 {
+				return false
+			}
+		}
+		return true
+	}, 30*time.Second, 100*time.Millisecond)
+
+	assert.Empty(t, coordinator.RebalancePlan().Moves)
+
+	for i := 0; i < 20; i++ {
+		_, value, _, err := client.Get(context.Background(), fmt.Sprintf("key-%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), value)
+	}
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, coordinator.Close())
+	assert.NoError(t, clientPool.Close())
+
+	for _, serverObj := range servers {
+		assert.NoError(t, serverObj.Close())
+	}
+}
+
 func TestCoordinator_AddRemoveNodes(t *testing.T) {
 	s1, sa1 := newServer(t)
 	s2, sa2 := newServer(t)
@@ -629,7 +1041,7 @@ func TestCoordinator_AddRemoveNodes(t *testing.T) {
 	}
 
 	configChangesCh := make(chan any)
-	c, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool))
+	c, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	assert.Equal(t, 3, len(c.(*coordinator).getNodeControllers()))
@@ -691,7 +1103,7 @@ func TestCoordinator_ShrinkCluster(t *testing.T) {
 	}
 
 	configChangesCh := make(chan any)
-	c, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool))
+	c, err := NewCoordinator(metadataProvider, configProvider, configChangesCh, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
 	assert.NoError(t, err)
 
 	// Wait for all shards to be ready
@@ -723,7 +1135,7 @@ func TestCoordinator_ShrinkCluster(t *testing.T) {
 	assert.Eventually(t, func() bool {
 		for _, ns := range c.ClusterStatus().Namespaces {
 			for _, shard := range ns.Shards {
-				return shard.Term > 0
+				return shard.Term > 0 && shard.Status == model.ShardStatusSteadyState
 			}
 		}
 		return true
@@ -769,3 +1181,258 @@ func checkServerLists(t *testing.T, expected, actual []model.ServerAddress) {
 		assert.True(t, ok)
 	}
 }
+
+var errCoordinatorCrashed = errors.New("coordinator crashed")
+
+// crashingRpcProvider stops the elections of a coordinator at a given step,
+// as if the coordinator had crashed right there.
+type crashingRpcProvider struct {
+	RpcProvider
+
+	failNewTerm      atomic.Bool
+	failBecomeLeader atomic.Bool
+
+	// The highest term sent to the nodes
+	maxTerm atomic.Int64
+}
+
+func (r *crashingRpcProvider) NewTerm(ctx context.Context, node model.ServerAddress, req *proto.NewTermRequest) (*proto.NewTermResponse, error) {
+	if r.failNewTerm.Load() {
+		return nil, errCoordinatorCrashed
+	}
+
+	for {
+		current := r.maxTerm.Load()
+		if req.Term <= current || r.maxTerm.CompareAndSwap(current, req.Term) {
+			break
+		}
+	}
+	return r.RpcProvider.NewTerm(ctx, node, req)
+}
+
+func (r *crashingRpcProvider) BecomeLeader(ctx context.Context, node model.ServerAddress, req *proto.BecomeLeaderRequest) (*proto.BecomeLeaderResponse, error) {
+	if r.failBecomeLeader.Load() {
+		return nil, errCoordinatorCrashed
+	}
+	return r.RpcProvider.BecomeLeader(ctx, node, req)
+}
+
+func TestCoordinator_CrashBeforeNewTerm(t *testing.T) {
+	testCoordinatorCrashDuringElection(t, func(rpc *crashingRpcProvider, _ int64) func() bool {
+		rpc.failNewTerm.Store(true)
+		return func() bool { return true }
+	})
+}
+
+func TestCoordinator_CrashBeforeBecomeLeader(t *testing.T) {
+	testCoordinatorCrashDuringElection(t, func(rpc *crashingRpcProvider, term int64) func() bool {
+		rpc.failBecomeLeader.Store(true)
+		return func() bool { return rpc.maxTerm.Load() > term }
+	})
+}
+
+// testCoordinatorCrashDuringElection starts an election that is stopped by
+// the crash function, then restarts the coordinator on the same metadata and
+// checks that the new leader is elected with a term that was never used.
+func testCoordinatorCrashDuringElection(t *testing.T, crash func(rpc *crashingRpcProvider, term int64) func() bool) {
+	t.Helper()
+
+	s1, sa1 := newServer(t)
+	s2, sa2 := newServer(t)
+	s3, sa3 := newServer(t)
+
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              common.DefaultNamespace,
+			ReplicationFactor: 3,
+			InitialShardCount: 1,
+		}},
+		Servers: []model.ServerAddress{sa1, sa2, sa3},
+	}
+	configProvider := func() (model.ClusterConfig, error) { return clusterConfig, nil }
+	clientPool := common.NewClientPool(nil, nil)
+
+	rpc := &crashingRpcProvider{RpcProvider: NewRpcProvider(clientPool)}
+	c1, err := NewCoordinator(metadataProvider, configProvider, nil, rpc, testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		shard := c1.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0]
+		return shard.Status == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+	term := c1.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0].Term
+
+	client, err := oxia.NewSyncClient(sa1.Public)
+	assert.NoError(t, err)
+	_, _, err = client.Put(context.Background(), "my-key", []byte("my-value"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	// The election keeps being retried until the coordinator is closed
+	crashed := crash(rpc, term)
+	go func() { _ = c1.TriggerElection(0) }()
+
+	persistedTerm := func() int64 {
+		status, _, err := metadataProvider.Get()
+		assert.NoError(t, err)
+		return status.Namespaces[common.DefaultNamespace].Shards[0].Term
+	}
+	assert.Eventually(t, func() bool {
+		return persistedTerm() > term && crashed()
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// Nothing is sent by the crashed coordinator after this point, and the
+	// new one starts from the metadata that was persisted up to now
+	rpc.failNewTerm.Store(true)
+	assert.NoError(t, c1.Close())
+
+	crashedStatus, _, err := metadataProvider.Get()
+	assert.NoError(t, err)
+	crashedTerm := crashedStatus.Namespaces[common.DefaultNamespace].Shards[0].Term
+
+	// Every term sent to the nodes was persisted before
+	assert.LessOrEqual(t, rpc.maxTerm.Load(), crashedTerm)
+
+	restartedMetadataProvider := NewMetadataProviderMemory()
+	_, err = restartedMetadataProvider.Store(crashedStatus, MetadataNotExists)
+	assert.NoError(t, err)
+
+	c2, err := NewCoordinator(restartedMetadataProvider, configProvider, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		shard := c2.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0]
+		return shard.Status == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Greater(t, c2.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0].Term, crashedTerm)
+
+	assert.Eventually(t, func() bool {
+		client, err = oxia.NewSyncClient(sa1.Public)
+		if err != nil {
+			return false
+		}
+		_, value, _, err := client.Get(context.Background(), "my-key")
+		_ = client.Close()
+		return err == nil && string(value) == "my-value"
+	}, 10*time.Second, 100*time.Millisecond)
+
+	assert.NoError(t, c2.Close())
+	assert.NoError(t, clientPool.Close())
+
+	assert.NoError(t, s1.Close())
+	assert.NoError(t, s2.Close())
+	assert.NoError(t, s3.Close())
+}
+
+func TestCoordinator_DrainServer(t *testing.T) {
+	s1, sa1 := newServer(t)
+	s2, sa2 := newServer(t)
+	s3, sa3 := newServer(t)
+	servers := []*server.Server{s1, s2, s3}
+
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              common.DefaultNamespace,
+			ReplicationFactor: 2,
+			InitialShardCount: 3,
+		}},
+		Servers: []model.ServerAddress{sa1, sa2, sa3},
+	}
+	clientPool := common.NewClientPool(nil, nil)
+
+	c, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil,
+		NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	allInSteadyState := func() bool {
+		for _, shard := range c.ClusterStatus().Namespaces[common.DefaultNamespace].Shards {
+			if shard.Status != model.ShardStatusSteadyState || len(shard.Ensemble) != 2 {
+				return false
+			}
+		}
+		return true
+	}
+	assert.Eventually(t, allInSteadyState, 10*time.Second, 10*time.Millisecond)
+
+	client, err := oxia.NewSyncClient(sa1.Public)
+	assert.NoError(t, err)
+
+	// Keep writing while the server is drained, and track the writes that
+	// were acknowledged
+	var written []string
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			key := fmt.Sprintf("key-%d", i)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if _, _, err := client.Put(ctx, key, []byte(key)); err == nil {
+				written = append(written, key)
+			}
+			cancel()
+		}
+	}()
+
+	drained := *c.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0].Leader
+
+	// An aborted drain leaves all the shards with all their replicas
+	ctx, cancel := context.WithCancel(context.Background())
+	var progress []DrainProgress
+	err = c.DrainServer(ctx, drained.Internal, true, func(p DrainProgress) {
+		progress = append(progress, p)
+		cancel()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, progress, 1)
+	assert.Equal(t, DrainActionTransferLeader, progress[0].Action)
+	assert.Empty(t, progress[0].Error)
+	assert.True(t, allInSteadyState())
+
+	progress = nil
+	assert.NoError(t, c.DrainServer(context.Background(), drained.Internal, true, func(p DrainProgress) {
+		progress = append(progress, p)
+	}))
+	assert.NotEmpty(t, progress)
+	for _, p := range progress {
+		assert.Empty(t, p.Error)
+	}
+	assert.Empty(t, progress[len(progress)-1].RemainingShards)
+
+	close(stop)
+	<-writerDone
+
+	// The server has no replica nor leadership left, and the other servers
+	// have all the replicas
+	assert.Eventually(t, allInSteadyState, 10*time.Second, 10*time.Millisecond)
+	for shardId, shard := range c.ClusterStatus().Namespaces[common.DefaultNamespace].Shards {
+		assert.NotEqual(t, drained, *shard.Leader, "shard %d", shardId)
+		assert.NotContains(t, shard.Ensemble, drained, "shard %d", shardId)
+	}
+
+	assert.ErrorIs(t, c.DrainServer(context.Background(), "unknown:1234", false, func(DrainProgress) {}), ErrServerNotFound)
+
+	// No acknowledged write was lost
+	assert.NotEmpty(t, written)
+	for _, key := range written {
+		_, value, _, err := client.Get(context.Background(), key)
+		assert.NoError(t, err)
+		assert.Equal(t, key, string(value))
+	}
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, c.Close())
+	assert.NoError(t, clientPool.Close())
+
+	for _, serverObj := range servers {
+		assert.NoError(t, serverObj.Close())
+	}
+}
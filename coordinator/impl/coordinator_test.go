@@ -0,0 +1,74 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/model"
+)
+
+func TestCoordinator_InitiateLeaderElectionPersistsTerm(t *testing.T) {
+	metadataProvider := NewMetadataProviderMemory()
+	status := &model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			common.DefaultNamespace: {
+				ReplicationFactor: 3,
+				Shards: map[int64]model.ShardMetadata{
+					0: {Status: model.ShardStatusSteadyState, Term: 1, Leader: &s1, Ensemble: []model.ServerAddress{s1, s2, s3}},
+					1: {Status: model.ShardStatusSteadyState, Term: 1, Leader: &s2, Ensemble: []model.ServerAddress{s1, s2, s3}},
+				},
+			},
+		},
+	}
+	version, err := metadataProvider.Store(status, MetadataNotExists)
+	assert.NoError(t, err)
+
+	c := &coordinator{
+		MetadataProvider: metadataProvider,
+		clusterStatus:    status,
+		metadataVersion:  version,
+		log:              slog.Default(),
+	}
+	c.assignmentsChanged = common.NewConditionContext(c)
+
+	shard0 := status.Namespaces[common.DefaultNamespace].Shards[0].Clone()
+	shard0.Status = model.ShardStatusElection
+	shard0.Leader = nil
+	shard0.Term = 2
+	assert.NoError(t, c.InitiateLeaderElection(common.DefaultNamespace, 0, shard0))
+
+	// Completing the election of another shard must not store the previous
+	// term of the first one
+	shard1 := status.Namespaces[common.DefaultNamespace].Shards[1].Clone()
+	shard1.Term = 2
+	assert.NoError(t, c.ElectedLeader(common.DefaultNamespace, 1, shard1))
+
+	persisted, _, err := metadataProvider.Get()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, persisted.Namespaces[common.DefaultNamespace].Shards[0].Term)
+	assert.Equal(t, model.ShardStatusElection, persisted.Namespaces[common.DefaultNamespace].Shards[0].Status)
+	assert.EqualValues(t, 2, persisted.Namespaces[common.DefaultNamespace].Shards[1].Term)
+
+	// A term is never used twice
+	assert.ErrorIs(t, c.InitiateLeaderElection(common.DefaultNamespace, 0, shard0), ErrTermNotIncreasing)
+
+	shard0.Term = 3
+	assert.NoError(t, c.InitiateLeaderElection(common.DefaultNamespace, 0, shard0))
+}
@@ -0,0 +1,276 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/proto"
+)
+
+var (
+	ErrServerNotFound   = errors.New("server not found")
+	ErrNoEligibleServer = errors.New("no eligible server")
+)
+
+type DrainAction string
+
+const (
+	DrainActionTransferLeader DrainAction = "transfer-leader"
+	DrainActionMoveReplica    DrainAction = "move-replica"
+)
+
+// DrainProgress reports the outcome of a step of the drain of a server.
+type DrainProgress struct {
+	Namespace string               `json:"namespace"`
+	Shard     int64                `json:"shard"`
+	Action    DrainAction          `json:"action"`
+	To        *model.ServerAddress `json:"to,omitempty"`
+	Error     string               `json:"error,omitempty"`
+
+	// RemainingShards are the shards that still have to be drained after
+	// this step
+	RemainingShards []int64 `json:"remainingShards"`
+}
+
+type drainStep struct {
+	namespace string
+	shard     int64
+	action    DrainAction
+}
+
+// DrainServer moves the leaderships, and optionally the replicas, of all the
+// shards away from the server, one shard at a time. The server is put in
+// maintenance first, so that it's not elected as leader again.
+//
+// The drain is stopped when the context is done, though the step in progress
+// is always completed, so that every shard is left with all its replicas.
+func (c *coordinator) DrainServer(ctx context.Context, server string, removeReplicas bool, progress func(DrainProgress)) error {
+	c.Lock()
+	addr := c.findServerByInternalAddress(c.ClusterConfig, server)
+	c.Unlock()
+	if addr == nil {
+		return errors.Wrapf(ErrServerNotFound, "server %s", server)
+	}
+
+	c.log.Info(
+		"Draining server",
+		slog.Any("server", addr),
+		slog.Bool("remove-replicas", removeReplicas),
+	)
+
+	if _, err := c.rpc.SetMaintenance(ctx, *addr, &proto.SetMaintenanceRequest{Enabled: true}); err != nil {
+		return errors.Wrapf(err, "failed to put server %s in maintenance", server)
+	}
+
+	c.Lock()
+	c.drainedNodes[addr.Internal] = true
+	c.Unlock()
+
+	steps := c.drainSteps(*addr, removeReplicas)
+	failed := 0
+	for i, step := range steps {
+		if err := ctx.Err(); err != nil {
+			c.log.Warn(
+				"Drain aborted",
+				slog.Any("server", addr),
+				slog.Int("remaining-steps", len(steps)-i),
+			)
+			return errors.Wrap(err, "drain aborted")
+		}
+
+		to, err := c.drainShard(step, *addr)
+		p := DrainProgress{
+			Namespace:       step.namespace,
+			Shard:           step.shard,
+			Action:          step.action,
+			To:              to,
+			RemainingShards: remainingShards(steps[i+1:]),
+		}
+		if err != nil {
+			c.log.Warn(
+				"Failed to drain shard",
+				slog.Any("server", addr),
+				slog.Int64("shard", step.shard),
+				slog.Any("action", step.action),
+				slog.Any("error", err),
+			)
+			p.Error = err.Error()
+			failed++
+		}
+		progress(p)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("failed to drain %d shards from server %s", failed, server)
+	}
+
+	c.log.Info(
+		"Drained server",
+		slog.Any("server", addr),
+	)
+	return nil
+}
+
+// drainSteps lists the shards led by the server and then, if the replicas
+// are removed too, the shards that have a replica on the server.
+func (c *coordinator) drainSteps(server model.ServerAddress, removeReplicas bool) []drainStep {
+	cs := c.ClusterStatus()
+
+	var transfers, moves []drainStep
+	for namespace, ns := range cs.Namespaces {
+		for shard, sm := range ns.Shards {
+			if sm.Leader != nil && *sm.Leader == server {
+				transfers = append(transfers, drainStep{namespace, shard, DrainActionTransferLeader})
+			}
+			if removeReplicas && listContains(sm.Ensemble, server) {
+				moves = append(moves, drainStep{namespace, shard, DrainActionMoveReplica})
+			}
+		}
+	}
+
+	for _, steps := range [][]drainStep{transfers, moves} {
+		sort.Slice(steps, func(i, j int) bool {
+			return steps[i].shard < steps[j].shard
+		})
+	}
+	return append(transfers, moves...)
+}
+
+func remainingShards(steps []drainStep) []int64 {
+	shards := common.NewSet[int64]()
+	for _, step := range steps {
+		shards.Add(step.shard)
+	}
+	return shards.GetSorted()
+}
+
+// drainShard applies the step and returns the server that took over the
+// leadership or the replica, or nil if the shard had already moved away.
+func (c *coordinator) drainShard(step drainStep, server model.ServerAddress) (*model.ServerAddress, error) {
+	sc, sm, err := c.getShard(step.shard)
+	if err != nil {
+		return nil, err
+	}
+	if !isMovable(sm) {
+		return nil, errors.Wrapf(ErrShardNotAvailable, "shard %d", step.shard)
+	}
+
+	constraints := c.currentPlacementConstraints()
+
+	switch step.action {
+	case DrainActionTransferLeader:
+		if sm.Leader == nil || *sm.Leader != server {
+			return nil, nil
+		}
+
+		to, err := c.drainLeaderTarget(sm, constraints)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(c.ctx, moveLeadershipTimeout)
+		defer cancel()
+		return to, backoff.Retry(func() error {
+			return sc.TransferLeadership(*to)
+		}, common.NewBackOff(ctx))
+
+	case DrainActionMoveReplica:
+		if !listContains(sm.Ensemble, server) {
+			return nil, nil
+		}
+
+		to, err := c.drainReplicaTarget(sm, server, constraints)
+		if err != nil {
+			return nil, err
+		}
+		return to, c.moveReplica(sc, SwapNodeAction{Shard: step.shard, From: server, To: *to})
+	}
+	return nil, errors.Errorf("unknown drain action %s", step.action)
+}
+
+// drainLeaderTarget picks the in-sync member of the ensemble that leads the
+// fewest shards.
+func (c *coordinator) drainLeaderTarget(sm model.ShardMetadata, constraints placementConstraints) (*model.ServerAddress, error) {
+	cs := c.ClusterStatus()
+	nodes := c.NodesStatus()
+
+	var res *model.ServerAddress
+	leaderships := map[model.ServerAddress]int{}
+	for _, ns := range cs.Namespaces {
+		for _, shard := range ns.Shards {
+			if shard.Leader != nil {
+				leaderships[*shard.Leader]++
+			}
+		}
+	}
+
+	for _, member := range sm.Ensemble {
+		if member == *sm.Leader || !constraints.isAvailable(member) || !isInSync(sm, member, nodes) {
+			continue
+		}
+		if res == nil || leaderships[member] < leaderships[*res] {
+			res = &member
+		}
+	}
+
+	if res == nil {
+		return nil, errors.Wrap(ErrNoEligibleServer, "no other member of the ensemble can be the leader")
+	}
+	return res, nil
+}
+
+// drainReplicaTarget picks the server with the fewest replicas, among the ones
+// that are not part of the ensemble and that don't break the placement
+// constraints.
+func (c *coordinator) drainReplicaTarget(sm model.ShardMetadata, from model.ServerAddress,
+	constraints placementConstraints) (*model.ServerAddress, error) {
+	cs := c.ClusterStatus()
+	c.Lock()
+	servers := append([]model.ServerAddress{}, c.ClusterConfig.Servers...)
+	c.Unlock()
+
+	replicas := map[model.ServerAddress]int{}
+	for _, ns := range cs.Namespaces {
+		for _, shard := range ns.Shards {
+			for _, member := range shard.Ensemble {
+				replicas[member]++
+			}
+		}
+	}
+
+	var res *model.ServerAddress
+	for _, server := range servers {
+		if listContains(sm.Ensemble, server) || !constraints.isAvailable(server) ||
+			!constraints.canMove(sm.Ensemble, from, server) {
+			continue
+		}
+		if res == nil || replicas[server] < replicas[*res] {
+			res = &server
+		}
+	}
+
+	if res == nil {
+		return nil, errors.Wrap(ErrNoEligibleServer, "no server can take the replica")
+	}
+	return res, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultProbeInterval    = 2 * time.Second
+	DefaultProbeTimeout     = 2 * time.Second
+	DefaultFailureThreshold = 3
+	DefaultElectionBackoff  = 1 * time.Second
+
+	maxElectionBackoff = 1 * time.Minute
+)
+
+// FailureDetectorOptions configures how the coordinator decides that a node
+// has failed, and how quickly it reacts by electing new leaders for the
+// shards that were led by the node. The zero values are replaced with the
+// defaults.
+type FailureDetectorOptions struct {
+	// ProbeInterval is how often the health of each node is checked
+	ProbeInterval time.Duration
+
+	// ProbeTimeout is how long to wait for the response to a health check
+	ProbeTimeout time.Duration
+
+	// FailureThreshold is the number of consecutive failed health checks
+	// after which the node is considered down
+	FailureThreshold int
+
+	// ElectionBackoff is the delay before electing a new leader, after the
+	// leader of a shard has failed. The delay is randomized for each shard,
+	// and it grows when the leader of the same shard keeps failing
+	ElectionBackoff time.Duration
+}
+
+func (o FailureDetectorOptions) Validate() error {
+	switch {
+	case o.ProbeInterval < 0:
+		return errors.New("the probe interval must not be negative")
+	case o.ProbeTimeout < 0:
+		return errors.New("the probe timeout must not be negative")
+	case o.FailureThreshold < 0:
+		return errors.New("the failure threshold must not be negative")
+	case o.ElectionBackoff < 0:
+		return errors.New("the election backoff must not be negative")
+	}
+	return nil
+}
+
+func (o FailureDetectorOptions) withDefaults() FailureDetectorOptions {
+	if o.ProbeInterval == 0 {
+		o.ProbeInterval = DefaultProbeInterval
+	}
+	if o.ProbeTimeout == 0 {
+		o.ProbeTimeout = DefaultProbeTimeout
+	}
+	if o.FailureThreshold == 0 {
+		o.FailureThreshold = DefaultFailureThreshold
+	}
+	if o.ElectionBackoff == 0 {
+		o.ElectionBackoff = DefaultElectionBackoff
+	}
+	return o
+}
+
+// electionBackoff spreads over time the elections of the shards led by a
+// failed node, and slows down the elections of a shard whose leaders keep
+// failing, as with a node that is flapping. It's reset once the shard had
+// no failures for the max backoff.
+type electionBackoff struct {
+	backOff      *backoff.ExponentialBackOff
+	lastFailure  time.Time
+	resetTimeout time.Duration
+}
+
+func newElectionBackoff(initialInterval time.Duration) *electionBackoff {
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     initialInterval,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		Multiplier:          backoff.DefaultMultiplier,
+		MaxInterval:         max(initialInterval, maxElectionBackoff),
+		MaxElapsedTime:      0,
+		Stop:                backoff.Stop,
+		Clock:               backoff.SystemClock,
+	}
+	b.Reset()
+
+	return &electionBackoff{
+		backOff:      b,
+		resetTimeout: b.MaxInterval,
+	}
+}
+
+// next returns the delay before the election that follows a failure of the
+// leader.
+func (e *electionBackoff) next() time.Duration {
+	if e.backOff.InitialInterval <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	if !e.lastFailure.IsZero() && now.Sub(e.lastFailure) > e.resetTimeout {
+		e.backOff.Reset()
+	}
+	e.lastFailure = now
+	return e.backOff.NextBackOff()
+}
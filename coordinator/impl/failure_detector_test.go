@@ -0,0 +1,61 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureDetectorOptions(t *testing.T) {
+	assert.NoError(t, FailureDetectorOptions{}.Validate())
+	assert.Error(t, FailureDetectorOptions{ProbeInterval: -1}.Validate())
+	assert.Error(t, FailureDetectorOptions{ProbeTimeout: -1}.Validate())
+	assert.Error(t, FailureDetectorOptions{FailureThreshold: -1}.Validate())
+	assert.Error(t, FailureDetectorOptions{ElectionBackoff: -1}.Validate())
+
+	assert.Equal(t, FailureDetectorOptions{
+		ProbeInterval:    DefaultProbeInterval,
+		ProbeTimeout:     DefaultProbeTimeout,
+		FailureThreshold: DefaultFailureThreshold,
+		ElectionBackoff:  DefaultElectionBackoff,
+	}, FailureDetectorOptions{}.withDefaults())
+
+	assert.Equal(t, 5, FailureDetectorOptions{FailureThreshold: 5}.withDefaults().FailureThreshold)
+}
+
+func TestElectionBackoff(t *testing.T) {
+	e := newElectionBackoff(100 * time.Millisecond)
+
+	// Each delay is randomized by 50% around an interval that grows by 50%
+	interval := 100 * time.Millisecond
+	for i := 0; i < 4; i++ {
+		d := e.next()
+		assert.GreaterOrEqual(t, d, interval/2)
+		assert.LessOrEqual(t, d, interval*3/2)
+		interval = interval * 3 / 2
+	}
+
+	// Without failures for a while, the backoff starts again from the
+	// initial interval
+	e.lastFailure = time.Now().Add(-2 * e.resetTimeout)
+	d := e.next()
+	assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+	assert.LessOrEqual(t, d, 150*time.Millisecond)
+
+	assert.Zero(t, newElectionBackoff(0).next())
+}
@@ -24,6 +24,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -56,6 +57,18 @@ func NewK8SClientset(config *rest.Config) kubernetes.Interface {
 	return clientset
 }
 
+func NewK8SDynamicClient(config *rest.Config) dynamic.Interface {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		slog.Error(
+			"failed to create dynamic client",
+			slog.Any("error", err),
+		)
+		os.Exit(1)
+	}
+	return client
+}
+
 func K8SConfigMaps(kc kubernetes.Interface) Client[corev1.ConfigMap] {
 	return newNamespaceClient[corev1.ConfigMap](func(namespace string) ResourceInterface[corev1.ConfigMap] {
 		return kc.CoreV1().ConfigMaps(namespace)
@@ -83,6 +96,11 @@ type resource interface {
 
 type Client[Resource resource] interface {
 	Upsert(namespace, name string, resource *Resource) (*Resource, error)
+	// Create fails with AlreadyExists if the resource is already there
+	Create(namespace string, resource *Resource) (*Resource, error)
+	// Update fails with Conflict if the resource version of the resource
+	// is not the current one
+	Update(namespace string, resource *Resource) (*Resource, error)
 	Delete(namespace, name string) error
 	Get(namespace, name string) (*Resource, error)
 }
@@ -111,6 +129,16 @@ func (c *clientImpl[Resource]) Upsert(namespace, name string, resource *Resource
 	return result, err
 }
 
+func (c *clientImpl[Resource]) Create(namespace string, resource *Resource) (*Resource, error) {
+	client := c.clientFunc(namespace)
+	return client.Create(context.Background(), resource, metav1.CreateOptions{FieldManager: fieldManager})
+}
+
+func (c *clientImpl[Resource]) Update(namespace string, resource *Resource) (*Resource, error) {
+	client := c.clientFunc(namespace)
+	return client.Update(context.Background(), resource, metav1.UpdateOptions{FieldManager: fieldManager})
+}
+
 func (c *clientImpl[Resource]) Delete(namespace, name string) error {
 	client := c.clientFunc(namespace)
 	return client.Delete(context.Background(), name, metav1.DeleteOptions{})
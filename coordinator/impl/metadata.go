@@ -19,6 +19,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/coordinator/model"
 )
 
@@ -38,3 +39,26 @@ type MetadataProvider interface {
 
 	Store(cs *model.ClusterStatus, expectedVersion Version) (newVersion Version, err error)
 }
+
+// metadataProviderWithMetrics counts the cluster status updates that could
+// not be stored, whatever the metadata provider in use.
+type metadataProviderWithMetrics struct {
+	MetadataProvider
+	storeFailed metrics.Counter
+}
+
+func newMetadataProviderWithMetrics(provider MetadataProvider) MetadataProvider {
+	return &metadataProviderWithMetrics{
+		MetadataProvider: provider,
+		storeFailed: metrics.NewCounter("oxia_coordinator_metadata_store_failed",
+			"The number of cluster status updates that could not be stored", "count", nil),
+	}
+}
+
+func (m *metadataProviderWithMetrics) Store(cs *model.ClusterStatus, expectedVersion Version) (Version, error) {
+	version, err := m.MetadataProvider.Store(cs, expectedVersion)
+	if err != nil {
+		m.storeFailed.Inc()
+	}
+	return version, err
+}
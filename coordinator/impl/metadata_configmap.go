@@ -15,42 +15,82 @@
 package impl
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"log/slog"
-	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8s "k8s.io/client-go/kubernetes"
 
+	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/coordinator/model"
 )
 
+const (
+	configMapStatusKey = "status"
+
+	// configMapSchemaVersion is increased on the incompatible changes of the
+	// stored format. New fields don't require a new version, since the
+	// unknown fields are ignored when reading the status
+	configMapSchemaVersion = 1
+
+	metadataStoreMaxRetries = 10
+)
+
+// configMapStatus is the versioned format of the cluster status stored in
+// the ConfigMap.
+type configMapStatus struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	ClusterStatus *model.ClusterStatus `json:"clusterStatus"`
+}
+
+// metadataProviderConfigMap keeps the cluster status in a ConfigMap. The
+// updates are conditional on the resource version of the ConfigMap, so that
+// a coordinator that lost the leadership cannot overwrite the status written
+// by the new one.
 type metadataProviderConfigMap struct {
 	sync.Mutex
 	kubernetes      k8s.Interface
 	namespace, name string
 
+	initialRetryBackoff time.Duration
+
 	metadataSize      atomic.Int64
 	getLatencyHisto   metrics.LatencyHistogram
 	storeLatencyHisto metrics.LatencyHistogram
+	storeErrors       metrics.Counter
 	metadataSizeGauge metrics.Gauge
+	log               *slog.Logger
 }
 
 func NewMetadataProviderConfigMap(kc k8s.Interface, namespace, name string) MetadataProvider {
 	m := &metadataProviderConfigMap{
-		kubernetes: kc,
-		namespace:  namespace,
-		name:       name,
+		kubernetes:          kc,
+		namespace:           namespace,
+		name:                name,
+		initialRetryBackoff: 100 * time.Millisecond,
 
 		getLatencyHisto: metrics.NewLatencyHistogram("oxia_coordinator_metadata_get_latency",
 			"Latency for reading coordinator metadata", nil),
 		storeLatencyHisto: metrics.NewLatencyHistogram("oxia_coordinator_metadata_store_latency",
 			"Latency for storing coordinator metadata", nil),
+		storeErrors: metrics.NewCounter("oxia_coordinator_metadata_store_errors",
+			"The number of failed attempts to store the coordinator metadata", "count", nil),
+		log: slog.With(
+			slog.String("component", "metadata-configmap"),
+			slog.String("namespace", namespace),
+			slog.String("name", name),
+		),
 	}
 
 	m.metadataSizeGauge = metrics.NewGauge("oxia_coordinator_metadata_size",
@@ -79,10 +119,10 @@ func (m *metadataProviderConfigMap) getWithoutLock() (*model.ClusterStatus, Vers
 		return nil, "", err
 	}
 
-	status := &model.ClusterStatus{}
-	data := []byte(cm.Data["status"])
-	if err = yaml.Unmarshal(data, status); err != nil {
-		return nil, "", err
+	data := []byte(cm.Data[configMapStatusKey])
+	status, err := unmarshalConfigMapStatus(data)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid cluster status in configmap %s/%s", m.namespace, m.name)
 	}
 
 	version := Version(cm.ResourceVersion)
@@ -97,39 +137,135 @@ func (m *metadataProviderConfigMap) Store(status *model.ClusterStatus, expectedV
 	m.Lock()
 	defer m.Unlock()
 
-	_, version, err := m.getWithoutLock()
-	if err != nil {
-		return version, err
+	// An update without a resource version would not be conditional
+	if expectedVersion == "" {
+		panic(ErrMetadataBadVersion)
 	}
 
-	if version != expectedVersion {
-		panic(ErrMetadataBadVersion)
+	data, err := json.Marshal(configMapStatus{
+		SchemaVersion: configMapSchemaVersion,
+		ClusterStatus: status,
+	})
+	if err != nil {
+		return "", err
 	}
+	cm := configMap(m.name, data, expectedVersion)
 
-	data := configMap(m.name, status, expectedVersion)
-	cm, err := K8SConfigMaps(m.kubernetes).Upsert(m.namespace, m.name, data)
-	if k8serrors.IsConflict(err) {
-		panic(ErrMetadataBadVersion)
+	// A failed attempt might still have been applied, in which case the retry
+	// finds the version already changed by the write itself
+	ambiguous := false
+	var version Version
+
+	err = backoff.RetryNotify(func() error {
+		res, err := m.storeOnce(cm, expectedVersion)
+		switch {
+		case err == nil:
+			version = Version(res.ResourceVersion)
+			return nil
+
+		case isMetadataVersionConflict(err):
+			if ambiguous {
+				var stored bool
+				if version, stored = m.alreadyStored(data); stored {
+					return nil
+				}
+			}
+
+			m.log.Error(
+				"The cluster status was changed by another coordinator",
+				slog.Any("expected-version", expectedVersion),
+				slog.Any("error", err),
+			)
+			panic(ErrMetadataBadVersion)
+
+		default:
+			ambiguous = true
+			m.storeErrors.Inc()
+			return err
+		}
+	}, backoff.WithMaxRetries(common.NewBackOffWithInitialInterval(context.Background(), m.initialRetryBackoff), metadataStoreMaxRetries),
+		func(err error, duration time.Duration) {
+			m.log.Warn(
+				"Failed to store the cluster status, retrying later",
+				slog.Any("error", err),
+				slog.Duration("retry-after", duration),
+			)
+		})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to store the cluster status in configmap %s/%s", m.namespace, m.name)
 	}
-	version = Version(cm.ResourceVersion)
-	m.metadataSize.Store(int64(len(data.Data["status"])))
+
+	m.metadataSize.Store(int64(len(data)))
 	return version, nil
 }
 
+// The ConfigMap is created if it's expected not to exist, otherwise it's
+// replaced only if it still has the expected resource version.
+func (m *metadataProviderConfigMap) storeOnce(cm *corev1.ConfigMap, expectedVersion Version) (*corev1.ConfigMap, error) {
+	if expectedVersion == MetadataNotExists {
+		return K8SConfigMaps(m.kubernetes).Create(m.namespace, cm.DeepCopy())
+	}
+	return K8SConfigMaps(m.kubernetes).Update(m.namespace, cm.DeepCopy())
+}
+
+// alreadyStored checks whether the ConfigMap already contains the data,
+// returning its current version.
+func (m *metadataProviderConfigMap) alreadyStored(data []byte) (Version, bool) {
+	cm, err := K8SConfigMaps(m.kubernetes).Get(m.namespace, m.name)
+	if err != nil || cm.Data[configMapStatusKey] != string(data) {
+		return "", false
+	}
+
+	m.log.Info(
+		"The cluster status was stored by a previous attempt",
+		slog.String("version", cm.ResourceVersion),
+	)
+	return Version(cm.ResourceVersion), true
+}
+
 func (*metadataProviderConfigMap) Close() error {
 	return nil
 }
 
-func configMap(name string, status *model.ClusterStatus, version Version) *corev1.ConfigMap {
-	bytes, err := yaml.Marshal(status)
-	if err != nil {
-		slog.Error(
-			"unable to marshal cluster status",
-			slog.Any("error", err),
-		)
-		os.Exit(1)
+// The ConfigMap was created or deleted, or its version changed, since the
+// coordinator read it.
+func isMetadataVersionConflict(err error) bool {
+	return k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err) || k8serrors.IsNotFound(err)
+}
+
+func unmarshalConfigMapStatus(data []byte) (*model.ClusterStatus, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty cluster status")
+	}
+
+	// The coordinators before the versioned format stored the status as yaml
+	if data[0] != '{' {
+		status := &model.ClusterStatus{}
+		if err := yaml.Unmarshal(data, status); err != nil {
+			return nil, err
+		}
+		return status, nil
 	}
 
+	cs := configMapStatus{}
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cs.SchemaVersion <= 0:
+		return nil, errors.Errorf("missing schema version")
+	case cs.SchemaVersion > configMapSchemaVersion:
+		return nil, errors.Errorf("schema version %d is not supported, the max supported is %d",
+			cs.SchemaVersion, configMapSchemaVersion)
+	case cs.ClusterStatus == nil:
+		return nil, errors.New("missing cluster status")
+	}
+	return cs.ClusterStatus, nil
+}
+
+func configMap(name string, data []byte, version Version) *corev1.ConfigMap {
 	cm := &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ConfigMap",
@@ -137,7 +273,7 @@ func configMap(name string, status *model.ClusterStatus, version Version) *corev
 		},
 		ObjectMeta: metav1.ObjectMeta{Name: name},
 		Data: map[string]string{
-			"status": string(bytes),
+			configMapStatusKey: string(data),
 		},
 	}
 
@@ -15,21 +15,27 @@
 package impl
 
 import (
+	"fmt"
+	"math"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
+	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/coordinator/model"
 )
 
 var (
-	_fake = func() *fake.Clientset {
-		f := fake.NewSimpleClientset()
-		f.PrependReactor("*", "*", K8SResourceVersionSupport(f.Tracker()))
-		return f
-	}()
+	_fake             = newFakeK8S()
 	metadataProviders = map[string]func(t *testing.T) MetadataProvider{
 		"memory": func(t *testing.T) MetadataProvider {
 			t.Helper()
@@ -83,3 +89,211 @@ func TestMetadataProvider(t *testing.T) {
 		})
 	}
 }
+
+func newFakeK8S() *fake.Clientset {
+	f := fake.NewSimpleClientset()
+	f.PrependReactor("*", "*", K8SResourceVersionSupport(f.Tracker()))
+	return f
+}
+
+func newTestClusterStatus(shardIdGenerator int64) *model.ClusterStatus {
+	cs := model.NewClusterStatus()
+	cs.ShardIdGenerator = shardIdGenerator
+	return cs
+}
+
+// Store returns ErrMetadataBadVersion, instead of panicking with it.
+func storeOrBadVersion(m MetadataProvider, cs *model.ClusterStatus, expectedVersion Version) (version Version, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	return m.Store(cs, expectedVersion)
+}
+
+func TestMetadataProviderConfigMap_ConcurrentWriters(t *testing.T) {
+	kc := newFakeK8S()
+	m1 := NewMetadataProviderConfigMap(kc, "ns", "n")
+	m2 := NewMetadataProviderConfigMap(kc, "ns", "n")
+
+	version, err := m1.Store(newTestClusterStatus(0), MetadataNotExists)
+	assert.NoError(t, err)
+
+	// Both coordinators try to update the same version of the status
+	wg := sync.WaitGroup{}
+	errs := make([]error, 2)
+	for i, m := range []MetadataProvider{m1, m2} {
+		wg.Add(1)
+		go func(i int, m MetadataProvider) {
+			defer wg.Done()
+			_, errs[i] = storeOrBadVersion(m, newTestClusterStatus(int64(i+1)), version)
+		}(i, m)
+	}
+	wg.Wait()
+
+	// Only one of them succeeds, and the status is the one that it wrote
+	winner := -1
+	for i, err := range errs {
+		if err == nil {
+			assert.Equal(t, -1, winner)
+			winner = i
+		} else {
+			assert.ErrorIs(t, err, ErrMetadataBadVersion)
+		}
+	}
+	assert.NotEqual(t, -1, winner)
+
+	res, _, err := m2.Get()
+	assert.NoError(t, err)
+	assert.EqualValues(t, winner+1, res.ShardIdGenerator)
+
+	// Two coordinators creating the initial status
+	kc = newFakeK8S()
+	m1 = NewMetadataProviderConfigMap(kc, "ns", "n")
+	m2 = NewMetadataProviderConfigMap(kc, "ns", "n")
+	_, err = m1.Store(newTestClusterStatus(1), MetadataNotExists)
+	assert.NoError(t, err)
+	_, err = storeOrBadVersion(m2, newTestClusterStatus(2), MetadataNotExists)
+	assert.ErrorIs(t, err, ErrMetadataBadVersion)
+}
+
+func TestMetadataProviderConfigMap_ZombieCoordinator(t *testing.T) {
+	kc := newFakeK8S()
+	oldCoordinator := NewMetadataProviderConfigMap(kc, "ns", "n")
+	newCoordinator := NewMetadataProviderConfigMap(kc, "ns", "n")
+
+	oldVersion, err := oldCoordinator.Store(newTestClusterStatus(1), MetadataNotExists)
+	assert.NoError(t, err)
+
+	// The new coordinator reloads the status and updates it
+	cs, version, err := newCoordinator.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, oldVersion, version)
+	cs.ShardIdGenerator = 2
+	_, err = newCoordinator.Store(cs, version)
+	assert.NoError(t, err)
+
+	// The old coordinator cannot overwrite it
+	_, err = storeOrBadVersion(oldCoordinator, newTestClusterStatus(3), oldVersion)
+	assert.ErrorIs(t, err, ErrMetadataBadVersion)
+	_, err = storeOrBadVersion(oldCoordinator, newTestClusterStatus(3), "")
+	assert.ErrorIs(t, err, ErrMetadataBadVersion)
+
+	cs, _, err = oldCoordinator.Get()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cs.ShardIdGenerator)
+}
+
+func TestMetadataProviderConfigMap_ExistingData(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		data  string
+		valid bool
+	}{
+		{"empty", "", false},
+		{"invalid-json", `{"schemaVersion": 1, "clusterStatus": {`, false},
+		{"invalid-yaml", "namespaces: [", false},
+		{"missing-schema-version", `{"clusterStatus": {"shardIdGenerator": 3}}`, false},
+		{"newer-schema-version", `{"schemaVersion": 2, "clusterStatus": {"shardIdGenerator": 3}}`, false},
+		{"missing-cluster-status", `{"schemaVersion": 1}`, false},
+		{"valid", `{"schemaVersion": 1, "clusterStatus": {"namespaces": {}, "shardIdGenerator": 3}}`, true},
+		{"unknown-fields", `{"schemaVersion": 1, "future": true, "clusterStatus": {"namespaces": {}, "shardIdGenerator": 3, "future": {}}}`, true},
+		{"legacy-yaml", "namespaces: {}\nshardIdGenerator: 3\nserverIdx: 0\n", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			kc := newFakeK8S()
+			_, err := K8SConfigMaps(kc).Create("ns", configMap("n", []byte(test.data), MetadataNotExists))
+			assert.NoError(t, err)
+
+			m := NewMetadataProviderConfigMap(kc, "ns", "n")
+			cs, version, err := m.Get()
+			if !test.valid {
+				assert.Error(t, err)
+				assert.Nil(t, cs)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.EqualValues(t, 3, cs.ShardIdGenerator)
+
+			// The status is then stored in the current format
+			_, err = m.Store(cs, version)
+			assert.NoError(t, err)
+			stored, err := K8SConfigMaps(kc).Get("ns", "n")
+			assert.NoError(t, err)
+			assert.JSONEq(t, `{"schemaVersion": 1, "clusterStatus": {"namespaces": {}, "shardIdGenerator": 3, "serverIdx": 0}}`,
+				stored.Data[configMapStatusKey])
+		})
+	}
+}
+
+func TestMetadataProviderConfigMap_FailedWrites(t *testing.T) {
+	for _, applied := range []bool{false, true} {
+		t.Run(fmt.Sprintf("applied-%v", applied), func(t *testing.T) {
+			kc := newFakeK8S()
+			m := NewMetadataProviderConfigMap(kc, "ns", "n")
+			m.(*metadataProviderConfigMap).initialRetryBackoff = time.Millisecond
+
+			version, err := m.Store(newTestClusterStatus(1), MetadataNotExists)
+			assert.NoError(t, err)
+
+			// The first update fails, though it might have been applied
+			failures := 1
+			apply := applied
+			kc.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if failures == 0 {
+					return false, nil, nil
+				}
+				failures--
+
+				if apply {
+					if handled, _, err := K8SResourceVersionSupport(kc.Tracker())(action); handled {
+						return true, nil, err
+					}
+					update := action.(k8stesting.UpdateAction)
+					if err := kc.Tracker().Update(update.GetResource(), update.GetObject(), update.GetNamespace()); err != nil {
+						return true, nil, err
+					}
+				}
+				return true, nil, k8serrors.NewServerTimeout(corev1.Resource("configmaps"), "update", 1)
+			})
+
+			newVersion, err := m.Store(newTestClusterStatus(2), version)
+			assert.NoError(t, err)
+			assert.NotEqual(t, version, newVersion)
+
+			cs, currentVersion, err := m.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, newVersion, currentVersion)
+			assert.EqualValues(t, 2, cs.ShardIdGenerator)
+
+			// When all the attempts fail, the error is returned
+			failures = math.MaxInt
+			apply = false
+			_, err = m.Store(newTestClusterStatus(3), newVersion)
+			assert.Error(t, err)
+			assert.True(t, k8serrors.IsServerTimeout(errors.Cause(err)))
+		})
+	}
+}
+
+func TestCoordinator_InvalidMetadata(t *testing.T) {
+	metadataProvider := NewMetadataProviderMemory()
+	cs := newTestClusterStatus(1)
+	cs.Namespaces[common.DefaultNamespace] = model.NamespaceStatus{
+		ReplicationFactor: 1,
+		Shards: map[int64]model.ShardMetadata{
+			// The shard has no ensemble
+			0: {Status: model.ShardStatusSteadyState, Term: 1},
+		},
+	}
+	_, err := metadataProvider.Store(cs, MetadataNotExists)
+	assert.NoError(t, err)
+
+	rpc := newMockRpcProvider()
+	_, err = NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) {
+		return model.ClusterConfig{}, nil
+	}, nil, rpc, testFailureDetector, RebalanceOptions{})
+	assert.ErrorContains(t, err, "invalid cluster status")
+}
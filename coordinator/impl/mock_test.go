@@ -29,6 +29,7 @@ import (
 	pb "google.golang.org/protobuf/proto"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/container"
 	"github.com/streamnative/oxia/coordinator/model"
 	"github.com/streamnative/oxia/proto"
 )
@@ -78,12 +79,14 @@ func (sap *mockShardAssignmentsProvider) WaitForNextUpdate(ctx context.Context,
 }
 
 type mockNodeAvailabilityListener struct {
-	events chan model.ServerAddress
+	events            chan model.ServerAddress
+	maintenanceEvents chan model.ServerAddress
 }
 
 func newMockNodeAvailabilityListener() *mockNodeAvailabilityListener {
 	return &mockNodeAvailabilityListener{
-		events: make(chan model.ServerAddress, 100),
+		events:            make(chan model.ServerAddress, 100),
+		maintenanceEvents: make(chan model.ServerAddress, 100),
 	}
 }
 
@@ -91,6 +94,10 @@ func (nal *mockNodeAvailabilityListener) NodeBecameUnavailable(node model.Server
 	nal.events <- node
 }
 
+func (nal *mockNodeAvailabilityListener) NodeEnteredMaintenance(node model.ServerAddress) {
+	nal.maintenanceEvents <- node
+}
+
 type mockPerNodeChannels struct {
 	newTermRequests  chan *proto.NewTermRequest
 	newTermResponses chan struct {
@@ -122,8 +129,22 @@ type mockPerNodeChannels struct {
 		error
 	}
 
+	removeFollowerRequests  chan *proto.RemoveFollowerRequest
+	removeFollowerResponses chan struct {
+		*proto.RemoveFollowerResponse
+		error
+	}
+
+	transferLeadershipRequests  chan *proto.TransferLeadershipRequest
+	transferLeadershipResponses chan struct {
+		*proto.TransferLeadershipResponse
+		error
+	}
+
 	shardAssignmentsStream *mockShardAssignmentClient
 	healthClient           *mockHealthClient
+	info                   *proto.GetInfoResponse
+	maintenance            bool
 	err                    error
 }
 
@@ -155,6 +176,16 @@ func (m *mockPerNodeChannels) expectAddFollowerRequest(t *testing.T, shard int64
 	assert.Equal(t, term, r.Term)
 }
 
+func (m *mockPerNodeChannels) expectRemoveFollowerRequest(t *testing.T, shard int64, term int64, follower string) {
+	t.Helper()
+
+	r := <-m.removeFollowerRequests
+
+	assert.Equal(t, shard, r.Shard)
+	assert.Equal(t, term, r.Term)
+	assert.Equal(t, follower, r.FollowerName)
+}
+
 func (m *mockPerNodeChannels) NewTermResponse(term int64, offset int64, err error) {
 	m.newTermResponses <- struct {
 		*proto.NewTermResponse
@@ -167,6 +198,32 @@ func (m *mockPerNodeChannels) NewTermResponse(term int64, offset int64, err erro
 	}, err}
 }
 
+func (m *mockPerNodeChannels) NewTermResponseInMaintenance(term int64, offset int64) {
+	m.newTermResponses <- struct {
+		*proto.NewTermResponse
+		error
+	}{&proto.NewTermResponse{
+		HeadEntryId: &proto.EntryId{
+			Term:   term,
+			Offset: offset,
+		},
+		Maintenance: true,
+	}, nil}
+}
+
+func (m *mockPerNodeChannels) NewTermResponseWithDiskFull(term int64, offset int64) {
+	m.newTermResponses <- struct {
+		*proto.NewTermResponse
+		error
+	}{&proto.NewTermResponse{
+		HeadEntryId: &proto.EntryId{
+			Term:   term,
+			Offset: offset,
+		},
+		DiskFull: true,
+	}, nil}
+}
+
 func (m *mockPerNodeChannels) BecomeLeaderResponse(err error) {
 	m.becomeLeaderResponses <- struct {
 		*proto.BecomeLeaderResponse
@@ -181,6 +238,38 @@ func (m *mockPerNodeChannels) AddFollowerResponse(err error) {
 	}{&proto.AddFollowerResponse{}, err}
 }
 
+func (m *mockPerNodeChannels) RemoveFollowerResponse(err error) {
+	m.removeFollowerResponses <- struct {
+		*proto.RemoveFollowerResponse
+		error
+	}{&proto.RemoveFollowerResponse{}, err}
+}
+
+func (m *mockPerNodeChannels) TransferLeadershipResponse(err error) {
+	m.transferLeadershipResponses <- struct {
+		*proto.TransferLeadershipResponse
+		error
+	}{&proto.TransferLeadershipResponse{}, err}
+}
+
+func (m *mockPerNodeChannels) GetStatusResponse(term int64, status proto.ServingStatus, headOffset int64, err error) {
+	m.getStatusResponses <- struct {
+		*proto.GetStatusResponse
+		error
+	}{&proto.GetStatusResponse{
+		Term:       term,
+		Status:     status,
+		HeadOffset: headOffset,
+	}, err}
+}
+
+func (m *mockPerNodeChannels) DeleteShardResponse(err error) {
+	m.deleteShardResponses <- struct {
+		*proto.DeleteShardResponse
+		error
+	}{&proto.DeleteShardResponse{}, err}
+}
+
 func newMockPerNodeChannels() *mockPerNodeChannels {
 	return &mockPerNodeChannels{
 		newTermRequests: make(chan *proto.NewTermRequest, 100),
@@ -198,11 +287,26 @@ func newMockPerNodeChannels() *mockPerNodeChannels {
 			*proto.GetStatusResponse
 			error
 		}, 100),
+		deleteShardRequests: make(chan *proto.DeleteShardRequest, 100),
+		deleteShardResponses: make(chan struct {
+			*proto.DeleteShardResponse
+			error
+		}, 100),
 		addFollowerRequests: make(chan *proto.AddFollowerRequest, 100),
 		addFollowerResponses: make(chan struct {
 			*proto.AddFollowerResponse
 			error
 		}, 100),
+		removeFollowerRequests: make(chan *proto.RemoveFollowerRequest, 100),
+		removeFollowerResponses: make(chan struct {
+			*proto.RemoveFollowerResponse
+			error
+		}, 100),
+		transferLeadershipRequests: make(chan *proto.TransferLeadershipRequest, 100),
+		transferLeadershipResponses: make(chan struct {
+			*proto.TransferLeadershipResponse
+			error
+		}, 100),
 		shardAssignmentsStream: newMockShardAssignmentClient(),
 		healthClient:           newMockHealthClient(),
 	}
@@ -235,6 +339,14 @@ func (r *mockRpcProvider) RecoverNode(node model.ServerAddress) {
 	n.err = nil
 }
 
+func (r *mockRpcProvider) SetLocality(node model.ServerAddress, zone string, host string) {
+	r.Lock()
+	defer r.Unlock()
+
+	n := r.getNode(node)
+	n.info = &proto.GetInfoResponse{Zone: zone, Host: host}
+}
+
 func (r *mockRpcProvider) GetNode(node model.ServerAddress) *mockPerNodeChannels {
 	r.Lock()
 	defer r.Unlock()
@@ -333,6 +445,20 @@ func (r *mockRpcProvider) GetStatus(ctx context.Context, node model.ServerAddres
 	}
 }
 
+func (r *mockRpcProvider) GetInfo(_ context.Context, node model.ServerAddress) (*proto.GetInfoResponse, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	s := r.getNode(node)
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.info == nil {
+		return &proto.GetInfoResponse{}, nil
+	}
+	return s.info, nil
+}
+
 func (r *mockRpcProvider) DeleteShard(ctx context.Context, node model.ServerAddress, req *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error) {
 	r.Lock()
 
@@ -356,6 +482,10 @@ func (r *mockRpcProvider) DeleteShard(ctx context.Context, node model.ServerAddr
 	}
 }
 
+func (*mockRpcProvider) SplitShard(context.Context, model.ServerAddress, *proto.SplitShardRequest) (*proto.SplitShardResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (r *mockRpcProvider) AddFollower(ctx context.Context, node model.ServerAddress, req *proto.AddFollowerRequest) (*proto.AddFollowerResponse, error) {
 	r.Lock()
 
@@ -379,6 +509,75 @@ func (r *mockRpcProvider) AddFollower(ctx context.Context, node model.ServerAddr
 	}
 }
 
+func (r *mockRpcProvider) RemoveFollower(ctx context.Context, node model.ServerAddress, req *proto.RemoveFollowerRequest) (*proto.RemoveFollowerResponse, error) {
+	r.Lock()
+
+	s := r.getNode(node)
+	s.removeFollowerRequests <- req
+
+	if s.err != nil {
+		r.Unlock()
+		return nil, s.err
+	}
+
+	r.Unlock()
+
+	select {
+	case response := <-s.removeFollowerResponses:
+		return response.RemoveFollowerResponse, response.error
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(3 * time.Second):
+		return nil, errors.New("timeout")
+	}
+}
+
+func (r *mockRpcProvider) TransferLeadership(ctx context.Context, node model.ServerAddress, req *proto.TransferLeadershipRequest) (*proto.TransferLeadershipResponse, error) {
+	r.Lock()
+
+	s := r.getNode(node)
+	s.transferLeadershipRequests <- req
+
+	if s.err != nil {
+		r.Unlock()
+		return nil, s.err
+	}
+
+	r.Unlock()
+
+	select {
+	case response := <-s.transferLeadershipResponses:
+		return response.TransferLeadershipResponse, response.error
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(3 * time.Second):
+		return nil, errors.New("timeout")
+	}
+}
+
+func (r *mockRpcProvider) SetMaintenance(_ context.Context, node model.ServerAddress, req *proto.SetMaintenanceRequest) (*proto.SetMaintenanceResponse, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	s := r.getNode(node)
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.maintenance = req.Enabled
+	return &proto.SetMaintenanceResponse{}, nil
+}
+
+func (r *mockRpcProvider) CompactShard(_ context.Context, node model.ServerAddress, _ *proto.CompactShardRequest) (*proto.CompactShardResponse, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	s := r.getNode(node)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &proto.CompactShardResponse{}, nil
+}
+
 func (r *mockRpcProvider) GetHealthClient(node model.ServerAddress) (grpc_health_v1.HealthClient, error) {
 	return r.GetNode(node).healthClient, nil
 }
@@ -448,18 +647,28 @@ func (m *mockShardAssignmentClient) RecvMsg(any) error {
 type mockHealthClient struct {
 	sync.Mutex
 
-	status  grpc_health_v1.HealthCheckResponse_ServingStatus
-	err     error
-	watches []*mockHealthWatchClient
+	status           grpc_health_v1.HealthCheckResponse_ServingStatus
+	leadershipStatus grpc_health_v1.HealthCheckResponse_ServingStatus
+	err              error
+	failures         int
+	watches          []*mockHealthWatchClient
 }
 
 func newMockHealthClient() *mockHealthClient {
 	return &mockHealthClient{
-		status:  grpc_health_v1.HealthCheckResponse_SERVING,
-		watches: make([]*mockHealthWatchClient, 0),
+		status:           grpc_health_v1.HealthCheckResponse_SERVING,
+		leadershipStatus: grpc_health_v1.HealthCheckResponse_SERVING,
+		watches:          make([]*mockHealthWatchClient, 0),
 	}
 }
 
+func (m *mockHealthClient) SetLeadershipStatus(status grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.leadershipStatus = status
+}
+
 func (m *mockHealthClient) SetStatus(status grpc_health_v1.HealthCheckResponse_ServingStatus) {
 	m.Lock()
 	defer m.Unlock()
@@ -481,12 +690,34 @@ func (m *mockHealthClient) SetError(err error) {
 	}
 }
 
+// FailNextChecks makes the next n health checks of the node fail.
+func (m *mockHealthClient) FailNextChecks(n int) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.failures = n
+}
+
+func (m *mockHealthClient) PendingFailures() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.failures
+}
+
 func (m *mockHealthClient) Check(ctx context.Context, in *grpc_health_v1.HealthCheckRequest, opts ...grpc.CallOption) (*grpc_health_v1.HealthCheckResponse, error) {
 	m.Lock()
 	defer m.Unlock()
 	if m.err != nil {
 		return nil, m.err
 	}
+	if in.Service == "" && m.failures > 0 {
+		m.failures--
+		return nil, context.DeadlineExceeded
+	}
+	if in.Service == container.LeadershipHealthService {
+		return &grpc_health_v1.HealthCheckResponse{Status: m.leadershipStatus}, nil
+	}
 	return &grpc_health_v1.HealthCheckResponse{Status: m.status}, nil
 }
 
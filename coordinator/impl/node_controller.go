@@ -19,13 +19,17 @@ import (
 	"io"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/container"
 	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/coordinator/model"
 	"github.com/streamnative/oxia/proto"
@@ -39,11 +43,20 @@ const (
 	Draining //
 )
 
-const (
-	healthCheckProbeInterval   = 2 * time.Second
-	healthCheckProbeTimeout    = 2 * time.Second
-	defaultInitialRetryBackoff = 10 * time.Second
-)
+func (s NodeStatus) String() string {
+	switch s {
+	case Running:
+		return "Running"
+	case NotRunning:
+		return "NotRunning"
+	case Draining:
+		return "Draining"
+	default:
+		return "Unknown"
+	}
+}
+
+const defaultInitialRetryBackoff = 10 * time.Second
 
 // The NodeController takes care of checking the health-status of each node
 // and to push all the service discovery updates.
@@ -53,6 +66,14 @@ type NodeController interface {
 	Status() NodeStatus
 
 	SetStatus(status NodeStatus)
+
+	// InMaintenance tells whether the node has asked for its leaderships
+	// to be moved away
+	InMaintenance() bool
+
+	// Locality returns where the node is running, or nil if it's not
+	// known yet
+	Locality() *model.ServerLocality
 }
 
 type nodeController struct {
@@ -66,25 +87,39 @@ type nodeController struct {
 	ctx                      context.Context
 	cancel                   context.CancelFunc
 	initialRetryBackoff      time.Duration
+	failureDetector          FailureDetectorOptions
+
+	// The number of health checks that failed since the last successful one
+	consecutiveFailures atomic.Int64
+
+	// Whether the node has asked for its leaderships to be moved away
+	leadershipTransferRequested bool
+
+	// Where the node is running. It's fetched again when the node comes
+	// back, since it might have been moved to another host
+	locality *model.ServerLocality
 
 	sendAssignmentsCtx    context.Context
 	sendAssignmentsCancel context.CancelFunc
 
 	nodeIsRunningGauge metrics.Gauge
+	suspicionGauge     metrics.Gauge
 	failedHealthChecks metrics.Counter
 }
 
 func NewNodeController(addr model.ServerAddress,
 	shardAssignmentsProvider ShardAssignmentsProvider,
 	nodeAvailabilityListener NodeAvailabilityListener,
-	rpc RpcProvider) NodeController {
-	return newNodeController(addr, shardAssignmentsProvider, nodeAvailabilityListener, rpc, defaultInitialRetryBackoff)
+	rpc RpcProvider,
+	failureDetector FailureDetectorOptions) NodeController {
+	return newNodeController(addr, shardAssignmentsProvider, nodeAvailabilityListener, rpc, failureDetector, defaultInitialRetryBackoff)
 }
 
 func newNodeController(addr model.ServerAddress,
 	shardAssignmentsProvider ShardAssignmentsProvider,
 	nodeAvailabilityListener NodeAvailabilityListener,
 	rpc RpcProvider,
+	failureDetector FailureDetectorOptions,
 	initialRetryBackoff time.Duration) NodeController {
 	labels := map[string]any{"node": addr.Internal}
 	nc := &nodeController{
@@ -98,6 +133,7 @@ func newNodeController(addr model.ServerAddress,
 			slog.Any("addr", addr),
 		),
 		initialRetryBackoff: initialRetryBackoff,
+		failureDetector:     failureDetector.withDefaults(),
 
 		failedHealthChecks: metrics.NewCounter("oxia_coordinator_node_health_checks_failed",
 			"The number of failed health checks to a node", "count", labels),
@@ -112,6 +148,10 @@ func newNodeController(addr model.ServerAddress,
 			}
 			return 0
 		})
+	nc.suspicionGauge = metrics.NewGauge("oxia_coordinator_node_suspicion",
+		"The number of consecutive failed health checks to a node", "count", labels, func() int64 {
+			return nc.consecutiveFailures.Load()
+		})
 
 	go common.DoWithLabels(
 		nc.ctx,
@@ -119,7 +159,7 @@ func newNodeController(addr model.ServerAddress,
 			"oxia": "node-controller",
 			"addr": nc.addr.Internal,
 		},
-		nc.healthCheckWithRetries,
+		nc.healthCheckLoop,
 	)
 
 	go common.DoWithLabels(
@@ -148,117 +188,174 @@ func (n *nodeController) SetStatus(status NodeStatus) {
 	n.log.Info("Changed status", slog.Any("status", status))
 }
 
-func (n *nodeController) healthCheckWithRetries() {
-	backOff := common.NewBackOffWithInitialInterval(n.ctx, n.initialRetryBackoff)
-	_ = backoff.RetryNotify(func() error {
-		return n.healthCheck(backOff)
-	}, backOff, func(err error, duration time.Duration) {
-		if n.Status() == Draining {
-			// Stop the health check and close
-			_ = n.Close()
-			n.nodeAvailabilityListener.NodeBecameUnavailable(n.addr)
-			return
-		}
-
-		n.log.Warn(
-			"Storage node health check failed",
-			slog.Any("error", err),
-			slog.Duration("retry-after", duration),
-		)
-
-		n.Lock()
-		defer n.Unlock()
-		if n.status == Running {
-			n.status = NotRunning
-			n.failedHealthChecks.Inc()
-			n.nodeAvailabilityListener.NodeBecameUnavailable(n.addr)
-		}
+func (n *nodeController) InMaintenance() bool {
+	n.Lock()
+	defer n.Unlock()
+	return n.leadershipTransferRequested
+}
 
-		// To avoid the send assignments stream to miss the notification about the current
-		// node went down, we interrupt the current stream when the ping on the node fails
-		if n.sendAssignmentsCancel != nil {
-			n.sendAssignmentsCancel()
-		}
-	})
+func (n *nodeController) Locality() *model.ServerLocality {
+	n.Lock()
+	defer n.Unlock()
+	return n.locality
 }
 
-func (n *nodeController) healthCheckLoop(ctx context.Context, health grpc_health_v1.HealthClient) {
-	ticker := time.NewTicker(healthCheckProbeInterval)
+// healthCheckLoop probes the health of the node periodically. A single
+// failed probe is not enough to consider the node down, since it might be
+// caused by a pause of the node or by a transient network issue.
+func (n *nodeController) healthCheckLoop() {
+	ticker := time.NewTicker(n.failureDetector.ProbeInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			pingCtx, pingCancel := context.WithTimeout(ctx, healthCheckProbeTimeout)
+			n.probe()
 
-			res, err := health.Check(pingCtx, &grpc_health_v1.HealthCheckRequest{Service: ""})
-			pingCancel()
-			if err2 := n.processHealthCheckResponse(res, err); err2 != nil {
-				n.log.Warn("Node stopped responding to ping")
-				return
-			}
-
-		case <-ctx.Done():
+		case <-n.ctx.Done():
 			return
 		}
 	}
 }
 
-func (n *nodeController) healthCheck(backoff backoff.BackOff) error {
+func (n *nodeController) probe() {
 	health, err := n.rpc.GetHealthClient(n.addr)
+	if err == nil {
+		err = n.healthCheck(health)
+	}
+
 	if err != nil {
-		return err
+		n.healthCheckFailed(err)
+		return
 	}
 
-	ctx, cancel := context.WithCancel(n.ctx)
-	defer cancel()
+	n.healthCheckSucceeded()
+	n.checkLeadershipTransfer(health)
+	n.checkLocality()
+}
 
-	go common.DoWithLabels(
-		n.ctx,
-		map[string]string{
-			"oxia": "node-controller-health-check-ping",
-			"addr": n.addr.Internal,
-		},
-		func() {
-			defer cancel()
-			n.healthCheckLoop(ctx, health)
-		},
-	)
+func (n *nodeController) healthCheck(health grpc_health_v1.HealthClient) error {
+	ctx, cancel := context.WithTimeout(n.ctx, n.failureDetector.ProbeTimeout)
+	defer cancel()
 
-	watch, err := health.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: ""})
+	res, err := health.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: ""})
 	if err != nil {
 		return err
 	}
 
-	for ctx.Err() == nil {
-		res, err := watch.Recv()
+	if res.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return errors.New("node is not actively serving")
+	}
+	return nil
+}
 
-		if err2 := n.processHealthCheckResponse(res, err); err2 != nil {
-			return err2
-		}
+func (n *nodeController) healthCheckFailed(err error) {
+	failures := n.consecutiveFailures.Add(1)
+	n.failedHealthChecks.Inc()
 
-		backoff.Reset()
+	if n.Status() == Draining {
+		// Stop the health check and close
+		_ = n.Close()
+		n.nodeAvailabilityListener.NodeBecameUnavailable(n.addr)
+		return
 	}
 
-	return ctx.Err()
-}
+	n.log.Warn(
+		"Storage node health check failed",
+		slog.Any("error", err),
+		slog.Int64("consecutive-failures", failures),
+		slog.Int("failure-threshold", n.failureDetector.FailureThreshold),
+	)
 
-func (n *nodeController) processHealthCheckResponse(res *grpc_health_v1.HealthCheckResponse, err error) error {
-	if err != nil {
-		return err
+	if failures < int64(n.failureDetector.FailureThreshold) {
+		return
 	}
 
-	if res.Status != grpc_health_v1.HealthCheckResponse_SERVING {
-		return errors.New("node is not actively serving")
+	n.Lock()
+	defer n.Unlock()
+	if n.status == Running {
+		n.log.Warn("Storage node is considered down")
+		n.status = NotRunning
+		n.locality = nil
+		n.nodeAvailabilityListener.NodeBecameUnavailable(n.addr)
+	}
+
+	// To avoid the send assignments stream to miss the notification about the current
+	// node went down, we interrupt the current stream when the ping on the node fails
+	if n.sendAssignmentsCancel != nil {
+		n.sendAssignmentsCancel()
 	}
+}
+
+func (n *nodeController) healthCheckSucceeded() {
+	n.consecutiveFailures.Store(0)
 
 	n.Lock()
+	defer n.Unlock()
 	if n.status == NotRunning {
 		n.log.Info("Storage node is back online")
+		n.status = Running
+	}
+}
+
+// checkLeadershipTransfer notifies the listener when the node enters
+// maintenance mode and asks for its leaderships to be transferred.
+func (n *nodeController) checkLeadershipTransfer(health grpc_health_v1.HealthClient) {
+	ctx, cancel := context.WithTimeout(n.ctx, n.failureDetector.ProbeTimeout)
+	defer cancel()
+
+	res, err := health.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: container.LeadershipHealthService})
+	if err != nil {
+		// Nodes that don't support maintenance mode do not register the service
+		return
 	}
-	n.status = Running
+
+	requested := res.Status == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+
+	n.Lock()
+	changed := requested && !n.leadershipTransferRequested
+	n.leadershipTransferRequested = requested
 	n.Unlock()
 
-	return nil
+	if changed {
+		n.log.Info("Storage node entered maintenance mode, transferring its leaderships")
+		n.nodeAvailabilityListener.NodeEnteredMaintenance(n.addr)
+	}
+}
+
+// checkLocality fetches the locality of the node, if it's not known yet.
+func (n *nodeController) checkLocality() {
+	if n.Locality() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.failureDetector.ProbeTimeout)
+	defer cancel()
+
+	res, err := n.rpc.GetInfo(ctx, n.addr)
+	if err != nil {
+		if status.Code(err) != codes.Unimplemented {
+			n.log.Warn(
+				"Failed to get the locality of the storage node",
+				slog.Any("error", err),
+			)
+			return
+		}
+
+		// The node is running an older version, which doesn't report
+		// its locality
+		res = &proto.GetInfoResponse{}
+	}
+
+	locality := &model.ServerLocality{Zone: res.Zone, Host: res.Host}
+	n.log.Info(
+		"Received the locality of the storage node",
+		slog.Any("locality", locality),
+	)
+
+	n.Lock()
+	n.locality = locality
+	n.Unlock()
 }
 
 func (n *nodeController) sendAssignmentsUpdatesWithRetries() {
@@ -348,6 +445,7 @@ func (n *nodeController) sendAssignmentsUpdates(backoff backoff.BackOff) error {
 
 func (n *nodeController) Close() error {
 	n.nodeIsRunningGauge.Unregister()
+	n.suspicionGauge.Unregister()
 	n.cancel()
 
 	n.log.Info("Closed node controller")
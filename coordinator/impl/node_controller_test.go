@@ -36,7 +36,7 @@ func TestNodeController_HealthCheck(t *testing.T) {
 	sap := newMockShardAssignmentsProvider()
 	nal := newMockNodeAvailabilityListener()
 	rpc := newMockRpcProvider()
-	nc := newNodeController(addr, sap, nal, rpc, 1*time.Second)
+	nc := newNodeController(addr, sap, nal, rpc, testFailureDetector, 1*time.Second)
 
 	assert.Equal(t, Running, nc.Status())
 
@@ -64,6 +64,45 @@ func TestNodeController_HealthCheck(t *testing.T) {
 	assert.NoError(t, nc.Close())
 }
 
+func TestNodeController_IntermittentFailures(t *testing.T) {
+	addr := model.ServerAddress{
+		Public:   "my-server:9190",
+		Internal: "my-server:8190",
+	}
+
+	sap := newMockShardAssignmentsProvider()
+	nal := newMockNodeAvailabilityListener()
+	rpc := newMockRpcProvider()
+	nc := newNodeController(addr, sap, nal, rpc, testFailureDetector, 1*time.Second).(*nodeController)
+
+	node := rpc.GetNode(addr)
+
+	// The failures below the threshold, like during a GC pause, don't make
+	// the node unavailable
+	for i := 0; i < 5; i++ {
+		node.healthClient.FailNextChecks(testFailureDetector.FailureThreshold - 1)
+
+		assert.Eventually(t, func() bool {
+			return node.healthClient.PendingFailures() == 0 && nc.consecutiveFailures.Load() == 0
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	assert.Equal(t, Running, nc.Status())
+	assert.Empty(t, nal.events)
+
+	node.healthClient.FailNextChecks(testFailureDetector.FailureThreshold)
+
+	unavailableNode := <-nal.events
+	assert.Equal(t, addr, unavailableNode)
+	assert.EqualValues(t, testFailureDetector.FailureThreshold, nc.consecutiveFailures.Load())
+
+	assert.Eventually(t, func() bool {
+		return nc.Status() == Running && nc.consecutiveFailures.Load() == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, nc.Close())
+}
+
 func TestNodeController_ShardsAssignments(t *testing.T) {
 	addr := model.ServerAddress{
 		Public:   "my-server:9190",
@@ -73,7 +112,7 @@ func TestNodeController_ShardsAssignments(t *testing.T) {
 	sap := newMockShardAssignmentsProvider()
 	nal := newMockNodeAvailabilityListener()
 	rpc := newMockRpcProvider()
-	nc := newNodeController(addr, sap, nal, rpc, 1*time.Second)
+	nc := newNodeController(addr, sap, nal, rpc, testFailureDetector, 1*time.Second)
 
 	node := rpc.GetNode(addr)
 
@@ -122,3 +161,63 @@ func TestNodeController_ShardsAssignments(t *testing.T) {
 
 	assert.NoError(t, nc.Close())
 }
+
+func TestNodeController_MaintenanceMode(t *testing.T) {
+	addr := model.ServerAddress{
+		Public:   "my-server:9190",
+		Internal: "my-server:8190",
+	}
+
+	sap := newMockShardAssignmentsProvider()
+	nal := newMockNodeAvailabilityListener()
+	rpc := newMockRpcProvider()
+	nc := newNodeController(addr, sap, nal, rpc, testFailureDetector, 1*time.Second)
+
+	node := rpc.GetNode(addr)
+	node.healthClient.SetLeadershipStatus(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	maintenanceNode := <-nal.maintenanceEvents
+	assert.Equal(t, addr, maintenanceNode)
+
+	// The node is still available as follower
+	assert.Equal(t, Running, nc.Status())
+	assert.Empty(t, nal.events)
+
+	assert.NoError(t, nc.Close())
+}
+
+func TestNodeController_Locality(t *testing.T) {
+	addr := model.ServerAddress{
+		Public:   "my-server:9190",
+		Internal: "my-server:8190",
+	}
+
+	sap := newMockShardAssignmentsProvider()
+	nal := newMockNodeAvailabilityListener()
+	rpc := newMockRpcProvider()
+	rpc.SetLocality(addr, "zone-a", "host-1")
+	nc := newNodeController(addr, sap, nal, rpc, testFailureDetector, 1*time.Second)
+
+	assert.Eventually(t, func() bool {
+		return nc.Locality() != nil
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(t, model.ServerLocality{Zone: "zone-a", Host: "host-1"}, *nc.Locality())
+
+	// The node might come back on another host
+	node := rpc.GetNode(addr)
+	node.healthClient.SetStatus(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	unavailableNode := <-nal.events
+	assert.Equal(t, addr, unavailableNode)
+	assert.Nil(t, nc.Locality())
+
+	rpc.SetLocality(addr, "zone-a", "host-2")
+	node.healthClient.SetStatus(grpc_health_v1.HealthCheckResponse_SERVING)
+
+	assert.Eventually(t, func() bool {
+		locality := nc.Locality()
+		return locality != nil && locality.Host == "host-2"
+	}, 10*time.Second, 100*time.Millisecond)
+
+	assert.NoError(t, nc.Close())
+}
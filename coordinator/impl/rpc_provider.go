@@ -25,15 +25,25 @@ import (
 	"github.com/streamnative/oxia/proto"
 )
 
-const rpcTimeout = 30 * time.Second
+const (
+	rpcTimeout        = 30 * time.Second
+	splitTimeout      = 5 * time.Minute
+	compactionTimeout = 1 * time.Hour
+)
 
 type RpcProvider interface {
 	PushShardAssignments(ctx context.Context, node model.ServerAddress) (proto.OxiaCoordination_PushShardAssignmentsClient, error)
 	NewTerm(ctx context.Context, node model.ServerAddress, req *proto.NewTermRequest) (*proto.NewTermResponse, error)
 	BecomeLeader(ctx context.Context, node model.ServerAddress, req *proto.BecomeLeaderRequest) (*proto.BecomeLeaderResponse, error)
 	AddFollower(ctx context.Context, node model.ServerAddress, req *proto.AddFollowerRequest) (*proto.AddFollowerResponse, error)
+	RemoveFollower(ctx context.Context, node model.ServerAddress, req *proto.RemoveFollowerRequest) (*proto.RemoveFollowerResponse, error)
+	TransferLeadership(ctx context.Context, node model.ServerAddress, req *proto.TransferLeadershipRequest) (*proto.TransferLeadershipResponse, error)
 	GetStatus(ctx context.Context, node model.ServerAddress, req *proto.GetStatusRequest) (*proto.GetStatusResponse, error)
+	GetInfo(ctx context.Context, node model.ServerAddress) (*proto.GetInfoResponse, error)
 	DeleteShard(ctx context.Context, node model.ServerAddress, req *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error)
+	SplitShard(ctx context.Context, node model.ServerAddress, req *proto.SplitShardRequest) (*proto.SplitShardResponse, error)
+	SetMaintenance(ctx context.Context, node model.ServerAddress, req *proto.SetMaintenanceRequest) (*proto.SetMaintenanceResponse, error)
+	CompactShard(ctx context.Context, node model.ServerAddress, req *proto.CompactShardRequest) (*proto.CompactShardResponse, error)
 
 	GetHealthClient(node model.ServerAddress) (grpc_health_v1.HealthClient, error)
 }
@@ -91,6 +101,30 @@ func (r *rpcProvider) AddFollower(ctx context.Context, node model.ServerAddress,
 	return rpc.AddFollower(ctx, req)
 }
 
+func (r *rpcProvider) RemoveFollower(ctx context.Context, node model.ServerAddress, req *proto.RemoveFollowerRequest) (*proto.RemoveFollowerResponse, error) {
+	rpc, err := r.pool.GetCoordinationRpc(node.Internal)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rpcTimeout)
+	defer cancel()
+
+	return rpc.RemoveFollower(ctx, req)
+}
+
+func (r *rpcProvider) TransferLeadership(ctx context.Context, node model.ServerAddress, req *proto.TransferLeadershipRequest) (*proto.TransferLeadershipResponse, error) {
+	rpc, err := r.pool.GetCoordinationRpc(node.Internal)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rpcTimeout)
+	defer cancel()
+
+	return rpc.TransferLeadership(ctx, req)
+}
+
 func (r *rpcProvider) GetStatus(ctx context.Context, node model.ServerAddress, req *proto.GetStatusRequest) (*proto.GetStatusResponse, error) {
 	rpc, err := r.pool.GetCoordinationRpc(node.Internal)
 	if err != nil {
@@ -103,6 +137,18 @@ func (r *rpcProvider) GetStatus(ctx context.Context, node model.ServerAddress, r
 	return rpc.GetStatus(ctx, req)
 }
 
+func (r *rpcProvider) GetInfo(ctx context.Context, node model.ServerAddress) (*proto.GetInfoResponse, error) {
+	rpc, err := r.pool.GetCoordinationRpc(node.Internal)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rpcTimeout)
+	defer cancel()
+
+	return rpc.GetInfo(ctx, &proto.GetInfoRequest{})
+}
+
 func (r *rpcProvider) DeleteShard(ctx context.Context, node model.ServerAddress, req *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error) {
 	rpc, err := r.pool.GetCoordinationRpc(node.Internal)
 	if err != nil {
@@ -115,6 +161,46 @@ func (r *rpcProvider) DeleteShard(ctx context.Context, node model.ServerAddress,
 	return rpc.DeleteShard(ctx, req)
 }
 
+func (r *rpcProvider) SplitShard(ctx context.Context, node model.ServerAddress, req *proto.SplitShardRequest) (*proto.SplitShardResponse, error) {
+	rpc, err := r.pool.GetCoordinationRpc(node.Internal)
+	if err != nil {
+		return nil, err
+	}
+
+	// The children have to catch up with the parent, which can take longer
+	// than the other operations
+	ctx, cancel := context.WithTimeout(ctx, splitTimeout)
+	defer cancel()
+
+	return rpc.SplitShard(ctx, req)
+}
+
+func (r *rpcProvider) SetMaintenance(ctx context.Context, node model.ServerAddress, req *proto.SetMaintenanceRequest) (*proto.SetMaintenanceResponse, error) {
+	rpc, err := r.pool.GetCoordinationRpc(node.Internal)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rpcTimeout)
+	defer cancel()
+
+	return rpc.SetMaintenance(ctx, req)
+}
+
+func (r *rpcProvider) CompactShard(ctx context.Context, node model.ServerAddress, req *proto.CompactShardRequest) (*proto.CompactShardResponse, error) {
+	rpc, err := r.pool.GetCoordinationRpc(node.Internal)
+	if err != nil {
+		return nil, err
+	}
+
+	// The database of a large shard can take a while to compact, and the
+	// compaction might wait for the others running on the node
+	ctx, cancel := context.WithTimeout(ctx, compactionTimeout)
+	defer cancel()
+
+	return rpc.CompactShard(ctx, req)
+}
+
 func (r *rpcProvider) GetHealthClient(node model.ServerAddress) (grpc_health_v1.HealthClient, error) {
 	return r.pool.GetHealthRpc(node.Internal)
 }
@@ -0,0 +1,292 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/streamnative/oxia/coordinator/model"
+)
+
+const (
+	// DefaultMembershipDebounce is how long the membership has to be stable
+	// before the change is notified. It covers the restart of a pod.
+	DefaultMembershipDebounce = 30 * time.Second
+
+	publicPortName   = "public"
+	internalPortName = "internal"
+)
+
+// ServerMembership discovers the servers of the cluster dynamically.
+type ServerMembership interface {
+	io.Closer
+
+	// Servers returns the servers that are members of the cluster, sorted
+	// by internal address.
+	Servers() []model.ServerAddress
+}
+
+// k8sServerMembership discovers the servers from the EndpointSlices of their
+// headless service. The changes are only notified once the membership has
+// not changed for the debounce period, so that a server that flaps doesn't
+// trigger a rebalancing each time.
+type k8sServerMembership struct {
+	sync.Mutex
+
+	namespace     string
+	service       string
+	debounce      time.Duration
+	notifications chan<- any
+	lister        discoverylisters.EndpointSliceNamespaceLister
+	factory       informers.SharedInformerFactory
+	servers       []model.ServerAddress
+	timer         *time.Timer
+	log           *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewK8SServerMembership(kc kubernetes.Interface, namespace string, service string, debounce time.Duration,
+	notifications chan<- any) (ServerMembership, error) {
+	m := &k8sServerMembership{
+		namespace:     namespace,
+		service:       service,
+		debounce:      debounce,
+		notifications: notifications,
+		log: slog.With(
+			slog.String("component", "server-membership"),
+			slog.String("namespace", namespace),
+			slog.String("service", service),
+		),
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	m.factory = informers.NewSharedInformerFactoryWithOptions(kc, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = discoveryv1.LabelServiceName + "=" + service
+		}))
+	informer := m.factory.Discovery().V1().EndpointSlices()
+	m.lister = informer.Lister().EndpointSlices(namespace)
+
+	if _, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { m.membershipChanged() },
+		UpdateFunc: func(any, any) { m.membershipChanged() },
+		DeleteFunc: func(any) { m.membershipChanged() },
+	}); err != nil {
+		return nil, err
+	}
+
+	m.factory.Start(m.ctx.Done())
+	for _, synced := range m.factory.WaitForCacheSync(m.ctx.Done()) {
+		if !synced {
+			m.cancel()
+			return nil, errors.Errorf("failed to list the endpoints of service %s/%s", namespace, service)
+		}
+	}
+
+	var err error
+	if m.servers, err = m.discover(); err != nil {
+		m.cancel()
+		return nil, err
+	}
+
+	m.log.Info(
+		"Discovered the servers",
+		slog.Any("servers", m.servers),
+	)
+	return m, nil
+}
+
+func (m *k8sServerMembership) Servers() []model.ServerAddress {
+	m.Lock()
+	defer m.Unlock()
+	return slices.Clone(m.servers)
+}
+
+func (m *k8sServerMembership) membershipChanged() {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.timer = time.AfterFunc(m.debounce, m.update)
+}
+
+func (m *k8sServerMembership) update() {
+	servers, err := m.discover()
+	if err != nil {
+		m.log.Warn(
+			"Failed to discover the servers",
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	m.Lock()
+	if slices.Equal(servers, m.servers) {
+		m.Unlock()
+		return
+	}
+	m.log.Info(
+		"The servers have changed",
+		slog.Any("servers", servers),
+		slog.Any("previous-servers", m.servers),
+	)
+	m.servers = servers
+	m.Unlock()
+
+	select {
+	case m.notifications <- nil:
+	case <-m.ctx.Done():
+	}
+}
+
+// discover returns the servers in the endpoints of the service. The servers
+// that have a hostname, like the pods of a StatefulSet, are identified by
+// their stable DNS name, the others by their IP address.
+func (m *k8sServerMembership) discover() ([]model.ServerAddress, error) {
+	endpointSlices, err := m.lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	servers := map[string]model.ServerAddress{}
+	for _, es := range endpointSlices {
+		var publicPort, internalPort *int32
+		for _, port := range es.Ports {
+			if port.Name == nil {
+				continue
+			}
+			switch *port.Name {
+			case publicPortName:
+				publicPort = port.Port
+			case internalPortName:
+				internalPort = port.Port
+			}
+		}
+		if publicPort == nil || internalPort == nil {
+			m.log.Warn(
+				"Ignoring the endpoints without the public and internal ports",
+				slog.String("endpoint-slice", es.Name),
+			)
+			continue
+		}
+
+		for _, endpoint := range es.Endpoints {
+			if endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating {
+				continue
+			}
+
+			var server model.ServerAddress
+			switch {
+			case endpoint.Hostname != nil:
+				server = model.ServerAddress{
+					Public:   fmt.Sprintf("%s.%s.%s.svc.cluster.local:%d", *endpoint.Hostname, m.service, m.namespace, *publicPort),
+					Internal: fmt.Sprintf("%s.%s:%d", *endpoint.Hostname, m.service, *internalPort),
+				}
+			case len(endpoint.Addresses) > 0:
+				server = model.ServerAddress{
+					Public:   fmt.Sprintf("%s:%d", endpoint.Addresses[0], *publicPort),
+					Internal: fmt.Sprintf("%s:%d", endpoint.Addresses[0], *internalPort),
+				}
+			default:
+				continue
+			}
+			servers[server.Internal] = server
+		}
+	}
+
+	res := make([]model.ServerAddress, 0, len(servers))
+	for _, server := range servers {
+		res = append(res, server)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Internal < res[j].Internal
+	})
+	return res, nil
+}
+
+func (m *k8sServerMembership) Close() error {
+	m.cancel()
+
+	m.Lock()
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.Unlock()
+
+	m.factory.Shutdown()
+	return nil
+}
+
+// MembershipClusterConfigProvider replaces the servers of the cluster config
+// with the members of the cluster. The servers that left the membership
+// while they still hold replicas of the shards are kept, so that they are
+// considered down until they come back, rather than being removed from the
+// cluster along with their replicas.
+func MembershipClusterConfigProvider(provider func() (model.ClusterConfig, error), membership ServerMembership,
+	metadataProvider MetadataProvider) func() (model.ClusterConfig, error) {
+	return func() (model.ClusterConfig, error) {
+		cc, err := provider()
+		if err != nil {
+			return cc, err
+		}
+
+		status, _, err := metadataProvider.Get()
+		if err != nil && !errors.Is(err, ErrMetadataNotInitialized) {
+			return cc, errors.Wrap(err, "failed to read the cluster status")
+		}
+
+		cc.Servers = membership.Servers()
+		if status == nil {
+			return cc, nil
+		}
+
+		var left []model.ServerAddress
+		for _, ns := range status.Namespaces {
+			for _, shard := range ns.Shards {
+				for _, server := range shard.Ensemble {
+					if !listContains(cc.Servers, server) && !listContains(left, server) {
+						left = append(left, server)
+					}
+				}
+			}
+		}
+		sort.Slice(left, func(i, j int) bool {
+			return left[i].Internal < left[j].Internal
+		})
+		cc.Servers = append(cc.Servers, left...)
+		return cc, nil
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/server"
+)
+
+const (
+	testMembershipNamespace = "oxia"
+	testMembershipService   = "oxia-svc"
+	testMembershipDebounce  = 200 * time.Millisecond
+)
+
+func newEndpointSlice(name string, publicPort int32, internalPort int32, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testMembershipNamespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: testMembershipService},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports: []discoveryv1.EndpointPort{
+			{Name: ptr.To(publicPortName), Port: ptr.To(publicPort)},
+			{Name: ptr.To(internalPortName), Port: ptr.To(internalPort)},
+		},
+		Endpoints: endpoints,
+	}
+}
+
+func podEndpoint(hostname string) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}, Hostname: ptr.To(hostname)}
+}
+
+func podServer(hostname string) model.ServerAddress {
+	return model.ServerAddress{
+		Public:   hostname + ".oxia-svc.oxia.svc.cluster.local:6648",
+		Internal: hostname + ".oxia-svc:6649",
+	}
+}
+
+func updateEndpointSlice(t *testing.T, kc kubernetes.Interface, es *discoveryv1.EndpointSlice) {
+	t.Helper()
+	_, err := kc.DiscoveryV1().EndpointSlices(testMembershipNamespace).Update(context.Background(), es, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+}
+
+func TestK8SServerMembership(t *testing.T) {
+	es := newEndpointSlice("oxia-svc-1", 6648, 6649, podEndpoint("oxia-0"), podEndpoint("oxia-1"))
+	other := newEndpointSlice("other-svc", 6648, 6649, podEndpoint("other-0"))
+	other.Labels[discoveryv1.LabelServiceName] = "other-svc"
+	kc := fake.NewSimpleClientset(es, other)
+
+	notifications := make(chan any, 10)
+	m, err := NewK8SServerMembership(kc, testMembershipNamespace, testMembershipService, testMembershipDebounce, notifications)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ServerAddress{podServer("oxia-0"), podServer("oxia-1")}, m.Servers())
+
+	// A server that flaps is only notified once, after the debounce period
+	es.Endpoints = append(es.Endpoints, podEndpoint("oxia-2"))
+	updateEndpointSlice(t, kc, es)
+	es.Endpoints = es.Endpoints[:2]
+	updateEndpointSlice(t, kc, es)
+	es.Endpoints = append(es.Endpoints, podEndpoint("oxia-2"))
+	updateEndpointSlice(t, kc, es)
+
+	select {
+	case <-notifications:
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "the membership change was not notified")
+	}
+	assert.Equal(t, []model.ServerAddress{podServer("oxia-0"), podServer("oxia-1"), podServer("oxia-2")}, m.Servers())
+
+	// The terminating servers are not members anymore, nor the ones in the
+	// endpoints without the oxia ports
+	es.Endpoints[0].Conditions.Terminating = ptr.To(true)
+	updateEndpointSlice(t, kc, es)
+	_, err = kc.DiscoveryV1().EndpointSlices(testMembershipNamespace).Create(context.Background(), &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "oxia-svc-2",
+			Namespace: testMembershipNamespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: testMembershipService},
+		},
+		Endpoints: []discoveryv1.Endpoint{podEndpoint("oxia-3")},
+	}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	select {
+	case <-notifications:
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "the membership change was not notified")
+	}
+	assert.Equal(t, []model.ServerAddress{podServer("oxia-1"), podServer("oxia-2")}, m.Servers())
+	assert.Empty(t, notifications)
+
+	assert.NoError(t, m.Close())
+}
+
+type staticMembership []model.ServerAddress
+
+func (staticMembership) Close() error {
+	return nil
+}
+
+func (m staticMembership) Servers() []model.ServerAddress {
+	return m
+}
+
+func TestMembershipClusterConfigProvider(t *testing.T) {
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{Name: common.DefaultNamespace, ReplicationFactor: 2, InitialShardCount: 1}},
+		Servers:    []model.ServerAddress{s4},
+	}
+	provider := MembershipClusterConfigProvider(func() (model.ClusterConfig, error) {
+		return clusterConfig, nil
+	}, staticMembership{s1, s2}, metadataProvider)
+
+	// Before the cluster is initialized
+	cc, err := provider()
+	assert.NoError(t, err)
+	assert.Equal(t, clusterConfig.Namespaces, cc.Namespaces)
+	assert.Equal(t, []model.ServerAddress{s1, s2}, cc.Servers)
+
+	// The servers that hold replicas are kept, even if they have left
+	_, err = metadataProvider.Store(&model.ClusterStatus{
+		Namespaces: map[string]model.NamespaceStatus{
+			common.DefaultNamespace: {
+				ReplicationFactor: 2,
+				Shards: map[int64]model.ShardMetadata{
+					0: {Ensemble: []model.ServerAddress{s1, s3}},
+					1: {Ensemble: []model.ServerAddress{s3, s2}},
+				},
+			},
+		},
+	}, MetadataNotExists)
+	assert.NoError(t, err)
+
+	cc, err = provider()
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ServerAddress{s1, s2, s3}, cc.Servers)
+}
+
+// localEndpointSlice makes the server a member, through its own slice, since
+// each local server has its own ports.
+func localEndpointSlice(t *testing.T, addr model.ServerAddress) *discoveryv1.EndpointSlice {
+	t.Helper()
+
+	port := func(hostPort string) int32 {
+		_, p, err := net.SplitHostPort(hostPort)
+		assert.NoError(t, err)
+		res, err := strconv.ParseInt(p, 10, 32)
+		assert.NoError(t, err)
+		return int32(res)
+	}
+
+	return newEndpointSlice(fmt.Sprintf("oxia-svc-%d", port(addr.Internal)), port(addr.Public), port(addr.Internal),
+		discoveryv1.Endpoint{Addresses: []string{"127.0.0.1"}})
+}
+
+func localServerAddress(addr model.ServerAddress) model.ServerAddress {
+	_, publicPort, _ := net.SplitHostPort(addr.Public)
+	_, internalPort, _ := net.SplitHostPort(addr.Internal)
+	return model.ServerAddress{Public: "127.0.0.1:" + publicPort, Internal: "127.0.0.1:" + internalPort}
+}
+
+func TestCoordinator_ServerMembership(t *testing.T) {
+	s1, sa1 := newServer(t)
+	s2, sa2 := newServer(t)
+	s3, sa3 := newServer(t)
+	servers := []*server.Server{s1, s2}
+
+	kc := fake.NewSimpleClientset(localEndpointSlice(t, sa1), localEndpointSlice(t, sa2))
+	notifications := make(chan any)
+	membership, err := NewK8SServerMembership(kc, testMembershipNamespace, testMembershipService, testMembershipDebounce, notifications)
+	assert.NoError(t, err)
+
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              common.DefaultNamespace,
+			ReplicationFactor: 2,
+			InitialShardCount: 3,
+		}},
+	}
+	configProvider := MembershipClusterConfigProvider(func() (model.ClusterConfig, error) {
+		return clusterConfig, nil
+	}, membership, metadataProvider)
+	clientPool := common.NewClientPool(nil, nil)
+
+	c, err := NewCoordinator(metadataProvider, configProvider, notifications, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	replicasOn := func(server model.ServerAddress) int {
+		count := 0
+		for _, shard := range c.ClusterStatus().Namespaces[common.DefaultNamespace].Shards {
+			if shard.Status == model.ShardStatusSteadyState && listContains(shard.Ensemble, server) {
+				count++
+			}
+		}
+		return count
+	}
+
+	assert.Eventually(t, func() bool {
+		return replicasOn(localServerAddress(sa1)) == 3 && replicasOn(localServerAddress(sa2)) == 3
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// A new server is added to the failure detector and the shards are
+	// rebalanced
+	_, err = kc.DiscoveryV1().EndpointSlices(testMembershipNamespace).Create(context.Background(),
+		localEndpointSlice(t, sa3), metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	newServer := localServerAddress(sa3)
+	assert.Eventually(t, func() bool {
+		return replicasOn(newServer) == 2
+	}, 30*time.Second, 10*time.Millisecond)
+	assert.Equal(t, Running, c.NodesStatus()[newServer.Internal])
+
+	// The server that disappears with its replicas is considered down
+	assert.NoError(t, s3.Close())
+	assert.NoError(t, kc.DiscoveryV1().EndpointSlices(testMembershipNamespace).Delete(context.Background(),
+		localEndpointSlice(t, sa3).Name, metav1.DeleteOptions{}))
+
+	assert.Eventually(t, func() bool {
+		return c.NodesStatus()[newServer.Internal] == NotRunning
+	}, 10*time.Second, 10*time.Millisecond)
+
+	time.Sleep(2 * testMembershipDebounce)
+	assert.Contains(t, c.NodesStatus(), newServer.Internal)
+	ensembles := 0
+	for _, shard := range c.ClusterStatus().Namespaces[common.DefaultNamespace].Shards {
+		if listContains(shard.Ensemble, newServer) {
+			ensembles++
+		}
+	}
+	assert.Equal(t, 2, ensembles)
+
+	assert.NoError(t, membership.Close())
+	assert.NoError(t, c.Close())
+	assert.NoError(t, clientPool.Close())
+
+	for _, serverObj := range servers {
+		assert.NoError(t, serverObj.Close())
+	}
+}
@@ -51,6 +51,11 @@ type swapNodeRequest struct {
 	res  chan error
 }
 
+type nodeRequest struct {
+	node model.ServerAddress
+	res  chan error
+}
+
 type newTermAndAddFollowerRequest struct {
 	ctx  context.Context
 	node model.ServerAddress
@@ -65,6 +70,23 @@ type ShardController interface {
 	HandleNodeFailure(failedNode model.ServerAddress)
 
 	SwapNode(from model.ServerAddress, to model.ServerAddress) error
+
+	// AddNode adds a new member to the ensemble of the shard, without going
+	// through a leader election
+	AddNode(node model.ServerAddress) error
+
+	// RemoveNode removes a member from the ensemble of the shard. A new leader
+	// is elected only if the removed node is the current leader
+	RemoveNode(node model.ServerAddress) error
+
+	// TransferLeadership moves the leadership to another member of the ensemble,
+	// without waiting for the failure of the current leader to be detected
+	TransferLeadership(newLeader model.ServerAddress) error
+
+	// ElectLeader forces a new leader election, even if the current leader
+	// is healthy
+	ElectLeader() error
+
 	DeleteShard()
 
 	Term() int64
@@ -83,8 +105,18 @@ type shardController struct {
 	deleteOp                chan any
 	nodeFailureOp           chan model.ServerAddress
 	swapNodeOp              chan swapNodeRequest
+	addNodeOp               chan nodeRequest
+	removeNodeOp            chan nodeRequest
+	transferLeadershipOp    chan nodeRequest
+	electLeaderOp           chan chan error
 	newTermAndAddFollowerOp chan newTermAndAddFollowerRequest
 
+	// When set, the node is chosen as leader in the next election, if it
+	// has the highest entry
+	preferredLeader *model.ServerAddress
+
+	electionBackoff *electionBackoff
+
 	ctx    context.Context
 	cancel context.CancelFunc
 
@@ -96,10 +128,28 @@ type shardController struct {
 	newTermQuorumLatency  metrics.LatencyHistogram
 	becomeLeaderLatency   metrics.LatencyHistogram
 	leaderElectionsFailed metrics.Counter
+	leaderElections       map[electionReason]metrics.Counter
 	termGauge             metrics.Gauge
 }
 
-func NewShardController(namespace string, shard int64, shardMetadata model.ShardMetadata, rpc RpcProvider, coordinator Coordinator) ShardController {
+// electionReason tells why a new leader is elected for the shard.
+type electionReason string
+
+const (
+	// The shard controller has just started and the shard has no healthy leader
+	electionReasonStartup electionReason = "startup"
+	// The leader of the shard has failed
+	electionReasonNodeDown electionReason = "node-down"
+	// The leadership is being moved to another node of the ensemble
+	electionReasonTransfer electionReason = "transfer"
+	// A node is being added or removed from the ensemble
+	electionReasonEnsembleChange electionReason = "ensemble-change"
+	// The election was requested by an operator
+	electionReasonAdmin electionReason = "admin"
+)
+
+func NewShardController(namespace string, shard int64, shardMetadata model.ShardMetadata, rpc RpcProvider, coordinator Coordinator,
+	electionBackoff time.Duration) ShardController {
 	labels := metrics.LabelsForShard(namespace, shard)
 	s := &shardController{
 		namespace:               namespace,
@@ -110,7 +160,12 @@ func NewShardController(namespace string, shard int64, shardMetadata model.Shard
 		deleteOp:                make(chan any, chanBufferSize),
 		nodeFailureOp:           make(chan model.ServerAddress, chanBufferSize),
 		swapNodeOp:              make(chan swapNodeRequest, chanBufferSize),
+		addNodeOp:               make(chan nodeRequest, chanBufferSize),
+		removeNodeOp:            make(chan nodeRequest, chanBufferSize),
+		transferLeadershipOp:    make(chan nodeRequest, chanBufferSize),
+		electLeaderOp:           make(chan chan error, chanBufferSize),
 		newTermAndAddFollowerOp: make(chan newTermAndAddFollowerRequest, chanBufferSize),
+		electionBackoff:         newElectionBackoff(electionBackoff),
 		log: slog.With(
 			slog.String("component", "shard-controller"),
 			slog.String("namespace", namespace),
@@ -125,6 +180,15 @@ func NewShardController(namespace string, shard int64, shardMetadata model.Shard
 			"The time it takes to take the ensemble of nodes to a new term", labels),
 		becomeLeaderLatency: metrics.NewLatencyHistogram("oxia_coordinator_become_leader_latency",
 			"The time it takes for the new elected leader to start", labels),
+		leaderElections: make(map[electionReason]metrics.Counter),
+	}
+
+	for _, reason := range []electionReason{electionReasonStartup, electionReasonNodeDown,
+		electionReasonTransfer, electionReasonEnsembleChange, electionReasonAdmin} {
+		reasonLabels := metrics.LabelsForShard(namespace, shard)
+		reasonLabels["reason"] = string(reason)
+		s.leaderElections[reason] = metrics.NewCounter("oxia_coordinator_leader_elections",
+			"The number of leader elections performed", "count", reasonLabels)
 	}
 
 	s.termGauge = metrics.NewGauge("oxia_coordinator_term",
@@ -157,7 +221,7 @@ func (s *shardController) run() {
 	case s.shardMetadata.Status == model.ShardStatusDeleting:
 		s.DeleteShard()
 	case s.shardMetadata.Leader == nil || s.shardMetadata.Status != model.ShardStatusSteadyState:
-		s.electLeaderWithRetries()
+		s.electLeaderWithRetries(electionReasonStartup)
 	default:
 		s.log.Info(
 			"There is already a node marked as leader on the shard, verifying",
@@ -165,7 +229,7 @@ func (s *shardController) run() {
 		)
 
 		if !s.verifyCurrentEnsemble() {
-			s.electLeaderWithRetries()
+			s.electLeaderWithRetries(electionReasonStartup)
 		}
 	}
 
@@ -188,6 +252,18 @@ func (s *shardController) run() {
 		case sw := <-s.swapNodeOp:
 			s.swapNode(sw.from, sw.to, sw.res)
 
+		case a := <-s.addNodeOp:
+			a.res <- s.addNode(a.node)
+
+		case r := <-s.removeNodeOp:
+			r.res <- s.removeNode(r.node)
+
+		case tl := <-s.transferLeadershipOp:
+			tl.res <- s.transferLeadership(tl.node)
+
+		case res := <-s.electLeaderOp:
+			res <- s.forceElection()
+
 		case a := <-s.newTermAndAddFollowerOp:
 			s.internalNewTermAndAddFollower(a.ctx, a.node, a.res)
 		}
@@ -207,11 +283,22 @@ func (s *shardController) handleNodeFailure(failedNode model.ServerAddress) {
 
 	if s.shardMetadata.Leader != nil &&
 		*s.shardMetadata.Leader == failedNode {
+		// The elections of the shards led by the failed node are spread
+		// over time, rather than all happening at once
+		delay := s.electionBackoff.next()
 		s.log.Info(
 			"Detected failure on shard leader",
 			slog.Any("leader", failedNode),
+			slog.Duration("election-delay", delay),
 		)
-		s.electLeaderWithRetries()
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		s.electLeaderWithRetries(electionReasonNodeDown)
 	}
 }
 
@@ -266,8 +353,10 @@ func (s *shardController) verifyCurrentEnsemble() bool {
 	return true
 }
 
-func (s *shardController) electLeaderWithRetries() {
-	_ = backoff.RetryNotify(s.electLeader, common.NewBackOff(s.ctx),
+func (s *shardController) electLeaderWithRetries(reason electionReason) {
+	_ = backoff.RetryNotify(func() error {
+		return s.electLeader(reason)
+	}, common.NewBackOff(s.ctx),
 		func(err error, duration time.Duration) {
 			s.leaderElectionsFailed.Inc()
 			s.log.Warn(
@@ -278,7 +367,7 @@ func (s *shardController) electLeaderWithRetries() {
 		})
 }
 
-func (s *shardController) electLeader() error {
+func (s *shardController) electLeader(reason electionReason) error {
 	timer := s.leaderElectionLatency.Timer()
 
 	if s.currentElectionCancel != nil {
@@ -297,6 +386,7 @@ func (s *shardController) electLeader() error {
 	s.log.Info(
 		"Starting leader election",
 		slog.Int64("term", s.shardMetadata.Term),
+		slog.Any("reason", reason),
 	)
 
 	if err := s.coordinator.InitiateLeaderElection(s.namespace, s.shard, s.shardMetadata); err != nil {
@@ -304,12 +394,12 @@ func (s *shardController) electLeader() error {
 	}
 
 	// Send NewTerm to all the ensemble members
-	fr, err := s.newTermQuorum()
+	fr, maintenance, err := s.newTermQuorum()
 	if err != nil {
 		return err
 	}
 
-	newLeader, followers := s.selectNewLeader(fr)
+	newLeader, followers := s.selectNewLeader(fr, maintenance)
 
 	if s.log.Enabled(context.Background(), slog.LevelInfo) {
 		f := make([]struct {
@@ -361,6 +451,7 @@ func (s *shardController) electLeader() error {
 	)
 
 	timer.Done()
+	s.leaderElections[reason].Inc()
 
 	s.keepFencingFailedFollowers(followers)
 	return nil
@@ -463,7 +554,7 @@ func (s *shardController) newTermAndAddFollower(ctx context.Context, node model.
 }
 
 func (s *shardController) internalNewTermAndAddFollower(ctx context.Context, node model.ServerAddress, res chan error) {
-	fr, err := s.newTerm(ctx, node)
+	fr, _, err := s.newTerm(ctx, node)
 	if err != nil {
 		res <- err
 		return
@@ -494,7 +585,11 @@ func (s *shardController) internalNewTermAndAddFollower(ctx context.Context, nod
 
 // Send NewTerm to all the ensemble members in parallel and wait for
 // a majority of them to reply successfully.
-func (s *shardController) newTermQuorum() (map[model.ServerAddress]*proto.EntryId, error) { //nolint:revive
+// Returns the head entry of each ensemble member that replied, and the
+// set of the ones that should not be elected as leader, because they are
+// in maintenance mode or their disk is full.
+func (s *shardController) newTermQuorum() ( //nolint:revive
+	map[model.ServerAddress]*proto.EntryId, map[model.ServerAddress]bool, error) {
 	timer := s.newTermQuorumLatency.Timer()
 
 	fencingQuorum := mergeLists(s.shardMetadata.Ensemble, s.shardMetadata.RemovedNodes)
@@ -509,6 +604,7 @@ func (s *shardController) newTermQuorum() (map[model.ServerAddress]*proto.EntryI
 	ch := make(chan struct {
 		model.ServerAddress
 		*proto.EntryId
+		maintenance bool
 		error
 	}, fencingQuorumSize)
 
@@ -522,7 +618,7 @@ func (s *shardController) newTermQuorum() (map[model.ServerAddress]*proto.EntryI
 				"shard": fmt.Sprintf("%d", s.shard),
 				"node":  sa.Internal,
 			}, func() {
-				entryId, err := s.newTerm(ctx, serverAddress)
+				entryId, maintenance, err := s.newTerm(ctx, serverAddress)
 				if err != nil {
 					s.log.Warn(
 						"Failed to newTerm node",
@@ -534,14 +630,16 @@ func (s *shardController) newTermQuorum() (map[model.ServerAddress]*proto.EntryI
 						"Processed newTerm response",
 						slog.Any("server-address", serverAddress),
 						slog.Any("entry-id", entryId),
+						slog.Bool("maintenance", maintenance),
 					)
 				}
 
 				ch <- struct {
 					model.ServerAddress
 					*proto.EntryId
+					maintenance bool
 					error
-				}{serverAddress, entryId, err}
+				}{serverAddress, entryId, maintenance, err}
 			},
 		)
 	}
@@ -550,6 +648,7 @@ func (s *shardController) newTermQuorum() (map[model.ServerAddress]*proto.EntryI
 	totalResponses := 0
 
 	res := make(map[model.ServerAddress]*proto.EntryId)
+	maintenance := make(map[model.ServerAddress]bool)
 	var err error
 
 	// Wait for a majority to respond
@@ -563,6 +662,9 @@ func (s *shardController) newTermQuorum() (map[model.ServerAddress]*proto.EntryI
 			// We don't consider the removed nodes as candidates for leader/followers
 			if listContains(s.shardMetadata.Ensemble, r.ServerAddress) {
 				res[r.ServerAddress] = r.EntryId
+				if r.maintenance {
+					maintenance[r.ServerAddress] = true
+				}
 			}
 		} else {
 			err = multierr.Append(err, r.error)
@@ -570,7 +672,7 @@ func (s *shardController) newTermQuorum() (map[model.ServerAddress]*proto.EntryI
 	}
 
 	if successResponses < majority {
-		return nil, errors.Wrap(err, "failed to newTerm shard")
+		return nil, nil, errors.Wrap(err, "failed to newTerm shard")
 	}
 
 	// If we have already reached a quorum of successful responses, we can wait a
@@ -581,31 +683,37 @@ func (s *shardController) newTermQuorum() (map[model.ServerAddress]*proto.EntryI
 			totalResponses++
 			if r.error == nil {
 				res[r.ServerAddress] = r.EntryId
+				if r.maintenance {
+					maintenance[r.ServerAddress] = true
+				}
 			} else {
 				err = multierr.Append(err, r.error)
 			}
 
 		case <-time.After(quorumFencingGracePeriod):
 			timer.Done()
-			return res, nil
+			return res, maintenance, nil
 		}
 	}
 
 	timer.Done()
-	return res, nil
+	return res, maintenance, nil
 }
 
-func (s *shardController) newTerm(ctx context.Context, node model.ServerAddress) (*proto.EntryId, error) {
+func (s *shardController) newTerm(ctx context.Context, node model.ServerAddress) (
+	headEntryId *proto.EntryId, maintenance bool, err error) {
 	res, err := s.rpc.NewTerm(ctx, node, &proto.NewTermRequest{
 		Namespace: s.namespace,
 		Shard:     s.shard,
 		Term:      s.shardMetadata.Term,
 	})
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	return res.HeadEntryId, nil
+	// A node with a full disk would reject the writes as leader, so it's
+	// avoided like the ones in maintenance
+	return res.HeadEntryId, res.Maintenance || res.DiskFull, nil
 }
 
 func (s *shardController) deleteShardRpc(ctx context.Context, node model.ServerAddress) error {
@@ -618,7 +726,8 @@ func (s *shardController) deleteShardRpc(ctx context.Context, node model.ServerA
 	return err
 }
 
-func (*shardController) selectNewLeader(newTermResponses map[model.ServerAddress]*proto.EntryId) (
+func (s *shardController) selectNewLeader(newTermResponses map[model.ServerAddress]*proto.EntryId,
+	maintenance map[model.ServerAddress]bool) (
 	leader model.ServerAddress, followers map[model.ServerAddress]*proto.EntryId) {
 	// Select all the nodes that have the highest entry in the wal
 	var currentMax int64 = -1
@@ -637,8 +746,30 @@ func (*shardController) selectNewLeader(newTermResponses map[model.ServerAddress
 		}
 	}
 
+	// Skip the nodes in maintenance mode or with a full disk. If all the
+	// candidates are unavailable we still have to pick one of them, since
+	// they are the only ones that are guaranteed to have all the committed
+	// entries.
+	var available []model.ServerAddress
+	for _, addr := range candidates {
+		if !maintenance[addr] {
+			available = append(available, addr)
+		}
+	}
+	if len(available) > 0 {
+		candidates = available
+	} else {
+		s.log.Warn(
+			"All the leader candidates are in maintenance mode or have a full disk",
+			slog.Any("candidates", candidates),
+		)
+	}
+
 	// Select a random leader among the nodes with the highest entry in the wal
 	leader = candidates[rand.Intn(len(candidates))] //nolint:gosec
+	if s.preferredLeader != nil && listContains(candidates, *s.preferredLeader) {
+		leader = *s.preferredLeader
+	}
 	followers = make(map[model.ServerAddress]*proto.EntryId)
 	for a, e := range newTermResponses {
 		if a != leader {
@@ -671,12 +802,18 @@ func (s *shardController) becomeLeader(leader model.ServerAddress, followers map
 }
 
 func (s *shardController) addFollower(leader model.ServerAddress, follower string, followerHeadEntryId *proto.EntryId) error {
+	return s.addFollowerWithReplicationFactor(leader, follower, followerHeadEntryId, 0)
+}
+
+func (s *shardController) addFollowerWithReplicationFactor(leader model.ServerAddress, follower string,
+	followerHeadEntryId *proto.EntryId, replicationFactor uint32) error {
 	if _, err := s.rpc.AddFollower(s.ctx, leader, &proto.AddFollowerRequest{
 		Namespace:           s.namespace,
 		Shard:               s.shard,
 		Term:                s.shardMetadata.Term,
 		FollowerName:        follower,
 		FollowerHeadEntryId: followerHeadEntryId,
+		ReplicationFactor:   replicationFactor,
 	}); err != nil {
 		return err
 	}
@@ -776,7 +913,7 @@ func (s *shardController) swapNode(from model.ServerAddress, to model.ServerAddr
 		slog.Any("from", from),
 		slog.Any("to", to),
 	)
-	if err := s.electLeader(); err != nil {
+	if err := s.electLeader(electionReasonEnsembleChange); err != nil {
 		res <- err
 		return
 	}
@@ -805,6 +942,255 @@ func (s *shardController) swapNode(from model.ServerAddress, to model.ServerAddr
 	res <- nil
 }
 
+func (s *shardController) AddNode(node model.ServerAddress) error {
+	res := make(chan error)
+	s.addNodeOp <- nodeRequest{
+		node: node,
+		res:  res,
+	}
+
+	return <-res
+}
+
+func (s *shardController) addNode(node model.ServerAddress) error {
+	if listContains(s.shardMetadata.Ensemble, node) {
+		return errors.Errorf("node %s is already part of the ensemble", node.Internal)
+	}
+
+	leader := s.shardMetadata.Leader
+	if leader == nil || s.shardMetadata.Status != model.ShardStatusSteadyState {
+		return errors.New("no leader is active on the shard")
+	}
+
+	s.log.Info(
+		"Adding node to the ensemble",
+		slog.Any("node", node),
+		slog.Any("ensemble", s.shardMetadata.Ensemble),
+	)
+
+	fr, _, err := s.newTerm(s.ctx, node)
+	if err != nil {
+		return err
+	}
+
+	// The leader will count the new node in the ack quorum only after it has
+	// caught up. Since a majority of the new ensemble always contains a majority
+	// of the old one, the entries committed so far are safe whether the
+	// coordinator or the leader switch first.
+	if err = s.addFollowerWithReplicationFactor(*leader, node.Internal, fr,
+		uint32(len(s.shardMetadata.Ensemble)+1)); err != nil {
+		return err
+	}
+
+	if err = s.waitForFollowersToCatchUp(s.currentElectionCtx, *leader, []model.ServerAddress{node}); err != nil {
+		return err
+	}
+
+	metadata := s.shardMetadata.Clone()
+	metadata.Ensemble = append(metadata.Ensemble, node)
+	if err = s.coordinator.ElectedLeader(s.namespace, s.shard, metadata); err != nil {
+		return err
+	}
+
+	s.shardMetadataMutex.Lock()
+	s.shardMetadata = metadata
+	s.shardMetadataMutex.Unlock()
+
+	s.log.Info(
+		"Successfully added node to the ensemble",
+		slog.Any("node", node),
+		slog.Any("ensemble", s.shardMetadata.Ensemble),
+	)
+	return nil
+}
+
+func (s *shardController) RemoveNode(node model.ServerAddress) error {
+	res := make(chan error)
+	s.removeNodeOp <- nodeRequest{
+		node: node,
+		res:  res,
+	}
+
+	return <-res
+}
+
+func (s *shardController) removeNode(node model.ServerAddress) error {
+	if !listContains(s.shardMetadata.Ensemble, node) {
+		return errors.Errorf("node %s is not part of the ensemble", node.Internal)
+	}
+
+	if len(s.shardMetadata.Ensemble) == 1 {
+		return errors.New("cannot remove the last node of the ensemble")
+	}
+
+	s.log.Info(
+		"Removing node from the ensemble",
+		slog.Any("node", node),
+		slog.Any("ensemble", s.shardMetadata.Ensemble),
+	)
+
+	// The new ensemble must be persisted before the leader shrinks its ack quorum.
+	// Entries committed with the smaller quorum might not be on a majority of the
+	// old ensemble, so a later election must not be based on the old one.
+	// The removed node is still fenced in the elections, until its data is deleted.
+	metadata := s.shardMetadata.Clone()
+	metadata.Ensemble = removeFromList(metadata.Ensemble, node)
+	metadata.RemovedNodes = append(metadata.RemovedNodes, node)
+
+	leader := s.shardMetadata.Leader
+	if leader == nil || *leader == node || s.shardMetadata.Status != model.ShardStatusSteadyState {
+		s.shardMetadataMutex.Lock()
+		s.shardMetadata = metadata
+		s.shardMetadataMutex.Unlock()
+		return s.electLeader(electionReasonEnsembleChange)
+	}
+
+	if err := s.coordinator.ElectedLeader(s.namespace, s.shard, metadata); err != nil {
+		return err
+	}
+
+	s.shardMetadataMutex.Lock()
+	s.shardMetadata = metadata
+	s.shardMetadataMutex.Unlock()
+
+	if _, err := s.rpc.RemoveFollower(s.ctx, *leader, &proto.RemoveFollowerRequest{
+		Namespace:    s.namespace,
+		Shard:        s.shard,
+		Term:         s.shardMetadata.Term,
+		FollowerName: node.Internal,
+	}); err != nil {
+		s.log.Warn(
+			"Failed to remove follower from the leader, starting a new election",
+			slog.Any("error", err),
+			slog.Any("node", node),
+		)
+		return s.electLeader(electionReasonEnsembleChange)
+	}
+
+	// If the removed node is not reachable, the data will be deleted
+	// during the next leader election
+	if err := s.deleteShardRpc(s.ctx, node); err != nil {
+		s.log.Warn(
+			"Failed to delete shard from the removed node",
+			slog.Any("error", err),
+			slog.Any("node", node),
+		)
+	} else {
+		metadata = s.shardMetadata.Clone()
+		metadata.RemovedNodes = removeFromList(metadata.RemovedNodes, node)
+		if err = s.coordinator.ElectedLeader(s.namespace, s.shard, metadata); err != nil {
+			return err
+		}
+
+		s.shardMetadataMutex.Lock()
+		s.shardMetadata = metadata
+		s.shardMetadataMutex.Unlock()
+	}
+
+	s.log.Info(
+		"Successfully removed node from the ensemble",
+		slog.Any("node", node),
+		slog.Any("ensemble", s.shardMetadata.Ensemble),
+	)
+	return nil
+}
+
+func (s *shardController) TransferLeadership(newLeader model.ServerAddress) error {
+	res := make(chan error)
+	s.transferLeadershipOp <- nodeRequest{
+		node: newLeader,
+		res:  res,
+	}
+
+	return <-res
+}
+
+func (s *shardController) transferLeadership(newLeader model.ServerAddress) error {
+	if !listContains(s.shardMetadata.Ensemble, newLeader) {
+		return errors.Errorf("node %s is not part of the ensemble", newLeader.Internal)
+	}
+
+	leader := s.shardMetadata.Leader
+	if leader == nil || s.shardMetadata.Status != model.ShardStatusSteadyState {
+		return errors.New("no leader is active on the shard")
+	}
+
+	if *leader == newLeader {
+		return nil
+	}
+
+	s.log.Info(
+		"Transferring leadership",
+		slog.Any("current-leader", leader),
+		slog.Any("new-leader", newLeader),
+	)
+
+	// The current leader stops accepting writes and waits until the new
+	// leader has all its entries. If the new leader is lagging behind,
+	// the request is refused and the current leader keeps serving.
+	if _, err := s.rpc.TransferLeadership(s.ctx, *leader, &proto.TransferLeadershipRequest{
+		Namespace: s.namespace,
+		Shard:     s.shard,
+		Term:      s.shardMetadata.Term,
+		NewLeader: newLeader.Internal,
+	}); err != nil {
+		return err
+	}
+
+	s.preferredLeader = &newLeader
+	defer func() {
+		s.preferredLeader = nil
+	}()
+
+	if err := s.electLeader(electionReasonTransfer); err != nil {
+		s.log.Warn(
+			"Failed to elect the new leader after the leadership transfer",
+			slog.Any("error", err),
+			slog.Any("new-leader", newLeader),
+		)
+		s.electLeaderWithRetries(electionReasonTransfer)
+		return err
+	}
+
+	if *s.shardMetadata.Leader != newLeader {
+		return errors.Errorf("node %s was not elected as leader, the new leader is %s",
+			newLeader.Internal, s.shardMetadata.Leader.Internal)
+	}
+
+	s.log.Info(
+		"Successfully transferred leadership",
+		slog.Any("new-leader", newLeader),
+	)
+	return nil
+}
+
+func (s *shardController) ElectLeader() error {
+	res := make(chan error)
+	s.electLeaderOp <- res
+	return <-res
+}
+
+func (s *shardController) forceElection() error {
+	if s.shardMetadata.Status == model.ShardStatusDeleting {
+		return errors.New("the shard is being deleted")
+	}
+
+	s.log.Info(
+		"Forcing leader election",
+		slog.Any("current-leader", s.shardMetadata.Leader),
+	)
+
+	if err := s.electLeader(electionReasonAdmin); err != nil {
+		s.log.Warn(
+			"Failed to elect a new leader",
+			slog.Any("error", err),
+		)
+		s.electLeaderWithRetries(electionReasonAdmin)
+		return err
+	}
+	return nil
+}
+
 func (s *shardController) isFollowerCatchUp(ctx context.Context, server model.ServerAddress, leaderHeadOffset int64) error {
 	fs, err := s.rpc.GetStatus(ctx, server, &proto.GetStatusRequest{Shard: s.shard})
 	if err != nil {
@@ -878,6 +1264,16 @@ func mergeLists[T any](lists ...[]T) []T {
 	return res
 }
 
+func removeFromList(list []model.ServerAddress, sa model.ServerAddress) []model.ServerAddress {
+	var res []model.ServerAddress
+	for _, item := range list {
+		if item != sa {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
 func replaceInList(list []model.ServerAddress, oldServerAddress, newServerAddress model.ServerAddress) []model.ServerAddress {
 	var res []model.ServerAddress
 	for _, item := range list {
@@ -42,7 +42,7 @@ func TestShardController(t *testing.T) {
 		Term:     1,
 		Leader:   nil,
 		Ensemble: []model.ServerAddress{s1, s2, s3},
-	}, rpc, coordinator)
+	}, rpc, coordinator, 0)
 
 	// Shard controller should initiate a leader election
 	// and newTerm each server
@@ -102,6 +102,167 @@ func TestShardController(t *testing.T) {
 	assert.NoError(t, sc.Close())
 }
 
+func TestShardController_ElectionBackoff(t *testing.T) {
+	var shard int64 = 5
+	rpc := newMockRpcProvider()
+	coordinator := newMockCoordinator()
+
+	s1 := model.ServerAddress{Public: "s1:9091", Internal: "s1:8191"}
+	s2 := model.ServerAddress{Public: "s2:9091", Internal: "s2:8191"}
+	s3 := model.ServerAddress{Public: "s3:9091", Internal: "s3:8191"}
+
+	electionBackoff := 400 * time.Millisecond
+	sc := NewShardController(common.DefaultNamespace, shard, model.ShardMetadata{
+		Status:   model.ShardStatusUnknown,
+		Term:     1,
+		Leader:   nil,
+		Ensemble: []model.ServerAddress{s1, s2, s3},
+	}, rpc, coordinator, electionBackoff)
+
+	// The initial election is not delayed
+	rpc.GetNode(s1).NewTermResponse(1, 0, nil)
+	rpc.GetNode(s2).NewTermResponse(1, -1, nil)
+	rpc.GetNode(s3).NewTermResponse(1, -1, nil)
+	rpc.GetNode(s1).BecomeLeaderResponse(nil)
+
+	rpc.GetNode(s1).expectNewTermRequest(t, shard, 2)
+	rpc.GetNode(s2).expectNewTermRequest(t, shard, 2)
+	rpc.GetNode(s3).expectNewTermRequest(t, shard, 2)
+	rpc.GetNode(s1).expectBecomeLeaderRequest(t, shard, 2, 3)
+
+	assert.Eventually(t, func() bool {
+		return sc.Status() == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// The election after the failure of the leader waits for the backoff,
+	// randomized between 50% and 150% of the configured value
+	rpc.FailNode(s1, errors.New("failed to connect"))
+	rpc.GetNode(s2).NewTermResponse(2, 0, nil)
+	rpc.GetNode(s3).NewTermResponse(2, -1, nil)
+	rpc.GetNode(s2).BecomeLeaderResponse(nil)
+
+	start := time.Now()
+	sc.HandleNodeFailure(s1)
+
+	rpc.GetNode(s2).expectNewTermRequest(t, shard, 3)
+	assert.GreaterOrEqual(t, time.Since(start), electionBackoff/2)
+	rpc.GetNode(s2).expectBecomeLeaderRequest(t, shard, 3, 3)
+
+	assert.Eventually(t, func() bool {
+		return sc.Status() == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, s2, *sc.Leader())
+
+	// The backoff grows when the leader of the shard keeps failing
+	start = time.Now()
+	sc.HandleNodeFailure(s2)
+
+	rpc.GetNode(s3).expectNewTermRequest(t, shard, 3)
+	rpc.GetNode(s3).expectNewTermRequest(t, shard, 4)
+	assert.GreaterOrEqual(t, time.Since(start), electionBackoff*3/4)
+
+	assert.NoError(t, sc.Close())
+}
+
+func TestShardController_ElectionWithNodeInMaintenance(t *testing.T) {
+	var shard int64 = 5
+	rpc := newMockRpcProvider()
+	coordinator := newMockCoordinator()
+
+	s1 := model.ServerAddress{Public: "s1:9091", Internal: "s1:8191"}
+	s2 := model.ServerAddress{Public: "s2:9091", Internal: "s2:8191"}
+	s3 := model.ServerAddress{Public: "s3:9091", Internal: "s3:8191"}
+
+	// s1 and s2 have the same head offset, though s1 is in maintenance
+	rpc.GetNode(s1).NewTermResponseInMaintenance(1, 0)
+	rpc.GetNode(s2).NewTermResponse(1, 0, nil)
+	rpc.GetNode(s3).NewTermResponse(1, -1, nil)
+
+	rpc.GetNode(s2).BecomeLeaderResponse(nil)
+
+	sc := NewShardController(common.DefaultNamespace, shard, model.ShardMetadata{
+		Status:   model.ShardStatusUnknown,
+		Term:     1,
+		Leader:   nil,
+		Ensemble: []model.ServerAddress{s1, s2, s3},
+	}, rpc, coordinator, 0)
+
+	rpc.GetNode(s1).expectNewTermRequest(t, shard, 2)
+	rpc.GetNode(s2).expectNewTermRequest(t, shard, 2)
+	rpc.GetNode(s3).expectNewTermRequest(t, shard, 2)
+
+	// s2 should be selected as new leader, because s1 is in maintenance
+	rpc.GetNode(s2).expectBecomeLeaderRequest(t, shard, 2, 3)
+
+	assert.Eventually(t, func() bool {
+		return sc.Status() == model.ShardStatusSteadyState
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.EqualValues(t, 2, sc.Term())
+	assert.Equal(t, s2, *sc.Leader())
+
+	// When the node in maintenance is the only one with all the entries,
+	// it still has to be selected
+	rpc.GetNode(s1).NewTermResponseInMaintenance(2, 5)
+	rpc.GetNode(s3).NewTermResponse(2, 3, nil)
+
+	rpc.GetNode(s1).BecomeLeaderResponse(nil)
+
+	rpc.FailNode(s2, errors.New("failed to connect"))
+	sc.HandleNodeFailure(s2)
+
+	rpc.GetNode(s1).expectNewTermRequest(t, shard, 3)
+	rpc.GetNode(s3).expectNewTermRequest(t, shard, 3)
+
+	rpc.GetNode(s1).expectBecomeLeaderRequest(t, shard, 3, 3)
+
+	assert.Eventually(t, func() bool {
+		return sc.Status() == model.ShardStatusSteadyState
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.EqualValues(t, 3, sc.Term())
+	assert.Equal(t, s1, *sc.Leader())
+
+	assert.NoError(t, sc.Close())
+}
+
+func TestShardController_ElectionWithNodeWithDiskFull(t *testing.T) {
+	var shard int64 = 5
+	rpc := newMockRpcProvider()
+	coordinator := newMockCoordinator()
+
+	s1 := model.ServerAddress{Public: "s1:9091", Internal: "s1:8191"}
+	s2 := model.ServerAddress{Public: "s2:9091", Internal: "s2:8191"}
+	s3 := model.ServerAddress{Public: "s3:9091", Internal: "s3:8191"}
+
+	// s1 and s2 have the same head offset, though the disk of s1 is full
+	rpc.GetNode(s1).NewTermResponseWithDiskFull(1, 0)
+	rpc.GetNode(s2).NewTermResponse(1, 0, nil)
+	rpc.GetNode(s3).NewTermResponse(1, -1, nil)
+
+	rpc.GetNode(s2).BecomeLeaderResponse(nil)
+
+	sc := NewShardController(common.DefaultNamespace, shard, model.ShardMetadata{
+		Status:   model.ShardStatusUnknown,
+		Term:     1,
+		Leader:   nil,
+		Ensemble: []model.ServerAddress{s1, s2, s3},
+	}, rpc, coordinator, 0)
+
+	rpc.GetNode(s1).expectNewTermRequest(t, shard, 2)
+	rpc.GetNode(s2).expectNewTermRequest(t, shard, 2)
+	rpc.GetNode(s3).expectNewTermRequest(t, shard, 2)
+
+	// s2 should be selected as new leader, because s1 would reject the writes
+	rpc.GetNode(s2).expectBecomeLeaderRequest(t, shard, 2, 3)
+
+	assert.Eventually(t, func() bool {
+		return sc.Status() == model.ShardStatusSteadyState
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.EqualValues(t, 2, sc.Term())
+	assert.Equal(t, s2, *sc.Leader())
+
+	assert.NoError(t, sc.Close())
+}
+
 func TestShardController_StartingWithLeaderAlreadyPresent(t *testing.T) {
 	var shard int64 = 5
 	rpc := newMockRpcProvider()
@@ -116,7 +277,7 @@ func TestShardController_StartingWithLeaderAlreadyPresent(t *testing.T) {
 		Term:     1,
 		Leader:   &s1,
 		Ensemble: []model.ServerAddress{s1, s2, s3},
-	}, rpc, coordinator)
+	}, rpc, coordinator, 0)
 
 	select {
 	case <-rpc.GetNode(s1).newTermRequests:
@@ -147,7 +308,7 @@ func TestShardController_NewTermWithNonRespondingServer(t *testing.T) {
 		Term:     1,
 		Leader:   nil,
 		Ensemble: []model.ServerAddress{s1, s2, s3},
-	}, rpc, coordinator)
+	}, rpc, coordinator, 0)
 
 	timeStart := time.Now()
 
@@ -188,7 +349,7 @@ func TestShardController_NewTermFollowerUntilItRecovers(t *testing.T) {
 		Term:     1,
 		Leader:   nil,
 		Ensemble: []model.ServerAddress{s1, s2, s3},
-	}, rpc, coordinator)
+	}, rpc, coordinator, 0)
 
 	// s3 is failing, though we can still elect a leader
 	rpc.GetNode(s1).NewTermResponse(1, 0, nil)
@@ -242,7 +403,7 @@ func TestShardController_VerifyFollowersWereAllFenced(t *testing.T) {
 		Term:     4,
 		Leader:   &s1,
 		Ensemble: []model.ServerAddress{s1, s2, s3},
-	}, rpc, coordinator)
+	}, rpc, coordinator, 0)
 
 	r1 := <-n1.getStatusRequests
 	assert.EqualValues(t, 5, r1.Shard)
@@ -294,6 +455,171 @@ type sCoordinatorEvents struct {
 	metadata model.ShardMetadata
 }
 
+func TestShardController_AddAndRemoveNode(t *testing.T) {
+	var shard int64 = 5
+	rpc := newMockRpcProvider()
+	coordinator := newMockCoordinator()
+
+	s1 := model.ServerAddress{Public: "s1:9091", Internal: "s1:8191"}
+	s2 := model.ServerAddress{Public: "s2:9091", Internal: "s2:8191"}
+	s3 := model.ServerAddress{Public: "s3:9091", Internal: "s3:8191"}
+	s4 := model.ServerAddress{Public: "s4:9091", Internal: "s4:8191"}
+
+	sc := NewShardController(common.DefaultNamespace, shard, model.ShardMetadata{
+		Status:   model.ShardStatusUnknown,
+		Term:     1,
+		Leader:   nil,
+		Ensemble: []model.ServerAddress{s1, s2, s3},
+	}, rpc, coordinator, 0)
+
+	rpc.GetNode(s1).NewTermResponse(1, 0, nil)
+	rpc.GetNode(s2).NewTermResponse(1, -1, nil)
+	rpc.GetNode(s3).NewTermResponse(1, -1, nil)
+	rpc.GetNode(s1).BecomeLeaderResponse(nil)
+
+	rpc.GetNode(s1).expectBecomeLeaderRequest(t, shard, 2, 3)
+
+	assert.Eventually(t, func() bool {
+		return sc.Status() == model.ShardStatusSteadyState
+	}, 10*time.Second, 100*time.Millisecond)
+
+	ensemble := func() []model.ServerAddress {
+		s := sc.(*shardController)
+		s.shardMetadataMutex.Lock()
+		defer s.shardMetadataMutex.Unlock()
+		return s.shardMetadata.Ensemble
+	}
+
+	// Add a new member, without changing the leader or the term
+	rpc.GetNode(s4).NewTermResponse(-1, -1, nil)
+	rpc.GetNode(s1).AddFollowerResponse(nil)
+	rpc.GetNode(s1).GetStatusResponse(2, proto.ServingStatus_LEADER, 0, nil)
+	rpc.GetNode(s4).GetStatusResponse(2, proto.ServingStatus_FOLLOWER, 0, nil)
+
+	assert.NoError(t, sc.AddNode(s4))
+
+	rpc.GetNode(s4).expectNewTermRequest(t, shard, 2)
+	af := <-rpc.GetNode(s1).addFollowerRequests
+	assert.Equal(t, s4.Internal, af.FollowerName)
+	assert.EqualValues(t, 4, af.ReplicationFactor)
+	assert.Equal(t, []model.ServerAddress{s1, s2, s3, s4}, ensemble())
+
+	assert.Error(t, sc.AddNode(s4))
+
+	// Remove a follower
+	rpc.GetNode(s1).RemoveFollowerResponse(nil)
+	rpc.GetNode(s2).DeleteShardResponse(nil)
+
+	assert.NoError(t, sc.RemoveNode(s2))
+
+	rpc.GetNode(s1).expectRemoveFollowerRequest(t, shard, 2, s2.Internal)
+	<-rpc.GetNode(s2).deleteShardRequests
+	assert.Equal(t, []model.ServerAddress{s1, s3, s4}, ensemble())
+	assert.Empty(t, sc.(*shardController).shardMetadata.RemovedNodes)
+	assert.EqualValues(t, 2, sc.Term())
+	assert.Equal(t, s1, *sc.Leader())
+
+	assert.Error(t, sc.RemoveNode(s2))
+
+	assert.NoError(t, sc.Close())
+}
+
+func TestShardController_TransferLeadership(t *testing.T) {
+	var shard int64 = 5
+	rpc := newMockRpcProvider()
+	coordinator := newMockCoordinator()
+
+	s1 := model.ServerAddress{Public: "s1:9091", Internal: "s1:8191"}
+	s2 := model.ServerAddress{Public: "s2:9091", Internal: "s2:8191"}
+	s3 := model.ServerAddress{Public: "s3:9091", Internal: "s3:8191"}
+
+	sc := NewShardController(common.DefaultNamespace, shard, model.ShardMetadata{
+		Status:   model.ShardStatusUnknown,
+		Term:     1,
+		Leader:   nil,
+		Ensemble: []model.ServerAddress{s1, s2, s3},
+	}, rpc, coordinator, 0)
+
+	rpc.GetNode(s1).NewTermResponse(1, 0, nil)
+	rpc.GetNode(s2).NewTermResponse(1, -1, nil)
+	rpc.GetNode(s3).NewTermResponse(1, -1, nil)
+	rpc.GetNode(s1).BecomeLeaderResponse(nil)
+
+	rpc.GetNode(s1).expectBecomeLeaderRequest(t, shard, 2, 3)
+
+	assert.Eventually(t, func() bool {
+		return sc.Status() == model.ShardStatusSteadyState
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// The leader refuses to transfer to a lagging follower
+	rpc.GetNode(s1).TransferLeadershipResponse(errors.New("follower is lagging behind"))
+	assert.Error(t, sc.TransferLeadership(s3))
+
+	tl := <-rpc.GetNode(s1).transferLeadershipRequests
+	assert.Equal(t, s3.Internal, tl.NewLeader)
+	assert.EqualValues(t, 2, tl.Term)
+	assert.EqualValues(t, 2, sc.Term())
+	assert.Equal(t, s1, *sc.Leader())
+
+	// Once s2 has caught up, it's elected in the next term, even though s1
+	// has the same entries
+	rpc.GetNode(s1).TransferLeadershipResponse(nil)
+	rpc.GetNode(s1).NewTermResponse(2, 5, nil)
+	rpc.GetNode(s2).NewTermResponse(2, 5, nil)
+	rpc.GetNode(s3).NewTermResponse(2, 3, nil)
+	rpc.GetNode(s2).BecomeLeaderResponse(nil)
+
+	assert.NoError(t, sc.TransferLeadership(s2))
+
+	rpc.GetNode(s2).expectBecomeLeaderRequest(t, shard, 3, 3)
+	assert.EqualValues(t, 3, sc.Term())
+	assert.Equal(t, s2, *sc.Leader())
+
+	assert.NoError(t, sc.Close())
+}
+
+func TestShardController_ElectLeader(t *testing.T) {
+	var shard int64 = 5
+	rpc := newMockRpcProvider()
+	coordinator := newMockCoordinator()
+
+	s1 := model.ServerAddress{Public: "s1:9091", Internal: "s1:8191"}
+	s2 := model.ServerAddress{Public: "s2:9091", Internal: "s2:8191"}
+	s3 := model.ServerAddress{Public: "s3:9091", Internal: "s3:8191"}
+
+	sc := NewShardController(common.DefaultNamespace, shard, model.ShardMetadata{
+		Status:   model.ShardStatusUnknown,
+		Term:     1,
+		Leader:   nil,
+		Ensemble: []model.ServerAddress{s1, s2, s3},
+	}, rpc, coordinator, 0)
+
+	rpc.GetNode(s1).NewTermResponse(1, 0, nil)
+	rpc.GetNode(s2).NewTermResponse(1, -1, nil)
+	rpc.GetNode(s3).NewTermResponse(1, -1, nil)
+	rpc.GetNode(s1).BecomeLeaderResponse(nil)
+
+	rpc.GetNode(s1).expectBecomeLeaderRequest(t, shard, 2, 3)
+
+	assert.Eventually(t, func() bool {
+		return sc.Status() == model.ShardStatusSteadyState
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// The election is forced while the leader is healthy
+	rpc.GetNode(s1).NewTermResponse(2, 3, nil)
+	rpc.GetNode(s2).NewTermResponse(2, 5, nil)
+	rpc.GetNode(s3).NewTermResponse(2, 3, nil)
+	rpc.GetNode(s2).BecomeLeaderResponse(nil)
+
+	assert.NoError(t, sc.ElectLeader())
+
+	rpc.GetNode(s2).expectBecomeLeaderRequest(t, shard, 3, 3)
+	assert.EqualValues(t, 3, sc.Term())
+	assert.Equal(t, s2, *sc.Leader())
+
+	assert.NoError(t, sc.Close())
+}
+
 type mockCoordinator struct {
 	sync.Mutex
 	err                      error
@@ -316,6 +642,38 @@ func (m *mockCoordinator) ClusterStatus() model.ClusterStatus {
 	panic("not implemented")
 }
 
+func (m *mockCoordinator) NodesStatus() map[string]NodeStatus {
+	panic("not implemented")
+}
+
+func (m *mockCoordinator) RebalancePlan() RebalancePlan {
+	panic("not implemented")
+}
+
+func (m *mockCoordinator) ListShards() []ShardInfo {
+	panic("not implemented")
+}
+
+func (m *mockCoordinator) ListServers() []ServerInfo {
+	panic("not implemented")
+}
+
+func (m *mockCoordinator) TriggerElection(shard int64) error {
+	panic("not implemented")
+}
+
+func (m *mockCoordinator) TransferLeader(shard int64, newLeader string) error {
+	panic("not implemented")
+}
+
+func (m *mockCoordinator) DrainServer(ctx context.Context, server string, removeReplicas bool, progress func(DrainProgress)) error {
+	panic("not implemented")
+}
+
+func (m *mockCoordinator) CompactShard(ctx context.Context, shard int64) ([]ShardCompaction, error) {
+	panic("not implemented")
+}
+
 func (m *mockCoordinator) WaitForNextUpdate(ctx context.Context, currentValue *proto.ShardAssignments) (*proto.ShardAssignments, error) {
 	panic("not implemented")
 }
@@ -353,6 +711,14 @@ func (m *mockCoordinator) ShardDeleted(namespace string, shard int64) error {
 	return nil
 }
 
+func (m *mockCoordinator) SplitShard(namespace string, shard int64) (int64, int64, error) {
+	panic("not implemented")
+}
+
 func (m *mockCoordinator) NodeBecameUnavailable(node model.ServerAddress) {
 	panic("not implemented")
 }
+
+func (m *mockCoordinator) NodeEnteredMaintenance(node model.ServerAddress) {
+	panic("not implemented")
+}
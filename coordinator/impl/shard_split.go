@@ -0,0 +1,342 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/proto"
+)
+
+var (
+	ErrShardNotFound         = errors.New("shard not found")
+	ErrShardCannotBeSplit    = errors.New("shard cannot be split")
+	ErrShardAlreadySplitting = errors.New("shard is already being split")
+)
+
+// SplitShard divides the hash range of a shard in two halves, each one
+// assigned to a new shard, with the same ensemble as the parent.
+//
+// The split is carried out in background: first the children shards are
+// bootstrapped with the data of the parent, while it keeps serving the
+// requests. Then the parent stops accepting requests, the children leaders
+// are elected and the shard assignments are switched to the children in a
+// single update. The progress is persisted, so that the split is resumed
+// if the coordinator restarts.
+func (c *coordinator) SplitShard(namespace string, shard int64) (leftChild int64, rightChild int64, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	cs := c.clusterStatus.Clone()
+	ns, ok := cs.Namespaces[namespace]
+	if !ok {
+		return -1, -1, ErrNamespaceNotFound
+	}
+
+	parent, ok := ns.Shards[shard]
+	switch {
+	case !ok:
+		return -1, -1, ErrShardNotFound
+	case parent.Split != nil:
+		return -1, -1, ErrShardAlreadySplitting
+	case parent.Status != model.ShardStatusSteadyState || parent.Leader == nil:
+		return -1, -1, errors.Wrapf(ErrShardCannotBeSplit, "shard %d is in status %s", shard, parent.Status)
+	case parent.Int32HashRange.Min == parent.Int32HashRange.Max:
+		return -1, -1, errors.Wrapf(ErrShardCannotBeSplit, "shard %d has a single hash", shard)
+	}
+
+	leftChild = cs.ShardIdGenerator
+	rightChild = cs.ShardIdGenerator + 1
+	cs.ShardIdGenerator += 2
+
+	splitPoint := parent.Int32HashRange.Min + (parent.Int32HashRange.Max-parent.Int32HashRange.Min)/2
+	hashRanges := map[int64]model.Int32HashRange{
+		leftChild:  {Min: parent.Int32HashRange.Min, Max: splitPoint},
+		rightChild: {Min: splitPoint + 1, Max: parent.Int32HashRange.Max},
+	}
+
+	for child, hashRange := range hashRanges {
+		ensemble := make([]model.ServerAddress, len(parent.Ensemble))
+		copy(ensemble, parent.Ensemble)
+
+		ns.Shards[child] = model.ShardMetadata{
+			Status:         model.ShardStatusUnknown,
+			Term:           parent.Term,
+			Leader:         nil,
+			Ensemble:       ensemble,
+			RemovedNodes:   []model.ServerAddress{},
+			Int32HashRange: hashRange,
+			Split: &model.SplitMetadata{
+				Phase:         model.SplitPhaseBootstrap,
+				ParentShardId: shard,
+			},
+		}
+	}
+
+	parent.Split = &model.SplitMetadata{
+		Phase:         model.SplitPhaseBootstrap,
+		ParentShardId: shard,
+		ChildShardIds: []int64{leftChild, rightChild},
+	}
+	ns.Shards[shard] = parent
+
+	newMetadataVersion, err := c.MetadataProvider.Store(cs, c.metadataVersion)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	c.metadataVersion = newMetadataVersion
+	c.clusterStatus = cs
+
+	c.log.Info(
+		"Splitting shard",
+		slog.String("namespace", namespace),
+		slog.Int64("shard", shard),
+		slog.Any("children", hashRanges),
+	)
+
+	c.startShardSplit(namespace, shard)
+	return leftChild, rightChild, nil
+}
+
+func (c *coordinator) startShardSplit(namespace string, shard int64) {
+	go common.DoWithLabels(
+		c.ctx,
+		map[string]string{
+			"oxia":  "split-shard",
+			"shard": fmt.Sprintf("%d", shard),
+		},
+		func() {
+			_ = backoff.RetryNotify(func() error {
+				return c.continueShardSplit(namespace, shard)
+			}, common.NewBackOff(c.ctx), func(err error, duration time.Duration) {
+				c.log.Warn(
+					"Shard split failed, retrying later",
+					slog.String("namespace", namespace),
+					slog.Int64("shard", shard),
+					slog.Duration("retry-after", duration),
+					slog.Any("error", err),
+				)
+			})
+		},
+	)
+}
+
+func (c *coordinator) continueShardSplit(namespace string, shard int64) error {
+	parent, children, err := c.getSplitShards(namespace, shard)
+	if err != nil || parent.Split == nil {
+		return err
+	}
+
+	if parent.Split.Phase == model.SplitPhaseBootstrap {
+		if err = c.bootstrapSplitChildren(namespace, shard, parent, children); err != nil {
+			return err
+		}
+	}
+
+	return c.cutoverShardSplit(namespace, shard)
+}
+
+func (c *coordinator) getSplitShards(namespace string, shard int64) (parent model.ShardMetadata, children map[int64]model.ShardMetadata, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	ns, ok := c.clusterStatus.Namespaces[namespace]
+	if !ok {
+		return parent, nil, ErrNamespaceNotFound
+	}
+
+	if parent, ok = ns.Shards[shard]; !ok {
+		return parent, nil, ErrShardNotFound
+	}
+
+	children = make(map[int64]model.ShardMetadata)
+	if parent.Split != nil {
+		for _, child := range parent.Split.ChildShardIds {
+			children[child] = ns.Shards[child].Clone()
+		}
+	}
+	return parent.Clone(), children, nil
+}
+
+// Replicate the parent shard into the children, until the parent leader
+// appends the split entry and a majority of each child has received it.
+func (c *coordinator) bootstrapSplitChildren(namespace string, shard int64,
+	parent model.ShardMetadata, children map[int64]model.ShardMetadata) error {
+	if parent.Leader == nil || parent.Status != model.ShardStatusSteadyState {
+		return errors.Errorf("shard %d has no leader", shard)
+	}
+
+	req := &proto.SplitShardRequest{
+		Namespace: namespace,
+		Shard:     shard,
+		Term:      parent.Term,
+	}
+
+	for child, childMetadata := range children {
+		splitChild := &proto.SplitShardChild{
+			Shard: child,
+			Int32HashRange: &proto.Int32HashRange{
+				MinHashInclusive: childMetadata.Int32HashRange.Min,
+				MaxHashInclusive: childMetadata.Int32HashRange.Max,
+			},
+		}
+
+		for _, node := range childMetadata.Ensemble {
+			// The children members follow the parent leader for now, in
+			// the same term
+			if _, err := c.rpc.NewTerm(c.ctx, node, &proto.NewTermRequest{
+				Namespace: namespace,
+				Shard:     child,
+				Term:      parent.Term,
+			}); err != nil && status.Code(err) != common.CodeInvalidStatus {
+				return errors.Wrapf(err, "failed to fence shard %d on %s", child, node.Internal)
+			}
+
+			splitChild.Ensemble = append(splitChild.Ensemble, node.Internal)
+		}
+
+		req.Children = append(req.Children, splitChild)
+	}
+
+	res, err := c.rpc.SplitShard(c.ctx, *parent.Leader, req)
+	if err != nil {
+		return err
+	}
+
+	c.log.Info(
+		"Children shards were bootstrapped",
+		slog.String("namespace", namespace),
+		slog.Int64("shard", shard),
+		slog.Any("split-entry-id", res.SplitEntryId),
+	)
+
+	return c.updateSplitMetadata(namespace, shard, func(ns model.NamespaceStatus, parent *model.ShardMetadata) {
+		parent.Split.Phase = model.SplitPhaseCutover
+		for _, child := range parent.Split.ChildShardIds {
+			childMetadata := ns.Shards[child]
+			childMetadata.Term = res.SplitEntryId.Term
+			childMetadata.Split.Phase = model.SplitPhaseCutover
+			ns.Shards[child] = childMetadata
+		}
+	})
+}
+
+// Elect the leaders of the children shards and then replace the parent
+// with the children in the shard assignments.
+func (c *coordinator) cutoverShardSplit(namespace string, shard int64) error {
+	c.Lock()
+	defer c.Unlock()
+
+	ns := c.clusterStatus.Namespaces[namespace]
+	children := ns.Shards[shard].Split.ChildShardIds
+	for _, child := range children {
+		if _, ok := c.shardControllers[child]; !ok {
+			c.shardControllers[child] = NewShardController(namespace, child, ns.Shards[child], c.rpc, c, c.failureDetector.ElectionBackoff)
+		}
+	}
+
+	for !c.splitChildrenAreElected(namespace, children) {
+		if err := c.assignmentsChanged.Wait(c.ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := c.updateSplitMetadataNoMutex(namespace, shard, func(ns model.NamespaceStatus, parent *model.ShardMetadata) {
+		for _, child := range parent.Split.ChildShardIds {
+			childMetadata := ns.Shards[child]
+			childMetadata.Split = nil
+			ns.Shards[child] = childMetadata
+		}
+
+		parent.Split = nil
+		parent.Status = model.ShardStatusDeleting
+	}); err != nil {
+		return err
+	}
+
+	c.log.Info(
+		"Shard was split",
+		slog.String("namespace", namespace),
+		slog.Int64("shard", shard),
+		slog.Any("children", children),
+	)
+
+	if sc, ok := c.shardControllers[shard]; ok {
+		sc.DeleteShard()
+	}
+	return nil
+}
+
+func (c *coordinator) splitChildrenAreElected(namespace string, children []int64) bool {
+	ns := c.clusterStatus.Namespaces[namespace]
+	for _, child := range children {
+		childMetadata, ok := ns.Shards[child]
+		if !ok || childMetadata.Leader == nil || childMetadata.Status != model.ShardStatusSteadyState {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *coordinator) updateSplitMetadata(namespace string, shard int64, update func(model.NamespaceStatus, *model.ShardMetadata)) error {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.updateSplitMetadataNoMutex(namespace, shard, update)
+}
+
+func (c *coordinator) updateSplitMetadataNoMutex(namespace string, shard int64, update func(model.NamespaceStatus, *model.ShardMetadata)) error {
+	cs := c.clusterStatus.Clone()
+	ns := cs.Namespaces[namespace]
+	parent := ns.Shards[shard]
+	update(ns, &parent)
+	ns.Shards[shard] = parent
+
+	newMetadataVersion, err := c.MetadataProvider.Store(cs, c.metadataVersion)
+	if err != nil {
+		return err
+	}
+
+	c.metadataVersion = newMetadataVersion
+	c.clusterStatus = cs
+
+	c.computeNewAssignments()
+	return nil
+}
+
+// The children shards are not visible to the clients until the split is
+// completed.
+func isSplitChild(shard int64, metadata model.ShardMetadata) bool {
+	return metadata.Split != nil && metadata.Split.ParentShardId != shard
+}
+
+// Keep the split progress, and the deletion of a parent that was split, when
+// the shard controller updates the shard metadata.
+func mergeShardMetadata(existing model.ShardMetadata, metadata model.ShardMetadata) model.ShardMetadata {
+	metadata.Split = existing.Split.Clone()
+	if existing.Status == model.ShardStatusDeleting {
+		metadata.Status = model.ShardStatusDeleting
+	}
+	return metadata
+}
@@ -0,0 +1,227 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/oxia"
+	"github.com/streamnative/oxia/proto"
+)
+
+func TestCoordinator_SplitShard(t *testing.T) {
+	s1, sa1 := newServer(t)
+	s2, sa2 := newServer(t)
+	s3, sa3 := newServer(t)
+
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              common.DefaultNamespace,
+			ReplicationFactor: 3,
+			InitialShardCount: 1,
+		}},
+		Servers: []model.ServerAddress{sa1, sa2, sa3},
+	}
+	clientPool := common.NewClientPool(nil, nil)
+
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		shard := coordinator.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0]
+		return shard.Status == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+
+	client, err := oxia.NewSyncClient(sa1.Public)
+	assert.NoError(t, err)
+
+	// Keep writing while the shard gets split, and record all the writes
+	// that were acknowledged
+	m := sync.Mutex{}
+	ackedKeys := map[string]string{}
+	stop := make(chan any)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				key := fmt.Sprintf("key-%d-%d", w, i)
+				value := fmt.Sprintf("value-%d-%d", w, i)
+				if _, _, err := client.Put(context.Background(), key, []byte(value)); err == nil {
+					m.Lock()
+					ackedKeys[key] = value
+					m.Unlock()
+				}
+			}
+		}(w)
+	}
+
+	assert.Eventually(t, func() bool {
+		m.Lock()
+		defer m.Unlock()
+		return len(ackedKeys) >= 100
+	}, 10*time.Second, 10*time.Millisecond)
+
+	leftChild, rightChild, err := coordinator.SplitShard(common.DefaultNamespace, 0)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, leftChild)
+	assert.EqualValues(t, 2, rightChild)
+
+	_, _, err = coordinator.SplitShard(common.DefaultNamespace, 0)
+	assert.ErrorIs(t, err, ErrShardAlreadySplitting)
+
+	assert.Eventually(t, func() bool {
+		shards := coordinator.ClusterStatus().Namespaces[common.DefaultNamespace].Shards
+		_, parentExists := shards[0]
+		return !parentExists && len(shards) == 2 &&
+			shards[leftChild].Status == model.ShardStatusSteadyState &&
+			shards[rightChild].Status == model.ShardStatusSteadyState
+	}, 30*time.Second, 10*time.Millisecond)
+
+	// Let the writers go through the children shards for a bit
+	m.Lock()
+	acked := len(ackedKeys)
+	m.Unlock()
+	assert.Eventually(t, func() bool {
+		m.Lock()
+		defer m.Unlock()
+		return len(ackedKeys) >= acked+100
+	}, 10*time.Second, 10*time.Millisecond)
+
+	close(stop)
+	wg.Wait()
+
+	shards := coordinator.ClusterStatus().Namespaces[common.DefaultNamespace].Shards
+	assert.Nil(t, shards[leftChild].Split)
+	assert.Nil(t, shards[rightChild].Split)
+	assert.EqualValues(t, 0, shards[leftChild].Int32HashRange.Min)
+	assert.Equal(t, shards[leftChild].Int32HashRange.Max+1, shards[rightChild].Int32HashRange.Min)
+	assert.EqualValues(t, math.MaxUint32, shards[rightChild].Int32HashRange.Max)
+
+	// No acknowledged write was lost
+	for key, value := range ackedKeys {
+		_, res, _, err := client.Get(context.Background(), key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, value, string(res), key)
+	}
+
+	// Every key is stored in exactly one child, the one owning its hash
+	keys := map[string]int64{}
+	for _, child := range []int64{leftChild, rightChild} {
+		hashRange := shards[child].Int32HashRange
+		for _, key := range listShard(t, clientPool, shards[child].Leader.Public, child) {
+			_, found := keys[key]
+			assert.False(t, found, key)
+			keys[key] = child
+
+			hash := common.Xxh332(key)
+			assert.True(t, hash >= hashRange.Min && hash <= hashRange.Max, key)
+		}
+	}
+
+	for key := range ackedKeys {
+		assert.Contains(t, keys, key)
+	}
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, coordinator.Close())
+	assert.NoError(t, clientPool.Close())
+
+	assert.NoError(t, s1.Close())
+	assert.NoError(t, s2.Close())
+	assert.NoError(t, s3.Close())
+}
+
+func TestCoordinator_SplitShardValidation(t *testing.T) {
+	s1, sa1 := newServer(t)
+
+	metadataProvider := NewMetadataProviderMemory()
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              common.DefaultNamespace,
+			ReplicationFactor: 1,
+			InitialShardCount: 1,
+		}},
+		Servers: []model.ServerAddress{sa1},
+	}
+	clientPool := common.NewClientPool(nil, nil)
+
+	coordinator, err := NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, NewRpcProvider(clientPool), testFailureDetector, RebalanceOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		shard := coordinator.ClusterStatus().Namespaces[common.DefaultNamespace].Shards[0]
+		return shard.Status == model.ShardStatusSteadyState
+	}, 10*time.Second, 10*time.Millisecond)
+
+	_, _, err = coordinator.SplitShard("my-ns", 0)
+	assert.ErrorIs(t, err, ErrNamespaceNotFound)
+
+	_, _, err = coordinator.SplitShard(common.DefaultNamespace, 5)
+	assert.ErrorIs(t, err, ErrShardNotFound)
+
+	assert.NoError(t, coordinator.Close())
+	assert.NoError(t, clientPool.Close())
+	assert.NoError(t, s1.Close())
+}
+
+func listShard(t *testing.T, clientPool common.ClientPool, addr string, shard int64) []string {
+	t.Helper()
+
+	rpc, err := clientPool.GetClientRpc(addr)
+	assert.NoError(t, err)
+
+	stream, err := rpc.List(context.Background(), &proto.ListRequest{
+		Shard:          &shard,
+		StartInclusive: "a",
+		EndExclusive:   "z",
+	})
+	assert.NoError(t, err)
+
+	var keys []string
+	for {
+		res, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return keys
+		}
+		assert.NoError(t, err)
+		if err != nil {
+			return keys
+		}
+
+		keys = append(keys, res.Keys...)
+	}
+}
@@ -41,3 +41,17 @@ func TestShardStatus_JSON(t *testing.T) {
 	err = s.UnmarshalJSON([]byte("xyz"))
 	assert.Error(t, err)
 }
+
+func TestSplitPhase_JSON(t *testing.T) {
+	j, err := model.SplitPhaseCutover.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\"Cutover\""), j)
+
+	var p model.SplitPhase
+	err = p.UnmarshalJSON(j)
+	assert.NoError(t, err)
+	assert.Equal(t, model.SplitPhaseCutover, p)
+
+	err = p.UnmarshalJSON([]byte("xyz"))
+	assert.Error(t, err)
+}
@@ -17,6 +17,11 @@ package model
 type ClusterConfig struct {
 	Namespaces []NamespaceConfig `json:"namespaces" yaml:"namespaces"`
 	Servers    []ServerAddress   `json:"servers" yaml:"servers"`
+
+	// ServerZones is an optional hint of the zone of each server, keyed by
+	// the internal address. The replicas of a shard are spread across the
+	// zones when the cluster is rebalanced.
+	ServerZones map[string]string `json:"serverZones,omitempty" yaml:"serverZones,omitempty"`
 }
 
 type NamespaceConfig struct {
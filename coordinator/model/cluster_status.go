@@ -14,6 +14,8 @@
 
 package model
 
+import "github.com/pkg/errors"
+
 type ServerAddress struct {
 	// Public is the endpoint that is advertised to clients
 	Public string `json:"public" yaml:"public"`
@@ -22,6 +24,13 @@ type ServerAddress struct {
 	Internal string `json:"internal" yaml:"internal"`
 }
 
+// ServerLocality is where a server is running, as reported by the server
+// itself. Empty values are unknown.
+type ServerLocality struct {
+	Zone string `json:"zone,omitempty" yaml:"zone,omitempty"`
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+}
+
 type Int32HashRange struct {
 	// The minimum inclusive hash that the shard can contain
 	Min uint32 `json:"min"`
@@ -37,6 +46,10 @@ type ShardMetadata struct {
 	Ensemble       []ServerAddress `json:"ensemble" yaml:"ensemble"`
 	RemovedNodes   []ServerAddress `json:"removedNodes" yaml:"removedNodes"`
 	Int32HashRange Int32HashRange  `json:"int32HashRange" yaml:"int32HashRange"`
+
+	// Split is set while the shard is being split, or is one of the
+	// shards that are replacing a shard being split
+	Split *SplitMetadata `json:"split,omitempty" yaml:"split,omitempty"`
 }
 
 type NamespaceStatus struct {
@@ -58,6 +71,53 @@ func NewClusterStatus() *ClusterStatus {
 	}
 }
 
+// Validate checks the consistency of a cluster status loaded from the
+// metadata, before the coordinator starts acting on it.
+func (c *ClusterStatus) Validate() error {
+	if c.ShardIdGenerator < 0 {
+		return errors.Errorf("invalid shard id generator %d", c.ShardIdGenerator)
+	}
+
+	// The shard ids are unique across all the namespaces
+	shards := make(map[int64]string)
+	for name, ns := range c.Namespaces {
+		if name == "" {
+			return errors.New("namespace with empty name")
+		}
+		if ns.ReplicationFactor == 0 {
+			return errors.Errorf("namespace %s has replication factor 0", name)
+		}
+
+		for shard, sm := range ns.Shards {
+			if other, ok := shards[shard]; ok {
+				return errors.Errorf("shard %d is in both namespaces %s and %s", shard, other, name)
+			}
+			shards[shard] = name
+
+			if err := sm.validate(shard, c.ShardIdGenerator); err != nil {
+				return errors.Wrapf(err, "namespace %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+func (sm ShardMetadata) validate(shard int64, shardIdGenerator int64) error {
+	switch {
+	case shard < 0 || shard >= shardIdGenerator:
+		return errors.Errorf("shard %d is outside of the generated ids [0, %d)", shard, shardIdGenerator)
+	case !sm.Status.valid():
+		return errors.Errorf("shard %d has unknown status %d", shard, sm.Status)
+	case sm.Term < -1:
+		return errors.Errorf("shard %d has invalid term %d", shard, sm.Term)
+	case len(sm.Ensemble) == 0:
+		return errors.Errorf("shard %d has an empty ensemble", shard)
+	case sm.Int32HashRange.Min > sm.Int32HashRange.Max:
+		return errors.Errorf("shard %d has invalid hash range [%d, %d]", shard, sm.Int32HashRange.Min, sm.Int32HashRange.Max)
+	}
+	return nil
+}
+
 func (sm Int32HashRange) Clone() Int32HashRange {
 	return Int32HashRange{
 		Min: sm.Min,
@@ -73,6 +133,7 @@ func (sm ShardMetadata) Clone() ShardMetadata {
 		Ensemble:       make([]ServerAddress, len(sm.Ensemble)),
 		RemovedNodes:   make([]ServerAddress, len(sm.RemovedNodes)),
 		Int32HashRange: sm.Int32HashRange.Clone(),
+		Split:          sm.Split.Clone(),
 	}
 
 	copy(r.Ensemble, sm.Ensemble)
@@ -45,6 +45,11 @@ func TestClusterStatus_Clone(t *testing.T) {
 							Public:   "r1",
 							Internal: "r1",
 						}},
+						Split: &SplitMetadata{
+							Phase:         SplitPhaseCutover,
+							ParentShardId: 0,
+							ChildShardIds: []int64{1, 2},
+						},
 					},
 				},
 			},
@@ -64,6 +69,67 @@ func TestClusterStatus_Clone(t *testing.T) {
 	assert.Equal(t, cs1.Namespaces["test-ns"].Shards[0], cs2.Namespaces["test-ns"].Shards[0])
 	assert.NotSame(t, cs1.Namespaces["test-ns"].Shards[0], cs2.Namespaces["test-ns"].Shards[0])
 
+	assert.Equal(t, cs1.Namespaces["test-ns"].Shards[0].Split, cs2.Namespaces["test-ns"].Shards[0].Split)
+	assert.NotSame(t, cs1.Namespaces["test-ns"].Shards[0].Split, cs2.Namespaces["test-ns"].Shards[0].Split)
+
 	assert.Equal(t, cs1.ShardIdGenerator, cs2.ShardIdGenerator)
 	assert.Equal(t, cs1.ServerIdx, cs2.ServerIdx)
 }
+
+func TestClusterStatus_Validate(t *testing.T) {
+	newStatus := func(update func(cs *ClusterStatus)) *ClusterStatus {
+		cs := &ClusterStatus{
+			Namespaces: map[string]NamespaceStatus{
+				"ns-1": {
+					ReplicationFactor: 1,
+					Shards: map[int64]ShardMetadata{
+						0: {
+							Status:         ShardStatusSteadyState,
+							Term:           1,
+							Ensemble:       []ServerAddress{{Public: "s1", Internal: "s1"}},
+							Int32HashRange: Int32HashRange{Min: 0, Max: 100},
+						},
+					},
+				},
+			},
+			ShardIdGenerator: 2,
+		}
+		update(cs)
+		return cs
+	}
+	updateShard := func(update func(sm *ShardMetadata)) func(cs *ClusterStatus) {
+		return func(cs *ClusterStatus) {
+			sm := cs.Namespaces["ns-1"].Shards[0]
+			update(&sm)
+			cs.Namespaces["ns-1"].Shards[0] = sm
+		}
+	}
+
+	assert.NoError(t, NewClusterStatus().Validate())
+	assert.NoError(t, newStatus(func(*ClusterStatus) {}).Validate())
+	assert.NoError(t, newStatus(updateShard(func(sm *ShardMetadata) { sm.Term = -1 })).Validate())
+
+	for name, update := range map[string]func(cs *ClusterStatus){
+		"negative-shard-id-generator": func(cs *ClusterStatus) { cs.ShardIdGenerator = -1 },
+		"shard-id-not-generated":      func(cs *ClusterStatus) { cs.ShardIdGenerator = 0 },
+		"empty-namespace": func(cs *ClusterStatus) {
+			cs.Namespaces[""] = NamespaceStatus{ReplicationFactor: 1}
+		},
+		"no-replication-factor": func(cs *ClusterStatus) {
+			ns := cs.Namespaces["ns-1"]
+			ns.ReplicationFactor = 0
+			cs.Namespaces["ns-1"] = ns
+		},
+		"duplicated-shard": func(cs *ClusterStatus) {
+			cs.Namespaces["ns-2"] = cs.Namespaces["ns-1"].Clone()
+		},
+		"unknown-status": updateShard(func(sm *ShardMetadata) { sm.Status = 10 }),
+		"invalid-term":   updateShard(func(sm *ShardMetadata) { sm.Term = -2 }),
+		"empty-ensemble": updateShard(func(sm *ShardMetadata) { sm.Ensemble = nil }),
+		"invalid-hash-range": updateShard(func(sm *ShardMetadata) {
+			sm.Int32HashRange = Int32HashRange{Min: 10, Max: 5}
+		}),
+	} {
+		assert.Error(t, newStatus(update).Validate(), name)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+type SplitPhase uint16
+
+const (
+	// SplitPhaseBootstrap The children shards are receiving the data of the
+	// parent shard, which is still serving the requests
+	SplitPhaseBootstrap SplitPhase = iota
+
+	// SplitPhaseCutover The parent shard has stopped serving the requests
+	// and the children shards are taking over
+	SplitPhaseCutover
+)
+
+var splitPhaseToString = map[SplitPhase]string{
+	SplitPhaseBootstrap: "Bootstrap",
+	SplitPhaseCutover:   "Cutover",
+}
+
+var toSplitPhase = map[string]SplitPhase{
+	"Bootstrap": SplitPhaseBootstrap,
+	"Cutover":   SplitPhaseCutover,
+}
+
+func (s SplitPhase) String() string {
+	return splitPhaseToString[s]
+}
+
+// MarshalJSON marshals the enum as a quoted json string.
+func (s SplitPhase) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString(`"`)
+	buffer.WriteString(splitPhaseToString[s]) //nolint:revive
+	buffer.WriteString(`"`)                   //nolint:revive
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalJSON unmarshals a quoted json string to the enum value.
+func (s *SplitPhase) UnmarshalJSON(b []byte) error {
+	var j string
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*s = toSplitPhase[j]
+	return nil
+}
+
+// SplitMetadata is set on both the parent and the children shards, for as
+// long as the split is in progress.
+type SplitMetadata struct {
+	Phase         SplitPhase `json:"phase" yaml:"phase"`
+	ParentShardId int64      `json:"parentShardId" yaml:"parentShardId"`
+	ChildShardIds []int64    `json:"childShardIds" yaml:"childShardIds"`
+}
+
+func (sm *SplitMetadata) Clone() *SplitMetadata {
+	if sm == nil {
+		return nil
+	}
+
+	r := &SplitMetadata{
+		Phase:         sm.Phase,
+		ParentShardId: sm.ParentShardId,
+		ChildShardIds: make([]int64, len(sm.ChildShardIds)),
+	}
+	copy(r.ChildShardIds, sm.ChildShardIds)
+	return r
+}
@@ -32,6 +32,11 @@ func (s ShardStatus) String() string {
 	return toString[s]
 }
 
+func (s ShardStatus) valid() bool {
+	_, ok := toString[s]
+	return ok
+}
+
 var toString = map[ShardStatus]string{
 	ShardStatusUnknown:     "Unknown",
 	ShardStatusSteadyState: "SteadyState",
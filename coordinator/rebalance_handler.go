@@ -0,0 +1,43 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coordinator
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/streamnative/oxia/coordinator/impl"
+)
+
+// newRebalancePlanHandler serves the moves that the coordinator would apply
+// to rebalance the cluster. It's a dry-run: the moves are not applied.
+func newRebalancePlanHandler(coordinator impl.Coordinator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(coordinator.RebalancePlan()); err != nil {
+			slog.Warn(
+				"Failed to write the rebalance plan",
+				slog.Any("error", err),
+			)
+		}
+	})
+}
@@ -64,8 +64,9 @@ timeoutSeconds: 10
 Probe
 */}}
 {{- define "oxia-cluster.readiness-probe" -}}
-exec:
-  command: ["oxia", "health", "--port={{ . }}", "--service=oxia-readiness"]
+grpc:
+  port: {{ . }}
+  service: oxia-readiness
 initialDelaySeconds: 10
 timeoutSeconds: 10
 {{- end }}
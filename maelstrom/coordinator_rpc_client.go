@@ -80,6 +80,24 @@ func (m *maelstromCoordinatorRpcProvider) AddFollower(ctx context.Context, node
 	return res.(*proto.AddFollowerResponse), nil
 }
 
+func (m *maelstromCoordinatorRpcProvider) RemoveFollower(ctx context.Context, node model.ServerAddress, req *proto.RemoveFollowerRequest) (*proto.RemoveFollowerResponse, error) {
+	res, err := m.dispatcher.RpcRequest(ctx, node.Internal, MsgTypeRemoveFollowerRequest, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*proto.RemoveFollowerResponse), nil
+}
+
+func (m *maelstromCoordinatorRpcProvider) TransferLeadership(ctx context.Context, node model.ServerAddress, req *proto.TransferLeadershipRequest) (*proto.TransferLeadershipResponse, error) {
+	res, err := m.dispatcher.RpcRequest(ctx, node.Internal, MsgTypeTransferLeadershipRequest, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*proto.TransferLeadershipResponse), nil
+}
+
 func (m *maelstromCoordinatorRpcProvider) GetStatus(ctx context.Context, node model.ServerAddress, req *proto.GetStatusRequest) (*proto.GetStatusResponse, error) {
 	res, err := m.dispatcher.RpcRequest(ctx, node.Internal, MsgTypeGetStatusRequest, req)
 	if err != nil {
@@ -98,6 +116,23 @@ func (m *maelstromCoordinatorRpcProvider) DeleteShard(ctx context.Context, node
 	return res.(*proto.DeleteShardResponse), nil
 }
 
+func (*maelstromCoordinatorRpcProvider) GetInfo(context.Context, model.ServerAddress) (*proto.GetInfoResponse, error) {
+	// The maelstrom nodes don't have a locality
+	return &proto.GetInfoResponse{}, nil
+}
+
+func (*maelstromCoordinatorRpcProvider) SplitShard(context.Context, model.ServerAddress, *proto.SplitShardRequest) (*proto.SplitShardResponse, error) {
+	return nil, ErrNotImplement
+}
+
+func (*maelstromCoordinatorRpcProvider) SetMaintenance(context.Context, model.ServerAddress, *proto.SetMaintenanceRequest) (*proto.SetMaintenanceResponse, error) {
+	return nil, ErrNotImplement
+}
+
+func (*maelstromCoordinatorRpcProvider) CompactShard(context.Context, model.ServerAddress, *proto.CompactShardRequest) (*proto.CompactShardResponse, error) {
+	return nil, ErrNotImplement
+}
+
 func (m *maelstromCoordinatorRpcProvider) GetHealthClient(node model.ServerAddress) (grpc_health_v1.HealthClient, error) {
 	return &maelstromHealthCheckClient{
 		provider: m,
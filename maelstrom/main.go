@@ -174,7 +174,7 @@ func main() {
 		_, err := impl.NewCoordinator(
 			impl.NewMetadataProviderFile(filepath.Join(dataDir, "cluster-status.json")),
 			func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil,
-			newRpcProvider(dispatcher))
+			newRpcProvider(dispatcher), impl.FailureDetectorOptions{}, impl.RebalanceOptions{})
 		if err != nil {
 			slog.Error(
 				"failed to create coordinator",
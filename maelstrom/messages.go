@@ -43,45 +43,53 @@ const (
 
 	/* Oxia specific messages. */
 
-	MsgTypeNewTermRequest       MsgType = "term-req"
-	MsgTypeNewTermResponse      MsgType = "term-resp"
-	MsgTypeTruncateRequest      MsgType = "truncate-req"
-	MsgTypeTruncateResponse     MsgType = "truncate-resp"
-	MsgTypeBecomeLeaderRequest  MsgType = "leader-req"
-	MsgTypeBecomeLeaderResponse MsgType = "leader-resp"
-	MsgTypeAppend               MsgType = "add-entry"
-	MsgTypeAck                  MsgType = "ack"
-	MsgTypeAddFollowerRequest   MsgType = "add-follower-req"
-	MsgTypeAddFollowerResponse  MsgType = "add-follower-resp"
-	MsgTypeGetStatusRequest     MsgType = "get-status"
-	MsgTypeDeleteShardRequest   MsgType = "delete-shard-req"
-	MsgTypeDeleteShardResponse  MsgType = "delete-shard-resp"
-	MsgTypeGetStatusResponse    MsgType = "status"
-	MsgTypeHealthCheck          MsgType = "health"
-	MsgTypeHealthCheckOk        MsgType = "health-ok"
+	MsgTypeNewTermRequest             MsgType = "term-req"
+	MsgTypeNewTermResponse            MsgType = "term-resp"
+	MsgTypeTruncateRequest            MsgType = "truncate-req"
+	MsgTypeTruncateResponse           MsgType = "truncate-resp"
+	MsgTypeBecomeLeaderRequest        MsgType = "leader-req"
+	MsgTypeBecomeLeaderResponse       MsgType = "leader-resp"
+	MsgTypeAppend                     MsgType = "add-entry"
+	MsgTypeAck                        MsgType = "ack"
+	MsgTypeAddFollowerRequest         MsgType = "add-follower-req"
+	MsgTypeAddFollowerResponse        MsgType = "add-follower-resp"
+	MsgTypeRemoveFollowerRequest      MsgType = "remove-follower-req"
+	MsgTypeRemoveFollowerResponse     MsgType = "remove-follower-resp"
+	MsgTypeTransferLeadershipRequest  MsgType = "transfer-leadership-req"
+	MsgTypeTransferLeadershipResponse MsgType = "transfer-leadership-resp"
+	MsgTypeGetStatusRequest           MsgType = "get-status"
+	MsgTypeDeleteShardRequest         MsgType = "delete-shard-req"
+	MsgTypeDeleteShardResponse        MsgType = "delete-shard-resp"
+	MsgTypeGetStatusResponse          MsgType = "status"
+	MsgTypeHealthCheck                MsgType = "health"
+	MsgTypeHealthCheckOk              MsgType = "health-ok"
 
 	MsgTypeShardAssignmentsResponse MsgType = "shards"
 )
 
 var (
 	oxiaRequests = map[MsgType]bool{
-		MsgTypeNewTermRequest:      true,
-		MsgTypeTruncateRequest:     true,
-		MsgTypeBecomeLeaderRequest: true,
-		MsgTypeAddFollowerRequest:  true,
-		MsgTypeHealthCheck:         true,
-		MsgTypeGetStatusRequest:    true,
-		MsgTypeDeleteShardRequest:  true,
+		MsgTypeNewTermRequest:            true,
+		MsgTypeTruncateRequest:           true,
+		MsgTypeBecomeLeaderRequest:       true,
+		MsgTypeAddFollowerRequest:        true,
+		MsgTypeRemoveFollowerRequest:     true,
+		MsgTypeTransferLeadershipRequest: true,
+		MsgTypeHealthCheck:               true,
+		MsgTypeGetStatusRequest:          true,
+		MsgTypeDeleteShardRequest:        true,
 	}
 
 	oxiaResponses = map[MsgType]bool{
-		MsgTypeNewTermResponse:      true,
-		MsgTypeTruncateResponse:     true,
-		MsgTypeBecomeLeaderResponse: true,
-		MsgTypeAddFollowerResponse:  true,
-		MsgTypeHealthCheckOk:        true,
-		MsgTypeGetStatusResponse:    true,
-		MsgTypeDeleteShardResponse:  true,
+		MsgTypeNewTermResponse:            true,
+		MsgTypeTruncateResponse:           true,
+		MsgTypeBecomeLeaderResponse:       true,
+		MsgTypeAddFollowerResponse:        true,
+		MsgTypeRemoveFollowerResponse:     true,
+		MsgTypeTransferLeadershipResponse: true,
+		MsgTypeHealthCheckOk:              true,
+		MsgTypeGetStatusResponse:          true,
+		MsgTypeDeleteShardResponse:        true,
 	}
 
 	oxiaStreamRequests = map[MsgType]bool{
@@ -220,18 +228,22 @@ var jsonMsgMapping = map[MsgType]any{
 }
 
 var protoMsgMapping = map[MsgType]pb.Message{
-	MsgTypeNewTermRequest:       &proto.NewTermRequest{},
-	MsgTypeNewTermResponse:      &proto.NewTermResponse{},
-	MsgTypeTruncateRequest:      &proto.TruncateRequest{},
-	MsgTypeTruncateResponse:     &proto.TruncateResponse{},
-	MsgTypeBecomeLeaderRequest:  &proto.BecomeLeaderRequest{},
-	MsgTypeBecomeLeaderResponse: &proto.BecomeLeaderResponse{},
-	MsgTypeAppend:               &proto.Append{},
-	MsgTypeAck:                  &proto.Ack{},
-	MsgTypeAddFollowerRequest:   &proto.AddFollowerRequest{},
-	MsgTypeAddFollowerResponse:  &proto.AddFollowerResponse{},
-	MsgTypeGetStatusRequest:     &proto.GetStatusRequest{},
-	MsgTypeGetStatusResponse:    &proto.GetStatusResponse{},
+	MsgTypeNewTermRequest:             &proto.NewTermRequest{},
+	MsgTypeNewTermResponse:            &proto.NewTermResponse{},
+	MsgTypeTruncateRequest:            &proto.TruncateRequest{},
+	MsgTypeTruncateResponse:           &proto.TruncateResponse{},
+	MsgTypeBecomeLeaderRequest:        &proto.BecomeLeaderRequest{},
+	MsgTypeBecomeLeaderResponse:       &proto.BecomeLeaderResponse{},
+	MsgTypeAppend:                     &proto.Append{},
+	MsgTypeAck:                        &proto.Ack{},
+	MsgTypeAddFollowerRequest:         &proto.AddFollowerRequest{},
+	MsgTypeAddFollowerResponse:        &proto.AddFollowerResponse{},
+	MsgTypeRemoveFollowerRequest:      &proto.RemoveFollowerRequest{},
+	MsgTypeRemoveFollowerResponse:     &proto.RemoveFollowerResponse{},
+	MsgTypeTransferLeadershipRequest:  &proto.TransferLeadershipRequest{},
+	MsgTypeTransferLeadershipResponse: &proto.TransferLeadershipResponse{},
+	MsgTypeGetStatusRequest:           &proto.GetStatusRequest{},
+	MsgTypeGetStatusResponse:          &proto.GetStatusResponse{},
 
 	MsgTypeShardAssignmentsResponse: &proto.ShardAssignments{},
 }
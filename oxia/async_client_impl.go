@@ -40,10 +40,11 @@ type clientImpl struct {
 	options           clientOptions
 	shardManager      internal.ShardManager
 	writeBatchManager *batch.Manager
-	readBatchManager  *batch.Manager
+	readBatchManagers map[proto.ReadConsistency]*batch.Manager
 	executor          internal.Executor
 	sessions          *sessions
 	notifications     []*notifications
+	observedOffsets   *observedOffsets
 
 	clientPool common.ClientPool
 	ctx        context.Context
@@ -66,21 +67,23 @@ func NewAsyncClient(serviceAddress string, opts ...ClientOption) (AsyncClient, e
 	}
 
 	clientPool := common.NewClientPool(options.tls, options.authentication)
+	clientMetrics := metrics.NewMetrics(options.meterProvider)
 
 	shardManager, err := internal.NewShardManager(internal.NewShardStrategy(), clientPool, serviceAddress,
-		options.namespace, options.requestTimeout)
+		options.namespace, options.requestTimeout, clientMetrics)
 	if err != nil {
 		return nil, err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	executor := internal.NewExecutor(ctx, options.namespace, clientPool, shardManager, options.serviceAddress)
+	executor := internal.NewExecutor(ctx, options.namespace, clientPool, shardManager, options.serviceAddress,
+		options.streamTimeout)
 	batcherFactory := batch.NewBatcherFactory(
 		executor,
 		options.namespace,
 		options.batchLinger,
 		options.maxRequestsPerBatch,
-		metrics.NewMetrics(options.meterProvider),
+		clientMetrics,
 		options.requestTimeout)
 	c := &clientImpl{
 		options:      options,
@@ -89,8 +92,13 @@ func NewAsyncClient(serviceAddress string, opts ...ClientOption) (AsyncClient, e
 		writeBatchManager: batch.NewManager(func(shard *int64) commonbatch.Batcher {
 			return batcherFactory.NewWriteBatcher(shard, options.maxBatchSize)
 		}),
-		readBatchManager: batch.NewManager(batcherFactory.NewReadBatcher),
-		executor:         executor,
+		executor:        executor,
+		observedOffsets: newObservedOffsets(),
+	}
+	c.readBatchManagers = map[proto.ReadConsistency]*batch.Manager{
+		proto.ReadConsistency_LINEARIZABLE:      newReadBatchManager(batcherFactory, proto.ReadConsistency_LINEARIZABLE, nil),
+		proto.ReadConsistency_BOUNDED_STALENESS: newReadBatchManager(batcherFactory, proto.ReadConsistency_BOUNDED_STALENESS, nil),
+		proto.ReadConsistency_SESSION:           newReadBatchManager(batcherFactory, proto.ReadConsistency_SESSION, c.observedOffsets.get),
 	}
 
 	c.ctx, c.cancel = ctx, cancel
@@ -98,13 +106,25 @@ func NewAsyncClient(serviceAddress string, opts ...ClientOption) (AsyncClient, e
 	return c, nil
 }
 
+func newReadBatchManager(batcherFactory *batch.BatcherFactory, consistency proto.ReadConsistency,
+	minOffset func(shardId int64) *int64) *batch.Manager {
+	return batch.NewManager(func(shard *int64) commonbatch.Batcher {
+		return batcherFactory.NewReadBatcher(shard, consistency, minOffset)
+	})
+}
+
 func (c *clientImpl) Close() error {
+	// The pending writes are flushed before the sessions of the ephemeral
+	// records are closed
 	err := multierr.Combine(
-		c.sessions.Close(),
 		c.writeBatchManager.Close(),
-		c.readBatchManager.Close(),
-		c.clientPool.Close(),
+		c.sessions.Close(),
 	)
+	for _, readBatchManager := range c.readBatchManagers {
+		err = multierr.Append(err, readBatchManager.Close())
+	}
+	err = multierr.Append(err, c.shardManager.Close())
+	err = multierr.Append(err, c.clientPool.Close())
 	c.cancel()
 
 	err = multierr.Append(err, c.closeNotifications())
@@ -114,29 +134,50 @@ func (c *clientImpl) Close() error {
 func (c *clientImpl) Put(key string, value []byte, options ...PutOption) <-chan PutResult {
 	ch := make(chan PutResult, 1)
 
-	callback := func(response *proto.PutResponse, err error) {
-		if err != nil {
-			ch <- PutResult{Err: err}
-		} else {
-			ch <- toPutResult(key, response)
-		}
-		close(ch)
-	}
-
 	opts, err := newPutOptions(options)
 	if err != nil {
-		callback(nil, err)
+		ch <- PutResult{Err: err}
+		close(ch)
 		return ch
 	}
 
+	c.doPut(key, value, opts, ch)
+	return ch
+}
+
+func (c *clientImpl) doPut(key string, value []byte, opts *putOptions, ch chan PutResult) {
+	fail := func(err error) {
+		ch <- PutResult{Err: err}
+		close(ch)
+	}
+
+	callback := func(response *proto.PutResponse, err error) {
+		switch {
+		case c.retryOnShardSplit(err, func() { c.doPut(key, value, opts, ch) }, fail):
+		case err != nil:
+			fail(err)
+		default:
+			ch <- toPutResult(key, response)
+			close(ch)
+		}
+	}
+
 	shardId := c.getShardForKey(key, opts)
 	putCall := model.PutCall{
 		Key:                key,
 		Value:              value,
 		ExpectedVersionId:  opts.expectedVersion,
+		ExpectedValue:      opts.expectedValue,
 		SequenceKeysDeltas: opts.sequenceKeysDeltas,
 		PartitionKey:       opts.partitionKey,
-		Callback:           callback,
+		ExpireAfterMs:      opts.expireAfterMs(),
+		Context:            opts.ctx,
+		Callback: func(response *proto.PutResponse, err error) {
+			if err == nil {
+				c.observedOffsets.observe(shardId, response.Version)
+			}
+			callback(response, err)
+		},
 	}
 	if opts.ephemeral {
 		putCall.ClientIdentity = &c.options.identity
@@ -146,41 +187,72 @@ func (c *clientImpl) Put(key string, value []byte, options ...PutOption) <-chan
 				return
 			}
 			putCall.SessionId = &sessionId
+			putCallback := putCall.Callback
+			putCall.Callback = func(response *proto.PutResponse, err error) {
+				if err == nil && response.Status == proto.Status_SESSION_DOES_NOT_EXIST {
+					// The session has expired before the keep-alive noticed
+					c.sessions.sessionLost(shardId, sessionId)
+				}
+				putCallback(response, err)
+			}
 			c.writeBatchManager.Get(shardId).Add(putCall)
 		})
 	} else {
 		c.writeBatchManager.Get(shardId).Add(putCall)
 	}
-	return ch
 }
 
 func (c *clientImpl) Delete(key string, options ...DeleteOption) <-chan error {
 	ch := make(chan error, 1)
+	c.doDelete(key, newDeleteOptions(options), ch)
+	return ch
+}
+
+func (c *clientImpl) doDelete(key string, opts *deleteOptions, ch chan error) {
+	fail := func(err error) {
+		ch <- err
+		close(ch)
+	}
+
 	callback := func(response *proto.DeleteResponse, err error) {
-		if err != nil {
-			ch <- err
-		} else {
-			ch <- toDeleteResult(response)
+		switch {
+		case c.retryOnShardSplit(err, func() { c.doDelete(key, opts, ch) }, fail):
+		case err != nil:
+			fail(err)
+		default:
+			fail(toDeleteResult(response))
 		}
-		close(ch)
 	}
-	opts := newDeleteOptions(options)
 	shardId := c.getShardForKey(key, opts)
 	c.writeBatchManager.Get(shardId).Add(model.DeleteCall{
 		Key:               key,
 		ExpectedVersionId: opts.expectedVersion,
+		ExpectedValue:     opts.expectedValue,
+		Context:           opts.ctx,
 		Callback:          callback,
 	})
-	return ch
 }
 
 func (c *clientImpl) DeleteRange(minKeyInclusive string, maxKeyExclusive string, options ...DeleteRangeOption) <-chan error {
 	ch := make(chan error, 1)
-	opts := newDeleteRangeOptions(options)
+	c.doDeleteRange(minKeyInclusive, maxKeyExclusive, newDeleteRangeOptions(options), ch)
+	return ch
+}
+
+func (c *clientImpl) doDeleteRange(minKeyInclusive string, maxKeyExclusive string, opts *deleteRangeOptions, ch chan error) {
+	fail := func(err error) {
+		ch <- err
+		close(ch)
+	}
+
+	// Deleting a range again is harmless, so it can be retried on all the
+	// shards, when any of them was split
+	retry := func() { c.doDeleteRange(minKeyInclusive, maxKeyExclusive, opts, ch) }
+
 	if opts.partitionKey != nil {
 		shardId := c.getShardForKey("", opts)
-		c.doSingleShardDeleteRange(shardId, minKeyInclusive, maxKeyExclusive, ch)
-		return ch
+		c.doSingleShardDeleteRange(shardId, minKeyInclusive, maxKeyExclusive, opts, retry, fail)
+		return
 	}
 
 	// If there is no partition key, we will make the request to delete-range on all the shards
@@ -188,10 +260,10 @@ func (c *clientImpl) DeleteRange(minKeyInclusive string, maxKeyExclusive string,
 	wg := common.NewWaitGroup(len(shardIDs))
 
 	for _, shardId := range shardIDs {
-		// chInner := make(chan error, 1)
 		c.writeBatchManager.Get(shardId).Add(model.DeleteRangeCall{
 			MinKeyInclusive: minKeyInclusive,
 			MaxKeyExclusive: maxKeyExclusive,
+			Context:         opts.ctx,
 			Callback: func(response *proto.DeleteRangeResponse, err error) {
 				if err != nil {
 					wg.Fail(err)
@@ -208,36 +280,39 @@ func (c *clientImpl) DeleteRange(minKeyInclusive string, maxKeyExclusive string,
 		})
 	}
 	go func() {
-		ch <- wg.Wait(c.ctx)
-		close(ch)
+		err := wg.Wait(c.ctx)
+		if !c.retryOnShardSplit(err, retry, fail) {
+			fail(err)
+		}
 	}()
-	return ch
 }
 
-func (c *clientImpl) doSingleShardDeleteRange(shardId int64, minKeyInclusive string, maxKeyExclusive string, ch chan error) {
+func (c *clientImpl) doSingleShardDeleteRange(shardId int64, minKeyInclusive string, maxKeyExclusive string,
+	opts *deleteRangeOptions, retry func(), fail func(error)) {
 	c.writeBatchManager.Get(shardId).Add(model.DeleteRangeCall{
 		MinKeyInclusive: minKeyInclusive,
 		MaxKeyExclusive: maxKeyExclusive,
+		Context:         opts.ctx,
 		Callback: func(response *proto.DeleteRangeResponse, err error) {
-			if err != nil {
-				ch <- err
-			} else {
-				ch <- toDeleteRangeResult(response)
+			switch {
+			case c.retryOnShardSplit(err, retry, fail):
+			case err != nil:
+				fail(err)
+			default:
+				fail(toDeleteRangeResult(response))
 			}
-
-			close(ch)
 		},
 	})
 }
 
 func (c *clientImpl) Get(key string, options ...GetOption) <-chan GetResult {
-	ch := make(chan GetResult)
+	ch := make(chan GetResult, 1)
 
 	opts := newGetOptions(options)
 	if opts.comparisonType == proto.KeyComparisonType_EQUAL || opts.partitionKey != nil {
 		c.doSingleShardGet(key, opts, ch)
 	} else {
-		c.doFloorCeilingGet(key, opts.comparisonType, ch)
+		c.doFloorCeilingGet(key, opts, ch)
 	}
 
 	return ch
@@ -245,45 +320,134 @@ func (c *clientImpl) Get(key string, options ...GetOption) <-chan GetResult {
 
 func (c *clientImpl) doSingleShardGet(key string, opts *getOptions, ch chan GetResult) {
 	shardId := c.getShardForKey(key, opts)
-	c.readBatchManager.Get(shardId).Add(model.GetCall{
+	c.readBatchManagers[opts.consistency].Get(shardId).Add(model.GetCall{
 		Key:            key,
 		ComparisonType: opts.comparisonType,
+		ExcludeValue:   opts.excludeValue,
+		MaxValueSize:   opts.maxValueSize,
+		Context:        opts.ctx,
 		Callback: func(response *proto.GetResponse, err error) {
+			if c.retryOnShardSplit(err, func() { c.doSingleShardGet(key, opts, ch) }, func(err error) {
+				ch <- toGetResult(nil, key, err)
+				close(ch)
+			}) {
+				return
+			}
+
+			if err == nil {
+				c.observedOffsets.observe(shardId, response.Version)
+			}
 			ch <- toGetResult(response, key, err)
 			close(ch)
 		},
 	})
 }
 
+func (c *clientImpl) GetMany(keys []string, options ...GetOption) <-chan []GetResult {
+	ch := make(chan []GetResult, 1)
+	results := make([]GetResult, len(keys))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(keys))
+	go func() {
+		wg.Wait()
+		ch <- results
+		close(ch)
+	}()
+
+	opts := newGetOptions(options)
+	if opts.comparisonType != proto.KeyComparisonType_EQUAL && opts.partitionKey == nil {
+		// Each of the keys has to be checked on all the shards
+		for i, key := range keys {
+			go func() {
+				results[i] = <-c.Get(key, options...)
+				wg.Done()
+			}()
+		}
+		return ch
+	}
+
+	getsByShard := make(map[int64]model.GetCalls)
+	for i, key := range keys {
+		shardId := c.getShardForKey(key, opts)
+		getsByShard[shardId] = append(getsByShard[shardId], model.GetCall{
+			Key:            key,
+			ComparisonType: opts.comparisonType,
+			ExcludeValue:   opts.excludeValue,
+			MaxValueSize:   opts.maxValueSize,
+			Context:        opts.ctx,
+			Callback: func(response *proto.GetResponse, err error) {
+				if c.retryOnShardSplit(err, func() {
+					single := make(chan GetResult, 1)
+					c.doSingleShardGet(key, opts, single)
+					results[i] = <-single
+					wg.Done()
+				}, func(err error) {
+					results[i] = toGetResult(nil, key, err)
+					wg.Done()
+				}) {
+					return
+				}
+
+				if err == nil {
+					c.observedOffsets.observe(shardId, response.Version)
+				}
+				results[i] = toGetResult(response, key, err)
+				wg.Done()
+			},
+		})
+	}
+
+	for shardId, gets := range getsByShard {
+		c.readBatchManagers[opts.consistency].Get(shardId).Add(gets)
+	}
+	return ch
+}
+
 // The keys might get hashed to multiple shards, so we have to check on all shards and then compare the results.
-func (c *clientImpl) doFloorCeilingGet(key string, comparisonType proto.KeyComparisonType, ch chan GetResult) {
+func (c *clientImpl) doFloorCeilingGet(key string, opts *getOptions, ch chan GetResult) {
 	m := sync.Mutex{}
 	var results []*proto.GetResponse
 	shards := c.shardManager.GetAll()
 	counter := len(shards)
 
 	for _, shardId := range shards {
-		c.readBatchManager.Get(shardId).Add(model.GetCall{
+		c.readBatchManagers[opts.consistency].Get(shardId).Add(model.GetCall{
 			Key:            key,
-			ComparisonType: comparisonType,
+			ComparisonType: opts.comparisonType,
+			ExcludeValue:   opts.excludeValue,
+			MaxValueSize:   opts.maxValueSize,
+			Context:        opts.ctx,
 			Callback: func(response *proto.GetResponse, err error) {
 				m.Lock()
 				defer m.Unlock()
 
+				if counter <= 0 {
+					// The get has already failed on another shard
+					return
+				}
+
 				if err != nil {
-					ch <- toGetResult(nil, key, err)
-					close(ch)
 					counter = 0
+					fail := func(err error) {
+						ch <- toGetResult(nil, key, err)
+						close(ch)
+					}
+					if !c.retryOnShardSplit(err, func() { c.doFloorCeilingGet(key, opts, ch) }, fail) {
+						fail(err)
+					}
+					return
 				}
 
-				if response.Status == proto.Status_OK {
+				if response.Status == proto.Status_OK || response.Status == proto.Status_VALUE_TOO_LARGE {
+					c.observedOffsets.observe(shardId, response.Version)
 					results = append(results, response)
 				}
 
 				counter--
 				if counter == 0 {
 					// We have responses from all the shards
-					processAllGetResponses(key, results, comparisonType, ch)
+					processAllGetResponses(key, results, opts.comparisonType, ch)
 				}
 			},
 		})
@@ -319,16 +483,20 @@ func processAllGetResponses(key string, results []*proto.GetResponse, comparison
 	close(ch)
 }
 
-func (c *clientImpl) listFromShard(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, shardId int64, ch chan<- ListResult) {
+func (c *clientImpl) listFromShard(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, shardId int64,
+	opts *listOptions, ch chan<- ListResult) {
 	request := &proto.ListRequest{
 		Shard:          &shardId,
 		StartInclusive: minKeyInclusive,
 		EndExclusive:   maxKeyExclusive,
+		Limit:          opts.limit,
+		Consistency:    opts.consistency,
+		MinOffset:      c.minOffset(shardId, opts.consistency),
 	}
 
 	client, err := c.executor.ExecuteList(ctx, request)
 	if err != nil {
-		ch <- ListResult{Err: err}
+		sendResult(ctx, ch, ListResult{Err: c.toShardSplitError(err)})
 		return
 	}
 
@@ -339,11 +507,13 @@ func (c *clientImpl) listFromShard(ctx context.Context, minKeyInclusive string,
 				return
 			}
 
-			ch <- ListResult{Err: err}
+			sendResult(ctx, ch, ListResult{Err: c.toShardSplitError(err)})
 			return
 		}
 
-		ch <- ListResult{Keys: response.Keys}
+		if !sendResult(ctx, ch, ListResult{Keys: response.Keys}) {
+			return
+		}
 	}
 }
 
@@ -355,47 +525,183 @@ func (c *clientImpl) List(ctx context.Context, minKeyInclusive string, maxKeyExc
 		// If the partition key is specified, we only need to make the request to one shard
 		shardId := c.getShardForKey("", opts)
 		go func() {
-			c.listFromShard(ctx, minKeyInclusive, maxKeyExclusive, shardId, ch)
+			c.listFromShard(ctx, minKeyInclusive, maxKeyExclusive, shardId, opts, ch)
 			close(ch)
 		}()
+		return ch
+	}
+
+	// Do the list on all shards and aggregate the responses. The shards are
+	// stopped as soon as the results are not needed anymore.
+	ctx, cancel := context.WithCancel(ctx)
+	shardIDs := c.shardManager.GetAll()
+	channels := make([]chan ListResult, len(shardIDs))
+	for i, shardId := range shardIDs {
+		shardIdPtr := shardId
+		shardCh := make(chan ListResult)
+		channels[i] = shardCh
+		go func() {
+			defer close(shardCh)
+
+			c.listFromShard(ctx, minKeyInclusive, maxKeyExclusive, shardIdPtr, opts, shardCh)
+		}()
+	}
+
+	if opts.sorted {
+		go aggregateAndSortListAcrossShards(ctx, cancel, channels, opts.limit, ch)
 	} else {
-		// Do the list on all shards and aggregate the responses
-		shardIDs := c.shardManager.GetAll()
+		go aggregateListAcrossShards(ctx, cancel, channels, opts.limit, ch)
+	}
+	return ch
+}
 
-		wg := common.NewWaitGroup(len(shardIDs))
-		for _, shardId := range shardIDs {
-			shardIdPtr := shardId
-			go func() {
-				defer wg.Done()
+// Forward the keys in the order they are received from the shards. Each shard
+// returns up to limit keys, so only the first ones received are forwarded.
+func aggregateListAcrossShards(ctx context.Context, cancel context.CancelFunc, channels []chan ListResult,
+	limit *uint64, out chan<- ListResult) {
+	defer close(out)
 
-				c.listFromShard(ctx, minKeyInclusive, maxKeyExclusive, shardIdPtr, ch)
-			}()
+	in := make(chan ListResult)
+	wg := sync.WaitGroup{}
+	wg.Add(len(channels))
+	for _, ch := range channels {
+		go func(ch chan ListResult) {
+			defer wg.Done()
+			for r := range ch {
+				in <- r
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(in)
+	}()
+
+	var count uint64
+	for r := range in {
+		if ctx.Err() != nil {
+			// Drain the rest, to let the shards go-routines complete
+			continue
 		}
 
-		go func() {
-			_ = wg.Wait(ctx)
-			close(ch)
-		}()
+		if r.Err == nil && limit != nil {
+			if count >= *limit {
+				continue
+			}
+			if uint64(len(r.Keys)) > *limit-count {
+				r.Keys = r.Keys[:*limit-count]
+			}
+			count += uint64(len(r.Keys))
+		}
+
+		if sendResult(ctx, out, r) && limit != nil && count >= *limit {
+			cancel()
+		}
 	}
+	cancel()
+}
 
-	return ch
+// Merge the keys received from the shards, which are sorted within each
+// shard, by always picking the lowest one. Only one page of keys per shard is
+// held at any time.
+func aggregateAndSortListAcrossShards(ctx context.Context, cancel context.CancelFunc, channels []chan ListResult,
+	limit *uint64, out chan<- ListResult) {
+	defer close(out)
+
+	h := &KeysHeap{}
+	var keys []string
+
+	// Read the next page of keys of the shard. A failure stops the list,
+	// since the following keys could not be sorted anymore.
+	next := func(ch chan ListResult) bool {
+		for r := range ch {
+			if r.Err != nil {
+				sendResult(ctx, out, r)
+				return false
+			}
+			if len(r.Keys) > 0 {
+				heap.Push(h, &KeysAndChannel{r.Keys, ch})
+				return true
+			}
+		}
+		return true
+	}
+
+	ok := true
+	for _, ch := range channels {
+		if ok = next(ch); !ok {
+			break
+		}
+	}
+
+	var count uint64
+	for ok && h.Len() > 0 && (limit == nil || count < *limit) {
+		kc := (*h)[0]
+		keys = append(keys, kc.keys[0])
+		count++
+
+		if kc.keys = kc.keys[1:]; len(kc.keys) > 0 {
+			heap.Fix(h, 0)
+			continue
+		}
+
+		// Send the keys merged so far, before waiting for the next page
+		heap.Pop(h)
+		if len(keys) > 0 {
+			ok = sendResult(ctx, out, ListResult{Keys: keys})
+			keys = nil
+		}
+		ok = ok && next(kc.ch)
+	}
+
+	if ok && len(keys) > 0 {
+		sendResult(ctx, out, ListResult{Keys: keys})
+	}
+
+	// Stop the shards that still have keys and let their go-routines complete
+	cancel()
+	for _, ch := range channels {
+		for range ch { //nolint:revive
+		}
+	}
+}
+
+// Send a result, unless the operation is canceled.
+func sendResult[T any](ctx context.Context, ch chan<- T, r T) bool {
+	select {
+	case ch <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *clientImpl) minOffset(shardId int64, consistency proto.ReadConsistency) *int64 {
+	if consistency != proto.ReadConsistency_SESSION {
+		return nil
+	}
+	return c.observedOffsets.get(shardId)
 }
 
-func (c *clientImpl) rangeScanFromShard(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, shardId int64, ch chan<- GetResult) {
+func (c *clientImpl) rangeScanFromShard(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, shardId int64,
+	opts *rangeScanOptions, ch chan<- GetResult) {
+	defer close(ch)
+
 	request := &proto.RangeScanRequest{
 		Shard:          &shardId,
 		StartInclusive: minKeyInclusive,
 		EndExclusive:   maxKeyExclusive,
+		Limit:          opts.limit,
+		Consistency:    opts.consistency,
+		MinOffset:      c.minOffset(shardId, opts.consistency),
 	}
 
 	client, err := c.executor.ExecuteRangeScan(ctx, request)
 	if err != nil {
-		ch <- GetResult{Err: err}
+		sendResult(ctx, ch, GetResult{Err: c.toShardSplitError(err)})
 		return
 	}
 
-	defer close(ch)
-
 	for {
 		response, err := client.Recv()
 		if err != nil {
@@ -403,12 +709,14 @@ func (c *clientImpl) rangeScanFromShard(ctx context.Context, minKeyInclusive str
 				return
 			}
 
-			ch <- GetResult{Err: err}
+			sendResult(ctx, ch, GetResult{Err: c.toShardSplitError(err)})
 			return
 		}
 
 		for _, record := range response.Records {
-			ch <- toGetResult(record, "", nil)
+			if !sendResult(ctx, ch, toGetResult(record, "", nil)) {
+				return
+			}
 		}
 	}
 }
@@ -421,10 +729,12 @@ func (c *clientImpl) RangeScan(ctx context.Context, minKeyInclusive string, maxK
 		// If the partition key is specified, we only need to make the request to one shard
 		shardId := c.getShardForKey("", opts)
 		go func() {
-			c.rangeScanFromShard(ctx, minKeyInclusive, maxKeyExclusive, shardId, outCh)
+			c.rangeScanFromShard(ctx, minKeyInclusive, maxKeyExclusive, shardId, opts, outCh)
 		}()
 	} else {
-		// Do the list on all shards and aggregate the responses
+		// Do the range scan on all shards and aggregate the responses. The
+		// shards are stopped as soon as the results are not needed anymore.
+		ctx, cancel := context.WithCancel(ctx)
 		shardIDs := c.shardManager.GetAll()
 		channels := make([]chan GetResult, len(shardIDs))
 
@@ -433,11 +743,11 @@ func (c *clientImpl) RangeScan(ctx context.Context, minKeyInclusive string, maxK
 			ch := make(chan GetResult)
 			channels[i] = ch
 			go func() {
-				c.rangeScanFromShard(ctx, minKeyInclusive, maxKeyExclusive, shardIdPtr, ch)
+				c.rangeScanFromShard(ctx, minKeyInclusive, maxKeyExclusive, shardIdPtr, opts, ch)
 			}()
 		}
 
-		go aggregateAndSortRangeScanAcrossShards(channels, outCh)
+		go aggregateAndSortRangeScanAcrossShards(ctx, cancel, channels, opts.limit, outCh)
 	}
 
 	return outCh
@@ -445,10 +755,15 @@ func (c *clientImpl) RangeScan(ctx context.Context, minKeyInclusive string, maxK
 
 // We do range scan on all the shards, and we need to always pick the lowest key
 // across all the shards.
-func aggregateAndSortRangeScanAcrossShards(channels []chan GetResult, outCh chan GetResult) {
+func aggregateAndSortRangeScanAcrossShards(ctx context.Context, cancel context.CancelFunc, channels []chan GetResult,
+	limit *uint64, outCh chan GetResult) {
+	defer close(outCh)
+
 	h := &ResultHeap{}
 	heap.Init(h)
 
+	var count uint64
+
 	// First make sure we have 1 key from each channel
 	for _, ch := range channels {
 		if gr, ok := <-ch; ok {
@@ -458,19 +773,17 @@ func aggregateAndSortRangeScanAcrossShards(channels []chan GetResult, outCh chan
 
 	// Now that we have something from each channel, iterate by picking the
 	// result with the lowest key and then reading again from that same
-	// channel
-	for h.Len() > 0 {
+	// channel. Each shard returns up to limit records.
+	for h.Len() > 0 && (limit == nil || count < *limit) {
 		r, ok := heap.Pop(h).(*ResultAndChannel)
 		if !ok {
 			panic("failed to cast")
 		}
 
-		outCh <- r.gr
-
-		if r.gr.Err != nil {
-			close(outCh)
-			return
+		if !sendResult(ctx, outCh, r.gr) || r.gr.Err != nil {
+			break
 		}
+		count++
 
 		// read again from same channel
 		if gr, ok := <-r.ch; ok {
@@ -478,7 +791,13 @@ func aggregateAndSortRangeScanAcrossShards(channels []chan GetResult, outCh chan
 		}
 	}
 
-	close(outCh)
+	// Stop the shards that still have records and let their go-routines
+	// complete
+	cancel()
+	for _, ch := range channels {
+		for range ch { //nolint:revive
+		}
+	}
 }
 
 func (c *clientImpl) closeNotifications() error {
@@ -501,8 +820,9 @@ func (c *clientImpl) getShardForKey(key string, options baseOptionsIf) int64 {
 	return c.shardManager.Get(key)
 }
 
-func (c *clientImpl) GetNotifications() (Notifications, error) {
-	nm, err := newNotifications(c.ctx, c.options, c.clientPool, c.shardManager)
+func (c *clientImpl) GetNotifications(options ...NotificationsOption) (Notifications, error) {
+	opts := newNotificationsOptions(options)
+	nm, err := newNotifications(c.ctx, c.options, opts, c.clientPool, c.shardManager)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to create notification stream")
 	}
@@ -18,12 +18,18 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/server"
@@ -118,6 +124,8 @@ func TestSyncClientImpl_Notifications(t *testing.T) {
 	assert.Equal(t, KeyModified, n.Type)
 	assert.Equal(t, "/a", n.Key)
 	assert.Equal(t, s2.VersionId, n.VersionId)
+	assert.Equal(t, s2.CreatedTimestamp, n.CreatedTimestamp)
+	assert.Equal(t, s2.ModifiedTimestamp, n.ModifiedTimestamp)
 
 	_, s3, _ := client.Put(ctx, "/b", []byte("0"))
 	assert.NoError(t, client.Delete(ctx, "/a"))
@@ -131,6 +139,8 @@ func TestSyncClientImpl_Notifications(t *testing.T) {
 	assert.Equal(t, KeyDeleted, n.Type)
 	assert.Equal(t, "/a", n.Key)
 	assert.EqualValues(t, -1, n.VersionId)
+	assert.Zero(t, n.CreatedTimestamp)
+	assert.NotZero(t, n.ModifiedTimestamp)
 
 	// Create a 2nd notifications channel
 	// This will only receive new updates
@@ -260,6 +270,50 @@ func TestAsyncClientImpl_Sessions(t *testing.T) {
 	assert.NoError(t, standaloneServer.Close())
 }
 
+func TestAsyncClientImpl_SessionReconnect(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewSyncClient(serviceAddress, WithSessionTimeout(5*time.Second))
+	assert.NoError(t, err)
+
+	_, _, err = client.Put(context.Background(), "/x", []byte("x"), Ephemeral())
+	assert.NoError(t, err)
+
+	// Restart the server on the same address, which takes over the session
+	// from the persisted state
+	assert.NoError(t, standaloneServer.Close())
+	config.PublicServiceAddr = serviceAddress
+	standaloneServer, err = server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	// The client reconnects and keeps the session alive past its timeout
+	time.Sleep(7 * time.Second)
+	_, value, version, err := client.Get(context.Background(), "/x")
+	assert.NoError(t, err)
+	assert.Equal(t, "x", string(value))
+	assert.True(t, version.Ephemeral)
+
+	_, version, err = client.Put(context.Background(), "/y", []byte("y"), Ephemeral())
+	assert.NoError(t, err)
+	assert.True(t, version.Ephemeral)
+
+	assert.NoError(t, client.Close())
+
+	client, err = NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, _, _, errX := client.Get(context.Background(), "/x")
+		_, _, _, errY := client.Get(context.Background(), "/y")
+		return errors.Is(errX, ErrKeyNotFound) && errors.Is(errY, ErrKeyNotFound)
+	}, 10*time.Second, 500*time.Millisecond)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
 func TestAsyncClientImpl_OverrideEphemeral(t *testing.T) {
 	client, err := NewSyncClient(serviceAddress,
 		WithSessionTimeout(5*time.Second),
@@ -636,10 +690,9 @@ func TestSyncClientImpl_PartitionRouting(t *testing.T) {
 	assert.Equal(t, "b", key)
 	assert.Equal(t, "1", string(value))
 
-	key, value, _, err = client.Get(ctx, "a", ComparisonHigher(), PartitionKey("wrong-partition-key"))
-	assert.NoError(t, err)
-	assert.NotEqual(t, "b", key)
-	assert.NotEqual(t, "1", string(value))
+	// The shard of the wrong partition key has no records
+	_, _, _, err = client.Get(ctx, "a", ComparisonHigher(), PartitionKey("wrong-partition-key"))
+	assert.ErrorIs(t, err, ErrKeyNotFound)
 
 	// Delete with wrong partition key would fail to delete all keys
 	err = client.DeleteRange(ctx, "c", "e", PartitionKey("wrong-partition-key"))
@@ -823,6 +876,301 @@ func TestSyncClientImpl_RangeScanOnPartition(t *testing.T) {
 	assert.NoError(t, standaloneServer.Close())
 }
 
+func TestSyncClientImpl_ScanWithLimit(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	// Test with multiple shards to ensure correctness across shards
+	config.NumShards = 10
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		_, _, err = client.Put(ctx, key, []byte(key))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, client.Delete(ctx, "c"))
+
+	// The records are sorted across the shards, before applying the limit
+	var keys []string
+	for gr := range client.RangeScan(ctx, "b", "g", Limit(3)) {
+		assert.NoError(t, gr.Err)
+		keys = append(keys, gr.Key)
+	}
+	assert.Equal(t, []string{"b", "d", "e"}, keys)
+
+	keys, err = client.List(ctx, "a", "g", Limit(2))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	keys, err = client.List(ctx, "a", "a", Limit(2))
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// The limit spans over multiple response pages
+	asyncClient, err := NewAsyncClient(serviceAddress)
+	assert.NoError(t, err)
+	var results []<-chan PutResult
+	for i := 0; i < 1500; i++ {
+		results = append(results, asyncClient.Put(fmt.Sprintf("/x/%04d", i), []byte{0}, PartitionKey("x")))
+	}
+	for _, r := range results {
+		assert.NoError(t, (<-r).Err)
+	}
+	assert.NoError(t, asyncClient.Close())
+
+	count := 0
+	for gr := range client.RangeScan(ctx, "/x/", "/x//", PartitionKey("x"), Limit(1200)) {
+		assert.NoError(t, gr.Err)
+		assert.Equal(t, fmt.Sprintf("/x/%04d", count), gr.Key)
+		count++
+	}
+	assert.Equal(t, 1200, count)
+
+	keys, err = client.List(ctx, "/x/", "/x//", PartitionKey("x"), Limit(1200), ConsistencyBoundedStaleness())
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1200)
+	assert.Equal(t, "/x/1199", keys[1199])
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestAsyncClientImpl_ListAcrossShards(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	config.NumShards = 4
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress)
+	assert.NoError(t, err)
+
+	// The keys with the prefix are spread across all the shards
+	var expected []string
+	var results []<-chan PutResult
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("/p/%04d", i)
+		expected = append(expected, key)
+		results = append(results, client.Put(key, []byte{0}))
+	}
+	results = append(results, client.Put("/q/0000", []byte{0}))
+	for _, r := range results {
+		assert.NoError(t, (<-r).Err)
+	}
+
+	list := func(minKeyInclusive string, maxKeyExclusive string, options ...ListOption) []string {
+		keys := []string{}
+		for r := range client.List(context.Background(), minKeyInclusive, maxKeyExclusive, options...) {
+			assert.NoError(t, r.Err)
+			keys = append(keys, r.Keys...)
+		}
+		return keys
+	}
+
+	assert.ElementsMatch(t, expected, list("/p/", "/p//"))
+	assert.Equal(t, expected, list("/p/", "/p//", Sorted()))
+	assert.Equal(t, expected[:1500], list("/p/", "/p//", Sorted(), Limit(1500)))
+	assert.Len(t, list("/p/", "/p//", Limit(1500)), 1500)
+
+	assert.Empty(t, list("/r/", "/r//"))
+	assert.Empty(t, list("/r/", "/r//", Sorted()))
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func shardListResults(results ...ListResult) chan ListResult {
+	ch := make(chan ListResult)
+	go func() {
+		defer close(ch)
+		for _, r := range results {
+			ch <- r
+		}
+	}()
+	return ch
+}
+
+func TestAggregateListAcrossShards(t *testing.T) {
+	errShard := errors.New("shard failed")
+	zero, three := uint64(0), uint64(3)
+
+	for _, item := range []struct {
+		name     string
+		sorted   bool
+		limit    *uint64
+		channels func() []chan ListResult
+		keys     []string
+		err      error
+	}{
+		{"sorted pages", true, nil, func() []chan ListResult {
+			return []chan ListResult{
+				shardListResults(ListResult{Keys: []string{"a", "d"}}, ListResult{Keys: []string{"e", "h"}}),
+				shardListResults(ListResult{Keys: []string{"b"}}, ListResult{}, ListResult{Keys: []string{"c", "f", "g"}}),
+				shardListResults(),
+			}
+		}, []string{"a", "b", "c", "d", "e", "f", "g", "h"}, nil},
+		{"sorted limit", true, &three, func() []chan ListResult {
+			return []chan ListResult{
+				shardListResults(ListResult{Keys: []string{"a", "d", "e"}}),
+				shardListResults(ListResult{Keys: []string{"b", "c", "f"}}),
+			}
+		}, []string{"a", "b", "c"}, nil},
+		{"sorted failure", true, nil, func() []chan ListResult {
+			return []chan ListResult{
+				shardListResults(ListResult{Keys: []string{"a", "c"}}, ListResult{Keys: []string{"d"}}),
+				shardListResults(ListResult{Keys: []string{"b"}}, ListResult{Err: errShard}),
+			}
+		}, []string{"a", "b"}, errShard},
+		{"unsorted failure", false, nil, func() []chan ListResult {
+			return []chan ListResult{
+				shardListResults(ListResult{Keys: []string{"a", "c"}}, ListResult{Keys: []string{"d"}}),
+				shardListResults(ListResult{Err: errShard}),
+			}
+		}, []string{"a", "c", "d"}, errShard},
+		{"unsorted limit", false, &zero, func() []chan ListResult {
+			return []chan ListResult{
+				shardListResults(ListResult{Keys: []string{"a", "c"}}),
+			}
+		}, nil, nil},
+	} {
+		t.Run(item.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			out := make(chan ListResult)
+			if item.sorted {
+				go aggregateAndSortListAcrossShards(ctx, cancel, item.channels(), item.limit, out)
+			} else {
+				go aggregateListAcrossShards(ctx, cancel, item.channels(), item.limit, out)
+			}
+
+			var keys []string
+			var err error
+			for r := range out {
+				if r.Err != nil {
+					err = r.Err
+				}
+				keys = append(keys, r.Keys...)
+			}
+
+			// The failure of a shard doesn't drop the keys of the others,
+			// as long as they don't need to be sorted
+			if item.sorted {
+				assert.Equal(t, item.keys, keys)
+			} else {
+				assert.ElementsMatch(t, item.keys, keys)
+			}
+			assert.Equal(t, item.err, err)
+		})
+	}
+}
+
+func scanGoroutines() int {
+	buf := make([]byte, 16<<20)
+	count := 0
+	for _, stack := range strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n") {
+		if strings.Contains(stack, "FromShard") || strings.Contains(stack, "AcrossShards") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestAsyncClientImpl_ScanCanceled(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	config.NumShards = 4
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress)
+	assert.NoError(t, err)
+
+	var results []<-chan PutResult
+	for i := 0; i < 3000; i++ {
+		results = append(results, client.Put(fmt.Sprintf("/p/%04d", i), []byte{0}))
+	}
+	for _, r := range results {
+		assert.NoError(t, (<-r).Err)
+	}
+
+	// The application stops reading the results once the context is
+	// canceled
+	for _, sorted := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		var options []ListOption
+		if sorted {
+			options = append(options, Sorted())
+		}
+		r := <-client.List(ctx, "/p/", "/p//", options...)
+		assert.NoError(t, r.Err)
+		cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gr := <-client.RangeScan(ctx, "/p/", "/p//")
+	assert.NoError(t, gr.Err)
+	cancel()
+
+	// A list that is canceled is not mistaken for an empty one
+	syncClient := &syncClientImpl{asyncClient: client}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = syncClient.List(ctx, "/p/", "/p//")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// All the shards are stopped
+	assert.Eventually(t, func() bool {
+		return scanGoroutines() == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestSyncClientImpl_ExpectedValue(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, _, err = client.Put(ctx, "a", []byte("x"), ExpectedValue([]byte("x")))
+	assert.ErrorIs(t, err, ErrUnexpectedValue)
+
+	_, v1, err := client.Put(ctx, "a", []byte("x"))
+	assert.NoError(t, err)
+
+	_, v2, err := client.Put(ctx, "a", []byte("y"), ExpectedValue([]byte("x")))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, v2.ModificationsCount)
+
+	// The version of the stored record is returned on mismatch
+	_, version, err := client.Put(ctx, "a", []byte("z"), ExpectedValue([]byte("x")))
+	assert.ErrorIs(t, err, ErrUnexpectedValue)
+	assert.Equal(t, v2.VersionId, version.VersionId)
+
+	_, _, err = client.Put(ctx, "a", []byte("z"), ExpectedValue([]byte("y")), ExpectedVersionId(v1.VersionId))
+	assert.ErrorIs(t, err, ErrUnexpectedVersionId)
+
+	_, _, err = client.Put(ctx, "a", []byte("z"), PartitionKey("a"), SequenceKeysDeltas(1), ExpectedValue([]byte("y")))
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+
+	assert.ErrorIs(t, client.Delete(ctx, "a", ExpectedValue([]byte("x"))), ErrUnexpectedValue)
+	assert.NoError(t, client.Delete(ctx, "a", ExpectedValue([]byte("y")), ExpectedVersionId(v2.VersionId)))
+	assert.ErrorIs(t, client.Delete(ctx, "a", ExpectedValue([]byte("y"))), ErrKeyNotFound)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
 func TestAsyncClientImpl_SequenceOrdering(t *testing.T) {
 	config := server.NewTestConfig(t.TempDir())
 	standaloneServer, err := server.NewStandalone(config)
@@ -884,3 +1232,459 @@ func TestAsyncClientImpl_versionId(t *testing.T) {
 
 	assert.NoError(t, standaloneServer.Close())
 }
+
+func TestAsyncClientImpl_CloseFlushesBatches(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress, WithBatchLinger(1*time.Minute))
+	assert.NoError(t, err)
+
+	var responses []<-chan PutResult
+	for i := 0; i < 10; i++ {
+		responses = append(responses, client.Put(fmt.Sprintf("/key-%d", i), []byte("0")))
+	}
+
+	// The writes are still lingering in the batch when the client is closed
+	assert.NoError(t, client.Close())
+	for _, r := range responses {
+		assert.NoError(t, (<-r).Err)
+	}
+
+	syncClient, err := NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+	keys, err := syncClient.List(context.Background(), "/key-", "/key-/")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 10)
+
+	assert.NoError(t, syncClient.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestAsyncClientImpl_Metrics(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress,
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithBatchLinger(1*time.Minute),
+		WithMaxRequestsPerBatch(4))
+	assert.NoError(t, err)
+
+	var responses []<-chan PutResult
+	for i := 0; i < 10; i++ {
+		responses = append(responses, client.Put(fmt.Sprintf("/key-%d", i), []byte("0")))
+	}
+	for _, r := range responses[:8] {
+		assert.NoError(t, (<-r).Err)
+	}
+
+	// The last batch is only sent when the client is closed
+	assert.NoError(t, client.Close())
+	for _, r := range responses[8:] {
+		assert.NoError(t, (<-r).Err)
+	}
+
+	rm := metricdata.ResourceMetrics{}
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+	sums := func(name string, key attribute.Key) map[string]int64 {
+		res := map[string]int64{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if d, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == name {
+					for _, dp := range d.DataPoints {
+						value, _ := dp.Attributes.Value(key)
+						res[value.Emit()] += dp.Value
+					}
+				}
+			}
+		}
+		return res
+	}
+
+	assert.Equal(t, map[string]int64{"0": 10}, sums("oxia_client_op", "shard"))
+	assert.Equal(t, map[string]int64{"max_requests": 2, "close": 1}, sums("oxia_client_batch_flush", "reason"))
+	assert.Equal(t, map[string]int64{"full": 1}, sums("oxia_client_shard_assignments_updates", "type"))
+
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestAsyncClientImpl_StaleLeader(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+
+	// A server that doesn't lead the shard anymore
+	formerLeader, err := server.New(server.Config{
+		PublicServiceAddr:          "localhost:0",
+		InternalServiceAddr:        "localhost:0",
+		DataDir:                    t.TempDir(),
+		WalDir:                     t.TempDir(),
+		NotificationsRetentionTime: 1 * time.Minute,
+	})
+	assert.NoError(t, err)
+	formerLeaderAddress := fmt.Sprintf("localhost:%d", formerLeader.PublicPort())
+
+	client, err := NewAsyncClient(serviceAddress, WithBatchLinger(0))
+	assert.NoError(t, err)
+	shardManager := client.(*clientImpl).shardManager
+
+	// The client has missed the change of leader, and the assignments were
+	// just refreshed, so the next refresh is delayed
+	shardManager.InvalidateLeader(0, serviceAddress, formerLeaderAddress)
+	assert.Eventually(t, func() bool {
+		return shardManager.Leader(0) == serviceAddress
+	}, 10*time.Second, 10*time.Millisecond)
+	shardManager.InvalidateLeader(0, serviceAddress, formerLeaderAddress)
+	assert.Equal(t, formerLeaderAddress, shardManager.Leader(0))
+
+	// The requests rejected by the former leader wait for the refreshed
+	// assignments and are sent to the leader
+	put := client.Put("/key", []byte("value"))
+	get := client.Get("/key")
+	assert.NoError(t, (<-put).Err)
+	res := <-get
+	if res.Err != nil {
+		assert.ErrorIs(t, res.Err, ErrKeyNotFound)
+	}
+	assert.Equal(t, serviceAddress, shardManager.Leader(0))
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, formerLeader.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestAsyncClientImpl_LeaderRestart(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress, WithBatchLinger(0))
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, (<-client.Put(fmt.Sprintf("/key-%d", i), []byte("0"))).Err)
+	}
+
+	// The leader is restarted while the requests are in flight
+	const requests = 20
+	gets := make([]GetResult, requests)
+	puts := make([]PutResult, requests)
+	wg := sync.WaitGroup{}
+	wg.Add(2 * requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			defer wg.Done()
+			gets[i] = <-client.Get(fmt.Sprintf("/key-%d", i%10))
+		}()
+		go func() {
+			defer wg.Done()
+			puts[i] = <-client.Put(fmt.Sprintf("/new-key-%d", i), []byte("1"), ExpectedRecordNotExists())
+		}()
+
+		if i == requests/2 {
+			assert.NoError(t, standaloneServer.Close())
+		}
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	config.PublicServiceAddr = serviceAddress
+	standaloneServer, err = server.NewStandalone(config)
+	assert.NoError(t, err)
+	wg.Wait()
+
+	for _, res := range gets {
+		assert.NoError(t, res.Err)
+		assert.Equal(t, []byte("0"), res.Value)
+	}
+
+	// The puts were applied at most once. A put whose first attempt was
+	// applied fails the retry with a conflict with itself
+	for i, res := range puts {
+		get := <-client.Get(fmt.Sprintf("/new-key-%d", i))
+		switch {
+		case res.Err == nil:
+			assert.NoError(t, get.Err)
+			assert.Equal(t, res.Version, get.Version)
+		case errors.Is(res.Err, ErrUnexpectedVersionId):
+			assert.NoError(t, get.Err)
+		default:
+			continue
+		}
+		assert.EqualValues(t, 0, get.Version.ModificationsCount)
+	}
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestAsyncClientImpl_Context(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress, WithBatchLinger(100*time.Millisecond))
+	assert.NoError(t, err)
+
+	// The puts given up while lingering in the batch are not sent
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := client.Put("/a", []byte("0"), Context(ctx))
+	put := client.Put("/b", []byte("0"), Context(context.Background()))
+	cancel()
+
+	assert.ErrorIs(t, (<-canceled).Err, context.Canceled)
+	assert.NoError(t, (<-put).Err)
+
+	assert.ErrorIs(t, (<-client.Get("/a")).Err, ErrKeyNotFound)
+	assert.ErrorIs(t, (<-client.Get("/a", Context(ctx))).Err, context.Canceled)
+	assert.ErrorIs(t, <-client.Delete("/b", Context(ctx)), context.Canceled)
+	assert.NoError(t, (<-client.Get("/b")).Err)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestAsyncClientImpl_Deadlines(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress, WithBatchLinger(100*time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, (<-client.Put("/a", []byte("0"))).Err)
+
+	// The leader is down while the puts are retried
+	assert.NoError(t, standaloneServer.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	expired := client.Put("/b", []byte("0"), Context(ctx))
+	put := client.Put("/c", []byte("0"))
+
+	// The put with a deadline fails once it's expired, without waiting for
+	// the request timeout
+	assert.ErrorIs(t, (<-expired).Err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), DefaultRequestTimeout/2)
+
+	config.PublicServiceAddr = serviceAddress
+	standaloneServer, err = server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	// The put without a deadline is retried until the leader is back
+	assert.NoError(t, (<-put).Err)
+	assert.ErrorIs(t, (<-client.Get("/b")).Err, ErrKeyNotFound)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestAsyncClientImpl_ConcurrentPuts(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+	goroutines := clientGoroutines()
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress)
+	assert.NoError(t, err)
+
+	const keys = 1_000
+	const putsPerKey = 100
+	results := make([]<-chan PutResult, 0, keys*putsPerKey)
+	for i := 0; i < putsPerKey; i++ {
+		for k := 0; k < keys; k++ {
+			results = append(results, client.Put(fmt.Sprintf("/key-%d", k), []byte(fmt.Sprintf("%d", i))))
+		}
+	}
+
+	// The puts on each key are applied in the order in which they were issued
+	for i, r := range results {
+		res := <-r
+		assert.NoError(t, res.Err)
+		assert.EqualValues(t, i/keys, res.Version.ModificationsCount)
+		_, ok := <-r
+		assert.False(t, ok)
+	}
+
+	for k := 0; k < keys; k++ {
+		res := <-client.Get(fmt.Sprintf("/key-%d", k))
+		assert.NoError(t, res.Err)
+		assert.Equal(t, []byte(fmt.Sprintf("%d", putsPerKey-1)), res.Value)
+	}
+
+	assert.NoError(t, client.Close())
+	assert.Eventually(t, func() bool {
+		return clientGoroutines() <= goroutines
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, standaloneServer.Close())
+}
+
+// clientGoroutines counts the goroutines of the clients, leaving aside the
+// ones of the servers that are still shutting down.
+func clientGoroutines() int {
+	buf := make([]byte, 16<<20)
+	count := 0
+	for _, stack := range strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n") {
+		if strings.Contains(stack, "oxia/oxia/internal") || strings.Contains(stack, "oxia/common/batch") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSyncClientImpl_GetMany(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, _, err = client.Put(ctx, "/a", []byte("0"))
+	assert.NoError(t, err)
+	_, _, err = client.Put(ctx, "/c", []byte("2"))
+	assert.NoError(t, err)
+
+	results := client.GetMany(ctx, []string{"/c", "/b", "/a", "/d", "/a"})
+	assert.Len(t, results, 5)
+
+	// The results are in the order of the keys
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "/c", results[0].Key)
+	assert.Equal(t, "2", string(results[0].Value))
+	assert.ErrorIs(t, results[1].Err, ErrKeyNotFound)
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, "/a", results[2].Key)
+	assert.Equal(t, "0", string(results[2].Value))
+	assert.ErrorIs(t, results[3].Err, ErrKeyNotFound)
+	assert.NoError(t, results[4].Err)
+	assert.Equal(t, "/a", results[4].Key)
+
+	results = client.GetMany(ctx, []string{"/b", "/c"}, ComparisonFloor())
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "/a", results[0].Key)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, "/c", results[1].Key)
+
+	assert.Empty(t, client.GetMany(ctx, nil))
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestAsyncClientImpl_GetManyAcrossShards(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	config.NumShards = 3
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress,
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	assert.NoError(t, err)
+
+	var keys []string
+	shards := map[int64]bool{}
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("/key-%02d", i)
+		keys = append(keys, key)
+		shards[client.(*clientImpl).shardManager.Get(key)] = true
+
+		// Only the even keys exist
+		if i%2 == 0 {
+			assert.NoError(t, (<-client.Put(key, []byte(key))).Err)
+		}
+	}
+	assert.Len(t, shards, 3)
+
+	results := <-client.GetMany(keys)
+	assert.Len(t, results, len(keys))
+	for i, r := range results {
+		if i%2 == 0 {
+			assert.NoError(t, r.Err)
+			assert.Equal(t, keys[i], r.Key)
+			assert.Equal(t, keys[i], string(r.Value))
+		} else {
+			assert.ErrorIs(t, r.Err, ErrKeyNotFound)
+		}
+	}
+
+	// The gets are sent with a single request per shard
+	rm := metricdata.ResourceMetrics{}
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+	readBatches := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if d, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == "oxia_client_batch_exec" {
+				for _, dp := range d.DataPoints {
+					if requestType, _ := dp.Attributes.Value("type"); requestType.AsString() == "read" {
+						shard, _ := dp.Attributes.Value("shard")
+						readBatches[shard.Emit()] += dp.Value
+					}
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{"0": 1, "1": 1, "2": 1}, readBatches)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestSyncClientImpl_GetWithoutValue(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, putVersion, err := client.Put(ctx, "/a", []byte("0123456789"))
+	assert.NoError(t, err)
+
+	key, value, version, err := client.Get(ctx, "/a", ExcludeValue())
+	assert.NoError(t, err)
+	assert.Equal(t, "/a", key)
+	assert.Nil(t, value)
+	assert.Equal(t, putVersion.VersionId, version.VersionId)
+
+	_, value, version, err = client.Get(ctx, "/a", MaxValueSize(9))
+	assert.ErrorIs(t, err, ErrValueTooLarge)
+	assert.Nil(t, value)
+	assert.Equal(t, putVersion.VersionId, version.VersionId)
+
+	_, value, _, err = client.Get(ctx, "/a", MaxValueSize(10))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(value))
+
+	_, _, _, err = client.Get(ctx, "/b", MaxValueSize(10))
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	results := client.GetMany(ctx, []string{"/a", "/b"}, ExcludeValue())
+	assert.NoError(t, results[0].Err)
+	assert.Nil(t, results[0].Value)
+	assert.EqualValues(t, 10, results[0].ValueSize)
+	assert.ErrorIs(t, results[1].Err, ErrKeyNotFound)
+
+	results = client.GetMany(ctx, []string{"/a"}, MaxValueSize(5), ConsistencyBoundedStaleness())
+	assert.ErrorIs(t, results[0].Err, ErrValueTooLarge)
+	assert.EqualValues(t, 10, results[0].ValueSize)
+	assert.Equal(t, putVersion.VersionId, results[0].Version.VersionId)
+
+	key, _, _, err = client.Get(ctx, "/", ComparisonHigher(), MaxValueSize(5))
+	assert.ErrorIs(t, err, ErrValueTooLarge)
+	assert.Equal(t, "/a", key)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
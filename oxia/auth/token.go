@@ -16,6 +16,11 @@ package auth
 
 import (
 	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 type tokenAuthentication struct {
@@ -43,3 +48,60 @@ func NewTokenAuthenticationWithToken(token string, requireTransportSecurity bool
 		return token
 	}, requireTransportSecurity)
 }
+
+// tokenRefreshMargin is how long before its expiration a token is refreshed,
+// so that it doesn't expire while a request is in flight. It's reduced for
+// the tokens that are valid for a short time.
+const tokenRefreshMargin = 30 * time.Second
+
+type refreshingTokenAuthentication struct {
+	sync.Mutex
+	requireTransportSecurity bool
+	refreshFunc              func(ctx context.Context) (token string, expiresAt time.Time, err error)
+
+	token     string
+	expiresAt time.Time
+	refreshAt time.Time
+}
+
+func (tokenAuth *refreshingTokenAuthentication) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	tokenAuth.Lock()
+	defer tokenAuth.Unlock()
+
+	now := time.Now()
+	if tokenAuth.token == "" || !now.Before(tokenAuth.refreshAt) {
+		token, expiresAt, err := tokenAuth.refreshFunc(ctx)
+		if err != nil {
+			// The current token is used until it expires, in case the
+			// token provider is only temporarily unavailable
+			if tokenAuth.token == "" || !now.Before(tokenAuth.expiresAt) {
+				return nil, errors.Wrap(err, "failed to refresh the authentication token")
+			}
+			slog.Warn(
+				"Failed to refresh the authentication token, using the current one",
+				slog.Time("expires-at", tokenAuth.expiresAt),
+				slog.Any("error", err),
+			)
+		} else {
+			tokenAuth.token = token
+			tokenAuth.expiresAt = expiresAt
+			tokenAuth.refreshAt = expiresAt.Add(-min(tokenRefreshMargin, expiresAt.Sub(now)/10))
+		}
+	}
+
+	return map[string]string{
+		"authorization": "Bearer" + " " + tokenAuth.token,
+	}, nil
+}
+
+func (tokenAuth *refreshingTokenAuthentication) RequireTransportSecurity() bool {
+	return tokenAuth.requireTransportSecurity
+}
+
+// NewTokenAuthenticationWithRefresh creates the authentication with the tokens
+// that expire, like JWTs. The refresh function is called to get a new token,
+// along with its expiration time, before the current one expires.
+func NewTokenAuthenticationWithRefresh(refreshFunc func(ctx context.Context) (token string, expiresAt time.Time, err error),
+	requireTransportSecurity bool) Authentication {
+	return &refreshingTokenAuthentication{refreshFunc: refreshFunc, requireTransportSecurity: requireTransportSecurity}
+}
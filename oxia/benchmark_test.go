@@ -0,0 +1,78 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oxia
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/server"
+)
+
+// BenchmarkPut compares the throughput of the puts sent one by one with the
+// one of the puts that are batched by the client.
+func BenchmarkPut(b *testing.B) {
+	common.LogLevel = slog.LevelWarn
+	common.ConfigureLogger()
+
+	for _, item := range []struct {
+		name    string
+		options []ClientOption
+	}{
+		{"unbatched", []ClientOption{WithMaxRequestsPerBatch(1)}},
+		{"batched", []ClientOption{WithBatchLinger(DefaultBatchLinger)}},
+	} {
+		b.Run(item.name, func(b *testing.B) {
+			standaloneServer, err := server.NewStandalone(server.NewTestConfig(b.TempDir()))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+			client, err := NewAsyncClient(serviceAddress, item.options...)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			value := make([]byte, 128)
+			b.SetBytes(int64(len(value)))
+			b.ResetTimer()
+
+			// Keep many puts in flight, so that they can be batched
+			inFlight := make(chan (<-chan PutResult), 1000)
+			go func() {
+				for i := 0; i < b.N; i++ {
+					inFlight <- client.Put(fmt.Sprintf("key-%d", i%10_000), value)
+				}
+				close(inFlight)
+			}()
+			for r := range inFlight {
+				if res := <-r; res.Err != nil {
+					b.Fatal(res.Err)
+				}
+			}
+
+			b.StopTimer()
+			if err := client.Close(); err != nil {
+				b.Fatal(err)
+			}
+			if err := standaloneServer.Close(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
@@ -35,11 +35,30 @@ var (
 	// the current version id of the stored record.
 	ErrUnexpectedVersionId = errors.New("unexpected version id")
 
+	// ErrUnexpectedValue The expected value passed as a condition does not match
+	// the current value of the stored record, or the record does not exist.
+	ErrUnexpectedValue = errors.New("unexpected value")
+
 	ErrInvalidOptions = errors.New("invalid options")
 
 	// ErrRequestTooLarge is returned when a request is larger than the maximum batch size.
 	ErrRequestTooLarge = batch.ErrRequestTooLarge
 
+	// ErrShardSplit The shard was split while the operation was in progress. The
+	// operations on a single key are retried automatically, while the others can
+	// be retried by the application.
+	ErrShardSplit = errors.New("shard was split")
+
+	// ErrSessionLost The session that holds the ephemeral records of the client
+	// has expired, or it was closed. A new session is created for the next
+	// ephemeral records.
+	ErrSessionLost = errors.New("session lost")
+
+	// ErrValueTooLarge The value of the record is larger than the size set with
+	// the MaxValueSize option of the get. The result has the version of the
+	// record and the size of the value.
+	ErrValueTooLarge = errors.New("value too large")
+
 	// ErrUnknownStatus Unknown error.
 	ErrUnknownStatus = errors.New("unknown status")
 )
@@ -55,6 +74,11 @@ var (
 // Batching of requests will ensure a larger throughput and more efficient handling.
 // Applications can control the batching by configuring the linger-time with
 // [WithBatchLinger] option in [NewAsyncClient].
+//
+// The operations on the same shard are applied in the order in which they were
+// enqueued, and each channel receives exactly one result. The [Context] option
+// lets the operations be dropped if the caller gives up on them before they're
+// sent.
 type AsyncClient interface {
 	io.Closer
 
@@ -64,19 +88,26 @@ type AsyncClient interface {
 	//  - The Put operation can be made conditional on that the record hasn't changed from
 	//    a specific existing version by passing the [ExpectedVersionId] option.
 	//  - Client can assert that the record does not exist by passing [ExpectedRecordNotExists]
+	//  - The Put operation can be made conditional on the current value of the record
+	//    by passing the [ExpectedValue] option.
 	//  - Client can create an ephemeral record with [Ephemeral]
 	//
 	// Returns a [Version] object that contains information about the newly updated record
 	// Returns [ErrorUnexpectedVersionId] if the expected version id does not match the
 	// current version id of the record
+	// Returns [ErrUnexpectedValue] if the expected value does not match the current
+	// value of the record. In this case, the [Version] of the stored record is returned.
 	Put(key string, value []byte, options ...PutOption) <-chan PutResult
 
 	// Delete removes the key and its associated value from the data store.
 	//
 	// The Delete operation can be made conditional on that the record hasn't changed from
-	// a specific existing version by passing the [ExpectedVersionId] option.
+	// a specific existing version by passing the [ExpectedVersionId] option, or on its
+	// current value by passing the [ExpectedValue] option.
 	// Returns [ErrorUnexpectedVersionId] if the expected version id does not match the
 	// current version id of the record
+	// Returns [ErrUnexpectedValue] if the expected value does not match the current
+	// value of the record
 	Delete(key string, options ...DeleteOption) <-chan error
 
 	// DeleteRange deletes any records with keys within the specified range.
@@ -89,12 +120,32 @@ type AsyncClient interface {
 	// In addition to the value, a version object is also returned, with information
 	// about the record state.
 	// Returns ErrorKeyNotFound if the record does not exist
+	//
+	// With the ComparisonFloor, ComparisonCeiling, ComparisonLower and
+	// ComparisonHigher options, the closest key is searched on all the shards,
+	// or only on the shard of the PartitionKey if set, and the key that was
+	// found is returned.
 	Get(key string, options ...GetOption) <-chan GetResult
 
+	// GetMany returns the values associated with the specified keys, in the
+	// same order as the keys. The gets are sent with at most one request per
+	// shard, and the ones on the same shard are read from the same state of
+	// the shard.
+	// The result of each key has ErrorKeyNotFound if the record does not exist
+	GetMany(keys []string, options ...GetOption) <-chan []GetResult
+
 	// List any existing keys within the specified range.
 	// Note: Oxia uses a custom sorting order that treats `/` characters in special way.
 	// Refer to this documentation for the specifics:
 	// https://github.com/streamnative/oxia/blob/main/docs/oxia-key-sorting.md
+	//
+	// The keys are listed on all the shards in parallel, and they are sorted
+	// within each shard, but not across the shards, unless the [Sorted] option
+	// is passed. The failure of a shard is returned in a [ListResult], while
+	// the keys of the other shards keep being listed.
+	//
+	// Canceling the context stops the list on all the shards, and closes the
+	// channel.
 	List(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, options ...ListOption) <-chan ListResult
 
 	// RangeScan perform a scan for existing records with any keys within the specified range.
@@ -104,8 +155,10 @@ type AsyncClient interface {
 	RangeScan(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, options ...RangeScanOption) <-chan GetResult
 
 	// GetNotifications creates a new subscription to receive the notifications
-	// from Oxia for any change that is applied to the database
-	GetNotifications() (Notifications, error)
+	// from Oxia for any change that is applied to the database.
+	// The notifications can be restricted to a subset of the keys with the
+	// [KeyPrefix] option.
+	GetNotifications(options ...NotificationsOption) (Notifications, error)
 }
 
 // SyncClient is the main interface to perform operations with Oxia.
@@ -125,20 +178,27 @@ type SyncClient interface {
 	//  - The Put operation can be made conditional on that the record hasn't changed from
 	//    a specific existing version by passing the [ExpectedVersionId] option.
 	//  - Client can assert that the record does not exist by passing [ExpectedRecordNotExists]
+	//  - The Put operation can be made conditional on the current value of the record
+	//    by passing the [ExpectedValue] option.
 	//  - Client can create an ephemeral record with [Ephemeral]
 	//
 	// Returns the actual key of the inserted record
 	// Returns a [Version] object that contains information about the newly updated record
 	// Returns [ErrorUnexpectedVersionId] if the expected version id does not match the
 	// current version id of the record
+	// Returns [ErrUnexpectedValue] if the expected value does not match the current
+	// value of the record. In this case, the [Version] of the stored record is returned.
 	Put(ctx context.Context, key string, value []byte, options ...PutOption) (insertedKey string, version Version, err error)
 
 	// Delete removes the key and its associated value from the data store.
 	//
 	// The Delete operation can be made conditional on that the record hasn't changed from
-	// a specific existing version by passing the [ExpectedVersionId] option.
+	// a specific existing version by passing the [ExpectedVersionId] option, or on its
+	// current value by passing the [ExpectedValue] option.
 	// Returns [ErrorUnexpectedVersionId] if the expected version id does not match the
 	// current version id of the record
+	// Returns [ErrUnexpectedValue] if the expected value does not match the current
+	// value of the record
 	Delete(ctx context.Context, key string, options ...DeleteOption) error
 
 	// DeleteRange deletes any records with keys within the specified range.
@@ -151,12 +211,27 @@ type SyncClient interface {
 	// In addition to the value, a version object is also returned, with information
 	// about the record state.
 	// Returns ErrorKeyNotFound if the record does not exist
+	//
+	// With the ComparisonFloor, ComparisonCeiling, ComparisonLower and
+	// ComparisonHigher options, the closest key is searched on all the shards,
+	// or only on the shard of the PartitionKey if set, and the key that was
+	// found is returned.
 	Get(ctx context.Context, key string, options ...GetOption) (storedKey string, value []byte, version Version, err error)
 
+	// GetMany returns the values associated with the specified keys, in the
+	// same order as the keys. The gets are sent with at most one request per
+	// shard, and the ones on the same shard are read from the same state of
+	// the shard.
+	// The result of each key has ErrorKeyNotFound if the record does not exist
+	GetMany(ctx context.Context, keys []string, options ...GetOption) []GetResult
+
 	// List any existing keys within the specified range.
 	// Note: Oxia uses a custom sorting order that treats `/` characters in special way.
 	// Refer to this documentation for the specifics:
 	// https://github.com/streamnative/oxia/blob/main/docs/oxia-key-sorting.md
+	//
+	// The keys are sorted across the shards only if the [Sorted] option is
+	// passed.
 	List(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, options ...ListOption) (keys []string, err error)
 
 	// RangeScan perform a scan for existing records with any keys within the specified range.
@@ -165,8 +240,10 @@ type SyncClient interface {
 	RangeScan(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, options ...RangeScanOption) <-chan GetResult
 
 	// GetNotifications creates a new subscription to receive the notifications
-	// from Oxia for any change that is applied to the database
-	GetNotifications() (Notifications, error)
+	// from Oxia for any change that is applied to the database.
+	// The notifications can be restricted to a subset of the keys with the
+	// [KeyPrefix] option.
+	GetNotifications(options ...NotificationsOption) (Notifications, error)
 }
 
 // Version includes some information regarding the state of a record.
@@ -224,6 +301,10 @@ type GetResult struct {
 	// The version information
 	Version Version
 
+	// ValueSize is the size of the value, when the value is not returned
+	// because of the ExcludeValue or the MaxValueSize options
+	ValueSize uint64
+
 	// The error if the `Get` operation failed
 	Err error
 }
@@ -242,8 +323,18 @@ type ListResult struct {
 type Notifications interface {
 	io.Closer
 
-	// Ch exposes the channel where all the notification events are published
+	// Ch exposes the channel where all the notification events are published.
+	// The changes of a key are published in the order they were applied, and
+	// the notifications are not read from the servers while the channel is
+	// full, so a slow consumer doesn't make the client buffer them.
+	//
+	// The channel is closed once the notifications are closed, or when they
+	// fail permanently, in which case [Notifications.Err] returns the error.
 	Ch() <-chan *Notification
+
+	// Err returns the error that stopped the notifications, or nil if they
+	// were closed by the application.
+	Err() error
 }
 
 // NotificationType represents the type of the notification event.
@@ -281,4 +372,17 @@ type Notification struct {
 
 	// The current VersionId of the record, or -1 for a KeyDeleted event
 	VersionId int64
+
+	// The shard that holds the record
+	Shard int64
+
+	// The offset of the change in the shard
+	Offset int64
+
+	// The time when the record was created, or 0 for a KeyDeleted event
+	CreatedTimestamp uint64
+
+	// The time of the change, which is the ModifiedTimestamp of the record
+	// for the KeyCreated and KeyModified events
+	ModifiedTimestamp uint64
 }
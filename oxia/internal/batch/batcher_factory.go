@@ -20,6 +20,7 @@ import (
 	"github.com/streamnative/oxia/common/batch"
 	"github.com/streamnative/oxia/oxia/internal"
 	"github.com/streamnative/oxia/oxia/internal/metrics"
+	"github.com/streamnative/oxia/proto"
 )
 
 type BatcherFactory struct {
@@ -50,7 +51,7 @@ func NewBatcherFactory(
 }
 
 func (b *BatcherFactory) NewWriteBatcher(shardId *int64, maxWriteBatchSize int) batch.Batcher {
-	return b.newBatcher(shardId, writeBatchFactory{
+	return b.newBatcher(shardId, "write", writeBatchFactory{
 		execute:        b.Executor.ExecuteWrite,
 		metrics:        b.Metrics,
 		requestTimeout: b.RequestTimeout,
@@ -58,16 +59,23 @@ func (b *BatcherFactory) NewWriteBatcher(shardId *int64, maxWriteBatchSize int)
 	}.newBatch)
 }
 
-func (b *BatcherFactory) NewReadBatcher(shardId *int64) batch.Batcher {
-	return b.newBatcher(shardId, readBatchFactory{
+// NewReadBatcher creates a batcher for the reads with the given consistency.
+// If minOffset is set, it provides the offset that the replica serving the
+// reads must have applied.
+func (b *BatcherFactory) NewReadBatcher(shardId *int64, consistency proto.ReadConsistency,
+	minOffset func(shardId int64) *int64) batch.Batcher {
+	return b.newBatcher(shardId, "read", readBatchFactory{
 		execute:        b.Executor.ExecuteRead,
+		consistency:    consistency,
+		minOffset:      minOffset,
 		metrics:        b.Metrics,
 		requestTimeout: b.RequestTimeout,
 	}.newBatch)
 }
 
-func (b *BatcherFactory) newBatcher(shardId *int64, batchFactory func(shardId *int64) batch.Batch) batch.Batcher {
+func (b *BatcherFactory) newBatcher(shardId *int64, requestType string,
+	batchFactory func(shardId *int64) batch.Batch) batch.Batcher {
 	return b.NewBatcher(func() batch.Batch {
 		return batchFactory(shardId)
-	})
+	}, b.Metrics.BatchFlushed(requestType, *shardId))
 }
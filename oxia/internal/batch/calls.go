@@ -0,0 +1,64 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import "time"
+
+type cancelableCall interface {
+	ContextErr() error
+	ContextDeadline() (time.Time, bool)
+}
+
+// removeCanceled returns the calls that are still awaited by the callers, and
+// fails the other ones with the error of their context.
+func removeCanceled[T cancelableCall](calls []T, fail func(T, error)) []T {
+	res := calls[:0]
+	for _, call := range calls {
+		if err := call.ContextErr(); err != nil {
+			fail(call, err)
+			continue
+		}
+		res = append(res, call)
+	}
+	return res
+}
+
+// deadlines are the earliest and the latest deadlines of the calls of a
+// batch. The calls whose context has no deadline are given the default one,
+// from the request timeout.
+type deadlines struct {
+	defaultDeadline time.Time
+	earliest        time.Time
+	latest          time.Time
+}
+
+func newDeadlines(defaultDeadline time.Time) *deadlines {
+	return &deadlines{defaultDeadline: defaultDeadline}
+}
+
+func addDeadlines[T cancelableCall](d *deadlines, calls []T) {
+	for _, call := range calls {
+		deadline, ok := call.ContextDeadline()
+		if !ok {
+			deadline = d.defaultDeadline
+		}
+		if d.earliest.IsZero() || deadline.Before(d.earliest) {
+			d.earliest = deadline
+		}
+		if deadline.After(d.latest) {
+			d.latest = deadline
+		}
+	}
+}
@@ -33,6 +33,8 @@ import (
 type readBatchFactory struct {
 	namespace      string
 	execute        func(context.Context, *proto.ReadRequest) (proto.OxiaClient_ReadClient, error)
+	consistency    proto.ReadConsistency
+	minOffset      func(shardId int64) *int64
 	metrics        *metrics.Metrics
 	requestTimeout time.Duration
 }
@@ -42,10 +44,12 @@ func (b readBatchFactory) newBatch(shardId *int64) batch.Batch {
 		namespace:      b.namespace,
 		shardId:        shardId,
 		execute:        b.execute,
+		consistency:    b.consistency,
+		minOffset:      b.minOffset,
 		gets:           make([]model.GetCall, 0),
 		start:          time.Now(),
 		metrics:        b.metrics,
-		callback:       b.metrics.ReadCallback(),
+		callback:       b.metrics.ReadCallback(*shardId),
 		requestTimeout: b.requestTimeout,
 	}
 }
@@ -54,6 +58,8 @@ type readBatch struct {
 	namespace      string
 	shardId        *int64
 	execute        func(context.Context, *proto.ReadRequest) (proto.OxiaClient_ReadClient, error)
+	consistency    proto.ReadConsistency
+	minOffset      func(shardId int64) *int64
 	gets           []model.GetCall
 	start          time.Time
 	requestTimeout time.Duration
@@ -68,7 +74,11 @@ func (*readBatch) CanAdd(_ any) bool {
 func (b *readBatch) Add(call any) {
 	switch c := call.(type) {
 	case model.GetCall:
-		b.gets = append(b.gets, b.metrics.DecorateGet(c))
+		b.gets = append(b.gets, b.metrics.DecorateGet(c, *b.shardId))
+	case model.GetCalls:
+		for _, get := range c {
+			b.gets = append(b.gets, b.metrics.DecorateGet(get, *b.shardId))
+		}
 	default:
 		panic("invalid call")
 	}
@@ -79,30 +89,69 @@ func (b *readBatch) Size() int {
 }
 
 func (b *readBatch) Complete() {
+	b.removeCanceled()
+	if b.Size() == 0 {
+		return
+	}
+
 	executionStart := time.Now()
-	request := b.toProto()
-	response, err := b.doRequestWithRetries(request)
+	request, response, err := b.doRequestWithRetries(b.toProto())
 	b.callback(executionStart, request, response, err)
 	if err != nil {
+		// The gets that have expired fail with the error of their context
+		b.removeCanceled()
 		b.Fail(err)
 	} else {
 		b.handle(response)
 	}
 }
 
-func (b *readBatch) doRequestWithRetries(request *proto.ReadRequest) (response *proto.ReadResponse, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), b.requestTimeout)
+// removeCanceled fails the gets that the callers have given up on, since
+// they don't need to be sent anymore.
+func (b *readBatch) removeCanceled() {
+	b.gets = removeCanceled(b.gets, func(get model.GetCall, err error) {
+		get.Callback(nil, err)
+	})
+}
+
+func (b *readBatch) deadlines(defaultDeadline time.Time) *deadlines {
+	d := newDeadlines(defaultDeadline)
+	addDeadlines(d, b.gets)
+	return d
+}
+
+// doRequestWithRetries sends the request until the latest deadline of the
+// gets. Each attempt is bounded by the earliest deadline, and the gets that
+// have expired are removed from the request before it's sent again.
+func (b *readBatch) doRequestWithRetries(request *proto.ReadRequest) (*proto.ReadRequest, *proto.ReadResponse, error) {
+	defaultDeadline := time.Now().Add(b.requestTimeout)
+	ctx, cancel := context.WithDeadline(context.Background(), b.deadlines(defaultDeadline).latest)
 	defer cancel()
 
 	backOff := common.NewBackOff(ctx)
 
-	err = backoff.RetryNotify(func() error {
-		response, err = b.doRequest(ctx, request)
-		if !isRetriable(err) {
+	var response *proto.ReadResponse
+	attempt := 0
+	err := backoff.RetryNotify(func() error {
+		if attempt++; attempt > 1 {
+			b.removeCanceled()
+			if b.Size() == 0 {
+				return backoff.Permanent(context.DeadlineExceeded)
+			}
+			request = b.toProto()
+		}
+
+		attemptCtx, attemptCancel := context.WithDeadline(ctx, b.deadlines(defaultDeadline).earliest)
+		defer attemptCancel()
+
+		var err error
+		response, err = b.doRequest(attemptCtx, request)
+		if !isExpiredAttempt(ctx, attemptCtx, err) && !isRetriable(err) {
 			return backoff.Permanent(err)
 		}
 		return err
 	}, backOff, func(err error, duration time.Duration) {
+		b.metrics.Retry("read", err)
 		slog.Debug(
 			"Failed to perform request, retrying later",
 			slog.Any("error", err),
@@ -112,7 +161,7 @@ func (b *readBatch) doRequestWithRetries(request *proto.ReadRequest) (response *
 		)
 	})
 
-	return response, err
+	return request, response, err
 }
 
 func (b *readBatch) doRequest(ctx context.Context, request *proto.ReadRequest) (*proto.ReadResponse, error) {
@@ -148,8 +197,13 @@ func (b *readBatch) handle(response *proto.ReadResponse) {
 }
 
 func (b *readBatch) toProto() *proto.ReadRequest {
-	return &proto.ReadRequest{
-		Shard: b.shardId,
-		Gets:  model.Convert[model.GetCall, *proto.GetRequest](b.gets, model.GetCall.ToProto),
+	request := &proto.ReadRequest{
+		Shard:       b.shardId,
+		Gets:        model.Convert[model.GetCall, *proto.GetRequest](b.gets, model.GetCall.ToProto),
+		Consistency: b.consistency,
+	}
+	if b.minOffset != nil {
+		request.MinOffset = b.minOffset(*b.shardId)
 	}
+	return request
 }
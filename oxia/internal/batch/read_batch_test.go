@@ -20,6 +20,7 @@ import (
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/otel/metric/noop"
@@ -40,6 +41,7 @@ func TestReadBatchAdd(t *testing.T) {
 		{model.DeleteCall{}, true, 0},
 		{model.DeleteRangeCall{}, true, 0},
 		{model.GetCall{}, false, 1},
+		{model.GetCalls{{Key: "/a"}, {Key: "/b"}, {Key: "/c"}}, false, 3},
 	} {
 		factory := &readBatchFactory{
 			metrics: metrics.NewMetrics(noop.NewMeterProvider()),
@@ -134,6 +136,46 @@ func TestReadBatchComplete(t *testing.T) {
 	}
 }
 
+func TestReadBatchConsistency(t *testing.T) {
+	minOffset := int64(5)
+	execute := func(ctx context.Context, request *proto.ReadRequest) (proto.OxiaClient_ReadClient, error) {
+		assert.Equal(t, &proto.ReadRequest{
+			Shard: &shardId,
+			Gets: []*proto.GetRequest{{
+				Key:          "/a",
+				IncludeValue: true,
+			}},
+			Consistency: proto.ReadConsistency_SESSION,
+			MinOffset:   &minOffset,
+		}, request)
+		return readClient([]*proto.ReadResponse{{
+			Gets: []*proto.GetResponse{{Status: proto.Status_KEY_NOT_FOUND}},
+		}}), nil
+	}
+
+	factory := &readBatchFactory{
+		execute:     execute,
+		consistency: proto.ReadConsistency_SESSION,
+		minOffset: func(id int64) *int64 {
+			assert.Equal(t, shardId, id)
+			return &minOffset
+		},
+		metrics: metrics.NewMetrics(noop.NewMeterProvider()),
+	}
+	batch := factory.newBatch(&shardId)
+
+	var getErr error
+	batch.Add(model.GetCall{
+		Key: "/a",
+		Callback: func(response *proto.GetResponse, err error) {
+			getErr = err
+		},
+	})
+
+	batch.Complete()
+	assert.NoError(t, getErr)
+}
+
 type readResult struct {
 	response *proto.ReadResponse
 	err      error
@@ -180,3 +222,48 @@ func (t *testOxiaClientReadClient) SendMsg(m any) error {
 func (t *testOxiaClientReadClient) RecvMsg(m any) error {
 	panic("not implemented")
 }
+
+func TestReadBatchDeadlines(t *testing.T) {
+	shortCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	longCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var requests []*proto.ReadRequest
+	factory := &readBatchFactory{
+		execute: func(ctx context.Context, request *proto.ReadRequest) (proto.OxiaClient_ReadClient, error) {
+			requests = append(requests, request)
+			if len(requests) == 1 {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+
+			// The deadline of the caller is later than the request timeout
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok)
+			assert.Greater(t, time.Until(deadline), 10*time.Second)
+			return readClient([]*proto.ReadResponse{{Gets: []*proto.GetResponse{{Status: proto.Status_KEY_NOT_FOUND}}}}), nil
+		},
+		metrics:        metrics.NewMetrics(noop.NewMeterProvider()),
+		requestTimeout: 10 * time.Second,
+	}
+	batch := factory.newBatch(&shardId)
+
+	var expiredErr, getErr error
+	var getResponse *proto.GetResponse
+	batch.Add(model.GetCall{Key: "/a", Context: shortCtx, Callback: func(_ *proto.GetResponse, err error) {
+		expiredErr = err
+	}})
+	batch.Add(model.GetCall{Key: "/b", Context: longCtx, Callback: func(response *proto.GetResponse, err error) {
+		getResponse = response
+		getErr = err
+	}})
+	batch.Complete()
+
+	assert.ErrorIs(t, expiredErr, context.DeadlineExceeded)
+	assert.NoError(t, getErr)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, getResponse.Status)
+	assert.Len(t, requests, 2)
+	assert.Len(t, requests[1].Gets, 1)
+	assert.Equal(t, "/b", requests[1].Gets[0].Key)
+}
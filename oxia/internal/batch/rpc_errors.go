@@ -15,10 +15,13 @@
 package batch
 
 import (
+	"context"
+
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/oxia/internal"
 )
 
 func isRetriable(err error) bool {
@@ -37,7 +40,30 @@ func isRetriable(err error) bool {
 		// We're making a request to a node that is not leader anymore.
 		// Retry to make the request to the new leader
 		return true
+	case common.CodeReadBarrierTimeout:
+		// The replica is lagging behind. Retry on a replica that might
+		// have caught up
+		return true
 	}
 
 	return false
 }
+
+// isRetriableWrite tells whether a failed write can be sent again. A write
+// that has reached the server might have been applied, even though it has
+// failed, so it's only sent again if it's idempotent, unless the server has
+// rejected it.
+func isRetriableWrite(err error, idempotent bool) bool {
+	if !isRetriable(err) {
+		return false
+	}
+
+	return idempotent || internal.IsRequestNotSent(err) || status.Code(err) == common.CodeNodeIsNotLeader
+}
+
+// isExpiredAttempt tells whether the request has failed only because the
+// calls with the earliest deadline have expired, while the other calls of the
+// batch can still be sent again.
+func isExpiredAttempt(ctx context.Context, attemptCtx context.Context, err error) bool {
+	return err != nil && attemptCtx.Err() != nil && ctx.Err() == nil
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/common/batch"
+	"github.com/streamnative/oxia/oxia/internal"
 	"github.com/streamnative/oxia/oxia/internal/metrics"
 	"github.com/streamnative/oxia/oxia/internal/model"
 	"github.com/streamnative/oxia/proto"
@@ -49,9 +50,10 @@ func (b writeBatchFactory) newBatch(shardId *int64) batch.Batch {
 		deleteRanges:   make([]model.DeleteRangeCall, 0),
 		requestTimeout: b.requestTimeout,
 		metrics:        b.metrics,
-		callback:       b.metrics.WriteCallback(),
+		callback:       b.metrics.WriteCallback(*shardId),
 		maxByteSize:    b.maxByteSize,
 		byteSize:       0,
+		idempotent:     true,
 	}
 }
 
@@ -67,6 +69,10 @@ type writeBatch struct {
 	callback       func(time.Time, *proto.WriteRequest, *proto.WriteResponse, error)
 	maxByteSize    int
 	byteSize       int
+
+	// idempotent is set when sending the batch again can't apply any of its
+	// writes twice
+	idempotent bool
 }
 
 func (b *writeBatch) CanAdd(call any) bool {
@@ -77,15 +83,16 @@ func (b *writeBatch) CanAdd(call any) bool {
 func (b *writeBatch) Add(call any) {
 	switch c := call.(type) {
 	case model.PutCall:
-		b.puts = append(b.puts, b.metrics.DecoratePut(c))
+		b.puts = append(b.puts, b.metrics.DecoratePut(c, *b.shardId))
 	case model.DeleteCall:
-		b.deletes = append(b.deletes, b.metrics.DecorateDelete(c))
+		b.deletes = append(b.deletes, b.metrics.DecorateDelete(c, *b.shardId))
 	case model.DeleteRangeCall:
-		b.deleteRanges = append(b.deleteRanges, b.metrics.DecorateDeleteRange(c))
+		b.deleteRanges = append(b.deleteRanges, b.metrics.DecorateDeleteRange(c, *b.shardId))
 	default:
 		panic("invalid call")
 	}
 	b.byteSize += getByteSize(call)
+	b.idempotent = b.idempotent && isIdempotent(call)
 }
 
 func (b *writeBatch) Size() int {
@@ -93,36 +100,87 @@ func (b *writeBatch) Size() int {
 }
 
 func (b *writeBatch) Complete() {
+	b.removeCanceled()
 	if b.Size() == 0 {
 		return
 	}
 	executionStart := time.Now()
-	request := b.toProto()
 
-	response, err := b.doRequestWithRetries(request)
+	request, response, err := b.doRequestWithRetries(b.toProto())
 
 	b.callback(executionStart, request, response, err)
 
 	if err != nil {
+		// The calls that have expired fail with the error of their context
+		b.removeCanceled()
 		b.Fail(err)
 	} else {
 		b.handle(response)
 	}
 }
 
-func (b *writeBatch) doRequestWithRetries(request *proto.WriteRequest) (response *proto.WriteResponse, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), b.requestTimeout)
+// removeCanceled fails the calls that the callers have given up on, since
+// they don't need to be sent anymore.
+func (b *writeBatch) removeCanceled() {
+	b.puts = removeCanceled(b.puts, func(put model.PutCall, err error) {
+		put.Callback(nil, err)
+	})
+	b.deletes = removeCanceled(b.deletes, func(_delete model.DeleteCall, err error) {
+		_delete.Callback(nil, err)
+	})
+	b.deleteRanges = removeCanceled(b.deleteRanges, func(deleteRange model.DeleteRangeCall, err error) {
+		deleteRange.Callback(nil, err)
+	})
+}
+
+func (b *writeBatch) deadlines(defaultDeadline time.Time) *deadlines {
+	d := newDeadlines(defaultDeadline)
+	addDeadlines(d, b.puts)
+	addDeadlines(d, b.deletes)
+	addDeadlines(d, b.deleteRanges)
+	return d
+}
+
+// doRequestWithRetries sends the request until the latest deadline of the
+// calls. Each attempt is bounded by the earliest deadline, and the calls that
+// have expired are removed from the request before it's sent again. A
+// deduplicated request is sent again unchanged, since the server answers a
+// retry with the response of the first attempt.
+func (b *writeBatch) doRequestWithRetries(request *proto.WriteRequest) (
+	*proto.WriteRequest, *proto.WriteResponse, error) {
+	defaultDeadline := time.Now().Add(b.requestTimeout)
+	ctx, cancel := context.WithDeadline(context.Background(), b.deadlines(defaultDeadline).latest)
 	defer cancel()
 
 	backOff := common.NewBackOff(ctx)
 
-	err = backoff.RetryNotify(func() error {
-		response, err = b.execute(ctx, request)
-		if !isRetriable(err) {
+	var response *proto.WriteResponse
+	deduplicated := isDeduplicated(request)
+	idempotent := b.idempotent || deduplicated
+	attempt := 0
+	err := backoff.RetryNotify(func() error {
+		if attempt++; attempt > 1 && !deduplicated {
+			b.removeCanceled()
+			if b.Size() == 0 {
+				return backoff.Permanent(context.DeadlineExceeded)
+			}
+			request = b.toProto()
+		}
+
+		attemptCtx, attemptCancel := context.WithDeadline(ctx, b.deadlines(defaultDeadline).earliest)
+		defer attemptCancel()
+
+		var err error
+		response, err = b.execute(attemptCtx, request)
+		if isExpiredAttempt(ctx, attemptCtx, err) && (idempotent || internal.IsRequestNotSent(err)) {
+			return err
+		}
+		if !isRetriableWrite(err, idempotent) {
 			return backoff.Permanent(err)
 		}
 		return err
 	}, backOff, func(err error, duration time.Duration) {
+		b.metrics.Retry("write", err)
 		slog.Debug(
 			"Failed to perform request, retrying later",
 			slog.Any("error", err),
@@ -132,7 +190,7 @@ func (b *writeBatch) doRequestWithRetries(request *proto.WriteRequest) (response
 		)
 	})
 
-	return response, err
+	return request, response, err
 }
 
 func (b *writeBatch) Fail(err error) {
@@ -168,12 +226,34 @@ func (b *writeBatch) toProto() *proto.WriteRequest {
 	}
 }
 
+// isIdempotent tells whether the operation can be sent again without being
+// applied twice. Only the writes conditional on the version are: if the first
+// attempt was applied, the version has changed and the retry fails instead.
+// The unconditional writes would overwrite the changes made in between, and
+// the puts of sequential keys would create new keys.
+func isIdempotent(call any) bool {
+	switch c := call.(type) {
+	case model.PutCall:
+		return c.ExpectedVersionId != nil && len(c.SequenceKeysDeltas) == 0
+	case model.DeleteCall:
+		return c.ExpectedVersionId != nil
+	default:
+		return false
+	}
+}
+
+// isDeduplicated tells whether the server deduplicates the request within a
+// session, in which case a retry gets the response of the first attempt.
+func isDeduplicated(request *proto.WriteRequest) bool {
+	return request.SessionId != nil && request.Sequence != nil
+}
+
 func getByteSize(call any) int {
 	switch c := call.(type) {
 	case model.PutCall:
-		return len(c.Key) + len(c.Value)
+		return len(c.Key) + len(c.Value) + len(c.ExpectedValue)
 	case model.DeleteCall:
-		return len(c.Key)
+		return len(c.Key) + len(c.ExpectedValue)
 	case model.DeleteRangeCall:
 		return len(c.MinKeyInclusive) + len(c.MaxKeyExclusive)
 	default:
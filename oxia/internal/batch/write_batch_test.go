@@ -20,9 +20,14 @@ import (
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/otel/metric/noop"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
 
 	"github.com/streamnative/oxia/oxia/internal/metrics"
 	"github.com/streamnative/oxia/oxia/internal/model"
@@ -249,3 +254,191 @@ func TestWriteBatchCanAdd(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteBatchRetries(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	putCall := func(callback func(*proto.PutResponse, error)) model.PutCall {
+		return model.PutCall{Key: "/a", Value: []byte{0}, Callback: callback}
+	}
+	conditionalPutCall := func(callback func(*proto.PutResponse, error)) model.PutCall {
+		return model.PutCall{Key: "/a", Value: []byte{0}, ExpectedVersionId: &one, Callback: callback}
+	}
+
+	for _, item := range []struct {
+		name            string
+		call            func(callback func(*proto.PutResponse, error)) model.PutCall
+		err             error
+		expectedRetried bool
+	}{
+		{"idempotent", conditionalPutCall, unavailable, true},
+		{"not idempotent", putCall, unavailable, false},
+		{"not idempotent rejected", putCall, common.ErrorNodeIsNotLeader, true},
+		{"not idempotent fenced", putCall, common.ErrorInvalidStatus, false},
+		{"not retriable", conditionalPutCall, io.EOF, false},
+	} {
+		t.Run(item.name, func(t *testing.T) {
+			attempts := 0
+			execute := func(ctx context.Context, request *proto.WriteRequest) (*proto.WriteResponse, error) {
+				attempts++
+				if attempts == 1 {
+					return nil, item.err
+				}
+				return &proto.WriteResponse{Puts: []*proto.PutResponse{{Status: proto.Status_OK}}}, nil
+			}
+
+			factory := &writeBatchFactory{
+				execute:        execute,
+				metrics:        metrics.NewMetrics(noop.NewMeterProvider()),
+				requestTimeout: 10 * time.Second,
+				maxByteSize:    1024,
+			}
+			batch := factory.newBatch(&shardId)
+
+			var putErr error
+			batch.Add(item.call(func(_ *proto.PutResponse, err error) {
+				putErr = err
+			}))
+			batch.Complete()
+
+			if item.expectedRetried {
+				assert.Equal(t, 2, attempts)
+				assert.NoError(t, putErr)
+			} else {
+				assert.Equal(t, 1, attempts)
+				assert.ErrorIs(t, putErr, item.err)
+			}
+		})
+	}
+}
+
+func TestWriteBatchRetriesDeduplicated(t *testing.T) {
+	var requests []*proto.WriteRequest
+	factory := &writeBatchFactory{
+		execute: func(ctx context.Context, request *proto.WriteRequest) (*proto.WriteResponse, error) {
+			requests = append(requests, request)
+			if len(requests) == 1 {
+				return nil, status.Error(codes.Unavailable, "unavailable")
+			}
+			return &proto.WriteResponse{Puts: []*proto.PutResponse{{Status: proto.Status_OK}}}, nil
+		},
+		metrics:        metrics.NewMetrics(noop.NewMeterProvider()),
+		requestTimeout: 10 * time.Second,
+		maxByteSize:    1024,
+	}
+	b := factory.newBatch(&shardId).(*writeBatch)
+	b.Add(model.PutCall{Key: "/a", Value: []byte{0}, Callback: func(*proto.PutResponse, error) {}})
+
+	// The plain put is retried, since the server deduplicates the request
+	sequence := uint64(1)
+	request := b.toProto()
+	request.SessionId = &one
+	request.Sequence = &sequence
+	_, response, err := b.doRequestWithRetries(request)
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, response.Puts[0].Status)
+
+	assert.Len(t, requests, 2)
+	assert.Same(t, requests[0], requests[1])
+}
+
+func TestWriteBatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var requests []*proto.WriteRequest
+	factory := &writeBatchFactory{
+		execute: func(ctx context.Context, request *proto.WriteRequest) (*proto.WriteResponse, error) {
+			requests = append(requests, request)
+			return &proto.WriteResponse{Puts: []*proto.PutResponse{{Status: proto.Status_OK}}}, nil
+		},
+		metrics:        metrics.NewMetrics(noop.NewMeterProvider()),
+		requestTimeout: 10 * time.Second,
+		maxByteSize:    1024,
+	}
+	batch := factory.newBatch(&shardId)
+
+	var canceledErr, putErr error
+	batch.Add(model.PutCall{Key: "/a", Context: ctx, Callback: func(_ *proto.PutResponse, err error) {
+		canceledErr = err
+	}})
+	batch.Add(model.PutCall{Key: "/b", Context: context.Background(), Callback: func(_ *proto.PutResponse, err error) {
+		putErr = err
+	}})
+	batch.Complete()
+
+	assert.ErrorIs(t, canceledErr, context.Canceled)
+	assert.NoError(t, putErr)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, []*proto.PutRequest{{Key: "/b"}}, requests[0].Puts)
+
+	// Nothing is sent when all the calls were canceled
+	batch = factory.newBatch(&shardId)
+	batch.Add(model.DeleteCall{Key: "/a", Context: ctx, Callback: func(_ *proto.DeleteResponse, err error) {
+		canceledErr = err
+	}})
+	batch.Complete()
+	assert.ErrorIs(t, canceledErr, context.Canceled)
+	assert.Len(t, requests, 1)
+}
+
+func TestWriteBatchDeadlines(t *testing.T) {
+	shortCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var requests []*proto.WriteRequest
+	factory := &writeBatchFactory{
+		execute: func(ctx context.Context, request *proto.WriteRequest) (*proto.WriteResponse, error) {
+			requests = append(requests, request)
+			if len(requests) == 1 {
+				// The first attempt lasts until the earliest deadline
+				deadline, ok := ctx.Deadline()
+				assert.True(t, ok)
+				shortDeadline, _ := shortCtx.Deadline()
+				assert.Equal(t, shortDeadline, deadline)
+
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return &proto.WriteResponse{Puts: []*proto.PutResponse{{Status: proto.Status_OK}}}, nil
+		},
+		metrics:        metrics.NewMetrics(noop.NewMeterProvider()),
+		requestTimeout: 10 * time.Second,
+		maxByteSize:    1024,
+	}
+	batch := factory.newBatch(&shardId)
+
+	var expiredErr, putErr error
+	batch.Add(model.PutCall{Key: "/a", ExpectedVersionId: &one, Context: shortCtx,
+		Callback: func(_ *proto.PutResponse, err error) {
+			expiredErr = err
+		}})
+	batch.Add(model.PutCall{Key: "/b", ExpectedVersionId: &one, Context: context.Background(),
+		Callback: func(_ *proto.PutResponse, err error) {
+			putErr = err
+		}})
+	batch.Complete()
+
+	// The expired call fails, while the other one is sent again
+	assert.ErrorIs(t, expiredErr, context.DeadlineExceeded)
+	assert.NoError(t, putErr)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, []*proto.PutRequest{{Key: "/b", ExpectedVersionId: &one}}, requests[1].Puts)
+
+	// A call that is not idempotent is not sent again, since the first
+	// request could have been applied
+	shortCtx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	requests = nil
+	batch = factory.newBatch(&shardId)
+	batch.Add(model.PutCall{Key: "/a", Context: shortCtx, Callback: func(_ *proto.PutResponse, err error) {
+		expiredErr = err
+	}})
+	batch.Add(model.PutCall{Key: "/b", Context: context.Background(), Callback: func(_ *proto.PutResponse, err error) {
+		putErr = err
+	}})
+	batch.Complete()
+
+	assert.ErrorIs(t, expiredErr, context.DeadlineExceeded)
+	assert.ErrorIs(t, putErr, context.DeadlineExceeded)
+	assert.Len(t, requests, 1)
+}
@@ -16,10 +16,15 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/proto"
@@ -41,14 +46,17 @@ type executorImpl struct {
 
 	writeStreams map[int64]*streamWrapper
 
-	ctx       context.Context
-	namespace string
+	ctx           context.Context
+	namespace     string
+	streamTimeout time.Duration
 }
 
-func NewExecutor(ctx context.Context, namespace string, pool common.ClientPool, manager ShardManager, serviceAddress string) Executor {
+func NewExecutor(ctx context.Context, namespace string, pool common.ClientPool, manager ShardManager, serviceAddress string,
+	streamTimeout time.Duration) Executor {
 	e := &executorImpl{
 		ctx:            ctx,
 		namespace:      namespace,
+		streamTimeout:  streamTimeout,
 		ClientPool:     pool,
 		ShardManager:   manager,
 		ServiceAddress: serviceAddress,
@@ -59,25 +67,36 @@ func NewExecutor(ctx context.Context, namespace string, pool common.ClientPool,
 }
 
 func (e *executorImpl) ExecuteWrite(ctx context.Context, request *proto.WriteRequest) (*proto.WriteResponse, error) {
-	sw, err := e.writeStream(request.Shard) //nolint:contextcheck
+	sw, err := e.writeStream(ctx, request.Shard)
 	if err != nil {
-		return nil, err
+		return nil, &requestNotSentError{err}
 	}
 
-	return sw.Send(ctx, request)
+	response, err := sw.Send(ctx, request)
+	if err != nil {
+		e.checkLeader(*request.Shard, sw.target, err)
+	}
+	return response, err
 }
 
 func (e *executorImpl) ExecuteRead(ctx context.Context, request *proto.ReadRequest) (proto.OxiaClient_ReadClient, error) {
-	rpc, err := e.rpc(request.Shard)
+	rpc, target, err := e.readRpc(ctx, request.Shard, request.Consistency)
 	if err != nil {
 		return nil, err
 	}
 
-	return rpc.Read(ctx, request)
+	stream, err := rpc.Read(ctx, request)
+	if err != nil {
+		e.checkLeader(*request.Shard, target, err)
+		return nil, err
+	}
+	return &readClient{stream, func(err error) {
+		e.checkLeader(*request.Shard, target, err)
+	}}, nil
 }
 
 func (e *executorImpl) ExecuteList(ctx context.Context, request *proto.ListRequest) (proto.OxiaClient_ListClient, error) {
-	rpc, err := e.rpc(request.Shard)
+	rpc, _, err := e.streamRpc(ctx, request.Shard, request.Consistency)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +105,7 @@ func (e *executorImpl) ExecuteList(ctx context.Context, request *proto.ListReque
 }
 
 func (e *executorImpl) ExecuteRangeScan(ctx context.Context, request *proto.RangeScanRequest) (proto.OxiaClient_RangeScanClient, error) {
-	rpc, err := e.rpc(request.Shard)
+	rpc, _, err := e.streamRpc(ctx, request.Shard, request.Consistency)
 	if err != nil {
 		return nil, err
 	}
@@ -94,22 +113,90 @@ func (e *executorImpl) ExecuteRangeScan(ctx context.Context, request *proto.Rang
 	return rpc.RangeScan(ctx, request)
 }
 
-func (e *executorImpl) rpc(shardId *int64) (proto.OxiaClientClient, error) {
-	var target string
+// streamRpc returns the client for a stream that lasts as long as the
+// context. When the context has no deadline, the wait for the shard leader is
+// bounded by the stream timeout.
+func (e *executorImpl) streamRpc(ctx context.Context, shardId *int64, consistency proto.ReadConsistency) (proto.OxiaClientClient, string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.streamTimeout)
+		defer cancel()
+	}
+	return e.readRpc(ctx, shardId, consistency)
+}
+
+// rpc returns the client of the shard leader, waiting for the shard to have
+// one, and the address of the leader.
+func (e *executorImpl) rpc(ctx context.Context, shardId *int64) (proto.OxiaClientClient, string, error) {
+	target := e.ServiceAddress
 	if shardId != nil {
-		target = e.ShardManager.Leader(*shardId)
-	} else {
-		target = e.ServiceAddress
+		var err error
+		if target, err = e.ShardManager.WaitForLeader(ctx, *shardId); err != nil {
+			return nil, "", err
+		}
 	}
 
 	rpc, err := e.ClientPool.GetClientRpc(target)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	return rpc, target, nil
+}
+
+// Reads that don't require linearizable consistency are spread across
+// all the replicas of the shard, instead of going always to the leader.
+func (e *executorImpl) readRpc(ctx context.Context, shardId *int64, consistency proto.ReadConsistency) (proto.OxiaClientClient, string, error) {
+	if shardId == nil || consistency == proto.ReadConsistency_LINEARIZABLE {
+		return e.rpc(ctx, shardId)
+	}
+
+	replicas := e.ShardManager.Replicas(*shardId)
+	if len(replicas) == 0 {
+		return e.rpc(ctx, shardId)
+	}
+
+	target := replicas[rand.Intn(len(replicas))] //nolint:gosec
+	rpc, err := e.ClientPool.GetClientRpc(target)
+	if err != nil {
+		return nil, "", err
+	}
+	return rpc, target, nil
+}
+
+// checkLeader invalidates the leader of the shard when the server has
+// rejected the request because it's not the leader anymore, or because it
+// was fenced by a new leader. The error can carry the new leader.
+func (e *executorImpl) checkLeader(shardId int64, target string, err error) {
+	switch status.Code(err) {
+	case common.CodeNodeIsNotLeader, common.CodeInvalidStatus, common.CodeInvalidTerm:
+		e.ShardManager.InvalidateLeader(shardId, target, leaderHint(err))
+	}
+}
+
+func leaderHint(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if assignment, ok := detail.(*proto.ShardAssignment); ok {
+			return assignment.Leader
+		}
+	}
+	return ""
+}
+
+// readClient checks the errors returned by the read stream.
+type readClient struct {
+	proto.OxiaClient_ReadClient
+	onError func(error)
+}
+
+func (r *readClient) Recv() (*proto.ReadResponse, error) {
+	response, err := r.OxiaClient_ReadClient.Recv()
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.onError(err)
 	}
-	return rpc, nil
+	return response, err
 }
 
-func (e *executorImpl) writeStream(shardId *int64) (*streamWrapper, error) {
+func (e *executorImpl) writeStream(ctx context.Context, shardId *int64) (*streamWrapper, error) {
 	e.RLock()
 
 	sw, ok := e.writeStreams[*shardId]
@@ -120,20 +207,20 @@ func (e *executorImpl) writeStream(shardId *int64) (*streamWrapper, error) {
 
 	e.RUnlock()
 
-	rpc, err := e.rpc(shardId)
+	rpc, target, err := e.rpc(ctx, shardId)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx := metadata.AppendToOutgoingContext(e.ctx, common.MetadataNamespace, e.namespace)
-	ctx = metadata.AppendToOutgoingContext(ctx, common.MetadataShardId, fmt.Sprintf("%d", *shardId))
+	streamCtx := metadata.AppendToOutgoingContext(e.ctx, common.MetadataNamespace, e.namespace)
+	streamCtx = metadata.AppendToOutgoingContext(streamCtx, common.MetadataShardId, fmt.Sprintf("%d", *shardId))
 
-	stream, err := rpc.WriteStream(ctx)
+	stream, err := rpc.WriteStream(streamCtx) //nolint:contextcheck
 	if err != nil {
 		return nil, err
 	}
 
-	sw = newStreamWrapper(stream)
+	sw = newStreamWrapper(stream, target)
 
 	e.Lock()
 	defer e.Unlock()
@@ -20,6 +20,7 @@ import (
 
 	"go.opentelemetry.io/otel/metric"
 
+	"github.com/streamnative/oxia/common/batch"
 	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/oxia/internal/model"
 	"github.com/streamnative/oxia/proto"
@@ -36,6 +37,11 @@ type Metrics struct {
 	batchExecTime  Timer
 	batchValue     metric.Int64Histogram
 	batchRequests  metric.Int64Histogram
+
+	batchFlushes metric.Int64Counter
+	retries      metric.Int64Counter
+
+	assignmentsUpdates metric.Int64Counter
 }
 
 func NewMetrics(provider metric.MeterProvider) *Metrics {
@@ -55,12 +61,17 @@ func newMetrics(provider metric.MeterProvider, timeFunc func() time.Time, sinceF
 		batchExecTime:  newTimer(meter, "oxia_client_batch_exec"),
 		batchValue:     newHistogram(meter, "oxia_client_batch_value", metrics.Bytes),
 		batchRequests:  newHistogram(meter, "oxia_client_batch_request", ""),
+
+		batchFlushes: newCounter(meter, "oxia_client_batch_flush", ""),
+		retries:      newCounter(meter, "oxia_client_retries", ""),
+
+		assignmentsUpdates: newCounter(meter, "oxia_client_shard_assignments_updates", ""),
 	}
 }
 
-func (m *Metrics) DecoratePut(put model.PutCall) model.PutCall {
+func (m *Metrics) DecoratePut(put model.PutCall, shardId int64) model.PutCall {
 	callback := put.Callback
-	metricContext := m.metricContextFunc("put")
+	metricContext := m.metricContextFunc("put", shardId)
 	put.Callback = func(response *proto.PutResponse, err error) {
 		callback(response, err)
 		ctx, start, _attrs := metricContext(err)
@@ -70,9 +81,9 @@ func (m *Metrics) DecoratePut(put model.PutCall) model.PutCall {
 	return put
 }
 
-func (m *Metrics) DecorateDelete(deleteCall model.DeleteCall) model.DeleteCall {
+func (m *Metrics) DecorateDelete(deleteCall model.DeleteCall, shardId int64) model.DeleteCall {
 	callback := deleteCall.Callback
-	metricContext := m.metricContextFunc("delete")
+	metricContext := m.metricContextFunc("delete", shardId)
 	deleteCall.Callback = func(response *proto.DeleteResponse, err error) {
 		callback(response, err)
 		ctx, start, _attrs := metricContext(err)
@@ -81,9 +92,9 @@ func (m *Metrics) DecorateDelete(deleteCall model.DeleteCall) model.DeleteCall {
 	return deleteCall
 }
 
-func (m *Metrics) DecorateDeleteRange(deleteRange model.DeleteRangeCall) model.DeleteRangeCall {
+func (m *Metrics) DecorateDeleteRange(deleteRange model.DeleteRangeCall, shardId int64) model.DeleteRangeCall {
 	callback := deleteRange.Callback
-	metricContext := m.metricContextFunc("delete_range")
+	metricContext := m.metricContextFunc("delete_range", shardId)
 	deleteRange.Callback = func(response *proto.DeleteRangeResponse, err error) {
 		callback(response, err)
 		ctx, start, _attrs := metricContext(err)
@@ -92,9 +103,9 @@ func (m *Metrics) DecorateDeleteRange(deleteRange model.DeleteRangeCall) model.D
 	return deleteRange
 }
 
-func (m *Metrics) DecorateGet(get model.GetCall) model.GetCall {
+func (m *Metrics) DecorateGet(get model.GetCall, shardId int64) model.GetCall {
 	callback := get.Callback
-	metricContext := m.metricContextFunc("get")
+	metricContext := m.metricContextFunc("get", shardId)
 	get.Callback = func(response *proto.GetResponse, err error) {
 		callback(response, err)
 		ctx, start, _attrs := metricContext(err)
@@ -108,8 +119,8 @@ func (m *Metrics) DecorateGet(get model.GetCall) model.GetCall {
 	return get
 }
 
-func (m *Metrics) WriteCallback() func(time.Time, *proto.WriteRequest, *proto.WriteResponse, error) {
-	metricContext := m.metricContextFunc("write")
+func (m *Metrics) WriteCallback(shardId int64) func(time.Time, *proto.WriteRequest, *proto.WriteResponse, error) {
+	metricContext := m.metricContextFunc("write", shardId)
 	return func(executionStart time.Time, request *proto.WriteRequest, _ *proto.WriteResponse, err error) {
 		ctx, batchStart, _attrs := metricContext(err)
 		m.batchTotalTime.Record(ctx, m.sinceFunc(batchStart), _attrs)
@@ -120,8 +131,8 @@ func (m *Metrics) WriteCallback() func(time.Time, *proto.WriteRequest, *proto.Wr
 	}
 }
 
-func (m *Metrics) ReadCallback() func(time.Time, *proto.ReadRequest, *proto.ReadResponse, error) {
-	metricContext := m.metricContextFunc("read")
+func (m *Metrics) ReadCallback(shardId int64) func(time.Time, *proto.ReadRequest, *proto.ReadResponse, error) {
+	metricContext := m.metricContextFunc("read", shardId)
 	return func(executionStart time.Time, _ *proto.ReadRequest, response *proto.ReadResponse, err error) {
 		ctx, batchStart, attrs := metricContext(err)
 		m.batchTotalTime.Record(ctx, m.sinceFunc(batchStart), attrs)
@@ -132,9 +143,28 @@ func (m *Metrics) ReadCallback() func(time.Time, *proto.ReadRequest, *proto.Read
 	}
 }
 
-func (m *Metrics) metricContextFunc(requestType string) func(error) (context.Context, time.Time, metric.MeasurementOption) {
+// Retry records that a batch is sent again, after it failed with the error.
+func (m *Metrics) Retry(requestType string, err error) {
+	m.retries.Add(context.TODO(), 1, retryAttrs(requestType, err))
+}
+
+// BatchFlushed returns the listener that records why the batches of the type
+// are sent.
+func (m *Metrics) BatchFlushed(requestType string, shardId int64) func(batch.FlushReason) {
+	return func(reason batch.FlushReason) {
+		m.batchFlushes.Add(context.TODO(), 1, flushAttrs(requestType, shardId, reason))
+	}
+}
+
+// AssignmentsUpdated records that the shard assignments were received, either
+// in full or as a delta of the previous ones.
+func (m *Metrics) AssignmentsUpdated(delta bool) {
+	m.assignmentsUpdates.Add(context.TODO(), 1, assignmentsAttrs(delta))
+}
+
+func (m *Metrics) metricContextFunc(requestType string, shardId int64) func(error) (context.Context, time.Time, metric.MeasurementOption) {
 	start := m.timeFunc()
 	return func(err error) (context.Context, time.Time, metric.MeasurementOption) {
-		return context.TODO(), start, attrs(requestType, err)
+		return context.TODO(), start, attrs(requestType, shardId, err)
 	}
 }
@@ -25,26 +25,31 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/streamnative/oxia/common/batch"
 	"github.com/streamnative/oxia/oxia/internal/model"
 	"github.com/streamnative/oxia/proto"
 )
 
+const testShard = 5
+
 func TestMetricsDecorate(t *testing.T) {
 	putFunc := func(metrics *Metrics, err error) {
-		metrics.DecoratePut(model.PutCall{Callback: func(*proto.PutResponse, error) {}, Value: []byte{0, 1, 2, 3, 4}}).
+		metrics.DecoratePut(model.PutCall{Callback: func(*proto.PutResponse, error) {}, Value: []byte{0, 1, 2, 3, 4}}, testShard).
 			Callback(&proto.PutResponse{}, err)
 	}
 	deleteFunc := func(metrics *Metrics, err error) {
-		metrics.DecorateDelete(model.DeleteCall{Callback: func(*proto.DeleteResponse, error) {}}).
+		metrics.DecorateDelete(model.DeleteCall{Callback: func(*proto.DeleteResponse, error) {}}, testShard).
 			Callback(&proto.DeleteResponse{}, err)
 	}
 	deleteRangeFunc := func(metrics *Metrics, err error) {
-		metrics.DecorateDeleteRange(model.DeleteRangeCall{Callback: func(*proto.DeleteRangeResponse, error) {}}).
+		metrics.DecorateDeleteRange(model.DeleteRangeCall{Callback: func(*proto.DeleteRangeResponse, error) {}}, testShard).
 			Callback(&proto.DeleteRangeResponse{}, err)
 	}
 	getFunc := func(metrics *Metrics, err error) {
-		metrics.DecorateGet(model.GetCall{Callback: func(*proto.GetResponse, error) {}}).
+		metrics.DecorateGet(model.GetCall{Callback: func(*proto.GetResponse, error) {}}, testShard).
 			Callback(&proto.GetResponse{Value: []byte{0, 1, 2, 3, 4}}, err)
 	}
 
@@ -91,12 +96,12 @@ func TestMetricsDecorate(t *testing.T) {
 
 func TestMetricsCallback(t *testing.T) {
 	writeFunc := func(metrics *Metrics, err error) {
-		metrics.WriteCallback()(time.Now(), &proto.WriteRequest{
+		metrics.WriteCallback(testShard)(time.Now(), &proto.WriteRequest{
 			Puts: []*proto.PutRequest{{Value: []byte{0, 1, 2, 3, 4}}},
 		}, &proto.WriteResponse{}, err)
 	}
 	readFunc := func(metrics *Metrics, err error) {
-		metrics.ReadCallback()(time.Now(), &proto.ReadRequest{}, &proto.ReadResponse{
+		metrics.ReadCallback(testShard)(time.Now(), &proto.ReadRequest{}, &proto.ReadResponse{
 			Gets: []*proto.GetResponse{{Value: []byte{0, 1, 2, 3, 4}}},
 		}, err)
 	}
@@ -138,6 +143,71 @@ func TestMetricsCallback(t *testing.T) {
 	}
 }
 
+func TestMetricsRetry(t *testing.T) {
+	metrics, reader := setup(time.Since)
+
+	metrics.Retry("write", status.Error(codes.Unavailable, "unavailable"))
+	metrics.Retry("write", status.Error(codes.Unavailable, "unavailable"))
+
+	rm := metricdata.ResourceMetrics{}
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+
+	datapoints, err := counter[int64](rm, "oxia_client_retries")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(datapoints))
+	assert.EqualValues(t, 2, datapoints[0].Value)
+	assertAttribute(t, datapoints[0].Attributes, "type", "write")
+	assertAttribute(t, datapoints[0].Attributes, "reason", codes.Unavailable.String())
+}
+
+func TestMetricsBatchFlushed(t *testing.T) {
+	metrics, reader := setup(time.Since)
+
+	metrics.BatchFlushed("write", testShard)(batch.FlushReasonLinger)
+	metrics.BatchFlushed("write", testShard)(batch.FlushReasonLinger)
+	metrics.BatchFlushed("write", testShard)(batch.FlushReasonFull)
+
+	rm := metricdata.ResourceMetrics{}
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+
+	datapoints, err := counter[int64](rm, "oxia_client_batch_flush")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(datapoints))
+	for _, dp := range datapoints {
+		assertAttribute(t, dp.Attributes, "type", "write")
+		assertShard(t, dp.Attributes)
+		reason, _ := dp.Attributes.Value("reason")
+		switch batch.FlushReason(reason.AsString()) {
+		case batch.FlushReasonLinger:
+			assert.EqualValues(t, 2, dp.Value)
+		case batch.FlushReasonFull:
+			assert.EqualValues(t, 1, dp.Value)
+		default:
+			assert.Fail(t, "unexpected reason", reason.AsString())
+		}
+	}
+}
+
+func TestMetricsAssignmentsUpdated(t *testing.T) {
+	metrics, reader := setup(time.Since)
+
+	metrics.AssignmentsUpdated(false)
+	metrics.AssignmentsUpdated(true)
+	metrics.AssignmentsUpdated(true)
+
+	rm := metricdata.ResourceMetrics{}
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+
+	datapoints, err := counter[int64](rm, "oxia_client_shard_assignments_updates")
+	assert.NoError(t, err)
+	values := map[string]int64{}
+	for _, dp := range datapoints {
+		update, _ := dp.Attributes.Value("type")
+		values[update.AsString()] = dp.Value
+	}
+	assert.Equal(t, map[string]int64{"full": 1, "delta": 2}, values)
+}
+
 func setup(sinceFunc func(time.Time) time.Duration) (*Metrics, metric.Reader) {
 	reader := metric.NewManualReader()
 	provider := metric.NewMeterProvider(metric.WithReader(reader))
@@ -215,10 +285,20 @@ func assertDataPoints[N int64 | float64](
 func assertAttributes(t *testing.T, attrs attribute.Set, expectedType string, expectedResult string) {
 	t.Helper()
 
-	assert.Equal(t, 2, attrs.Len())
+	assert.Equal(t, 3, attrs.Len())
 	assertAttribute(t, attrs, "type", expectedType)
 	assertAttribute(t, attrs, "result", expectedResult)
+	assertShard(t, attrs)
 }
+
+func assertShard(t *testing.T, attrs attribute.Set) {
+	t.Helper()
+
+	value, ok := attrs.Value("shard")
+	assert.True(t, ok)
+	assert.EqualValues(t, testShard, value.AsInt64())
+}
+
 func assertAttribute(t *testing.T, attrs attribute.Set, key attribute.Key, expected string) {
 	t.Helper()
 
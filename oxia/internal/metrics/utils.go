@@ -20,7 +20,9 @@ import (
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/status"
 
+	"github.com/streamnative/oxia/common/batch"
 	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/proto"
 )
@@ -55,13 +57,37 @@ func fatalOnErr(err error, name string) {
 	}
 }
 
-func attrs(requestType string, err error) metric.MeasurementOption {
+func attrs(requestType string, shardId int64, err error) metric.MeasurementOption {
 	return metric.WithAttributes(
 		attribute.Key("type").String(requestType),
+		attribute.Key("shard").Int64(shardId),
 		attribute.Key("result").String(result(err)),
 	)
 }
 
+func flushAttrs(requestType string, shardId int64, reason batch.FlushReason) metric.MeasurementOption {
+	return metric.WithAttributes(
+		attribute.Key("type").String(requestType),
+		attribute.Key("shard").Int64(shardId),
+		attribute.Key("reason").String(string(reason)),
+	)
+}
+
+func assignmentsAttrs(delta bool) metric.MeasurementOption {
+	update := "full"
+	if delta {
+		update = "delta"
+	}
+	return metric.WithAttributes(attribute.Key("type").String(update))
+}
+
+func retryAttrs(requestType string, err error) metric.MeasurementOption {
+	return metric.WithAttributes(
+		attribute.Key("type").String(requestType),
+		attribute.Key("reason").String(status.Code(err).String()),
+	)
+}
+
 func result(err error) string {
 	if err == nil {
 		return "success"
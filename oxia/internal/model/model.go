@@ -15,6 +15,9 @@
 package model
 
 import (
+	"context"
+	"time"
+
 	"github.com/streamnative/oxia/proto"
 )
 
@@ -22,40 +25,105 @@ type PutCall struct {
 	Key                string
 	Value              []byte
 	ExpectedVersionId  *int64
+	ExpectedValue      []byte
 	SequenceKeysDeltas []uint64
 	SessionId          *int64
 	ClientIdentity     *string
 	PartitionKey       *string
+	ExpireAfterMs      *uint64
+	Context            context.Context
 	Callback           func(*proto.PutResponse, error)
 }
 
 type DeleteCall struct {
 	Key               string
 	ExpectedVersionId *int64
+	ExpectedValue     []byte
+	Context           context.Context
 	Callback          func(*proto.DeleteResponse, error)
 }
 
 type DeleteRangeCall struct {
 	MinKeyInclusive string
 	MaxKeyExclusive string
+	Context         context.Context
 	Callback        func(*proto.DeleteRangeResponse, error)
 }
 
 type GetCall struct {
 	Key            string
 	ComparisonType proto.KeyComparisonType
+	ExcludeValue   bool
+	MaxValueSize   *uint64
+	Context        context.Context
 	Callback       func(*proto.GetResponse, error)
 }
 
+// GetCalls are gets on the same shard that are added to the batch together,
+// so that they are sent in the same request.
+type GetCalls []GetCall
+
+// ContextErr returns the error of the context of the call, once the caller
+// has given up on it.
+func (r PutCall) ContextErr() error {
+	return contextErr(r.Context)
+}
+
+func (r DeleteCall) ContextErr() error {
+	return contextErr(r.Context)
+}
+
+func (r DeleteRangeCall) ContextErr() error {
+	return contextErr(r.Context)
+}
+
+func (r GetCall) ContextErr() error {
+	return contextErr(r.Context)
+}
+
+func contextErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// ContextDeadline returns the deadline of the context of the call, if the
+// caller has set one.
+func (r PutCall) ContextDeadline() (time.Time, bool) {
+	return contextDeadline(r.Context)
+}
+
+func (r DeleteCall) ContextDeadline() (time.Time, bool) {
+	return contextDeadline(r.Context)
+}
+
+func (r DeleteRangeCall) ContextDeadline() (time.Time, bool) {
+	return contextDeadline(r.Context)
+}
+
+func (r GetCall) ContextDeadline() (time.Time, bool) {
+	return contextDeadline(r.Context)
+}
+
+func contextDeadline(ctx context.Context) (time.Time, bool) {
+	if ctx == nil {
+		return time.Time{}, false
+	}
+	return ctx.Deadline()
+}
+
 func (r PutCall) ToProto() *proto.PutRequest {
 	return &proto.PutRequest{
 		Key:               r.Key,
 		Value:             r.Value,
 		ExpectedVersionId: r.ExpectedVersionId,
+		ExpectedValue:     r.ExpectedValue,
 		SessionId:         r.SessionId,
 		ClientIdentity:    r.ClientIdentity,
 		PartitionKey:      r.PartitionKey,
 		SequenceKeyDelta:  r.SequenceKeysDeltas,
+		ExpireAfterMs:     r.ExpireAfterMs,
 	}
 }
 
@@ -63,6 +131,7 @@ func (r DeleteCall) ToProto() *proto.DeleteRequest {
 	return &proto.DeleteRequest{
 		Key:               r.Key,
 		ExpectedVersionId: r.ExpectedVersionId,
+		ExpectedValue:     r.ExpectedValue,
 	}
 }
 
@@ -77,7 +146,8 @@ func (r GetCall) ToProto() *proto.GetRequest {
 	return &proto.GetRequest{
 		Key:            r.Key,
 		ComparisonType: r.ComparisonType,
-		IncludeValue:   true,
+		IncludeValue:   !r.ExcludeValue,
+		MaxValueSize:   r.MaxValueSize,
 	}
 }
 
@@ -27,6 +27,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/oxia/internal/metrics"
 	"github.com/streamnative/oxia/proto"
 )
 
@@ -35,11 +36,33 @@ type ShardManager interface {
 	Get(key string) int64
 	GetAll() []int64
 	Leader(shardId int64) string
+	Replicas(shardId int64) []string
+
+	// WaitForShards waits until all the shards are part of the assignments.
+	WaitForShards(ctx context.Context, shardIds []int64) error
+
+	// WaitForLeader waits until the shard has a leader and returns it. It
+	// fails with ErrorShardSplit if the shard is not part of the assignments
+	// anymore.
+	WaitForLeader(ctx context.Context, shardId int64) (string, error)
+
+	// InvalidateLeader tells that the server has rejected the requests to
+	// the shard, because it's not the leader anymore. The requests are sent
+	// to the hinted leader, if any, or wait until the assignments, which are
+	// refreshed, have a new leader.
+	InvalidateLeader(shardId int64, leader string, hint string)
 }
 
+// minAssignmentsRefreshInterval limits how often the assignments are
+// refreshed, for when many shards are moving at the same time.
+const minAssignmentsRefreshInterval = 1 * time.Second
+
+var errAssignmentsRefreshed = errors.New("the shard assignments are refreshed")
+
 type shardManagerImpl struct {
 	sync.RWMutex
-	updatedWg common.WaitGroup
+	updatedWg     common.WaitGroup
+	shardsUpdated common.ConditionContext
 
 	shardStrategy  ShardStrategy
 	clientPool     common.ClientPool
@@ -50,10 +73,17 @@ type shardManagerImpl struct {
 	cancel         context.CancelFunc
 	logger         *slog.Logger
 	requestTimeout time.Duration
+	metrics        *metrics.Metrics
+
+	// streamCancel closes the current assignments stream, so that it's
+	// opened again with all the assignments
+	streamCancel context.CancelFunc
+	lastRefresh  time.Time
+	refreshTimer *time.Timer
 }
 
 func NewShardManager(shardStrategy ShardStrategy, clientPool common.ClientPool,
-	serviceAddress string, namespace string, requestTimeout time.Duration) (ShardManager, error) {
+	serviceAddress string, namespace string, requestTimeout time.Duration, metrics *metrics.Metrics) (ShardManager, error) {
 	sm := &shardManagerImpl{
 		namespace:      namespace,
 		shardStrategy:  shardStrategy,
@@ -61,12 +91,14 @@ func NewShardManager(shardStrategy ShardStrategy, clientPool common.ClientPool,
 		serviceAddress: serviceAddress,
 		shards:         make(map[int64]Shard),
 		requestTimeout: requestTimeout,
+		metrics:        metrics,
 		logger: slog.With(
 			slog.String("component", "shardManager"),
 		),
 	}
 
 	sm.updatedWg = common.NewWaitGroup(1)
+	sm.shardsUpdated = common.NewConditionContext(sm)
 	sm.ctx, sm.cancel = context.WithCancel(context.Background())
 
 	if err := sm.start(); err != nil {
@@ -78,6 +110,12 @@ func NewShardManager(shardStrategy ShardStrategy, clientPool common.ClientPool,
 
 func (s *shardManagerImpl) Close() error {
 	s.cancel()
+
+	s.Lock()
+	if s.refreshTimer != nil {
+		s.refreshTimer.Stop()
+	}
+	s.Unlock()
 	return nil
 }
 
@@ -124,6 +162,9 @@ func (s *shardManagerImpl) GetAll() []int64 {
 	return shardIDs
 }
 
+// Leader returns the address of the shard leader, or an empty string if
+// the shard has no leader, or if it's not part of the assignments anymore,
+// after it was split.
 func (s *shardManagerImpl) Leader(shardId int64) string {
 	s.RLock()
 	defer s.RUnlock()
@@ -131,7 +172,118 @@ func (s *shardManagerImpl) Leader(shardId int64) string {
 	if shard, ok := s.shards[shardId]; ok {
 		return shard.Leader
 	}
-	panic("shard not found")
+	return ""
+}
+
+// Replicas returns the addresses of all the servers hosting the shard,
+// starting with the leader.
+func (s *shardManagerImpl) Replicas(shardId int64) []string {
+	s.RLock()
+	defer s.RUnlock()
+
+	if shard, ok := s.shards[shardId]; ok {
+		replicas := make([]string, 0, len(shard.Followers)+1)
+		if shard.Leader != "" {
+			replicas = append(replicas, shard.Leader)
+		}
+		return append(replicas, shard.Followers...)
+	}
+	return nil
+}
+
+func (s *shardManagerImpl) WaitForShards(ctx context.Context, shardIds []int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for !s.hasShards(shardIds) {
+		if err := s.shardsUpdated.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *shardManagerImpl) WaitForLeader(ctx context.Context, shardId int64) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for {
+		shard, ok := s.shards[shardId]
+		if !ok {
+			// The shard was replaced by the shards it was split into
+			return "", common.ErrorShardSplit
+		}
+		if shard.Leader != "" {
+			return shard.Leader, nil
+		}
+		if err := s.shardsUpdated.Wait(ctx); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (s *shardManagerImpl) InvalidateLeader(shardId int64, leader string, hint string) {
+	s.Lock()
+	defer s.Unlock()
+
+	shard, ok := s.shards[shardId]
+	if !ok || shard.Leader != leader || leader == "" {
+		// The assignments were already updated
+		return
+	}
+
+	if hint == leader {
+		hint = ""
+	}
+	s.logger.Info(
+		"The leader of the shard has changed, refreshing the assignments",
+		slog.Int64("shard", shardId),
+		slog.String("leader", leader),
+		slog.String("leader-hint", hint),
+	)
+	shard.Leader = hint
+	s.shards[shardId] = shard
+	s.shardsUpdated.Broadcast()
+	s.refresh()
+}
+
+// refresh opens the assignments stream again, to get all the assignments
+// from a server that might be more up to date. The refreshes are delayed
+// to happen at most once per interval.
+func (s *shardManagerImpl) refresh() {
+	if s.refreshTimer != nil {
+		// A refresh is already scheduled
+		return
+	}
+
+	delay := time.Until(s.lastRefresh.Add(minAssignmentsRefreshInterval))
+	if delay <= 0 {
+		s.doRefresh()
+		return
+	}
+
+	s.refreshTimer = time.AfterFunc(delay, func() {
+		s.Lock()
+		defer s.Unlock()
+		s.refreshTimer = nil
+		s.doRefresh()
+	})
+}
+
+func (s *shardManagerImpl) doRefresh() {
+	s.lastRefresh = time.Now()
+	if s.streamCancel != nil {
+		s.streamCancel()
+	}
+}
+
+func (s *shardManagerImpl) hasShards(shardIds []int64) bool {
+	for _, shardId := range shardIds {
+		if _, ok := s.shards[shardId]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *shardManagerImpl) isClosed() bool {
@@ -143,6 +295,9 @@ func (s *shardManagerImpl) receiveWithRecovery() {
 	err := backoff.RetryNotify(
 		func() error {
 			err := s.receive(backOff)
+			for errors.Is(err, errAssignmentsRefreshed) {
+				err = s.receive(backOff)
+			}
 			if s.isClosed() {
 				s.logger.Debug(
 					"Closed",
@@ -182,16 +337,31 @@ func (s *shardManagerImpl) receive(backOff backoff.BackOff) error {
 		return err
 	}
 
-	request := proto.ShardAssignmentsRequest{Namespace: s.namespace}
+	request := proto.ShardAssignmentsRequest{
+		Namespace:    s.namespace,
+		AcceptDeltas: true,
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	s.Lock()
+	s.streamCancel = cancel
+	s.Unlock()
 
-	stream, err := rpc.GetShardAssignments(s.ctx, &request)
+	stream, err := rpc.GetShardAssignments(ctx, &request)
 	if err != nil {
+		if ctx.Err() != nil && !s.isClosed() {
+			return errAssignmentsRefreshed
+		}
 		return err
 	}
 
 	for {
 		response, err := stream.Recv()
 		if err != nil {
+			if ctx.Err() != nil && !s.isClosed() {
+				return errAssignmentsRefreshed
+			}
 			return err
 		}
 
@@ -204,15 +374,39 @@ func (s *shardManagerImpl) receive(backOff backoff.BackOff) error {
 		for i, assignment := range assignments.Assignments {
 			shards[i] = toShard(assignment)
 		}
-		s.update(shards)
+		if response.Delta {
+			s.update(shards, assignments.RemovedShards)
+		} else {
+			// The first response in the stream, or a response from a server
+			// that doesn't send deltas, carries all the assignments
+			s.replace(shards)
+		}
+		s.metrics.AssignmentsUpdated(response.Delta)
 		backOff.Reset()
 	}
 }
 
-func (s *shardManagerImpl) update(updates []Shard) {
+func (s *shardManagerImpl) replace(shards []Shard) {
 	s.Lock()
 	defer s.Unlock()
 
+	s.shards = make(map[int64]Shard, len(shards))
+	for _, shard := range shards {
+		s.shards[shard.Id] = shard
+	}
+
+	s.updatedWg.Done()
+	s.shardsUpdated.Broadcast()
+}
+
+func (s *shardManagerImpl) update(updates []Shard, removed []int64) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, shardId := range removed {
+		delete(s.shards, shardId)
+	}
+
 	for _, update := range updates {
 		if _, ok := s.shards[update.Id]; !ok {
 			// delete overlaps
@@ -231,6 +425,7 @@ func (s *shardManagerImpl) update(updates []Shard) {
 	}
 
 	s.updatedWg.Done()
+	s.shardsUpdated.Broadcast()
 }
 
 func overlap(a HashRange, b HashRange) bool {
@@ -15,13 +15,18 @@
 package internal
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/metric/noop"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/oxia/internal/metrics"
 	"github.com/streamnative/oxia/server"
 )
 
@@ -40,7 +45,8 @@ func TestWithStandalone(t *testing.T) {
 
 	clientPool := common.NewClientPool(nil, nil)
 	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
-	shardManager, err := NewShardManager(&testShardStrategy{}, clientPool, serviceAddress, common.DefaultNamespace, 30*time.Second)
+	shardManager, err := NewShardManager(&testShardStrategy{}, clientPool, serviceAddress, common.DefaultNamespace, 30*time.Second,
+		metrics.NewMetrics(noop.NewMeterProvider()))
 	assert.NoError(t, err)
 
 	defer func() {
@@ -52,6 +58,147 @@ func TestWithStandalone(t *testing.T) {
 	assert.EqualValues(t, 0, shardId)
 }
 
+func TestShardManager_WaitForShards(t *testing.T) {
+	sm := &shardManagerImpl{
+		shards: map[int64]Shard{
+			0: {Id: 0, HashRange: hashRange(0, 9)},
+		},
+		updatedWg: common.NewWaitGroup(1),
+		logger:    slog.Default(),
+	}
+	sm.shardsUpdated = common.NewConditionContext(sm)
+
+	assert.NoError(t, sm.WaitForShards(context.Background(), []int64{0}))
+
+	ch := make(chan error, 1)
+	go func() {
+		ch <- sm.WaitForShards(context.Background(), []int64{1, 2})
+	}()
+
+	// The parent shard gets replaced by the children
+	sm.update([]Shard{{Id: 1, HashRange: hashRange(0, 4)}}, nil)
+	select {
+	case <-ch:
+		assert.Fail(t, "should still be waiting for shard 2")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sm.update([]Shard{{Id: 2, HashRange: hashRange(5, 9)}}, nil)
+	assert.NoError(t, <-ch)
+	assert.ElementsMatch(t, []int64{1, 2}, sm.GetAll())
+	assert.Equal(t, "", sm.Leader(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, sm.WaitForShards(ctx, []int64{3}), context.DeadlineExceeded)
+}
+
+func TestShardManager_Deltas(t *testing.T) {
+	sm := &shardManagerImpl{
+		shards:    map[int64]Shard{},
+		updatedWg: common.NewWaitGroup(1),
+		logger:    slog.Default(),
+	}
+	sm.shardsUpdated = common.NewConditionContext(sm)
+
+	sm.replace([]Shard{
+		{Id: 0, Leader: "server-0", HashRange: hashRange(0, 4)},
+		{Id: 1, Leader: "server-1", HashRange: hashRange(5, 9)},
+	})
+
+	// The leader of shard 1 has changed
+	sm.update([]Shard{{Id: 1, Leader: "server-2", HashRange: hashRange(5, 9)}}, nil)
+	assert.Equal(t, "server-0", sm.Leader(0))
+	assert.Equal(t, "server-2", sm.Leader(1))
+
+	// Shard 1 was deleted
+	sm.update(nil, []int64{1})
+	assert.ElementsMatch(t, []int64{0}, sm.GetAll())
+
+	// A new stream starts again from all the assignments
+	sm.replace([]Shard{{Id: 2, Leader: "server-1", HashRange: hashRange(0, 9)}})
+	assert.ElementsMatch(t, []int64{2}, sm.GetAll())
+	assert.Equal(t, "", sm.Leader(0))
+}
+
+func TestShardManager_InvalidateLeader(t *testing.T) {
+	sm := &shardManagerImpl{
+		shards:    map[int64]Shard{},
+		updatedWg: common.NewWaitGroup(1),
+		logger:    slog.Default(),
+	}
+	sm.shardsUpdated = common.NewConditionContext(sm)
+	var refreshes atomic.Int32
+	sm.streamCancel = func() {
+		refreshes.Add(1)
+	}
+
+	sm.replace([]Shard{
+		{Id: 0, Leader: "server-0", HashRange: hashRange(0, 4)},
+		{Id: 1, Leader: "server-1", HashRange: hashRange(5, 9)},
+	})
+
+	// The requests go to the hinted leader right away
+	sm.InvalidateLeader(1, "server-1", "server-2")
+	assert.Equal(t, "server-2", sm.Leader(1))
+	assert.EqualValues(t, 1, refreshes.Load())
+
+	// The leader was already updated
+	sm.InvalidateLeader(1, "server-1", "")
+	assert.Equal(t, "server-2", sm.Leader(1))
+
+	// Without a hint, the requests wait for the new leader
+	sm.InvalidateLeader(1, "server-2", "")
+	ch := make(chan string, 1)
+	go func() {
+		leader, err := sm.WaitForLeader(context.Background(), 1)
+		assert.NoError(t, err)
+		ch <- leader
+	}()
+	select {
+	case <-ch:
+		assert.Fail(t, "the shard has no leader")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The refreshes are delayed, while many shards move at the same time
+	sm.InvalidateLeader(0, "server-0", "")
+	assert.EqualValues(t, 1, refreshes.Load())
+	assert.Eventually(t, func() bool {
+		return refreshes.Load() == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	sm.update([]Shard{{Id: 1, Leader: "server-0", HashRange: hashRange(5, 9)}}, nil)
+	assert.Equal(t, "server-0", <-ch)
+
+	_, err := sm.WaitForLeader(context.Background(), 2)
+	assert.ErrorIs(t, err, common.ErrorShardSplit)
+}
+
+func TestShardManager_Get(t *testing.T) {
+	sm := &shardManagerImpl{
+		shardStrategy: NewShardStrategy(),
+		shards:        map[int64]Shard{},
+		updatedWg:     common.NewWaitGroup(1),
+		logger:        slog.Default(),
+	}
+	sm.shardsUpdated = common.NewConditionContext(sm)
+
+	// The hash space split in 4 shards, as the coordinator does
+	sm.replace([]Shard{
+		{Id: 0, Leader: "server-0", HashRange: hashRange(0, 1<<30-1)},
+		{Id: 1, Leader: "server-1", HashRange: hashRange(1<<30, 1<<31-1)},
+		{Id: 2, Leader: "server-0", HashRange: hashRange(1<<31, 3<<30-1)},
+		{Id: 3, Leader: "server-1", HashRange: hashRange(3<<30, 1<<32-1)},
+	})
+
+	// The keys are routed with the same hash the servers use
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		assert.EqualValues(t, common.Xxh332(key)>>30, sm.Get(key), key)
+	}
+}
+
 func TestOverlap(t *testing.T) {
 	for _, item := range []struct {
 		a         HashRange
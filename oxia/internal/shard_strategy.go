@@ -21,6 +21,7 @@ type ShardStrategy interface {
 type Shard struct {
 	Id        int64
 	Leader    string
+	Followers []string
 	HashRange HashRange
 }
 
@@ -16,6 +16,7 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log/slog"
 	"sync"
@@ -25,17 +26,40 @@ import (
 	"github.com/streamnative/oxia/proto"
 )
 
+// requestNotSentError is the failure of a request that didn't reach the
+// server, which can then be sent again without the risk of applying it twice.
+type requestNotSentError struct {
+	err error
+}
+
+func (e *requestNotSentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestNotSentError) Unwrap() error {
+	return e.err
+}
+
+// IsRequestNotSent tells whether the request has failed before reaching the
+// server.
+func IsRequestNotSent(err error) bool {
+	var notSent *requestNotSentError
+	return errors.As(err, &notSent)
+}
+
 type streamWrapper struct {
 	sync.Mutex
 
 	stream          proto.OxiaClient_WriteStreamClient
+	target          string
 	pendingRequests []common.Future[*proto.WriteResponse]
 	failed          atomic.Bool
 }
 
-func newStreamWrapper(stream proto.OxiaClient_WriteStreamClient) *streamWrapper {
+func newStreamWrapper(stream proto.OxiaClient_WriteStreamClient, target string) *streamWrapper {
 	sw := &streamWrapper{
 		stream:          stream,
+		target:          target,
 		pendingRequests: nil,
 	}
 
@@ -52,7 +76,7 @@ func (sw *streamWrapper) Send(ctx context.Context, req *proto.WriteRequest) (*pr
 	if err := sw.stream.Send(req); err != nil {
 		sw.failed.Store(true)
 		sw.Unlock()
-		return nil, err
+		return nil, &requestNotSentError{err}
 	}
 
 	sw.Unlock()
@@ -80,6 +104,12 @@ func (sw *streamWrapper) handleResponses() {
 		sw.Lock()
 
 		if err != nil {
+			// Fail the pending requests with the error returned by the
+			// server, instead of letting them time out
+			for _, f := range sw.pendingRequests {
+				f.Fail(err)
+			}
+			sw.pendingRequests = nil
 			sw.failed.Store(true)
 			sw.Unlock()
 			return
@@ -20,9 +20,12 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/oxia/internal"
@@ -30,10 +33,14 @@ import (
 )
 
 type notifications struct {
-	multiplexCh  chan *Notification
-	closeCh      chan any
-	shardManager internal.ShardManager
-	clientPool   common.ClientPool
+	sync.Mutex
+	multiplexCh       chan *Notification
+	closeCh           chan any
+	shardManager      internal.ShardManager
+	clientPool        common.ClientPool
+	keyPrefix         string
+	startFromEarliest bool
+	err               error
 
 	initWaitGroup common.WaitGroup
 	ctx           context.Context
@@ -43,12 +50,15 @@ type notifications struct {
 	cancelMultiplexChanClosed context.CancelFunc
 }
 
-func newNotifications(ctx context.Context, options clientOptions, clientPool common.ClientPool, shardManager internal.ShardManager) (*notifications, error) {
+func newNotifications(ctx context.Context, options clientOptions, notificationsOpts *notificationsOptions,
+	clientPool common.ClientPool, shardManager internal.ShardManager) (*notifications, error) {
 	nm := &notifications{
-		multiplexCh:  make(chan *Notification, 100),
-		closeCh:      make(chan any),
-		shardManager: shardManager,
-		clientPool:   clientPool,
+		multiplexCh:       make(chan *Notification, 100),
+		closeCh:           make(chan any),
+		shardManager:      shardManager,
+		clientPool:        clientPool,
+		keyPrefix:         notificationsOpts.keyPrefix,
+		startFromEarliest: notificationsOpts.startFromEarliest,
 	}
 
 	nm.ctx, nm.cancel = context.WithCancel(ctx)
@@ -80,7 +90,7 @@ func newNotifications(ctx context.Context, options clientOptions, clientPool com
 	)
 
 	// Wait for the notifications on all the shards to be initialized
-	timeoutCtx, cancel := context.WithTimeout(nm.ctx, options.requestTimeout)
+	timeoutCtx, cancel := context.WithTimeout(nm.ctx, options.streamTimeout)
 	defer cancel()
 
 	if err := nm.initWaitGroup.Wait(timeoutCtx); err != nil {
@@ -94,6 +104,24 @@ func (nm *notifications) Ch() <-chan *Notification {
 	return nm.multiplexCh
 }
 
+func (nm *notifications) Err() error {
+	nm.Lock()
+	defer nm.Unlock()
+	return nm.err
+}
+
+// Stop the notifications on all the shards after a shard has failed
+// permanently. Only the first error is kept.
+func (nm *notifications) fail(err error) {
+	nm.Lock()
+	if nm.err == nil {
+		nm.err = err
+	}
+	nm.Unlock()
+
+	nm.cancel()
+}
+
 func (nm *notifications) Close() error {
 	// Interrupt the go-routines receiving notifications on all the shards
 	nm.cancel()
@@ -147,7 +175,7 @@ func newShardNotificationsManager(shard int64, nm *notifications) *shardNotifica
 }
 
 func (snm *shardNotificationsManager) getNotificationsWithRetries() { //nolint:revive
-	_ = backoff.RetryNotify(snm.getNotifications,
+	err := backoff.RetryNotify(snm.getNotifications,
 		snm.backoff, func(err error, duration time.Duration) {
 			if !errors.Is(err, context.Canceled) {
 				snm.log.Error(
@@ -164,6 +192,19 @@ func (snm *shardNotificationsManager) getNotificationsWithRetries() { //nolint:r
 			}
 		})
 
+	if err != nil && snm.ctx.Err() == nil {
+		snm.log.Error(
+			"Failed to get notifications",
+			slog.Any("error", err),
+		)
+
+		if !snm.initialized {
+			snm.initialized = true
+			snm.nm.initWaitGroup.Fail(err)
+		}
+		snm.nm.fail(err)
+	}
+
 	// Signal that this shard notification manager is now closed
 	snm.nm.closeCh <- nil
 }
@@ -183,7 +224,7 @@ func (snm *shardNotificationsManager) multiplexNotificationBatch(nb *proto.Notif
 
 	for key, n := range nb.Notifications {
 		select {
-		case snm.nm.multiplexCh <- convertNotification(key, n):
+		case snm.nm.multiplexCh <- convertNotification(key, n, nb):
 
 		// Unblock from channel write when we're closing down
 		case <-snm.ctx.Done():
@@ -223,11 +264,27 @@ func (snm *shardNotificationsManager) multiplexNotifications(notifications proto
 	for {
 		err := snm.multiplexNotificationBatchOnce(notifications)
 		if err != nil {
-			return err
+			return toNotificationsError(err)
 		}
 	}
 }
 
+// The errors that will not go away by reconnecting stop the notifications,
+// while for the others the stream is resumed from the last notification
+// that was received.
+func toNotificationsError(err error) error {
+	if isShardSplit(err) {
+		return backoff.Permanent(ErrShardSplit)
+	}
+
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied, codes.Unimplemented:
+		return backoff.Permanent(err)
+	default:
+		return err
+	}
+}
+
 func (snm *shardNotificationsManager) getNotifications() error {
 	leader := snm.nm.shardManager.Leader(snm.shard)
 
@@ -239,21 +296,34 @@ func (snm *shardNotificationsManager) getNotifications() error {
 	var startOffsetExclusive *int64
 	if snm.lastOffsetReceived >= 0 {
 		startOffsetExclusive = &snm.lastOffsetReceived
+	} else if snm.nm.startFromEarliest {
+		// The server starts from the oldest notification it still has
+		earliest := int64(-1)
+		startOffsetExclusive = &earliest
 	}
 
 	notifications, err := rpc.GetNotifications(snm.ctx, &proto.NotificationsRequest{
 		Shard:                snm.shard,
 		StartOffsetExclusive: startOffsetExclusive,
+		KeyPrefix:            snm.nm.keyPrefix,
 	})
 	if err != nil {
 		if snm.ctx.Err() != nil {
 			return snm.ctx.Err()
 		}
-		return err
+		return toNotificationsError(err)
 	}
 
 	snm.backoff.Reset()
 
+	if !snm.initialized && startOffsetExclusive != nil {
+		// There's no dummy notification when the stream is positioned by
+		// the client
+		snm.log.Debug("Initialized the notification manager")
+		snm.initialized = true
+		snm.nm.initWaitGroup.Done()
+	}
+
 	return snm.multiplexNotifications(notifications)
 }
 
@@ -270,14 +340,18 @@ func convertNotificationType(t proto.NotificationType) NotificationType {
 	}
 }
 
-func convertNotification(key string, n *proto.Notification) *Notification {
+func convertNotification(key string, n *proto.Notification, nb *proto.NotificationBatch) *Notification {
 	versionId := int64(-1)
 	if n.VersionId != nil {
 		versionId = *n.VersionId
 	}
 	return &Notification{
-		Type:      convertNotificationType(n.Type),
-		Key:       key,
-		VersionId: versionId,
+		Type:              convertNotificationType(n.Type),
+		Key:               key,
+		VersionId:         versionId,
+		Shard:             nb.Shard,
+		Offset:            nb.Offset,
+		CreatedTimestamp:  n.GetCreatedTimestamp(),
+		ModifiedTimestamp: nb.Timestamp,
 	}
 }
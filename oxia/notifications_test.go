@@ -16,9 +16,18 @@ package oxia
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/server"
 )
 
 func TestNotificationsClose(t *testing.T) {
@@ -49,3 +58,171 @@ func TestNotificationsClose(t *testing.T) {
 	assert.Equal(t, false, ok)
 	assert.Nil(t, n)
 }
+
+func TestNotificationsError(t *testing.T) {
+	for _, item := range []struct {
+		err       error
+		permanent bool
+	}{
+		{status.Error(codes.Unavailable, "unavailable"), false},
+		{status.Error(common.CodeNodeIsNotLeader, "not leader"), false},
+		{context.DeadlineExceeded, false},
+		{status.Error(codes.InvalidArgument, "invalid"), true},
+		{status.Error(codes.Unauthenticated, "unauthenticated"), true},
+		{status.Error(common.CodeShardSplit, "split"), true},
+	} {
+		var permanent *backoff.PermanentError
+		assert.Equal(t, item.permanent, errors.As(toNotificationsError(item.err), &permanent), item.err)
+	}
+	assert.ErrorIs(t, toNotificationsError(status.Error(common.CodeShardSplit, "split")), ErrShardSplit)
+
+	// Only the first failure is kept
+	nm := &notifications{}
+	_, nm.cancel = context.WithCancel(context.Background())
+	nm.fail(ErrShardSplit)
+	nm.fail(context.Canceled)
+	assert.ErrorIs(t, nm.Err(), ErrShardSplit)
+}
+
+func receiveNotification(t *testing.T, notifications Notifications) *Notification {
+	t.Helper()
+
+	select {
+	case n := <-notifications.Ch():
+		return n
+	case <-time.After(10 * time.Second):
+		assert.FailNow(t, "the notification was not received")
+		return nil
+	}
+}
+
+func assertNoNotifications(t *testing.T, notifications Notifications) {
+	t.Helper()
+
+	select {
+	case n := <-notifications.Ch():
+		assert.Failf(t, "unexpected notification", "%+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotifications_KeyPrefix(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	config.NumShards = 3
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress, WithBatchLinger(0))
+	assert.NoError(t, err)
+
+	notifications, err := client.GetNotifications(KeyPrefix("/a/"))
+	assert.NoError(t, err)
+
+	for _, key := range []string{"/a/1", "/b/1", "/a/2", "/a"} {
+		assert.NoError(t, (<-client.Put(key, []byte("0"))).Err)
+	}
+
+	var keys []string
+	for i := 0; i < 2; i++ {
+		n := receiveNotification(t, notifications)
+		assert.Equal(t, KeyCreated, n.Type)
+		assert.Equal(t, client.(*clientImpl).shardManager.Get(n.Key), n.Shard)
+		keys = append(keys, n.Key)
+	}
+	assert.ElementsMatch(t, []string{"/a/1", "/a/2"}, keys)
+	assertNoNotifications(t, notifications)
+
+	assert.NoError(t, notifications.Close())
+	assert.NoError(t, notifications.Err())
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestNotifications_StartFromEarliest(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	config.NotificationsRetentionTime = 1 * time.Minute
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewSyncClient(serviceAddress, WithBatchLinger(0))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, v1, err := client.Put(ctx, "/a", []byte("0"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Delete(ctx, "/a"))
+
+	notifications, err := client.GetNotifications(StartFromEarliest())
+	assert.NoError(t, err)
+
+	n := receiveNotification(t, notifications)
+	assert.Equal(t, KeyCreated, n.Type)
+	assert.Equal(t, "/a", n.Key)
+	assert.Equal(t, v1.VersionId, n.VersionId)
+	offset := n.Offset
+
+	n = receiveNotification(t, notifications)
+	assert.Equal(t, KeyDeleted, n.Type)
+	assert.Equal(t, "/a", n.Key)
+	assert.Greater(t, n.Offset, offset)
+
+	_, v2, err := client.Put(ctx, "/b", []byte("0"))
+	assert.NoError(t, err)
+
+	n = receiveNotification(t, notifications)
+	assert.Equal(t, "/b", n.Key)
+	assert.Equal(t, v2.VersionId, n.VersionId)
+	assertNoNotifications(t, notifications)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestNotifications_Reconnect(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	config.NotificationsRetentionTime = 1 * time.Minute
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewSyncClient(serviceAddress, WithBatchLinger(0))
+	assert.NoError(t, err)
+
+	notifications, err := client.GetNotifications()
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, _, err = client.Put(ctx, "/a", []byte("0"))
+	assert.NoError(t, err)
+
+	n := receiveNotification(t, notifications)
+	assert.Equal(t, "/a", n.Key)
+	offset := n.Offset
+
+	// The stream is resumed after the last notification that was received,
+	// once the server is back
+	assert.NoError(t, standaloneServer.Close())
+	time.Sleep(500 * time.Millisecond)
+	config.PublicServiceAddr = serviceAddress
+	standaloneServer, err = server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, _, err = client.Put(ctx, fmt.Sprintf("/b/%d", i), []byte("0"))
+		assert.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		n = receiveNotification(t, notifications)
+		assert.Equal(t, fmt.Sprintf("/b/%d", i), n.Key)
+		assert.Greater(t, n.Offset, offset)
+		offset = n.Offset
+	}
+	assertNoNotifications(t, notifications)
+	assert.NoError(t, notifications.Err())
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oxia
+
+import (
+	"sync"
+
+	"github.com/streamnative/oxia/proto"
+)
+
+// observedOffsets keeps track of the highest offset observed by the client
+// on each shard, through the versions of the records it has written or read.
+// It is used as the barrier for the reads with session consistency.
+type observedOffsets struct {
+	sync.Mutex
+	offsets map[int64]int64
+}
+
+func newObservedOffsets() *observedOffsets {
+	return &observedOffsets{
+		offsets: make(map[int64]int64),
+	}
+}
+
+func (o *observedOffsets) observe(shardId int64, version *proto.Version) {
+	if version == nil {
+		return
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	if offset, ok := o.offsets[shardId]; !ok || version.VersionId > offset {
+		o.offsets[shardId] = version.VersionId
+	}
+}
+
+func (o *observedOffsets) get(shardId int64) *int64 {
+	o.Lock()
+	defer o.Unlock()
+
+	if offset, ok := o.offsets[shardId]; ok {
+		return &offset
+	}
+	return nil
+}
@@ -14,6 +14,8 @@
 
 package oxia
 
+import "context"
+
 // BaseOption is an option that applies to all the client operations.
 type BaseOption interface {
 	PutOption
@@ -26,6 +28,7 @@ type BaseOption interface {
 
 type baseOptions struct {
 	partitionKey *string
+	ctx          context.Context
 }
 
 type baseOptionsIf interface {
@@ -75,3 +78,46 @@ func PartitionKey(partitionKey string) BaseOption {
 		partitionKey: &partitionKey,
 	}
 }
+
+// --------------------------------------------------------------------------------------------
+
+// ContextOption is an option that applies to the operations of the [AsyncClient]
+// that don't take a context.
+type ContextOption interface {
+	DeleteOption
+	GetOption
+	DeleteRangeOption
+}
+
+type contextOpt struct {
+	ctx context.Context
+}
+
+func (o *contextOpt) applyPut(opts *putOptions) {
+	opts.ctx = o.ctx
+}
+
+func (o *contextOpt) applyDelete(opts *deleteOptions) {
+	opts.ctx = o.ctx
+}
+
+func (o *contextOpt) applyDeleteRange(opts *deleteRangeOptions) {
+	opts.ctx = o.ctx
+}
+
+func (o *contextOpt) applyGet(opts *getOptions) {
+	opts.ctx = o.ctx
+}
+
+// Context sets the context of an operation of the [AsyncClient]. If the
+// context is done before the operation is sent to the server, the operation
+// is dropped and fails with the error of the context. Once the operation is
+// sent, it's retried until the deadline of the context, or until the request
+// timeout of the client when the context has no deadline. An operation that
+// expires while it's retried fails with [context.DeadlineExceeded], though it
+// might have been applied.
+func Context(ctx context.Context) ContextOption {
+	return &contextOpt{
+		ctx: ctx,
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"go.uber.org/multierr"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/security"
 )
 
 const (
@@ -35,6 +36,7 @@ const (
 	DefaultMaxRequestsPerBatch = 1000
 	DefaultMaxBatchSize        = 128 * 1024
 	DefaultRequestTimeout      = 30 * time.Second
+	DefaultStreamTimeout       = 1 * time.Minute
 	DefaultSessionTimeout      = 15 * time.Second
 	DefaultNamespace           = common.DefaultNamespace
 )
@@ -44,6 +46,7 @@ var (
 	ErrInvalidOptionMaxRequestsPerBatch = errors.New("MaxRequestsPerBatch must be greater than zero")
 	ErrInvalidOptionMaxBatchSize        = errors.New("MaxBatchSize must be greater than zero")
 	ErrInvalidOptionRequestTimeout      = errors.New("RequestTimeout must be greater than zero")
+	ErrInvalidOptionStreamTimeout       = errors.New("StreamTimeout must be greater than zero")
 	ErrInvalidOptionSessionTimeout      = errors.New("SessionTimeout must be greater than zero")
 	ErrInvalidOptionIdentity            = errors.New("Identity must be non-empty")
 	ErrInvalidOptionNamespace           = errors.New("Namespace cannot be empty")
@@ -59,8 +62,10 @@ type clientOptions struct {
 	maxRequestsPerBatch int
 	maxBatchSize        int
 	requestTimeout      time.Duration
+	streamTimeout       time.Duration
 	meterProvider       metric.MeterProvider
 	sessionTimeout      time.Duration
+	sessionLostListener func()
 	identity            string
 	tls                 *tls.Config
 	authentication      auth.Authentication
@@ -76,6 +81,12 @@ func (o clientOptions) RequestTimeout() time.Duration {
 	return o.requestTimeout
 }
 
+// StreamTimeout defines how long the client will wait for the streams of List, RangeScan and GetNotifications to be
+// established.
+func (o clientOptions) StreamTimeout() time.Duration {
+	return o.streamTimeout
+}
+
 // ClientOption is an interface for applying Oxia client options.
 type ClientOption interface {
 	// apply is used to set a ClientOption value of a clientOptions.
@@ -90,6 +101,7 @@ func newClientOptions(serviceAddress string, opts ...ClientOption) (clientOption
 		maxRequestsPerBatch: DefaultMaxRequestsPerBatch,
 		maxBatchSize:        DefaultMaxBatchSize,
 		requestTimeout:      DefaultRequestTimeout,
+		streamTimeout:       DefaultStreamTimeout,
 		meterProvider:       noop.NewMeterProvider(),
 		sessionTimeout:      DefaultSessionTimeout,
 		identity:            defaultIdentity(),
@@ -147,6 +159,21 @@ func WithMaxRequestsPerBatch(maxRequestsPerBatch int) ClientOption {
 	})
 }
 
+// WithMaxBatchSize defines the maximum size in bytes of the keys and values that a batch of writes can contain before
+// the batched request is sent. The value must be greater than zero.
+func WithMaxBatchSize(maxBatchSize int) ClientOption {
+	return clientOptionFunc(func(options clientOptions) (clientOptions, error) {
+		if maxBatchSize <= 0 {
+			return options, ErrInvalidOptionMaxBatchSize
+		}
+		options.maxBatchSize = maxBatchSize
+		return options, nil
+	})
+}
+
+// WithRequestTimeout sets how long the operations are retried, when their
+// context has no deadline. The operations with a deadline are retried until
+// their deadline instead.
 func WithRequestTimeout(requestTimeout time.Duration) ClientOption {
 	return clientOptionFunc(func(options clientOptions) (clientOptions, error) {
 		if requestTimeout <= 0 {
@@ -157,6 +184,20 @@ func WithRequestTimeout(requestTimeout time.Duration) ClientOption {
 	})
 }
 
+// WithStreamTimeout sets how long List, RangeScan and GetNotifications wait
+// for the shards to have a leader and for the streams to be established, when
+// their context has no deadline. It's longer than the request timeout by
+// default, since the streams are established on all the shards.
+func WithStreamTimeout(streamTimeout time.Duration) ClientOption {
+	return clientOptionFunc(func(options clientOptions) (clientOptions, error) {
+		if streamTimeout <= 0 {
+			return options, ErrInvalidOptionStreamTimeout
+		}
+		options.streamTimeout = streamTimeout
+		return options, nil
+	})
+}
+
 func WithMeterProvider(meterProvider metric.MeterProvider) ClientOption {
 	return clientOptionFunc(func(options clientOptions) (clientOptions, error) {
 		if meterProvider == nil {
@@ -184,6 +225,18 @@ func WithSessionTimeout(sessionTimeout time.Duration) ClientOption {
 	})
 }
 
+// WithSessionLostListener sets a function that is called, from a separate
+// go-routine, when a session of the client has expired or was closed by the
+// service, and the ephemeral records it held were deleted. The application
+// can create them again, since a new session is started for the next
+// ephemeral records.
+func WithSessionLostListener(listener func()) ClientOption {
+	return clientOptionFunc(func(options clientOptions) (clientOptions, error) {
+		options.sessionLostListener = listener
+		return options, nil
+	})
+}
+
 func WithIdentity(identity string) ClientOption {
 	return clientOptionFunc(func(options clientOptions) (clientOptions, error) {
 		if identity == "" {
@@ -204,6 +257,29 @@ func WithTLS(tlsConf *tls.Config) ClientOption {
 	})
 }
 
+// WithTLSFromFiles makes the client connect with TLS, verifying the servers
+// against the CA in the trusted CA file. The certificate and key files are
+// optional, and are only needed if the servers require the clients to
+// authenticate with a certificate. The files are read again when they are
+// modified, so that the certificates can be rotated.
+func WithTLSFromFiles(trustedCaFile string, certFile string, keyFile string) ClientOption {
+	return clientOptionFunc(func(options clientOptions) (clientOptions, error) {
+		tlsOption := security.TLSOption{
+			TrustedCaFile: trustedCaFile,
+			CertFile:      certFile,
+			KeyFile:       keyFile,
+		}
+		tlsConf, err := tlsOption.MakeClientTLSConf()
+		if err != nil {
+			return options, errors.Wrap(err, "failed to load the TLS files")
+		}
+		options.tls = tlsConf
+		return options, nil
+	})
+}
+
+// WithAuthentication sets the credentials that are attached to all the
+// requests and streams of the client, to all the servers.
 func WithAuthentication(authentication auth.Authentication) ClientOption {
 	return clientOptionFunc(func(options clientOptions) (clientOptions, error) {
 		if authentication == nil {
@@ -17,6 +17,7 @@ package oxia
 type deleteOptions struct {
 	baseOptions
 	expectedVersion *int64
+	expectedValue   []byte
 }
 
 // DeleteOption represents an option for the [SyncClient.Delete] operation.
@@ -50,3 +51,23 @@ func (e *expectedVersionId) applyPut(opts *putOptions) {
 func (e *expectedVersionId) applyDelete(opts *deleteOptions) {
 	opts.expectedVersion = &e.versionId
 }
+
+// ExpectedValue Marks that the operation should only be successful
+// if the value of the record stored in the server is exactly the expected one.
+// The operation fails with [ErrUnexpectedValue] if the record does not exist.
+// When combined with [ExpectedVersionId], both conditions must be satisfied.
+func ExpectedValue(value []byte) DeleteOption {
+	return &expectedValue{value}
+}
+
+type expectedValue struct {
+	value []byte
+}
+
+func (e *expectedValue) applyPut(opts *putOptions) {
+	opts.expectedValue = e.value
+}
+
+func (e *expectedValue) applyDelete(opts *deleteOptions) {
+	opts.expectedValue = e.value
+}
@@ -28,6 +28,7 @@ func TestNewClientConfig(t *testing.T) {
 	assert.Equal(t, "serviceAddress", options.serviceAddress)
 	assert.Equal(t, DefaultBatchLinger, options.batchLinger)
 	assert.Equal(t, DefaultMaxRequestsPerBatch, options.maxRequestsPerBatch)
+	assert.Equal(t, DefaultMaxBatchSize, options.maxBatchSize)
 	assert.Equal(t, DefaultRequestTimeout, options.requestTimeout)
 }
 
@@ -63,6 +64,22 @@ func TestWithMaxRequestsPerBatch(t *testing.T) {
 	}
 }
 
+func TestWithMaxBatchSize(t *testing.T) {
+	for _, item := range []struct {
+		maxBatchSize         int
+		expectedMaxBatchSize int
+		expectedErr          error
+	}{
+		{-1, DefaultMaxBatchSize, ErrInvalidOptionMaxBatchSize},
+		{0, DefaultMaxBatchSize, ErrInvalidOptionMaxBatchSize},
+		{1, 1, nil},
+	} {
+		options, err := newClientOptions("serviceAddress", WithMaxBatchSize(item.maxBatchSize))
+		assert.Equal(t, item.expectedMaxBatchSize, options.maxBatchSize)
+		assert.ErrorIs(t, err, item.expectedErr)
+	}
+}
+
 func TestWithRequestTimeout(t *testing.T) {
 	for _, item := range []struct {
 		requestTimeout         time.Duration
@@ -78,3 +95,19 @@ func TestWithRequestTimeout(t *testing.T) {
 		assert.ErrorIs(t, err, item.expectedErr)
 	}
 }
+
+func TestWithStreamTimeout(t *testing.T) {
+	for _, item := range []struct {
+		streamTimeout         time.Duration
+		expectedStreamTimeout time.Duration
+		expectedErr           error
+	}{
+		{-1, DefaultStreamTimeout, ErrInvalidOptionStreamTimeout},
+		{0, DefaultStreamTimeout, ErrInvalidOptionStreamTimeout},
+		{1, 1, nil},
+	} {
+		options, err := newClientOptions("serviceAddress", WithStreamTimeout(item.streamTimeout))
+		assert.Equal(t, item.expectedStreamTimeout, options.StreamTimeout())
+		assert.ErrorIs(t, err, item.expectedErr)
+	}
+}
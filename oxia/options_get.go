@@ -19,6 +19,9 @@ import "github.com/streamnative/oxia/proto"
 type getOptions struct {
 	baseOptions
 	comparisonType proto.KeyComparisonType
+	consistency    proto.ReadConsistency
+	excludeValue   bool
+	maxValueSize   *uint64
 }
 
 // GetOption represents an option for the [SyncClient.Get] operation.
@@ -70,3 +73,77 @@ func ComparisonLower() GetOption {
 func ComparisonHigher() GetOption {
 	return &getComparisonType{proto.KeyComparisonType_HIGHER}
 }
+
+type getConsistency struct {
+	consistency proto.ReadConsistency
+}
+
+// ReadConsistencyOption is an option that sets the consistency of the read
+// operations: [SyncClient.Get], [SyncClient.List] and [SyncClient.RangeScan].
+type ReadConsistencyOption interface {
+	GetOption
+	ListOption
+	RangeScanOption
+}
+
+func (c *getConsistency) applyGet(opts *getOptions) {
+	opts.consistency = c.consistency
+}
+
+func (c *getConsistency) applyList(opts *listOptions) {
+	opts.consistency = c.consistency
+}
+
+func (c *getConsistency) applyRangeScan(opts *rangeScanOptions) {
+	opts.consistency = c.consistency
+}
+
+// ConsistencyLinearizable option will make the read operation to be served by the
+// shard leader, observing all the writes that were already acknowledged.
+// This is the default.
+func ConsistencyLinearizable() ReadConsistencyOption {
+	return &getConsistency{proto.ReadConsistency_LINEARIZABLE}
+}
+
+// ConsistencyBoundedStaleness option will allow the read operation to be served by
+// any replica of the shard. The returned records might not reflect the most
+// recent writes.
+func ConsistencyBoundedStaleness() ReadConsistencyOption {
+	return &getConsistency{proto.ReadConsistency_BOUNDED_STALENESS}
+}
+
+// ConsistencySession option will allow the read operation to be served by any
+// replica of the shard that has already caught up with all the records
+// versions observed by this client.
+func ConsistencySession() ReadConsistencyOption {
+	return &getConsistency{proto.ReadConsistency_SESSION}
+}
+
+type getExcludeValue struct{}
+
+func (getExcludeValue) applyGet(opts *getOptions) {
+	opts.excludeValue = true
+}
+
+// ExcludeValue option will make the get operation return only the version of
+// the record and the size of its value, in [GetResult.ValueSize], without
+// transferring the value.
+func ExcludeValue() GetOption {
+	return getExcludeValue{}
+}
+
+type getMaxValueSize struct {
+	maxValueSize uint64
+}
+
+func (m *getMaxValueSize) applyGet(opts *getOptions) {
+	opts.maxValueSize = &m.maxValueSize
+}
+
+// MaxValueSize option will make the get operation fail with ErrValueTooLarge,
+// instead of returning the value, when the value is larger than maxValueSize
+// bytes. The version of the record and the size of the value are still
+// returned, so that the value can be read again without the limit.
+func MaxValueSize(maxValueSize uint64) GetOption {
+	return &getMaxValueSize{maxValueSize}
+}
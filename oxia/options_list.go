@@ -14,8 +14,13 @@
 
 package oxia
 
+import "github.com/streamnative/oxia/proto"
+
 type listOptions struct {
 	baseOptions
+	consistency proto.ReadConsistency
+	limit       *uint64
+	sorted      bool
 }
 
 // ListOption represents an option for the [SyncClient.List] operation.
@@ -30,3 +35,46 @@ func newListOptions(opts []ListOption) *listOptions {
 	}
 	return listOpts
 }
+
+// ScanOption is an option that applies to both the [SyncClient.List] and
+// [SyncClient.RangeScan] operations.
+type ScanOption interface {
+	ListOption
+	RangeScanOption
+}
+
+type limitOpt struct {
+	limit uint64
+}
+
+func (o *limitOpt) applyList(opts *listOptions) {
+	opts.limit = &o.limit
+}
+
+func (o *limitOpt) applyRangeScan(opts *rangeScanOptions) {
+	opts.limit = &o.limit
+}
+
+// Limit sets the maximum number of keys, or records, that are returned
+// by the operation.
+//
+// The keys returned by a [SyncClient.List] across all the shards are not
+// sorted, so the limit does not select the lowest keys in the range, unless a
+// [PartitionKey] or the [Sorted] option is passed.
+func Limit(limit uint64) ScanOption {
+	return &limitOpt{limit}
+}
+
+type sortedOpt struct{}
+
+func (sortedOpt) applyList(opts *listOptions) {
+	opts.sorted = true
+}
+
+// Sorted returns the keys of a [SyncClient.List] in order across all the
+// shards. The keys of each shard are merged as they are received, so only a
+// page of keys per shard is held in memory, though the list proceeds at the
+// pace of the slowest shard.
+func Sorted() ListOption {
+	return sortedOpt{}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oxia
+
+type notificationsOptions struct {
+	keyPrefix         string
+	startFromEarliest bool
+}
+
+// NotificationsOption represents an option for the [SyncClient.GetNotifications] operation.
+type NotificationsOption interface {
+	applyNotifications(opts *notificationsOptions)
+}
+
+func newNotificationsOptions(opts []NotificationsOption) *notificationsOptions {
+	notificationsOpts := &notificationsOptions{}
+	for _, opt := range opts {
+		opt.applyNotifications(notificationsOpts)
+	}
+	return notificationsOpts
+}
+
+type keyPrefix struct {
+	prefix string
+}
+
+func (k *keyPrefix) applyNotifications(opts *notificationsOptions) {
+	opts.keyPrefix = k.prefix
+}
+
+// KeyPrefix restricts the notifications to the keys that start with the given prefix.
+// The filtering is done on the server side.
+func KeyPrefix(prefix string) NotificationsOption {
+	return &keyPrefix{prefix}
+}
+
+type startFromEarliest struct{}
+
+func (startFromEarliest) applyNotifications(opts *notificationsOptions) {
+	opts.startFromEarliest = true
+}
+
+// StartFromEarliest makes the notifications start from the oldest change that
+// is still retained by the servers, instead of the next change that is applied.
+// The servers keep the notifications for the configured retention time.
+func StartFromEarliest() NotificationsOption {
+	return startFromEarliest{}
+}
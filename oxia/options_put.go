@@ -14,13 +14,19 @@
 
 package oxia
 
-import "github.com/pkg/errors"
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 type putOptions struct {
 	baseOptions
 	expectedVersion    *int64
+	expectedValue      []byte
 	ephemeral          bool
 	sequenceKeysDeltas []uint64
+	expireAfter        *time.Duration
 }
 
 // PutOption represents an option for the [SyncClient.Put] operation.
@@ -43,11 +49,19 @@ func newPutOptions(opts []PutOption) (*putOptions, error) {
 			return nil, errors.Wrap(ErrInvalidOptions, "usage of sequential keys does not allow to specify an ExpectedVersionId")
 		}
 
+		if putOpts.expectedValue != nil {
+			return nil, errors.Wrap(ErrInvalidOptions, "usage of sequential keys does not allow to specify an ExpectedValue")
+		}
+
 		if putOpts.sequenceKeysDeltas[0] == 0 {
 			return nil, errors.Wrap(ErrInvalidOptions, "first delta in sequence keys delta must always be > 0")
 		}
 	}
 
+	if putOpts.expireAfter != nil && *putOpts.expireAfter < time.Millisecond {
+		return nil, errors.Wrap(ErrInvalidOptions, "the expiration time must be at least 1 millisecond")
+	}
+
 	return putOpts, nil
 }
 
@@ -95,3 +109,28 @@ func (s *sequenceKeysDeltas) applyPut(opts *putOptions) {
 func SequenceKeysDeltas(delta ...uint64) PutOption {
 	return &sequenceKeysDeltas{delta}
 }
+
+func (o *putOptions) expireAfterMs() *uint64 {
+	if o.expireAfter == nil {
+		return nil
+	}
+
+	ms := uint64(o.expireAfter.Milliseconds())
+	return &ms
+}
+
+type expireAfter struct {
+	expireAfter time.Duration
+}
+
+func (e *expireAfter) applyPut(opts *putOptions) {
+	opts.expireAfter = &e.expireAfter
+}
+
+// ExpireAfter marks the record to be automatically deleted once the given
+// amount of time has elapsed since it was written.
+// The expiration is based on the time at which the server has accepted the
+// write, and it is reset by any later update of the record.
+func ExpireAfter(d time.Duration) PutOption {
+	return &expireAfter{d}
+}
@@ -14,8 +14,12 @@
 
 package oxia
 
+import "github.com/streamnative/oxia/proto"
+
 type rangeScanOptions struct {
 	baseOptions
+	consistency proto.ReadConsistency
+	limit       *uint64
 }
 
 // RangeScanOption represents an option for the [SyncClient.RangeScan] operation.
@@ -18,9 +18,14 @@ import "github.com/streamnative/oxia/proto"
 
 func toPutResult(originalKey string, r *proto.PutResponse) PutResult {
 	if err := toError(r.Status); err != nil {
-		return PutResult{
+		pr := PutResult{
 			Err: err,
 		}
+		if r.Version != nil {
+			// The version of the record that failed the expected value check
+			pr.Version = toVersion(r.Version)
+		}
+		return pr
 	}
 	pr := PutResult{
 		Version: toVersion(r.Version),
@@ -48,14 +53,18 @@ func toGetResult(r *proto.GetResponse, originalKey string, err error) GetResult
 		return GetResult{Err: err}
 	}
 
-	if err := toError(r.Status); err != nil {
+	// The records with a value too large are returned with their version
+	err = toError(r.Status)
+	if err != nil && r.Status != proto.Status_VALUE_TOO_LARGE {
 		return GetResult{
 			Err: err,
 		}
 	}
 	gr := GetResult{
-		Value:   r.Value,
-		Version: toVersion(r.Version),
+		Value:     r.Value,
+		Version:   toVersion(r.Version),
+		ValueSize: r.GetValueSize(),
+		Err:       err,
 	}
 
 	if r.Key != nil {
@@ -88,8 +97,14 @@ func toError(status proto.Status) error {
 		return nil
 	case proto.Status_UNEXPECTED_VERSION_ID:
 		return ErrUnexpectedVersionId
+	case proto.Status_UNEXPECTED_VALUE:
+		return ErrUnexpectedValue
 	case proto.Status_KEY_NOT_FOUND:
 		return ErrKeyNotFound
+	case proto.Status_SESSION_DOES_NOT_EXIST:
+		return ErrSessionLost
+	case proto.Status_VALUE_TOO_LARGE:
+		return ErrValueTooLarge
 	default:
 		return ErrUnknownStatus
 	}
@@ -46,3 +46,35 @@ func (h *ResultHeap) Pop() any {
 	*h = old[0 : n-1]
 	return x
 }
+
+type KeysAndChannel struct {
+	keys []string
+	ch   chan ListResult
+}
+
+// KeysHeap sorts the pages of keys by their first key.
+type KeysHeap []*KeysAndChannel
+
+func (h KeysHeap) Len() int {
+	return len(h)
+}
+
+func (h KeysHeap) Less(i, j int) bool {
+	return compare.CompareWithSlash([]byte(h[i].keys[0]), []byte(h[j].keys[0])) < 0
+}
+
+func (h KeysHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *KeysHeap) Push(x any) {
+	*h = append(*h, x.(*KeysAndChannel))
+}
+
+func (h *KeysHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
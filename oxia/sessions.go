@@ -95,9 +95,37 @@ func (s *sessions) startSession(shardId int64) *clientSession {
 	return cs
 }
 
+// The session has expired, or it was closed by the service. The next
+// ephemeral records of the shard will be created with a new session.
+func (s *sessions) sessionLost(shardId int64, sessionId int64) {
+	s.Lock()
+	cs, found := s.sessionsByShard[shardId]
+	if !found || cs.id() != sessionId {
+		// The session was already replaced
+		s.Unlock()
+		return
+	}
+	delete(s.sessionsByShard, shardId)
+	s.Unlock()
+
+	cs.log.Warn("Session lost")
+	cs.cancel()
+
+	if listener := s.clientOpts.sessionLostListener; listener != nil {
+		go listener()
+	}
+}
+
 func (s *sessions) Close() error {
-	var err error
+	s.Lock()
+	sessions := make([]*clientSession, 0, len(s.sessionsByShard))
 	for _, cs := range s.sessionsByShard {
+		sessions = append(sessions, cs)
+	}
+	s.Unlock()
+
+	var err error
+	for _, cs := range sessions {
 		err = multierr.Append(err, cs.Close())
 	}
 
@@ -109,12 +137,19 @@ type clientSession struct {
 	started   chan error
 	shardId   int64
 	sessionId int64
+	created   bool
 	log       *slog.Logger
 	sessions  *sessions
 	ctx       context.Context
 	cancel    context.CancelFunc
 }
 
+func (cs *clientSession) id() int64 {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.sessionId
+}
+
 func (cs *clientSession) executeWithId(callback func(int64, error)) {
 	select {
 	case err := <-cs.started:
@@ -176,6 +211,7 @@ func (cs *clientSession) createSession() error {
 	cs.Lock()
 	defer cs.Unlock()
 	cs.sessionId = sessionId
+	cs.created = true
 	cs.log = cs.log.With(
 		slog.Int64("session-id", sessionId),
 		slog.String("client-identity", cs.sessions.clientIdentity),
@@ -190,44 +226,60 @@ func (cs *clientSession) createSession() error {
 			"shard":   fmt.Sprintf("%d", cs.shardId),
 			"session": fmt.Sprintf("%x016", cs.sessionId),
 		},
-		func() {
-			backOff := common.NewBackOff(cs.sessions.ctx)
-			err := backoff.RetryNotify(func() error {
-				err := cs.keepAlive()
-				if status.Code(err) == common.CodeInvalidSession {
-					cs.log.Error(
-						"Session is no longer valid",
-						slog.Any("error", err),
-					)
-
-					cs.sessions.Lock()
-					defer cs.sessions.Unlock()
-					cs.Lock()
-					defer cs.Unlock()
-					delete(cs.sessions.sessionsByShard, cs.shardId)
-					return backoff.Permanent(err)
-				}
-				return err
-			}, backOff, func(err error, duration time.Duration) {
-				slog.Debug(
-					"Failed to send session heartbeat, retrying later",
-					slog.Any("error", err),
-					slog.Duration("retry-after", duration),
-				)
-			})
-
-			if err != nil && !errors.Is(err, context.Canceled) {
-				cs.log.Error(
-					"Failed to keep alive session",
-					slog.Any("error", err),
-				)
-			}
-		},
+		cs.keepAliveWithRetries,
 	)
 
 	return nil
 }
 
+// The heartbeats that fail are retried often enough to reach the new leader
+// of the shard before the session expires.
+func (cs *clientSession) keepAliveWithRetries() {
+	backOff := backoff.WithContext(&backoff.ExponentialBackOff{
+		InitialInterval:     100 * time.Millisecond,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		Multiplier:          backoff.DefaultMultiplier,
+		MaxInterval:         cs.keepAliveInterval(),
+		MaxElapsedTime:      0, // Never stop trying
+		Stop:                backoff.Stop,
+		Clock:               backoff.SystemClock,
+	}, cs.ctx)
+
+	err := backoff.RetryNotify(func() error {
+		err := cs.keepAlive(backOff)
+		if status.Code(err) == common.CodeInvalidSession {
+			cs.log.Error(
+				"Session is no longer valid",
+				slog.Any("error", err),
+			)
+
+			cs.sessions.sessionLost(cs.shardId, cs.id())
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backOff, func(err error, duration time.Duration) {
+		cs.log.Debug(
+			"Failed to send session heartbeat, retrying later",
+			slog.Any("error", err),
+			slog.Duration("retry-after", duration),
+		)
+	})
+
+	if err != nil && !errors.Is(err, context.Canceled) && status.Code(err) != common.CodeInvalidSession {
+		cs.log.Error(
+			"Failed to keep alive session",
+			slog.Any("error", err),
+		)
+	}
+}
+
+// Send the heartbeats often enough that a few of them can be missed before
+// the session expires, though not more often than needed.
+func (cs *clientSession) keepAliveInterval() time.Duration {
+	timeout := cs.sessions.clientOpts.sessionTimeout
+	return max(timeout/10, min(2*time.Second, timeout/3))
+}
+
 func (cs *clientSession) getRpc() (proto.OxiaClientClient, error) {
 	leader := cs.sessions.shardManager.Leader(cs.shardId)
 	return cs.sessions.pool.GetClientRpc(leader)
@@ -236,6 +288,13 @@ func (cs *clientSession) getRpc() (proto.OxiaClientClient, error) {
 func (cs *clientSession) Close() error {
 	cs.cancel()
 
+	cs.Lock()
+	created, sessionId := cs.created, cs.sessionId
+	cs.Unlock()
+	if !created {
+		return nil
+	}
+
 	rpc, err := cs.getRpc()
 	if err != nil {
 		return err
@@ -245,29 +304,20 @@ func (cs *clientSession) Close() error {
 
 	if _, err = rpc.CloseSession(ctx, &proto.CloseSessionRequest{
 		Shard:     cs.shardId,
-		SessionId: cs.sessionId,
+		SessionId: sessionId,
 	}); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (cs *clientSession) keepAlive() error {
-	cs.sessions.Lock()
+func (cs *clientSession) keepAlive(backOff backoff.BackOff) error {
 	cs.Lock()
-	timeout := cs.sessions.clientOpts.sessionTimeout
-	ctx := cs.ctx
 	shardId := cs.shardId
 	sessionId := cs.sessionId
 	cs.Unlock()
-	cs.sessions.Unlock()
 
-	tickTime := timeout / 10
-	if tickTime < 2*time.Second {
-		tickTime = 2 * time.Second
-	}
-
-	ticker := time.NewTicker(tickTime)
+	ticker := time.NewTicker(cs.keepAliveInterval())
 	defer ticker.Stop()
 
 	rpc, err := cs.getRpc()
@@ -278,11 +328,17 @@ func (cs *clientSession) keepAlive() error {
 	for {
 		select {
 		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(cs.ctx, cs.sessions.clientOpts.requestTimeout)
 			_, err = rpc.KeepAlive(ctx, &proto.SessionHeartbeat{Shard: shardId, SessionId: sessionId})
+			cancel()
 			if err != nil {
+				if cs.ctx.Err() != nil {
+					return cs.ctx.Err()
+				}
 				return err
 			}
-		case <-ctx.Done():
+			backOff.Reset()
+		case <-cs.ctx.Done():
 			return nil
 		}
 	}
@@ -0,0 +1,144 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oxia
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server"
+)
+
+func clientSessionOf(t *testing.T, client AsyncClient, shardId int64) *clientSession {
+	t.Helper()
+
+	s := client.(*clientImpl).sessions
+	s.Lock()
+	defer s.Unlock()
+	cs, found := s.sessionsByShard[shardId]
+	assert.True(t, found)
+	return cs
+}
+
+func TestSessions_KeepAlivesStopped(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress, WithSessionTimeout(2*time.Second))
+	assert.NoError(t, err)
+	observer, err := NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+
+	assert.NoError(t, (<-client.Put("/e", []byte("0"), Ephemeral())).Err)
+
+	// The client is not sending the heartbeats anymore, as if it crashed
+	clientSessionOf(t, client, 0).cancel()
+
+	assert.Eventually(t, func() bool {
+		_, _, _, err := observer.Get(context.Background(), "/e")
+		return err == ErrKeyNotFound
+	}, 10*time.Second, 100*time.Millisecond)
+
+	_ = client.Close()
+	assert.NoError(t, observer.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestSessions_LeaderRestart(t *testing.T) {
+	config := server.NewTestConfig(t.TempDir())
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress, WithSessionTimeout(3*time.Second))
+	assert.NoError(t, err)
+
+	assert.NoError(t, (<-client.Put("/e", []byte("0"), Ephemeral())).Err)
+	sessionId := clientSessionOf(t, client, 0).id()
+
+	assert.NoError(t, standaloneServer.Close())
+	time.Sleep(500 * time.Millisecond)
+	config.PublicServiceAddr = serviceAddress
+	standaloneServer, err = server.NewStandalone(config)
+	assert.NoError(t, err)
+
+	// The heartbeats keep the session alive on the new leader
+	time.Sleep(6 * time.Second)
+	r := <-client.Get("/e")
+	assert.NoError(t, r.Err)
+	assert.True(t, r.Version.Ephemeral)
+
+	assert.NoError(t, (<-client.Put("/f", []byte("0"), Ephemeral())).Err)
+	assert.Equal(t, sessionId, clientSessionOf(t, client, 0).id())
+
+	// The ephemeral records are deleted when the client is closed
+	assert.NoError(t, client.Close())
+	observer, err := NewSyncClient(serviceAddress)
+	assert.NoError(t, err)
+	for _, key := range []string{"/e", "/f"} {
+		_, _, _, err = observer.Get(context.Background(), key)
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+	}
+
+	assert.NoError(t, observer.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
+
+func TestSessions_Lost(t *testing.T) {
+	standaloneServer, err := server.NewStandalone(server.NewTestConfig(t.TempDir()))
+	assert.NoError(t, err)
+
+	sessionLost := make(chan any, 10)
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+	client, err := NewAsyncClient(serviceAddress, WithSessionLostListener(func() {
+		sessionLost <- nil
+	}))
+	assert.NoError(t, err)
+
+	assert.NoError(t, (<-client.Put("/a", []byte("0"), Ephemeral())).Err)
+	sessionId := clientSessionOf(t, client, 0).id()
+
+	// The session is closed behind the client back
+	c := client.(*clientImpl)
+	rpc, err := c.clientPool.GetClientRpc(c.shardManager.Leader(0))
+	assert.NoError(t, err)
+	_, err = rpc.CloseSession(context.Background(), &proto.CloseSessionRequest{Shard: 0, SessionId: sessionId})
+	assert.NoError(t, err)
+
+	// Either the put or the keep-alive finds out that the session was lost
+	if err := (<-client.Put("/b", []byte("0"), Ephemeral())).Err; err != nil {
+		assert.ErrorIs(t, err, ErrSessionLost)
+	}
+
+	select {
+	case <-sessionLost:
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "the session loss was not notified")
+	}
+
+	// The ephemeral records are created again with a new session
+	assert.NoError(t, (<-client.Put("/a", []byte("0"), Ephemeral())).Err)
+	assert.NotEqual(t, sessionId, clientSessionOf(t, client, 0).id())
+	assert.Empty(t, sessionLost)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, standaloneServer.Close())
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oxia
+
+import (
+	"context"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+func isShardSplit(err error) bool {
+	return status.Code(err) == common.CodeShardSplit
+}
+
+// The error returned by a shard that was split carries the shards that
+// replaced it.
+func shardSplitChildren(err error) []int64 {
+	var children []int64
+	for _, detail := range status.Convert(err).Details() {
+		if assignments, ok := detail.(*proto.NamespaceShardsAssignment); ok {
+			for _, assignment := range assignments.Assignments {
+				children = append(children, assignment.Shard)
+			}
+		}
+	}
+	return children
+}
+
+// Wait until the client has received the assignments of the shards that
+// replaced a shard that was split, so that the operation can be retried on
+// them.
+func (c *clientImpl) waitForShardSplit(err error) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.options.requestTimeout)
+	defer cancel()
+
+	if c.shardManager.WaitForShards(ctx, shardSplitChildren(err)) != nil {
+		return ErrShardSplit
+	}
+	return nil
+}
+
+// Retry an operation that was rejected because the shard was split. The
+// retry is executed in background, since this is called from the batches
+// callbacks.
+func (c *clientImpl) retryOnShardSplit(err error, retry func(), fail func(error)) bool {
+	if !isShardSplit(err) {
+		return false
+	}
+
+	go func() {
+		if err := c.waitForShardSplit(err); err != nil {
+			fail(err)
+			return
+		}
+
+		retry()
+	}()
+	return true
+}
+
+// The operations that span multiple shards, or that stream the results, are
+// not retried, though the error is returned once the new shards are known,
+// so that the application can retry them straight away.
+func (c *clientImpl) toShardSplitError(err error) error {
+	if !isShardSplit(err) {
+		return err
+	}
+
+	_ = c.waitForShardSplit(err)
+	return ErrShardSplit
+}
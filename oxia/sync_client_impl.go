@@ -80,7 +80,7 @@ func (c *syncClientImpl) Close() error {
 
 func (c *syncClientImpl) Put(ctx context.Context, key string, value []byte, options ...PutOption) (string, Version, error) {
 	select {
-	case r := <-c.asyncClient.Put(key, value, options...):
+	case r := <-c.asyncClient.Put(key, value, append([]PutOption{Context(ctx)}, options...)...):
 		return r.Key, r.Version, r.Err
 	case <-ctx.Done():
 		return "", Version{}, ctx.Err()
@@ -89,7 +89,7 @@ func (c *syncClientImpl) Put(ctx context.Context, key string, value []byte, opti
 
 func (c *syncClientImpl) Delete(ctx context.Context, key string, options ...DeleteOption) error {
 	select {
-	case err := <-c.asyncClient.Delete(key, options...):
+	case err := <-c.asyncClient.Delete(key, append([]DeleteOption{Context(ctx)}, options...)...):
 		return err
 	case <-ctx.Done():
 		return ctx.Err()
@@ -98,7 +98,7 @@ func (c *syncClientImpl) Delete(ctx context.Context, key string, options ...Dele
 
 func (c *syncClientImpl) DeleteRange(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, options ...DeleteRangeOption) error {
 	select {
-	case err := <-c.asyncClient.DeleteRange(minKeyInclusive, maxKeyExclusive, options...):
+	case err := <-c.asyncClient.DeleteRange(minKeyInclusive, maxKeyExclusive, append([]DeleteRangeOption{Context(ctx)}, options...)...):
 		return err
 	case <-ctx.Done():
 		return ctx.Err()
@@ -107,14 +107,31 @@ func (c *syncClientImpl) DeleteRange(ctx context.Context, minKeyInclusive string
 
 func (c *syncClientImpl) Get(ctx context.Context, key string, options ...GetOption) (string, []byte, Version, error) {
 	select {
-	case r := <-c.asyncClient.Get(key, options...):
+	case r := <-c.asyncClient.Get(key, append([]GetOption{Context(ctx)}, options...)...):
 		return r.Key, r.Value, r.Version, r.Err
 	case <-ctx.Done():
 		return "", nil, Version{}, ctx.Err()
 	}
 }
 
+func (c *syncClientImpl) GetMany(ctx context.Context, keys []string, options ...GetOption) []GetResult {
+	select {
+	case r := <-c.asyncClient.GetMany(keys, append([]GetOption{Context(ctx)}, options...)...):
+		return r
+	case <-ctx.Done():
+		results := make([]GetResult, len(keys))
+		for i := range results {
+			results[i] = GetResult{Err: ctx.Err()}
+		}
+		return results
+	}
+}
+
 func (c *syncClientImpl) List(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, options ...ListOption) ([]string, error) {
+	// Stop the list on the other shards when one fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	ch := c.asyncClient.List(ctx, minKeyInclusive, maxKeyExclusive, options...)
 
 	keys := make([]string, 0)
@@ -126,6 +143,10 @@ func (c *syncClientImpl) List(ctx context.Context, minKeyInclusive string, maxKe
 		keys = append(keys, r.Keys...)
 	}
 
+	// The keys of the shards that were not listed yet are missing
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return keys, nil
 }
 
@@ -133,6 +154,6 @@ func (c *syncClientImpl) RangeScan(ctx context.Context, minKeyInclusive string,
 	return c.asyncClient.RangeScan(ctx, minKeyInclusive, maxKeyExclusive, options...)
 }
 
-func (c *syncClientImpl) GetNotifications() (Notifications, error) {
-	return c.asyncClient.GetNotifications()
+func (c *syncClientImpl) GetNotifications(options ...NotificationsOption) (Notifications, error) {
+	return c.asyncClient.GetNotifications(options...)
 }
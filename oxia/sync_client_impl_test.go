@@ -42,6 +42,10 @@ func (c *neverCompleteAsyncClient) Get(key string, options ...GetOption) <-chan
 	return make(chan GetResult)
 }
 
+func (c *neverCompleteAsyncClient) GetMany(keys []string, options ...GetOption) <-chan []GetResult {
+	return make(chan []GetResult)
+}
+
 func (c *neverCompleteAsyncClient) List(ctx context.Context, minKeyInclusive string, maxKeyExclusive string, options ...ListOption) <-chan ListResult {
 	panic("not implemented")
 }
@@ -50,7 +54,7 @@ func (c *neverCompleteAsyncClient) RangeScan(ctx context.Context, minKeyInclusiv
 	panic("not implemented")
 }
 
-func (c *neverCompleteAsyncClient) GetNotifications() (Notifications, error) {
+func (c *neverCompleteAsyncClient) GetNotifications(...NotificationsOption) (Notifications, error) {
 	panic("not implemented")
 }
 
@@ -16,27 +16,54 @@ package perf
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
-	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/bmizerany/perks/quantile"
+	"github.com/pkg/errors"
 	"golang.org/x/time/rate"
 
 	"github.com/streamnative/oxia/oxia"
 )
 
+const (
+	DefaultKeyPrefix      = "perf-key-"
+	DefaultReportInterval = 10 * time.Second
+	DefaultMaxInFlight    = 10_000
+)
+
 type Config struct {
 	ServiceAddr     string
 	Namespace       string
 	RequestRate     float64
 	ReadPercentage  float64
 	KeysCardinality uint32
-	ValueSize       uint32
+	KeyPrefix       string
+
+	// The values are between ValueSize and ValueSizeMax bytes, with sizes
+	// uniformly distributed
+	ValueSize    uint32
+	ValueSizeMax uint32
+
+	// Streams is the number of clients that generate the traffic in
+	// parallel, each with its own connections and batches
+	Streams int
+
+	// Duration is how long the traffic is generated, or until the context is
+	// done if zero
+	Duration       time.Duration
+	ReportInterval time.Duration
+
+	// MaxInFlight is the maximum number of operations awaiting a response.
+	// The operations over the limit are not issued, and are counted as
+	// throttled
+	MaxInFlight int
+
+	// Cleanup deletes the keys written by the run once it's completed
+	Cleanup bool
 
 	BatchLinger         time.Duration
 	MaxRequestsPerBatch int
@@ -44,172 +71,261 @@ type Config struct {
 }
 
 type Perf interface {
-	Run(context.Context)
+	// Run generates the traffic until the duration has elapsed or the
+	// context is done, and returns the stats of the whole run.
+	Run(context.Context) (*Result, error)
 }
 
 func New(config Config) Perf {
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = DefaultKeyPrefix
+	}
+	if config.ValueSizeMax < config.ValueSize {
+		config.ValueSizeMax = config.ValueSize
+	}
+	if config.Streams <= 0 {
+		config.Streams = 1
+	}
+	if config.ReportInterval <= 0 {
+		config.ReportInterval = DefaultReportInterval
+	}
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = DefaultMaxInFlight
+	}
+
 	return &perf{
 		config: config,
+		writes: newOpStats(),
+		reads:  newOpStats(),
 	}
 }
 
 type perf struct {
-	config    Config
-	keys      []string
-	failedOps atomic.Int64
+	config   Config
+	keys     []string
+	writes   *opStats
+	reads    *opStats
+	inFlight atomic.Int64
+	ops      sync.WaitGroup
 }
 
-func (p *perf) Run(ctx context.Context) {
+func (p *perf) Run(ctx context.Context) (*Result, error) {
 	slog.Info(
 		"Starting Oxia perf client",
 		slog.Any("config", p.config),
 	)
 
+	if p.config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.Duration)
+		defer cancel()
+	}
+
 	p.keys = make([]string, p.config.KeysCardinality)
 	for i := uint32(0); i < p.config.KeysCardinality; i++ {
-		p.keys[i] = fmt.Sprintf("key-%d", i)
+		p.keys[i] = fmt.Sprintf("%s%d", p.config.KeyPrefix, i)
 	}
 
-	client, err := oxia.NewAsyncClient(p.config.ServiceAddr, //nolint:contextcheck
-		oxia.WithNamespace(p.config.Namespace),
-		oxia.WithBatchLinger(p.config.BatchLinger),
-		oxia.WithMaxRequestsPerBatch(p.config.MaxRequestsPerBatch),
-		oxia.WithRequestTimeout(p.config.RequestTimeout),
-	)
-	if err != nil {
-		slog.Error(
-			"Failed to create Oxia client",
-			slog.Any("error", err),
+	clients := make([]oxia.AsyncClient, 0, p.config.Streams)
+	defer func() {
+		for _, client := range clients {
+			_ = client.Close()
+		}
+	}()
+	for i := 0; i < p.config.Streams; i++ {
+		client, err := oxia.NewAsyncClient(p.config.ServiceAddr, //nolint:contextcheck
+			oxia.WithNamespace(p.config.Namespace),
+			oxia.WithBatchLinger(p.config.BatchLinger),
+			oxia.WithMaxRequestsPerBatch(p.config.MaxRequestsPerBatch),
+			oxia.WithRequestTimeout(p.config.RequestTimeout),
 		)
-		os.Exit(1)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Oxia client")
+		}
+		clients = append(clients, client)
 	}
 
-	writeLatencyCh := make(chan int64)
-	go p.generateWriteTraffic(ctx, client, writeLatencyCh)
+	writeRate := p.config.RequestRate * (100.0 - p.config.ReadPercentage) / 100 / float64(p.config.Streams)
+	readRate := p.config.RequestRate * p.config.ReadPercentage / 100 / float64(p.config.Streams)
+
+	start := time.Now()
+	generators := sync.WaitGroup{}
+	for _, client := range clients {
+		generators.Add(2)
+		go func() {
+			defer generators.Done()
+			p.generateTraffic(ctx, writeRate, p.writes, func(key string) {
+				p.write(client, key)
+			})
+		}()
+		go func() {
+			defer generators.Done()
+			p.generateTraffic(ctx, readRate, p.reads, func(key string) {
+				p.read(client, key)
+			})
+		}()
+	}
 
-	readLatencyCh := make(chan int64)
-	go p.generateReadTraffic(ctx, client, readLatencyCh)
+	p.report(ctx)
+
+	// The operations in flight are completed before the stats of the whole
+	// run are taken
+	generators.Wait()
+	p.ops.Wait()
+	elapsed := time.Since(start)
+	result := &Result{
+		Duration: elapsed.Seconds(),
+		Write:    p.writes.total(elapsed),
+		Read:     p.reads.total(elapsed),
+	}
 
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
+	if p.config.Cleanup {
+		if err := p.cleanup(clients[0]); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
 
-	wq := quantile.NewTargeted(0.50, 0.95, 0.99, 0.999, 1.0)
-	rq := quantile.NewTargeted(0.50, 0.95, 0.99, 0.999, 1.0)
-	writeOps := 0
-	readOps := 0
+func (p *perf) report(ctx context.Context) {
+	ticker := time.NewTicker(p.config.ReportInterval)
+	defer ticker.Stop()
 
+	last := time.Now()
 	for {
 		select {
 		case <-ticker.C:
-			writeRate := float64(writeOps) / float64(10)
-			readRate := float64(readOps) / float64(10)
-			failedOpsRate := float64(p.failedOps.Swap(0)) / float64(10)
-			slog.Info(fmt.Sprintf(`Stats - Total ops: %6.1f ops/s - Failed ops: %6.1f ops/s
+			now := time.Now()
+			elapsed := now.Sub(last)
+			last = now
+			write := p.writes.interval(elapsed)
+			read := p.reads.interval(elapsed)
+
+			slog.Info(fmt.Sprintf(`Stats - Total ops: %6.1f ops/s - Failed ops: %6.1f ops/s - Throttled ops: %6.1f ops/s
 			Write ops %6.1f w/s  Latency ms: 50%% %5.1f - 95%% %5.1f - 99%% %5.1f - 99.9%% %5.1f - max %6.1f
 			Read  ops %6.1f r/s  Latency ms: 50%% %5.1f - 95%% %5.1f - 99%% %5.1f - 99.9%% %5.1f - max %6.1f`,
-				writeRate+readRate,
-				failedOpsRate,
-				writeRate,
-				wq.Query(0.5),
-				wq.Query(0.95),
-				wq.Query(0.99),
-				wq.Query(0.999),
-				wq.Query(1.0),
-				readRate,
-				rq.Query(0.5),
-				rq.Query(0.95),
-				rq.Query(0.99),
-				rq.Query(0.999),
-				rq.Query(1.0),
+				write.Rate+read.Rate,
+				float64(write.Failed+read.Failed)/elapsed.Seconds(),
+				float64(write.Throttled+read.Throttled)/elapsed.Seconds(),
+				write.Rate,
+				write.LatencyMs.P50,
+				write.LatencyMs.P95,
+				write.LatencyMs.P99,
+				write.LatencyMs.P999,
+				write.LatencyMs.Max,
+				read.Rate,
+				read.LatencyMs.P50,
+				read.LatencyMs.P95,
+				read.LatencyMs.P99,
+				read.LatencyMs.P999,
+				read.LatencyMs.Max,
 			))
 
-			wq.Reset()
-			rq.Reset()
-			writeOps = 0
-			readOps = 0
-
-		case wl := <-writeLatencyCh:
-			writeOps++
-			wq.Insert(float64(wl) / 1000.0) // Convert to millis
-
-		case rl := <-readLatencyCh:
-			readOps++
-			rq.Insert(float64(rl) / 1000.0) // Convert to millis
-
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (p *perf) generateWriteTraffic(ctx context.Context, client oxia.AsyncClient, latencyCh chan int64) {
-	writeRate := p.config.RequestRate * (100.0 - p.config.ReadPercentage) / 100
-	limiter := rate.NewLimiter(rate.Limit(writeRate), int(writeRate))
-
-	value := make([]byte, p.config.ValueSize)
+// generateTraffic issues the operations at the given rate. When too many
+// operations are awaiting a response, the new ones are throttled instead of
+// piling up.
+func (p *perf) generateTraffic(ctx context.Context, opsRate float64, stats *opStats, op func(key string)) {
+	if opsRate <= 0 {
+		return
+	}
+	limiter := rate.NewLimiter(rate.Limit(opsRate), max(1, int(opsRate)))
 
 	for {
 		if err := limiter.Wait(ctx); err != nil {
 			return
 		}
 
-		key := p.keys[rand.Intn(int(p.config.KeysCardinality))] //nolint:gosec
+		if p.inFlight.Load() >= int64(p.config.MaxInFlight) {
+			stats.throttle()
+			continue
+		}
 
-		start := time.Now()
-		ch := client.Put(key, value)
-		go func() {
-			r := <-ch
-			if r.Err != nil {
-				slog.Warn(
-					"Write operation has failed",
-					slog.Any("error", r.Err),
-					slog.String("key", key),
-				)
-				p.failedOps.Add(1)
-			} else {
-				slog.Debug(
-					"Write operation has succeeded",
-					slog.String("key", key),
-					slog.Any("version", r.Version),
-				)
-
-				latencyCh <- time.Since(start).Microseconds()
-			}
-		}()
+		key := p.keys[rand.Intn(len(p.keys))] //nolint:gosec
+		p.inFlight.Add(1)
+		p.ops.Add(1)
+		op(key)
 	}
 }
 
-func (p *perf) generateReadTraffic(ctx context.Context, client oxia.AsyncClient, latencyCh chan int64) {
-	readRate := p.config.RequestRate * p.config.ReadPercentage / 100
-	limiter := rate.NewLimiter(rate.Limit(readRate), int(readRate))
+func (p *perf) completed() {
+	p.inFlight.Add(-1)
+	p.ops.Done()
+}
 
-	for {
-		if err := limiter.Wait(ctx); err != nil {
+func (p *perf) write(client oxia.AsyncClient, key string) {
+	size := p.config.ValueSize
+	if p.config.ValueSizeMax > p.config.ValueSize {
+		size += uint32(rand.Int63n(int64(p.config.ValueSizeMax - p.config.ValueSize + 1))) //nolint:gosec
+	}
+	value := make([]byte, size)
+
+	start := time.Now()
+	ch := client.Put(key, value)
+	go func() {
+		defer p.completed()
+		r := <-ch
+		if r.Err != nil {
+			slog.Warn(
+				"Write operation has failed",
+				slog.Any("error", r.Err),
+				slog.String("key", key),
+			)
+			p.writes.fail()
 			return
 		}
 
-		key := p.keys[rand.Intn(int(p.config.KeysCardinality))] //nolint:gosec
+		slog.Debug(
+			"Write operation has succeeded",
+			slog.String("key", key),
+			slog.Any("version", r.Version),
+		)
+		p.writes.record(time.Since(start))
+	}()
+}
 
-		start := time.Now()
-		ch := client.Get(key)
-		go func() {
-			r := <-ch
-			if r.Err != nil && !errors.Is(r.Err, oxia.ErrKeyNotFound) {
-				slog.Warn(
-					"Read operation has failed",
-					slog.Any("error", r.Err),
-					slog.String("key", key),
-				)
-				p.failedOps.Add(1)
-			} else {
-				slog.Debug(
-					"Read operation has succeeded",
-					slog.String("key", key),
-					slog.Any("version", r.Version),
-				)
-
-				latencyCh <- time.Since(start).Microseconds()
-			}
-		}()
+func (p *perf) read(client oxia.AsyncClient, key string) {
+	start := time.Now()
+	ch := client.Get(key)
+	go func() {
+		defer p.completed()
+		r := <-ch
+		if r.Err != nil && !errors.Is(r.Err, oxia.ErrKeyNotFound) {
+			slog.Warn(
+				"Read operation has failed",
+				slog.Any("error", r.Err),
+				slog.String("key", key),
+			)
+			p.reads.fail()
+			return
+		}
+
+		slog.Debug(
+			"Read operation has succeeded",
+			slog.String("key", key),
+			slog.Any("version", r.Version),
+		)
+		p.reads.record(time.Since(start))
+	}()
+}
+
+// cleanup deletes all the keys with the prefix of the run.
+func (p *perf) cleanup(client oxia.AsyncClient) error {
+	prefix := p.config.KeyPrefix
+	end := []byte(prefix)
+	end[len(end)-1]++
+	if err := <-client.DeleteRange(prefix, string(end)); err != nil {
+		return errors.Wrap(err, "failed to delete the keys")
 	}
+
+	slog.Info(
+		"Deleted the keys",
+		slog.String("key-prefix", prefix),
+	)
+	return nil
 }
@@ -0,0 +1,157 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bmizerany/perks/quantile"
+)
+
+// Result are the stats of a whole run.
+type Result struct {
+	// Duration is the duration of the run, in seconds
+	Duration float64 `json:"duration"`
+	Write    OpStats `json:"write"`
+	Read     OpStats `json:"read"`
+}
+
+type OpStats struct {
+	Ops       int64 `json:"ops"`
+	Failed    int64 `json:"failed"`
+	Throttled int64 `json:"throttled"`
+
+	// Rate is the number of succeeded operations per second
+	Rate      float64   `json:"rate"`
+	LatencyMs Latencies `json:"latency_ms"`
+}
+
+type Latencies struct {
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+}
+
+// WriteText writes the result as a summary for humans.
+func (r *Result) WriteText(out io.Writer) error {
+	_, err := fmt.Fprintf(out, `Duration: %.1f s
+Write ops %10d - Failed %8d - Throttled %8d - %8.1f w/s  Latency ms: 50%% %5.1f - 95%% %5.1f - 99%% %5.1f - 99.9%% %5.1f - max %6.1f
+Read  ops %10d - Failed %8d - Throttled %8d - %8.1f r/s  Latency ms: 50%% %5.1f - 95%% %5.1f - 99%% %5.1f - 99.9%% %5.1f - max %6.1f
+`,
+		r.Duration,
+		r.Write.Ops, r.Write.Failed, r.Write.Throttled, r.Write.Rate,
+		r.Write.LatencyMs.P50, r.Write.LatencyMs.P95, r.Write.LatencyMs.P99, r.Write.LatencyMs.P999, r.Write.LatencyMs.Max,
+		r.Read.Ops, r.Read.Failed, r.Read.Throttled, r.Read.Rate,
+		r.Read.LatencyMs.P50, r.Read.LatencyMs.P95, r.Read.LatencyMs.P99, r.Read.LatencyMs.P999, r.Read.LatencyMs.Max,
+	)
+	return err
+}
+
+// WriteJSON writes the result in JSON, to be collected by scripts.
+func (r *Result) WriteJSON(out io.Writer) error {
+	return json.NewEncoder(out).Encode(r)
+}
+
+// opStats are the stats of a type of operation, both for the current
+// interval and for the whole run.
+type opStats struct {
+	sync.Mutex
+
+	intervalLatencies *quantile.Stream
+	intervalCounts    counts
+	totalLatencies    *quantile.Stream
+	totalCounts       counts
+}
+
+type counts struct {
+	ops       int64
+	failed    int64
+	throttled int64
+}
+
+func newLatencies() *quantile.Stream {
+	return quantile.NewTargeted(0.50, 0.95, 0.99, 0.999, 1.0)
+}
+
+func newOpStats() *opStats {
+	return &opStats{
+		intervalLatencies: newLatencies(),
+		totalLatencies:    newLatencies(),
+	}
+}
+
+func (s *opStats) record(latency time.Duration) {
+	ms := float64(latency.Microseconds()) / 1000.0
+
+	s.Lock()
+	defer s.Unlock()
+	s.intervalLatencies.Insert(ms)
+	s.totalLatencies.Insert(ms)
+	s.intervalCounts.ops++
+	s.totalCounts.ops++
+}
+
+func (s *opStats) fail() {
+	s.Lock()
+	defer s.Unlock()
+	s.intervalCounts.failed++
+	s.totalCounts.failed++
+}
+
+func (s *opStats) throttle() {
+	s.Lock()
+	defer s.Unlock()
+	s.intervalCounts.throttled++
+	s.totalCounts.throttled++
+}
+
+// interval returns the stats since the previous interval, and starts a new
+// one.
+func (s *opStats) interval(elapsed time.Duration) OpStats {
+	s.Lock()
+	defer s.Unlock()
+	res := toOpStats(s.intervalLatencies, s.intervalCounts, elapsed)
+	s.intervalLatencies.Reset()
+	s.intervalCounts = counts{}
+	return res
+}
+
+func (s *opStats) total(elapsed time.Duration) OpStats {
+	s.Lock()
+	defer s.Unlock()
+	return toOpStats(s.totalLatencies, s.totalCounts, elapsed)
+}
+
+func toOpStats(latencies *quantile.Stream, c counts, elapsed time.Duration) OpStats {
+	return OpStats{
+		Ops:       c.ops,
+		Failed:    c.failed,
+		Throttled: c.throttled,
+		Rate:      float64(c.ops) / elapsed.Seconds(),
+		LatencyMs: Latencies{
+			P50:  latencies.Query(0.50),
+			P95:  latencies.Query(0.95),
+			P99:  latencies.Query(0.99),
+			P999: latencies.Query(0.999),
+			Max:  latencies.Query(1.0),
+		},
+	}
+}
@@ -26,8 +26,17 @@ type OxiaCoordinationClient interface {
 	NewTerm(ctx context.Context, in *NewTermRequest, opts ...grpc.CallOption) (*NewTermResponse, error)
 	BecomeLeader(ctx context.Context, in *BecomeLeaderRequest, opts ...grpc.CallOption) (*BecomeLeaderResponse, error)
 	AddFollower(ctx context.Context, in *AddFollowerRequest, opts ...grpc.CallOption) (*AddFollowerResponse, error)
+	RemoveFollower(ctx context.Context, in *RemoveFollowerRequest, opts ...grpc.CallOption) (*RemoveFollowerResponse, error)
+	TransferLeadership(ctx context.Context, in *TransferLeadershipRequest, opts ...grpc.CallOption) (*TransferLeadershipResponse, error)
+	SplitShard(ctx context.Context, in *SplitShardRequest, opts ...grpc.CallOption) (*SplitShardResponse, error)
 	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error)
+	GetInfo(ctx context.Context, in *GetInfoRequest, opts ...grpc.CallOption) (*GetInfoResponse, error)
 	DeleteShard(ctx context.Context, in *DeleteShardRequest, opts ...grpc.CallOption) (*DeleteShardResponse, error)
+	AssignShard(ctx context.Context, in *AssignShardRequest, opts ...grpc.CallOption) (*AssignShardResponse, error)
+	UnassignShard(ctx context.Context, in *UnassignShardRequest, opts ...grpc.CallOption) (*UnassignShardResponse, error)
+	SetMaintenance(ctx context.Context, in *SetMaintenanceRequest, opts ...grpc.CallOption) (*SetMaintenanceResponse, error)
+	CompactShard(ctx context.Context, in *CompactShardRequest, opts ...grpc.CallOption) (*CompactShardResponse, error)
+	GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (OxiaCoordination_GetSnapshotClient, error)
 }
 
 type oxiaCoordinationClient struct {
@@ -99,6 +108,33 @@ func (c *oxiaCoordinationClient) AddFollower(ctx context.Context, in *AddFollowe
 	return out, nil
 }
 
+func (c *oxiaCoordinationClient) RemoveFollower(ctx context.Context, in *RemoveFollowerRequest, opts ...grpc.CallOption) (*RemoveFollowerResponse, error) {
+	out := new(RemoveFollowerResponse)
+	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/RemoveFollower", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oxiaCoordinationClient) TransferLeadership(ctx context.Context, in *TransferLeadershipRequest, opts ...grpc.CallOption) (*TransferLeadershipResponse, error) {
+	out := new(TransferLeadershipResponse)
+	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/TransferLeadership", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oxiaCoordinationClient) SplitShard(ctx context.Context, in *SplitShardRequest, opts ...grpc.CallOption) (*SplitShardResponse, error) {
+	out := new(SplitShardResponse)
+	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/SplitShard", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *oxiaCoordinationClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error) {
 	out := new(GetStatusResponse)
 	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/GetStatus", in, out, opts...)
@@ -108,6 +144,15 @@ func (c *oxiaCoordinationClient) GetStatus(ctx context.Context, in *GetStatusReq
 	return out, nil
 }
 
+func (c *oxiaCoordinationClient) GetInfo(ctx context.Context, in *GetInfoRequest, opts ...grpc.CallOption) (*GetInfoResponse, error) {
+	out := new(GetInfoResponse)
+	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/GetInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *oxiaCoordinationClient) DeleteShard(ctx context.Context, in *DeleteShardRequest, opts ...grpc.CallOption) (*DeleteShardResponse, error) {
 	out := new(DeleteShardResponse)
 	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/DeleteShard", in, out, opts...)
@@ -117,6 +162,74 @@ func (c *oxiaCoordinationClient) DeleteShard(ctx context.Context, in *DeleteShar
 	return out, nil
 }
 
+func (c *oxiaCoordinationClient) AssignShard(ctx context.Context, in *AssignShardRequest, opts ...grpc.CallOption) (*AssignShardResponse, error) {
+	out := new(AssignShardResponse)
+	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/AssignShard", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oxiaCoordinationClient) UnassignShard(ctx context.Context, in *UnassignShardRequest, opts ...grpc.CallOption) (*UnassignShardResponse, error) {
+	out := new(UnassignShardResponse)
+	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/UnassignShard", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oxiaCoordinationClient) SetMaintenance(ctx context.Context, in *SetMaintenanceRequest, opts ...grpc.CallOption) (*SetMaintenanceResponse, error) {
+	out := new(SetMaintenanceResponse)
+	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/SetMaintenance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oxiaCoordinationClient) CompactShard(ctx context.Context, in *CompactShardRequest, opts ...grpc.CallOption) (*CompactShardResponse, error) {
+	out := new(CompactShardResponse)
+	err := c.cc.Invoke(ctx, "/replication.OxiaCoordination/CompactShard", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oxiaCoordinationClient) GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (OxiaCoordination_GetSnapshotClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OxiaCoordination_ServiceDesc.Streams[1], "/replication.OxiaCoordination/GetSnapshot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &oxiaCoordinationGetSnapshotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OxiaCoordination_GetSnapshotClient interface {
+	Recv() (*SnapshotChunk, error)
+	grpc.ClientStream
+}
+
+type oxiaCoordinationGetSnapshotClient struct {
+	grpc.ClientStream
+}
+
+func (x *oxiaCoordinationGetSnapshotClient) Recv() (*SnapshotChunk, error) {
+	m := new(SnapshotChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // OxiaCoordinationServer is the server API for OxiaCoordination service.
 // All implementations must embed UnimplementedOxiaCoordinationServer
 // for forward compatibility
@@ -125,8 +238,17 @@ type OxiaCoordinationServer interface {
 	NewTerm(context.Context, *NewTermRequest) (*NewTermResponse, error)
 	BecomeLeader(context.Context, *BecomeLeaderRequest) (*BecomeLeaderResponse, error)
 	AddFollower(context.Context, *AddFollowerRequest) (*AddFollowerResponse, error)
+	RemoveFollower(context.Context, *RemoveFollowerRequest) (*RemoveFollowerResponse, error)
+	TransferLeadership(context.Context, *TransferLeadershipRequest) (*TransferLeadershipResponse, error)
+	SplitShard(context.Context, *SplitShardRequest) (*SplitShardResponse, error)
 	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	GetInfo(context.Context, *GetInfoRequest) (*GetInfoResponse, error)
 	DeleteShard(context.Context, *DeleteShardRequest) (*DeleteShardResponse, error)
+	AssignShard(context.Context, *AssignShardRequest) (*AssignShardResponse, error)
+	UnassignShard(context.Context, *UnassignShardRequest) (*UnassignShardResponse, error)
+	SetMaintenance(context.Context, *SetMaintenanceRequest) (*SetMaintenanceResponse, error)
+	CompactShard(context.Context, *CompactShardRequest) (*CompactShardResponse, error)
+	GetSnapshot(*GetSnapshotRequest, OxiaCoordination_GetSnapshotServer) error
 	mustEmbedUnimplementedOxiaCoordinationServer()
 }
 
@@ -146,12 +268,39 @@ func (UnimplementedOxiaCoordinationServer) BecomeLeader(context.Context, *Become
 func (UnimplementedOxiaCoordinationServer) AddFollower(context.Context, *AddFollowerRequest) (*AddFollowerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AddFollower not implemented")
 }
+func (UnimplementedOxiaCoordinationServer) RemoveFollower(context.Context, *RemoveFollowerRequest) (*RemoveFollowerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveFollower not implemented")
+}
+func (UnimplementedOxiaCoordinationServer) TransferLeadership(context.Context, *TransferLeadershipRequest) (*TransferLeadershipResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransferLeadership not implemented")
+}
+func (UnimplementedOxiaCoordinationServer) SplitShard(context.Context, *SplitShardRequest) (*SplitShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SplitShard not implemented")
+}
 func (UnimplementedOxiaCoordinationServer) GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
 }
+func (UnimplementedOxiaCoordinationServer) GetInfo(context.Context, *GetInfoRequest) (*GetInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInfo not implemented")
+}
 func (UnimplementedOxiaCoordinationServer) DeleteShard(context.Context, *DeleteShardRequest) (*DeleteShardResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteShard not implemented")
 }
+func (UnimplementedOxiaCoordinationServer) AssignShard(context.Context, *AssignShardRequest) (*AssignShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignShard not implemented")
+}
+func (UnimplementedOxiaCoordinationServer) UnassignShard(context.Context, *UnassignShardRequest) (*UnassignShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnassignShard not implemented")
+}
+func (UnimplementedOxiaCoordinationServer) SetMaintenance(context.Context, *SetMaintenanceRequest) (*SetMaintenanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMaintenance not implemented")
+}
+func (UnimplementedOxiaCoordinationServer) CompactShard(context.Context, *CompactShardRequest) (*CompactShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompactShard not implemented")
+}
+func (UnimplementedOxiaCoordinationServer) GetSnapshot(*GetSnapshotRequest, OxiaCoordination_GetSnapshotServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetSnapshot not implemented")
+}
 func (UnimplementedOxiaCoordinationServer) mustEmbedUnimplementedOxiaCoordinationServer() {}
 
 // UnsafeOxiaCoordinationServer may be embedded to opt out of forward compatibility for this service.
@@ -245,6 +394,60 @@ func _OxiaCoordination_AddFollower_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OxiaCoordination_RemoveFollower_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveFollowerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OxiaCoordinationServer).RemoveFollower(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/replication.OxiaCoordination/RemoveFollower",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OxiaCoordinationServer).RemoveFollower(ctx, req.(*RemoveFollowerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OxiaCoordination_TransferLeadership_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferLeadershipRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OxiaCoordinationServer).TransferLeadership(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/replication.OxiaCoordination/TransferLeadership",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OxiaCoordinationServer).TransferLeadership(ctx, req.(*TransferLeadershipRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OxiaCoordination_SplitShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SplitShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OxiaCoordinationServer).SplitShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/replication.OxiaCoordination/SplitShard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OxiaCoordinationServer).SplitShard(ctx, req.(*SplitShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _OxiaCoordination_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetStatusRequest)
 	if err := dec(in); err != nil {
@@ -263,6 +466,24 @@ func _OxiaCoordination_GetStatus_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OxiaCoordination_GetInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OxiaCoordinationServer).GetInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/replication.OxiaCoordination/GetInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OxiaCoordinationServer).GetInfo(ctx, req.(*GetInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _OxiaCoordination_DeleteShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteShardRequest)
 	if err := dec(in); err != nil {
@@ -281,6 +502,99 @@ func _OxiaCoordination_DeleteShard_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OxiaCoordination_AssignShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OxiaCoordinationServer).AssignShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/replication.OxiaCoordination/AssignShard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OxiaCoordinationServer).AssignShard(ctx, req.(*AssignShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OxiaCoordination_UnassignShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnassignShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OxiaCoordinationServer).UnassignShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/replication.OxiaCoordination/UnassignShard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OxiaCoordinationServer).UnassignShard(ctx, req.(*UnassignShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OxiaCoordination_SetMaintenance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMaintenanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OxiaCoordinationServer).SetMaintenance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/replication.OxiaCoordination/SetMaintenance",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OxiaCoordinationServer).SetMaintenance(ctx, req.(*SetMaintenanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OxiaCoordination_CompactShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompactShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OxiaCoordinationServer).CompactShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/replication.OxiaCoordination/CompactShard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OxiaCoordinationServer).CompactShard(ctx, req.(*CompactShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OxiaCoordination_GetSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetSnapshotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OxiaCoordinationServer).GetSnapshot(m, &oxiaCoordinationGetSnapshotServer{stream})
+}
+
+type OxiaCoordination_GetSnapshotServer interface {
+	Send(*SnapshotChunk) error
+	grpc.ServerStream
+}
+
+type oxiaCoordinationGetSnapshotServer struct {
+	grpc.ServerStream
+}
+
+func (x *oxiaCoordinationGetSnapshotServer) Send(m *SnapshotChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // OxiaCoordination_ServiceDesc is the grpc.ServiceDesc for OxiaCoordination service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -300,14 +614,46 @@ var OxiaCoordination_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AddFollower",
 			Handler:    _OxiaCoordination_AddFollower_Handler,
 		},
+		{
+			MethodName: "RemoveFollower",
+			Handler:    _OxiaCoordination_RemoveFollower_Handler,
+		},
+		{
+			MethodName: "TransferLeadership",
+			Handler:    _OxiaCoordination_TransferLeadership_Handler,
+		},
+		{
+			MethodName: "SplitShard",
+			Handler:    _OxiaCoordination_SplitShard_Handler,
+		},
 		{
 			MethodName: "GetStatus",
 			Handler:    _OxiaCoordination_GetStatus_Handler,
 		},
+		{
+			MethodName: "GetInfo",
+			Handler:    _OxiaCoordination_GetInfo_Handler,
+		},
 		{
 			MethodName: "DeleteShard",
 			Handler:    _OxiaCoordination_DeleteShard_Handler,
 		},
+		{
+			MethodName: "AssignShard",
+			Handler:    _OxiaCoordination_AssignShard_Handler,
+		},
+		{
+			MethodName: "UnassignShard",
+			Handler:    _OxiaCoordination_UnassignShard_Handler,
+		},
+		{
+			MethodName: "SetMaintenance",
+			Handler:    _OxiaCoordination_SetMaintenance_Handler,
+		},
+		{
+			MethodName: "CompactShard",
+			Handler:    _OxiaCoordination_CompactShard_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -315,6 +661,11 @@ var OxiaCoordination_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _OxiaCoordination_PushShardAssignments_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "GetSnapshot",
+			Handler:       _OxiaCoordination_GetSnapshot_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "replication.proto",
 }
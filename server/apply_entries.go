@@ -0,0 +1,51 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+)
+
+// The committed entries are applied in batches, which are committed once
+// they reach either limit, so that the memory stays bounded when applying
+// a long range of entries.
+const (
+	maxApplyBatchCount = 1000
+	maxApplyBatchSize  = 4 * 1024 * 1024
+)
+
+// Apply a committed entry of the log to the database. The writes are added
+// to the batch, which the caller has to commit.
+func applyLogEntry(db kv.DB, batch kv.ApplyBatch, entry *proto.LogEntry, logEntryValue *proto.LogEntryValue) error {
+	if split := logEntryValue.GetSplit(); split != nil {
+		// The split is applied on its own, after the entries before it
+		if err := batch.Commit(); err != nil {
+			return err
+		}
+		return db.ProcessShardSplit(split, entry.Term, entry.Offset, entry.Timestamp, SessionUpdateOperationCallback)
+	}
+
+	for _, writeRequest := range logEntryValue.GetRequests().GetWrites() {
+		if _, err := batch.ProcessWrite(writeRequest, entry.Term, entry.Offset, entry.Timestamp, SessionUpdateOperationCallback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isApplyBatchFull(batch kv.ApplyBatch) bool {
+	return batch.Count() >= maxApplyBatchCount || batch.Size() >= maxApplyBatchSize
+}
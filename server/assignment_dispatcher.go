@@ -46,6 +46,10 @@ type ShardAssignmentsDispatcher interface {
 	Initialized() bool
 	PushShardAssignments(stream proto.OxiaCoordination_PushShardAssignmentsServer) error
 	RegisterForUpdates(req *proto.ShardAssignmentsRequest, client Client) error
+
+	// GetShardAssignment returns the last assignment of the shard received
+	// by this node, or nil if the shard is unknown.
+	GetShardAssignment(shardId int64) *proto.ShardAssignment
 }
 
 type shardAssignmentDispatcher struct {
@@ -66,6 +70,11 @@ type shardAssignmentDispatcher struct {
 
 //nolint:revive
 func (s *shardAssignmentDispatcher) RegisterForUpdates(req *proto.ShardAssignmentsRequest, clientStream Client) error {
+	assignmentsInterceptorFunc, err := s.assignmentsInterceptorFunc(clientStream)
+	if err != nil {
+		return err
+	}
+
 	s.Lock()
 
 	if s.assignments == nil {
@@ -78,23 +87,19 @@ func (s *shardAssignmentDispatcher) RegisterForUpdates(req *proto.ShardAssignmen
 		namespace = common.DefaultNamespace
 	}
 
-	if _, ok := s.assignments.Namespaces[namespace]; !ok {
+	lastSent, ok := s.assignments.Namespaces[namespace]
+	if !ok {
 		s.Unlock()
 		return common.ErrorNamespaceNotFound
 	}
 
-	initialAssignments := filterByNamespace(s.assignments, namespace)
+	initialAssignments := common.NextShardAssignments(nil, s.assignments, namespace, req.AcceptDeltas)
 
 	clientCh := make(chan *proto.ShardAssignments)
 	clientId := s.nextClientId
 	s.nextClientId++
 
 	s.clients[clientId] = clientCh
-
-	assignmentsInterceptorFunc, err := s.assignmentsInterceptorFunc(clientStream)
-	if err != nil {
-		return err
-	}
 	s.Unlock()
 
 	// Send initial assignments
@@ -113,8 +118,14 @@ func (s *shardAssignmentDispatcher) RegisterForUpdates(req *proto.ShardAssignmen
 				return common.ErrorCancelled
 			}
 
-			assignments = filterByNamespace(assignments, namespace)
-			err := clientStream.Send(assignmentsInterceptorFunc(assignments))
+			update := common.NextShardAssignments(lastSent, assignments, namespace, req.AcceptDeltas)
+			if update == nil {
+				// No changes in the namespace
+				continue
+			}
+			lastSent = assignments.Namespaces[namespace]
+
+			err := clientStream.Send(assignmentsInterceptorFunc(update))
 			if err != nil {
 				if status.Code(err) != codes.Canceled {
 					peerObject, _ := peer.FromContext(clientStream.Context())
@@ -144,20 +155,6 @@ func (s *shardAssignmentDispatcher) RegisterForUpdates(req *proto.ShardAssignmen
 	}
 }
 
-func filterByNamespace(assignments *proto.ShardAssignments, namespace string) *proto.ShardAssignments {
-	filtered := &proto.ShardAssignments{
-		Namespaces: map[string]*proto.NamespaceShardsAssignment{},
-	}
-
-	for ns, nsa := range assignments.Namespaces {
-		if ns == namespace {
-			filtered.Namespaces[ns] = nsa
-		}
-	}
-
-	return filtered
-}
-
 func (s *shardAssignmentDispatcher) assignmentsInterceptorFunc(clientStream Client) (func(assignments *proto.ShardAssignments) *proto.ShardAssignments, error) {
 	if s.standalone {
 		authority, err := authority(clientStream.Context())
@@ -190,6 +187,23 @@ func authority(ctx context.Context) (string, error) {
 	return "", status.Errorf(codes.Internal, "oxia: authority not identified")
 }
 
+func (s *shardAssignmentDispatcher) GetShardAssignment(shardId int64) *proto.ShardAssignment {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.assignments == nil {
+		return nil
+	}
+	for _, nsa := range s.assignments.Namespaces {
+		for _, assignment := range nsa.Assignments {
+			if assignment.Shard == shardId {
+				return assignment
+			}
+		}
+	}
+	return nil
+}
+
 func (s *shardAssignmentDispatcher) Close() error {
 	s.activeClientsGauge.Unregister()
 	s.cancel()
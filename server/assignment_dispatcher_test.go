@@ -26,6 +26,7 @@ import (
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/common/container"
@@ -324,6 +325,121 @@ func TestShardAssignmentDispatcher_MultipleNamespaces(t *testing.T) {
 	assert.NoError(t, dispatcher.Close())
 }
 
+func TestShardAssignmentDispatcher_Deltas(t *testing.T) {
+	dispatcher := NewShardAssignmentDispatcher(health.NewServer())
+
+	coordinatorStream := newMockShardAssignmentControllerStream()
+	go func() {
+		err := dispatcher.PushShardAssignments(coordinatorStream)
+		assert.NoError(t, err)
+	}()
+
+	otherNamespace := &proto.NamespaceShardsAssignment{
+		Assignments: []*proto.ShardAssignment{
+			newShardAssignment(3, "server1", 0, math.MaxUint32),
+		},
+		ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+	}
+	coordinatorStream.AddRequest(&proto.ShardAssignments{
+		Namespaces: map[string]*proto.NamespaceShardsAssignment{
+			common.DefaultNamespace: {
+				Assignments: []*proto.ShardAssignment{
+					newShardAssignment(0, "server1", 0, 100),
+					newShardAssignment(1, "server2", 101, math.MaxUint32),
+				},
+				ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+			},
+			"test-ns": otherNamespace,
+		},
+	})
+	assert.Eventually(t, func() bool {
+		return dispatcher.Initialized()
+	}, 10*time.Second, 10*time.Millisecond)
+
+	mockClient := newMockShardAssignmentClientStream()
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		req := &proto.ShardAssignmentsRequest{Namespace: common.DefaultNamespace, AcceptDeltas: true}
+		err := dispatcher.RegisterForUpdates(req, mockClient)
+		assert.NoError(t, err)
+		wg.Done()
+	}()
+
+	// The first response contains all the assignments
+	response := mockClient.GetResponse()
+	assert.False(t, response.Delta)
+	assert.Len(t, response.Namespaces[common.DefaultNamespace].Assignments, 2)
+
+	// The leader of shard 1 has changed
+	coordinatorStream.AddRequest(&proto.ShardAssignments{
+		Namespaces: map[string]*proto.NamespaceShardsAssignment{
+			common.DefaultNamespace: {
+				Assignments: []*proto.ShardAssignment{
+					newShardAssignment(0, "server1", 0, 100),
+					newShardAssignment(1, "server3", 101, math.MaxUint32),
+				},
+				ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+			},
+			"test-ns": otherNamespace,
+		},
+	})
+
+	response = mockClient.GetResponse()
+	assertShardAssignmentsEqual(t, &proto.ShardAssignments{
+		Namespaces: map[string]*proto.NamespaceShardsAssignment{
+			common.DefaultNamespace: {
+				Assignments: []*proto.ShardAssignment{
+					newShardAssignment(1, "server3", 101, math.MaxUint32),
+				},
+				ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+			},
+		},
+		Delta: true,
+	}, response)
+
+	// Shard 1 was split into shards 4 and 5, while the other namespace was
+	// deleted, which is not part of the delta
+	coordinatorStream.AddRequest(&proto.ShardAssignments{
+		Namespaces: map[string]*proto.NamespaceShardsAssignment{
+			common.DefaultNamespace: {
+				Assignments: []*proto.ShardAssignment{
+					newShardAssignment(0, "server1", 0, 100),
+					newShardAssignment(4, "server3", 101, 200),
+					newShardAssignment(5, "server3", 201, math.MaxUint32),
+				},
+				ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+			},
+		},
+	})
+
+	response = mockClient.GetResponse()
+	assertShardAssignmentsEqual(t, &proto.ShardAssignments{
+		Namespaces: map[string]*proto.NamespaceShardsAssignment{
+			common.DefaultNamespace: {
+				Assignments: []*proto.ShardAssignment{
+					newShardAssignment(4, "server3", 101, 200),
+					newShardAssignment(5, "server3", 201, math.MaxUint32),
+				},
+				ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+				RemovedShards:  []int64{1},
+			},
+		},
+		Delta: true,
+	}, response)
+
+	mockClient.cancel()
+	wg.Wait()
+	assert.Empty(t, mockClient.responses)
+
+	assert.NoError(t, dispatcher.Close())
+}
+
+func assertShardAssignmentsEqual(t *testing.T, expected *proto.ShardAssignments, actual *proto.ShardAssignments) {
+	t.Helper()
+	assert.Truef(t, pb.Equal(expected, actual), "expected: %v, actual: %v", expected, actual)
+}
+
 func newShardAssignment(id int64, leader string, minHashInclusive uint32, maxHashInclusive uint32) *proto.ShardAssignment {
 	return &proto.ShardAssignment{
 		Shard:  id,
@@ -336,3 +452,42 @@ func newShardAssignment(id int64, leader string, minHashInclusive uint32, maxHas
 		},
 	}
 }
+
+func TestShardAssignmentDispatcher_LeaderHint(t *testing.T) {
+	dispatcher := NewShardAssignmentDispatcher(health.NewServer())
+	rpc := &publicRpcServer{assignmentDispatcher: dispatcher}
+	notLeader := status.Errorf(common.CodeNodeIsNotLeader, "node is not leader for shard 1")
+
+	// No hint until the assignments are known
+	assert.Nil(t, dispatcher.GetShardAssignment(1))
+	assert.Empty(t, status.Convert(rpc.withLeaderHint(1, notLeader)).Details())
+
+	coordinatorStream := newMockShardAssignmentControllerStream()
+	go func() {
+		assert.NoError(t, dispatcher.PushShardAssignments(coordinatorStream))
+	}()
+	coordinatorStream.AddRequest(&proto.ShardAssignments{
+		Namespaces: map[string]*proto.NamespaceShardsAssignment{
+			common.DefaultNamespace: {
+				Assignments: []*proto.ShardAssignment{
+					newShardAssignment(0, "server1", 0, 100),
+					newShardAssignment(1, "server2", 100, math.MaxUint32),
+				},
+				ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+			},
+		},
+	})
+	assert.Eventually(t, dispatcher.Initialized, 10*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "server2", dispatcher.GetShardAssignment(1).Leader)
+	assert.Nil(t, dispatcher.GetShardAssignment(2))
+
+	err := rpc.withLeaderHint(1, notLeader)
+	assert.Equal(t, common.CodeNodeIsNotLeader, status.Code(err))
+	details := status.Convert(err).Details()
+	if assert.Len(t, details, 1) {
+		assert.Equal(t, "server2", details[0].(*proto.ShardAssignment).Leader)
+	}
+
+	assert.NoError(t, dispatcher.Close())
+}
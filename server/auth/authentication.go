@@ -21,7 +21,9 @@ import (
 )
 
 const (
-	ProviderOIDC = "oidc"
+	ProviderOIDC        = "oidc"
+	ProviderJWKS        = "jwks"
+	ProviderStaticToken = "static-token"
 
 	ProviderParamTypeToken = "token"
 )
@@ -54,6 +56,10 @@ func NewAuthenticationProvider(ctx context.Context, options Options) (Authentica
 	switch options.ProviderName {
 	case ProviderOIDC:
 		return NewOIDCProvider(ctx, options.ProviderParams)
+	case ProviderJWKS:
+		return NewJWKSProvider(ctx, options.ProviderParams)
+	case ProviderStaticToken:
+		return NewStaticTokenProvider(options.ProviderParams)
 	default:
 		return nil, ErrUnsupportedProvider
 	}
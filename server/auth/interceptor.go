@@ -44,26 +44,37 @@ type GrpcAuthenticationDelegator struct {
 
 func (delegator *GrpcAuthenticationDelegator) GetUnaryInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-		_, err := delegator.validate(ctx, delegator.provider)
+		userName, err := delegator.validate(ctx, delegator.provider)
 		if err != nil {
 			return nil, status.Error(codes.Unauthenticated, err.Error())
 		}
-		// todo: set username to metadata to support authorization
-		return handler(ctx, req)
+		return handler(WithPrincipal(ctx, Principal{UserName: userName}), req)
 	}
 }
 
 func (delegator *GrpcAuthenticationDelegator) GetStreamInterceptor() grpc.StreamServerInterceptor {
 	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		_, err := delegator.validate(ss.Context(), delegator.provider)
+		userName, err := delegator.validate(ss.Context(), delegator.provider)
 		if err != nil {
 			return status.Error(codes.Unauthenticated, err.Error())
 		}
-		// todo: set username to metadata to support authorization
-		return handler(srv, ss)
+		return handler(srv, &authenticatedServerStream{
+			ServerStream: ss,
+			ctx:          WithPrincipal(ss.Context(), Principal{UserName: userName}),
+		})
 	}
 }
 
+type authenticatedServerStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *authenticatedServerStream) Context() context.Context {
+	return s.ctx
+}
+
 func NewGrpcAuthenticationDelegator(provider AuthenticationProvider) (*GrpcAuthenticationDelegator, error) {
 	delegator := &GrpcAuthenticationDelegator{
 		provider: provider,
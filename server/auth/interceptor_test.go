@@ -0,0 +1,60 @@
+// Copyright 2024 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcAuthenticationDelegator_Principal(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	writeTokensFile(t, tokensFile, "user-1:token-1\n", time.Now())
+	provider, err := NewStaticTokenProvider(fmt.Sprintf(`{"tokensFile":"%s"}`, tokensFile))
+	require.NoError(t, err)
+	defer provider.(io.Closer).Close()
+	delegator, err := NewGrpcAuthenticationDelegator(provider)
+	require.NoError(t, err)
+
+	newContext := func(token string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{}})
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataAuthorizationKey, TokenPrefix+token))
+	}
+	handler := func(ctx context.Context, _ any) (any, error) {
+		principal, ok := PrincipalFromContext(ctx)
+		assert.True(t, ok)
+		return principal, nil
+	}
+
+	res, err := delegator.GetUnaryInterceptor()(newContext("token-1"), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, Principal{UserName: "user-1"}, res)
+
+	_, err = delegator.GetUnaryInterceptor()(newContext("token-2"), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
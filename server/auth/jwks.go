@@ -0,0 +1,107 @@
+// Copyright 2024 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrEmptyJWKSURL = errors.New("empty JWKS URL")
+	ErrEmptyIssuer  = errors.New("empty issuer")
+)
+
+type JWKSOptions struct {
+	JWKSURL          string `json:"jwksURL,omitempty"`
+	Issuer           string `json:"issuer,omitempty"`
+	AllowedAudiences string `json:"allowedAudiences,omitempty"`
+	UserNameClaim    string `json:"userNameClaim,omitempty"`
+}
+
+func (op *JWKSOptions) Validate() error {
+	if op.JWKSURL == "" {
+		return ErrEmptyJWKSURL
+	}
+	if op.Issuer == "" {
+		return ErrEmptyIssuer
+	}
+	if op.AllowedAudiences == "" {
+		return ErrEmptyAllowedAudiences
+	}
+	return nil
+}
+
+func (op *JWKSOptions) withDefault() {
+	if op.UserNameClaim == "" {
+		op.UserNameClaim = DefaultUserNameCalm
+	}
+}
+
+// JWKSProvider validates JWTs signed with the keys published at a JWKS URL,
+// without relying on the OIDC discovery of the issuer.
+type JWKSProvider struct {
+	userNameClaim    string
+	allowedAudiences map[string]string
+
+	verifier *oidc.IDTokenVerifier
+}
+
+func (*JWKSProvider) AcceptParamType() string {
+	return ProviderParamTypeToken
+}
+
+func (p *JWKSProvider) Authenticate(ctx context.Context, param any) (string, error) {
+	token, ok := param.(string)
+	if !ok {
+		return "", ErrUnMatchedAuthenticationParamType
+	}
+	// The signature, the issuer and the expiration are checked by the verifier
+	idToken, err := p.verifier.Verify(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	return userNameFromToken(idToken, p.userNameClaim, p.allowedAudiences)
+}
+
+func NewJWKSProvider(ctx context.Context, jsonParam string) (AuthenticationProvider, error) {
+	jwksParams := &JWKSOptions{}
+	if err := json.Unmarshal([]byte(jsonParam), jwksParams); err != nil {
+		return nil, err
+	}
+	jwksParams.withDefault()
+	if err := jwksParams.Validate(); err != nil {
+		return nil, err
+	}
+
+	// The keys are fetched lazily, and fetched again when a token is signed
+	// with an unknown key
+	ctx = oidc.ClientContext(ctx, &http.Client{Timeout: 30 * time.Second})
+	keySet := oidc.NewRemoteKeySet(ctx, jwksParams.JWKSURL)
+
+	return &JWKSProvider{
+		userNameClaim:    jwksParams.UserNameClaim,
+		allowedAudiences: parseAllowedAudiences(jwksParams.AllowedAudiences),
+		verifier: oidc.NewVerifier(jwksParams.Issuer, keySet, &oidc.Config{
+			SkipClientIDCheck: true,
+			Now:               time.Now,
+		}),
+	}, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/mockoidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJWKSProvider(t *testing.T, mockOIDC *mockoidc.MockOIDC, audience string) AuthenticationProvider {
+	t.Helper()
+
+	provider, err := NewAuthenticationProvider(context.Background(), Options{
+		ProviderName: ProviderJWKS,
+		ProviderParams: fmt.Sprintf(`{"jwksURL":"%s","issuer":"%s","allowedAudiences":"%s"}`,
+			mockOIDC.JWKSEndpoint(), mockOIDC.Issuer(), audience),
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func signToken(t *testing.T, mockOIDC *mockoidc.MockOIDC, issuer string, audience string, expiresAt time.Time) string {
+	t.Helper()
+
+	token, err := mockOIDC.Keypair.SignJWT(&jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    issuer,
+		Subject:   "user-1",
+	})
+	require.NoError(t, err)
+	return token
+}
+
+func TestJWKSProvider(t *testing.T) {
+	mockOIDC, err := mockoidc.Run()
+	require.NoError(t, err)
+	defer func() {
+		_ = mockOIDC.Shutdown()
+	}()
+
+	provider := newTestJWKSProvider(t, mockOIDC, "oxia")
+	ctx := context.Background()
+
+	userName, err := provider.Authenticate(ctx, signToken(t, mockOIDC, mockOIDC.Issuer(), "oxia", time.Now().Add(time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", userName)
+
+	// Expired token
+	_, err = provider.Authenticate(ctx, signToken(t, mockOIDC, mockOIDC.Issuer(), "oxia", time.Now().Add(-time.Minute)))
+	assert.Error(t, err)
+
+	// Wrong audience
+	_, err = provider.Authenticate(ctx, signToken(t, mockOIDC, mockOIDC.Issuer(), "other", time.Now().Add(time.Hour)))
+	assert.ErrorIs(t, err, ErrForbiddenAudience)
+
+	// Wrong issuer
+	_, err = provider.Authenticate(ctx, signToken(t, mockOIDC, "https://other-issuer", "oxia", time.Now().Add(time.Hour)))
+	assert.Error(t, err)
+
+	// Malformed token
+	_, err = provider.Authenticate(ctx, "wrongToken")
+	assert.Error(t, err)
+}
+
+func TestJWKSProvider_InvalidOptions(t *testing.T) {
+	for _, params := range []string{
+		`{"issuer":"https://issuer","allowedAudiences":"oxia"}`,
+		`{"jwksURL":"https://issuer/jwks","allowedAudiences":"oxia"}`,
+		`{"jwksURL":"https://issuer/jwks","issuer":"https://issuer"}`,
+	} {
+		_, err := NewAuthenticationProvider(context.Background(), Options{
+			ProviderName:   ProviderJWKS,
+			ProviderParams: params,
+		})
+		assert.Error(t, err, params)
+	}
+}
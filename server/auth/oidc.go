@@ -105,16 +105,22 @@ func (p *OIDCProvider) Authenticate(ctx context.Context, param any) (string, err
 	if err != nil {
 		return "", err
 	}
+	return userNameFromToken(idToken, p.userNameClaim, p.allowedAudiences)
+}
+
+// userNameFromToken checks the audience of a verified token and extracts
+// the user name from its claims.
+func userNameFromToken(idToken *oidc.IDToken, userNameClaim string, allowedAudiences map[string]string) (string, error) {
 	rawClaims := map[string]json.RawMessage{}
-	if err = idToken.Claims(&rawClaims); err != nil {
+	if err := idToken.Claims(&rawClaims); err != nil {
 		return "", err
 	}
-	rawMessage, ok := rawClaims[p.userNameClaim]
+	rawMessage, ok := rawClaims[userNameClaim]
 	if !ok {
 		return "", ErrUserNameNotFound
 	}
 	var userName string
-	if err = json.Unmarshal(rawMessage, &userName); err != nil {
+	if err := json.Unmarshal(rawMessage, &userName); err != nil {
 		return "", err
 	}
 
@@ -122,7 +128,7 @@ func (p *OIDCProvider) Authenticate(ctx context.Context, param any) (string, err
 	audienceAllowed := false
 	audienceArr := idToken.Audience
 	for _, audience := range audienceArr {
-		if _, ok := p.allowedAudiences[audience]; ok {
+		if _, ok := allowedAudiences[audience]; ok {
 			audienceAllowed = true
 		}
 	}
@@ -132,6 +138,16 @@ func (p *OIDCProvider) Authenticate(ctx context.Context, param any) (string, err
 	return userName, nil
 }
 
+func parseAllowedAudiences(audiences string) map[string]string {
+	allowedAudienceMap := map[string]string{}
+	allowedAudienceArr := strings.Split(audiences, ",")
+	for i := range allowedAudienceArr {
+		allowedAudience := allowedAudienceArr[i]
+		allowedAudienceMap[allowedAudience] = AllowedAudienceDefaultValue
+	}
+	return allowedAudienceMap
+}
+
 func NewOIDCProvider(ctx context.Context, jsonParam string) (AuthenticationProvider, error) {
 	oidcParams := &OIDCOptions{}
 	if err := json.Unmarshal([]byte(jsonParam), oidcParams); err != nil {
@@ -141,15 +157,9 @@ func NewOIDCProvider(ctx context.Context, jsonParam string) (AuthenticationProvi
 	if err := oidcParams.Validate(); err != nil {
 		return nil, err
 	}
-	allowedAudienceMap := map[string]string{}
-	allowedAudienceArr := strings.Split(oidcParams.AllowedAudiences, ",")
-	for i := range allowedAudienceArr {
-		allowedAudience := allowedAudienceArr[i]
-		allowedAudienceMap[allowedAudience] = AllowedAudienceDefaultValue
-	}
 	oidcProvider := &OIDCProvider{
 		userNameClaim:    oidcParams.UserNameClaim,
-		allowedAudiences: allowedAudienceMap,
+		allowedAudiences: parseAllowedAudiences(oidcParams.AllowedAudiences),
 		providers:        make(map[string]*ProviderWithVerifier),
 	}
 
@@ -0,0 +1,192 @@
+// Copyright 2024 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/common"
+)
+
+var (
+	ErrEmptyTokensFile = errors.New("empty tokens file")
+	ErrUnknownToken    = errors.New("unknown token")
+)
+
+// The tokens file is checked for changes in the background, so that the
+// authentication of the requests never touches the filesystem.
+var staticTokensReloadInterval = 10 * time.Second
+
+type StaticTokenOptions struct {
+	// TokensFile contains one "<user-name>:<token>" entry per line. Empty
+	// lines and lines starting with '#' are ignored.
+	TokensFile string `json:"tokensFile,omitempty"`
+}
+
+func (op *StaticTokenOptions) Validate() error {
+	if op.TokensFile == "" {
+		return ErrEmptyTokensFile
+	}
+	return nil
+}
+
+// StaticTokenProvider accepts the tokens listed in a file. The file is
+// read again when it's modified, so that tokens can be added or revoked
+// without restarting the server.
+type StaticTokenProvider struct {
+	io.Closer
+	tokensFile string
+
+	// The tokens are indexed by their hash, so that the lookup time doesn't
+	// depend on the token content
+	users atomic.Pointer[map[[sha256.Size]byte]string]
+
+	// Only accessed by the loading of the file
+	modTime time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (*StaticTokenProvider) AcceptParamType() string {
+	return ProviderParamTypeToken
+}
+
+func (p *StaticTokenProvider) Authenticate(_ context.Context, param any) (string, error) {
+	token, ok := param.(string)
+	if !ok {
+		return "", ErrUnMatchedAuthenticationParamType
+	}
+
+	userName, ok := (*p.users.Load())[sha256.Sum256([]byte(token))]
+	if !ok {
+		return "", ErrUnknownToken
+	}
+	return userName, nil
+}
+
+func (p *StaticTokenProvider) run() {
+	ticker := time.NewTicker(staticTokensReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.maybeReload()
+		}
+	}
+}
+
+func (p *StaticTokenProvider) maybeReload() {
+	info, err := os.Stat(p.tokensFile)
+	if err != nil || info.ModTime().Equal(p.modTime) {
+		return
+	}
+
+	// Keep the previous tokens if the new file can't be read
+	if err := p.load(); err != nil {
+		slog.Warn(
+			"Failed to reload the tokens file",
+			slog.String("tokens-file", p.tokensFile),
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (p *StaticTokenProvider) load() error {
+	info, err := os.Stat(p.tokensFile)
+	if err != nil {
+		return err
+	}
+	// The file is not read again until it changes, even if it's invalid
+	p.modTime = info.ModTime()
+
+	content, err := os.ReadFile(p.tokensFile)
+	if err != nil {
+		return err
+	}
+
+	users := map[[sha256.Size]byte]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		userName, token, found := strings.Cut(line, ":")
+		if !found || userName == "" || token == "" {
+			return errors.Errorf("invalid entry at line %d of %s", lineNumber, p.tokensFile)
+		}
+		users[sha256.Sum256([]byte(token))] = userName
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	p.users.Store(&users)
+	return nil
+}
+
+func (p *StaticTokenProvider) Close() error {
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
+
+func NewStaticTokenProvider(jsonParam string) (AuthenticationProvider, error) {
+	staticTokenParams := &StaticTokenOptions{}
+	if err := json.Unmarshal([]byte(jsonParam), staticTokenParams); err != nil {
+		return nil, err
+	}
+	if err := staticTokenParams.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &StaticTokenProvider{tokensFile: staticTokenParams.TokensFile}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.wg.Add(1)
+	go common.DoWithLabels(
+		p.ctx,
+		map[string]string{
+			"oxia": "static-tokens-reloader",
+		},
+		func() {
+			defer p.wg.Done()
+			p.run()
+		},
+	)
+	return p, nil
+}
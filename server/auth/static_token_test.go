@@ -0,0 +1,98 @@
+// Copyright 2024 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTokensFile(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestStaticTokenProvider(t *testing.T) {
+	reloadInterval := staticTokensReloadInterval
+	staticTokensReloadInterval = 10 * time.Millisecond
+	defer func() {
+		staticTokensReloadInterval = reloadInterval
+	}()
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	writeTokensFile(t, tokensFile, "# tokens\nuser-1:token-1\n\nuser-2:token-2\n", time.Now())
+
+	provider, err := NewAuthenticationProvider(context.Background(), Options{
+		ProviderName:   ProviderStaticToken,
+		ProviderParams: fmt.Sprintf(`{"tokensFile":"%s"}`, tokensFile),
+	})
+	require.NoError(t, err)
+	defer provider.(io.Closer).Close()
+	ctx := context.Background()
+
+	userName, err := provider.Authenticate(ctx, "token-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", userName)
+
+	userName, err = provider.Authenticate(ctx, "token-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-2", userName)
+
+	_, err = provider.Authenticate(ctx, "token-3")
+	assert.ErrorIs(t, err, ErrUnknownToken)
+
+	// The tokens are reloaded when the file changes
+	writeTokensFile(t, tokensFile, "user-1:token-1\nuser-3:token-3\n", time.Now().Add(time.Minute))
+
+	assert.Eventually(t, func() bool {
+		userName, err = provider.Authenticate(ctx, "token-3")
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "user-3", userName)
+
+	_, err = provider.Authenticate(ctx, "token-2")
+	assert.ErrorIs(t, err, ErrUnknownToken)
+
+	// An invalid file doesn't revoke the current tokens
+	writeTokensFile(t, tokensFile, "invalid", time.Now().Add(2*time.Minute))
+	time.Sleep(10 * staticTokensReloadInterval)
+
+	userName, err = provider.Authenticate(ctx, "token-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", userName)
+}
+
+func TestStaticTokenProvider_InvalidFile(t *testing.T) {
+	_, err := NewStaticTokenProvider(`{}`)
+	assert.ErrorIs(t, err, ErrEmptyTokensFile)
+
+	_, err = NewStaticTokenProvider(fmt.Sprintf(`{"tokensFile":"%s"}`, filepath.Join(t.TempDir(), "missing")))
+	assert.Error(t, err)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	writeTokensFile(t, tokensFile, "user-1:\n", time.Now())
+	_, err = NewStaticTokenProvider(fmt.Sprintf(`{"tokensFile":"%s"}`, tokensFile))
+	assert.Error(t, err)
+}
@@ -81,7 +81,9 @@ func BenchmarkServer(b *testing.B) {
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(5*time.Minute))
 	defer cancel()
 
-	perf.New(perfConf).Run(ctx)
+	if _, err = perf.New(perfConf).Run(ctx); err != nil {
+		b.Fatal(err)
+	}
 
 	pprof.StopCPUProfile()
 
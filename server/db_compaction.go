@@ -0,0 +1,333 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+)
+
+const (
+	// DefaultDbCompactionConcurrency is the number of shards compacted at
+	// the same time when it's not configured
+	DefaultDbCompactionConcurrency = 1
+
+	// How often the scheduler looks for the shards that are due for a
+	// compaction
+	dbCompactionCheckInterval = 1 * time.Minute
+
+	// A follower that has more committed entries than this left to apply
+	// is catching up with the leader, and is not compacted
+	maxCompactionLagEntries = 1000
+)
+
+// DbCompactionOptions configures the compactions of the databases of the
+// shards, which drop the deleted and the overwritten records that the
+// engine might otherwise keep around for a long time.
+type DbCompactionOptions struct {
+	// Interval is the time between the compactions of the database of each
+	// shard. 0 disables the scheduled compactions
+	Interval time.Duration
+
+	// Window restricts the scheduled compactions to a time of the day, as
+	// "HH:MM-HH:MM" in local time. The window can span midnight, e.g.
+	// "22:00-04:00". Empty means any time
+	Window string
+
+	// Concurrency is the max number of shards that are compacted at the
+	// same time on the node, including the compactions requested on demand.
+	// 0 means DefaultDbCompactionConcurrency
+	Concurrency int
+}
+
+// Validate checks that the window can be parsed, and that the interval and
+// the concurrency are not negative.
+func (o DbCompactionOptions) Validate() error {
+	if o.Interval < 0 {
+		return errors.Errorf("invalid db compaction interval %s", o.Interval)
+	}
+	if o.Concurrency < 0 {
+		return errors.Errorf("invalid db compaction concurrency %d", o.Concurrency)
+	}
+	_, err := parseCompactionWindow(o.Window)
+	return err
+}
+
+func (o DbCompactionOptions) concurrency() int {
+	if o.Concurrency <= 0 {
+		return DefaultDbCompactionConcurrency
+	}
+	return o.Concurrency
+}
+
+// compactionWindow is a time of the day, in minutes since midnight. The
+// start is inclusive and the end is exclusive.
+type compactionWindow struct {
+	start int
+	end   int
+}
+
+// parseCompactionWindow returns nil for an empty window, which contains any
+// time of the day.
+func parseCompactionWindow(s string) (*compactionWindow, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, errors.Errorf("invalid db compaction window %q: expected HH:MM-HH:MM", s)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(startStr))
+	if err != nil {
+		return nil, errors.Errorf("invalid db compaction window %q: expected HH:MM-HH:MM", s)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(endStr))
+	if err != nil {
+		return nil, errors.Errorf("invalid db compaction window %q: expected HH:MM-HH:MM", s)
+	}
+
+	w := &compactionWindow{
+		start: start.Hour()*60 + start.Minute(),
+		end:   end.Hour()*60 + end.Minute(),
+	}
+	if w.start == w.end {
+		return nil, errors.Errorf("invalid db compaction window %q: the window is empty", s)
+	}
+	return w, nil
+}
+
+func (w *compactionWindow) contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	// The window spans midnight
+	return minute >= w.start || minute < w.end
+}
+
+// The controllers compact the database of their shard when asked by the
+// compaction scheduler. The shards that are installing a snapshot or
+// catching up with the leader fail with common.ErrorShardCatchingUp.
+type dbCompactor interface {
+	compactDb() (reclaimedBytes int64, err error)
+}
+
+// dbCompactionScheduler compacts the database of each shard once per
+// interval, within the window. The compactions are bounded in number, so
+// that they don't take the whole disk bandwidth of the node.
+type dbCompactionScheduler struct {
+	sync.Mutex
+
+	options DbCompactionOptions
+	window  *compactionWindow
+	clock   common.Clock
+
+	// targets returns the controllers of the shards hosted on the node
+	targets func() map[int64]dbCompactor
+
+	// A slot is taken by each compaction, scheduled or on demand
+	slots chan struct{}
+
+	// The time of the last compaction of each shard, or the time at which
+	// the shard was first seen, so that the shards are not all compacted
+	// when the node starts
+	lastCompaction map[int64]time.Time
+
+	log *slog.Logger
+}
+
+func newDbCompactionScheduler(options DbCompactionOptions, clock common.Clock,
+	targets func() map[int64]dbCompactor) *dbCompactionScheduler {
+	s := &dbCompactionScheduler{
+		options:        options,
+		clock:          clock,
+		targets:        targets,
+		slots:          make(chan struct{}, options.concurrency()),
+		lastCompaction: make(map[int64]time.Time),
+		log: slog.With(
+			slog.String("component", "db-compaction-scheduler"),
+		),
+	}
+
+	window, err := parseCompactionWindow(options.Window)
+	if err != nil {
+		s.log.Error(
+			"Disabling the scheduled db compactions",
+			slog.Any("error", err),
+		)
+		s.options.Interval = 0
+	}
+	s.window = window
+	return s
+}
+
+func (s *dbCompactionScheduler) run(ctx context.Context) {
+	if s.options.Interval <= 0 {
+		return
+	}
+
+	s.log.Info(
+		"Scheduling the db compactions",
+		slog.Duration("interval", s.options.Interval),
+		slog.String("window", s.options.Window),
+		slog.Int("concurrency", s.options.concurrency()),
+	)
+
+	ticker := time.NewTicker(min(s.options.Interval, dbCompactionCheckInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.compactDueShards(ctx)
+		}
+	}
+}
+
+// compactDueShards compacts the shards whose last compaction is older than
+// the interval, the oldest first. The shards that are left when the window
+// closes are compacted in the next window.
+func (s *dbCompactionScheduler) compactDueShards(ctx context.Context) {
+	targets := s.targets()
+	due := s.dueShards(targets)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, shardId := range due {
+		if !s.window.contains(s.clock.Now()) || !s.acquire(ctx) {
+			return
+		}
+
+		shardId := shardId
+		wg.Add(1)
+		go common.DoWithLabels(
+			ctx,
+			map[string]string{
+				"oxia": "db-compaction",
+			},
+			func() {
+				defer wg.Done()
+				defer s.release()
+				_, _ = s.compact(shardId, targets[shardId])
+			},
+		)
+	}
+}
+
+func (s *dbCompactionScheduler) dueShards(targets map[int64]dbCompactor) []int64 {
+	s.Lock()
+	defer s.Unlock()
+
+	for shardId := range s.lastCompaction {
+		if _, ok := targets[shardId]; !ok {
+			delete(s.lastCompaction, shardId)
+		}
+	}
+
+	now := s.clock.Now()
+	var due []int64
+	for shardId := range targets {
+		last, ok := s.lastCompaction[shardId]
+		if !ok {
+			s.lastCompaction[shardId] = now
+			continue
+		}
+		if now.Sub(last) >= s.options.Interval {
+			due = append(due, shardId)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return s.lastCompaction[due[i]].Before(s.lastCompaction[due[j]])
+	})
+	return due
+}
+
+// compactShard compacts the shard on demand, waiting for a compaction slot
+// if needed.
+func (s *dbCompactionScheduler) compactShard(ctx context.Context, shardId int64, target dbCompactor) (int64, error) {
+	if !s.acquire(ctx) {
+		return 0, ctx.Err()
+	}
+	defer s.release()
+
+	return s.compact(shardId, target)
+}
+
+func (s *dbCompactionScheduler) acquire(ctx context.Context) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *dbCompactionScheduler) release() {
+	<-s.slots
+}
+
+func (s *dbCompactionScheduler) compact(shardId int64, target dbCompactor) (int64, error) {
+	log := s.log.With(
+		slog.Int64("shard", shardId),
+	)
+
+	start := time.Now()
+	reclaimed, err := target.compactDb()
+	switch status.Code(err) {
+	case common.CodeShardCatchingUp:
+		// The shard is compacted again at the next check, once it's caught up
+		log.Debug("Skipping the db compaction of a shard that is catching up")
+		return 0, err
+	case common.CodeAlreadyClosed:
+		return 0, err
+	}
+
+	if err != nil {
+		log.Warn(
+			"Failed to compact the database of the shard",
+			slog.Any("error", err),
+		)
+	} else {
+		log.Info(
+			"Compacted the database of the shard",
+			slog.Int64("reclaimed-bytes", reclaimed),
+			slog.Duration("elapsed-time", time.Since(start)),
+		)
+	}
+
+	// A failed compaction is not retried before the next interval either
+	s.Lock()
+	s.lastCompaction[shardId] = s.clock.Now()
+	s.Unlock()
+	return reclaimed, err
+}
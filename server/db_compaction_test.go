@@ -0,0 +1,273 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+// fakeDbCompactor blocks each compaction until it's released, keeping track
+// of the compactions running at the same time.
+type fakeDbCompactor struct {
+	release     chan struct{}
+	inFlight    *atomic.Int64
+	maxInFlight *atomic.Int64
+	count       atomic.Int64
+	err         error
+}
+
+func (f *fakeDbCompactor) compactDb() (int64, error) {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		m := f.maxInFlight.Load()
+		if n <= m || f.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	if f.release != nil {
+		<-f.release
+	}
+	f.count.Add(1)
+	return 10, f.err
+}
+
+func TestDbCompactionOptions_Validate(t *testing.T) {
+	for _, test := range []struct {
+		options DbCompactionOptions
+		valid   bool
+	}{
+		{DbCompactionOptions{}, true},
+		{DbCompactionOptions{Interval: time.Hour}, true},
+		{DbCompactionOptions{Interval: time.Hour, Window: "01:00-05:00", Concurrency: 2}, true},
+		{DbCompactionOptions{Interval: time.Hour, Window: "22:30 - 04:00"}, true},
+		{DbCompactionOptions{Interval: -time.Hour}, false},
+		{DbCompactionOptions{Concurrency: -1}, false},
+		{DbCompactionOptions{Window: "01:00"}, false},
+		{DbCompactionOptions{Window: "01:00-25:00"}, false},
+		{DbCompactionOptions{Window: "1am-5am"}, false},
+		{DbCompactionOptions{Window: "03:00-03:00"}, false},
+	} {
+		t.Run(fmt.Sprintf("%+v", test.options), func(t *testing.T) {
+			assert.Equal(t, test.valid, test.options.Validate() == nil)
+		})
+	}
+}
+
+func TestCompactionWindow_Contains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	var always *compactionWindow
+	assert.True(t, always.contains(at(12, 0)))
+
+	w, err := parseCompactionWindow("01:00-05:30")
+	assert.NoError(t, err)
+	assert.False(t, w.contains(at(0, 59)))
+	assert.True(t, w.contains(at(1, 0)))
+	assert.True(t, w.contains(at(5, 29)))
+	assert.False(t, w.contains(at(5, 30)))
+	assert.False(t, w.contains(at(23, 0)))
+
+	// The window spans midnight
+	w, err = parseCompactionWindow("22:00-04:00")
+	assert.NoError(t, err)
+	assert.False(t, w.contains(at(21, 59)))
+	assert.True(t, w.contains(at(22, 0)))
+	assert.True(t, w.contains(at(0, 0)))
+	assert.True(t, w.contains(at(3, 59)))
+	assert.False(t, w.contains(at(4, 0)))
+	assert.False(t, w.contains(at(12, 0)))
+}
+
+func TestDbCompactionScheduler_Concurrency(t *testing.T) {
+	release := make(chan struct{})
+	inFlight := &atomic.Int64{}
+	maxInFlight := &atomic.Int64{}
+	targets := map[int64]dbCompactor{}
+	for shardId := int64(0); shardId < 6; shardId++ {
+		targets[shardId] = &fakeDbCompactor{release: release, inFlight: inFlight, maxInFlight: maxInFlight}
+	}
+
+	clock := &common.MockedClock{}
+	s := newDbCompactionScheduler(DbCompactionOptions{Interval: time.Hour, Concurrency: 2}, clock,
+		func() map[int64]dbCompactor { return targets })
+
+	// The shards are first due one interval after they're seen
+	s.compactDueShards(context.Background())
+	assert.Zero(t, inFlight.Load())
+
+	clock.Set(time.Hour.Milliseconds())
+	done := make(chan struct{})
+	go func() {
+		s.compactDueShards(context.Background())
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return inFlight.Load() == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// The compactions on demand wait for a slot too
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := s.compactShard(ctx, 0, targets[0])
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	for i := 0; i < 6; i++ {
+		release <- struct{}{}
+	}
+	<-done
+
+	assert.EqualValues(t, 2, maxInFlight.Load())
+	for _, target := range targets {
+		assert.EqualValues(t, 1, target.(*fakeDbCompactor).count.Load())
+	}
+
+	// The shards are not due again before the interval
+	clock.Set(time.Hour.Milliseconds() + time.Minute.Milliseconds())
+	assert.Empty(t, s.dueShards(targets))
+}
+
+func TestDbCompactionScheduler_Skip(t *testing.T) {
+	inFlight := &atomic.Int64{}
+	maxInFlight := &atomic.Int64{}
+	catchingUp := &fakeDbCompactor{inFlight: inFlight, maxInFlight: maxInFlight, err: common.ErrorShardCatchingUp}
+	failing := &fakeDbCompactor{inFlight: inFlight, maxInFlight: maxInFlight, err: errors.New("failed")}
+	healthy := &fakeDbCompactor{inFlight: inFlight, maxInFlight: maxInFlight}
+
+	var lock sync.Mutex
+	targets := map[int64]dbCompactor{0: catchingUp, 1: failing, 2: healthy}
+	getTargets := func() map[int64]dbCompactor {
+		lock.Lock()
+		defer lock.Unlock()
+		res := map[int64]dbCompactor{}
+		for shardId, target := range targets {
+			res[shardId] = target
+		}
+		return res
+	}
+
+	clock := &common.MockedClock{}
+	window, err := parseCompactionWindow("01:00-05:00")
+	assert.NoError(t, err)
+	s := newDbCompactionScheduler(DbCompactionOptions{Interval: time.Hour, Window: "01:00-05:00"}, clock, getTargets)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	clock.Set(start.UnixMilli())
+	s.compactDueShards(context.Background())
+
+	// Outside the window, nothing is compacted
+	clock.Set(start.Add(time.Hour - time.Minute).UnixMilli())
+	assert.False(t, window.contains(clock.Now()))
+	s.compactDueShards(context.Background())
+	assert.Zero(t, healthy.count.Load())
+
+	clock.Set(start.Add(time.Hour).UnixMilli())
+	s.compactDueShards(context.Background())
+	assert.EqualValues(t, 1, catchingUp.count.Load())
+	assert.EqualValues(t, 1, failing.count.Load())
+	assert.EqualValues(t, 1, healthy.count.Load())
+
+	// Only the shard that is catching up is retried at the next check
+	clock.Set(start.Add(time.Hour + time.Minute).UnixMilli())
+	s.compactDueShards(context.Background())
+	assert.EqualValues(t, 2, catchingUp.count.Load())
+	assert.EqualValues(t, 1, failing.count.Load())
+	assert.EqualValues(t, 1, healthy.count.Load())
+
+	// The shards that are gone are forgotten
+	lock.Lock()
+	delete(targets, 1)
+	lock.Unlock()
+	s.dueShards(getTargets())
+	assert.NotContains(t, s.lastCompaction, int64(1))
+}
+
+func TestShardsDirector_CompactShard(t *testing.T) {
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), health.NewServer())
+
+	var shard int64 = 1
+	lc, err := sd.GetOrCreateLeader(common.DefaultNamespace, shard)
+	assert.NoError(t, err)
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	assert.NoError(t, err)
+
+	// The shard is not compacted while the leader is being elected
+	_, err = sd.CompactShard(context.Background(), &proto.CompactShardRequest{Shard: shard})
+	assert.Equal(t, common.CodeShardCatchingUp, status.Code(err))
+
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 1,
+	})
+	assert.NoError(t, err)
+
+	// The reclaimed bytes are checked by the tests of the kv package
+	_, err = sd.CompactShard(context.Background(), &proto.CompactShardRequest{Shard: shard})
+	assert.NoError(t, err)
+
+	_, err = sd.CompactShard(context.Background(), &proto.CompactShardRequest{Shard: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// A follower is not compacted while it's installing a snapshot, or while
+	// it's far behind the commit offset of the leader
+	fc, err := sd.GetOrCreateFollower(common.DefaultNamespace, 3, 1)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Shard: 3, Term: 1})
+	assert.NoError(t, err)
+	_, err = sd.CompactShard(context.Background(), &proto.CompactShardRequest{Shard: 3})
+	assert.NoError(t, err)
+
+	fc.(*followerController).advertisedCommitOffset.Store(maxCompactionLagEntries + 1)
+	_, err = sd.CompactShard(context.Background(), &proto.CompactShardRequest{Shard: 3})
+	assert.Equal(t, common.CodeShardCatchingUp, status.Code(err))
+	fc.(*followerController).advertisedCommitOffset.Store(wal.InvalidOffset)
+
+	fc.(*followerController).installingSnapshot.Store(true)
+	_, err = sd.CompactShard(context.Background(), &proto.CompactShardRequest{Shard: 3})
+	assert.Equal(t, common.CodeShardCatchingUp, status.Code(err))
+	fc.(*followerController).installingSnapshot.Store(false)
+
+	assert.NoError(t, sd.Close())
+	_, err = sd.CompactShard(context.Background(), &proto.CompactShardRequest{Shard: shard})
+	assert.ErrorIs(t, err, common.ErrorAlreadyClosed)
+
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/streamnative/oxia/server/wal"
+)
+
+// shardDebugStatus is the state of the controller of a shard, as shown in
+// the /debug/shards page.
+type shardDebugStatus struct {
+	Namespace     string `json:"namespace,omitempty"`
+	Shard         int64  `json:"shard"`
+	Role          string `json:"role"`
+	Status        string `json:"status,omitempty"`
+	Term          int64  `json:"term"`
+	HeadOffset    int64  `json:"headOffset"`
+	CommitOffset  int64  `json:"commitOffset"`
+	AppliedOffset int64  `json:"appliedOffset"`
+
+	// The offsets of the first and the last entries of the wal
+	WalFirstOffset int64 `json:"walFirstOffset"`
+	WalLastOffset  int64 `json:"walLastOffset"`
+}
+
+// The controllers report their state for the /debug/shards page.
+type debugStatusReporter interface {
+	debugStatus() shardDebugStatus
+}
+
+// newShardDebugStatus returns the status of a shard whose controller has no
+// wal or db, because it is closed or not recovered yet.
+func newShardDebugStatus(namespace string, shardId int64, role string) shardDebugStatus {
+	return shardDebugStatus{
+		Namespace:      namespace,
+		Shard:          shardId,
+		Role:           role,
+		Term:           wal.InvalidTerm,
+		HeadOffset:     wal.InvalidOffset,
+		CommitOffset:   wal.InvalidOffset,
+		AppliedOffset:  wal.InvalidOffset,
+		WalFirstOffset: wal.InvalidOffset,
+		WalLastOffset:  wal.InvalidOffset,
+	}
+}
+
+func (s *shardsDirector) debugStatus() []shardDebugStatus {
+	// The controllers are asked without holding the lock, since they might
+	// be busy with a write
+	s.RLock()
+	reporters := make([]debugStatusReporter, 0, len(s.leaders)+len(s.followers))
+	for _, leader := range s.leaders {
+		reporters = append(reporters, leader.(debugStatusReporter))
+	}
+	for _, follower := range s.followers {
+		reporters = append(reporters, follower.(debugStatusReporter))
+	}
+	shards := make([]shardDebugStatus, 0, len(reporters)+len(s.recovering))
+	for shardId := range s.recovering {
+		shards = append(shards, newShardDebugStatus("", shardId, "recovering"))
+	}
+	s.RUnlock()
+
+	for _, reporter := range reporters {
+		shards = append(shards, reporter.debugStatus())
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i].Shard < shards[j].Shard
+	})
+	return shards
+}
+
+// newShardsDebugHandler serves the state of the controllers of the node, in
+// JSON.
+func newShardsDebugHandler(sd ShardsDirector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var shards []shardDebugStatus
+		if d, ok := sd.(*shardsDirector); ok {
+			shards = d.debugStatus()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(shards); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+)
+
+func getShardsDebugPage(t *testing.T, sd ShardsDirector) []shardDebugStatus {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	newShardsDebugHandler(sd).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/shards", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var shards []shardDebugStatus
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &shards))
+	return shards
+}
+
+func TestShardsDebugPage(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	require.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), health.NewServer())
+	assert.Empty(t, getShardsDebugPage(t, sd))
+
+	lc, err := sd.GetOrCreateLeader(common.DefaultNamespace, shard)
+	require.NoError(t, err)
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 2})
+	require.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              2,
+		ReplicationFactor: 1,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = lc.Write(context.Background(), &proto.WriteRequest{
+			Shard: &shard,
+			Puts:  []*proto.PutRequest{{Key: fmt.Sprintf("key-%d", i), Value: []byte("value")}},
+		})
+		require.NoError(t, err)
+	}
+
+	_, err = sd.GetOrCreateFollower(common.DefaultNamespace, 2, 1)
+	require.NoError(t, err)
+
+	// A shard that is still being recovered
+	d := sd.(*shardsDirector)
+	d.Lock()
+	d.recovering[3] = make(chan struct{})
+	d.Unlock()
+
+	assert.Equal(t, []shardDebugStatus{{
+		Namespace:      common.DefaultNamespace,
+		Shard:          1,
+		Role:           "leader",
+		Status:         proto.ServingStatus_LEADER.String(),
+		Term:           2,
+		HeadOffset:     9,
+		CommitOffset:   9,
+		AppliedOffset:  9,
+		WalFirstOffset: 0,
+		WalLastOffset:  9,
+	}, {
+		Namespace:      common.DefaultNamespace,
+		Shard:          2,
+		Role:           "follower",
+		Status:         proto.ServingStatus_NOT_MEMBER.String(),
+		Term:           -1,
+		HeadOffset:     -1,
+		CommitOffset:   -1,
+		AppliedOffset:  -1,
+		WalFirstOffset: -1,
+		WalLastOffset:  -1,
+	},
+		newShardDebugStatus("", 3, "recovering"),
+	}, getShardsDebugPage(t, sd))
+
+	d.Lock()
+	delete(d.recovering, 3)
+	d.Unlock()
+
+	assert.NoError(t, sd.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/streamnative/oxia/common/metrics"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+const DefaultDiskUsageRefreshInterval = 1 * time.Minute
+
+// The controllers measure the disk space taken by their shard when asked
+// by the shards director.
+type diskUsageReporter interface {
+	refreshDiskUsage() error
+}
+
+// diskUsageTracker keeps the last disk usage measured for a shard, so that
+// the metrics scrapes and the status requests don't go through the files
+// of the shard each time.
+type diskUsageTracker struct {
+	usage atomic.Pointer[proto.DiskUsage]
+
+	walGauge metrics.Gauge
+	dbGauge  metrics.Gauge
+}
+
+func newDiskUsageTracker(namespace string, shardId int64) *diskUsageTracker {
+	labels := metrics.LabelsForShard(namespace, shardId)
+	t := &diskUsageTracker{}
+	t.walGauge = metrics.NewGauge("oxia_server_wal_disk_usage",
+		"The disk space taken by the wal of the shard", metrics.Bytes, labels, func() int64 {
+			return t.Get().GetWalBytes()
+		})
+	t.dbGauge = metrics.NewGauge("oxia_server_db_disk_usage",
+		"The disk space taken by the database of the shard", metrics.Bytes, labels, func() int64 {
+			return t.Get().GetDbBytes()
+		})
+	return t
+}
+
+// Get returns the last measured disk usage, or nil if it was never measured.
+func (t *diskUsageTracker) Get() *proto.DiskUsage {
+	return t.usage.Load()
+}
+
+func (t *diskUsageTracker) update(w wal.Wal, db kv.DB) error {
+	walBytes, err := w.DiskUsage()
+	if err != nil {
+		return err
+	}
+
+	t.usage.Store(&proto.DiskUsage{
+		WalBytes: walBytes,
+		DbBytes:  db.DiskUsage(),
+	})
+	return nil
+}
+
+func (t *diskUsageTracker) Close() {
+	t.walGauge.Unregister()
+	t.dbGauge.Unregister()
+}
@@ -0,0 +1,325 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/container"
+	"github.com/streamnative/oxia/common/metrics"
+)
+
+const DefaultDiskWatermarkCheckInterval = 10 * time.Second
+
+// DiskWatermarkOptions configures when the leaders on the node stop
+// accepting writes, before the disk is exhausted. Each watermark is either
+// a percentage of the disk in use (e.g. "90%") or the free space left on
+// the disk (e.g. "10GiB"). An empty high watermark disables the check.
+type DiskWatermarkOptions struct {
+	// High is the watermark above which the writes are rejected
+	High string
+
+	// Low is the watermark below which the writes are accepted again. If
+	// empty, it's the same as the high watermark
+	Low string
+
+	CheckInterval time.Duration
+}
+
+func (o DiskWatermarkOptions) enabled() bool {
+	return o.High != ""
+}
+
+// Validate checks that the watermarks can be parsed and that the low one
+// is not above the high one.
+func (o DiskWatermarkOptions) Validate() error {
+	_, _, err := o.parse()
+	return err
+}
+
+func (o DiskWatermarkOptions) parse() (high, low diskWatermark, err error) {
+	if !o.enabled() {
+		if o.Low != "" {
+			return high, low, errors.New("the low disk watermark requires a high disk watermark")
+		}
+		return high, low, nil
+	}
+
+	if high, err = parseDiskWatermark(o.High); err != nil {
+		return high, low, err
+	}
+	if o.Low == "" {
+		return high, high, nil
+	}
+	if low, err = parseDiskWatermark(o.Low); err != nil {
+		return high, low, err
+	}
+
+	switch {
+	case high.usedPercent > 0 && low.usedPercent > high.usedPercent:
+		return high, low, errors.Errorf("low disk watermark %s is above the high disk watermark %s", o.Low, o.High)
+	case high.freeBytes > 0 && low.freeBytes > 0 && low.freeBytes < high.freeBytes:
+		return high, low, errors.Errorf("low disk watermark %s is above the high disk watermark %s", o.Low, o.High)
+	}
+	return high, low, nil
+}
+
+// diskWatermark is reached either when the used percentage of the disk
+// reaches usedPercent, or when the free space drops to freeBytes.
+type diskWatermark struct {
+	usedPercent float64
+	freeBytes   uint64
+}
+
+func parseDiskWatermark(s string) (diskWatermark, error) {
+	if p, ok := strings.CutSuffix(strings.TrimSpace(s), "%"); ok {
+		usedPercent, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || usedPercent <= 0 || usedPercent > 100 {
+			return diskWatermark{}, errors.Errorf("invalid disk watermark %q: the percentage must be in (0, 100]", s)
+		}
+		return diskWatermark{usedPercent: usedPercent}, nil
+	}
+
+	freeBytes, err := humanize.ParseBytes(s)
+	if err != nil || freeBytes == 0 {
+		return diskWatermark{}, errors.Errorf("invalid disk watermark %q: expected a percentage or a size in bytes", s)
+	}
+	return diskWatermark{freeBytes: freeBytes}, nil
+}
+
+func (w diskWatermark) reached(stats diskStats) bool {
+	if w.usedPercent > 0 {
+		return stats.usedPercent() >= w.usedPercent
+	}
+	return stats.AvailBytes <= w.freeBytes
+}
+
+type diskStats struct {
+	TotalBytes uint64
+	AvailBytes uint64
+}
+
+func (s diskStats) usedPercent() float64 {
+	if s.TotalBytes == 0 {
+		return 0
+	}
+	return float64(s.TotalBytes-min(s.AvailBytes, s.TotalBytes)) * 100 / float64(s.TotalBytes)
+}
+
+// diskStatsProvider returns the usage of the volume where the directory is.
+type diskStatsProvider func(dir string) (diskStats, error)
+
+// The directories might not be created yet, in which case the volume of the
+// closest existing parent is checked.
+func fsDiskStats(dir string) (diskStats, error) {
+	for {
+		usage, err := vfs.Default.GetDiskUsage(dir)
+		if err == nil {
+			return diskStats{TotalBytes: usage.TotalBytes, AvailBytes: usage.AvailBytes}, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, fs.ErrNotExist) || parent == dir {
+			return diskStats{}, err
+		}
+		dir = parent
+	}
+}
+
+// diskWatermarkMonitor periodically checks the volumes of the wal and of
+// the database. Once the high watermark is reached, the node is reported as
+// having a full disk until the usage drops below the low watermark.
+//
+// While the disk is full, the leaders reject the new writes, while reads and
+// replication continue. The node reports it in the NewTerm and GetStatus
+// responses, so that the coordinator does not elect it as leader.
+type diskWatermarkMonitor struct {
+	high          diskWatermark
+	low           diskWatermark
+	dirs          []string
+	statsProvider diskStatsProvider
+	healthServer  *health.Server
+
+	full atomic.Bool
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	fullGauge metrics.Gauge
+	log       *slog.Logger
+}
+
+func newDiskWatermarkMonitor(options DiskWatermarkOptions, dirs []string, healthServer *health.Server,
+	statsProvider diskStatsProvider) (*diskWatermarkMonitor, error) {
+	high, low, err := options.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &diskWatermarkMonitor{
+		high:          high,
+		low:           low,
+		statsProvider: statsProvider,
+		healthServer:  healthServer,
+		log: slog.With(
+			slog.String("component", "disk-watermark-monitor"),
+		),
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	// The wal and the database are often on the same directory
+	for _, dir := range dirs {
+		if dir != "" && !slices.Contains(m.dirs, dir) {
+			m.dirs = append(m.dirs, dir)
+		}
+	}
+
+	m.fullGauge = metrics.NewGauge("oxia_server_disk_full",
+		"Whether the disk is above the high watermark and the writes are rejected", metrics.Dimensionless, nil, func() int64 {
+			if m.Full() {
+				return 1
+			}
+			return 0
+		})
+	m.healthServer.SetServingStatus(container.DiskHealthService, grpc_health_v1.HealthCheckResponse_SERVING)
+
+	if !options.enabled() {
+		return m, nil
+	}
+
+	m.check()
+
+	interval := options.CheckInterval
+	if interval <= 0 {
+		interval = DefaultDiskWatermarkCheckInterval
+	}
+
+	m.wg.Add(1)
+	go common.DoWithLabels(
+		m.ctx,
+		map[string]string{
+			"oxia": "disk-watermark-monitor",
+		},
+		func() {
+			defer m.wg.Done()
+			m.run(interval)
+		},
+	)
+
+	return m, nil
+}
+
+// Full returns whether the disk is above the high watermark.
+func (m *diskWatermarkMonitor) Full() bool {
+	return m.full.Load()
+}
+
+// checkWrite rejects the writes while the disk is full.
+func (m *diskWatermarkMonitor) checkWrite(shard int64) error {
+	if m.Full() {
+		return status.Errorf(codes.ResourceExhausted, "oxia: disk is full on the leader of shard %d", shard)
+	}
+	return nil
+}
+
+func (m *diskWatermarkMonitor) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+func (m *diskWatermarkMonitor) check() {
+	full := m.full.Load()
+	watermark := m.high
+	if full {
+		watermark = m.low
+	}
+
+	// If any of the volumes can't be checked, the previous state is kept
+	reached := false
+	for _, dir := range m.dirs {
+		stats, err := m.statsProvider(dir)
+		if err != nil {
+			m.log.Warn(
+				"Failed to check the disk usage",
+				slog.String("dir", dir),
+				slog.Any("error", err),
+			)
+			return
+		}
+
+		if watermark.reached(stats) {
+			m.log.Debug(
+				"Disk watermark reached",
+				slog.String("dir", dir),
+				slog.Uint64("total-bytes", stats.TotalBytes),
+				slog.Uint64("avail-bytes", stats.AvailBytes),
+			)
+			reached = true
+		}
+	}
+
+	switch {
+	case !full && reached:
+		m.log.Warn("Disk usage reached the high watermark, rejecting the writes")
+		m.setFull(true)
+	case full && !reached:
+		m.log.Info("Disk usage dropped below the low watermark, accepting the writes")
+		m.setFull(false)
+	}
+}
+
+func (m *diskWatermarkMonitor) setFull(full bool) {
+	m.full.Store(full)
+
+	diskStatus := grpc_health_v1.HealthCheckResponse_SERVING
+	if full {
+		diskStatus = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	m.healthServer.SetServingStatus(container.DiskHealthService, diskStatus)
+}
+
+func (m *diskWatermarkMonitor) Close() error {
+	m.cancel()
+	m.wg.Wait()
+	m.fullGauge.Unregister()
+	return nil
+}
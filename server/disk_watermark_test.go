@@ -0,0 +1,282 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/container"
+	"github.com/streamnative/oxia/proto"
+)
+
+type fakeDiskStats struct {
+	sync.Mutex
+	stats map[string]diskStats
+	err   error
+}
+
+func newFakeDiskStats() *fakeDiskStats {
+	return &fakeDiskStats{stats: make(map[string]diskStats)}
+}
+
+func (f *fakeDiskStats) set(dir string, usedPercent uint64) {
+	f.Lock()
+	defer f.Unlock()
+	f.stats[dir] = diskStats{TotalBytes: 100, AvailBytes: 100 - usedPercent}
+}
+
+func (f *fakeDiskStats) setError(err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.err = err
+}
+
+func (f *fakeDiskStats) get(dir string) (diskStats, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.stats[dir], f.err
+}
+
+// newTestDiskWatermarkMonitor creates a monitor with the watermarks disabled.
+func newTestDiskWatermarkMonitor(t *testing.T, healthServer *health.Server) *diskWatermarkMonitor {
+	t.Helper()
+
+	m, err := newDiskWatermarkMonitor(DiskWatermarkOptions{}, nil, healthServer, newFakeDiskStats().get)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, m.Close())
+	})
+	return m
+}
+
+func TestDiskWatermarkOptions_Validate(t *testing.T) {
+	for _, test := range []struct {
+		options DiskWatermarkOptions
+		valid   bool
+	}{
+		{DiskWatermarkOptions{}, true},
+		{DiskWatermarkOptions{High: "90%"}, true},
+		{DiskWatermarkOptions{High: "90%", Low: "85%"}, true},
+		{DiskWatermarkOptions{High: "90.5%", Low: "90.5%"}, true},
+		{DiskWatermarkOptions{High: "10GiB", Low: "20GiB"}, true},
+		{DiskWatermarkOptions{High: "95%", Low: "20GiB"}, true},
+		{DiskWatermarkOptions{Low: "85%"}, false},
+		{DiskWatermarkOptions{High: "85%", Low: "90%"}, false},
+		{DiskWatermarkOptions{High: "20GiB", Low: "10GiB"}, false},
+		{DiskWatermarkOptions{High: "0%"}, false},
+		{DiskWatermarkOptions{High: "101%"}, false},
+		{DiskWatermarkOptions{High: "0"}, false},
+		{DiskWatermarkOptions{High: "lots"}, false},
+	} {
+		err := test.options.Validate()
+		if test.valid {
+			assert.NoError(t, err, test.options)
+		} else {
+			assert.Error(t, err, test.options)
+		}
+	}
+}
+
+func TestDiskWatermark_Reached(t *testing.T) {
+	w, err := parseDiskWatermark("90%")
+	assert.NoError(t, err)
+	assert.False(t, w.reached(diskStats{TotalBytes: 1000, AvailBytes: 101}))
+	assert.True(t, w.reached(diskStats{TotalBytes: 1000, AvailBytes: 100}))
+	assert.False(t, w.reached(diskStats{}))
+
+	w, err = parseDiskWatermark("1KiB")
+	assert.NoError(t, err)
+	assert.False(t, w.reached(diskStats{TotalBytes: 1 << 20, AvailBytes: 1025}))
+	assert.True(t, w.reached(diskStats{TotalBytes: 1 << 20, AvailBytes: 1024}))
+}
+
+func TestDiskWatermarkMonitor(t *testing.T) {
+	stats := newFakeDiskStats()
+	stats.set("wal", 50)
+	stats.set("db", 50)
+
+	healthServer := health.NewServer()
+	m, err := newDiskWatermarkMonitor(DiskWatermarkOptions{
+		High:          "90%",
+		Low:           "80%",
+		CheckInterval: time.Hour,
+	}, []string{"wal", "db", "db", ""}, healthServer, stats.get)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"wal", "db"}, m.dirs)
+
+	assertDiskHealth := func(expected grpc_health_v1.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		res, err := healthServer.Check(context.Background(),
+			&grpc_health_v1.HealthCheckRequest{Service: container.DiskHealthService})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, res.Status)
+	}
+
+	assert.False(t, m.Full())
+	assert.NoError(t, m.checkWrite(0))
+	assertDiskHealth(grpc_health_v1.HealthCheckResponse_SERVING)
+
+	// Any of the volumes above the high watermark stops the writes
+	stats.set("db", 90)
+	m.check()
+	assert.True(t, m.Full())
+	assert.Equal(t, codes.ResourceExhausted, status.Code(m.checkWrite(0)))
+	assertDiskHealth(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	// The writes are not accepted until the usage drops below the low watermark
+	stats.set("db", 85)
+	m.check()
+	assert.True(t, m.Full())
+
+	// Failing to check the disk keeps the previous state
+	stats.set("db", 10)
+	stats.setError(errors.New("failed to stat"))
+	m.check()
+	assert.True(t, m.Full())
+
+	stats.setError(nil)
+	m.check()
+	assert.False(t, m.Full())
+	assert.NoError(t, m.checkWrite(0))
+	assertDiskHealth(grpc_health_v1.HealthCheckResponse_SERVING)
+
+	stats.set("wal", 95)
+	m.check()
+	assert.True(t, m.Full())
+
+	assert.NoError(t, m.Close())
+}
+
+func TestDiskWatermarkMonitor_Disabled(t *testing.T) {
+	stats := newFakeDiskStats()
+	stats.set("db", 100)
+
+	m, err := newDiskWatermarkMonitor(DiskWatermarkOptions{}, []string{"db"}, health.NewServer(), stats.get)
+	assert.NoError(t, err)
+	assert.False(t, m.Full())
+	assert.NoError(t, m.Close())
+}
+
+func TestDiskWatermarkMonitor_Periodic(t *testing.T) {
+	stats := newFakeDiskStats()
+	stats.set("db", 10)
+
+	m, err := newDiskWatermarkMonitor(DiskWatermarkOptions{
+		High:          "90%",
+		CheckInterval: 10 * time.Millisecond,
+	}, []string{"db"}, health.NewServer(), stats.get)
+	assert.NoError(t, err)
+	assert.False(t, m.Full())
+
+	stats.set("db", 99)
+	assert.Eventually(t, m.Full, 10*time.Second, 10*time.Millisecond)
+
+	stats.set("db", 10)
+	assert.Eventually(t, func() bool { return !m.Full() }, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, m.Close())
+}
+
+func TestFsDiskStats(t *testing.T) {
+	dir := t.TempDir()
+
+	stats, err := fsDiskStats(dir)
+	assert.NoError(t, err)
+	assert.Positive(t, stats.TotalBytes)
+	assert.LessOrEqual(t, stats.AvailBytes, stats.TotalBytes)
+
+	// The directories that are not created yet are on the volume of the parent
+	missingStats, err := fsDiskStats(filepath.Join(dir, "not", "created"))
+	assert.NoError(t, err)
+	assert.Equal(t, stats.TotalBytes, missingStats.TotalBytes)
+}
+
+func TestDiskWatermarkMonitor_PublicService(t *testing.T) {
+	config := NewTestConfig(t.TempDir())
+	config.DiskWatermarks = DiskWatermarkOptions{High: "100%", CheckInterval: time.Hour}
+
+	standalone, err := NewStandalone(config)
+	assert.NoError(t, err)
+
+	stats := newFakeDiskStats()
+	stats.set(config.WalDir, 10)
+	stats.set(config.DataDir, 10)
+	standalone.diskWatermarks.statsProvider = stats.get
+
+	cnx, err := grpc.NewClient(fmt.Sprintf("localhost:%d", standalone.RpcPort()),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	client := proto.NewOxiaClientClient(cnx)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), common.MetadataNamespace, common.DefaultNamespace)
+	var shard int64
+	write := func() error {
+		_, err := client.Write(ctx, &proto.WriteRequest{
+			Shard: &shard,
+			Puts:  []*proto.PutRequest{{Key: "key", Value: []byte("value")}},
+		})
+		return err
+	}
+	read := func() (*proto.GetResponse, error) {
+		stream, err := client.Read(ctx, &proto.ReadRequest{
+			Shard: &shard,
+			Gets:  []*proto.GetRequest{{Key: "key", IncludeValue: true}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		res, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return res.Gets[0], nil
+	}
+
+	assert.NoError(t, write())
+
+	stats.set(config.DataDir, 100)
+	standalone.diskWatermarks.check()
+
+	assert.Equal(t, codes.ResourceExhausted, status.Code(write()))
+
+	// The reads are still served
+	res, err := read()
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, res.Status)
+	assert.Equal(t, []byte("value"), res.Value)
+
+	stats.set(config.DataDir, 50)
+	standalone.diskWatermarks.check()
+	assert.NoError(t, write())
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, standalone.Close())
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/common/compression"
+	"github.com/streamnative/oxia/proto"
+)
+
+// compressLogEntryValue sets the value of the entry, compressing it when
+// it's larger than the threshold. A threshold of 0 disables the compression.
+// The value is kept uncompressed if the compression doesn't make it smaller.
+func compressLogEntryValue(entry *proto.LogEntry, value []byte, threshold int) {
+	entry.Value = value
+	entry.Compression = proto.CompressionType_NONE
+
+	if threshold <= 0 || len(value) <= threshold {
+		return
+	}
+
+	if compressed := compression.ZstdCompress(nil, value); len(compressed) < len(value) {
+		entry.Value = compressed
+		entry.Compression = proto.CompressionType_ZSTD
+	}
+}
+
+// decompressLogEntryValue returns the serialized LogEntryValue of the entry,
+// decompressing it first into buf if the leader compressed it. The returned
+// slice shares the memory of either buf or the entry.
+func decompressLogEntryValue(buf []byte, entry *proto.LogEntry) ([]byte, error) {
+	switch entry.Compression {
+	case proto.CompressionType_NONE:
+		return entry.Value, nil
+	case proto.CompressionType_ZSTD:
+		value, err := compression.ZstdDecompress(buf[:0], entry.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decompress the entry at offset %d", entry.Offset)
+		}
+		return value, nil
+	default:
+		return nil, errors.Errorf("unknown compression %v of the entry at offset %d", entry.Compression, entry.Offset)
+	}
+}
+
+// DecodeLogEntryValue returns the operations stored in the entry, eg: to
+// inspect the wal.
+func DecodeLogEntryValue(entry *proto.LogEntry) (*proto.LogEntryValue, error) {
+	value, err := decompressLogEntryValue(nil, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	logEntryValue := &proto.LogEntryValue{}
+	if err = logEntryValue.UnmarshalVT(value); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the entry at offset %d", entry.Offset)
+	}
+	return logEntryValue, nil
+}
+
+// entryDecoder decodes the committed entries in the apply loops, reusing
+// the same LogEntryValue and decompression buffer for all the entries.
+type entryDecoder struct {
+	value *proto.LogEntryValue
+	buf   []byte
+}
+
+func newEntryDecoder() *entryDecoder {
+	return &entryDecoder{value: proto.LogEntryValueFromVTPool()}
+}
+
+// decode returns the operations of the entry. The returned value is owned by
+// the decoder: it's only valid until the next call to decode or to close.
+// The fields of the value don't share any memory with the entry or the
+// decompression buffer.
+func (d *entryDecoder) decode(entry *proto.LogEntry) (*proto.LogEntryValue, error) {
+	d.value.ResetVT()
+
+	value, err := decompressLogEntryValue(d.buf, entry)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Compression != proto.CompressionType_NONE {
+		d.buf = value
+	}
+
+	if err = d.value.UnmarshalVT(value); err != nil {
+		return nil, err
+	}
+	return d.value, nil
+}
+
+// close returns the LogEntryValue to the pool.
+func (d *entryDecoder) close() {
+	d.value.ReturnToVTPool()
+	d.value = nil
+}
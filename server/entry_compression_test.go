@@ -0,0 +1,376 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+const testEntryCompressionThreshold = 256
+
+// A JSON document of roughly the given size, which compresses well
+func jsonValue(size int, id string) []byte {
+	sb := strings.Builder{}
+	sb.WriteString(`{"id":"` + id + `","items":[`)
+	for i := 0; sb.Len() < size; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf(`{"name":"item-%d","enabled":true,"tags":["a","b","c"]}`, i))
+	}
+	sb.WriteString("]}")
+	return []byte(sb.String())
+}
+
+func TestCompressLogEntryValue(t *testing.T) {
+	incompressible := make([]byte, 2*testEntryCompressionThreshold)
+	_, err := rand.Read(incompressible)
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		name                string
+		value               []byte
+		threshold           int
+		expectedCompression proto.CompressionType
+	}{
+		{"disabled", jsonValue(4096, "x"), 0, proto.CompressionType_NONE},
+		{"below-threshold", []byte(strings.Repeat("a", testEntryCompressionThreshold-1)), testEntryCompressionThreshold, proto.CompressionType_NONE},
+		{"at-threshold", []byte(strings.Repeat("a", testEntryCompressionThreshold)), testEntryCompressionThreshold, proto.CompressionType_NONE},
+		{"above-threshold", []byte(strings.Repeat("a", testEntryCompressionThreshold+1)), testEntryCompressionThreshold, proto.CompressionType_ZSTD},
+		{"incompressible", incompressible, testEntryCompressionThreshold, proto.CompressionType_NONE},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := wrapInLogEntryValue(&proto.WriteRequest{
+				Puts: []*proto.PutRequest{{Key: "k", Value: test.value}},
+			}).MarshalVT()
+			assert.NoError(t, err)
+
+			// The threshold applies to the serialized entry value
+			threshold := test.threshold
+			if threshold > 0 {
+				threshold += len(value) - len(test.value)
+			}
+
+			entry := &proto.LogEntry{Term: 1, Offset: 5}
+			compressLogEntryValue(entry, value, threshold)
+			assert.Equal(t, test.expectedCompression, entry.Compression)
+			if entry.Compression == proto.CompressionType_ZSTD {
+				assert.Less(t, len(entry.Value), len(value))
+			}
+
+			decompressed, err := decompressLogEntryValue(nil, entry)
+			assert.NoError(t, err)
+			assert.Equal(t, value, decompressed)
+
+			logEntryValue := &proto.LogEntryValue{}
+			assert.NoError(t, logEntryValue.UnmarshalVT(decompressed))
+			assert.Equal(t, test.value, logEntryValue.GetRequests().Writes[0].Puts[0].Value)
+		})
+	}
+}
+
+func TestDecompressLogEntryValue_Invalid(t *testing.T) {
+	// Not a zstd frame
+	_, err := decompressLogEntryValue(nil, &proto.LogEntry{
+		Value:       []byte("not-compressed"),
+		Compression: proto.CompressionType_ZSTD,
+	})
+	assert.Error(t, err)
+
+	// Written with a compression unknown to this node
+	_, err = decompressLogEntryValue(nil, &proto.LogEntry{
+		Value:       []byte{},
+		Compression: proto.CompressionType(100),
+	})
+	assert.Error(t, err)
+}
+
+func createCompressedAddRequest(t *testing.T, term int64, offset int64, key string, value []byte, commitOffset int64) *proto.Append {
+	t.Helper()
+
+	v, err := wrapInLogEntryValue(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: key, Value: value}},
+	}).MarshalVT()
+	assert.NoError(t, err)
+
+	entry := &proto.LogEntry{Term: term, Offset: offset}
+	compressLogEntryValue(entry, v, testEntryCompressionThreshold)
+	return &proto.Append{
+		Term:         term,
+		Entry:        entry,
+		CommitOffset: commitOffset,
+	}
+}
+
+func assertWalCompression(t *testing.T, w wal.Wal, expected map[int64]proto.CompressionType) {
+	t.Helper()
+
+	reader, err := w.NewReader(wal.InvalidOffset)
+	assert.NoError(t, err)
+	for reader.HasNext() {
+		entry, err := reader.ReadNext()
+		assert.NoError(t, err)
+		assert.Equal(t, expected[entry.Offset], entry.Compression, "offset %d", entry.Offset)
+		delete(expected, entry.Offset)
+	}
+	assert.Empty(t, expected)
+	assert.NoError(t, reader.Close())
+}
+
+func TestFollower_MixedCompressedEntries(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	// The even entries are above the threshold and the odd ones below
+	valueFor := func(term int64, offset int64) []byte {
+		id := fmt.Sprintf("%d-%d", term, offset)
+		if offset%2 == 0 {
+			return jsonValue(4*testEntryCompressionThreshold, id)
+		}
+		return []byte(id)
+	}
+	compressionFor := func(offset int64) proto.CompressionType {
+		if offset%2 == 0 {
+			return proto.CompressionType_ZSTD
+		}
+		return proto.CompressionType_NONE
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockServerReplicateStream()
+	stream.ctx = ctx
+	go func() { assert.ErrorIs(t, fc.Replicate(stream), context.Canceled) }()
+
+	for i := int64(0); i < 6; i++ {
+		stream.AddRequest(createCompressedAddRequest(t, 1, i, fmt.Sprintf("key-%d", i), valueFor(1, i), min(i-1, 2)))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+
+	assertDbValue := func(offset int64, expected []byte) {
+		assert.Eventually(t, func() bool {
+			res, err := fc.(*followerController).db.Get(&proto.GetRequest{
+				Key:          fmt.Sprintf("key-%d", offset),
+				IncludeValue: true,
+			})
+			assert.NoError(t, err)
+			return res.Status == proto.Status_OK && string(res.Value) == string(expected)
+		}, 10*time.Second, 10*time.Millisecond, "offset %d", offset)
+	}
+
+	for i := int64(0); i <= 2; i++ {
+		assertDbValue(i, valueFor(1, i))
+	}
+
+	// The leader goes away
+	cancel()
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
+
+	// The new leader has the entries up to the uncompressed one at offset 3,
+	// so the truncation drops a compressed and an uncompressed entry
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.NoError(t, err)
+	truncateRes, err := fc.Truncate(&proto.TruncateRequest{
+		Term:        2,
+		HeadEntryId: &proto.EntryId{Term: 1, Offset: 3},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, truncateRes.HeadEntryId.Offset)
+
+	stream = newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	for i := int64(4); i < 6; i++ {
+		stream.AddRequest(createCompressedAddRequest(t, 2, i, fmt.Sprintf("key-%d", i), valueFor(2, i), i-1))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+	stream.AddRequest(createCommitOffsetRequest(2, 5))
+
+	for i := int64(0); i < 4; i++ {
+		assertDbValue(i, valueFor(1, i))
+	}
+	for i := int64(4); i < 6; i++ {
+		assertDbValue(i, valueFor(2, i))
+	}
+
+	expected := map[int64]proto.CompressionType{}
+	for i := int64(0); i < 6; i++ {
+		expected[i] = compressionFor(i)
+	}
+	assertWalCompression(t, fc.(*followerController).wal, expected)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestLeaderController_EntryCompression(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	lc, err := NewLeaderController(Config{EntryCompressionThreshold: testEntryCompressionThreshold},
+		common.DefaultNamespace, shard, newMockRpcClient(), walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	assert.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 1,
+	})
+	assert.NoError(t, err)
+
+	values := [][]byte{
+		[]byte("small"),
+		jsonValue(4*testEntryCompressionThreshold, "large"),
+	}
+	// The requests are recycled by the leader once written, so they can't
+	// share the buffers with the expected values
+	for i, value := range values {
+		_, err := lc.Write(context.Background(), &proto.WriteRequest{
+			Shard: &shard,
+			Puts:  []*proto.PutRequest{{Key: fmt.Sprintf("key-%d", i), Value: slices.Clone(value)}},
+		})
+		assert.NoError(t, err)
+	}
+
+	for i, value := range values {
+		r := <-lc.Read(context.Background(), &proto.ReadRequest{
+			Shard: &shard,
+			Gets:  []*proto.GetRequest{{Key: fmt.Sprintf("key-%d", i), IncludeValue: true}},
+		})
+		assert.NoError(t, r.Err)
+		assert.Equal(t, value, r.Response.Value)
+	}
+
+	assertWalCompression(t, lc.(*leaderController).wal, map[int64]proto.CompressionType{
+		0: proto.CompressionType_NONE,
+		1: proto.CompressionType_ZSTD,
+	})
+	assert.NoError(t, lc.Close())
+
+	// A new leader without compression applies the compressed entries
+	// from the wal and keeps writing uncompressed ones
+	kvFactory2, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	lc, err = NewLeaderController(Config{}, common.DefaultNamespace, shard, newMockRpcClient(), walFactory, kvFactory2)
+	assert.NoError(t, err)
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 2})
+	assert.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              2,
+		ReplicationFactor: 1,
+	})
+	assert.NoError(t, err)
+
+	_, err = lc.Write(context.Background(), &proto.WriteRequest{
+		Shard: &shard,
+		Puts:  []*proto.PutRequest{{Key: "key-2", Value: slices.Clone(values[1])}},
+	})
+	assert.NoError(t, err)
+
+	for i, value := range append(values, values[1]) {
+		r := <-lc.Read(context.Background(), &proto.ReadRequest{
+			Shard: &shard,
+			Gets:  []*proto.GetRequest{{Key: fmt.Sprintf("key-%d", i), IncludeValue: true}},
+		})
+		assert.NoError(t, r.Err)
+		assert.Equal(t, value, r.Response.Value)
+	}
+
+	assertWalCompression(t, lc.(*leaderController).wal, map[int64]proto.CompressionType{
+		0: proto.CompressionType_NONE,
+		1: proto.CompressionType_ZSTD,
+		2: proto.CompressionType_NONE,
+	})
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, kvFactory2.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// The bytes/op of the benchmarks is the size of the uncompressed values and
+// the "wire-bytes/op" metric the size of the entries sent to the followers
+// and stored in the wal.
+func BenchmarkEntryCompression(b *testing.B) {
+	for _, size := range []int{1024, 16 * 1024} {
+		value, err := wrapInLogEntryValue(&proto.WriteRequest{
+			Puts: []*proto.PutRequest{{Key: "key", Value: jsonValue(size, "bench")}},
+		}).MarshalVT()
+		assert.NoError(b, err)
+
+		for _, threshold := range []int{0, 512} {
+			name := fmt.Sprintf("size-%d/threshold-%d", size, threshold)
+
+			b.Run(name+"/compress", func(b *testing.B) {
+				b.SetBytes(int64(len(value)))
+				b.ReportAllocs()
+				entry := &proto.LogEntry{}
+				for i := 0; i < b.N; i++ {
+					compressLogEntryValue(entry, value, threshold)
+				}
+				b.ReportMetric(float64(len(entry.Value)), "wire-bytes/op")
+			})
+
+			b.Run(name+"/decompress", func(b *testing.B) {
+				entry := &proto.LogEntry{}
+				compressLogEntryValue(entry, value, threshold)
+				logEntryValue := &proto.LogEntryValue{}
+
+				b.SetBytes(int64(len(value)))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					decompressed, err := decompressLogEntryValue(nil, entry)
+					if err != nil {
+						b.Fatal(err)
+					}
+					logEntryValue.ResetVT()
+					if err := logEntryValue.UnmarshalVT(decompressed); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(len(entry.Value)), "wire-bytes/op")
+			})
+		}
+	}
+}
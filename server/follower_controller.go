@@ -21,9 +21,11 @@ import (
 	"log/slog"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -34,6 +36,15 @@ import (
 	"github.com/streamnative/oxia/server/wal"
 )
 
+// The max amount of time a follower read waits for the replica to apply
+// the entries up to the requested min offset
+const defaultReadBarrierTimeout = 5 * time.Second
+
+// The number of entries that the follower accepts from the leader without
+// acking them, which it advertises in every ack. The received entries wait
+// in a queue of this size to be appended to the wal.
+const replicationWindow = 1000
+
 // FollowerController handles all the operations of a given shard's follower.
 type FollowerController interface {
 	io.Closer
@@ -69,6 +80,30 @@ type FollowerController interface {
 	GetStatus(request *proto.GetStatusRequest) (*proto.GetStatusResponse, error)
 	DeleteShard(request *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error)
 
+	// GetSnapshot sends a snapshot of the database of the shard, to be
+	// kept as a backup
+	GetSnapshot(req *proto.GetSnapshotRequest, hashRange *proto.Int32HashRange, stream proto.OxiaCoordination_GetSnapshotServer) error
+
+	// Read
+	//
+	// Serves the reads that don't require linearizable consistency from
+	// the local database. If the request carries a min offset, the read
+	// waits until the follower has applied all the entries up to it.
+	Read(ctx context.Context, request *proto.ReadRequest) <-chan GetResult
+
+	// List and RangeScan
+	//
+	// Serve the scans that don't require linearizable consistency, with
+	// the same read barrier as the point reads.
+	List(ctx context.Context, request *proto.ListRequest) (<-chan string, error)
+	RangeScan(ctx context.Context, request *proto.RangeScanRequest) (<-chan *proto.GetResponse, <-chan error, error)
+
+	// GetNotifications
+	//
+	// Serves a notifications stream from the entries already applied in
+	// the local database.
+	GetNotifications(req *proto.NotificationsRequest, stream proto.OxiaClient_GetNotificationsServer) error
+
 	Term() int64
 	CommitOffset() int64
 	Status() proto.ServingStatus
@@ -79,7 +114,12 @@ type followerController struct {
 
 	namespace string
 	shardId   int64
-	term      int64
+
+	// The term and the status are only changed while holding the mutex,
+	// though they can be read at any time without it, so that they never
+	// wait behind the I/O done under the mutex
+	term   atomic.Int64
+	status atomic.Int32
 
 	// The highest commit offset advertised by the leader
 	advertisedCommitOffset atomic.Int64
@@ -87,59 +127,154 @@ type followerController struct {
 	// The commit offset already applied in the database
 	commitOffset atomic.Int64
 
-	// Offset of the last entry appended and not fully synced yet on the wal
-	lastAppendedOffset int64
+	// Offset of the last entry appended and not fully synced yet on the wal.
+	// It's only changed while holding the mutex
+	lastAppendedOffset atomic.Int64
 
-	status    proto.ServingStatus
-	wal       wal.Wal
-	kvFactory kv.Factory
-	db        kv.DB
+	statusListener func(proto.ServingStatus)
+	wal            wal.Wal
+	kvFactory      kv.Factory
+	db             kv.DB
 
 	ctx              context.Context
 	cancel           context.CancelFunc
 	syncCond         common.ConditionContext
 	applyEntriesCond common.ConditionContext
 	applyEntriesDone chan any
-	closeStreamWg    common.WaitGroup
-	log              *slog.Logger
-	config           Config
-
-	writeLatencyHisto metrics.LatencyHistogram
+	// Whether the committed entries are being applied, outside the mutex.
+	// The wal and the db can't be wiped while the applying is in progress
+	applying      bool
+	applyIdleCond common.ConditionContext
+	// Incremented every time the wal and the db are wiped, so that the
+	// applying in progress is aborted
+	dataGeneration atomic.Int64
+	closeStreamWg  common.WaitGroup
+	log            *slog.Logger
+
+	// The goroutines handling the replication and snapshot streams. They
+	// must have returned before the wal and the db get closed
+	activeStreams      sync.WaitGroup
+	installingSnapshot atomic.Bool
+
+	config Config
+
+	// Broadcast every time the commit offset applied in the database moves
+	appliedCond        common.ConditionContext
+	readBarrierTimeout time.Duration
+
+	maxEntrySize int
+
+	// The committed entry that could not be applied, at which the applying
+	// of the entries is stopped
+	unappliableEntry *proto.UnappliableEntry
+
+	notificationDispatchers *notificationDispatchers
+
+	writeLatencyHisto     metrics.LatencyHistogram
+	walAppendLatencyHisto metrics.LatencyHistogram
+	applyLatencyHisto     metrics.LatencyHistogram
+	appendedBytesCounter  metrics.Counter
+	appliedEntriesCounter metrics.Counter
+	newTermCounter        metrics.Counter
+	truncateCounter       metrics.Counter
+	unappliableCounter    metrics.Counter
+	headOffsetGauge       metrics.Gauge
+	commitOffsetGauge     metrics.Gauge
+	commitLagGauge        metrics.Gauge
+	termGauge             metrics.Gauge
+	statusGauge           metrics.Gauge
+	diskUsage             *diskUsageTracker
 }
 
 func NewFollowerController(config Config, namespace string, shardId int64, wf wal.Factory, kvFactory kv.Factory) (FollowerController, error) {
+	labels := metrics.LabelsForShard(namespace, shardId)
 	fc := &followerController{
 		config:           config,
 		namespace:        namespace,
 		shardId:          shardId,
 		kvFactory:        kvFactory,
-		status:           proto.ServingStatus_NOT_MEMBER,
 		closeStreamWg:    nil,
 		applyEntriesDone: make(chan any),
 		writeLatencyHisto: metrics.NewLatencyHistogram("oxia_server_follower_write_latency",
-			"Latency for write operations in the follower", metrics.LabelsForShard(namespace, shardId)),
+			"Latency for write operations in the follower", labels),
+		walAppendLatencyHisto: metrics.NewLatencyHistogram("oxia_server_follower_wal_append_latency",
+			"The time it takes to append an entry to the wal in the follower", labels),
+		applyLatencyHisto: metrics.NewLatencyHistogram("oxia_server_follower_apply_latency",
+			"The time it takes to commit a batch of applied entries into the database in the follower", labels),
+		appendedBytesCounter: metrics.NewCounter("oxia_server_follower_appended",
+			"The size of the entries appended to the wal in the follower", metrics.Bytes, labels),
+		appliedEntriesCounter: metrics.NewCounter("oxia_server_follower_applied_entries",
+			"The number of committed entries applied to the database in the follower", "count", labels),
+		newTermCounter: metrics.NewCounter("oxia_server_follower_new_term",
+			"The number of new term requests received by the follower", "count", labels),
+		truncateCounter: metrics.NewCounter("oxia_server_follower_truncate",
+			"The number of truncate requests received by the follower", "count", labels),
+		unappliableCounter: metrics.NewCounter("oxia_server_follower_unappliable_entries",
+			"The number of committed entries that the follower could not apply", "count", labels),
+		diskUsage: newDiskUsageTracker(namespace, shardId),
 	}
+
+	fc.headOffsetGauge = metrics.NewGauge("oxia_server_follower_head_offset",
+		"The current head offset", "offset", labels, func() int64 {
+			return fc.lastAppendedOffset.Load()
+		})
+	fc.commitOffsetGauge = metrics.NewGauge("oxia_server_follower_commit_offset",
+		"The current commit offset", "offset", labels, func() int64 {
+			return fc.commitOffset.Load()
+		})
+	fc.commitLagGauge = metrics.NewGauge("oxia_server_follower_commit_lag",
+		"The number of entries between the head offset and the commit offset", "count", labels, func() int64 {
+			return fc.lastAppendedOffset.Load() - fc.commitOffset.Load()
+		})
+	fc.termGauge = metrics.NewGauge("oxia_server_follower_term",
+		"The current term", "count", labels, func() int64 {
+			return fc.Term()
+		})
+	fc.statusGauge = metrics.NewGauge("oxia_server_follower_status",
+		"The current serving status, as the number of the proto.ServingStatus value", "count", labels, func() int64 {
+			return int64(fc.Status())
+		})
 	fc.ctx, fc.cancel = context.WithCancel(context.Background())
 	fc.syncCond = common.NewConditionContext(fc)
 	fc.applyEntriesCond = common.NewConditionContext(fc)
+	fc.appliedCond = common.NewConditionContext(fc)
+	fc.applyIdleCond = common.NewConditionContext(fc)
+	fc.readBarrierTimeout = defaultReadBarrierTimeout
+	fc.maxEntrySize = config.WriteSizeLimits.maxLogEntrySize()
+	fc.notificationDispatchers = newNotificationDispatchers(shardId)
 
 	var err error
 	if fc.wal, err = wf.NewWal(namespace, shardId, fc); err != nil {
+		if errors.Is(err, wal.ErrWalCorrupted) {
+			slog.Error(
+				"The wal of the shard is corrupted",
+				slog.String("namespace", namespace),
+				slog.Int64("shard", shardId),
+				slog.Any("error", err),
+			)
+			return nil, common.ErrorWalCorrupted
+		}
 		return nil, err
 	}
 
-	fc.lastAppendedOffset = fc.wal.LastOffset()
+	fc.lastAppendedOffset.Store(fc.wal.LastOffset())
 
 	if fc.db, err = kv.NewDB(namespace, shardId, kvFactory, config.NotificationsRetentionTime, common.SystemClock); err != nil {
 		return nil, err
 	}
 
-	if fc.term, err = fc.db.ReadTerm(); err != nil {
+	term, err := fc.db.ReadTerm()
+	if err != nil {
 		return nil, err
 	}
+	fc.term.Store(term)
 
-	if fc.term != wal.InvalidTerm {
-		fc.status = proto.ServingStatus_FENCED
+	if fc.unappliableEntry, err = fc.db.UnappliableEntry(); err != nil {
+		return nil, err
+	}
+
+	if fc.term.Load() != wal.InvalidTerm {
+		fc.setStatus(proto.ServingStatus_FENCED)
 	}
 
 	commitOffset, err := fc.db.ReadCommitOffset()
@@ -147,14 +282,22 @@ func NewFollowerController(config Config, namespace string, shardId int64, wf wa
 		return nil, err
 	}
 	fc.commitOffset.Store(commitOffset)
-
-	if fc.lastAppendedOffset == wal.InvalidOffset {
-		// The wal is empty, though we have restored from snapshot
-		fc.lastAppendedOffset = commitOffset
+	// Until the leader advertises one, the known commit offset is the one
+	// already applied
+	fc.advertisedCommitOffset.Store(commitOffset)
+
+	if commitOffset > fc.lastAppendedOffset.Load() {
+		// The wal is empty or behind the database, since we have
+		// restored from a snapshot
+		fc.lastAppendedOffset.Store(commitOffset)
 	}
 
 	fc.setLogger()
 
+	if err = fc.diskUsage.update(fc.wal, fc.db); err != nil {
+		return nil, err
+	}
+
 	go common.DoWithLabels(
 		fc.ctx,
 		map[string]string{
@@ -166,7 +309,7 @@ func NewFollowerController(config Config, namespace string, shardId int64, wf wa
 
 	fc.log.Info(
 		"Created follower",
-		slog.Int64("head-offset", fc.lastAppendedOffset),
+		slog.Int64("head-offset", fc.lastAppendedOffset.Load()),
 		slog.Int64("commit-offset", commitOffset),
 	)
 	return fc, nil
@@ -177,7 +320,7 @@ func (fc *followerController) setLogger() {
 		slog.String("component", "follower-controller"),
 		slog.String("namespace", fc.namespace),
 		slog.Int64("shard", fc.shardId),
-		slog.Int64("term", fc.term),
+		slog.Int64("term", fc.term.Load()),
 	)
 }
 
@@ -187,20 +330,52 @@ func (fc *followerController) isClosed() bool {
 
 func (fc *followerController) Close() error {
 	fc.log.Debug("Closing follower controller")
-	fc.cancel()
-
-	<-fc.applyEntriesDone
+	fc.stopStreams()
 
 	fc.Lock()
 	defer fc.Unlock()
 	return fc.close()
 }
 
+// Stop accepting new streams, and wait for the active ones, as well as for
+// the applying of the committed entries, to stop using the wal and the db.
+func (fc *followerController) stopStreams() {
+	// The streams are registered under the mutex, after checking that the
+	// controller is not closed
+	fc.Lock()
+	fc.cancel()
+	fc.Unlock()
+
+	<-fc.applyEntriesDone
+	fc.activeStreams.Wait()
+}
+
+// The context of the goroutines handling a stream is cancelled either when
+// the stream is done or when the controller is closed.
+func (fc *followerController) newStreamContext(stream grpc.ServerStream) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(stream.Context())
+	stop := context.AfterFunc(fc.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
 func (fc *followerController) close() error {
 	var err error
 
+	fc.headOffsetGauge.Unregister()
+	fc.commitOffsetGauge.Unregister()
+	fc.commitLagGauge.Unregister()
+	fc.termGauge.Unregister()
+	fc.statusGauge.Unregister()
+	fc.diskUsage.Close()
+
+	fc.notificationDispatchers.close()
+
 	if fc.wal != nil {
 		err = multierr.Append(err, fc.wal.Close())
+		fc.wal = nil
 	}
 
 	if fc.db != nil {
@@ -210,10 +385,15 @@ func (fc *followerController) close() error {
 	return err
 }
 
-func (fc *followerController) closeStream(err error) {
+// Close the stream, unless it was already closed. The goroutines of a stream
+// can outlive it, so they must not close the stream that replaced it.
+func (fc *followerController) closeStream(closeStreamWg common.WaitGroup, err error) {
 	fc.Lock()
 	defer fc.Unlock()
 
+	if fc.closeStreamWg != closeStreamWg {
+		return
+	}
 	fc.closeStreamNoMutex(err)
 }
 
@@ -232,15 +412,34 @@ func (fc *followerController) closeStreamNoMutex(err error) {
 }
 
 func (fc *followerController) Status() proto.ServingStatus {
+	return proto.ServingStatus(fc.status.Load())
+}
+
+// Update the serving status and notify the listener, if it changed. It must
+// be called with the mutex held.
+func (fc *followerController) setStatus(status proto.ServingStatus) {
+	if fc.Status() == status {
+		return
+	}
+
+	fc.status.Store(int32(status))
+	if fc.statusListener != nil {
+		fc.statusListener(status)
+	}
+}
+
+// The listener is invoked with the current status straight away, and then
+// every time the status changes.
+func (fc *followerController) setStatusListener(listener func(proto.ServingStatus)) {
 	fc.Lock()
 	defer fc.Unlock()
-	return fc.status
+
+	fc.statusListener = listener
+	listener(fc.Status())
 }
 
 func (fc *followerController) Term() int64 {
-	fc.Lock()
-	defer fc.Unlock()
-	return fc.term
+	return fc.term.Load()
 }
 
 func (fc *followerController) CommitOffset() int64 {
@@ -255,25 +454,27 @@ func (fc *followerController) NewTerm(req *proto.NewTermRequest) (*proto.NewTerm
 		return nil, common.ErrorAlreadyClosed
 	}
 
-	if req.Term < fc.term {
+	if req.Term < fc.term.Load() {
 		fc.log.Warn(
 			"Failed to fence with invalid term",
-			slog.Int64("follower-term", fc.term),
+			slog.Int64("follower-term", fc.term.Load()),
 			slog.Int64("new-term", req.Term),
 		)
 		return nil, common.ErrorInvalidTerm
-	} else if req.Term == fc.term && fc.status != proto.ServingStatus_FENCED {
+	} else if req.Term == fc.term.Load() && fc.Status() != proto.ServingStatus_FENCED {
 		// It's OK to receive a duplicate Fence request, for the same term, as long as we haven't moved
 		// out of the Fenced state for that term
 		fc.log.Warn(
 			"Failed to fence with same term in invalid state",
-			slog.Int64("follower-term", fc.term),
+			slog.Int64("follower-term", fc.term.Load()),
 			slog.Int64("new-term", req.Term),
-			slog.Any("status", fc.status),
+			slog.Any("status", fc.Status()),
 		)
 		return nil, common.ErrorInvalidStatus
 	}
 
+	fc.newTermCounter.Inc()
+
 	if fc.db == nil {
 		var err error
 		if fc.db, err = kv.NewDB(fc.namespace, fc.shardId, fc.kvFactory, fc.config.NotificationsRetentionTime, common.SystemClock); err != nil {
@@ -285,17 +486,17 @@ func (fc *followerController) NewTerm(req *proto.NewTermRequest) (*proto.NewTerm
 		return nil, err
 	}
 
-	fc.term = req.Term
+	fc.term.Store(req.Term)
 	fc.setLogger()
-	fc.status = proto.ServingStatus_FENCED
+	fc.setStatus(proto.ServingStatus_FENCED)
 	fc.closeStreamNoMutex(nil)
 
-	lastEntryId, err := getLastEntryIdInWal(fc.wal)
+	lastEntryId, err := getHeadEntryId(fc.wal, fc.db)
 	if err != nil {
 		fc.log.Warn(
 			"Failed to get last",
 			slog.Any("error", err),
-			slog.Int64("follower-term", fc.term),
+			slog.Int64("follower-term", fc.term.Load()),
 			slog.Int64("new-term", req.Term),
 		)
 		return nil, err
@@ -316,32 +517,108 @@ func (fc *followerController) Truncate(req *proto.TruncateRequest) (*proto.Trunc
 		return nil, common.ErrorAlreadyClosed
 	}
 
-	if fc.status != proto.ServingStatus_FENCED {
+	if fc.Status() != proto.ServingStatus_FENCED {
 		return nil, common.ErrorInvalidStatus
 	}
 
-	if req.Term != fc.term {
+	if req.Term != fc.term.Load() {
 		return nil, common.ErrorInvalidTerm
 	}
 
-	fc.status = proto.ServingStatus_FOLLOWER
+	fc.setStatus(proto.ServingStatus_FOLLOWER)
+	fc.truncateCounter.Inc()
+
+	if req.HeadEntryId.Offset < fc.commitOffset.Load() {
+		// The database already contains entries that are being truncated
+		// out of the log. We cannot roll them back, so we discard everything
+		// and let the leader send us a snapshot
+		return fc.truncateAll(req)
+	}
+
 	headOffset, err := fc.wal.TruncateLog(req.HeadEntryId.Offset)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to truncate wal. truncate-offset: %d - wal-last-offset: %d",
 			req.HeadEntryId.Offset, fc.wal.LastOffset())
 	}
 
+	fc.lastAppendedOffset.Store(headOffset)
+	if fc.lastAppendedOffset.Load() == wal.InvalidOffset {
+		// The wal is empty, though we have restored from snapshot
+		fc.lastAppendedOffset.Store(fc.commitOffset.Load())
+	}
+
 	return &proto.TruncateResponse{
 		HeadEntryId: &proto.EntryId{
 			Term:   req.Term,
-			Offset: headOffset,
+			Offset: fc.lastAppendedOffset.Load(),
+		},
+	}, nil
+}
+
+func (fc *followerController) truncateAll(req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
+	fc.log.Warn(
+		"Database is ahead of the truncation point, discarding all the shard data",
+		slog.Int64("commit-offset", fc.commitOffset.Load()),
+		slog.Any("truncate-head-entry", req.HeadEntryId),
+	)
+
+	// The applying of the committed entries must not use the wal and the db
+	// while they are wiped. The mutex is released while waiting for it, so
+	// the term could have moved in the meantime.
+	if err := fc.waitForApplyingToStop(); err != nil {
+		return nil, err
+	}
+	if req.Term != fc.term.Load() {
+		return nil, common.ErrorInvalidTerm
+	}
+
+	// The wal is cleared first: if we crash before the database is wiped,
+	// we will restart with an empty wal, and the head entry becomes the
+	// commit offset of the database. That is still ahead of the truncation
+	// point, so the leader's next truncate request wipes the database again.
+	if err := fc.wal.Clear(); err != nil {
+		return nil, errors.Wrap(err, "failed to clear wal")
+	}
+
+	fc.notificationDispatchers.drain()
+
+	if fc.db != nil {
+		if err := fc.db.Delete(); err != nil {
+			return nil, errors.Wrap(err, "failed to delete database")
+		}
+		fc.db = nil
+	}
+
+	var err error
+	if fc.db, err = kv.NewDB(fc.namespace, fc.shardId, fc.kvFactory, fc.config.NotificationsRetentionTime, common.SystemClock); err != nil {
+		return nil, errors.Wrap(err, "failed to reopen database")
+	}
+
+	// The term must be persisted again in the new database
+	if err = fc.db.UpdateTerm(fc.term.Load()); err != nil {
+		return nil, err
+	}
+
+	fc.advertisedCommitOffset.Store(wal.InvalidOffset)
+	fc.commitOffset.Store(wal.InvalidOffset)
+	fc.lastAppendedOffset.Store(wal.InvalidOffset)
+
+	return &proto.TruncateResponse{
+		HeadEntryId: &proto.EntryId{
+			Term:   req.Term,
+			Offset: wal.InvalidOffset,
 		},
 	}, nil
 }
 
 func (fc *followerController) Replicate(stream proto.OxiaLogReplication_ReplicateServer) error {
 	fc.Lock()
-	if fc.status != proto.ServingStatus_FENCED && fc.status != proto.ServingStatus_FOLLOWER {
+	if fc.isClosed() {
+		fc.Unlock()
+		return common.ErrorAlreadyClosed
+	}
+
+	if fc.Status() != proto.ServingStatus_FENCED && fc.Status() != proto.ServingStatus_FOLLOWER {
 		fc.Unlock()
 		return common.ErrorInvalidStatus
 	}
@@ -353,40 +630,108 @@ func (fc *followerController) Replicate(stream proto.OxiaLogReplication_Replicat
 
 	closeStreamWg := common.NewWaitGroup(1)
 	fc.closeStreamWg = closeStreamWg
+	fc.activeStreams.Add(2)
 	fc.Unlock()
 
+	ctx, cancel := fc.newStreamContext(stream)
+	defer cancel()
+
 	go common.DoWithLabels(
-		stream.Context(),
+		ctx,
 		map[string]string{
 			"oxia":  "add-entries",
 			"shard": fmt.Sprintf("%d", fc.shardId),
 		},
-		func() { fc.handleServerStream(stream) },
+		func() {
+			defer fc.activeStreams.Done()
+			fc.handleServerStream(ctx, closeStreamWg, stream)
+		},
 	)
 
 	go common.DoWithLabels(
-		stream.Context(),
+		ctx,
 		map[string]string{
 			"oxia":  "add-entries-sync",
 			"shard": fmt.Sprintf("%d", fc.shardId),
 		},
-		func() { fc.handleReplicateSync(stream) },
+		func() {
+			defer fc.activeStreams.Done()
+			fc.handleReplicateSync(ctx, closeStreamWg, stream)
+		},
 	)
 
 	return closeStreamWg.Wait(fc.ctx)
 }
 
-func (fc *followerController) handleServerStream(stream proto.OxiaLogReplication_ReplicateServer) {
+func (fc *followerController) handleServerStream(ctx context.Context, closeStreamWg common.WaitGroup, stream proto.OxiaLogReplication_ReplicateServer) {
+	// The entries are received in a separate goroutine, so that the leader
+	// can keep sending them while the ones before are appended to the wal,
+	// and so that the handler can return as soon as the context is
+	// cancelled, even while a receive is pending
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := make(chan *proto.Append, replicationWindow)
+	go common.DoWithLabels(
+		ctx,
+		map[string]string{
+			"oxia":  "add-entries-receive",
+			"shard": fmt.Sprintf("%d", fc.shardId),
+		},
+		func() { fc.receiveAppends(ctx, cancel, closeStreamWg, stream, queue) },
+	)
+
+	for {
+		select {
+		case req := <-queue:
+			if err := fc.append(req, stream); err != nil {
+				fc.closeStream(closeStreamWg, err)
+				return
+			}
+		case <-ctx.Done():
+			fc.closeStream(closeStreamWg, ctx.Err())
+			return
+		}
+	}
+}
+
+// receiveAppends puts the received entries in the queue. The leader doesn't
+// send more entries than the advertised window without waiting for the acks,
+// so the queue can only be full if the leader doesn't respect it, and the
+// stream is then closed instead of buffering more. The appends that only
+// carry the commit offset are handled right away.
+func (fc *followerController) receiveAppends(ctx context.Context, cancel context.CancelFunc, closeStreamWg common.WaitGroup,
+	stream proto.OxiaLogReplication_ReplicateServer, queue chan<- *proto.Append) {
 	for {
-		if req, err := stream.Recv(); err != nil {
-			fc.closeStream(err)
+		req, err := stream.Recv()
+		if ctx.Err() != nil {
 			return
-		} else if req == nil {
-			fc.closeStream(nil)
+		}
+
+		switch {
+		case err != nil:
+			fc.closeStream(closeStreamWg, err)
 			return
-		} else if err := fc.append(req, stream); err != nil {
-			fc.closeStream(err)
+		case req == nil:
+			fc.closeStream(closeStreamWg, nil)
 			return
+		case req.Entry == nil:
+			if err = fc.append(req, stream); err != nil {
+				fc.closeStream(closeStreamWg, err)
+				return
+			}
+		default:
+			select {
+			case queue <- req:
+			default:
+				// The handler can be blocked on the wal while holding the
+				// mutex, so fail the stream without taking it. The handler
+				// then sees the cancellation and releases the stream
+				closeStreamWg.Fail(status.Errorf(codes.ResourceExhausted,
+					"oxia: the leader exceeded the replication window of %d entries", replicationWindow))
+				cancel()
+				return
+			}
 		}
 	}
 }
@@ -398,72 +743,192 @@ func (fc *followerController) append(req *proto.Append, stream proto.OxiaLogRepl
 	fc.Lock()
 	defer fc.Unlock()
 
-	if req.Term != fc.term {
+	if fc.isClosed() {
+		return common.ErrorAlreadyClosed
+	}
+
+	if req.Term > fc.term.Load() {
+		// The term can only be changed by fencing the follower, which also
+		// truncates the entries that the new leader doesn't have
+		fc.log.Warn(
+			"Received append with a term the follower was not fenced into",
+			slog.Int64("append-term", req.Term),
+		)
+		return common.ErrorTermNotFenced
+	} else if req.Term < fc.term.Load() {
 		return common.ErrorInvalidTerm
 	}
 
-	fc.log.Debug(
-		"Add entry",
-		slog.Int64("commit-offset", req.CommitOffset),
-		slog.Int64("offset", req.Entry.Offset),
-	)
+	if req.Entry == nil {
+		return fc.advanceCommitOffset(req, stream)
+	}
+
+	if size := req.Entry.SizeVT(); size > fc.maxEntrySize {
+		// Reject the entry before it reaches the wal, so that the failure
+		// is reported to the leader
+		fc.log.Warn(
+			"Received entry that exceeds the max size",
+			slog.Int64("offset", req.Entry.Offset),
+			slog.Int("size", size),
+			slog.Int("max-size", fc.maxEntrySize),
+		)
+		return common.ErrorEntryTooLarge
+	}
+
+	// Boxing the attributes allocates even when the level is disabled,
+	// which adds up on the path of every entry
+	if fc.log.Enabled(stream.Context(), slog.LevelDebug) {
+		fc.log.Debug(
+			"Add entry",
+			slog.Int64("commit-offset", req.CommitOffset),
+			slog.Int64("offset", req.Entry.Offset),
+		)
+	}
 
 	// A follower node confirms an entry to the leader
 	//
 	// The follower adds the entry to its log, sets the head offset
 	// and updates its commit offset with the commit offset of
 	// the request.
-	fc.status = proto.ServingStatus_FOLLOWER
+	fc.setStatus(proto.ServingStatus_FOLLOWER)
 
-	if req.Entry.Offset <= fc.lastAppendedOffset {
+	if req.Entry.Offset <= fc.lastAppendedOffset.Load() {
 		// This was a duplicated request. We already have this entry
 		fc.log.Debug(
 			"Ignoring duplicated entry",
 			slog.Int64("commit-offset", req.CommitOffset),
 			slog.Int64("offset", req.Entry.Offset),
 		)
-		if err := stream.Send(&proto.Ack{Offset: req.Entry.Offset}); err != nil {
+
+		if oldHeadOffset := syncedHeadOffset(fc.wal); req.Entry.Offset > oldHeadOffset {
+			// The entry was appended, though it's not synced yet. We need
+			// to ensure it's durable before acking it. The sync routine
+			// won't see these entries as new anymore, so we ack them here
+			if err := fc.wal.Sync(stream.Context()); err != nil {
+				return err
+			}
+
+			for offset := oldHeadOffset + 1; offset <= fc.wal.LastOffset(); offset++ {
+				if err := stream.Send(&proto.Ack{Offset: offset, Window: replicationWindow}); err != nil {
+					fc.closeStreamNoMutex(err)
+					return nil
+				}
+			}
+		}
+
+		if err := stream.Send(&proto.Ack{Offset: req.Entry.Offset, Window: replicationWindow}); err != nil {
 			fc.closeStreamNoMutex(err)
 		}
 		return nil
 	}
 
+	if req.Entry.Offset != fc.lastAppendedOffset.Load()+1 {
+		// There is a gap between our head and the entry. Reject it, so that
+		// the leader will restart the cursor from the last acked offset
+		fc.log.Warn(
+			"Received entry that does not follow the head offset",
+			slog.Int64("head-offset", fc.lastAppendedOffset.Load()),
+			slog.Int64("offset", req.Entry.Offset),
+		)
+		return status.Errorf(common.CodeInvalidNextOffset,
+			"oxia: entry offset %d does not follow head offset %d", req.Entry.Offset, fc.lastAppendedOffset.Load())
+	}
+
 	// Append the entry asynchronously. We'll sync it in a group from the "sync" routine,
 	// where the ack is then sent back
+	walTimer := fc.walAppendLatencyHisto.Timer()
 	if err := fc.wal.AppendAsync(req.GetEntry()); err != nil {
 		return err
 	}
+	walTimer.Done()
+	fc.appendedBytesCounter.Add(len(req.Entry.Value))
 
-	fc.advertisedCommitOffset.Store(req.CommitOffset)
-	fc.lastAppendedOffset = req.Entry.Offset
+	fc.advertiseCommitOffset(req.CommitOffset)
+	fc.lastAppendedOffset.Store(req.Entry.Offset)
 
 	// Trigger the sync
 	fc.syncCond.Signal()
 	return nil
 }
 
-func (fc *followerController) handleReplicateSync(stream proto.OxiaLogReplication_ReplicateServer) {
+// An append without any entry only carries the commit offset, which the
+// leader sends when it has no more entries for the follower. Must be called
+// with the lock held.
+func (fc *followerController) advanceCommitOffset(req *proto.Append, stream proto.OxiaLogReplication_ReplicateServer) error {
+	fc.log.Debug(
+		"Advance commit offset",
+		slog.Int64("commit-offset", req.CommitOffset),
+		slog.Int64("head-offset", fc.lastAppendedOffset.Load()),
+	)
+
+	fc.setStatus(proto.ServingStatus_FOLLOWER)
+
+	if fc.advertiseCommitOffset(req.CommitOffset) {
+		fc.applyEntriesCond.Signal()
+	}
+
+	// Confirm the entries that are already durable, without touching the wal.
+	// Right after a snapshot is installed the wal is empty, and the entries
+	// are durable in the db
+	ackOffset := syncedHeadOffset(fc.wal)
+	if ackOffset == wal.InvalidOffset {
+		ackOffset = fc.commitOffset.Load()
+	}
+	if err := stream.Send(&proto.Ack{Offset: ackOffset, Window: replicationWindow}); err != nil {
+		fc.closeStreamNoMutex(err)
+	}
+	return nil
+}
+
+// advertiseCommitOffset records the commit offset sent by the leader, and
+// returns whether it moved. A commit offset lower than the one already
+// received is ignored. The commit offset can be ahead of the head offset:
+// the entries are only applied up to the synced head offset, and the ones
+// after it are applied as soon as they're synced. Must be called with the
+// lock held.
+func (fc *followerController) advertiseCommitOffset(commitOffset int64) bool {
+	if commitOffset <= fc.advertisedCommitOffset.Load() {
+		return false
+	}
+
+	fc.advertisedCommitOffset.Store(commitOffset)
+	return true
+}
+
+func (fc *followerController) handleReplicateSync(ctx context.Context, closeStreamWg common.WaitGroup, stream proto.OxiaLogReplication_ReplicateServer) {
 	for {
 		fc.Lock()
-		if err := fc.syncCond.Wait(stream.Context()); err != nil {
+		if err := fc.syncCond.Wait(ctx); err != nil {
+			fc.Unlock()
+			fc.closeStream(closeStreamWg, err)
+			return
+		}
+
+		if fc.isClosed() {
 			fc.Unlock()
-			fc.closeStream(err)
+			fc.closeStream(closeStreamWg, common.ErrorAlreadyClosed)
 			return
 		}
+
+		// The wal is closed only after this goroutine has returned
+		w := fc.wal
 		fc.Unlock()
 
-		oldHeadOffset := fc.wal.LastOffset()
+		oldHeadOffset := syncedHeadOffset(w)
 
-		if err := fc.wal.Sync(stream.Context()); err != nil {
-			fc.closeStream(err)
+		if err := w.Sync(ctx); err != nil {
+			fc.closeStream(closeStreamWg, err)
 			return
 		}
 
-		// Ack all the entries that were synced in the last round
-		newHeadOffset := fc.wal.LastOffset()
+		// Ack all the entries that were synced in the last round. Each ack is
+		// a new message: gRPC doesn't allow modifying a message once it's
+		// passed to Send, since the stats handlers can retain it, so the
+		// acks cannot be pooled.
+		newHeadOffset := w.LastOffset()
 		for offset := oldHeadOffset + 1; offset <= newHeadOffset; offset++ {
-			if err := stream.Send(&proto.Ack{Offset: offset}); err != nil {
-				fc.closeStream(err)
+			if err := stream.Send(&proto.Ack{Offset: offset, Window: replicationWindow}); err != nil {
+				fc.closeStream(closeStreamWg, err)
 				return
 			}
 		}
@@ -472,6 +937,20 @@ func (fc *followerController) handleReplicateSync(stream proto.OxiaLogReplicatio
 	}
 }
 
+// syncedHeadOffset returns the offset of the last synced entry in the wal.
+// After a snapshot is installed, the wal doesn't start from the first
+// offset, so the entries before the first appended one are not pending.
+func syncedHeadOffset(w wal.Wal) int64 {
+	if lastOffset := w.LastOffset(); lastOffset != wal.InvalidOffset {
+		return lastOffset
+	}
+
+	if firstOffset := w.FirstOffset(); firstOffset != wal.InvalidOffset {
+		return firstOffset - 1
+	}
+	return wal.InvalidOffset
+}
+
 func (fc *followerController) applyAllCommittedEntries() {
 	for {
 		fc.Lock()
@@ -480,77 +959,215 @@ func (fc *followerController) applyAllCommittedEntries() {
 			fc.Unlock()
 			return
 		}
+		// The logger is replaced on each new term, grab it while holding the lock
+		log := fc.log
+		// The entries that are not synced yet can't be applied, even if the
+		// leader has committed them
+		maxInclusive := min(fc.advertisedCommitOffset.Load(), syncedHeadOffset(fc.wal))
+		generation := fc.dataGeneration.Load()
+		fc.applying = true
 		fc.Unlock()
 
-		maxInclusive := fc.advertisedCommitOffset.Load()
-		if err := fc.processCommittedEntries(maxInclusive); err != nil {
-			fc.closeStream(err)
+		err := fc.processCommittedEntries(maxInclusive, generation, log)
+
+		fc.Lock()
+		fc.applying = false
+		fc.applyIdleCond.Broadcast()
+
+		// On an unappliable entry, the replication keeps going, while the
+		// applying waits for the entry to be replaced by a snapshot. When the
+		// data is wiped, the entries are applied again from the new state.
+		if err != nil && !errors.Is(err, common.ErrorEntryUnappliable) && !errors.Is(err, errApplyingAborted) {
+			if errors.Is(err, wal.ErrWalCorrupted) {
+				err = common.ErrorWalCorrupted
+			}
+			fc.closeStreamNoMutex(err)
+			fc.Unlock()
 			close(fc.applyEntriesDone)
 			return
 		}
+
+		// Wake up the reads waiting for the entries to be applied
+		fc.appliedCond.Broadcast()
+		fc.Unlock()
 	}
 }
 
-func (fc *followerController) processCommitRequest(entry *proto.LogEntry, logEntryValue *proto.LogEntryValue) error {
-	for _, br := range logEntryValue.GetRequests().Writes {
-		_, err := fc.db.ProcessWrite(br, entry.Offset, entry.Timestamp, SessionUpdateOperationCallback)
-		if err != nil {
-			fc.log.Error(
-				"Error applying committed entry",
-				slog.Any("error", err),
-			)
-			return err
-		}
+func (fc *followerController) processCommitRequest(batch kv.ApplyBatch, entry *proto.LogEntry, logEntryValue *proto.LogEntryValue, log *slog.Logger) error {
+	if err := applyLogEntry(fc.db, batch, entry, logEntryValue); err != nil {
+		log.Error(
+			"Error applying committed entry",
+			slog.Any("error", err),
+		)
+		return err
 	}
 
 	return nil
 }
 
-func (fc *followerController) processCommittedEntriesLoop(reader wal.Reader, maxInclusive int64) error {
-	logEntryValue := proto.LogEntryValueFromVTPool()
-	defer logEntryValue.ReturnToVTPool()
+// errApplyingAborted is returned when the wal and the db are wiped while the
+// committed entries are being applied.
+var errApplyingAborted = errors.New("applying of the committed entries aborted")
+
+// waitForApplyingToStop waits for the applying of the committed entries to
+// stop using the wal and the db, so that they can be wiped. The mutex is
+// released while waiting, therefore the state must be checked again after.
+func (fc *followerController) waitForApplyingToStop() error {
+	fc.dataGeneration.Add(1)
+	for fc.applying {
+		if err := fc.applyIdleCond.Wait(fc.ctx); err != nil {
+			return common.ErrorAlreadyClosed
+		}
+	}
+	return nil
+}
+
+func (fc *followerController) processCommittedEntriesLoop(reader wal.Reader, maxInclusive int64, generation int64, log *slog.Logger) error {
+	decoder := newEntryDecoder()
+	defer decoder.close()
+
+	// The entries are only visible in the database, and the commit offset
+	// moves, once the batch is committed
+	batch := fc.db.NewApplyBatch()
+	defer func() {
+		if err := batch.Close(); err != nil {
+			log.Error(
+				"Error closing batch used for applying committed entries",
+				slog.Any("error", err),
+			)
+		}
+	}()
+
+	appliedOffset := fc.commitOffset.Load()
+	appliedEntries := 0
+	commit := func() error {
+		if fc.dataGeneration.Load() != generation {
+			return errApplyingAborted
+		}
+
+		timer := fc.applyLatencyHisto.Timer()
+		if err := batch.Commit(); err != nil {
+			log.Error(
+				"Error committing applied entries",
+				slog.Any("error", err),
+			)
+			return err
+		}
+
+		timer.Done()
+		fc.commitOffset.Store(appliedOffset)
+		fc.appliedEntriesCounter.Add(appliedEntries)
+		appliedEntries = 0
+		return nil
+	}
 
 	for reader.HasNext() {
+		if fc.dataGeneration.Load() != generation {
+			return errApplyingAborted
+		}
+
 		entry, err := reader.ReadNext()
 
 		if errors.Is(err, wal.ErrReaderClosed) {
-			fc.log.Info("Stopped reading committed entries")
+			log.Info("Stopped reading committed entries")
 			return err
 		} else if err != nil {
-			fc.log.Error("Error reading committed entry", slog.Any("error", err))
+			log.Error("Error reading committed entry", slog.Any("error", err))
 			return err
 		}
 
-		fc.log.Debug(
-			"Reading entry",
-			slog.Int64("offset", entry.Offset),
-		)
+		if log.Enabled(context.Background(), slog.LevelDebug) {
+			log.Debug(
+				"Reading entry",
+				slog.Int64("offset", entry.Offset),
+			)
+		}
 
 		if entry.Offset > maxInclusive {
 			// We read up to the max point
-			return nil
+			break
 		}
 
-		logEntryValue.ResetVT()
-		if err := logEntryValue.UnmarshalVT(entry.Value); err != nil {
-			fc.log.Error(
-				"Error unmarshalling committed entry",
-				slog.Any("error", err),
-			)
+		logEntryValue, err := decoder.decode(entry)
+		if err != nil {
+			// The entries before are applied in any case
+			if commitErr := commit(); commitErr != nil {
+				return commitErr
+			}
+
+			if err = fc.handleUnappliableEntry(entry, err, log); err != nil {
+				return err
+			}
+
+			// The entry is skipped
+			appliedOffset = entry.Offset
+			fc.commitOffset.Store(entry.Offset)
+			continue
+		}
+		if err := fc.processCommitRequest(batch, entry, logEntryValue, log); err != nil {
 			return err
 		}
-		if err := fc.processCommitRequest(entry, logEntryValue); err != nil {
+
+		appliedOffset = entry.Offset
+		appliedEntries++
+
+		if isApplyBatchFull(batch) {
+			if err := commit(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return commit()
+}
+
+// An entry that cannot be decoded fails the same way at every attempt, so
+// restarting the stream would not help. The entry is recorded, and it's only
+// skipped if the operator allowed it, otherwise ErrorEntryUnappliable is
+// returned and the applying stops at it.
+func (fc *followerController) handleUnappliableEntry(entry *proto.LogEntry, applyErr error, log *slog.Logger) error {
+	unappliable := &proto.UnappliableEntry{
+		EntryId: &proto.EntryId{Term: entry.Term, Offset: entry.Offset},
+		Error:   applyErr.Error(),
+	}
+
+	fc.Lock()
+	alreadyRecorded := fc.unappliableEntry != nil && fc.unappliableEntry.EqualVT(unappliable)
+	fc.unappliableEntry = unappliable
+	fc.Unlock()
+
+	if !alreadyRecorded {
+		log.Error(
+			"Committed entry cannot be applied",
+			slog.Int64("entry-term", entry.Term),
+			slog.Int64("offset", entry.Offset),
+			slog.Any("error", applyErr),
+		)
+		fc.unappliableCounter.Inc()
+
+		if err := fc.db.SetUnappliableEntry(unappliable); err != nil {
 			return err
 		}
+	}
 
-		fc.commitOffset.Store(entry.Offset)
+	if !fc.config.SkipUnappliableEntries {
+		return common.ErrorEntryUnappliable
 	}
 
-	return nil
+	log.Warn(
+		"Skipping committed entry that cannot be applied",
+		slog.Int64("entry-term", entry.Term),
+		slog.Int64("offset", entry.Offset),
+	)
+
+	fc.Lock()
+	fc.unappliableEntry = nil
+	fc.Unlock()
+	return fc.db.SetUnappliableEntry(nil)
 }
 
-func (fc *followerController) processCommittedEntries(maxInclusive int64) error {
-	fc.log.Debug(
+func (fc *followerController) processCommittedEntries(maxInclusive int64, generation int64, log *slog.Logger) error {
+	log.Debug(
 		"Process committed entries",
 		slog.Int64("min-exclusive", fc.commitOffset.Load()),
 		slog.Int64("max-inclusive", maxInclusive),
@@ -562,7 +1179,7 @@ func (fc *followerController) processCommittedEntries(maxInclusive int64) error
 
 	reader, err := fc.wal.NewReader(fc.commitOffset.Load())
 	if err != nil {
-		fc.log.Error(
+		log.Error(
 			"Error opening reader used for applying committed entries",
 			slog.Any("error", err),
 		)
@@ -571,14 +1188,14 @@ func (fc *followerController) processCommittedEntries(maxInclusive int64) error
 	defer func() {
 		err := reader.Close()
 		if err != nil {
-			fc.log.Error(
+			log.Error(
 				"Error closing reader used for applying committed entries",
 				slog.Any("error", err),
 			)
 		}
 	}()
 
-	return fc.processCommittedEntriesLoop(reader, maxInclusive)
+	return fc.processCommittedEntriesLoop(reader, maxInclusive, generation, log)
 }
 
 type MessageWithTerm interface {
@@ -588,6 +1205,11 @@ type MessageWithTerm interface {
 func (fc *followerController) SendSnapshot(stream proto.OxiaLogReplication_SendSnapshotServer) error {
 	fc.Lock()
 
+	if fc.isClosed() {
+		fc.Unlock()
+		return common.ErrorAlreadyClosed
+	}
+
 	if fc.closeStreamWg != nil {
 		fc.Unlock()
 		return common.ErrorLeaderAlreadyConnected
@@ -595,6 +1217,8 @@ func (fc *followerController) SendSnapshot(stream proto.OxiaLogReplication_SendS
 
 	closeStreamWg := common.NewWaitGroup(1)
 	fc.closeStreamWg = closeStreamWg
+	fc.activeStreams.Add(1)
+	fc.installingSnapshot.Store(true)
 	fc.Unlock()
 
 	go common.DoWithLabels(
@@ -603,46 +1227,53 @@ func (fc *followerController) SendSnapshot(stream proto.OxiaLogReplication_SendS
 			"oxia":  "receive-snapshot",
 			"shard": fmt.Sprintf("%d", fc.shardId),
 		},
-		func() { fc.handleSnapshot(stream) },
+		func() {
+			defer fc.activeStreams.Done()
+			defer fc.installingSnapshot.Store(false)
+			fc.handleSnapshot(stream)
+		},
 	)
 
 	return closeStreamWg.Wait(fc.ctx)
 }
 
-func (fc *followerController) readSnapshotStream(stream proto.OxiaLogReplication_SendSnapshotServer, loader kv.SnapshotLoader) (int64, error) {
-	var totalSize int64
+// Receive the chunks of the snapshot into the loader, verifying them as they
+// arrive. It returns the term of the leader that sent the snapshot.
+func (fc *followerController) readSnapshotStream(stream proto.OxiaLogReplication_SendSnapshotServer, loader kv.SnapshotLoader) (term int64, totalSize int64, err error) {
+	term = fc.term.Load()
+	verifier := newSnapshotVerifier(fc.commitOffset.Load(), fc.log)
 
 	for {
 		snapChunk, err := stream.Recv()
 		switch {
 		case err != nil:
 			if errors.Is(err, io.EOF) {
-				return totalSize, nil
+				return term, totalSize, verifier.verifyComplete()
 			}
-
-			fc.closeStreamNoMutex(err)
-			return totalSize, err
+			return term, totalSize, err
 		case snapChunk == nil:
-			return totalSize, nil
-		case fc.term != wal.InvalidTerm && snapChunk.Term != fc.term:
+			return term, totalSize, verifier.verifyComplete()
+		case fc.term.Load() != wal.InvalidTerm && snapChunk.Term != fc.term.Load():
 			// The follower could be left with term=-1 by a previous failed
 			// attempt at sending the snapshot. It's ok to proceed in that case.
-			fc.closeStreamNoMutex(common.ErrorInvalidTerm)
-			return totalSize, common.ErrorInvalidTerm
+			return term, totalSize, common.ErrorInvalidTerm
 		}
 
-		fc.term = snapChunk.Term
+		term = snapChunk.Term
 
 		fc.log.Debug(
 			"Applying snapshot chunk",
 			slog.String("chunk-name", snapChunk.Name),
 			slog.Int("chunk-size", len(snapChunk.Content)),
 			slog.String("chunk-progress", fmt.Sprintf("%d/%d", snapChunk.ChunkIndex, snapChunk.ChunkCount)),
-			slog.Int64("term", fc.term),
+			slog.Int64("term", term),
 		)
+		if err = verifier.verify(snapChunk); err != nil {
+			return term, totalSize, err
+		}
+
 		if err = loader.AddChunk(snapChunk.Name, snapChunk.ChunkIndex, snapChunk.ChunkCount, snapChunk.Content); err != nil {
-			fc.closeStream(err)
-			return totalSize, err
+			return term, totalSize, err
 		}
 
 		totalSize += int64(len(snapChunk.Content))
@@ -653,13 +1284,43 @@ func (fc *followerController) handleSnapshot(stream proto.OxiaLogReplication_Sen
 	fc.Lock()
 	defer fc.Unlock()
 
-	// Wipe out both WAL and DB contents
-	err := fc.wal.Clear()
+	if fc.isClosed() {
+		fc.closeStreamNoMutex(common.ErrorAlreadyClosed)
+		return
+	}
+
+	// The snapshot is downloaded aside, and the wal and the db are only
+	// replaced once it's complete and verified. If the transfer fails, the
+	// partial download is discarded and the leader starts over.
+	loader, err := fc.kvFactory.NewSnapshotLoader(fc.namespace, fc.shardId)
+	if err != nil {
+		fc.closeStreamNoMutex(err)
+		return
+	}
+
+	defer loader.Close()
+
+	term, totalSize, err := fc.readSnapshotStream(stream, loader)
 	if err != nil {
 		fc.closeStreamNoMutex(err)
 		return
 	}
 
+	// The applying of the committed entries must not use the wal and the db
+	// while they are replaced
+	if err = fc.waitForApplyingToStop(); err != nil {
+		fc.closeStreamNoMutex(err)
+		return
+	}
+
+	// Wipe out both WAL and DB contents
+	if err = fc.wal.Clear(); err != nil {
+		fc.closeStreamNoMutex(err)
+		return
+	}
+
+	fc.notificationDispatchers.drain()
+
 	if fc.db != nil {
 		err = fc.db.Close()
 		if err != nil {
@@ -670,21 +1331,13 @@ func (fc *followerController) handleSnapshot(stream proto.OxiaLogReplication_Sen
 		fc.db = nil
 	}
 
-	loader, err := fc.kvFactory.NewSnapshotLoader(fc.namespace, fc.shardId)
-	if err != nil {
+	// We have received all the files for the database
+	if err = loader.Complete(); err != nil {
 		fc.closeStreamNoMutex(err)
 		return
 	}
 
-	defer loader.Close()
-
-	totalSize, err := fc.readSnapshotStream(stream, loader)
-	if err != nil {
-		return
-	}
-
-	// We have received all the files for the database
-	loader.Complete()
+	fc.term.Store(term)
 
 	newDb, err := kv.NewDB(fc.namespace, fc.shardId, fc.kvFactory, fc.config.NotificationsRetentionTime, common.SystemClock)
 	if err != nil {
@@ -693,7 +1346,7 @@ func (fc *followerController) handleSnapshot(stream proto.OxiaLogReplication_Sen
 	}
 
 	// The new term must be persisted, to avoid rolling it back
-	if err = newDb.UpdateTerm(fc.term); err != nil {
+	if err = newDb.UpdateTerm(fc.term.Load()); err != nil {
 		fc.closeStreamNoMutex(errors.Wrap(err, "Failed to update term in db"))
 	}
 
@@ -712,39 +1365,271 @@ func (fc *followerController) handleSnapshot(stream proto.OxiaLogReplication_Sen
 
 	fc.db = newDb
 	fc.commitOffset.Store(commitOffset)
-	fc.lastAppendedOffset = commitOffset
+	fc.lastAppendedOffset.Store(commitOffset)
+	fc.unappliableEntry = nil
+	fc.appliedCond.Broadcast()
 	fc.closeStreamNoMutex(nil)
 
 	fc.log.Info(
 		"Successfully applied snapshot",
-		slog.Int64("term", fc.term),
+		slog.Int64("term", fc.term.Load()),
 		slog.Int64("snapshot-size", totalSize),
 		slog.Int64("commit-offset", commitOffset),
 	)
 }
 
+func (fc *followerController) Read(ctx context.Context, request *proto.ReadRequest) <-chan GetResult {
+	ch := make(chan GetResult)
+
+	go common.DoWithLabels(
+		ctx,
+		map[string]string{
+			"oxia":  "follower-read",
+			"shard": fmt.Sprintf("%d", fc.shardId),
+			"peer":  common.GetPeer(ctx),
+		},
+		func() {
+			fc.read(ctx, request, ch)
+		},
+	)
+
+	return ch
+}
+
+func (fc *followerController) read(ctx context.Context, request *proto.ReadRequest, ch chan<- GetResult) {
+	fc.log.Debug(
+		"Received read request",
+		slog.Int64("min-offset", request.GetMinOffset()),
+	)
+
+	db, err := fc.waitForAppliedOffset(ctx, request.GetMinOffset())
+	if err != nil {
+		ch <- GetResult{Err: err}
+		return
+	}
+
+	// The gets of the request observe the same state of the shard
+	responses, err := db.GetBatch(request.Gets)
+	if err != nil {
+		ch <- GetResult{Err: err}
+		return
+	}
+
+	for _, response := range responses {
+		ch <- GetResult{Response: response}
+		if ctx.Err() != nil {
+			ch <- GetResult{Err: ctx.Err()}
+			return
+		}
+	}
+	close(ch)
+}
+
+func (fc *followerController) List(ctx context.Context, request *proto.ListRequest) (<-chan string, error) {
+	db, err := fc.waitForAppliedOffset(ctx, request.GetMinOffset())
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan string)
+	go common.DoWithLabels(
+		ctx,
+		map[string]string{
+			"oxia":  "follower-list",
+			"shard": fmt.Sprintf("%d", fc.shardId),
+			"peer":  common.GetPeer(ctx),
+		},
+		func() {
+			listFromDB(ctx, db, request, ch, fc.log)
+		},
+	)
+
+	return ch, nil
+}
+
+func (fc *followerController) RangeScan(ctx context.Context, request *proto.RangeScanRequest) (<-chan *proto.GetResponse, <-chan error, error) {
+	db, err := fc.waitForAppliedOffset(ctx, request.GetMinOffset())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ch := make(chan *proto.GetResponse)
+	errCh := make(chan error)
+	go common.DoWithLabels(
+		ctx,
+		map[string]string{
+			"oxia":  "follower-range-scan",
+			"shard": fmt.Sprintf("%d", fc.shardId),
+			"peer":  common.GetPeer(ctx),
+		},
+		func() {
+			rangeScanFromDB(ctx, db, request, ch, errCh, fc.log)
+		},
+	)
+
+	return ch, errCh, nil
+}
+
+// Wait until the entries up to minOffset are applied in the database and
+// return the database the reads can be served from.
+func (fc *followerController) waitForAppliedOffset(ctx context.Context, minOffset int64) (kv.DB, error) {
+	ctx, cancel := context.WithTimeout(ctx, fc.readBarrierTimeout)
+	defer cancel()
+
+	fc.Lock()
+	defer fc.Unlock()
+
+	for {
+		if fc.isClosed() {
+			return nil, common.ErrorAlreadyClosed
+		}
+
+		// A fenced follower is about to be truncated, or to become the
+		// leader, so it cannot serve reads with its current state
+		if fc.Status() != proto.ServingStatus_FOLLOWER {
+			return nil, common.ErrorInvalidStatus
+		}
+
+		if fc.db != nil && fc.commitOffset.Load() >= minOffset {
+			return fc.db, nil
+		}
+
+		if fc.unappliableEntry != nil && fc.unappliableEntry.EntryId.Offset <= minOffset {
+			// The offset cannot be reached until the entry is replaced by a snapshot
+			return nil, common.ErrorEntryUnappliable
+		}
+
+		if err := fc.appliedCond.Wait(ctx); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, common.ErrorReadBarrierTimeout
+			}
+			return nil, err
+		}
+	}
+}
+
+func (fc *followerController) GetNotifications(req *proto.NotificationsRequest, stream proto.OxiaClient_GetNotificationsServer) error {
+	fc.Lock()
+	if fc.isClosed() {
+		fc.Unlock()
+		return common.ErrorAlreadyClosed
+	}
+
+	if fc.Status() != proto.ServingStatus_FOLLOWER || fc.db == nil {
+		fc.Unlock()
+		return common.ErrorInvalidStatus
+	}
+
+	commitOffset := func() (int64, error) {
+		return fc.CommitOffset(), nil
+	}
+
+	nd, err := fc.notificationDispatchers.startDispatcher(fc.db, commitOffset, fc.log, req, stream)
+	fc.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return nd.wait(fc.ctx)
+}
+
 func (fc *followerController) GetStatus(_ *proto.GetStatusRequest) (*proto.GetStatusResponse, error) {
 	fc.Lock()
 	defer fc.Unlock()
 
 	return &proto.GetStatusResponse{
-		Term:         fc.term,
-		Status:       fc.status,
-		HeadOffset:   fc.lastAppendedOffset,
-		CommitOffset: fc.CommitOffset(),
+		Term:             fc.term.Load(),
+		Status:           fc.Status(),
+		HeadOffset:       fc.lastAppendedOffset.Load(),
+		CommitOffset:     fc.CommitOffset(),
+		UnappliableEntry: fc.unappliableEntry,
+		DiskUsage:        fc.diskUsage.Get(),
 	}, nil
 }
 
+func (fc *followerController) refreshDiskUsage() error {
+	fc.Lock()
+	defer fc.Unlock()
+
+	if fc.wal == nil || fc.db == nil {
+		// The controller is closed, or the shard was deleted
+		return nil
+	}
+	return fc.diskUsage.update(fc.wal, fc.db)
+}
+
+func (fc *followerController) compactDb() (int64, error) {
+	// The lock is held for the whole installation of a snapshot
+	if fc.installingSnapshot.Load() {
+		return 0, common.ErrorShardCatchingUp
+	}
+
+	fc.Lock()
+	if fc.isClosed() || fc.db == nil {
+		fc.Unlock()
+		return 0, common.ErrorAlreadyClosed
+	}
+	if fc.advertisedCommitOffset.Load()-fc.commitOffset.Load() > maxCompactionLagEntries {
+		fc.Unlock()
+		return 0, common.ErrorShardCatchingUp
+	}
+	db := fc.db
+	fc.Unlock()
+
+	reclaimed, err := db.Compact()
+	if errors.Is(err, kv.ErrDbClosed) {
+		return 0, common.ErrorAlreadyClosed
+	}
+	return reclaimed, err
+}
+
+func (fc *followerController) debugStatus() shardDebugStatus {
+	fc.Lock()
+	defer fc.Unlock()
+
+	st := newShardDebugStatus(fc.namespace, fc.shardId, "follower")
+	st.Status = fc.Status().String()
+	st.Term = fc.term.Load()
+	st.HeadOffset = fc.lastAppendedOffset.Load()
+	st.CommitOffset = fc.advertisedCommitOffset.Load()
+	st.AppliedOffset = fc.commitOffset.Load()
+	if fc.wal != nil {
+		st.WalFirstOffset = fc.wal.FirstOffset()
+		st.WalLastOffset = fc.wal.LastOffset()
+	}
+	return st
+}
+
+func (fc *followerController) GetSnapshot(req *proto.GetSnapshotRequest, hashRange *proto.Int32HashRange,
+	stream proto.OxiaCoordination_GetSnapshotServer) error {
+	fc.Lock()
+	if fc.isClosed() {
+		fc.Unlock()
+		return common.ErrorAlreadyClosed
+	}
+	snapshot, err := newBackupSnapshot(fc.db, fc.term.Load())
+	fc.Unlock()
+	if err != nil {
+		return err
+	}
+
+	fc.log.Info(
+		"Sending a snapshot for a backup",
+		slog.Any("entry-id", snapshot.entryId),
+		slog.String("peer", common.GetPeer(stream.Context())),
+	)
+	return snapshot.send(hashRange, req.SkipFiles, stream.Send)
+}
+
 func (fc *followerController) DeleteShard(request *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error) {
-	fc.cancel()
-	<-fc.applyEntriesDone
+	fc.stopStreams()
 
 	fc.Lock()
 	defer fc.Unlock()
 
-	if request.Term != fc.term {
+	if request.Term != fc.term.Load() {
 		fc.log.Warn("Invalid term when deleting shard",
-			slog.Int64("follower-term", fc.term),
+			slog.Int64("follower-term", fc.term.Load()),
 			slog.Int64("new-term", request.Term))
 		_ = fc.close()
 		return nil, common.ErrorInvalidTerm
@@ -752,6 +1637,8 @@ func (fc *followerController) DeleteShard(request *proto.DeleteShardRequest) (*p
 
 	fc.log.Info("Deleting shard")
 
+	fc.notificationDispatchers.close()
+
 	// Wipe out both WAL and DB contents
 	if err := multierr.Combine(
 		fc.wal.Delete(),
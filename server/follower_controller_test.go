@@ -15,18 +15,29 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	pb "google.golang.org/protobuf/proto"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/proto"
 	"github.com/streamnative/oxia/server/kv"
 	"github.com/streamnative/oxia/server/wal"
@@ -217,7 +228,7 @@ func TestFollower_RestoreCommitOffset(t *testing.T) {
 	_, err = db.ProcessWrite(&proto.WriteRequest{Puts: []*proto.PutRequest{{
 		Key:   "xx",
 		Value: []byte(""),
-	}}}, 9, 0, kv.NoOpCallback)
+	}}}, 0, 9, 0, kv.NoOpCallback)
 	assert.NoError(t, err)
 
 	assert.NoError(t, db.UpdateTerm(6))
@@ -235,6 +246,184 @@ func TestFollower_RestoreCommitOffset(t *testing.T) {
 	assert.NoError(t, walFactory.Close())
 }
 
+// After a restart, the follower must resume applying entries from the
+// commit offset persisted in the database, without re-applying the
+// entries that were already committed.
+func TestFollower_NoReapplyAfterRestart(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, 0))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, 1))
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 1
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.NoError(t, fc.Close())
+
+	fc, err = NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, fc.CommitOffset())
+
+	stream = newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	// The leader re-sends the already committed entries along with the old commit offsets
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, 0))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, 1))
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+
+	stream.AddRequest(createAddRequest(t, 1, 2, map[string]string{"a": "2"}, 2))
+	assert.EqualValues(t, 2, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{
+		Key:          "a",
+		IncludeValue: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, dbRes.Status)
+	assert.Equal(t, []byte("2"), dbRes.Value)
+	// Each of the 3 entries was applied exactly once
+	assert.EqualValues(t, 2, dbRes.Version.ModificationsCount)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// The acks to the leader must only depend on the wal: a database that is
+// stuck applying the entries must not hold them back.
+func TestFollower_AckWithSlowDb(t *testing.T) {
+	var shardId int64
+	pebbleFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	kvFactory := newGatedKVFactory(pebbleFactory)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	kvFactory.closeGate()
+
+	n := int64(100)
+	for i := int64(0); i < n; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"a": fmt.Sprintf("%d", i)}, i-1))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+
+	// All the entries were acked while the database was blocked on the
+	// first committed entry
+	kvFactory.waitForBlockedBatch()
+	assert.EqualValues(t, wal.InvalidOffset, fc.CommitOffset())
+
+	kvFactory.openGate()
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == n-2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// A follower that crashed after the entries were committed, but before
+// they were all applied, must resume applying them from the offset that
+// was persisted in the database.
+func TestFollower_CrashBetweenCommitAndApply(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
+
+	// All the entries are in the wal, but only the first half was applied
+	walObject, err := walFactory.NewWal(common.DefaultNamespace, shardId, nil)
+	assert.NoError(t, err)
+	db, err := kv.NewDB(common.DefaultNamespace, shardId, kvFactory, 1*time.Hour, common.SystemClock)
+	assert.NoError(t, err)
+
+	for i := int64(0); i < 10; i++ {
+		wr := &proto.WriteRequest{Puts: []*proto.PutRequest{{
+			Key:   "a",
+			Value: []byte(fmt.Sprintf("%d", i)),
+		}}}
+		value, err := pb.Marshal(wrapInLogEntryValue(wr))
+		assert.NoError(t, err)
+		assert.NoError(t, walObject.Append(&proto.LogEntry{Term: 1, Offset: i, Value: value}))
+
+		if i < 5 {
+			_, err = db.ProcessWrite(wr, 1, i, 0, kv.NoOpCallback)
+			assert.NoError(t, err)
+		}
+	}
+
+	assert.NoError(t, db.UpdateTerm(1))
+	assert.NoError(t, db.Close())
+	assert.NoError(t, walObject.Close())
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, fc.CommitOffset())
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	// The leader advertises the commit offset again with the next entry
+	stream.AddRequest(createAddRequest(t, 1, 10, map[string]string{"a": "10"}, 10))
+	assert.EqualValues(t, 10, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 10
+	}, 10*time.Second, 10*time.Millisecond)
+
+	dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{
+		Key:          "a",
+		IncludeValue: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, dbRes.Status)
+	assert.Equal(t, []byte("10"), dbRes.Value)
+	// Each of the 11 entries was applied exactly once
+	assert.EqualValues(t, 10, dbRes.Version.ModificationsCount)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
 // If a follower receives a commit offset from the leader that is ahead
 // of the current follower head offset, it needs to advance the commit
 // offset only up to the current head.
@@ -401,6 +590,47 @@ func TestFollower_PersistentTerm(t *testing.T) {
 	assert.NoError(t, walFactory.Close())
 }
 
+func TestFollower_FencingSurvivesRestart(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
+		DataDir:     t.TempDir(),
+		CacheSizeMB: 1,
+	})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{
+		BaseWalDir: t.TempDir(),
+	})
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 5})
+	assert.NoError(t, err)
+	assert.NoError(t, fc.Close())
+
+	// After the restart, a deposed leader from an older term must still be fenced off
+	fc, err = NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 5, fc.Term())
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 4})
+	assert.Equal(t, common.CodeInvalidTerm, status.Code(err))
+
+	stream := newMockServerReplicateStream()
+	stream.AddRequest(createAddRequest(t, 4, 0, map[string]string{"a": "1"}, wal.InvalidOffset))
+
+	err = fc.Replicate(stream)
+	assert.Equal(t, common.CodeInvalidTerm, status.Code(err))
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 5, fc.Term())
+	assert.Equal(t, wal.InvalidOffset, fc.(*followerController).wal.LastOffset())
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
 func TestFollower_CommitOffsetLastEntry(t *testing.T) {
 	var shardId int64
 	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
@@ -455,71 +685,134 @@ func TestFollower_CommitOffsetLastEntry(t *testing.T) {
 	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollowerController_RejectEntriesWithDifferentTerm(t *testing.T) {
+func createCommitOffsetRequest(term int64, commitOffset int64) *proto.Append {
+	return &proto.Append{
+		Term:         term,
+		CommitOffset: commitOffset,
+	}
+}
+
+func TestFollower_AdvanceCommitOffsetWithoutEntry(t *testing.T) {
 	var shardId int64
-	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
-		DataDir:     t.TempDir(),
-		CacheSizeMB: 1,
-	})
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
 	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
 
-	db, err := kv.NewDB(common.DefaultNamespace, shardId, kvFactory, 1*time.Hour, common.SystemClock)
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
 	assert.NoError(t, err)
-	// Force a new term in the DB before opening
-	assert.NoError(t, db.UpdateTerm(5))
-	assert.NoError(t, db.Close())
 
-	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
 
-	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, 0))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// The leader has no more entries, though the last one got committed
+	stream.AddRequest(createCommitOffsetRequest(1, 1))
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{
+		Key:          "a",
+		IncludeValue: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), dbRes.Value)
+
+	// The entries are only applied up to the head offset
+	stream.AddRequest(createCommitOffsetRequest(1, 5))
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+	assert.EqualValues(t, 5, fc.(*followerController).advertisedCommitOffset.Load())
+
+	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
 	assert.NoError(t, err)
+	assert.EqualValues(t, 1, res.HeadOffset)
+	assert.EqualValues(t, 1, res.CommitOffset)
 
-	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
-	assert.EqualValues(t, 5, fc.Term())
+	// The entries after the head offset are still accepted, and applied
+	// with the commit offset that was already advertised
+	stream.AddRequest(createAddRequest(t, 1, 2, map[string]string{"a": "2"}, 1))
+	assert.EqualValues(t, 2, stream.GetResponse().Offset)
 
-	stream := newMockServerReplicateStream()
-	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "1", "b": "1"}, wal.InvalidOffset))
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 2
+	}, 10*time.Second, 10*time.Millisecond)
 
-	// Follower will reject the entry because it's from an earlier term
-	err = fc.Replicate(stream)
-	assert.Error(t, err)
-	assert.Equal(t, common.CodeInvalidTerm, status.Code(err))
-	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
-	assert.EqualValues(t, 5, fc.Term())
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
 
-	// If we send an entry of same term, it will be accepted
-	stream.AddRequest(createAddRequest(t, 5, 0, map[string]string{"a": "2", "b": "2"}, wal.InvalidOffset))
+func TestFollower_CommitOffsetBeyondHead(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
 
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	stream := newMockServerReplicateStream()
 	go func() {
 		// cancelled due to fc.Close() below
 		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
 	}()
 
-	// Wait for acks
-	r1 := stream.GetResponse()
+	// The leader advertises a commit offset that is ahead of the entries
+	// the follower has received
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, 3))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
 
-	assert.Equal(t, proto.ServingStatus_FOLLOWER, fc.Status())
-	assert.EqualValues(t, 0, r1.Offset)
-	assert.NoError(t, fc.Close())
-	close(stream.requests)
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 0
+	}, 10*time.Second, 10*time.Millisecond)
 
-	// A higher term will also be rejected
-	fc, err = NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
 	assert.NoError(t, err)
+	assert.EqualValues(t, 0, res.HeadOffset)
+	assert.EqualValues(t, 0, res.CommitOffset)
 
-	stream = newMockServerReplicateStream()
-	stream.AddRequest(createAddRequest(t, 6, 0, map[string]string{"a": "2", "b": "2"}, wal.InvalidOffset))
-	err = fc.Replicate(stream)
-	assert.Equal(t, common.CodeInvalidTerm, status.Code(err), "Unexpected error: %s", err)
-	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
-	assert.EqualValues(t, 5, fc.Term())
+	// The advertised commit offset is honored as the entries arrive, even
+	// though they carry a lower one
+	for offset := int64(1); offset <= 4; offset++ {
+		stream.AddRequest(createAddRequest(t, 1, offset, map[string]string{"a": fmt.Sprint(offset)}, 0))
+		assert.EqualValues(t, offset, stream.GetResponse().Offset)
+	}
+	assert.EqualValues(t, 3, fc.(*followerController).advertisedCommitOffset.Load())
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 3
+	}, 10*time.Second, 10*time.Millisecond)
+
+	res, err = fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, res.HeadOffset)
+	assert.EqualValues(t, 3, res.CommitOffset)
+
+	dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: "a", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("3"), dbRes.Value)
 
 	assert.NoError(t, fc.Close())
 	assert.NoError(t, kvFactory.Close())
 	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollower_RejectTruncateInvalidTerm(t *testing.T) {
+func TestFollower_CommitOffsetEqualToHead(t *testing.T) {
 	var shardId int64
 	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
 	assert.NoError(t, err)
@@ -528,11 +821,206 @@ func TestFollower_RejectTruncateInvalidTerm(t *testing.T) {
 	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
 	assert.NoError(t, err)
 
-	assert.Equal(t, proto.ServingStatus_NOT_MEMBER, fc.Status())
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
 
-	fenceRes, err := fc.NewTerm(&proto.NewTermRequest{Term: 5})
-	assert.NoError(t, err)
-	assert.Equal(t, InvalidEntryId, fenceRes.HeadEntryId)
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+
+	// The entry is committed along with itself, and applied once it's synced
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, 1))
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, res.HeadOffset)
+	assert.EqualValues(t, 1, res.CommitOffset)
+
+	// The same commit offset without any entry doesn't change anything
+	stream.AddRequest(createCommitOffsetRequest(1, 1))
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+
+	res, err = fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, res.HeadOffset)
+	assert.EqualValues(t, 1, res.CommitOffset)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_CommitOffsetRegression(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, 1))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// A commit offset going backwards is ignored, with or without an entry
+	stream.AddRequest(createCommitOffsetRequest(1, 0))
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+	assert.EqualValues(t, 1, fc.(*followerController).advertisedCommitOffset.Load())
+
+	stream.AddRequest(createAddRequest(t, 1, 2, map[string]string{"a": "2"}, wal.InvalidOffset))
+	assert.EqualValues(t, 2, stream.GetResponse().Offset)
+	assert.EqualValues(t, 1, fc.(*followerController).advertisedCommitOffset.Load())
+
+	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, res.HeadOffset)
+	assert.EqualValues(t, 1, res.CommitOffset)
+
+	dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: "a", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), dbRes.Value)
+
+	// The replication goes on from the commit offset that was reached
+	stream.AddRequest(createCommitOffsetRequest(1, 2))
+	assert.EqualValues(t, 2, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_AdvanceCommitOffsetWithoutEntryWrongTerm(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+
+	stream := newMockServerReplicateStream()
+	stream.AddRequest(createCommitOffsetRequest(1, 0))
+
+	// The follower rejects the commit offset from an older term
+	err = fc.Replicate(stream)
+	assert.Equal(t, common.CodeInvalidTerm, status.Code(err))
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.Equal(t, wal.InvalidOffset, fc.CommitOffset())
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollowerController_RejectEntriesWithDifferentTerm(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
+		DataDir:     t.TempDir(),
+		CacheSizeMB: 1,
+	})
+	assert.NoError(t, err)
+
+	db, err := kv.NewDB(common.DefaultNamespace, shardId, kvFactory, 1*time.Hour, common.SystemClock)
+	assert.NoError(t, err)
+	// Force a new term in the DB before opening
+	assert.NoError(t, db.UpdateTerm(5))
+	assert.NoError(t, db.Close())
+
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 5, fc.Term())
+
+	stream := newMockServerReplicateStream()
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "1", "b": "1"}, wal.InvalidOffset))
+
+	// Follower will reject the entry because it's from an earlier term
+	err = fc.Replicate(stream)
+	assert.Error(t, err)
+	assert.Equal(t, common.CodeInvalidTerm, status.Code(err))
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 5, fc.Term())
+
+	// If we send an entry of same term, it will be accepted. The leader opens
+	// a new stream, since the receiving of the failed one can outlive it
+	stream = newMockServerReplicateStream()
+	stream.AddRequest(createAddRequest(t, 5, 0, map[string]string{"a": "2", "b": "2"}, wal.InvalidOffset))
+
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	// Wait for acks
+	r1 := stream.GetResponse()
+
+	assert.Equal(t, proto.ServingStatus_FOLLOWER, fc.Status())
+	assert.EqualValues(t, 0, r1.Offset)
+	assert.NoError(t, fc.Close())
+	close(stream.requests)
+
+	// A higher term will also be rejected, since the follower was not
+	// fenced into it
+	fc, err = NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	stream = newMockServerReplicateStream()
+	stream.AddRequest(createAddRequest(t, 6, 1, map[string]string{"a": "2", "b": "2"}, wal.InvalidOffset))
+	err = fc.Replicate(stream)
+	assert.Equal(t, common.CodeTermNotFenced, status.Code(err), "Unexpected error: %s", err)
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 5, fc.Term())
+	assert.EqualValues(t, 0, fc.(*followerController).wal.LastOffset())
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_RejectTruncateInvalidTerm(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	assert.Equal(t, proto.ServingStatus_NOT_MEMBER, fc.Status())
+
+	fenceRes, err := fc.NewTerm(&proto.NewTermRequest{Term: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, InvalidEntryId, fenceRes.HeadEntryId)
 
 	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
 	assert.EqualValues(t, 5, fc.Term())
@@ -580,7 +1068,7 @@ func prepareTestDb(t *testing.T) kv.Snapshot {
 				Key:   fmt.Sprintf("key-%d", i),
 				Value: []byte(fmt.Sprintf("value-%d", i)),
 			}},
-		}, int64(i), 0, kv.NoOpCallback)
+		}, 1, int64(i), 0, kv.NoOpCallback)
 		assert.NoError(t, err)
 	}
 
@@ -592,6 +1080,20 @@ func prepareTestDb(t *testing.T) kv.Snapshot {
 	return snapshot
 }
 
+// Encode the snapshot returned by prepareTestDb as the leader would.
+func snapshotChunks(t *testing.T, snapshot kv.Snapshot, term int64) []*proto.SnapshotChunk {
+	t.Helper()
+
+	encoder := newSnapshotEncoder(term, &proto.EntryId{Term: 1, Offset: 99})
+	var chunks []*proto.SnapshotChunk
+	for snapshot.Valid() {
+		chunk, err := snapshot.Chunk()
+		assert.NoError(t, err)
+		chunks = append(chunks, encoder.encode(chunk, !snapshot.Next()))
+	}
+	return chunks
+}
+
 func TestFollower_HandleSnapshot(t *testing.T) {
 	var shardId int64
 	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
@@ -619,6 +1121,9 @@ func TestFollower_HandleSnapshot(t *testing.T) {
 	assert.EqualValues(t, 0, r1.Offset)
 	close(stream.requests)
 
+	// Wait for the replicate stream to be fully closed
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
+
 	// Load snapshot into follower
 	snapshot := prepareTestDb(t)
 
@@ -632,17 +1137,8 @@ func TestFollower_HandleSnapshot(t *testing.T) {
 		wg.Done()
 	}()
 
-	for ; snapshot.Valid(); snapshot.Next() {
-		chunk, err := snapshot.Chunk()
-		assert.NoError(t, err)
-		content := chunk.Content()
-		snapshotStream.AddChunk(&proto.SnapshotChunk{
-			Term:       1,
-			Name:       chunk.Name(),
-			Content:    content,
-			ChunkIndex: chunk.Index(),
-			ChunkCount: chunk.TotalCount(),
-		})
+	for _, chunk := range snapshotChunks(t, snapshot, 1) {
+		snapshotStream.AddChunk(chunk)
 	}
 
 	close(snapshotStream.chunks)
@@ -710,177 +1206,322 @@ func TestFollower_HandleSnapshot(t *testing.T) {
 	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollower_DisconnectLeader(t *testing.T) {
+func TestFollower_AdvanceCommitOffsetAfterSnapshot(t *testing.T) {
 	var shardId int64
-	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
+		DataDir: t.TempDir(),
+	})
 	assert.NoError(t, err)
-	walFactory := newTestWalFactory(t)
-
-	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
-	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
-
-	stream := newMockServerReplicateStream()
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
 
-	go func() { assert.NoError(t, fc.Replicate(stream)) }()
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
 
-	assert.Eventually(t, closeChanIsNotNil(fc), 10*time.Second, 10*time.Millisecond)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
 
-	// It's not possible to add a new leader stream
-	assert.ErrorIs(t, fc.Replicate(stream), common.ErrorLeaderAlreadyConnected)
+	snapshotStream := newMockServerSendSnapshotStream()
+	wg := sync.WaitGroup{}
+	wg.Add(1)
 
-	// When we fence again, the leader should have been cutoff
-	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 2})
-	assert.NoError(t, err)
+	go func() {
+		assert.NoError(t, fc.SendSnapshot(snapshotStream))
+		wg.Done()
+	}()
 
-	assert.Nil(t, fc.(*followerController).closeStreamWg)
+	for _, chunk := range snapshotChunks(t, prepareTestDb(t), 1) {
+		snapshotStream.AddChunk(chunk)
+	}
+	close(snapshotStream.chunks)
+	wg.Wait()
 
+	stream := newMockServerReplicateStream()
 	go func() {
 		// cancelled due to fc.Close() below
 		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
 	}()
 
-	assert.Eventually(t, closeChanIsNotNil(fc), 10*time.Second, 10*time.Millisecond)
+	// The wal is empty, though the entries of the snapshot are durable
+	stream.AddRequest(createCommitOffsetRequest(1, 99))
+	assert.EqualValues(t, 99, stream.GetResponse().Offset)
 
 	assert.NoError(t, fc.Close())
 	assert.NoError(t, kvFactory.Close())
 	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollower_DupEntries(t *testing.T) {
+func TestFollower_HandleSnapshotCorrupted(t *testing.T) {
 	var shardId int64
-	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
-	walFactory := newTestWalFactory(t)
+	dataDir := t.TempDir()
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: dataDir})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
 
-	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
-	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
 
 	stream := newMockServerReplicateStream()
-	go func() {
-		// cancelled due to fc.Close() below
-		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
-	}()
+	go func() { assert.NoError(t, fc.Replicate(stream)) }()
 
-	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "1"}, wal.InvalidOffset))
-	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "1"}, wal.InvalidOffset))
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, 0))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+	assert.Eventually(t, func() bool { return fc.CommitOffset() == 0 }, 10*time.Second, 10*time.Millisecond)
+	close(stream.requests)
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
 
-	// Wait for responses
-	r1 := stream.GetResponse()
-	assert.EqualValues(t, 0, r1.Offset)
+	snapshot := prepareTestDb(t)
+	chunks := snapshotChunks(t, snapshot, 1)
+	assert.Greater(t, len(chunks), 2)
 
-	r2 := stream.GetResponse()
-	assert.EqualValues(t, 0, r2.Offset)
+	sendSnapshot := func(chunks []*proto.SnapshotChunk) error {
+		snapshotStream := newMockServerSendSnapshotStream()
+		for _, chunk := range chunks {
+			snapshotStream.AddChunk(chunk)
+		}
+		close(snapshotStream.chunks)
+		return fc.SendSnapshot(snapshotStream)
+	}
 
-	// Write next entry
-	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "4", "b": "5"}, wal.InvalidOffset))
-	r3 := stream.GetResponse()
-	assert.EqualValues(t, 1, r3.Offset)
+	assertPreviousDb := func() {
+		t.Helper()
 
-	// Go back with older offset
-	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "4", "b": "5"}, wal.InvalidOffset))
-	r4 := stream.GetResponse()
-	assert.EqualValues(t, 0, r4.Offset)
+		dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: "a", IncludeValue: true})
+		assert.NoError(t, err)
+		assert.Equal(t, proto.Status_OK, dbRes.Status)
+		assert.Equal(t, []byte("0"), dbRes.Value)
+		assert.EqualValues(t, 0, fc.CommitOffset())
+		assert.EqualValues(t, 0, fc.(*followerController).wal.LastOffset())
+		assert.EqualValues(t, 1, fc.Term())
+
+		// The partial download is deleted
+		_, err = os.Stat(filepath.Join(dataDir, common.DefaultNamespace, "shard-0.loading"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	}
 
+	// A bit flipped in a chunk in the middle of the transfer
+	corrupted := slices.Clone(chunks)
+	corrupted[1] = chunks[1].CloneVT()
+	corrupted[1].Content[len(corrupted[1].Content)/2] ^= 0x10
+	assert.ErrorIs(t, sendSnapshot(corrupted), common.ErrorSnapshotCorrupted)
+	assertPreviousDb()
+
+	// A chunk whose crc matches the corrupted content is caught by the digest
+	corrupted[1].Crc = crc32.Checksum(corrupted[1].Content, snapshotCrcTable)
+	assert.ErrorIs(t, sendSnapshot(corrupted), common.ErrorSnapshotCorrupted)
+	assertPreviousDb()
+
+	// A snapshot that ends before the last chunk
+	assert.ErrorIs(t, sendSnapshot(chunks[:len(chunks)-1]), common.ErrorSnapshotCorrupted)
+	assertPreviousDb()
+
+	// A snapshot that is behind the entries applied by the follower
+	outdated := slices.Clone(chunks)
+	outdated[0] = chunks[0].CloneVT()
+	outdated[0].EntryId = &proto.EntryId{Term: 0, Offset: wal.InvalidOffset}
+	assert.ErrorIs(t, sendSnapshot(outdated), common.ErrorSnapshotOutdated)
+	assertPreviousDb()
+
+	// The follower keeps working, and can still install a valid snapshot
+	assert.NoError(t, sendSnapshot(chunks))
+	assert.EqualValues(t, 99, fc.CommitOffset())
+	dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: "key-99", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value-99"), dbRes.Value)
+
+	assert.NoError(t, snapshot.Close())
 	assert.NoError(t, fc.Close())
 	assert.NoError(t, kvFactory.Close())
 	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollowerController_DeleteShard(t *testing.T) {
+func TestFollower_RestoreThenRestartThenElection(t *testing.T) {
 	var shardId int64
-	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
-	walFactory := newTestWalFactory(t)
-
-	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
-	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
+		DataDir: t.TempDir(),
+	})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
 
-	stream := newMockServerReplicateStream()
-	go func() {
-		// cancelled due to fc.Close() below
-		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
-	}()
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
 
-	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "1"}, wal.InvalidOffset))
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
 
-	// Wait for responses
-	r1 := stream.GetResponse()
-	assert.EqualValues(t, 0, r1.Offset)
+	// Restore the follower from a snapshot, with entries up to {term: 1, offset: 99}
+	snapshot := prepareTestDb(t)
+	snapshotStream := newMockServerSendSnapshotStream()
+	wg := sync.WaitGroup{}
+	wg.Add(1)
 
-	_, err := fc.DeleteShard(&proto.DeleteShardRequest{
-		Namespace: common.DefaultNamespace,
-		Shard:     shardId,
-		Term:      1,
-	})
+	go func() {
+		assert.NoError(t, fc.SendSnapshot(snapshotStream))
+		wg.Done()
+	}()
+
+	for _, chunk := range snapshotChunks(t, snapshot, 1) {
+		snapshotStream.AddChunk(chunk)
+	}
+
+	close(snapshotStream.chunks)
+	wg.Wait()
+
+	assert.Equal(t, wal.InvalidOffset, fc.(*followerController).wal.LastOffset())
+	assert.NoError(t, fc.Close())
+
+	// After the restart, the wal is still empty
+	fc, err = NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 99, fc.CommitOffset())
 
+	// The follower must advertise the entries it has in the database
+	newTermRes, err := fc.NewTerm(&proto.NewTermRequest{Term: 2})
 	assert.NoError(t, err)
+	AssertProtoEqual(t, &proto.EntryId{Term: 1, Offset: 99}, newTermRes.HeadEntryId)
+
+	// The new leader has the same entries, so nothing gets truncated
+	truncateRes, err := fc.Truncate(&proto.TruncateRequest{
+		Term:        2,
+		HeadEntryId: &proto.EntryId{Term: 1, Offset: 99},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 99, truncateRes.HeadEntryId.Offset)
+	assert.EqualValues(t, 99, fc.CommitOffset())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockServerReplicateStream()
+	stream.ctx = ctx
+	go func() { assert.ErrorIs(t, fc.Replicate(stream), context.Canceled) }()
+
+	stream.AddRequest(createAddRequest(t, 2, 100, map[string]string{"a": "100"}, 100))
+	assert.EqualValues(t, 100, stream.GetResponse().Offset)
+
+	assert.Eventually(t, func() bool { return fc.CommitOffset() == 100 }, 10*time.Second, 10*time.Millisecond)
+
+	for _, key := range []string{"key-0", "key-99", "a"} {
+		dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: key})
+		assert.NoError(t, err)
+		assert.Equal(t, proto.Status_OK, dbRes.Status)
+	}
+
+	cancel()
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
 
 	assert.NoError(t, fc.Close())
 	assert.NoError(t, kvFactory.Close())
 	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollowerController_DeleteShard_WrongTerm(t *testing.T) {
+func TestFollower_HandleSnapshotInterrupted(t *testing.T) {
 	var shardId int64
-	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
-	walFactory := newTestWalFactory(t)
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
+		DataDir: t.TempDir(),
+	})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
 
-	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
-	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
 
-	_, err := fc.DeleteShard(&proto.DeleteShardRequest{
-		Namespace: common.DefaultNamespace,
-		Shard:     shardId,
-		Term:      2,
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	snapshot := prepareTestDb(t)
+	chunks := snapshotChunks(t, snapshot, 1)
+	assert.Greater(t, len(chunks), 1)
+
+	// The leader goes away in the middle of the transfer
+	snapshotStream := newMockServerSendSnapshotStream()
+	snapshotStream.AddChunk(chunks[0])
+	snapshotStream.Fail(context.Canceled)
+	assert.ErrorIs(t, fc.SendSnapshot(snapshotStream), context.Canceled)
+
+	// A chunk that doesn't belong to any file fails the transfer, without blocking the follower
+	snapshotStream = newMockServerSendSnapshotStream()
+	invalidChunk := chunks[0].CloneVT()
+	invalidChunk.ChunkIndex = 1
+	snapshotStream.AddChunk(invalidChunk)
+	assert.Error(t, fc.SendSnapshot(snapshotStream))
+
+	// The follower can be fenced again after the failed attempts
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+
+	// And a complete snapshot in the new term is installed correctly
+	snapshotStream = newMockServerSendSnapshotStream()
+	for _, chunk := range chunks {
+		chunk.Term = 2
+		snapshotStream.AddChunk(chunk)
+	}
+	close(snapshotStream.chunks)
+	assert.NoError(t, fc.SendSnapshot(snapshotStream))
+
+	statusRes, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, statusRes.Term)
+	assert.EqualValues(t, 99, statusRes.HeadOffset)
+	assert.EqualValues(t, 99, statusRes.CommitOffset)
+
+	dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{
+		Key:          "key-99",
+		IncludeValue: true,
 	})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, dbRes.Status)
+	assert.Equal(t, []byte("value-99"), dbRes.Value)
 
-	assert.ErrorIs(t, err, common.ErrorInvalidTerm)
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollowerController_Closed(t *testing.T) {
-	var shard int64 = 1
-
+func TestFollower_DisconnectLeader(t *testing.T) {
+	var shardId int64
 	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
 	assert.NoError(t, err)
 	walFactory := newTestWalFactory(t)
 
-	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shard, walFactory, kvFactory)
-	assert.NoError(t, err)
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
 
-	assert.EqualValues(t, wal.InvalidTerm, fc.Term())
-	assert.Equal(t, proto.ServingStatus_NOT_MEMBER, fc.Status())
+	stream := newMockServerReplicateStream()
 
-	assert.NoError(t, fc.Close())
+	go func() { assert.NoError(t, fc.Replicate(stream)) }()
 
-	res, err := fc.NewTerm(&proto.NewTermRequest{
-		Shard: shard,
-		Term:  2,
-	})
+	assert.Eventually(t, closeChanIsNotNil(fc), 10*time.Second, 10*time.Millisecond)
 
-	assert.Nil(t, res)
-	assert.Equal(t, common.CodeAlreadyClosed, status.Code(err))
+	// It's not possible to add a new leader stream
+	assert.ErrorIs(t, fc.Replicate(stream), common.ErrorLeaderAlreadyConnected)
 
-	res2, err := fc.Truncate(&proto.TruncateRequest{
-		Shard: shard,
-		Term:  2,
-		HeadEntryId: &proto.EntryId{
-			Term:   2,
-			Offset: 1,
-		},
-	})
+	// When we fence again, the leader should have been cutoff
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.NoError(t, err)
 
-	assert.Nil(t, res2)
-	assert.Equal(t, common.CodeAlreadyClosed, status.Code(err))
+	assert.Nil(t, fc.(*followerController).closeStreamWg)
+
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	assert.Eventually(t, closeChanIsNotNil(fc), 10*time.Second, 10*time.Millisecond)
 
+	assert.NoError(t, fc.Close())
 	assert.NoError(t, kvFactory.Close())
 	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollower_GetStatus(t *testing.T) {
+func TestFollower_DupEntries(t *testing.T) {
 	var shardId int64
 	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
 	walFactory := newTestWalFactory(t)
 
 	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
-	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
 
 	stream := newMockServerReplicateStream()
 	go func() {
@@ -888,135 +1529,1147 @@ func TestFollower_GetStatus(t *testing.T) {
 		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
 	}()
 
-	stream.AddRequest(createAddRequest(t, 2, 0, map[string]string{"a": "0", "b": "1"}, wal.InvalidOffset))
-	stream.AddRequest(createAddRequest(t, 2, 1, map[string]string{"a": "0", "b": "1"}, 0))
-	stream.AddRequest(createAddRequest(t, 2, 2, map[string]string{"a": "0", "b": "1"}, 1))
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "1"}, wal.InvalidOffset))
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "1"}, wal.InvalidOffset))
 
 	// Wait for responses
 	r1 := stream.GetResponse()
 	assert.EqualValues(t, 0, r1.Offset)
 
 	r2 := stream.GetResponse()
-	assert.EqualValues(t, 1, r2.Offset)
+	assert.EqualValues(t, 0, r2.Offset)
 
+	// Write next entry
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "4", "b": "5"}, wal.InvalidOffset))
 	r3 := stream.GetResponse()
-	assert.EqualValues(t, 2, r3.Offset)
-
-	assert.Eventually(t, func() bool {
-		res, _ := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
-		return res.CommitOffset == 1
-	}, 10*time.Second, 100*time.Millisecond)
+	assert.EqualValues(t, 1, r3.Offset)
 
-	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
-	assert.NoError(t, err)
-	assert.Equal(t, &proto.GetStatusResponse{
-		Term:         2,
-		Status:       proto.ServingStatus_FOLLOWER,
-		HeadOffset:   2,
-		CommitOffset: 1,
-	}, res)
+	// Go back with older offset
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "4", "b": "5"}, wal.InvalidOffset))
+	r4 := stream.GetResponse()
+	assert.EqualValues(t, 0, r4.Offset)
 
 	assert.NoError(t, fc.Close())
 	assert.NoError(t, kvFactory.Close())
 	assert.NoError(t, walFactory.Close())
 }
 
-func TestFollower_HandleSnapshotWithWrongTerm(t *testing.T) {
+func TestFollower_RejectEntriesWithGap(t *testing.T) {
 	var shardId int64
-	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
-		DataDir: t.TempDir(),
-	})
-	assert.NoError(t, err)
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
 	walFactory := newTestWalFactory(t)
 
-	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
-	assert.NoError(t, err)
-
-	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
-	assert.NoError(t, err)
-	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
-	assert.EqualValues(t, 1, fc.Term())
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
 
+	ctx, cancel := context.WithCancel(context.Background())
 	stream := newMockServerReplicateStream()
-	go func() { assert.NoError(t, fc.Replicate(stream)) }()
+	stream.ctx = ctx
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, wal.InvalidOffset))
+	// Duplicate of the current head
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, wal.InvalidOffset))
+	// Entry 2 is missing
+	stream.AddRequest(createAddRequest(t, 1, 3, map[string]string{"a": "3"}, wal.InvalidOffset))
+
+	err := fc.Replicate(stream)
+	assert.Equal(t, common.CodeInvalidNextOffset, status.Code(err), "Unexpected error: %s", err)
+
+	var acks []int64
+	for i := 0; i < 3; i++ {
+		acks = append(acks, stream.GetResponse().Offset)
+	}
+	assert.ElementsMatch(t, []int64{0, 1, 1}, acks)
+	assert.EqualValues(t, 1, fc.(*followerController).wal.LastOffset())
+	cancel()
 
-	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "1"}, 0))
+	// The leader can resume from the last acked entry
+	stream = newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
 
-	// Wait for acks
-	r1 := stream.GetResponse()
-	assert.Equal(t, proto.ServingStatus_FOLLOWER, fc.Status())
-	assert.EqualValues(t, 0, r1.Offset)
-	close(stream.requests)
+	stream.AddRequest(createAddRequest(t, 1, 2, map[string]string{"a": "2"}, wal.InvalidOffset))
+	assert.EqualValues(t, 2, stream.GetResponse().Offset)
+	stream.AddRequest(createAddRequest(t, 1, 3, map[string]string{"a": "3"}, wal.InvalidOffset))
+	assert.EqualValues(t, 3, stream.GetResponse().Offset)
+	assert.EqualValues(t, 3, fc.(*followerController).wal.LastOffset())
 
-	// Load snapshot into follower
-	snapshot := prepareTestDb(t)
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
 
-	snapshotStream := newMockServerSendSnapshotStream()
+func TestFollower_AppendAfterTruncate(t *testing.T) {
+	var shardId int64
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
 
-	wg := common.NewWaitGroup(1)
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
 
-	go func() {
-		err := fc.SendSnapshot(snapshotStream)
-		if err != nil {
-			wg.Fail(err)
-		} else {
-			wg.Done()
-		}
-	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockServerReplicateStream()
+	stream.ctx = ctx
+	go func() { assert.ErrorIs(t, fc.Replicate(stream), context.Canceled) }()
 
-	for ; snapshot.Valid(); snapshot.Next() {
-		chunk, err := snapshot.Chunk()
-		assert.NoError(t, err)
-		content := chunk.Content()
-		snapshotStream.AddChunk(&proto.SnapshotChunk{
-			Term:       2,
-			Name:       chunk.Name(),
-			Content:    content,
-			ChunkIndex: chunk.Index(),
-			ChunkCount: chunk.TotalCount(),
-		})
+	for i := int64(0); i < 3; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"a": "0"}, wal.InvalidOffset))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
 	}
 
-	close(snapshotStream.chunks)
+	// The leader goes away
+	cancel()
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
 
-	// The snapshot sending should fail because the term is invalid
-	assert.ErrorIs(t, common.ErrorInvalidTerm, wg.Wait(context.Background()))
+	// The new leader only has the first entry of term 1
+	_, err := fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.NoError(t, err)
+	truncateRes, err := fc.Truncate(&proto.TruncateRequest{
+		Term:        2,
+		HeadEntryId: &proto.EntryId{Term: 1, Offset: 0},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, truncateRes.HeadEntryId.Offset)
 
-	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 5})
+	stream = newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	// Entries from the new term must be appended right after the truncation point
+	// and not be confused with the discarded entries from the old term
+	for i := int64(1); i < 4; i++ {
+		stream.AddRequest(createAddRequest(t, 2, i, map[string]string{"a": "1"}, wal.InvalidOffset))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+
+	reader, err := fc.(*followerController).wal.NewReader(0)
 	assert.NoError(t, err)
-	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
-	assert.EqualValues(t, 5, fc.Term())
+	for i := int64(1); i < 4; i++ {
+		assert.True(t, reader.HasNext())
+		entry, err := reader.ReadNext()
+		assert.NoError(t, err)
+		assert.EqualValues(t, i, entry.Offset)
+		assert.EqualValues(t, 2, entry.Term)
+	}
+	assert.False(t, reader.HasNext())
+	assert.NoError(t, reader.Close())
 
 	assert.NoError(t, fc.Close())
 	assert.NoError(t, kvFactory.Close())
 	assert.NoError(t, walFactory.Close())
 }
 
-func closeChanIsNotNil(fc FollowerController) func() bool {
-	return func() bool {
-		_fc := fc.(*followerController)
-		_fc.Lock()
-		defer _fc.Unlock()
-		return _fc.closeStreamWg != nil
-	}
-}
+func TestFollower_TruncateWithDbAhead(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
+		DataDir:     t.TempDir(),
+		CacheSizeMB: 1,
+	})
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
 
-func createAddRequest(t *testing.T, term int64, offset int64,
-	kvs map[string]string,
-	commitOffset int64) *proto.Append {
-	t.Helper()
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
 
-	br := &proto.WriteRequest{}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockServerReplicateStream()
+	stream.ctx = ctx
+	go func() { assert.ErrorIs(t, fc.Replicate(stream), context.Canceled) }()
 
-	for k, v := range kvs {
-		br.Puts = append(br.Puts, &proto.PutRequest{
-			Key:   k,
-			Value: []byte(v),
-		})
+	for i := int64(0); i < 3; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"a": fmt.Sprintf("%d", i)}, i))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
 	}
 
-	entry, err := pb.Marshal(wrapInLogEntryValue(br))
-	assert.NoError(t, err)
+	// Wait for all the entries to be applied in the db
+	assert.Eventually(t, func() bool { return fc.CommitOffset() == 2 }, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
+
+	// The new leader only has the first entry, though the follower
+	// has already applied all the 3 entries
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.NoError(t, err)
+	truncateRes, err := fc.Truncate(&proto.TruncateRequest{
+		Term:        2,
+		HeadEntryId: &proto.EntryId{Term: 1, Offset: 0},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, truncateRes.HeadEntryId.Term)
+	assert.EqualValues(t, wal.InvalidOffset, truncateRes.HeadEntryId.Offset)
+	assert.EqualValues(t, wal.InvalidOffset, fc.CommitOffset())
+
+	// All the data that was not in the log anymore must be gone
+	dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, dbRes.Status)
+	assert.EqualValues(t, wal.InvalidOffset, fc.(*followerController).wal.LastOffset())
+
+	// The leader converges the follower by sending a snapshot
+	snapshot := prepareTestDb(t)
+	snapshotStream := newMockServerSendSnapshotStream()
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		assert.NoError(t, fc.SendSnapshot(snapshotStream))
+		wg.Done()
+	}()
+
+	for _, chunk := range snapshotChunks(t, snapshot, 2) {
+		snapshotStream.AddChunk(chunk)
+	}
+
+	close(snapshotStream.chunks)
+	wg.Wait()
+
+	assert.EqualValues(t, 99, fc.CommitOffset())
+	dbRes, err = fc.(*followerController).db.Get(&proto.GetRequest{Key: "key-0", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, dbRes.Status)
+	assert.Equal(t, []byte("value-0"), dbRes.Value)
+
+	assert.NoError(t, fc.Close())
+
+	// The term must have survived the wipe of the database
+	fc, err = NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, fc.Term())
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 99, fc.CommitOffset())
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// The database must not be wiped while the committed entries are being
+// applied to it.
+func TestFollower_TruncateWithDbAheadWhileApplying(t *testing.T) {
+	var shardId int64
+	pebbleFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	kvFactory := newGatedKVFactory(pebbleFactory)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockServerReplicateStream()
+	stream.ctx = ctx
+	go func() { assert.ErrorIs(t, fc.Replicate(stream), context.Canceled) }()
+
+	for i := int64(0); i < 3; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"a": fmt.Sprintf("%d", i)}, i))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+	assert.Eventually(t, func() bool { return fc.CommitOffset() == 2 }, 10*time.Second, 10*time.Millisecond)
+
+	// The next entries are blocked while they are applied
+	kvFactory.closeGate()
+	for i := int64(3); i < 5; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"b": fmt.Sprintf("%d", i)}, i))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+	kvFactory.waitForBlockedBatch()
+
+	cancel()
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.NoError(t, err)
+
+	truncated := make(chan *proto.TruncateResponse)
+	go func() {
+		truncateRes, err := fc.Truncate(&proto.TruncateRequest{
+			Term:        2,
+			HeadEntryId: &proto.EntryId{Term: 1, Offset: 0},
+		})
+		assert.NoError(t, err)
+		truncated <- truncateRes
+	}()
+
+	// The truncation waits for the applying to stop using the database
+	select {
+	case <-truncated:
+		assert.Fail(t, "the database was wiped while the entries were applied")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, proto.ServingStatus_FOLLOWER, fc.Status())
+
+	kvFactory.openGate()
+	truncateRes := <-truncated
+	assert.EqualValues(t, wal.InvalidOffset, truncateRes.HeadEntryId.Offset)
+	assert.EqualValues(t, wal.InvalidOffset, fc.CommitOffset())
+
+	for _, key := range []string{"a", "b"} {
+		dbRes, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: key})
+		assert.NoError(t, err)
+		assert.Equal(t, proto.Status_KEY_NOT_FOUND, dbRes.Status)
+	}
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_NewTermDuringReplay(t *testing.T) {
+	var shardId int64
+	pebbleFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	kvFactory := newGatedKVFactory(pebbleFactory)
+	walFactory := newTestWalFactory(t)
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockServerReplicateStream()
+	stream.ctx = ctx
+	go func() {
+		// Closed by the new term
+		assert.NoError(t, fc.Replicate(stream))
+	}()
+
+	// The replay is blocked on the database until the gate is opened
+	kvFactory.closeGate()
+
+	n := int64(5000)
+	for i := int64(0); i < n; i++ {
+		commitOffset := wal.InvalidOffset
+		if i == n-1 {
+			// Commit all the entries at once, triggering a long replay
+			commitOffset = i
+		}
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{fmt.Sprintf("key-%d", i): "0"}, commitOffset))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+
+	// Fencing must not wait for the committed entries to be applied
+	kvFactory.waitForBlockedBatch()
+	_, err := fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 2, fc.Term())
+
+	// The replay was still blocked when the fencing returned
+	assert.EqualValues(t, wal.InvalidOffset, fc.CommitOffset())
+	kvFactory.openGate()
+
+	// The entries were committed in the previous term, so they must
+	// all be applied anyway
+	assert.Eventually(t, func() bool { return fc.CommitOffset() == n-1 }, 30*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_ReplayAfterReconnect(t *testing.T) {
+	var shardId int64
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockServerReplicateStream()
+	stream.ctx = ctx
+	go func() { assert.ErrorIs(t, fc.Replicate(stream), context.Canceled) }()
+
+	for i := int64(0); i < 200; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"a": fmt.Sprintf("%d", i)}, wal.InvalidOffset))
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+
+	// The stream breaks and the leader reconnects, though it
+	// did not receive the acks for the last 100 entries
+	cancel()
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
+
+	stream = newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	for i := int64(100); i < 210; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"a": fmt.Sprintf("%d", i)}, wal.InvalidOffset))
+	}
+
+	for i := int64(100); i < 210; i++ {
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+
+	// Each entry must be in the wal exactly once
+	reader, err := fc.(*followerController).wal.NewReader(wal.InvalidOffset)
+	assert.NoError(t, err)
+	for i := int64(0); i < 210; i++ {
+		assert.True(t, reader.HasNext())
+		entry, err := reader.ReadNext()
+		assert.NoError(t, err)
+		assert.EqualValues(t, i, entry.Offset)
+	}
+	assert.False(t, reader.HasNext())
+	assert.NoError(t, reader.Close())
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// A leader that doesn't wait for the acks can't make the follower buffer
+// more than the replication window while the wal is slow.
+func TestFollower_ReplicationWindowExceeded(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newSlowAppendWalFactory(newTestWalFactory(t), 0)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	walFactory.closeGate()
+
+	stream := newMockServerReplicateStream()
+	replicateErr := make(chan error)
+	go func() { replicateErr <- fc.Replicate(stream) }()
+
+	// The first entry is stuck in the wal, and the next ones fill the queue
+	for i := int64(0); i <= replicationWindow+1; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"a": fmt.Sprintf("%d", i)}, wal.InvalidOffset))
+	}
+
+	err = <-replicateErr
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	walFactory.openGate()
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// A leader that respects the replication window gets all the entries acked,
+// in order, even when the wal of the follower is slow.
+func TestFollower_ReplicationWindowWithSlowWal(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newSlowAppendWalFactory(newTestWalFactory(t), 100*time.Microsecond)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	n := int64(3 * replicationWindow)
+	appends := make([]*proto.Append, n)
+	for i := range appends {
+		appends[i] = createAddRequest(t, 1, int64(i), map[string]string{"a": fmt.Sprintf("%d", i)}, int64(i)-1)
+	}
+	replicateWithinWindow(t, stream, appends, replicationWindow)
+
+	assert.Eventually(t, func() bool { return fc.CommitOffset() == n-2 }, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// replicateWithinWindow sends the appends the way the leader does, never
+// having more unacked entries than the window, or than the window advertised
+// by the follower, and verifies that every entry is acked in order.
+func replicateWithinWindow(tb testing.TB, stream *mockServerReplicateStream, appends []*proto.Append, window int64) {
+	tb.Helper()
+
+	n := int64(len(appends))
+	next := int64(0)
+	ackOffset := wal.InvalidOffset
+	for ackOffset < n-1 {
+		for ; next < n && next-ackOffset <= window; next++ {
+			stream.AddRequest(appends[next])
+		}
+
+		ack := stream.GetResponse()
+		if ack.Offset != ackOffset+1 {
+			tb.Fatalf("received ack for offset %d after %d", ack.Offset, ackOffset)
+		}
+		ackOffset = ack.Offset
+		window = min(window, ack.Window)
+	}
+}
+
+func TestFollower_CloseWithActiveStream(t *testing.T) {
+	var shardId int64
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{
+		BaseWalDir: t.TempDir(),
+		SyncPolicy: wal.SyncPolicyAlways,
+	})
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockServerReplicateStream()
+	stream.ctx = ctx
+	replicateErr := make(chan error)
+	go func() { replicateErr <- fc.Replicate(stream) }()
+
+	// Keep sending entries while the controller is closed, within the
+	// replication window
+	var ackOffset atomic.Int64
+	ackOffset.Store(wal.InvalidOffset)
+	go func() {
+		for i := int64(0); ; i++ {
+			for i-ackOffset.Load() > replicationWindow && ctx.Err() == nil {
+				time.Sleep(time.Millisecond)
+			}
+
+			select {
+			case stream.requests <- createAddRequest(t, 1, i, map[string]string{"a": fmt.Sprintf("%d", i)}, i-1):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		for ctx.Err() == nil {
+			select {
+			case ack := <-stream.responses:
+				ackOffset.Store(ack.Offset)
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	assert.Eventually(t, func() bool { return fc.CommitOffset() >= 10 }, 10*time.Second, 1*time.Millisecond)
+
+	assert.NoError(t, fc.Close())
+	assert.ErrorIs(t, <-replicateErr, context.Canceled)
+
+	// Closing again is a no-op
+	assert.NoError(t, fc.Close())
+
+	_, err := fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.ErrorIs(t, err, common.ErrorAlreadyClosed)
+
+	// Simulate the termination of the grpc stream
+	cancel()
+
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_CloseWithConcurrentStreams(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	next := int64(0)
+	for i := int64(1); i <= 10; i++ {
+		fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+		assert.NoError(t, err)
+		_, err = fc.NewTerm(&proto.NewTermRequest{Term: i})
+		assert.NoError(t, err)
+
+		// Keep replacing the stream, as a leader that reconnects would do,
+		// until the controller is closed
+		done := make(chan any)
+		go func(term int64) {
+			defer close(done)
+			for {
+				ctx, cancel := context.WithCancel(context.Background())
+				stream := newMockServerReplicateStream()
+				stream.ctx = ctx
+				replicateErr := make(chan error, 1)
+				go func() { replicateErr <- fc.Replicate(stream) }()
+
+				for offset := next; offset < next+10; offset++ {
+					stream.AddRequest(createAddRequest(t, term, offset, map[string]string{"a": fmt.Sprintf("%d", offset)}, offset-1))
+				}
+
+				timeout := time.After(5 * time.Millisecond)
+			receiveAcks:
+				for {
+					select {
+					case ack := <-stream.responses:
+						next = max(next, ack.Offset+1)
+					case <-timeout:
+						break receiveAcks
+					}
+				}
+
+				cancel()
+				if err := <-replicateErr; status.Code(err) == common.CodeAlreadyClosed {
+					return
+				}
+			}
+		}(i)
+
+		time.Sleep(20 * time.Millisecond)
+		assert.NoError(t, fc.Close())
+		<-done
+	}
+
+	assert.Greater(t, next, int64(0))
+
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollowerController_DeleteShard(t *testing.T) {
+	var shardId int64
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "1"}, wal.InvalidOffset))
+
+	// Wait for responses
+	r1 := stream.GetResponse()
+	assert.EqualValues(t, 0, r1.Offset)
+
+	_, err := fc.DeleteShard(&proto.DeleteShardRequest{
+		Namespace: common.DefaultNamespace,
+		Shard:     shardId,
+		Term:      1,
+	})
+
+	assert.NoError(t, err)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollowerController_DeleteShard_WrongTerm(t *testing.T) {
+	var shardId int64
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+
+	_, err := fc.DeleteShard(&proto.DeleteShardRequest{
+		Namespace: common.DefaultNamespace,
+		Shard:     shardId,
+		Term:      2,
+	})
+
+	assert.ErrorIs(t, err, common.ErrorInvalidTerm)
+}
+
+func TestFollowerController_Closed(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shard, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, wal.InvalidTerm, fc.Term())
+	assert.Equal(t, proto.ServingStatus_NOT_MEMBER, fc.Status())
+
+	assert.NoError(t, fc.Close())
+
+	res, err := fc.NewTerm(&proto.NewTermRequest{
+		Shard: shard,
+		Term:  2,
+	})
+
+	assert.Nil(t, res)
+	assert.Equal(t, common.CodeAlreadyClosed, status.Code(err))
+
+	res2, err := fc.Truncate(&proto.TruncateRequest{
+		Shard: shard,
+		Term:  2,
+		HeadEntryId: &proto.EntryId{
+			Term:   2,
+			Offset: 1,
+		},
+	})
+
+	assert.Nil(t, res2)
+	assert.Equal(t, common.CodeAlreadyClosed, status.Code(err))
+
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_GetStatus(t *testing.T) {
+	var shardId int64
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 2, 0, map[string]string{"a": "0", "b": "1"}, wal.InvalidOffset))
+	stream.AddRequest(createAddRequest(t, 2, 1, map[string]string{"a": "0", "b": "1"}, 0))
+	stream.AddRequest(createAddRequest(t, 2, 2, map[string]string{"a": "0", "b": "1"}, 1))
+
+	// Wait for responses
+	r1 := stream.GetResponse()
+	assert.EqualValues(t, 0, r1.Offset)
+
+	r2 := stream.GetResponse()
+	assert.EqualValues(t, 1, r2.Offset)
+
+	r3 := stream.GetResponse()
+	assert.EqualValues(t, 2, r3.Offset)
+
+	assert.Eventually(t, func() bool {
+		res, _ := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+		return res.CommitOffset == 1
+	}, 10*time.Second, 100*time.Millisecond)
+
+	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.Equal(t, &proto.GetStatusResponse{
+		Term:         2,
+		Status:       proto.ServingStatus_FOLLOWER,
+		HeadOffset:   2,
+		CommitOffset: 1,
+		DiskUsage:    res.DiskUsage,
+	}, res)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_GetStatusTransitions(t *testing.T) {
+	var shardId int64
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.Equal(t, &proto.GetStatusResponse{
+		Term:         wal.InvalidTerm,
+		Status:       proto.ServingStatus_NOT_MEMBER,
+		HeadOffset:   wal.InvalidOffset,
+		CommitOffset: wal.InvalidOffset,
+		DiskUsage:    res.DiskUsage,
+	}, res)
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+	res, err = fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FENCED, res.Status)
+	assert.EqualValues(t, 1, res.Term)
+
+	_, err = fc.Truncate(&proto.TruncateRequest{
+		Term:        1,
+		HeadEntryId: InvalidEntryId,
+	})
+	assert.NoError(t, err)
+	res, err = fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FOLLOWER, res.Status)
+	assert.EqualValues(t, wal.InvalidOffset, res.HeadOffset)
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// Closed by the new term below
+		assert.NoError(t, fc.Replicate(stream))
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+	res, err = fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, res.HeadOffset)
+	assert.EqualValues(t, wal.InvalidOffset, res.CommitOffset)
+
+	// A new term fences the follower again and keeps the head offset
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 2})
+	assert.NoError(t, err)
+	res, err = fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.Equal(t, &proto.GetStatusResponse{
+		Term:         2,
+		Status:       proto.ServingStatus_FENCED,
+		HeadOffset:   0,
+		CommitOffset: wal.InvalidOffset,
+		DiskUsage:    res.DiskUsage,
+	}, res)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_Metrics(t *testing.T) {
+	var shardId int64 = 5
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	_, _ = fc.Truncate(&proto.TruncateRequest{Term: 1, HeadEntryId: InvalidEntryId})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, 0))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+	assert.Eventually(t, func() bool { return fc.CommitOffset() == 0 }, 10*time.Second, 10*time.Millisecond)
+
+	m, err := metrics.Start("localhost:0")
+	assert.NoError(t, err)
+
+	response, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", m.Port()))
+	assert.NoError(t, err)
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	labels := fmt.Sprintf(`oxia_namespace="%s",shard="%d"`, common.DefaultNamespace, shardId)
+	for _, series := range []string{
+		"oxia_server_follower_head_offset",
+		"oxia_server_follower_commit_offset",
+		"oxia_server_follower_term",
+		"oxia_server_follower_applied_entries_total",
+		"oxia_server_follower_new_term_total",
+		"oxia_server_follower_truncate_total",
+		"oxia_server_follower_status",
+		"oxia_server_follower_commit_lag",
+		"oxia_server_follower_appended_bytes_total",
+		"oxia_server_follower_wal_append_latency_milliseconds_count",
+		"oxia_server_follower_apply_latency_milliseconds_count",
+	} {
+		assert.Regexp(t, fmt.Sprintf(`(?m)^%s\{[^}]*%s\} `, series, regexp.QuoteMeta(labels)), string(body))
+	}
+
+	// The head offset is 1 and the commit offset is 0
+	assert.Regexp(t, fmt.Sprintf(`(?m)^oxia_server_follower_commit_lag\{[^}]*%s\} 1$`, regexp.QuoteMeta(labels)), string(body))
+	assert.Regexp(t, fmt.Sprintf(`(?m)^oxia_server_follower_status\{[^}]*%s\} %d$`, regexp.QuoteMeta(labels),
+		proto.ServingStatus_FOLLOWER), string(body))
+
+	assert.NoError(t, m.Close())
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// The status and the term are read without waiting for the I/O that is
+// done while holding the mutex, such as receiving a snapshot.
+func TestFollower_StatusNotBlockedBySnapshot(t *testing.T) {
+	var shardId int64 = 6
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	fc, _ := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+
+	// The snapshot transfer holds the mutex while waiting for the chunks
+	snapshotStream := newMockServerSendSnapshotStream()
+	go func() {
+		assert.Error(t, fc.SendSnapshot(snapshotStream))
+	}()
+	assert.Eventually(t, func() bool {
+		if fc.(*followerController).TryLock() {
+			fc.(*followerController).Unlock()
+			return false
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+
+	m, err := metrics.Start("localhost:0")
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+		assert.EqualValues(t, 1, fc.Term())
+
+		response, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", m.Port()))
+		assert.NoError(t, err)
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+		labels := fmt.Sprintf(`oxia_namespace="%s",shard="%d"`, common.DefaultNamespace, shardId)
+		assert.Regexp(t, fmt.Sprintf(`(?m)^oxia_server_follower_term\{[^}]*%s\} 1$`, regexp.QuoteMeta(labels)), string(body))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the status was blocked by the snapshot transfer")
+	}
+
+	snapshotStream.Fail(context.Canceled)
+	assert.NoError(t, m.Close())
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_HandleSnapshotWithWrongTerm(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
+		DataDir: t.TempDir(),
+	})
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 1, fc.Term())
+
+	stream := newMockServerReplicateStream()
+	go func() { assert.NoError(t, fc.Replicate(stream)) }()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "1"}, 0))
+
+	// Wait for acks
+	r1 := stream.GetResponse()
+	assert.Equal(t, proto.ServingStatus_FOLLOWER, fc.Status())
+	assert.EqualValues(t, 0, r1.Offset)
+	close(stream.requests)
+
+	// Wait for the replicate stream to be fully closed
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
+
+	// Load snapshot into follower
+	snapshot := prepareTestDb(t)
+
+	snapshotStream := newMockServerSendSnapshotStream()
+
+	wg := common.NewWaitGroup(1)
+
+	go func() {
+		err := fc.SendSnapshot(snapshotStream)
+		if err != nil {
+			wg.Fail(err)
+		} else {
+			wg.Done()
+		}
+	}()
+
+	for _, chunk := range snapshotChunks(t, snapshot, 2) {
+		snapshotStream.AddChunk(chunk)
+	}
+
+	close(snapshotStream.chunks)
+
+	// The snapshot sending should fail because the term is invalid
+	assert.ErrorIs(t, common.ErrorInvalidTerm, wg.Wait(context.Background()))
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assert.EqualValues(t, 5, fc.Term())
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func closeChanIsNotNil(fc FollowerController) func() bool {
+	return func() bool {
+		_fc := fc.(*followerController)
+		_fc.Lock()
+		defer _fc.Unlock()
+		return _fc.closeStreamWg != nil
+	}
+}
+
+// gatedKVFactory wraps the databases so that a write batch can be blocked
+// from committing.
+type gatedKVFactory struct {
+	kv.Factory
+	sync.Mutex
+
+	// Whether the next batch to commit must wait for the gate to be opened
+	armed   bool
+	gate    chan struct{}
+	blocked chan struct{}
+}
+
+func newGatedKVFactory(factory kv.Factory) *gatedKVFactory {
+	return &gatedKVFactory{Factory: factory}
+}
+
+// closeGate blocks the next batch that is committed, until the gate is
+// opened. The batches committed after it are not affected.
+func (f *gatedKVFactory) closeGate() {
+	f.Lock()
+	defer f.Unlock()
+	f.armed = true
+	f.gate = make(chan struct{})
+	f.blocked = make(chan struct{})
+}
+
+func (f *gatedKVFactory) openGate() {
+	f.Lock()
+	defer f.Unlock()
+	close(f.gate)
+}
+
+// waitForBlockedBatch returns once a batch is waiting on the closed gate.
+func (f *gatedKVFactory) waitForBlockedBatch() {
+	f.Lock()
+	blocked := f.blocked
+	f.Unlock()
+	<-blocked
+}
+
+func (f *gatedKVFactory) waitForGate() {
+	f.Lock()
+	if !f.armed {
+		f.Unlock()
+		return
+	}
+	f.armed = false
+	gate := f.gate
+	close(f.blocked)
+	f.Unlock()
+	<-gate
+}
+
+func (f *gatedKVFactory) NewKV(namespace string, shardId int64) (kv.KV, error) {
+	k, err := f.Factory.NewKV(namespace, shardId)
+	if err != nil {
+		return nil, err
+	}
+	return &gatedKV{KV: k, factory: f}, nil
+}
+
+type gatedKV struct {
+	kv.KV
+	factory *gatedKVFactory
+}
+
+func (k *gatedKV) NewWriteBatch() kv.WriteBatch {
+	return &gatedWriteBatch{WriteBatch: k.KV.NewWriteBatch(), factory: k.factory}
+}
+
+type gatedWriteBatch struct {
+	kv.WriteBatch
+	factory *gatedKVFactory
+}
+
+func (b *gatedWriteBatch) Commit() error {
+	b.factory.waitForGate()
+	return b.WriteBatch.Commit()
+}
+
+// slowAppendWalFactory wraps the wals so that the entries are appended with
+// a delay, or are blocked until the gate is opened.
+type slowAppendWalFactory struct {
+	wal.Factory
+	sync.Mutex
+
+	delay time.Duration
+	gate  chan struct{}
+}
+
+func newSlowAppendWalFactory(factory wal.Factory, delay time.Duration) *slowAppendWalFactory {
+	gate := make(chan struct{})
+	close(gate)
+	return &slowAppendWalFactory{Factory: factory, delay: delay, gate: gate}
+}
+
+func (f *slowAppendWalFactory) closeGate() {
+	f.Lock()
+	defer f.Unlock()
+	f.gate = make(chan struct{})
+}
+
+func (f *slowAppendWalFactory) openGate() {
+	f.Lock()
+	defer f.Unlock()
+	close(f.gate)
+}
+
+func (f *slowAppendWalFactory) NewWal(namespace string, shard int64, provider wal.CommitOffsetProvider) (wal.Wal, error) {
+	w, err := f.Factory.NewWal(namespace, shard, provider)
+	if err != nil {
+		return nil, err
+	}
+	return &slowAppendWal{Wal: w, factory: f}, nil
+}
+
+type slowAppendWal struct {
+	wal.Wal
+	factory *slowAppendWalFactory
+}
+
+func (w *slowAppendWal) AppendAsync(entry *proto.LogEntry) error {
+	w.factory.Lock()
+	gate := w.factory.gate
+	w.factory.Unlock()
+
+	<-gate
+	time.Sleep(w.factory.delay)
+	return w.Wal.AppendAsync(entry)
+}
+
+func createAddRequest(t *testing.T, term int64, offset int64,
+	kvs map[string]string,
+	commitOffset int64) *proto.Append {
+	t.Helper()
+
+	br := &proto.WriteRequest{}
+
+	for k, v := range kvs {
+		br.Puts = append(br.Puts, &proto.PutRequest{
+			Key:   k,
+			Value: []byte(v),
+		})
+	}
+
+	entry, err := pb.Marshal(wrapInLogEntryValue(br))
+	assert.NoError(t, err)
 
 	le := &proto.LogEntry{
 		Term:   term,
@@ -1024,9 +2677,894 @@ func createAddRequest(t *testing.T, term int64, offset int64,
 		Value:  entry,
 	}
 
-	return &proto.Append{
-		Term:         term,
-		Entry:        le,
-		CommitOffset: commitOffset,
+	return &proto.Append{
+		Term:         term,
+		Entry:        le,
+		CommitOffset: commitOffset,
+	}
+}
+
+func TestFollower_CorruptedWal(t *testing.T) {
+	var shardId int64 = 1
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+
+	walDir := t.TempDir()
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{
+		BaseWalDir:  walDir,
+		SegmentSize: 128 * 1024,
+	})
+
+	w, err := walFactory.NewWal(common.DefaultNamespace, shardId, nil)
+	assert.NoError(t, err)
+	for i := int64(0); i < 10; i++ {
+		assert.NoError(t, w.Append(&proto.LogEntry{
+			Term:   1,
+			Offset: i,
+			Value:  []byte(fmt.Sprintf("entry-%d", i)),
+		}))
+	}
+	assert.NoError(t, w.Close())
+
+	// Flip a byte in the middle of the log
+	segmentPath := filepath.Join(walDir, common.DefaultNamespace, fmt.Sprintf("shard-%d", shardId), "0.txn")
+	data, err := os.ReadFile(segmentPath)
+	assert.NoError(t, err)
+	pos := bytes.Index(data, []byte("entry-3"))
+	assert.Greater(t, pos, 0)
+	data[pos] ^= 0xff
+	assert.NoError(t, os.WriteFile(segmentPath, data, 0644))
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.Equal(t, common.CodeWalCorrupted, status.Code(err))
+	assert.Nil(t, fc)
+
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func createUnappliableAddRequest(term int64, offset int64, commitOffset int64) *proto.Append {
+	return &proto.Append{
+		Term: term,
+		Entry: &proto.LogEntry{
+			Term:   term,
+			Offset: offset,
+			// Not a valid LogEntryValue
+			Value: []byte{0xff, 0xff, 0xff, 0xff},
+		},
+		CommitOffset: commitOffset,
+	}
+}
+
+func TestFollower_UnappliableEntry(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	stream.AddRequest(createUnappliableAddRequest(1, 1, 0))
+	stream.AddRequest(createAddRequest(t, 1, 2, map[string]string{"a": "2"}, 1))
+	stream.AddRequest(createAddRequest(t, 1, 3, map[string]string{"a": "3"}, 2))
+
+	// The stream keeps replicating the entries after the unappliable one
+	for i := int64(0); i < 4; i++ {
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+
+	expected := &proto.UnappliableEntry{EntryId: &proto.EntryId{Term: 1, Offset: 1}}
+	assert.Eventually(t, func() bool {
+		res, _ := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+		return res.UnappliableEntry != nil
+	}, 10*time.Second, 100*time.Millisecond)
+
+	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, res.HeadOffset)
+	assert.EqualValues(t, 0, res.CommitOffset)
+	assert.True(t, pb.Equal(expected.EntryId, res.UnappliableEntry.EntryId))
+	assert.NotEmpty(t, res.UnappliableEntry.Error)
+
+	// The entry is recorded in the database
+	recorded, err := fc.(*followerController).db.UnappliableEntry()
+	assert.NoError(t, err)
+	assert.True(t, pb.Equal(res.UnappliableEntry, recorded))
+
+	// The reads beyond the entry fail instead of waiting
+	minOffset := int64(2)
+	ch := fc.Read(context.Background(), &proto.ReadRequest{
+		Shard:       &shardId,
+		Gets:        []*proto.GetRequest{{Key: "a", IncludeValue: true}},
+		Consistency: proto.ReadConsistency_SESSION,
+		MinOffset:   &minOffset,
+	})
+	r := <-ch
+	assert.Equal(t, common.CodeEntryUnappliable, status.Code(r.Err))
+
+	assert.NoError(t, fc.Close())
+
+	// The entry is still reported after a restart
+	fc, err = NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	res, err = fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.True(t, pb.Equal(recorded, res.UnappliableEntry))
+	assert.NoError(t, fc.Close())
+
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_SkipUnappliableEntries(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{SkipUnappliableEntries: true}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	stream.AddRequest(createUnappliableAddRequest(1, 1, 0))
+	stream.AddRequest(createAddRequest(t, 1, 2, map[string]string{"a": "2"}, 1))
+	stream.AddRequest(createAddRequest(t, 1, 3, map[string]string{"a": "3"}, 2))
+
+	for i := int64(0); i < 4; i++ {
+		assert.EqualValues(t, i, stream.GetResponse().Offset)
+	}
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 2
+	}, 10*time.Second, 100*time.Millisecond)
+
+	res, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shardId})
+	assert.NoError(t, err)
+	assert.Nil(t, res.UnappliableEntry)
+
+	recorded, err := fc.(*followerController).db.UnappliableEntry()
+	assert.NoError(t, err)
+	assert.Nil(t, recorded)
+
+	gr, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: "a", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), gr.Value)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_ReadWithMinOffset(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	_, _ = fc.Truncate(&proto.TruncateRequest{
+		Term: 1,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	r := stream.GetResponse()
+	assert.EqualValues(t, 0, r.Offset)
+
+	// The entry is not committed yet, so the read must wait for it
+	minOffset := int64(0)
+	ch := fc.Read(context.Background(), &proto.ReadRequest{
+		Shard:       &shardId,
+		Gets:        []*proto.GetRequest{{Key: "a", IncludeValue: true}},
+		Consistency: proto.ReadConsistency_SESSION,
+		MinOffset:   &minOffset,
+	})
+
+	select {
+	case res := <-ch:
+		assert.Fail(t, "read should be waiting for the barrier", res)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"a": "1"}, 0))
+	r = stream.GetResponse()
+	assert.EqualValues(t, 1, r.Offset)
+
+	res := <-ch
+	assert.NoError(t, res.Err)
+	assert.Equal(t, proto.Status_OK, res.Response.Status)
+	assert.Equal(t, []byte("0"), res.Response.Value)
+
+	_, more := <-ch
+	assert.False(t, more)
+
+	// Without a min offset, the read is served with the state of the follower
+	ch = fc.Read(context.Background(), &proto.ReadRequest{
+		Shard:       &shardId,
+		Gets:        []*proto.GetRequest{{Key: "b", IncludeValue: true}},
+		Consistency: proto.ReadConsistency_BOUNDED_STALENESS,
+	})
+	res = <-ch
+	assert.NoError(t, res.Err)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, res.Response.Status)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_ReadWithoutValue(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	_, _ = fc.Truncate(&proto.TruncateRequest{
+		Term: 1,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0123456789"}, wal.InvalidOffset))
+	stream.GetResponse()
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"b": "0"}, 0))
+	stream.GetResponse()
+
+	minOffset := int64(0)
+	ch := fc.Read(context.Background(), &proto.ReadRequest{
+		Shard: &shardId,
+		Gets: []*proto.GetRequest{
+			{Key: "a"},
+			{Key: "a", IncludeValue: true, MaxValueSize: pb.Uint64(9)},
+			{Key: "a", IncludeValue: true, MaxValueSize: pb.Uint64(10)},
+		},
+		Consistency: proto.ReadConsistency_SESSION,
+		MinOffset:   &minOffset,
+	})
+
+	res := <-ch
+	assert.NoError(t, res.Err)
+	assert.Equal(t, proto.Status_OK, res.Response.Status)
+	assert.Nil(t, res.Response.Value)
+	assert.EqualValues(t, 10, res.Response.GetValueSize())
+	assert.NotNil(t, res.Response.Version)
+
+	res = <-ch
+	assert.NoError(t, res.Err)
+	assert.Equal(t, proto.Status_VALUE_TOO_LARGE, res.Response.Status)
+	assert.Nil(t, res.Response.Value)
+	assert.EqualValues(t, 10, res.Response.GetValueSize())
+	assert.NotNil(t, res.Response.Version)
+
+	res = <-ch
+	assert.NoError(t, res.Err)
+	assert.Equal(t, proto.Status_OK, res.Response.Status)
+	assert.Equal(t, []byte("0123456789"), res.Response.Value)
+
+	_, more := <-ch
+	assert.False(t, more)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_TimestampsConvergence(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shardId, newMockRpcClient(), walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shardId, Term: 1})
+	assert.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shardId,
+		Term:              1,
+		ReplicationFactor: 1,
+	})
+	assert.NoError(t, err)
+
+	for _, value := range []string{"0", "1"} {
+		_, err = lc.Write(context.Background(), &proto.WriteRequest{
+			Shard: &shardId,
+			Puts:  []*proto.PutRequest{{Key: "a", Value: []byte(value)}},
+		})
+		assert.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var entries []*proto.LogEntry
+	reader, err := lc.(*leaderController).wal.NewReader(wal.InvalidOffset)
+	assert.NoError(t, err)
+	for reader.HasNext() {
+		entry, err := reader.ReadNext()
+		assert.NoError(t, err)
+		entries = append(entries, entry)
+	}
+	assert.NoError(t, reader.Close())
+	assert.Len(t, entries, 2)
+
+	leaderRes, err := lc.(*leaderController).db.Get(&proto.GetRequest{Key: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, entries[0].Timestamp, leaderRes.Version.CreatedTimestamp)
+	assert.Equal(t, entries[1].Timestamp, leaderRes.Version.ModifiedTimestamp)
+	assert.NotEqual(t, leaderRes.Version.CreatedTimestamp, leaderRes.Version.ModifiedTimestamp)
+
+	// The follower applies the entries later, with the timestamps of the leader
+	time.Sleep(5 * time.Millisecond)
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId+1, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	_, _ = fc.Truncate(&proto.TruncateRequest{
+		Term: 1,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	for _, entry := range entries {
+		stream.AddRequest(&proto.Append{Term: 1, Entry: entry, CommitOffset: entry.Offset})
+		stream.GetResponse()
+	}
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	followerRes, err := fc.(*followerController).db.Get(&proto.GetRequest{Key: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, leaderRes.Version, followerRes.Version)
+
+	// The notifications carry the same timestamps
+	readNotifications := func(db kv.DB) []*proto.NotificationBatch {
+		batches, err := db.ReadNextNotifications(context.Background(), 0)
+		assert.NoError(t, err)
+		return batches
+	}
+	leaderNotifications := readNotifications(lc.(*leaderController).db)
+	assert.Len(t, leaderNotifications, 2)
+	assert.Equal(t, entries[1].Timestamp, leaderNotifications[1].Timestamp)
+	assert.Equal(t, entries[0].Timestamp, leaderNotifications[1].Notifications["a"].GetCreatedTimestamp())
+	for i, nb := range readNotifications(fc.(*followerController).db) {
+		assert.Equal(t, leaderNotifications[i].Timestamp, nb.Timestamp)
+		assert.Equal(t, leaderNotifications[i].Notifications, nb.Notifications)
+	}
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_DeleteRangeConvergence(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shardId, newMockRpcClient(), walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shardId, Term: 1})
+	assert.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shardId,
+		Term:              1,
+		ReplicationFactor: 1,
+	})
+	assert.NoError(t, err)
+
+	for _, wr := range []*proto.WriteRequest{{
+		Puts: []*proto.PutRequest{
+			{Key: "/a/0", Value: []byte("0")},
+			{Key: "/a/1", Value: []byte("1")},
+			{Key: "/b/0", Value: []byte("0")},
+		},
+	}, {
+		Puts:         []*proto.PutRequest{{Key: "/b/1", Value: []byte("1")}},
+		DeleteRanges: []*proto.DeleteRangeRequest{{StartInclusive: "/a/", EndExclusive: "/a//"}},
+	}, {
+		Puts:         []*proto.PutRequest{{Key: "/a/2", Value: []byte("2")}},
+		Deletes:      []*proto.DeleteRequest{{Key: "/b/1"}},
+		DeleteRanges: []*proto.DeleteRangeRequest{{StartInclusive: "/b/0", EndExclusive: "/b/1"}},
+	}} {
+		wr.Shard = &shardId
+		_, err = lc.Write(context.Background(), wr)
+		assert.NoError(t, err)
+	}
+
+	listAll := func(db kv.DB) []string {
+		it, err := db.List(&proto.ListRequest{StartInclusive: "/", EndExclusive: "/b//"})
+		assert.NoError(t, err)
+		var keys []string
+		for ; it.Valid(); it.Next() {
+			keys = append(keys, it.Key())
+		}
+		assert.NoError(t, it.Close())
+		return keys
+	}
+
+	leaderKeys := listAll(lc.(*leaderController).db)
+	assert.Equal(t, []string{"/a/2"}, leaderKeys)
+
+	// Replay the leader log on a follower of another shard
+	var entries []*proto.LogEntry
+	reader, err := lc.(*leaderController).wal.NewReader(wal.InvalidOffset)
+	assert.NoError(t, err)
+	for reader.HasNext() {
+		entry, err := reader.ReadNext()
+		assert.NoError(t, err)
+		entries = append(entries, entry)
+	}
+	assert.NoError(t, reader.Close())
+	assert.Len(t, entries, 3)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId+1, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	_, _ = fc.Truncate(&proto.TruncateRequest{
+		Term: 1,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	for _, entry := range entries {
+		stream.AddRequest(&proto.Append{Term: 1, Entry: entry, CommitOffset: entry.Offset})
+		stream.GetResponse()
+	}
+
+	assert.Eventually(t, func() bool {
+		return fc.CommitOffset() == 2
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, leaderKeys, listAll(fc.(*followerController).db))
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_ListAndRangeScan(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	_, _ = fc.Truncate(&proto.TruncateRequest{
+		Term: 1,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0", "b": "0", "c": "0"}, wal.InvalidOffset))
+	stream.GetResponse()
+
+	// The scan waits for the entry to be applied
+	minOffset := int64(0)
+	listCh := make(chan []string)
+	go func() {
+		ch, err := fc.List(context.Background(), &proto.ListRequest{
+			Shard:          &shardId,
+			StartInclusive: "a",
+			EndExclusive:   "z",
+			Limit:          pb.Uint64(2),
+			Consistency:    proto.ReadConsistency_SESSION,
+			MinOffset:      &minOffset,
+		})
+		assert.NoError(t, err)
+
+		var keys []string
+		for key := range ch {
+			keys = append(keys, key)
+		}
+		listCh <- keys
+	}()
+
+	select {
+	case keys := <-listCh:
+		assert.Fail(t, "list should be waiting for the barrier", keys)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"d": "1"}, 0))
+	stream.GetResponse()
+
+	assert.Equal(t, []string{"a", "b"}, <-listCh)
+
+	ch, errCh, err := fc.RangeScan(context.Background(), &proto.RangeScanRequest{
+		Shard:          &shardId,
+		StartInclusive: "b",
+		EndExclusive:   "z",
+		Consistency:    proto.ReadConsistency_BOUNDED_STALENESS,
+	})
+	assert.NoError(t, err)
+
+	var keys []string
+	for gr := range ch {
+		keys = append(keys, gr.GetKey())
+	}
+	assert.NoError(t, <-errCh)
+	assert.Equal(t, []string{"b", "c"}, keys)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_Notifications(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	notificationsStream := newMockGetNotificationsServer(context.Background())
+
+	// A fenced follower cannot serve notifications
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.ErrorIs(t, fc.GetNotifications(&proto.NotificationsRequest{Shard: shardId}, notificationsStream),
+		common.ErrorInvalidStatus)
+
+	_, _ = fc.Truncate(&proto.TruncateRequest{
+		Term: 1,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+
+	stream := newMockServerReplicateStream()
+	go func() {
+		// cancelled due to fc.Close() below
+		assert.ErrorIs(t, fc.Replicate(stream), context.Canceled)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"/a/0": "0"}, wal.InvalidOffset))
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"/b/0": "0"}, 0))
+	stream.AddRequest(createAddRequest(t, 1, 2, map[string]string{"/a/1": "1"}, 1))
+	for i := 0; i < 3; i++ {
+		stream.GetResponse()
+	}
+
+	closeCh := make(chan any)
+	go func() {
+		// cancelled due to fc.Close() below
+		err := fc.GetNotifications(&proto.NotificationsRequest{
+			Shard:                shardId,
+			StartOffsetExclusive: &wal.InvalidOffset,
+			KeyPrefix:            "/a/",
+		}, notificationsStream)
+		assert.ErrorIs(t, err, context.Canceled)
+		close(closeCh)
+	}()
+
+	// Only the applied entries are notified
+	nb := <-notificationsStream.ch
+	assert.EqualValues(t, 0, nb.Offset)
+	assert.Contains(t, nb.Notifications, "/a/0")
+
+	select {
+	case nb = <-notificationsStream.ch:
+		assert.Fail(t, "Unexpected notification", nb)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The notification is sent once the entry gets committed
+	stream.AddRequest(createAddRequest(t, 1, 3, map[string]string{"/b/1": "1"}, 3))
+	stream.GetResponse()
+
+	nb = <-notificationsStream.ch
+	assert.EqualValues(t, 2, nb.Offset)
+	assert.Equal(t, 1, len(nb.Notifications))
+	assert.Contains(t, nb.Notifications, "/a/1")
+
+	assert.NoError(t, fc.Close())
+	<-closeCh
+
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_ReadBarrierTimeout(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	fc.(*followerController).readBarrierTimeout = 100 * time.Millisecond
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	_, _ = fc.Truncate(&proto.TruncateRequest{
+		Term: 1,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+
+	minOffset := int64(5)
+	ch := fc.Read(context.Background(), &proto.ReadRequest{
+		Shard:       &shardId,
+		Gets:        []*proto.GetRequest{{Key: "a", IncludeValue: true}},
+		Consistency: proto.ReadConsistency_SESSION,
+		MinOffset:   &minOffset,
+	})
+
+	res := <-ch
+	assert.Nil(t, res.Response)
+	assert.Equal(t, common.CodeReadBarrierTimeout, status.Code(res.Err))
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestFollower_FencedRejectsReads(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, _ = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+
+	ch := fc.Read(context.Background(), &proto.ReadRequest{
+		Shard:       &shardId,
+		Gets:        []*proto.GetRequest{{Key: "a", IncludeValue: true}},
+		Consistency: proto.ReadConsistency_BOUNDED_STALENESS,
+	})
+
+	res := <-ch
+	assert.Nil(t, res.Response)
+	assert.Equal(t, common.CodeInvalidStatus, status.Code(res.Err))
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// The gRPC layer can hold on to the messages passed to Send, so the acks
+// must not be modified after they are sent.
+func TestFollower_AcksAreNotReused(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	stream := newMockServerReplicateStream()
+	go func() { assert.NoError(t, fc.Replicate(stream)) }()
+
+	var acks []*proto.Ack
+	for i := int64(0); i < 10; i++ {
+		stream.AddRequest(createAddRequest(t, 1, i, map[string]string{"a": "0"}, i-1))
+		acks = append(acks, stream.GetResponse())
+	}
+
+	for i, ack := range acks {
+		assert.EqualValues(t, i, ack.Offset)
+	}
+
+	close(stream.requests)
+	assert.Eventually(t, func() bool { return !closeChanIsNotNil(fc)() }, 10*time.Second, 10*time.Millisecond)
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// Benchmarks run with the default log level, so that the cost of the debug
+// logs in the hot path is the same as in production.
+func setBenchmarkLogLevel(b *testing.B) {
+	b.Helper()
+
+	common.LogLevel = common.DefaultLogLevel
+	common.ConfigureLogger()
+	b.Cleanup(func() {
+		common.LogLevel = slog.LevelDebug
+		common.ConfigureLogger()
+	})
+}
+
+func newBenchmarkAppends(b *testing.B, term int64) []*proto.Append {
+	b.Helper()
+
+	value, err := wrapInLogEntryValue(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "my-key", Value: make([]byte, 100)}},
+	}).MarshalVT()
+	assert.NoError(b, err)
+
+	appends := make([]*proto.Append, b.N)
+	for i := range appends {
+		appends[i] = &proto.Append{
+			Term:         term,
+			Entry:        &proto.LogEntry{Term: term, Offset: int64(i), Value: value},
+			CommitOffset: int64(i) - 1,
+		}
+	}
+	return appends
+}
+
+// Appends entries to the follower wal, without the syncing and the applying.
+func BenchmarkFollowerAddEntry(b *testing.B) {
+	setBenchmarkLogLevel(b)
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(b, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: b.TempDir()})
+
+	fc, err := NewFollowerController(Config{}, common.DefaultNamespace, 0, walFactory, kvFactory)
+	assert.NoError(b, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(b, err)
+
+	appends := newBenchmarkAppends(b, 1)
+	stream := newMockServerReplicateStream()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := fc.(*followerController).append(appends[i], stream); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	assert.NoError(b, fc.Close())
+	assert.NoError(b, kvFactory.Close())
+	assert.NoError(b, walFactory.Close())
+}
+
+// Replicates entries to the follower, with the leader waiting for the ack of
+// each entry before sending the next one, or pipelining them up to the
+// replication window.
+func BenchmarkFollowerReplicate(b *testing.B) {
+	setBenchmarkLogLevel(b)
+
+	for _, test := range []struct {
+		name   string
+		window int64
+	}{
+		{"lock-step", 1},
+		{"pipelined", replicationWindow},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+			assert.NoError(b, err)
+			walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: b.TempDir()})
+
+			fc, err := NewFollowerController(Config{}, common.DefaultNamespace, 0, walFactory, kvFactory)
+			assert.NoError(b, err)
+			_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+			assert.NoError(b, err)
+
+			appends := newBenchmarkAppends(b, 1)
+			stream := newMockServerReplicateStream()
+			go func() {
+				// cancelled due to fc.Close() below
+				_ = fc.Replicate(stream)
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			replicateWithinWindow(b, stream, appends, test.window)
+			b.StopTimer()
+
+			assert.NoError(b, fc.Close())
+			assert.NoError(b, kvFactory.Close())
+			assert.NoError(b, walFactory.Close())
+		})
+	}
+}
+
+// Applies the committed entries of the wal to the database.
+func BenchmarkCommitReplay(b *testing.B) {
+	setBenchmarkLogLevel(b)
+
+	for _, compressionThreshold := range []int{0, 1} {
+		b.Run(fmt.Sprintf("compression-threshold-%d", compressionThreshold), func(b *testing.B) {
+			kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+			assert.NoError(b, err)
+			walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: b.TempDir()})
+
+			fc, err := NewFollowerController(Config{}, common.DefaultNamespace, 0, walFactory, kvFactory)
+			assert.NoError(b, err)
+			_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+			assert.NoError(b, err)
+
+			for _, req := range newBenchmarkAppends(b, 1) {
+				compressLogEntryValue(req.Entry, req.Entry.Value, compressionThreshold)
+				assert.NoError(b, fc.(*followerController).wal.Append(req.Entry))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			assert.NoError(b, fc.(*followerController).processCommittedEntries(int64(b.N-1), fc.(*followerController).dataGeneration.Load(), fc.(*followerController).log))
+			b.StopTimer()
+
+			assert.EqualValues(b, b.N-1, fc.CommitOffset())
+
+			assert.NoError(b, fc.Close())
+			assert.NoError(b, kvFactory.Close())
+			assert.NoError(b, walFactory.Close())
+		})
 	}
 }
@@ -26,6 +26,7 @@ import (
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/dustin/go-humanize"
+	"go.uber.org/multierr"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -58,6 +59,10 @@ type FollowerCursor interface {
 
 	// AckOffset The highest entry already acknowledged by this follower
 	AckOffset() int64
+
+	// Remove closes the cursor and stops counting the acks of this follower
+	// in the quorum of the shard
+	Remove() error
 }
 
 type followerCursor struct {
@@ -78,10 +83,15 @@ type followerCursor struct {
 	shardId     int64
 
 	backoff backoff.BackOff
-	closed  atomic.Bool
-	ctx     context.Context
-	cancel  context.CancelFunc
-	log     *slog.Logger
+	ackCond common.ConditionContext
+	// Max number of entries that can be sent to the follower without being
+	// acknowledged, as advertised in its acks. This bounds the amount of data
+	// buffered for a follower that is falling behind
+	window atomic.Int64
+	closed atomic.Bool
+	ctx    context.Context
+	cancel context.CancelFunc
+	log    *slog.Logger
 
 	snapshotsTransferTime     metrics.LatencyHistogram
 	snapshotsStartedCounter   metrics.Counter
@@ -100,11 +110,26 @@ func NewFollowerCursor( //nolint:revive
 	walObject wal.Wal,
 	db kv.DB,
 	ackOffset int64) (FollowerCursor, error) {
-	labels := map[string]any{
-		"namespace": namespace,
-		"shard":     shardId,
-		"follower":  follower,
-	}
+	return newFollowerCursor(follower, term, namespace, shardId, replicateStreamProvider, ackTracker, walObject, db,
+		ackOffset, false)
+}
+
+// newFollowerCursor creates a cursor for the follower. If `learner` is set, the
+// follower is a new member of the ensemble and its acks are counted only after
+// it has caught up.
+func newFollowerCursor( //nolint:revive
+	follower string,
+	term int64,
+	namespace string,
+	shardId int64,
+	replicateStreamProvider ReplicateStreamProvider,
+	ackTracker QuorumAckTracker,
+	walObject wal.Wal,
+	db kv.DB,
+	ackOffset int64,
+	learner bool) (FollowerCursor, error) {
+	labels := metrics.LabelsForShard(namespace, shardId)
+	labels["follower"] = follower
 
 	fc := &followerCursor{
 		term:                    term,
@@ -138,12 +163,19 @@ func NewFollowerCursor( //nolint:revive
 
 	fc.ctx, fc.cancel = context.WithCancel(context.Background())
 	fc.backoff = common.NewBackOff(fc.ctx)
+	fc.ackCond = common.NewConditionContext(fc)
+	fc.window.Store(replicationWindow)
 
 	fc.lastPushed.Store(ackOffset)
 	fc.ackOffset.Store(ackOffset)
 
 	var err error
-	if fc.cursorAcker, err = ackTracker.NewCursorAcker(ackOffset); err != nil {
+	if learner {
+		fc.cursorAcker, err = ackTracker.NewLearnerCursorAcker(ackOffset)
+	} else {
+		fc.cursorAcker, err = ackTracker.NewCursorAcker(ackOffset)
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -176,6 +208,13 @@ func (fc *followerCursor) shouldSendSnapshot() bool {
 			slog.Int64("leader-commit-offset", fc.ackTracker.CommitOffset()),
 		)
 		return true
+	} else if walFirstOffset == wal.InvalidOffset && ackOffset < fc.ackTracker.CommitOffset() {
+		fc.log.Info(
+			"The leader WAL is empty and the follower is behind the leader database",
+			slog.Int64("follower-ack-offset", ackOffset),
+			slog.Int64("leader-commit-offset", fc.ackTracker.CommitOffset()),
+		)
+		return true
 	} else if walFirstOffset > 0 && ackOffset < walFirstOffset {
 		fc.log.Info(
 			"The follower is behind the first available entry in the leader WAL",
@@ -204,6 +243,11 @@ func (fc *followerCursor) Close() error {
 	return nil
 }
 
+func (fc *followerCursor) Remove() error {
+	err := fc.Close()
+	return multierr.Append(err, fc.ackTracker.RemoveCursorAcker(fc.cursorAcker))
+}
+
 func (fc *followerCursor) ShardId() int64 {
 	return fc.shardId
 }
@@ -256,6 +300,12 @@ func (fc *followerCursor) sendSnapshot() error {
 		return err
 	}
 
+	// The snapshot includes at least the entries committed before it's taken
+	entryId, err := fc.db.ReadCommitEntryId()
+	if err != nil {
+		return err
+	}
+
 	snapshot, err := fc.db.Snapshot()
 	if err != nil {
 		return err
@@ -265,32 +315,30 @@ func (fc *followerCursor) sendSnapshot() error {
 
 	var chunksCount, totalSize int64
 	startTime := time.Now()
+	encoder := newSnapshotEncoder(fc.term, entryId)
 
-	for ; snapshot.Valid(); snapshot.Next() {
+	for snapshot.Valid() {
 		chunk, err := snapshot.Chunk()
 		if err != nil {
 			return err
 		}
-		content := chunk.Content()
+
+		// The chunk was already read, so the snapshot can be moved ahead
+		// to know whether it's the last one
+		req := encoder.encode(chunk, !snapshot.Next())
 
 		fc.log.Debug(
 			"Sending snapshot chunk",
-			slog.String("chunk-name", chunk.Name()),
-			slog.Int("chunk-size", len(content)),
+			slog.String("chunk-name", req.Name),
+			slog.Int("chunk-size", len(req.Content)),
 		)
 
-		if err := stream.Send(&proto.SnapshotChunk{
-			Term:       fc.term,
-			Name:       chunk.Name(),
-			ChunkIndex: chunk.Index(),
-			ChunkCount: chunk.TotalCount(),
-			Content:    content,
-		}); err != nil {
+		if err := stream.Send(req); err != nil {
 			return err
 		}
 
 		chunksCount++
-		size := len(content)
+		size := len(req.Content)
 		totalSize += int64(size)
 		fc.snapshotsBytesSent.Add(size)
 	}
@@ -320,15 +368,37 @@ func (fc *followerCursor) sendSnapshot() error {
 }
 
 func (fc *followerCursor) streamEntriesLoop(ctx context.Context, reader wal.Reader, currentOffset int64) error {
+	// The commit offset last sent to the follower, with an entry or without
+	sentCommitOffset := wal.InvalidOffset
+
 	for {
 		if fc.closed.Load() {
 			return nil
 		}
 
 		if !reader.HasNext() {
-			// We have reached the head of the wal
-			// Wait for more entries to be written
-			if err := fc.ackTracker.WaitForHeadOffset(ctx, currentOffset+1); err != nil {
+			// We have reached the head of the wal. If the commit offset has
+			// moved since the last entry, the follower can only learn about
+			// it from an append without any entry
+			if commitOffset := fc.ackTracker.CommitOffset(); commitOffset > sentCommitOffset {
+				fc.log.Debug(
+					"Sending commit offset to follower",
+					slog.Int64("commit-offset", commitOffset),
+				)
+
+				if err := fc.stream.Send(&proto.Append{
+					Term:         fc.term,
+					CommitOffset: commitOffset,
+				}); err != nil {
+					return err
+				}
+
+				sentCommitOffset = commitOffset
+				continue
+			}
+
+			// Wait for more entries to be written, or to be committed
+			if err := fc.ackTracker.WaitForHeadOrCommitOffset(ctx, currentOffset+1, sentCommitOffset+1); err != nil {
 				return err
 			}
 
@@ -340,19 +410,30 @@ func (fc *followerCursor) streamEntriesLoop(ctx context.Context, reader wal.Read
 			return err
 		}
 
-		fc.log.Debug(
-			"Sending entries to follower",
-			slog.Int64("offset", le.Offset),
-		)
+		if err = fc.waitForInFlightWindow(ctx, le.Offset); err != nil {
+			return err
+		}
+
+		if fc.log.Enabled(ctx, slog.LevelDebug) {
+			fc.log.Debug(
+				"Sending entries to follower",
+				slog.Int64("offset", le.Offset),
+			)
+		}
 
+		// The append is a new message for each entry, since gRPC can retain
+		// a message after Send has returned
+		commitOffset := fc.ackTracker.CommitOffset()
 		if err = fc.stream.Send(&proto.Append{
 			Term:         fc.term,
 			Entry:        le,
-			CommitOffset: fc.ackTracker.CommitOffset(),
+			CommitOffset: commitOffset,
 		}); err != nil {
 			return err
 		}
 
+		sentCommitOffset = commitOffset
+
 		fc.lastPushed.Store(le.Offset)
 		currentOffset = le.Offset
 
@@ -361,6 +442,21 @@ func (fc *followerCursor) streamEntriesLoop(ctx context.Context, reader wal.Read
 	}
 }
 
+// Wait until the follower has acknowledged enough entries for us
+// to be able to send the entry at the given offset.
+func (fc *followerCursor) waitForInFlightWindow(ctx context.Context, offset int64) error {
+	fc.Lock()
+	defer fc.Unlock()
+
+	for offset-fc.ackOffset.Load() > fc.window.Load() {
+		if err := fc.ackCond.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (fc *followerCursor) streamEntries() error {
 	ctx, cancel := context.WithCancel(fc.ctx)
 	defer cancel()
@@ -430,5 +526,10 @@ func (fc *followerCursor) receiveAcks(cancel context.CancelFunc, stream proto.Ox
 		fc.cursorAcker.Ack(res.Offset)
 
 		fc.ackOffset.Store(res.Offset)
+		// The followers that don't advertise a window accept the default one
+		if res.Window > 0 {
+			fc.window.Store(res.Window)
+		}
+		fc.ackCond.Signal()
 	}
 }
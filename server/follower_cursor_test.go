@@ -90,6 +90,12 @@ func TestFollowerCursor(t *testing.T) {
 
 	assert.EqualValues(t, 0, ackTracker.CommitOffset())
 
+	// Without new entries, the commit offset is sent on its own
+	req = <-stream.appendReqs
+	assert.EqualValues(t, 1, req.Term)
+	assert.Nil(t, req.Entry)
+	assert.EqualValues(t, 0, req.CommitOffset)
+
 	// Next entry should carry the correct commit offset
 	err = w.Append(&proto.LogEntry{
 		Term:   1,
@@ -149,7 +155,7 @@ func TestFollowerCursor_SendSnapshot(t *testing.T) {
 			Timestamp: uint64(i),
 		}))
 
-		_, err := db.ProcessWrite(wr, i, uint64(i), kv.NoOpCallback)
+		_, err := db.ProcessWrite(wr, 0, i, uint64(i), kv.NoOpCallback)
 		assert.NoError(t, err)
 	}
 
@@ -159,9 +165,13 @@ func TestFollowerCursor_SendSnapshot(t *testing.T) {
 	assert.NoError(t, err)
 
 	s := stream.sendSnapshotStream
+	verifier := newSnapshotVerifier(wal.InvalidOffset, slog.Default())
 	for req := range s.requests {
 		assert.EqualValues(t, 1, req.Term)
+		assert.NoError(t, verifier.verify(req))
 	}
+	assert.NoError(t, verifier.verifyComplete())
+	AssertProtoEqual(t, &proto.EntryId{Term: 0, Offset: n - 1}, verifier.entryId)
 
 	slog.Info("Snapshot complete")
 
@@ -174,6 +184,74 @@ func TestFollowerCursor_SendSnapshot(t *testing.T) {
 	assert.NoError(t, fc.Close())
 }
 
+func TestFollowerCursor_InFlightWindow(t *testing.T) {
+	var term int64 = 1
+	var shard int64 = 2
+
+	n := int64(replicationWindow + 500)
+	stream := newMockRpcClient()
+	ackTracker := NewQuorumAckTracker(3, wal.InvalidOffset, wal.InvalidOffset)
+	kvf, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := kv.NewDB(common.DefaultNamespace, shard, kvf, 1*time.Hour, common.SystemClock)
+	assert.NoError(t, err)
+	wf := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
+	w, err := wf.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+
+	for i := int64(0); i < n; i++ {
+		assert.NoError(t, w.Append(&proto.LogEntry{
+			Term:   1,
+			Offset: i,
+			Value:  []byte(fmt.Sprintf("v%d", i)),
+		}))
+	}
+	ackTracker.AdvanceHeadOffset(n - 1)
+
+	fc, err := NewFollowerCursor("f1", term, common.DefaultNamespace, shard, stream, ackTracker, w, db, wal.InvalidOffset)
+	assert.NoError(t, err)
+
+	// The cursor must stop once the window of unacked entries is full
+	for i := int64(0); i < replicationWindow; i++ {
+		req := <-stream.appendReqs
+		assert.EqualValues(t, i, req.Entry.Offset)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, replicationWindow-1, fc.LastPushed())
+	assert.Empty(t, stream.appendReqs)
+
+	// Acking entries opens the window again, with the size advertised by
+	// the follower
+	stream.ackResps <- &proto.Ack{Offset: replicationWindow - 1, Window: 100}
+
+	for i := int64(replicationWindow); i < replicationWindow+100; i++ {
+		req := <-stream.appendReqs
+		assert.EqualValues(t, i, req.Entry.Offset)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, replicationWindow+99, fc.LastPushed())
+	assert.Empty(t, stream.appendReqs)
+
+	stream.ackResps <- &proto.Ack{Offset: replicationWindow + 99, Window: replicationWindow}
+
+	for i := int64(replicationWindow + 100); i < n; i++ {
+		req := <-stream.appendReqs
+		assert.EqualValues(t, i, req.Entry.Offset)
+	}
+
+	assert.Eventually(t, func() bool {
+		return fc.LastPushed() == n-1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, w.Close())
+	assert.NoError(t, db.Close())
+	assert.NoError(t, kvf.Close())
+	assert.NoError(t, wf.Close())
+}
+
 func wrapInLogEntryValue(wr *proto.WriteRequest) *proto.LogEntryValue {
 	return &proto.LogEntryValue{
 		Value: &proto.LogEntryValue_Requests{
@@ -0,0 +1,41 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/streamnative/oxia/proto"
+)
+
+// ShardHealthService is the name of the health checking service that reports
+// whether a shard can be served by this node.
+func ShardHealthService(namespace string, shard int64) string {
+	return fmt.Sprintf("oxia-shard/%s/%d", namespace, shard)
+}
+
+// A shard is served only by its leader and by the followers that are
+// replicating from it. While a shard is fenced, the node is waiting to
+// be caught up by the new leader.
+func shardHealthStatus(status proto.ServingStatus) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	switch status {
+	case proto.ServingStatus_LEADER, proto.ServingStatus_FOLLOWER:
+		return grpc_health_v1.HealthCheckResponse_SERVING
+	default:
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+func TestShardHealth_Follower(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+	healthServer := health.NewServer()
+
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), healthServer)
+
+	fc, err := sd.GetOrCreateFollower(common.DefaultNamespace, shard, 1)
+	assert.NoError(t, err)
+	assertShardHealth(t, healthServer, shard, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assertShardHealth(t, healthServer, shard, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	_, err = fc.Truncate(&proto.TruncateRequest{
+		Term: 1,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FOLLOWER, fc.Status())
+	assertShardHealth(t, healthServer, shard, grpc_health_v1.HealthCheckResponse_SERVING)
+
+	// A new term fences the follower again
+	_, err = fc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_FENCED, fc.Status())
+	assertShardHealth(t, healthServer, shard, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	_, err = fc.Truncate(&proto.TruncateRequest{
+		Term: 2,
+		HeadEntryId: &proto.EntryId{
+			Term:   0,
+			Offset: wal.InvalidOffset,
+		},
+	})
+	assert.NoError(t, err)
+	assertShardHealth(t, healthServer, shard, grpc_health_v1.HealthCheckResponse_SERVING)
+
+	_, err = sd.UnassignShard(&proto.UnassignShardRequest{Namespace: common.DefaultNamespace, Shard: shard})
+	assert.NoError(t, err)
+	assertShardHealth(t, healthServer, shard, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	assert.NoError(t, sd.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestShardHealth_PublicService(t *testing.T) {
+	server, err := New(Config{
+		InternalServiceAddr: "localhost:0",
+		PublicServiceAddr:   "localhost:0",
+		DataDir:             t.TempDir(),
+		WalDir:              t.TempDir(),
+	})
+	assert.NoError(t, err)
+
+	target := fmt.Sprintf("localhost:%d", server.PublicPort())
+	cnx, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	client := grpc_health_v1.NewHealthClient(cnx)
+
+	response, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: ""})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, response.Status)
+
+	// The node is not hosting any shard yet
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{
+		Service: ShardHealthService(common.DefaultNamespace, 0),
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = server.shardsDirector.GetOrCreateFollower(common.DefaultNamespace, 0, 1)
+	assert.NoError(t, err)
+
+	response, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{
+		Service: ShardHealthService(common.DefaultNamespace, 0),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, response.Status)
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, server.Close())
+}
+
+func assertShardHealth(t *testing.T, healthServer *health.Server, shard int64, expected grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+
+	response, err := healthServer.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{
+		Service: ShardHealthService(common.DefaultNamespace, shard),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, response.Status)
+}
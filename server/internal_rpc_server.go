@@ -44,15 +44,22 @@ type internalRpcServer struct {
 	assignmentDispatcher ShardAssignmentsDispatcher
 	grpcServer           container.GrpcServer
 	healthServer         *health.Server
+	maintenance          *maintenanceMode
+	diskWatermarks       *diskWatermarkMonitor
+	locality             Locality
 	log                  *slog.Logger
 }
 
 func newInternalRpcServer(grpcProvider container.GrpcProvider, bindAddress string, shardsDirector ShardsDirector,
-	assignmentDispatcher ShardAssignmentsDispatcher, healthServer *health.Server, tlsConf *tls.Config) (*internalRpcServer, error) {
+	assignmentDispatcher ShardAssignmentsDispatcher, healthServer *health.Server, maintenance *maintenanceMode,
+	diskWatermarks *diskWatermarkMonitor, locality Locality, tlsConf *tls.Config) (*internalRpcServer, error) {
 	server := &internalRpcServer{
 		shardsDirector:       shardsDirector,
 		assignmentDispatcher: assignmentDispatcher,
 		healthServer:         healthServer,
+		maintenance:          maintenance,
+		diskWatermarks:       diskWatermarks,
+		locality:             locality,
 		log: slog.With(
 			slog.String("component", "internal-rpc-server"),
 		),
@@ -128,8 +135,11 @@ func (s *internalRpcServer) NewTerm(c context.Context, req *proto.NewTermRequest
 				"NewTerm of follower failed",
 				slog.Any("error", err2),
 			)
+			return nil, err2
 		}
-		return res, err2
+		res.Maintenance = s.maintenance.Enabled()
+		res.DiskFull = s.diskWatermarks.Full()
+		return res, nil
 	}
 
 	leader, err := s.shardsDirector.GetOrCreateLeader(req.Namespace, req.Shard)
@@ -146,13 +156,16 @@ func (s *internalRpcServer) NewTerm(c context.Context, req *proto.NewTermRequest
 			"New term processing of leader failed",
 			slog.Any("error", err),
 		)
-	} else {
-		log.Info(
-			"New term processing completed",
-			slog.Any("response", res),
-		)
+		return nil, err2
 	}
-	return res, err2
+
+	res.Maintenance = s.maintenance.Enabled()
+	res.DiskFull = s.diskWatermarks.Full()
+	log.Info(
+		"New term processing completed",
+		slog.Any("response", res),
+	)
+	return res, nil
 }
 
 func (s *internalRpcServer) BecomeLeader(c context.Context, req *proto.BecomeLeaderRequest) (*proto.BecomeLeaderResponse, error) {
@@ -209,6 +222,87 @@ func (s *internalRpcServer) AddFollower(c context.Context, req *proto.AddFollowe
 	return res, err2
 }
 
+func (s *internalRpcServer) RemoveFollower(c context.Context, req *proto.RemoveFollowerRequest) (*proto.RemoveFollowerResponse, error) {
+	log := s.log.With(
+		slog.Any("request", req),
+		slog.String("peer", common.GetPeer(c)),
+	)
+
+	log.Info("Received RemoveFollower request")
+
+	leader, err := s.shardsDirector.GetLeader(req.Shard)
+	if err != nil {
+		log.Warn(
+			"RemoveFollower failed: could not get leader controller",
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	res, err2 := leader.RemoveFollower(req)
+	if err2 != nil {
+		log.Warn(
+			"RemoveFollower failed",
+			slog.Any("error", err2),
+		)
+	}
+	return res, err2
+}
+
+func (s *internalRpcServer) TransferLeadership(c context.Context, req *proto.TransferLeadershipRequest) (*proto.TransferLeadershipResponse, error) {
+	log := s.log.With(
+		slog.Any("request", req),
+		slog.String("peer", common.GetPeer(c)),
+	)
+
+	log.Info("Received TransferLeadership request")
+
+	leader, err := s.shardsDirector.GetLeader(req.Shard)
+	if err != nil {
+		log.Warn(
+			"TransferLeadership failed: could not get leader controller",
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	res, err2 := leader.TransferLeadership(c, req)
+	if err2 != nil {
+		log.Warn(
+			"TransferLeadership failed",
+			slog.Any("error", err2),
+		)
+	}
+	return res, err2
+}
+
+func (s *internalRpcServer) SplitShard(c context.Context, req *proto.SplitShardRequest) (*proto.SplitShardResponse, error) {
+	log := s.log.With(
+		slog.Any("request", req),
+		slog.String("peer", common.GetPeer(c)),
+	)
+
+	log.Info("Received SplitShard request")
+
+	leader, err := s.shardsDirector.GetLeader(req.Shard)
+	if err != nil {
+		log.Warn(
+			"SplitShard failed: could not get leader controller",
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+
+	res, err2 := leader.SplitShard(c, req)
+	if err2 != nil {
+		log.Warn(
+			"SplitShard failed",
+			slog.Any("error", err2),
+		)
+	}
+	return res, err2
+}
+
 func (s *internalRpcServer) Truncate(c context.Context, req *proto.TruncateRequest) (*proto.TruncateResponse, error) {
 	log := s.log.With(
 		slog.Any("request", req),
@@ -326,7 +420,14 @@ func (s *internalRpcServer) SendSnapshot(srv proto.OxiaLogReplication_SendSnapsh
 		return err
 	}
 
-	err2 := follower.SendSnapshot(srv)
+	// The snapshots restored from a backup must be of the same hash range
+	// as the shard
+	var hashRange *proto.Int32HashRange
+	if assignment := s.assignmentDispatcher.GetShardAssignment(shardId); assignment != nil {
+		hashRange = assignment.GetInt32HashRange()
+	}
+
+	err2 := follower.SendSnapshot(&snapshotRangeCheck{srv, hashRange})
 	if err2 != nil {
 		s.log.Warn(
 			"SendSnapshot failed",
@@ -340,6 +441,24 @@ func (s *internalRpcServer) SendSnapshot(srv proto.OxiaLogReplication_SendSnapsh
 }
 
 func (s *internalRpcServer) GetStatus(_ context.Context, req *proto.GetStatusRequest) (*proto.GetStatusResponse, error) {
+	res, err := s.getStatus(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Maintenance = s.maintenance.Enabled()
+	res.DiskFull = s.diskWatermarks.Full()
+	return res, nil
+}
+
+func (s *internalRpcServer) GetInfo(context.Context, *proto.GetInfoRequest) (*proto.GetInfoResponse, error) {
+	return &proto.GetInfoResponse{
+		Zone: s.locality.Zone,
+		Host: s.locality.Host,
+	}, nil
+}
+
+func (s *internalRpcServer) getStatus(req *proto.GetStatusRequest) (*proto.GetStatusResponse, error) {
 	follower, err := s.shardsDirector.GetFollower(req.Shard)
 	if err == nil {
 		return follower.GetStatus(req)
@@ -358,10 +477,90 @@ func (s *internalRpcServer) GetStatus(_ context.Context, req *proto.GetStatusReq
 	return leader.GetStatus(req)
 }
 
+func (s *internalRpcServer) GetSnapshot(req *proto.GetSnapshotRequest, srv proto.OxiaCoordination_GetSnapshotServer) error {
+	log := s.log.With(
+		slog.Any("req", req),
+		slog.String("peer", common.GetPeer(srv.Context())),
+	)
+
+	log.Info("Received GetSnapshot request")
+
+	var hashRange *proto.Int32HashRange
+	if assignment := s.assignmentDispatcher.GetShardAssignment(req.Shard); assignment != nil {
+		hashRange = assignment.GetInt32HashRange()
+	}
+
+	err := s.getSnapshot(req, hashRange, srv)
+	if err != nil {
+		log.Warn(
+			"GetSnapshot failed",
+			slog.Any("error", err),
+		)
+	}
+	return err
+}
+
+func (s *internalRpcServer) getSnapshot(req *proto.GetSnapshotRequest, hashRange *proto.Int32HashRange,
+	srv proto.OxiaCoordination_GetSnapshotServer) error {
+	follower, err := s.shardsDirector.GetFollower(req.Shard)
+	if err == nil {
+		return follower.GetSnapshot(req, hashRange, srv)
+	}
+
+	if status.Code(err) != common.CodeNodeIsNotFollower {
+		return err
+	}
+
+	// If we don't have a follower, fallback to the leader controller
+	leader, err := s.shardsDirector.GetLeader(req.Shard)
+	if err != nil {
+		return err
+	}
+
+	return leader.GetSnapshot(req, hashRange, srv)
+}
+
 func (s *internalRpcServer) DeleteShard(_ context.Context, req *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error) {
 	return s.shardsDirector.DeleteShard(req)
 }
 
+func (s *internalRpcServer) AssignShard(_ context.Context, req *proto.AssignShardRequest) (*proto.AssignShardResponse, error) {
+	return s.shardsDirector.AssignShard(req)
+}
+
+func (s *internalRpcServer) UnassignShard(_ context.Context, req *proto.UnassignShardRequest) (*proto.UnassignShardResponse, error) {
+	return s.shardsDirector.UnassignShard(req)
+}
+
+func (s *internalRpcServer) CompactShard(c context.Context, req *proto.CompactShardRequest) (*proto.CompactShardResponse, error) {
+	log := s.log.With(
+		slog.Any("req", req),
+		slog.String("peer", common.GetPeer(c)),
+	)
+
+	log.Info("Received CompactShard request")
+
+	res, err := s.shardsDirector.CompactShard(c, req)
+	if err != nil {
+		log.Warn(
+			"CompactShard failed",
+			slog.Any("error", err),
+		)
+	}
+	return res, err
+}
+
+func (s *internalRpcServer) SetMaintenance(c context.Context, req *proto.SetMaintenanceRequest) (*proto.SetMaintenanceResponse, error) {
+	s.log.Info(
+		"Received SetMaintenance request",
+		slog.Any("req", req),
+		slog.String("peer", common.GetPeer(c)),
+	)
+
+	s.maintenance.Set(req.Enabled, req.TransferLeadership)
+	return &proto.SetMaintenanceResponse{}, nil
+}
+
 func readHeader(md metadata.MD, key string) (value string, err error) {
 	arr := md.Get(key)
 	if len(arr) == 0 {
@@ -18,20 +18,26 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
+	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/common/container"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
 )
 
 func TestInternalHealthCheck(t *testing.T) {
 	healthServer := health.NewServer()
 	server, err := newInternalRpcServer(container.Default, "localhost:0", nil,
-		NewShardAssignmentDispatcher(healthServer), healthServer, nil)
+		NewShardAssignmentDispatcher(healthServer), healthServer, newMaintenanceMode(false, healthServer),
+		newTestDiskWatermarkMonitor(t, healthServer), Locality{}, nil)
 	assert.NoError(t, err)
 
 	target := fmt.Sprintf("localhost:%d", server.grpcServer.Port())
@@ -46,3 +52,210 @@ func TestInternalHealthCheck(t *testing.T) {
 
 	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, response.Status)
 }
+
+func TestInternalRpcServer_GetInfo(t *testing.T) {
+	healthServer := health.NewServer()
+	server, err := newInternalRpcServer(container.Default, "localhost:0", nil,
+		NewShardAssignmentDispatcher(healthServer), healthServer, newMaintenanceMode(false, healthServer),
+		newTestDiskWatermarkMonitor(t, healthServer), Locality{Zone: "zone-a", Host: "host-1"}, nil)
+	assert.NoError(t, err)
+
+	target := fmt.Sprintf("localhost:%d", server.grpcServer.Port())
+	cnx, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	res, err := proto.NewOxiaCoordinationClient(cnx).GetInfo(context.Background(), &proto.GetInfoRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "zone-a", res.Zone)
+	assert.Equal(t, "host-1", res.Host)
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, server.Close())
+}
+
+func TestInternalRpcServer_ErrorCodes(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+	healthServer := health.NewServer()
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), healthServer)
+
+	server, err := newInternalRpcServer(container.Default, "localhost:0", sd,
+		NewShardAssignmentDispatcher(healthServer), healthServer, newMaintenanceMode(false, healthServer),
+		newTestDiskWatermarkMonitor(t, healthServer), Locality{}, nil)
+	assert.NoError(t, err)
+
+	target := fmt.Sprintf("localhost:%d", server.grpcServer.Port())
+	cnx, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	client := proto.NewOxiaCoordinationClient(cnx)
+
+	_, err = client.NewTerm(context.Background(), &proto.NewTermRequest{
+		Namespace: common.DefaultNamespace,
+		Shard:     shard,
+		Term:      2,
+	})
+	assert.NoError(t, err)
+
+	// Stale term
+	_, err = client.NewTerm(context.Background(), &proto.NewTermRequest{
+		Namespace: common.DefaultNamespace,
+		Shard:     shard,
+		Term:      1,
+	})
+	assert.Equal(t, common.CodeInvalidTerm, status.Code(err))
+
+	_, err = client.AddFollower(context.Background(), &proto.AddFollowerRequest{
+		Namespace:           common.DefaultNamespace,
+		Shard:               shard,
+		Term:                1,
+		FollowerName:        "f1",
+		FollowerHeadEntryId: InvalidEntryId,
+	})
+	assert.Equal(t, common.CodeInvalidTerm, status.Code(err))
+
+	// The node is fenced and not leader yet. The error is wrapped on the
+	// server side, though the code must be preserved on the wire
+	_, err = client.AddFollower(context.Background(), &proto.AddFollowerRequest{
+		Namespace:           common.DefaultNamespace,
+		Shard:               shard,
+		Term:                2,
+		FollowerName:        "f1",
+		FollowerHeadEntryId: InvalidEntryId,
+	})
+	assert.Equal(t, common.CodeInvalidStatus, status.Code(err))
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, server.Close())
+	assert.NoError(t, sd.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestInternalRpcServer_Maintenance(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+	healthServer := health.NewServer()
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), healthServer)
+
+	server, err := newInternalRpcServer(container.Default, "localhost:0", sd,
+		NewShardAssignmentDispatcher(healthServer), healthServer, newMaintenanceMode(true, healthServer),
+		newTestDiskWatermarkMonitor(t, healthServer), Locality{}, nil)
+	assert.NoError(t, err)
+
+	target := fmt.Sprintf("localhost:%d", server.grpcServer.Port())
+	cnx, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	client := proto.NewOxiaCoordinationClient(cnx)
+	healthClient := grpc_health_v1.NewHealthClient(cnx)
+
+	checkLeadership := func() grpc_health_v1.HealthCheckResponse_ServingStatus {
+		res, err := healthClient.Check(context.Background(),
+			&grpc_health_v1.HealthCheckRequest{Service: container.LeadershipHealthService})
+		assert.NoError(t, err)
+		return res.Status
+	}
+
+	// Started in maintenance mode, without asking to transfer the leaderships
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, checkLeadership())
+
+	ntRes, err := client.NewTerm(context.Background(), &proto.NewTermRequest{
+		Namespace: common.DefaultNamespace,
+		Shard:     shard,
+		Term:      1,
+	})
+	assert.NoError(t, err)
+	assert.True(t, ntRes.Maintenance)
+
+	statusRes, err := client.GetStatus(context.Background(), &proto.GetStatusRequest{Shard: shard})
+	assert.NoError(t, err)
+	assert.True(t, statusRes.Maintenance)
+
+	_, err = client.SetMaintenance(context.Background(), &proto.SetMaintenanceRequest{
+		Enabled:            true,
+		TransferLeadership: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, checkLeadership())
+
+	_, err = client.SetMaintenance(context.Background(), &proto.SetMaintenanceRequest{Enabled: false})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, checkLeadership())
+
+	statusRes, err = client.GetStatus(context.Background(), &proto.GetStatusRequest{Shard: shard})
+	assert.NoError(t, err)
+	assert.False(t, statusRes.Maintenance)
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, server.Close())
+	assert.NoError(t, sd.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestInternalRpcServer_DiskFull(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+	healthServer := health.NewServer()
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), healthServer)
+
+	stats := newFakeDiskStats()
+	stats.set("db", 95)
+	diskWatermarks, err := newDiskWatermarkMonitor(DiskWatermarkOptions{High: "90%", CheckInterval: time.Hour},
+		[]string{"db"}, healthServer, stats.get)
+	assert.NoError(t, err)
+
+	server, err := newInternalRpcServer(container.Default, "localhost:0", sd,
+		NewShardAssignmentDispatcher(healthServer), healthServer, newMaintenanceMode(false, healthServer),
+		diskWatermarks, Locality{}, nil)
+	assert.NoError(t, err)
+
+	target := fmt.Sprintf("localhost:%d", server.grpcServer.Port())
+	cnx, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	client := proto.NewOxiaCoordinationClient(cnx)
+	healthClient := grpc_health_v1.NewHealthClient(cnx)
+
+	healthRes, err := healthClient.Check(context.Background(),
+		&grpc_health_v1.HealthCheckRequest{Service: container.DiskHealthService})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, healthRes.Status)
+
+	ntRes, err := client.NewTerm(context.Background(), &proto.NewTermRequest{
+		Namespace: common.DefaultNamespace,
+		Shard:     shard,
+		Term:      1,
+	})
+	assert.NoError(t, err)
+	assert.True(t, ntRes.DiskFull)
+	assert.False(t, ntRes.Maintenance)
+
+	statusRes, err := client.GetStatus(context.Background(), &proto.GetStatusRequest{Shard: shard})
+	assert.NoError(t, err)
+	assert.True(t, statusRes.DiskFull)
+
+	stats.set("db", 50)
+	diskWatermarks.check()
+
+	statusRes, err = client.GetStatus(context.Background(), &proto.GetStatusRequest{Shard: shard})
+	assert.NoError(t, err)
+	assert.False(t, statusRes.DiskFull)
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, server.Close())
+	assert.NoError(t, diskWatermarks.Close())
+	assert.NoError(t, sd.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
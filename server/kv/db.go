@@ -15,10 +15,12 @@
 package kv
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"log/slog"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -42,6 +44,7 @@ var (
 
 const (
 	commitOffsetKey        = common.InternalKeyPrefix + "commit-offset"
+	commitTermKey          = common.InternalKeyPrefix + "commit-term"
 	commitLastVersionIdKey = common.InternalKeyPrefix + "last-version-id"
 	termKey                = common.InternalKeyPrefix + "term"
 )
@@ -49,6 +52,10 @@ const (
 type UpdateOperationCallback interface {
 	OnPut(WriteBatch, *proto.PutRequest, *proto.StorageEntry) (proto.Status, error)
 	OnDelete(WriteBatch, string) error
+
+	// OnSessionWrite checks the session of a request that is deduplicated
+	// within the session
+	OnSessionWrite(WriteBatch, int64) (proto.Status, error)
 }
 
 type RangeScanIterator interface {
@@ -62,19 +69,77 @@ type RangeScanIterator interface {
 type DB interface {
 	io.Closer
 
-	ProcessWrite(b *proto.WriteRequest, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) (*proto.WriteResponse, error)
+	// ProcessWrite applies all the operations of the write request, together
+	// with the id of the committed entry, in a single write batch that is
+	// committed atomically.
+	//
+	// The operations whose conditions are not satisfied only carry a failure
+	// status in the response and don't prevent the other operations from
+	// being applied. The outcome only depends on the stored data, the commit
+	// offset and the timestamp, so that all the replicas that apply the same
+	// request, after the same history, end up in the same state.
+	//
+	// A request that carries a session id and a sequence is applied only
+	// once within the session: a retry gets the response of the original
+	// request.
+	//
+	// If an error is returned, nothing was applied.
+	ProcessWrite(b *proto.WriteRequest, commitTerm int64, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) (*proto.WriteResponse, error)
+
+	// NewApplyBatch creates a batch to apply the write requests of a range
+	// of committed entries with a single storage commit
+	NewApplyBatch() ApplyBatch
+
+	// ProcessShardSplit applies the split of the shard into the children
+	// shards. A child only keeps the records in its own hash range, while
+	// the parent retains all of them and records the split
+	ProcessShardSplit(split *proto.ShardSplit, commitTerm int64, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) error
+
+	// ShardSplit returns the split that the shard went through, if any
+	ShardSplit() (*proto.ShardSplit, error)
+
 	Get(request *proto.GetRequest) (*proto.GetResponse, error)
+
+	// GetBatch reads the records of all the gets from the same snapshot of
+	// the database, so that they observe the same state. The responses are
+	// in the order of the requests
+	GetBatch(requests []*proto.GetRequest) ([]*proto.GetResponse, error)
+
 	List(request *proto.ListRequest) (KeyIterator, error)
 	RangeScan(request *proto.RangeScanRequest) (RangeScanIterator, error)
 	ReadCommitOffset() (int64, error)
 
+	// ReadCommitEntryId returns the id of the last entry applied to the
+	// database. The database can be ahead of the wal, for example after a
+	// snapshot was installed.
+	ReadCommitEntryId() (*proto.EntryId, error)
+
+	// ExpiredKeys returns the delete operations for the records that have
+	// expired by the time `now`
+	ExpiredKeys(now uint64, maxCount int) ([]*proto.DeleteRequest, error)
+
 	ReadNextNotifications(ctx context.Context, startOffset int64) ([]*proto.NotificationBatch, error)
 
 	UpdateTerm(newTerm int64) error
 	ReadTerm() (term int64, err error)
 
+	// UnappliableEntry returns the committed entry that the replica could
+	// not apply, or nil if there is none
+	UnappliableEntry() (*proto.UnappliableEntry, error)
+
+	// SetUnappliableEntry records the committed entry that the replica could
+	// not apply, or clears it when nil
+	SetUnappliableEntry(entry *proto.UnappliableEntry) error
+
 	Snapshot() (Snapshot, error)
 
+	// DiskUsage returns the size in bytes of the files of the database
+	DiskUsage() int64
+
+	// Compact drops the records that were overwritten or deleted from the
+	// storage, and returns the number of bytes reclaimed
+	Compact() (reclaimedBytes int64, err error)
+
 	// Delete and close the database and all its files
 	Delete() error
 }
@@ -89,6 +154,7 @@ func NewDB(namespace string, shardId int64, factory Factory, notificationRetenti
 	db := &db{
 		kv:      kv,
 		shardId: shardId,
+		clock:   clock,
 		log: slog.With(
 			slog.String("component", "db"),
 			slog.String("namespace", namespace),
@@ -126,6 +192,10 @@ func NewDB(namespace string, shardId int64, factory Factory, notificationRetenti
 	}
 	db.versionIdTracker.Store(lastVersionId)
 
+	if err = db.completeShardSplit(); err != nil {
+		return nil, errors.Wrap(err, "failed to complete the shard split")
+	}
+
 	db.notificationsTracker = newNotificationsTracker(namespace, shardId, commitOffset, kv, notificationRetentionTime, clock)
 	return db, nil
 }
@@ -135,6 +205,7 @@ type db struct {
 	shardId              int64
 	versionIdTracker     atomic.Int64
 	notificationsTracker *notificationsTracker
+	clock                common.Clock
 	log                  *slog.Logger
 
 	putCounter          metrics.Counter
@@ -207,44 +278,26 @@ func (d *db) applyWriteRequest(b *proto.WriteRequest, batch WriteBatch, commitOf
 	return notifications, res, nil
 }
 
-func (d *db) ProcessWrite(b *proto.WriteRequest, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) (*proto.WriteResponse, error) {
+func (d *db) ProcessWrite(b *proto.WriteRequest, commitTerm int64, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) (*proto.WriteResponse, error) {
 	timer := d.batchWriteLatencyHisto.Timer()
 	defer timer.Done()
 
-	batch := d.kv.NewWriteBatch()
-	notifications, res, err := d.applyWriteRequest(b, batch, commitOffset, timestamp, updateOperationCallback)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := d.addASCIILong(commitOffsetKey, commitOffset, batch, timestamp); err != nil {
-		return nil, err
-	}
-
-	if err := d.addASCIILong(commitLastVersionIdKey, d.versionIdTracker.Load(), batch, timestamp); err != nil {
-		return nil, err
-	}
-
-	// Add the notifications to the batch as well
-	if err := d.addNotifications(batch, notifications); err != nil {
-		return nil, err
+	batch := d.NewApplyBatch()
+	res, err := batch.ProcessWrite(b, commitTerm, commitOffset, timestamp, updateOperationCallback)
+	if err == nil {
+		err = batch.Commit()
 	}
-
-	if err := batch.Commit(); err != nil {
-		return nil, err
-	}
-
-	d.notificationsTracker.UpdatedCommitOffset(commitOffset)
-
-	if err := batch.Close(); err != nil {
-		return nil, err
+	if err != nil {
+		return nil, multierr.Append(err, batch.Close())
 	}
 
-	return res, nil
+	return res, batch.Close()
 }
 
 func (*db) addNotifications(batch WriteBatch, notifications *notifications) error {
-	value, err := notifications.batch.MarshalVT()
+	// The notifications are keyed by the record key. The map must be
+	// serialized in a stable order to get the same bytes on all the replicas
+	value, err := pb.MarshalOptions{Deterministic: true}.Marshal(&notifications.batch)
 	if err != nil {
 		return err
 	}
@@ -252,6 +305,14 @@ func (*db) addNotifications(batch WriteBatch, notifications *notifications) erro
 	return batch.Put(notificationKey(notifications.batch.Offset), value)
 }
 
+func (d *db) addCommitEntryId(batch WriteBatch, commitTerm int64, commitOffset int64, timestamp uint64) error {
+	if err := d.addASCIILong(commitOffsetKey, commitOffset, batch, timestamp); err != nil {
+		return err
+	}
+
+	return d.addASCIILong(commitTermKey, commitTerm, batch, timestamp)
+}
+
 func (d *db) addASCIILong(key string, value int64, batch WriteBatch, timestamp uint64) error {
 	asciiValue := []byte(fmt.Sprintf("%d", value))
 	_, err := d.applyPut(batch, nil, &proto.PutRequest{
@@ -267,7 +328,45 @@ func (d *db) Get(request *proto.GetRequest) (*proto.GetResponse, error) {
 	defer timer.Done()
 
 	d.getCounter.Add(1)
-	return applyGet(d.kv, request)
+	return applyGet(d.kv, request, d.now())
+}
+
+func (d *db) GetBatch(requests []*proto.GetRequest) ([]*proto.GetResponse, error) {
+	if len(requests) <= 1 {
+		// A single get is consistent on its own
+		responses := make([]*proto.GetResponse, 0, len(requests))
+		for _, request := range requests {
+			response, err := d.Get(request)
+			if err != nil {
+				return nil, err
+			}
+			responses = append(responses, response)
+		}
+		return responses, nil
+	}
+
+	snapshot, err := d.kv.NewReadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.now()
+	responses := make([]*proto.GetResponse, 0, len(requests))
+	for _, request := range requests {
+		timer := d.getLatencyHisto.Timer()
+		d.getCounter.Add(1)
+		response, err := applyGet(snapshot, request, now)
+		timer.Done()
+		if err != nil {
+			return nil, multierr.Append(err, snapshot.Close())
+		}
+		responses = append(responses, response)
+	}
+	return responses, snapshot.Close()
+}
+
+func (d *db) now() uint64 {
+	return uint64(d.clock.Now().UnixMilli())
 }
 
 type listIterator struct {
@@ -283,13 +382,13 @@ func (it *listIterator) Close() error {
 func (d *db) List(request *proto.ListRequest) (KeyIterator, error) {
 	d.listCounter.Add(1)
 
-	it, err := d.kv.KeyRangeScan(request.StartInclusive, request.EndExclusive)
+	it, err := d.kv.RangeScan(request.StartInclusive, request.EndExclusive)
 	if err != nil {
 		return nil, err
 	}
 
 	return &listIterator{
-		KeyIterator: it,
+		KeyIterator: newLimitIterator(newUnexpiredIterator(it, d.now()), request.Limit),
 		timer:       d.listLatencyHisto.Timer(),
 	}, nil
 }
@@ -341,15 +440,62 @@ func (d *db) RangeScan(request *proto.RangeScanRequest) (RangeScanIterator, erro
 	}
 
 	return &rangeScanIterator{
-		KeyValueIterator: it,
+		KeyValueIterator: newLimitIterator(newUnexpiredIterator(it, d.now()), request.Limit),
 		timer:            d.listLatencyHisto.Timer(),
 	}, nil
 }
 
+// limitIterator stops after returning, at most, a given number of entries.
+type limitIterator struct {
+	KeyValueIterator
+	remaining uint64
+}
+
+func newLimitIterator(it KeyValueIterator, limit *uint64) KeyValueIterator {
+	if limit == nil {
+		return it
+	}
+
+	return &limitIterator{
+		KeyValueIterator: it,
+		remaining:        *limit,
+	}
+}
+
+func (it *limitIterator) Valid() bool {
+	return it.remaining > 0 && it.KeyValueIterator.Valid()
+}
+
+func (it *limitIterator) Next() bool {
+	if it.remaining > 0 {
+		it.remaining--
+	}
+
+	if it.remaining == 0 {
+		return false
+	}
+	return it.KeyValueIterator.Next()
+}
+
 func (d *db) ReadCommitOffset() (int64, error) {
 	return d.readASCIILong(commitOffsetKey)
 }
 
+func (d *db) ReadCommitEntryId() (*proto.EntryId, error) {
+	commitOffset, err := d.ReadCommitOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	// The term is missing in databases written before it was recorded
+	commitTerm, err := d.readASCIILong(commitTermKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.EntryId{Term: commitTerm, Offset: commitOffset}, nil
+}
+
 func (d *db) readLastVersionId() (int64, error) {
 	return d.readASCIILong(commitLastVersionIdKey)
 }
@@ -361,7 +507,7 @@ func (d *db) readASCIILong(key string) (int64, error) {
 		Key:          key,
 		IncludeValue: true,
 	}
-	gr, err := applyGet(kv, getReq)
+	gr, err := applyGet(kv, getReq, 0)
 	if err != nil {
 		return wal.InvalidOffset, err
 	}
@@ -399,12 +545,20 @@ func (d *db) UpdateTerm(newTerm int64) error {
 	return d.kv.Flush()
 }
 
+func (d *db) DiskUsage() int64 {
+	return d.kv.DiskUsage()
+}
+
+func (d *db) Compact() (reclaimedBytes int64, err error) {
+	return d.kv.Compact()
+}
+
 func (d *db) ReadTerm() (term int64, err error) {
 	getReq := &proto.GetRequest{
 		Key:          termKey,
 		IncludeValue: true,
 	}
-	gr, err := applyGet(d.kv, getReq)
+	gr, err := applyGet(d.kv, getReq, 0)
 	if err != nil {
 		return wal.InvalidTerm, err
 	}
@@ -438,6 +592,15 @@ func (d *db) applyPut(batch WriteBatch, notifications *notifications, putReq *pr
 		return nil, errors.Wrap(err, "oxia db: failed to apply batch")
 	}
 
+	if putReq.ExpectedValue != nil && (se == nil || !bytes.Equal(se.Value, putReq.ExpectedValue)) {
+		pr := &proto.PutResponse{Status: proto.Status_UNEXPECTED_VALUE}
+		if se != nil {
+			pr.Version = storageEntryVersion(se)
+			se.ReturnToVTPool()
+		}
+		return pr, nil
+	}
+
 	// No version conflict
 	status, err := updateOperationCallback.OnPut(batch, putReq, se)
 	if err != nil {
@@ -449,6 +612,18 @@ func (d *db) applyPut(batch WriteBatch, notifications *notifications, putReq *pr
 		}, nil
 	}
 
+	// The expiration is based on the timestamp of the log entry, so that
+	// all the replicas agree on it
+	var expirationTimestamp *uint64
+	if putReq.ExpireAfterMs != nil {
+		expirationTimestamp = pb.Uint64(timestamp + *putReq.ExpireAfterMs)
+	}
+	if !internal {
+		if err = updateExpiryIndex(batch, putReq.Key, se, expirationTimestamp); err != nil {
+			return nil, err
+		}
+	}
+
 	var versionId int64
 	if internal {
 		versionId = wal.InvalidOffset
@@ -466,6 +641,7 @@ func (d *db) applyPut(batch WriteBatch, notifications *notifications, putReq *pr
 		se.SessionId = putReq.SessionId
 		se.ClientIdentity = putReq.ClientIdentity
 		se.PartitionKey = putReq.PartitionKey
+		se.ExpirationTimestamp = expirationTimestamp
 	} else {
 		se.VersionId = versionId
 		se.ModificationsCount++
@@ -474,6 +650,7 @@ func (d *db) applyPut(batch WriteBatch, notifications *notifications, putReq *pr
 		se.SessionId = putReq.SessionId
 		se.ClientIdentity = putReq.ClientIdentity
 		se.PartitionKey = putReq.PartitionKey
+		se.ExpirationTimestamp = expirationTimestamp
 	}
 
 	defer se.ReturnToVTPool()
@@ -488,17 +665,10 @@ func (d *db) applyPut(batch WriteBatch, notifications *notifications, putReq *pr
 	}
 
 	if notifications != nil {
-		notifications.Modified(putReq.Key, se.VersionId, se.ModificationsCount)
+		notifications.Modified(putReq.Key, se.VersionId, se.ModificationsCount, se.CreationTimestamp)
 	}
 
-	version := &proto.Version{
-		VersionId:          se.VersionId,
-		ModificationsCount: se.ModificationsCount,
-		CreatedTimestamp:   se.CreationTimestamp,
-		ModifiedTimestamp:  se.ModificationTimestamp,
-		SessionId:          se.SessionId,
-		ClientIdentity:     se.ClientIdentity,
-	}
+	version := storageEntryVersion(se)
 
 	d.log.Debug(
 		"Applied put operation",
@@ -526,12 +696,21 @@ func (d *db) applyDelete(batch WriteBatch, notifications *notifications, delReq
 		return nil, errors.Wrap(err, "oxia db: failed to apply batch")
 	case se == nil:
 		return &proto.DeleteResponse{Status: proto.Status_KEY_NOT_FOUND}, nil
+	case delReq.ExpectedValue != nil && !bytes.Equal(se.Value, delReq.ExpectedValue):
+		return &proto.DeleteResponse{
+			Status:  proto.Status_UNEXPECTED_VALUE,
+			Version: storageEntryVersion(se),
+		}, nil
 	default:
 		err = updateOperationCallback.OnDelete(batch, delReq.Key)
 		if err != nil {
 			return nil, err
 		}
 
+		if err = updateExpiryIndex(batch, delReq.Key, se, nil); err != nil {
+			return nil, err
+		}
+
 		if err = batch.Delete(delReq.Key); err != nil {
 			return &proto.DeleteResponse{}, err
 		}
@@ -558,7 +737,7 @@ func (*db) applyDeleteRangeNotifications(batch WriteBatch, notifications *notifi
 		return err
 	}
 
-	for it.Next() {
+	for ; it.Valid(); it.Next() {
 		if notifications != nil {
 			notifications.Deleted(it.Key())
 		}
@@ -592,54 +771,110 @@ func (d *db) applyDeleteRange(batch WriteBatch, notifications *notifications, de
 	return &proto.DeleteRangeResponse{Status: proto.Status_OK}, nil
 }
 
-func applyGet(kv KV, getReq *proto.GetRequest) (*proto.GetResponse, error) {
-	key, value, closer, err := kv.Get(getReq.Key, ComparisonType(getReq.GetComparisonType()))
+// The records that have expired by the time `now` are skipped, as if they
+// were already deleted. Reads of internal keys, which never expire, pass 0.
+func applyGet(kv Reader, getReq *proto.GetRequest, now uint64) (*proto.GetResponse, error) {
+	searchKey := getReq.Key
+	comparisonType := getReq.ComparisonType
 
-	if errors.Is(err, ErrKeyNotFound) {
-		return &proto.GetResponse{Status: proto.Status_KEY_NOT_FOUND}, nil
-	} else if err != nil {
-		return nil, errors.Wrap(err, "oxia db: failed to apply batch")
+	for {
+		key, value, closer, err := kv.Get(searchKey, ComparisonType(comparisonType))
+
+		if errors.Is(err, ErrKeyNotFound) {
+			return &proto.GetResponse{Status: proto.Status_KEY_NOT_FOUND}, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "oxia db: failed to apply batch")
+		}
+
+		se, valueSize, includeValue, err := readStorageEntry(getReq, value)
+		if err = multierr.Append(err, closer.Close()); err != nil {
+			return nil, err
+		}
+
+		if isExpired(se, now) {
+			if !includeValue {
+				se.ReturnToVTPool()
+			}
+			if comparisonType == proto.KeyComparisonType_EQUAL {
+				return &proto.GetResponse{Status: proto.Status_KEY_NOT_FOUND}, nil
+			}
+
+			// Keep searching past the expired record
+			searchKey = key
+			switch comparisonType {
+			case proto.KeyComparisonType_FLOOR, proto.KeyComparisonType_LOWER:
+				comparisonType = proto.KeyComparisonType_LOWER
+			default:
+				comparisonType = proto.KeyComparisonType_HIGHER
+			}
+			continue
+		}
+
+		res := toGetResponse(getReq, key, se, valueSize, includeValue)
+		if !includeValue {
+			se.ReturnToVTPool()
+		}
+		return res, nil
 	}
+}
 
-	var se *proto.StorageEntry
-	if getReq.IncludeValue {
+// readStorageEntry deserializes the record of a get. The value is only copied
+// when it's returned, that is when it's requested and within the maximum size
+// of the request. Otherwise, only the metadata of the record and the size of
+// the value are read.
+func readStorageEntry(getReq *proto.GetRequest, value []byte) (se *proto.StorageEntry, valueSize uint64, includeValue bool, err error) {
+	// The serialized record is never smaller than its value
+	if getReq.IncludeValue && (getReq.MaxValueSize == nil || uint64(len(value)) <= *getReq.MaxValueSize) {
 		// If we need to return the value we cannot pool the objects, because
 		// the Value slice would be returned to pool
 		se = &proto.StorageEntry{}
-	} else {
-		se = proto.StorageEntryFromVTPool()
-		defer se.ReturnToVTPool()
+		err = deserialize(value, se)
+		return se, uint64(len(se.Value)), true, err
 	}
 
-	if err = multierr.Append(
-		deserialize(value, se),
-		closer.Close(),
-	); err != nil {
-		return nil, err
+	se = proto.StorageEntryFromVTPool()
+	if valueSize, err = deserializeMetadata(value, se); err != nil || !getReq.IncludeValue || valueSize > *getReq.MaxValueSize {
+		return se, valueSize, false, err
 	}
 
-	resValue := se.Value
-	if !getReq.IncludeValue {
-		resValue = nil
-	}
+	// The value is within the maximum size, though the whole record is not
+	se.ReturnToVTPool()
+	se = &proto.StorageEntry{}
+	err = deserialize(value, se)
+	return se, valueSize, true, err
+}
 
+func toGetResponse(getReq *proto.GetRequest, key string, se *proto.StorageEntry, valueSize uint64, includeValue bool) *proto.GetResponse {
 	res := &proto.GetResponse{
-		Value: resValue,
-		Version: &proto.Version{
-			VersionId:          se.VersionId,
-			ModificationsCount: se.ModificationsCount,
-			CreatedTimestamp:   se.CreationTimestamp,
-			ModifiedTimestamp:  se.ModificationTimestamp,
-			SessionId:          se.SessionId,
-			ClientIdentity:     se.ClientIdentity,
-		},
+		Version: storageEntryVersion(se),
+	}
+
+	switch {
+	case includeValue:
+		res.Value = se.Value
+	case getReq.IncludeValue:
+		res.Status = proto.Status_VALUE_TOO_LARGE
+		res.ValueSize = &valueSize
+	default:
+		res.ValueSize = &valueSize
 	}
 
 	if getReq.ComparisonType != proto.KeyComparisonType_EQUAL {
 		res.Key = &key
 	}
 
-	return res, nil
+	return res
+}
+
+func storageEntryVersion(se *proto.StorageEntry) *proto.Version {
+	return &proto.Version{
+		VersionId:          se.VersionId,
+		ModificationsCount: se.ModificationsCount,
+		CreatedTimestamp:   se.CreationTimestamp,
+		ModifiedTimestamp:  se.ModificationTimestamp,
+		SessionId:          se.SessionId,
+		ClientIdentity:     se.ClientIdentity,
+	}
 }
 
 func GetStorageEntry(batch WriteBatch, key string) (*proto.StorageEntry, error) {
@@ -689,6 +924,26 @@ func deserialize(value []byte, se *proto.StorageEntry) error {
 	return nil
 }
 
+// deserializeMetadata deserializes the storage entry without its value, of
+// which it returns the size.
+func deserializeMetadata(value []byte, se *proto.StorageEntry) (valueSize uint64, err error) {
+	// The unsafe deserialization doesn't copy the value, though the fields
+	// point into the buffer of the kv, which is released once the get is done
+	if err := se.UnmarshalVTUnsafe(value); err != nil {
+		return 0, errors.Wrap(err, "failed to deserialize storage entry")
+	}
+
+	valueSize = uint64(len(se.Value))
+	se.Value = nil
+	if se.ClientIdentity != nil {
+		se.ClientIdentity = pb.String(strings.Clone(*se.ClientIdentity))
+	}
+	if se.PartitionKey != nil {
+		se.PartitionKey = pb.String(strings.Clone(*se.PartitionKey))
+	}
+	return valueSize, nil
+}
+
 func (d *db) ReadNextNotifications(ctx context.Context, startOffset int64) ([]*proto.NotificationBatch, error) {
 	return d.notificationsTracker.ReadNextNotifications(ctx, startOffset)
 }
@@ -703,4 +958,8 @@ func (*noopCallback) OnDelete(WriteBatch, string) error {
 	return nil
 }
 
+func (*noopCallback) OnSessionWrite(WriteBatch, int64) (proto.Status, error) {
+	return proto.Status_OK, nil
+}
+
 var NoOpCallback UpdateOperationCallback = &noopCallback{}
@@ -0,0 +1,138 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"io"
+
+	"github.com/streamnative/oxia/proto"
+)
+
+// ApplyBatch accumulates the write requests of consecutive committed entries
+// in a single write batch, so that applying a range of entries doesn't take
+// a storage commit for each of them. The id of the last entry is committed
+// together with the writes, so that after a crash the entries in the batch
+// are either all applied or none of them is.
+type ApplyBatch interface {
+	// Close discards the requests that were not committed
+	io.Closer
+
+	// ProcessWrite applies the write request on top of the ones already in
+	// the batch, with the same semantics as DB.ProcessWrite. If an error is
+	// returned, the batch must be discarded.
+	ProcessWrite(b *proto.WriteRequest, commitTerm int64, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) (*proto.WriteResponse, error)
+
+	// Count returns the number of write requests in the batch
+	Count() int
+
+	// Size returns the size in bytes of the batch
+	Size() int
+
+	// Commit stores all the requests in the batch, after which the batch
+	// can be used for the next ones
+	Commit() error
+}
+
+type applyBatch struct {
+	d     *db
+	batch WriteBatch
+	count int
+
+	// The version id before the first request in the batch, to give out
+	// the same ids again if the batch is discarded
+	lastVersionId int64
+
+	commitTerm   int64
+	commitOffset int64
+	timestamp    uint64
+}
+
+func (d *db) NewApplyBatch() ApplyBatch {
+	return &applyBatch{d: d}
+}
+
+func (a *applyBatch) ProcessWrite(b *proto.WriteRequest, commitTerm int64, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) (*proto.WriteResponse, error) {
+	if a.batch == nil {
+		a.batch = a.d.kv.NewWriteBatch()
+		a.lastVersionId = a.d.versionIdTracker.Load()
+	}
+
+	notifications, res, err := a.d.applyWriteRequestOnce(b, a.batch, commitOffset, timestamp, updateOperationCallback)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each entry keeps its own notifications
+	if err = a.d.addNotifications(a.batch, notifications); err != nil {
+		return nil, err
+	}
+
+	a.count++
+	a.commitTerm = commitTerm
+	a.commitOffset = commitOffset
+	a.timestamp = timestamp
+	return res, nil
+}
+
+func (a *applyBatch) Count() int {
+	return a.count
+}
+
+func (a *applyBatch) Size() int {
+	if a.batch == nil {
+		return 0
+	}
+	return a.batch.Size()
+}
+
+func (a *applyBatch) Commit() error {
+	if a.batch == nil {
+		return nil
+	}
+
+	if err := a.d.addCommitEntryId(a.batch, a.commitTerm, a.commitOffset, a.timestamp); err != nil {
+		return err
+	}
+
+	if err := a.d.addASCIILong(commitLastVersionIdKey, a.d.versionIdTracker.Load(), a.batch, a.timestamp); err != nil {
+		return err
+	}
+
+	if err := a.batch.Commit(); err != nil {
+		return err
+	}
+
+	a.d.notificationsTracker.UpdatedCommitOffset(a.commitOffset)
+
+	err := a.batch.Close()
+	a.batch = nil
+	a.count = 0
+	return err
+}
+
+func (a *applyBatch) Close() error {
+	if a.batch == nil {
+		return nil
+	}
+
+	// Nothing was committed, so the version ids assigned to the operations
+	// in the batch must be given out again when the entries are applied
+	a.d.versionIdTracker.Store(a.lastVersionId)
+
+	err := a.batch.Close()
+	a.batch = nil
+	a.count = 0
+	return err
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+func TestDB_ApplyBatch(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	batch := db.NewApplyBatch()
+	for offset := int64(0); offset < 3; offset++ {
+		res, err := batch.ProcessWrite(&proto.WriteRequest{
+			Puts: []*proto.PutRequest{{Key: "a", Value: []byte(fmt.Sprintf("%d", offset))}},
+		}, 1, offset, 0, NoOpCallback)
+		assert.NoError(t, err)
+		assert.Equal(t, proto.Status_OK, res.Puts[0].Status)
+
+		// Each request sees the ones before it in the batch
+		assert.EqualValues(t, offset, res.Puts[0].Version.ModificationsCount)
+	}
+	assert.Equal(t, 3, batch.Count())
+	assert.Positive(t, batch.Size())
+
+	// Nothing is visible before the commit
+	gr, err := db.Get(&proto.GetRequest{Key: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, gr.Status)
+	commitOffset, err := db.ReadCommitOffset()
+	assert.NoError(t, err)
+	assert.Equal(t, wal.InvalidOffset, commitOffset)
+
+	assert.NoError(t, batch.Commit())
+	assert.Equal(t, 0, batch.Count())
+
+	gr, err = db.Get(&proto.GetRequest{Key: "a", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), gr.Value)
+	assert.EqualValues(t, 2, gr.Version.VersionId)
+	commitEntryId, err := db.ReadCommitEntryId()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, commitEntryId.Term)
+	assert.EqualValues(t, 2, commitEntryId.Offset)
+
+	// A discarded batch gives out the same version ids again
+	_, err = batch.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "b", Value: []byte("3")}},
+	}, 1, 3, 0, NoOpCallback)
+	assert.NoError(t, err)
+	assert.NoError(t, batch.Close())
+
+	res, err := db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "b", Value: []byte("3")}},
+	}, 1, 3, 0, NoOpCallback)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, res.Puts[0].Version.VersionId)
+
+	commitOffset, err = db.ReadCommitOffset()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, commitOffset)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+// Applies a commit offset advance of 10K entries, either with a storage
+// commit for each entry or with a single batch.
+func BenchmarkDB_ApplyCommittedEntries(b *testing.B) {
+	const entries = 10_000
+
+	newRequest := func(offset int64) *proto.WriteRequest {
+		return &proto.WriteRequest{
+			Puts: []*proto.PutRequest{{
+				Key:   fmt.Sprintf("key-%d", offset%1000),
+				Value: []byte(fmt.Sprintf("value-%d", offset)),
+			}},
+		}
+	}
+
+	run := func(b *testing.B, apply func(db DB, offset int64) error) {
+		b.Helper()
+
+		factory, err := NewPebbleKVFactory(&FactoryOptions{DataDir: b.TempDir()})
+		assert.NoError(b, err)
+		db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+		assert.NoError(b, err)
+
+		offset := int64(0)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err = apply(db, offset); err != nil {
+				b.Fatal(err)
+			}
+			offset += entries
+		}
+		b.StopTimer()
+
+		assert.NoError(b, db.Close())
+		assert.NoError(b, factory.Close())
+	}
+
+	b.Run("per-entry", func(b *testing.B) {
+		run(b, func(db DB, firstOffset int64) error {
+			for offset := firstOffset; offset < firstOffset+entries; offset++ {
+				if _, err := db.ProcessWrite(newRequest(offset), 1, offset, 0, NoOpCallback); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		run(b, func(db DB, firstOffset int64) error {
+			batch := db.NewApplyBatch()
+			defer batch.Close()
+
+			for offset := firstOffset; offset < firstOffset+entries; offset++ {
+				if _, err := batch.ProcessWrite(newRequest(offset), 1, offset, 0, NoOpCallback); err != nil {
+					return err
+				}
+			}
+			return batch.Commit()
+		})
+	})
+}
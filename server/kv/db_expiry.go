@@ -0,0 +1,178 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+// The records with an expiration time are indexed by their expiration
+// timestamp, so that the expired ones can be found with a range scan.
+const expiryKeyPrefix = common.InternalKeyPrefix + "expiry"
+
+func expiryKey(expirationTimestamp uint64, key string) string {
+	return fmt.Sprintf("%s/%016x/%s", expiryKeyPrefix, expirationTimestamp, url.PathEscape(key))
+}
+
+func parseExpiryKey(indexKey string) (expirationTimestamp uint64, key string, err error) {
+	timestamp, escapedKey, found := strings.Cut(strings.TrimPrefix(indexKey, expiryKeyPrefix+"/"), "/")
+	if !found {
+		return 0, "", errors.Errorf("invalid expiry key %s", indexKey)
+	}
+
+	if expirationTimestamp, err = strconv.ParseUint(timestamp, 16, 64); err != nil {
+		return 0, "", errors.Wrapf(err, "invalid expiry key %s", indexKey)
+	}
+
+	key, err = url.PathUnescape(escapedKey)
+	return expirationTimestamp, key, err
+}
+
+func isExpired(se *proto.StorageEntry, now uint64) bool {
+	return se.ExpirationTimestamp != nil && *se.ExpirationTimestamp <= now
+}
+
+// Update the expiry index for a record that is being written. The existing
+// entry, if any, is the version of the record being replaced.
+func updateExpiryIndex(batch WriteBatch, key string, existing *proto.StorageEntry, expirationTimestamp *uint64) error {
+	if existing != nil && existing.ExpirationTimestamp != nil {
+		if expirationTimestamp != nil && *existing.ExpirationTimestamp == *expirationTimestamp {
+			return nil
+		}
+
+		if err := batch.Delete(expiryKey(*existing.ExpirationTimestamp, key)); err != nil {
+			return err
+		}
+	}
+
+	if expirationTimestamp != nil {
+		return batch.Put(expiryKey(*expirationTimestamp, key), []byte{})
+	}
+	return nil
+}
+
+// ExpiredKeys returns the delete operations for, at most, maxCount records
+// that have expired by the time `now`.
+//
+// The index entries that are left over by delete ranges, or by records
+// that were overwritten, are deleted as well.
+func (d *db) ExpiredKeys(now uint64, maxCount int) ([]*proto.DeleteRequest, error) {
+	it, err := d.kv.KeyRangeScan(expiryKeyPrefix+"/", expiryKey(now+1, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	var deletes []*proto.DeleteRequest
+	for ; it.Valid() && len(deletes) < maxCount; it.Next() {
+		indexKey := it.Key()
+		expirationTimestamp, key, err := parseExpiryKey(indexKey)
+		if err != nil {
+			return nil, multierr.Append(err, it.Close())
+		}
+
+		se, err := d.getStorageEntry(key)
+		if err != nil {
+			return nil, multierr.Append(err, it.Close())
+		}
+
+		if se != nil && se.ExpirationTimestamp != nil && *se.ExpirationTimestamp == expirationTimestamp {
+			// The delete of the record takes care of the index entry too. The
+			// expected version protects from a concurrent update of the record
+			versionId := se.VersionId
+			deletes = append(deletes, &proto.DeleteRequest{
+				Key:               key,
+				ExpectedVersionId: &versionId,
+			})
+		} else {
+			deletes = append(deletes, &proto.DeleteRequest{Key: indexKey})
+		}
+
+		if se != nil {
+			se.ReturnToVTPool()
+		}
+	}
+
+	return deletes, it.Close()
+}
+
+func (d *db) getStorageEntry(key string) (*proto.StorageEntry, error) {
+	_, value, closer, err := d.kv.Get(key, ComparisonEqual)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil //nolint:nilnil
+	} else if err != nil {
+		return nil, err
+	}
+
+	se := proto.StorageEntryFromVTPool()
+	if err = multierr.Append(
+		deserialize(value, se),
+		closer.Close(),
+	); err != nil {
+		se.ReturnToVTPool()
+		return nil, err
+	}
+	return se, nil
+}
+
+// unexpiredIterator skips the records that are past their expiration time,
+// even if they were not deleted yet.
+type unexpiredIterator struct {
+	KeyValueIterator
+	now uint64
+}
+
+func newUnexpiredIterator(it KeyValueIterator, now uint64) *unexpiredIterator {
+	uit := &unexpiredIterator{
+		KeyValueIterator: it,
+		now:              now,
+	}
+	uit.skipExpired()
+	return uit
+}
+
+func (it *unexpiredIterator) Next() bool {
+	it.KeyValueIterator.Next()
+	it.skipExpired()
+	return it.Valid()
+}
+
+func (it *unexpiredIterator) skipExpired() {
+	se := proto.StorageEntryFromVTPool()
+	defer se.ReturnToVTPool()
+
+	for it.KeyValueIterator.Valid() {
+		value, err := it.KeyValueIterator.Value()
+		if err != nil {
+			// Let the error surface to the caller, when reading the value
+			return
+		}
+
+		se.ResetVT()
+		if err = deserialize(value, se); err != nil || !isExpired(se, it.now) {
+			return
+		}
+
+		it.KeyValueIterator.Next()
+	}
+}
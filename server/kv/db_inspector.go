@@ -0,0 +1,223 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/multierr"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+// Inspector gives read-only access to the database of a shard, to
+// troubleshoot it offline. The database cannot be inspected while it's in
+// use by a server, nor opened by a server in the meantime.
+type Inspector interface {
+	io.Closer
+
+	// Records calls fn with the records in the range [minKey, maxKey), in the
+	// order of the keys. An empty maxKey means there is no upper bound. The
+	// internal records are skipped.
+	Records(minKey, maxKey string, fn func(key string, se *proto.StorageEntry) error) error
+
+	// Get returns the record of the key, or ErrKeyNotFound.
+	Get(key string) (*proto.StorageEntry, error)
+
+	// InternalRecords calls fn with the keys and the raw values of the records
+	// that track the state of the shard, eg: the sessions and the
+	// notifications.
+	InternalRecords(fn func(key string, value []byte) error) error
+
+	// Markers returns the state of the shard that is persisted in the database.
+	Markers() (*Markers, error)
+
+	Stats() (*Stats, error)
+}
+
+type Markers struct {
+	// CommitEntryId is the id of the last entry applied to the database
+	CommitEntryId    *proto.EntryId
+	Term             int64
+	LastVersionId    int64
+	ShardSplit       *proto.ShardSplit
+	UnappliableEntry *proto.UnappliableEntry
+}
+
+type LevelStats struct {
+	Level int
+	Files int64
+	Size  int64
+}
+
+type Stats struct {
+	// EstimatedKeys is computed from the properties of the data files, and
+	// includes the internal records
+	EstimatedKeys      int64
+	DiskUsage          int64
+	FormatMajorVersion uint64
+	Levels             []LevelStats
+}
+
+type inspector struct {
+	db *db
+	p  *Pebble
+}
+
+// NewInspector opens the database of the shard read-only. It fails with
+// ErrDbLocked if the database is in use.
+func NewInspector(dataDir string, namespace string, shard int64) (Inspector, error) {
+	factory, err := NewPebbleKVFactory(&FactoryOptions{
+		DataDir:  dataDir,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = factory.Close()
+	}()
+
+	kv, err := factory.NewKV(namespace, shard)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the read methods of the db are used
+	return &inspector{
+		db: &db{kv: kv, shardId: shard},
+		p:  kv.(*Pebble),
+	}, nil
+}
+
+func (i *inspector) Records(minKey, maxKey string, fn func(key string, se *proto.StorageEntry) error) error {
+	it, err := i.p.RangeScan(minKey, maxKey)
+	if err != nil {
+		return err
+	}
+
+	for ; it.Valid(); it.Next() {
+		if strings.HasPrefix(it.Key(), common.InternalKeyPrefix) {
+			continue
+		}
+
+		value, err := it.Value()
+		if err != nil {
+			return multierr.Append(err, it.Close())
+		}
+
+		se := &proto.StorageEntry{}
+		if err = deserialize(value, se); err != nil {
+			return multierr.Append(err, it.Close())
+		}
+		if err = fn(it.Key(), se); err != nil {
+			return multierr.Append(err, it.Close())
+		}
+	}
+	return it.Close()
+}
+
+func (i *inspector) Get(key string) (*proto.StorageEntry, error) {
+	_, value, closer, err := i.p.Get(key, ComparisonEqual)
+	if err != nil {
+		return nil, err
+	}
+
+	se := &proto.StorageEntry{}
+	if err = multierr.Append(
+		deserialize(value, se),
+		closer.Close(),
+	); err != nil {
+		return nil, err
+	}
+	return se, nil
+}
+
+func (i *inspector) InternalRecords(fn func(key string, value []byte) error) error {
+	// The internal keys can have any number of slashes, so they are not
+	// contiguous in the slash-aware order
+	it, err := i.p.RangeScan("", "")
+	if err != nil {
+		return err
+	}
+
+	for ; it.Valid(); it.Next() {
+		if !strings.HasPrefix(it.Key(), common.InternalKeyPrefix) {
+			continue
+		}
+
+		value, err := it.Value()
+		if err != nil {
+			return multierr.Append(err, it.Close())
+		}
+		if err = fn(it.Key(), value); err != nil {
+			return multierr.Append(err, it.Close())
+		}
+	}
+	return it.Close()
+}
+
+func (i *inspector) Markers() (*Markers, error) {
+	var err error
+	m := &Markers{}
+	if m.CommitEntryId, err = i.db.ReadCommitEntryId(); err != nil {
+		return nil, err
+	}
+	if m.Term, err = i.db.ReadTerm(); err != nil {
+		return nil, err
+	}
+	if m.LastVersionId, err = i.db.readLastVersionId(); err != nil {
+		return nil, err
+	}
+	if m.ShardSplit, err = i.db.ShardSplit(); err != nil {
+		return nil, err
+	}
+	if m.UnappliableEntry, err = i.db.UnappliableEntry(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (i *inspector) Stats() (*Stats, error) {
+	levels, err := i.p.db.SSTables(pebble.WithProperties())
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		DiskUsage:          i.p.DiskUsage(),
+		FormatMajorVersion: uint64(i.p.db.FormatMajorVersion()),
+	}
+	for level, tables := range levels {
+		ls := LevelStats{Level: level}
+		for _, table := range tables {
+			ls.Files++
+			ls.Size += int64(table.Size)
+
+			// Each deletion hides a previous entry of the key
+			stats.EstimatedKeys += int64(table.Properties.NumEntries) - 2*int64(table.Properties.NumDeletions)
+		}
+		stats.Levels = append(stats.Levels, ls)
+	}
+	stats.EstimatedKeys = max(stats.EstimatedKeys, 0)
+	return stats, nil
+}
+
+func (i *inspector) Close() error {
+	return i.p.Close()
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+func TestInspector(t *testing.T) {
+	dataDir := t.TempDir()
+	factory, err := NewPebbleKVFactory(&FactoryOptions{DataDir: dataDir})
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	for i := int64(0); i < 10; i++ {
+		_, err = db.ProcessWrite(&proto.WriteRequest{
+			Puts: []*proto.PutRequest{{Key: fmt.Sprintf("key-%d", i), Value: []byte(fmt.Sprintf("value-%d", i))}},
+		}, 3, i, 1000+uint64(i), NoOpCallback)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, db.UpdateTerm(4))
+
+	// The database cannot be inspected while it's in use
+	i, err := NewInspector(dataDir, common.DefaultNamespace, 1)
+	assert.ErrorIs(t, err, ErrDbLocked)
+	assert.Nil(t, i)
+	assert.NoError(t, db.Close())
+
+	i, err = NewInspector(dataDir, common.DefaultNamespace, 1)
+	assert.NoError(t, err)
+
+	var keys []string
+	assert.NoError(t, i.Records("key-2", "key-5", func(key string, se *proto.StorageEntry) error {
+		keys = append(keys, key)
+		assert.Equal(t, "value-"+key[4:], string(se.Value))
+		return nil
+	}))
+	assert.Equal(t, []string{"key-2", "key-3", "key-4"}, keys)
+
+	se, err := i.Get("key-7")
+	assert.NoError(t, err)
+	assert.Equal(t, "value-7", string(se.Value))
+	assert.EqualValues(t, 7, se.VersionId)
+	assert.EqualValues(t, 1007, se.CreationTimestamp)
+
+	_, err = i.Get("non-existing")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	markers, err := i.Markers()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, markers.CommitEntryId.Term)
+	assert.EqualValues(t, 9, markers.CommitEntryId.Offset)
+	assert.EqualValues(t, 4, markers.Term)
+	assert.EqualValues(t, 9, markers.LastVersionId)
+	assert.Nil(t, markers.ShardSplit)
+	assert.Nil(t, markers.UnappliableEntry)
+
+	internalKeys := map[string]bool{}
+	assert.NoError(t, i.InternalRecords(func(key string, _ []byte) error {
+		internalKeys[key] = true
+		return nil
+	}))
+	assert.True(t, internalKeys[commitOffsetKey])
+	assert.True(t, internalKeys[termKey])
+
+	stats, err := i.Stats()
+	assert.NoError(t, err)
+	assert.Positive(t, stats.DiskUsage)
+	assert.GreaterOrEqual(t, stats.EstimatedKeys, int64(10))
+
+	// The database cannot be opened while it's inspected
+	_, err = NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.Error(t, err)
+	assert.NoError(t, i.Close())
+
+	db, err = NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestInspector_NotFound(t *testing.T) {
+	i, err := NewInspector(t.TempDir(), common.DefaultNamespace, 1)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Nil(t, i)
+}
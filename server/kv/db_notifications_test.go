@@ -41,7 +41,7 @@ func TestDB_Notifications(t *testing.T) {
 			Key:   "a",
 			Value: []byte("0"),
 		}},
-	}, 0, t0, NoOpCallback)
+	}, 0, 0, t0, NoOpCallback)
 
 	notifications, err := db.ReadNextNotifications(context.Background(), 0)
 	assert.NoError(t, err)
@@ -63,7 +63,7 @@ func TestDB_Notifications(t *testing.T) {
 			Key:   "a",
 			Value: []byte("1"),
 		}},
-	}, 1, t1, NoOpCallback)
+	}, 0, 1, t1, NoOpCallback)
 
 	t2 := now()
 	wr2, _ := db.ProcessWrite(&proto.WriteRequest{
@@ -71,7 +71,7 @@ func TestDB_Notifications(t *testing.T) {
 			Key:   "b",
 			Value: []byte("0"),
 		}},
-	}, 2, t2, NoOpCallback)
+	}, 0, 2, t2, NoOpCallback)
 
 	notifications, err = db.ReadNextNotifications(context.Background(), 1)
 	assert.NoError(t, err)
@@ -110,7 +110,7 @@ func TestDB_Notifications(t *testing.T) {
 		Deletes: []*proto.DeleteRequest{{
 			Key: "a",
 		}},
-	}, 3, t3, NoOpCallback)
+	}, 0, 3, t3, NoOpCallback)
 
 	notifications, err = db.ReadNextNotifications(context.Background(), 3)
 	assert.NoError(t, err)
@@ -145,7 +145,7 @@ func TestDB_Notifications(t *testing.T) {
 			Key:   "x1",
 			Value: []byte("1"),
 		}},
-	}, 4, t4, NoOpCallback)
+	}, 0, 4, t4, NoOpCallback)
 
 	notifications, err = db.ReadNextNotifications(context.Background(), 4)
 	assert.NoError(t, err)
@@ -177,7 +177,7 @@ func TestDB_NotificationsCancelWait(t *testing.T) {
 			Key:   "a",
 			Value: []byte("0"),
 		}},
-	}, 0, t0, NoOpCallback)
+	}, 0, 0, t0, NoOpCallback)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
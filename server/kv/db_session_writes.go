@@ -0,0 +1,144 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+const sessionWritesKeyPrefix = common.InternalKeyPrefix + "session-writes"
+
+// MaxRetainedSessionWrites is the number of responses retained for each
+// session. A client must not have more requests in flight within a session,
+// otherwise a retry could find its response already discarded.
+//
+// The sequences of the requests must be strictly increasing within the
+// session, in the order they reach the leader. Since the requests with a
+// lower sequence than the last applied one are never applied, the retained
+// responses cover all the sequences applied since the oldest of them.
+const MaxRetainedSessionWrites = 64
+
+// SessionWritesKey is the key of the responses retained for the session. The
+// record must be deleted together with the session.
+func SessionWritesKey(sessionId int64) string {
+	return fmt.Sprintf("%s/%016x", sessionWritesKeyPrefix, sessionId)
+}
+
+// Apply the write request only if it wasn't already applied within its
+// session. The responses are part of the replicated state, so a retry gets
+// the original response on any replica that becomes the leader.
+func (d *db) applyWriteRequestOnce(b *proto.WriteRequest, batch WriteBatch, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) (*notifications, *proto.WriteResponse, error) {
+	if b.SessionId == nil || b.Sequence == nil {
+		return d.applyWriteRequest(b, batch, commitOffset, timestamp, updateOperationCallback)
+	}
+
+	status, err := updateOperationCallback.OnSessionWrite(batch, *b.SessionId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status != proto.Status_OK {
+		return newNotifications(d.shardId, commitOffset, timestamp), failedWriteResponse(b, status), nil
+	}
+
+	key := SessionWritesKey(*b.SessionId)
+	writes, err := readSessionWrites(batch, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if n := len(writes.Writes); n > 0 && *b.Sequence <= writes.Writes[n-1].Sequence {
+		return newNotifications(d.shardId, commitOffset, timestamp), retriedWriteResponse(b, writes), nil
+	}
+
+	notifications, res, err := d.applyWriteRequest(b, batch, commitOffset, timestamp, updateOperationCallback)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	writes.Writes = append(writes.Writes, &proto.SessionWrite{Sequence: *b.Sequence, Response: res})
+	if len(writes.Writes) > MaxRetainedSessionWrites {
+		writes.Writes = writes.Writes[len(writes.Writes)-MaxRetainedSessionWrites:]
+	}
+
+	value, err := writes.MarshalVT()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err = d.applyPut(batch, nil, &proto.PutRequest{
+		Key:   key,
+		Value: value,
+	}, timestamp, NoOpCallback, true); err != nil {
+		return nil, nil, err
+	}
+
+	return notifications, res, nil
+}
+
+// The response to a request whose sequence is not higher than the last one
+// applied in the session.
+func retriedWriteResponse(b *proto.WriteRequest, writes *proto.SessionWrites) *proto.WriteResponse {
+	for _, w := range writes.Writes {
+		if w.Sequence == *b.Sequence {
+			return w.Response
+		}
+	}
+
+	if len(writes.Writes) < MaxRetainedSessionWrites || *b.Sequence > writes.Writes[0].Sequence {
+		// All the sequences applied from the oldest retained one are still
+		// retained, so the request was never applied: it was sent out of order
+		return failedWriteResponse(b, proto.Status_INVALID_SEQUENCE)
+	}
+
+	// The request was applied before the oldest retained one
+	return failedWriteResponse(b, proto.Status_DUPLICATE_REQUEST)
+}
+
+func readSessionWrites(batch WriteBatch, key string) (*proto.SessionWrites, error) {
+	writes := &proto.SessionWrites{}
+
+	se, err := GetStorageEntry(batch, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return writes, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer se.ReturnToVTPool()
+
+	if err = writes.UnmarshalVT(se.Value); err != nil {
+		return nil, errors.Wrap(err, "failed to deserialize session writes")
+	}
+	return writes, nil
+}
+
+// The response to a request of which none of the operations was applied.
+func failedWriteResponse(b *proto.WriteRequest, status proto.Status) *proto.WriteResponse {
+	res := &proto.WriteResponse{}
+	for range b.Puts {
+		res.Puts = append(res.Puts, &proto.PutResponse{Status: status})
+	}
+	for range b.Deletes {
+		res.Deletes = append(res.Deletes, &proto.DeleteResponse{Status: status})
+	}
+	for range b.DeleteRanges {
+		res.DeleteRanges = append(res.DeleteRanges, &proto.DeleteRangeResponse{Status: status})
+	}
+	return res
+}
@@ -0,0 +1,217 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	pb "google.golang.org/protobuf/proto"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+// The parent shard keeps the split that it went through, so that it can
+// report it to the clients, and to the leaders elected after it.
+const shardSplitKey = common.InternalKeyPrefix + "shard-split"
+
+// ProcessShardSplit applies the split entry of a parent shard.
+//
+// The children shards receive the full log of the parent, and when they
+// apply the split they delete all the records that fall outside their
+// own hash range. The parent shard instead keeps all the records and only
+// persists the split, after which it must not accept any more operations.
+func (d *db) ProcessShardSplit(split *proto.ShardSplit, commitTerm int64, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) error {
+	timer := d.batchWriteLatencyHisto.Timer()
+	defer timer.Done()
+
+	batch := d.kv.NewWriteBatch()
+	if err := d.commitShardSplit(split, batch, commitTerm, commitOffset, timestamp, updateOperationCallback); err != nil {
+		return multierr.Append(err, batch.Close())
+	}
+
+	d.notificationsTracker.UpdatedCommitOffset(commitOffset)
+	return batch.Close()
+}
+
+func (d *db) commitShardSplit(split *proto.ShardSplit, batch WriteBatch, commitTerm int64, commitOffset int64, timestamp uint64, updateOperationCallback UpdateOperationCallback) error {
+	if hashRange, isChild := split.Children[d.shardId]; isChild {
+		if err := d.deleteRecordsOutsideRange(hashRange, updateOperationCallback); err != nil {
+			return err
+		}
+	} else {
+		value, err := pb.MarshalOptions{Deterministic: true}.Marshal(split)
+		if err != nil {
+			return err
+		}
+
+		if err = batch.Put(shardSplitKey, value); err != nil {
+			return err
+		}
+	}
+
+	if err := d.addCommitEntryId(batch, commitTerm, commitOffset, timestamp); err != nil {
+		return err
+	}
+
+	if err := d.addASCIILong(commitLastVersionIdKey, d.versionIdTracker.Load(), batch, timestamp); err != nil {
+		return err
+	}
+
+	// An empty notifications batch, to keep the notifications aligned with
+	// the log offsets
+	if err := d.addNotifications(batch, newNotifications(d.shardId, commitOffset, timestamp)); err != nil {
+		return err
+	}
+
+	return batch.Commit()
+}
+
+// The records outside the hash range of a child shard are deleted in
+// batches that are committed when they reach either limit, so that the
+// memory stays bounded regardless of the size of the shard.
+const (
+	maxSplitDeleteBatchCount = 1000
+	maxSplitDeleteBatchSize  = 4 * 1024 * 1024
+)
+
+// deleteRecordsOutsideRange commits the deletions before the split itself.
+// If the node crashes in the middle, the split is applied again and the
+// records that were already deleted are not found anymore.
+func (d *db) deleteRecordsOutsideRange(hashRange *proto.Int32HashRange, updateOperationCallback UpdateOperationCallback) error {
+	it, err := d.kv.RangeScan("", "")
+	if err != nil {
+		return err
+	}
+
+	se := proto.StorageEntryFromVTPool()
+	defer se.ReturnToVTPool()
+
+	batch := d.kv.NewWriteBatch()
+	count := 0
+	for ; it.Valid(); it.Next() {
+		key := it.Key()
+		if strings.HasPrefix(key, common.InternalKeyPrefix) {
+			continue
+		}
+
+		var outside bool
+		if outside, err = isOutsideRange(key, it, se, hashRange); err != nil {
+			return multierr.Combine(err, batch.Close(), it.Close())
+		}
+		if !outside {
+			continue
+		}
+
+		if _, err = d.applyDelete(batch, nil, &proto.DeleteRequest{Key: key}, updateOperationCallback); err != nil {
+			return multierr.Combine(err, batch.Close(), it.Close())
+		}
+		count++
+
+		if batch.Count() >= maxSplitDeleteBatchCount || batch.Size() >= maxSplitDeleteBatchSize {
+			if err = multierr.Append(batch.Commit(), batch.Close()); err != nil {
+				return multierr.Append(err, it.Close())
+			}
+			batch = d.kv.NewWriteBatch()
+		}
+	}
+
+	if err = multierr.Combine(batch.Commit(), batch.Close(), it.Close()); err != nil {
+		return err
+	}
+
+	d.log.Info(
+		"Deleted the records outside the hash range of the shard",
+		slog.Int("count", count),
+		slog.Any("hash-range", hashRange),
+	)
+	return nil
+}
+
+func isOutsideRange(key string, it KeyValueIterator, se *proto.StorageEntry, hashRange *proto.Int32HashRange) (bool, error) {
+	value, err := it.Value()
+	if err != nil {
+		return false, err
+	}
+
+	se.ResetVT()
+	if err = deserialize(value, se); err != nil {
+		return false, err
+	}
+
+	// The records are placed by their partition key, when they have one
+	hash := common.Xxh332(key)
+	if se.PartitionKey != nil {
+		hash = common.Xxh332(*se.PartitionKey)
+	}
+
+	return hash < hashRange.MinHashInclusive || hash > hashRange.MaxHashInclusive, nil
+}
+
+// ShardSplit returns the split that the shard went through, or nil if the
+// shard was not split.
+func (d *db) ShardSplit() (*proto.ShardSplit, error) {
+	_, value, closer, err := d.kv.Get(shardSplitKey, ComparisonEqual)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil //nolint:nilnil
+	} else if err != nil {
+		return nil, err
+	}
+
+	split := &proto.ShardSplit{}
+	if err = multierr.Append(
+		pb.Unmarshal(value, split),
+		closer.Close(),
+	); err != nil {
+		return nil, err
+	}
+	return split, nil
+}
+
+// A child shard can be initialized with a snapshot of the parent taken
+// after the split was applied. In that case we need to do the same cleanup
+// that is done when the split entry gets applied.
+func (d *db) completeShardSplit() error {
+	split, err := d.ShardSplit()
+	if err != nil || split == nil {
+		return err
+	}
+
+	hashRange, isChild := split.Children[d.shardId]
+	if !isChild {
+		return nil
+	}
+
+	// The session shadow keys of the deleted ephemeral records are left
+	// behind, which is harmless, since deleting a missing record is a no-op
+	if err = d.deleteRecordsOutsideRange(hashRange, NoOpCallback); err != nil {
+		return err
+	}
+
+	// The split is only removed once all the records are deleted, so that
+	// the cleanup is resumed if the node crashes in the middle
+	batch := d.kv.NewWriteBatch()
+	if err = batch.Delete(shardSplitKey); err != nil {
+		return multierr.Append(err, batch.Close())
+	}
+
+	if err = batch.Commit(); err != nil {
+		return multierr.Append(err, batch.Close())
+	}
+	return batch.Close()
+}
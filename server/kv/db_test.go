@@ -15,9 +15,16 @@
 package kv
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	pb "google.golang.org/protobuf/proto"
 
@@ -80,7 +87,7 @@ func TestDBSimple(t *testing.T) {
 		},
 	}
 
-	res, err := db.ProcessWrite(req, 0, 0, NoOpCallback)
+	res, err := db.ProcessWrite(req, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 5, len(res.Puts))
@@ -183,7 +190,7 @@ func TestDBSameKeyMutations(t *testing.T) {
 	}
 
 	t0 := now()
-	writeRes, err := db.ProcessWrite(writeReq, 0, t0, NoOpCallback)
+	writeRes, err := db.ProcessWrite(writeReq, 0, 0, t0, NoOpCallback)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, len(writeRes.Puts))
@@ -215,7 +222,7 @@ func TestDBSameKeyMutations(t *testing.T) {
 	}
 
 	t1 := now()
-	writeRes, err = db.ProcessWrite(writeReq, 1, t1, NoOpCallback)
+	writeRes, err = db.ProcessWrite(writeReq, 0, 1, t1, NoOpCallback)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 2, len(writeRes.Puts))
@@ -299,7 +306,7 @@ func TestDBList(t *testing.T) {
 		}},
 	}
 
-	writeRes, err := db.ProcessWrite(writeReq, wal.InvalidOffset, now(), NoOpCallback)
+	writeRes, err := db.ProcessWrite(writeReq, 0, wal.InvalidOffset, now(), NoOpCallback)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 5, len(writeRes.Puts))
@@ -338,6 +345,73 @@ func TestDBList(t *testing.T) {
 	assert.NoError(t, factory.Close())
 }
 
+func TestDBListLimit(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	writeReq := &proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "/a/0", Value: []byte("0")},
+			{Key: "/a/1", Value: []byte("1")},
+			{Key: "/a/2", Value: []byte("2")},
+			{Key: "/a/3", Value: []byte("3")},
+			{Key: "/a/4", Value: []byte("4")},
+		},
+	}
+	_, err = db.ProcessWrite(writeReq, 0, 0, now(), NoOpCallback)
+	assert.NoError(t, err)
+
+	// The range straddles deleted keys, which are not counted in the limit
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Deletes: []*proto.DeleteRequest{{Key: "/a/1"}, {Key: "/a/2"}},
+	}, 0, 1, now(), NoOpCallback)
+	assert.NoError(t, err)
+
+	limit := func(l uint64) *uint64 { return &l }
+
+	assert.Equal(t, []string{"/a/0", "/a/3", "/a/4"}, keyIteratorToSlice(db.List(&proto.ListRequest{
+		StartInclusive: "/a/",
+		EndExclusive:   "/a//",
+	})))
+	assert.Equal(t, []string{"/a/0", "/a/3"}, keyIteratorToSlice(db.List(&proto.ListRequest{
+		StartInclusive: "/a/",
+		EndExclusive:   "/a//",
+		Limit:          limit(2),
+	})))
+	assert.Equal(t, []string{"/a/0", "/a/3", "/a/4"}, keyIteratorToSlice(db.List(&proto.ListRequest{
+		StartInclusive: "/a/",
+		EndExclusive:   "/a//",
+		Limit:          limit(10),
+	})))
+	assert.Empty(t, keyIteratorToSlice(db.List(&proto.ListRequest{
+		StartInclusive: "/a/",
+		EndExclusive:   "/a//",
+		Limit:          limit(0),
+	})))
+
+	// Empty range and range with only deleted keys
+	assert.Empty(t, keyIteratorToSlice(db.List(&proto.ListRequest{
+		StartInclusive: "/a/3",
+		EndExclusive:   "/a/3",
+	})))
+	assert.Empty(t, keyIteratorToSlice(db.List(&proto.ListRequest{
+		StartInclusive: "/a/1",
+		EndExclusive:   "/a/3",
+		Limit:          limit(1),
+	})))
+
+	assert.Equal(t, []string{"/a/3"}, rangeScanIteratorToSlice(db.RangeScan(&proto.RangeScanRequest{
+		StartInclusive: "/a/1",
+		EndExclusive:   "/a//",
+		Limit:          limit(1),
+	})))
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
 func keyIteratorToSlice(it KeyIterator, err error) []string {
 	assert.NoError(nil, err)
 	var keys []string
@@ -373,7 +447,7 @@ func TestDBDeleteRange(t *testing.T) {
 		}},
 	}
 
-	_, err = db.ProcessWrite(writeReq, wal.InvalidOffset, 0, NoOpCallback)
+	_, err = db.ProcessWrite(writeReq, 0, wal.InvalidOffset, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	writeReq = &proto.WriteRequest{
@@ -386,7 +460,7 @@ func TestDBDeleteRange(t *testing.T) {
 		}},
 	}
 
-	writeRes, err := db.ProcessWrite(writeReq, wal.InvalidOffset, 0, NoOpCallback)
+	writeRes, err := db.ProcessWrite(writeReq, 0, wal.InvalidOffset, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	keys := make([]string, 0)
@@ -415,6 +489,68 @@ func TestDBDeleteRange(t *testing.T) {
 	assert.NoError(t, factory.Close())
 }
 
+func TestDBDeleteRangeMixedBatch(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 1*time.Hour, common.SystemClock)
+	assert.NoError(t, err)
+
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "/a/0", Value: []byte("0")},
+			{Key: "/a/1", Value: []byte("1")},
+			{Key: "/b/0", Value: []byte("0")},
+		},
+	}, 0, 0, now(), NoOpCallback)
+	assert.NoError(t, err)
+
+	// The range delete is applied after the other operations of the same
+	// request, in a single batch
+	writeRes, err := db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "/a/2", Value: []byte("2")},
+			{Key: "/c/0", Value: []byte("0")},
+		},
+		Deletes: []*proto.DeleteRequest{{Key: "/b/0"}},
+		DeleteRanges: []*proto.DeleteRangeRequest{{
+			StartInclusive: "/a/",
+			EndExclusive:   "/a//",
+		}},
+	}, 0, 1, now(), NoOpCallback)
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, writeRes.Puts[0].Status)
+	assert.Equal(t, proto.Status_OK, writeRes.Puts[1].Status)
+	assert.Equal(t, proto.Status_OK, writeRes.Deletes[0].Status)
+	assert.Equal(t, proto.Status_OK, writeRes.DeleteRanges[0].Status)
+
+	assert.Empty(t, keyIteratorToSlice(db.List(&proto.ListRequest{
+		StartInclusive: "/a/",
+		EndExclusive:   "/b//",
+	})))
+	assert.Equal(t, []string{"/c/0"}, keyIteratorToSlice(db.List(&proto.ListRequest{
+		StartInclusive: "/c/",
+		EndExclusive:   "/c//",
+	})))
+
+	commitOffset, err := db.ReadCommitOffset()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, commitOffset)
+
+	// All the records in the range are notified as deleted, in the same batch
+	notifications, err := db.ReadNextNotifications(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(notifications))
+	nb := notifications[0]
+	assert.Equal(t, 5, len(nb.Notifications))
+	for _, key := range []string{"/a/0", "/a/1", "/a/2", "/b/0"} {
+		assert.Equal(t, proto.NotificationType_KEY_DELETED, nb.Notifications[key].Type, key)
+	}
+	assert.Equal(t, proto.NotificationType_KEY_CREATED, nb.Notifications["/c/0"].Type)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
 func TestDB_ReadCommitOffset(t *testing.T) {
 	offset := int64(13)
 
@@ -433,7 +569,7 @@ func TestDB_ReadCommitOffset(t *testing.T) {
 			Value: []byte("a"),
 		}},
 	}
-	_, err = db.ProcessWrite(writeReq, offset, 0, NoOpCallback)
+	_, err = db.ProcessWrite(writeReq, 0, offset, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	commitOffset, err = db.ReadCommitOffset()
@@ -444,6 +580,41 @@ func TestDB_ReadCommitOffset(t *testing.T) {
 	assert.NoError(t, factory.Close())
 }
 
+func TestDB_ReadCommitEntryId(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	entryId, err := db.ReadCommitEntryId()
+	assert.NoError(t, err)
+	assert.Equal(t, wal.InvalidTerm, entryId.Term)
+	assert.Equal(t, wal.InvalidOffset, entryId.Offset)
+
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{
+			Key:   "a",
+			Value: []byte("a"),
+		}},
+	}, 3, 13, 0, NoOpCallback)
+	assert.NoError(t, err)
+
+	entryId, err = db.ReadCommitEntryId()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, entryId.Term)
+	assert.EqualValues(t, 13, entryId.Offset)
+
+	assert.NoError(t, db.ProcessShardSplit(testSplit, 4, 14, 0, NoOpCallback))
+
+	entryId, err = db.ReadCommitEntryId()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, entryId.Term)
+	assert.EqualValues(t, 14, entryId.Offset)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
 func TestDb_UpdateTerm(t *testing.T) {
 	factory, err := NewPebbleKVFactory(testKVOptions)
 	assert.NoError(t, err)
@@ -488,7 +659,7 @@ func TestDB_Delete(t *testing.T) {
 			Value: []byte("a"),
 		}},
 	}
-	_, err = db.ProcessWrite(writeReq, offset, 0, NoOpCallback)
+	_, err = db.ProcessWrite(writeReq, 0, offset, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	assert.NoError(t, db.Delete())
@@ -507,6 +678,240 @@ func TestDB_Delete(t *testing.T) {
 	assert.NoError(t, factory.Close())
 }
 
+func TestDB_ExpectedValue(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	writeRes, err := db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: []byte("a0")},
+			{Key: "d", Value: []byte("d0")},
+		},
+	}, 0, 0, now(), NoOpCallback)
+	assert.NoError(t, err)
+	a0 := writeRes.Puts[0].Version
+
+	// The conditions are evaluated against the writes that precede them in
+	// the same request
+	writeRes, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: []byte("a1"), ExpectedValue: []byte("a0")},
+			{Key: "a", Value: []byte("a2"), ExpectedValue: []byte("a0")},
+			{Key: "b", Value: []byte("b0"), ExpectedValue: []byte("b0")},
+			{Key: "c", Value: []byte("c0")},
+			{Key: "c", Value: []byte("c1"), ExpectedValue: []byte("c0")},
+		},
+		Deletes: []*proto.DeleteRequest{
+			{Key: "c", ExpectedValue: []byte("c0")},
+			{Key: "b", ExpectedValue: []byte("b0")},
+			{Key: "d", ExpectedValue: []byte("d0")},
+			{Key: "d", ExpectedValue: []byte("d0")},
+		},
+	}, 0, 1, now(), NoOpCallback)
+	assert.NoError(t, err)
+
+	a1 := writeRes.Puts[0].Version
+	assert.Equal(t, proto.Status_OK, writeRes.Puts[0].Status)
+	assert.Equal(t, int64(1), a1.ModificationsCount)
+
+	// The stored version is returned on mismatch
+	assert.Equal(t, proto.Status_UNEXPECTED_VALUE, writeRes.Puts[1].Status)
+	assert.Equal(t, a1.VersionId, writeRes.Puts[1].Version.VersionId)
+	assert.Equal(t, a1.ModificationsCount, writeRes.Puts[1].Version.ModificationsCount)
+
+	// A record that does not exist never matches
+	assert.Equal(t, proto.Status_UNEXPECTED_VALUE, writeRes.Puts[2].Status)
+	assert.Nil(t, writeRes.Puts[2].Version)
+
+	assert.Equal(t, proto.Status_OK, writeRes.Puts[3].Status)
+	assert.Equal(t, proto.Status_OK, writeRes.Puts[4].Status)
+	c1 := writeRes.Puts[4].Version
+
+	assert.Equal(t, proto.Status_UNEXPECTED_VALUE, writeRes.Deletes[0].Status)
+	assert.Equal(t, c1.VersionId, writeRes.Deletes[0].Version.VersionId)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, writeRes.Deletes[1].Status)
+	assert.Nil(t, writeRes.Deletes[1].Version)
+	assert.Equal(t, proto.Status_OK, writeRes.Deletes[2].Status)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, writeRes.Deletes[3].Status)
+
+	// When both the version and the value are expected, both must match
+	writeRes, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: []byte("a2"), ExpectedVersionId: &a0.VersionId, ExpectedValue: []byte("a1")},
+			{Key: "a", Value: []byte("a2"), ExpectedVersionId: &a1.VersionId, ExpectedValue: []byte("a0")},
+			{Key: "a", Value: []byte("a2"), ExpectedVersionId: &a1.VersionId, ExpectedValue: []byte("a1")},
+		},
+		Deletes: []*proto.DeleteRequest{
+			{Key: "c", ExpectedVersionId: &a0.VersionId, ExpectedValue: []byte("c1")},
+			{Key: "c", ExpectedVersionId: &c1.VersionId, ExpectedValue: []byte("c0")},
+			{Key: "c", ExpectedVersionId: &c1.VersionId, ExpectedValue: []byte("c1")},
+		},
+	}, 0, 2, now(), NoOpCallback)
+	assert.NoError(t, err)
+
+	assert.Equal(t, proto.Status_UNEXPECTED_VERSION_ID, writeRes.Puts[0].Status)
+	assert.Equal(t, proto.Status_UNEXPECTED_VALUE, writeRes.Puts[1].Status)
+	assert.Equal(t, proto.Status_OK, writeRes.Puts[2].Status)
+	assert.Equal(t, proto.Status_UNEXPECTED_VERSION_ID, writeRes.Deletes[0].Status)
+	assert.Equal(t, proto.Status_UNEXPECTED_VALUE, writeRes.Deletes[1].Status)
+	assert.Equal(t, proto.Status_OK, writeRes.Deletes[2].Status)
+
+	getRes, err := db.Get(&proto.GetRequest{Key: "a", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, getRes.Status)
+	assert.Equal(t, "a2", string(getRes.Value))
+
+	for _, key := range []string{"b", "c", "d"} {
+		getRes, err = db.Get(&proto.GetRequest{Key: key})
+		assert.NoError(t, err)
+		assert.Equal(t, proto.Status_KEY_NOT_FOUND, getRes.Status, key)
+	}
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestDB_ProcessWriteDeterminism(t *testing.T) {
+	requests := []*proto.WriteRequest{{
+		Puts: []*proto.PutRequest{
+			{Key: "/a/0", Value: []byte("0")},
+			{Key: "/a/1", Value: []byte("1"), ExpireAfterMs: pb.Uint64(10)},
+			{Key: "/a/1", Value: []byte("2"), ExpectedVersionId: pb.Int64(-1)},
+			{Key: "/b", Value: []byte("0"), PartitionKey: pb.String("x"), SequenceKeyDelta: []uint64{1}},
+		},
+	}, {
+		Puts: []*proto.PutRequest{
+			{Key: "/a/0", Value: []byte("3"), ExpectedValue: []byte("0")},
+			{Key: "/a/0", Value: []byte("4"), ExpectedValue: []byte("0")},
+			{Key: "/a/2", Value: []byte("5"), ExpectedVersionId: pb.Int64(8)},
+		},
+		Deletes: []*proto.DeleteRequest{
+			{Key: "/a/1", ExpectedVersionId: pb.Int64(0)},
+			{Key: "/a/3"},
+		},
+	}, {
+		Puts: []*proto.PutRequest{
+			{Key: "/a/4", Value: []byte("6")},
+			{Key: "/b", Value: []byte("7"), PartitionKey: pb.String("x"), SequenceKeyDelta: []uint64{2}},
+		},
+		DeleteRanges: []*proto.DeleteRangeRequest{
+			{StartInclusive: "/a/0", EndExclusive: "/a/2"},
+		},
+	}}
+
+	// Two replicas with the same history must produce the same responses
+	// and the same stored bytes
+	var responses [2][]*proto.WriteResponse
+	var contents [2][]string
+	for i := 0; i < 2; i++ {
+		factory, err := NewPebbleKVFactory(testKVOptions)
+		assert.NoError(t, err)
+		db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+		assert.NoError(t, err)
+
+		for offset, req := range requests {
+			res, err := db.ProcessWrite(pb.Clone(req).(*proto.WriteRequest), 0, int64(offset), uint64(1000+offset), NoOpCallback)
+			assert.NoError(t, err)
+			responses[i] = append(responses[i], res)
+		}
+
+		contents[i] = rawContent(t, db)
+
+		assert.NoError(t, db.Close())
+		assert.NoError(t, factory.Close())
+	}
+
+	for i := range requests {
+		assert.True(t, pb.Equal(responses[0][i], responses[1][i]), "response %d", i)
+	}
+	assert.NotEmpty(t, contents[0])
+	assert.Equal(t, contents[0], contents[1])
+
+	// The failed conditions are only reported in the responses
+	assert.Equal(t, proto.Status_UNEXPECTED_VERSION_ID, responses[0][0].Puts[2].Status)
+	assert.Equal(t, proto.Status_OK, responses[0][1].Puts[0].Status)
+	assert.Equal(t, proto.Status_UNEXPECTED_VALUE, responses[0][1].Puts[1].Status)
+	assert.Equal(t, proto.Status_UNEXPECTED_VERSION_ID, responses[0][1].Puts[2].Status)
+	assert.Equal(t, proto.Status_UNEXPECTED_VERSION_ID, responses[0][1].Deletes[0].Status)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, responses[0][1].Deletes[1].Status)
+}
+
+type failingUpdateCallback struct {
+	key string
+}
+
+func (c *failingUpdateCallback) OnPut(_ WriteBatch, req *proto.PutRequest, _ *proto.StorageEntry) (proto.Status, error) {
+	if req.Key == c.key {
+		return proto.Status_OK, errors.New("failed to update")
+	}
+	return proto.Status_OK, nil
+}
+
+func (*failingUpdateCallback) OnDelete(WriteBatch, string) error {
+	return nil
+}
+
+func (*failingUpdateCallback) OnSessionWrite(WriteBatch, int64) (proto.Status, error) {
+	return proto.Status_OK, nil
+}
+
+func TestDB_ProcessWriteFailureIsAtomic(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "a", Value: []byte("0")}},
+	}, 0, 0, now(), NoOpCallback)
+	assert.NoError(t, err)
+	before := rawContent(t, db)
+
+	req := &proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "b", Value: []byte("1")},
+			{Key: "c", Value: []byte("2")},
+		},
+		Deletes: []*proto.DeleteRequest{{Key: "a"}},
+	}
+	_, err = db.ProcessWrite(req, 0, 1, now(), &failingUpdateCallback{key: "c"})
+	assert.Error(t, err)
+
+	// None of the operations, nor the commit offset, were applied
+	assert.Equal(t, before, rawContent(t, db))
+	commitOffset, err := db.ReadCommitOffset()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, commitOffset)
+
+	// Retrying the same entry assigns the same version ids
+	res, err := db.ProcessWrite(req, 0, 1, now(), NoOpCallback)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, res.Puts[0].Version.VersionId)
+	assert.EqualValues(t, 2, res.Puts[1].Version.VersionId)
+	assert.Equal(t, proto.Status_OK, res.Deletes[0].Status)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+// Dump all the keys and values stored in the database, including the
+// internal ones.
+func rawContent(t *testing.T, d DB) []string {
+	t.Helper()
+
+	it, err := d.(*db).kv.(*Pebble).db.NewIter(nil)
+	assert.NoError(t, err)
+
+	var content []string
+	for it.First(); it.Valid(); it.Next() {
+		content = append(content, fmt.Sprintf("%s=%x", it.Key(), it.Value()))
+	}
+	assert.NoError(t, it.Close())
+	return content
+}
+
 func TestDB_FloorCeiling(t *testing.T) {
 	factory, err := NewPebbleKVFactory(testKVOptions)
 	assert.NoError(t, err)
@@ -531,7 +936,7 @@ func TestDB_FloorCeiling(t *testing.T) {
 			Value: []byte("4"),
 		}},
 	}
-	_, err = db.ProcessWrite(writeReq, 0, 0, NoOpCallback)
+	_, err = db.ProcessWrite(writeReq, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	// ---------------------------------------------------------------
@@ -682,7 +1087,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Key:              "a",
 		Value:            []byte("0"),
 		SequenceKeyDelta: []uint64{3},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.ErrorIs(t, err, ErrMissingPartitionKey)
 
 	_, err = db.ProcessWrite(&proto.WriteRequest{Puts: []*proto.PutRequest{{
@@ -690,7 +1095,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Value:            []byte("0"),
 		PartitionKey:     pb.String("x"),
 		SequenceKeyDelta: []uint64{0},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.ErrorIs(t, err, ErrSequenceDeltaIsZero)
 
 	resp, err := db.ProcessWrite(&proto.WriteRequest{Puts: []*proto.PutRequest{{
@@ -699,7 +1104,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		PartitionKey:      pb.String("x"),
 		ExpectedVersionId: pb.Int64(1),
 		SequenceKeyDelta:  []uint64{5},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 	assert.Equal(t, proto.Status_UNEXPECTED_VERSION_ID, resp.GetPuts()[0].Status)
 
@@ -708,7 +1113,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Value:            []byte("0"),
 		PartitionKey:     pb.String("x"),
 		SequenceKeyDelta: []uint64{5},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 	assert.Equal(t, proto.Status_OK, resp.GetPuts()[0].Status)
 	assert.Equal(t, fmt.Sprintf("a-%020d", 5), resp.GetPuts()[0].GetKey())
@@ -718,7 +1123,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Value:            []byte("0"),
 		PartitionKey:     pb.String("x"),
 		SequenceKeyDelta: []uint64{3},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 	assert.Equal(t, proto.Status_OK, resp.GetPuts()[0].Status)
 	assert.Equal(t, fmt.Sprintf("a-%020d", 8), resp.GetPuts()[0].GetKey())
@@ -729,7 +1134,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Value:            []byte("0"),
 		PartitionKey:     pb.String("x"),
 		SequenceKeyDelta: []uint64{6, 9},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 	assert.Equal(t, proto.Status_OK, resp.GetPuts()[0].Status)
 	assert.Equal(t, fmt.Sprintf("a-%020d-%020d", 14, 9), resp.GetPuts()[0].GetKey())
@@ -740,7 +1145,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Value:            []byte("0"),
 		PartitionKey:     pb.String("x"),
 		SequenceKeyDelta: []uint64{2},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.ErrorIs(t, err, ErrMissingSequenceDeltas)
 
 	// Put bad existing suffix
@@ -748,7 +1153,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Key:          "b+xxxx",
 		Value:        []byte("0"),
 		PartitionKey: pb.String("x"),
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	resp, err = db.ProcessWrite(&proto.WriteRequest{Puts: []*proto.PutRequest{{
@@ -756,7 +1161,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Value:            []byte("0"),
 		PartitionKey:     pb.String("x"),
 		SequenceKeyDelta: []uint64{2},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 	assert.Equal(t, proto.Status_OK, resp.GetPuts()[0].Status)
 	assert.Equal(t, fmt.Sprintf("b-%020d", 2), resp.GetPuts()[0].GetKey())
@@ -765,7 +1170,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Key:          "c+.....",
 		Value:        []byte("0"),
 		PartitionKey: pb.String("x"),
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	resp, err = db.ProcessWrite(&proto.WriteRequest{Puts: []*proto.PutRequest{{
@@ -773,7 +1178,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Value:            []byte("0"),
 		PartitionKey:     pb.String("x"),
 		SequenceKeyDelta: []uint64{2},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 	assert.Equal(t, proto.Status_OK, resp.GetPuts()[0].Status)
 	assert.Equal(t, fmt.Sprintf("c-%020d", 2), resp.GetPuts()[0].GetKey())
@@ -784,7 +1189,7 @@ func TestDB_SequentialKeys(t *testing.T) {
 		Value:            []byte("0"),
 		PartitionKey:     pb.String("x"),
 		SequenceKeyDelta: []uint64{6, 9, 15},
-	}}}, 0, 0, NoOpCallback)
+	}}}, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 	assert.Equal(t, proto.Status_OK, resp.GetPuts()[0].Status)
 	assert.Equal(t, fmt.Sprintf("a-%020d-%020d-%020d", 20, 18, 15), resp.GetPuts()[0].GetKey())
@@ -827,7 +1232,7 @@ func TestDBRangeScan(t *testing.T) {
 		}},
 	}
 
-	writeRes, err := db.ProcessWrite(writeReq, wal.InvalidOffset, now(), NoOpCallback)
+	writeRes, err := db.ProcessWrite(writeReq, 0, wal.InvalidOffset, now(), NoOpCallback)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 5, len(writeRes.Puts))
@@ -889,7 +1294,7 @@ func TestDb_versionId(t *testing.T) {
 		},
 	}
 
-	res, err := db.ProcessWrite(req, 0, 0, NoOpCallback)
+	res, err := db.ProcessWrite(req, 0, 0, 0, NoOpCallback)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 3, len(res.Puts))
@@ -911,3 +1316,616 @@ func TestDb_versionId(t *testing.T) {
 	assert.NoError(t, db.Close())
 	assert.NoError(t, factory.Close())
 }
+
+func TestDB_ExpireAfter(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	clock := &common.MockedClock{}
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, clock)
+	assert.NoError(t, err)
+
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: []byte("a")},
+			{Key: "b", Value: []byte("b"), ExpireAfterMs: pb.Uint64(1000)},
+			{Key: "c", Value: []byte("c")},
+		},
+	}, 0, 0, 1000, NoOpCallback)
+	assert.NoError(t, err)
+
+	// Just before the expiration
+	clock.Set(1999)
+	res, err := db.Get(&proto.GetRequest{Key: "b", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, res.Status)
+	assert.Equal(t, "b", string(res.Value))
+
+	assert.Equal(t, []string{"a", "b", "c"}, listKeys(t, db, "a", "d"))
+
+	// The expiration is based on the write timestamp, not on the apply time
+	clock.Set(2000)
+	res, err = db.Get(&proto.GetRequest{Key: "b", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, res.Status)
+
+	assert.Equal(t, []string{"a", "c"}, listKeys(t, db, "a", "d"))
+
+	it, err := db.RangeScan(&proto.RangeScanRequest{StartInclusive: "a", EndExclusive: "d"})
+	assert.NoError(t, err)
+	var scanned []string
+	for ; it.Valid(); it.Next() {
+		gr, err := it.Value()
+		assert.NoError(t, err)
+		scanned = append(scanned, *gr.Key)
+	}
+	assert.NoError(t, it.Close())
+	assert.Equal(t, []string{"a", "c"}, scanned)
+
+	// Floor and ceiling searches skip the expired record
+	res, err = db.Get(&proto.GetRequest{Key: "b", ComparisonType: proto.KeyComparisonType_FLOOR})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, res.Status)
+	assert.Equal(t, "a", *res.Key)
+
+	res, err = db.Get(&proto.GetRequest{Key: "b", ComparisonType: proto.KeyComparisonType_CEILING})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, res.Status)
+	assert.Equal(t, "c", *res.Key)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestDB_ExpiredKeys(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	res, err := db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: []byte("a"), ExpireAfterMs: pb.Uint64(1000)},
+			{Key: "b", Value: []byte("b"), ExpireAfterMs: pb.Uint64(2000)},
+			{Key: "c", Value: []byte("c"), ExpireAfterMs: pb.Uint64(1000)},
+			{Key: "d", Value: []byte("d"), ExpireAfterMs: pb.Uint64(1000)},
+		},
+	}, 0, 0, 1000, NoOpCallback)
+	assert.NoError(t, err)
+
+	deletes, err := db.ExpiredKeys(1999, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, deletes)
+
+	// Overwriting "c" without expiration removes it from the index, while
+	// deleting "d" through a range leaves a stale index entry behind
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts:         []*proto.PutRequest{{Key: "c", Value: []byte("c")}},
+		DeleteRanges: []*proto.DeleteRangeRequest{{StartInclusive: "d", EndExclusive: "e"}},
+	}, 0, 1, 1500, NoOpCallback)
+	assert.NoError(t, err)
+
+	deletes, err = db.ExpiredKeys(2000, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(deletes))
+	assert.Equal(t, "a", deletes[0].Key)
+	assert.Equal(t, res.Puts[0].Version.VersionId, *deletes[0].ExpectedVersionId)
+	assert.Equal(t, expiryKey(2000, "d"), deletes[1].Key)
+	assert.Nil(t, deletes[1].ExpectedVersionId)
+
+	// The max count is respected
+	deletes, err = db.ExpiredKeys(3000, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(deletes))
+
+	deletes, err = db.ExpiredKeys(3000, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(deletes))
+
+	wr, err := db.ProcessWrite(&proto.WriteRequest{Deletes: deletes}, 0, 2, 3000, NoOpCallback)
+	assert.NoError(t, err)
+	for _, dr := range wr.Deletes {
+		assert.Equal(t, proto.Status_OK, dr.Status)
+	}
+
+	// Nothing is left in the index
+	deletes, err = db.ExpiredKeys(10000, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, deletes)
+
+	assert.Equal(t, []string{"c"}, listKeys(t, db, "a", "e"))
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func listKeys(t *testing.T, db DB, startInclusive string, endExclusive string) []string {
+	t.Helper()
+
+	it, err := db.List(&proto.ListRequest{StartInclusive: startInclusive, EndExclusive: endExclusive})
+	assert.NoError(t, err)
+
+	keys := make([]string, 0)
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, it.Key())
+	}
+	assert.NoError(t, it.Close())
+	return keys
+}
+
+var testSplit = &proto.ShardSplit{
+	Children: map[int64]*proto.Int32HashRange{
+		1: {MinHashInclusive: 0, MaxHashInclusive: math.MaxUint32 / 2},
+		2: {MinHashInclusive: math.MaxUint32/2 + 1, MaxHashInclusive: math.MaxUint32},
+	},
+}
+
+func writeSplitTestRecords(t *testing.T, db DB) {
+	t.Helper()
+
+	req := &proto.WriteRequest{}
+	for i := 0; i < 100; i++ {
+		req.Puts = append(req.Puts, &proto.PutRequest{Key: fmt.Sprintf("key-%d", i), Value: []byte("v")})
+	}
+	// All the records with a partition key are placed by it
+	for i := 0; i < 10; i++ {
+		req.Puts = append(req.Puts, &proto.PutRequest{
+			Key:          fmt.Sprintf("pk-key-%d", i),
+			Value:        []byte("v"),
+			PartitionKey: pb.String("key-0"),
+		})
+	}
+	_, err := db.ProcessWrite(req, 0, 0, 0, NoOpCallback)
+	assert.NoError(t, err)
+}
+
+func assertChildShardRecords(t *testing.T, db DB, shardId int64) {
+	t.Helper()
+
+	hashRange := testSplit.Children[shardId]
+	keys := listKeys(t, db, "a", "z")
+	assert.NotEmpty(t, keys)
+	for _, key := range keys {
+		hash := common.Xxh332(key)
+		if strings.HasPrefix(key, "pk-") {
+			hash = common.Xxh332("key-0")
+		}
+		assert.True(t, hash >= hashRange.MinHashInclusive && hash <= hashRange.MaxHashInclusive, key)
+	}
+}
+
+func TestDB_ShardSplit(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+
+	var allKeys []string
+	for _, shardId := range []int64{0, 1, 2} {
+		db, err := NewDB(common.DefaultNamespace, shardId, factory, 0, common.SystemClock)
+		assert.NoError(t, err)
+		writeSplitTestRecords(t, db)
+
+		assert.NoError(t, db.ProcessShardSplit(testSplit, 0, 1, 0, NoOpCallback))
+
+		commitOffset, err := db.ReadCommitOffset()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, commitOffset)
+
+		split, err := db.ShardSplit()
+		assert.NoError(t, err)
+
+		if shardId == 0 {
+			// The parent retains all the records
+			assert.True(t, pb.Equal(testSplit, split))
+			assert.Equal(t, 110, len(listKeys(t, db, "a", "z")))
+		} else {
+			assert.Nil(t, split)
+			assertChildShardRecords(t, db, shardId)
+			allKeys = append(allKeys, listKeys(t, db, "a", "z")...)
+		}
+
+		assert.NoError(t, db.Close())
+	}
+
+	// Each record is in exactly one of the children
+	assert.Equal(t, 110, len(allKeys))
+	slices.Sort(allKeys)
+	assert.Equal(t, len(allKeys), len(slices.Compact(allKeys)))
+
+	assert.NoError(t, factory.Close())
+}
+
+// The records of a large shard are deleted across several batches.
+func TestDB_ShardSplitLargeShard(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+
+	const records = 5 * maxSplitDeleteBatchCount
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	var expectedKeys []string
+	hashRange := testSplit.Children[1]
+	for i := 0; i < records; i += 100 {
+		req := &proto.WriteRequest{}
+		for j := i; j < i+100; j++ {
+			key := fmt.Sprintf("key-%05d", j)
+			req.Puts = append(req.Puts, &proto.PutRequest{Key: key, Value: []byte("v")})
+			if hash := common.Xxh332(key); hash >= hashRange.MinHashInclusive && hash <= hashRange.MaxHashInclusive {
+				expectedKeys = append(expectedKeys, key)
+			}
+		}
+		_, err = db.ProcessWrite(req, 0, int64(i), 0, NoOpCallback)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, db.ProcessShardSplit(testSplit, 0, records, 0, NoOpCallback))
+	assert.Equal(t, expectedKeys, listKeys(t, db, "a", "z"))
+
+	commitOffset, err := db.ReadCommitOffset()
+	assert.NoError(t, err)
+	assert.EqualValues(t, records, commitOffset)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestDB_ShardSplitFromSnapshot(t *testing.T) {
+	factory, err := NewPebbleKVFactory(&FactoryOptions{DataDir: t.TempDir(), CacheSizeMB: 1})
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 0, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	writeSplitTestRecords(t, db)
+	assert.NoError(t, db.ProcessShardSplit(testSplit, 0, 1, 0, NoOpCallback))
+
+	// A child that is initialized with a snapshot of the parent, taken after
+	// the split, only keeps its own records
+	snapshot, err := db.Snapshot()
+	assert.NoError(t, err)
+
+	loader, err := factory.NewSnapshotLoader(common.DefaultNamespace, 2)
+	assert.NoError(t, err)
+	for ; snapshot.Valid(); snapshot.Next() {
+		f, err := snapshot.Chunk()
+		assert.NoError(t, err)
+		assert.NoError(t, loader.AddChunk(f.Name(), f.Index(), f.TotalCount(), f.Content()))
+	}
+	assert.NoError(t, loader.Complete())
+	assert.NoError(t, loader.Close())
+	assert.NoError(t, snapshot.Close())
+
+	child, err := NewDB(common.DefaultNamespace, 2, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	assertChildShardRecords(t, child, 2)
+	split, err := child.ShardSplit()
+	assert.NoError(t, err)
+	assert.Nil(t, split)
+
+	commitOffset, err := child.ReadCommitOffset()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, commitOffset)
+
+	assert.NoError(t, child.Close())
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestDB_ProcessWriteWithinSession(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	sessionId := int64(5)
+	newRequest := func(sequence uint64) *proto.WriteRequest {
+		return &proto.WriteRequest{
+			Puts: []*proto.PutRequest{{
+				Key:   "a",
+				Value: []byte(fmt.Sprintf("%d", sequence)),
+			}},
+			DeleteRanges: []*proto.DeleteRangeRequest{{
+				StartInclusive: "b",
+				EndExclusive:   "c",
+			}},
+			SessionId: &sessionId,
+			Sequence:  &sequence,
+		}
+	}
+
+	offset := int64(0)
+	responses := map[uint64]*proto.WriteResponse{}
+	for sequence := uint64(1); sequence <= MaxRetainedSessionWrites+1; sequence++ {
+		res, err := db.ProcessWrite(newRequest(sequence), 1, offset, 0, NoOpCallback)
+		assert.NoError(t, err)
+		assert.Equal(t, proto.Status_OK, res.Puts[0].Status)
+		responses[sequence] = res
+		offset++
+	}
+
+	// The retries of the retained requests get the original response
+	for _, sequence := range []uint64{2, 30, MaxRetainedSessionWrites + 1} {
+		res, err := db.ProcessWrite(newRequest(sequence), 1, offset, 0, NoOpCallback)
+		assert.NoError(t, err)
+		assert.True(t, pb.Equal(responses[sequence], res))
+		offset++
+	}
+
+	// The response of the oldest request was discarded
+	res, err := db.ProcessWrite(newRequest(1), 1, offset, 0, NoOpCallback)
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_DUPLICATE_REQUEST, res.Puts[0].Status)
+	assert.Equal(t, proto.Status_DUPLICATE_REQUEST, res.DeleteRanges[0].Status)
+	offset++
+
+	gr, err := db.Get(&proto.GetRequest{Key: "a", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%d", MaxRetainedSessionWrites+1), string(gr.Value))
+	assert.EqualValues(t, MaxRetainedSessionWrites, gr.Version.ModificationsCount)
+
+	// The requests of other sessions, or without a session, are not affected
+	otherSessionId := int64(6)
+	res, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts:      []*proto.PutRequest{{Key: "a", Value: []byte("other")}},
+		SessionId: &otherSessionId,
+		Sequence:  pb.Uint64(1),
+	}, 1, offset, 0, NoOpCallback)
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, res.Puts[0].Status)
+	offset++
+
+	res, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "a", Value: []byte("none")}},
+	}, 1, offset, 0, NoOpCallback)
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, res.Puts[0].Status)
+	offset++
+
+	// The requests that reach the leader after a later one of the session
+	// were never applied, and they are rejected
+	for _, test := range []struct {
+		sessionId      int64
+		sequence       uint64
+		lowerSequence  uint64
+		expectedStatus proto.Status
+	}{
+		{otherSessionId, 3, 2, proto.Status_INVALID_SEQUENCE},
+		{sessionId, MaxRetainedSessionWrites + 3, MaxRetainedSessionWrites + 2, proto.Status_INVALID_SEQUENCE},
+		{sessionId, MaxRetainedSessionWrites + 4, 2, proto.Status_DUPLICATE_REQUEST},
+	} {
+		res, err = db.ProcessWrite(&proto.WriteRequest{
+			Puts:      []*proto.PutRequest{{Key: "b", Value: []byte("0")}},
+			SessionId: &test.sessionId,
+			Sequence:  &test.sequence,
+		}, 1, offset, 0, NoOpCallback)
+		assert.NoError(t, err)
+		assert.Equal(t, proto.Status_OK, res.Puts[0].Status)
+		offset++
+
+		res, err = db.ProcessWrite(&proto.WriteRequest{
+			Puts:      []*proto.PutRequest{{Key: "b", Value: []byte("1")}},
+			SessionId: &test.sessionId,
+			Sequence:  &test.lowerSequence,
+		}, 1, offset, 0, NoOpCallback)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedStatus, res.Puts[0].Status)
+		offset++
+
+		gr, err = db.Get(&proto.GetRequest{Key: "b", IncludeValue: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "0", string(gr.Value))
+	}
+
+	commitOffset, err := db.ReadCommitOffset()
+	assert.NoError(t, err)
+	assert.Equal(t, offset-1, commitOffset)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestDB_GetBatch(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: []byte("0")},
+			{Key: "c", Value: []byte("2")},
+		},
+	}, 0, 0, 0, NoOpCallback)
+	assert.NoError(t, err)
+
+	responses, err := db.GetBatch([]*proto.GetRequest{
+		{Key: "c", IncludeValue: true},
+		{Key: "b", IncludeValue: true},
+		{Key: "a", IncludeValue: true},
+		{Key: "b", IncludeValue: true, ComparisonType: proto.KeyComparisonType_FLOOR},
+		{Key: "a", IncludeValue: false},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, responses, 5)
+
+	// The responses are in the order of the requests
+	assert.Equal(t, proto.Status_OK, responses[0].Status)
+	assert.Equal(t, []byte("2"), responses[0].Value)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, responses[1].Status)
+	assert.Equal(t, proto.Status_OK, responses[2].Status)
+	assert.Equal(t, []byte("0"), responses[2].Value)
+	assert.Equal(t, proto.Status_OK, responses[3].Status)
+	assert.Equal(t, "a", responses[3].GetKey())
+	assert.Equal(t, []byte("0"), responses[3].Value)
+	assert.Equal(t, proto.Status_OK, responses[4].Status)
+	assert.Nil(t, responses[4].Value)
+
+	responses, err = db.GetBatch(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, responses)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestDB_FloorCeilingBoundaries(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	type query struct {
+		key            string
+		comparisonType proto.KeyComparisonType
+		expectedKey    string
+	}
+	check := func(queries []query) {
+		t.Helper()
+		for _, q := range queries {
+			getRes, err := db.Get(&proto.GetRequest{Key: q.key, IncludeValue: true, ComparisonType: q.comparisonType})
+			assert.NoError(t, err, q)
+			if q.expectedKey == "" {
+				assert.Equal(t, proto.Status_KEY_NOT_FOUND, getRes.Status, q)
+			} else {
+				assert.Equal(t, proto.Status_OK, getRes.Status, q)
+				assert.Equal(t, q.expectedKey, getRes.GetKey(), q)
+				assert.Equal(t, q.expectedKey, string(getRes.GetValue()), q)
+			}
+		}
+	}
+
+	// The store only has the internal keys, which are never returned
+	_, err = db.ProcessWrite(&proto.WriteRequest{}, 0, 0, 0, NoOpCallback)
+	assert.NoError(t, err)
+	assert.NoError(t, db.UpdateTerm(1))
+
+	var queries []query
+	for _, key := range []string{"", "a", "/a", "z", common.InternalKeyPrefix, common.InternalKeyPrefix + "term"} {
+		for _, comparisonType := range []proto.KeyComparisonType{
+			proto.KeyComparisonType_FLOOR,
+			proto.KeyComparisonType_CEILING,
+			proto.KeyComparisonType_LOWER,
+			proto.KeyComparisonType_HIGHER,
+		} {
+			queries = append(queries, query{key, comparisonType, ""})
+		}
+	}
+	check(queries)
+
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "b", Value: []byte("b")},
+			{Key: "d", Value: []byte("d")},
+		},
+	}, 1, 0, 0, NoOpCallback)
+	assert.NoError(t, err)
+
+	check([]query{
+		// Before the first key
+		{"a", proto.KeyComparisonType_FLOOR, ""},
+		{"a", proto.KeyComparisonType_LOWER, ""},
+		{"a", proto.KeyComparisonType_CEILING, "b"},
+		{"a", proto.KeyComparisonType_HIGHER, "b"},
+
+		// Exact hit on the first key
+		{"b", proto.KeyComparisonType_FLOOR, "b"},
+		{"b", proto.KeyComparisonType_LOWER, ""},
+		{"b", proto.KeyComparisonType_CEILING, "b"},
+		{"b", proto.KeyComparisonType_HIGHER, "d"},
+
+		// Exact hit on the last key
+		{"d", proto.KeyComparisonType_FLOOR, "d"},
+		{"d", proto.KeyComparisonType_LOWER, "b"},
+		{"d", proto.KeyComparisonType_CEILING, "d"},
+		{"d", proto.KeyComparisonType_HIGHER, ""},
+
+		// After the last key
+		{"e", proto.KeyComparisonType_FLOOR, "d"},
+		{"e", proto.KeyComparisonType_LOWER, "d"},
+		{"e", proto.KeyComparisonType_CEILING, ""},
+		{"e", proto.KeyComparisonType_HIGHER, ""},
+
+		// The internal keys are skipped
+		{common.InternalKeyPrefix + "term", proto.KeyComparisonType_FLOOR, "d"},
+		{common.InternalKeyPrefix + "term", proto.KeyComparisonType_CEILING, ""},
+	})
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestDB_GetWithoutValue(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	db, err := NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	value := bytes.Repeat([]byte("v"), 100)
+	_, err = db.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: value, ClientIdentity: pb.String("client-a")},
+			{Key: "b", Value: []byte{}},
+		},
+	}, 0, 0, 0, NoOpCallback)
+	assert.NoError(t, err)
+
+	full, err := db.Get(&proto.GetRequest{Key: "a", IncludeValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, full.Status)
+	assert.Equal(t, value, full.Value)
+	assert.Nil(t, full.ValueSize)
+
+	// Only the version and the size of the value
+	getRes, err := db.Get(&proto.GetRequest{Key: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, getRes.Status)
+	assert.Nil(t, getRes.Value)
+	assert.EqualValues(t, 100, getRes.GetValueSize())
+	assert.Equal(t, full.Version, getRes.Version)
+	assert.Equal(t, "client-a", getRes.Version.GetClientIdentity())
+
+	getRes, err = db.Get(&proto.GetRequest{Key: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, getRes.Status)
+	assert.EqualValues(t, 0, getRes.GetValueSize())
+
+	// The maximum size is inclusive
+	for _, item := range []struct {
+		maxValueSize   uint64
+		expectedStatus proto.Status
+	}{
+		{0, proto.Status_VALUE_TOO_LARGE},
+		{99, proto.Status_VALUE_TOO_LARGE},
+		{100, proto.Status_OK},
+		{1000, proto.Status_OK},
+	} {
+		getRes, err = db.Get(&proto.GetRequest{Key: "a", IncludeValue: true, MaxValueSize: pb.Uint64(item.maxValueSize)})
+		assert.NoError(t, err)
+		assert.Equal(t, item.expectedStatus, getRes.Status, item.maxValueSize)
+		assert.Equal(t, full.Version, getRes.Version, item.maxValueSize)
+		if item.expectedStatus == proto.Status_OK {
+			assert.Equal(t, value, getRes.Value, item.maxValueSize)
+			assert.Nil(t, getRes.ValueSize, item.maxValueSize)
+		} else {
+			assert.Nil(t, getRes.Value, item.maxValueSize)
+			assert.EqualValues(t, 100, getRes.GetValueSize(), item.maxValueSize)
+		}
+	}
+
+	// Without the value, the maximum size doesn't apply
+	getRes, err = db.Get(&proto.GetRequest{Key: "a", MaxValueSize: pb.Uint64(10)})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, getRes.Status)
+	assert.EqualValues(t, 100, getRes.GetValueSize())
+
+	getRes, err = db.Get(&proto.GetRequest{Key: "a0", ComparisonType: proto.KeyComparisonType_FLOOR, IncludeValue: true, MaxValueSize: pb.Uint64(10)})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_VALUE_TOO_LARGE, getRes.Status)
+	assert.Equal(t, "a", getRes.GetKey())
+
+	getRes, err = db.Get(&proto.GetRequest{Key: "c", MaxValueSize: pb.Uint64(10)})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, getRes.Status)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
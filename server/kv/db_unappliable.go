@@ -0,0 +1,73 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	pb "google.golang.org/protobuf/proto"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+// The committed entry that the replica could not apply. It's local to the
+// replica, so that the operators can find it after a restart.
+const unappliableEntryKey = common.InternalKeyPrefix + "unappliable-entry"
+
+func (d *db) UnappliableEntry() (*proto.UnappliableEntry, error) {
+	_, value, closer, err := d.kv.Get(unappliableEntryKey, ComparisonEqual)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil //nolint:nilnil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entry := &proto.UnappliableEntry{}
+	if err = multierr.Append(
+		pb.Unmarshal(value, entry),
+		closer.Close(),
+	); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (d *db) SetUnappliableEntry(entry *proto.UnappliableEntry) error {
+	batch := d.kv.NewWriteBatch()
+
+	if err := setUnappliableEntry(batch, entry); err != nil {
+		return multierr.Append(err, batch.Close())
+	}
+	return batch.Close()
+}
+
+func setUnappliableEntry(batch WriteBatch, entry *proto.UnappliableEntry) error {
+	if entry == nil {
+		if err := batch.Delete(unappliableEntryKey); err != nil {
+			return err
+		}
+		return batch.Commit()
+	}
+
+	value, err := pb.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err = batch.Put(unappliableEntryKey, value); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
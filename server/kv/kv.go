@@ -24,6 +24,8 @@ import (
 
 var (
 	ErrKeyNotFound             = errors.New("oxia: key not found")
+	ErrDbLocked                = errors.New("oxia: database is in use")
+	ErrDbClosed                = errors.New("oxia: database is closed")
 	MaxSnapshotChunkSize int64 = 1024 * 1024 // bytes
 
 )
@@ -92,8 +94,10 @@ type SnapshotLoader interface {
 
 	AddChunk(fileName string, chunkIndex int32, chunkCount int32, content []byte) error
 
-	// Complete signals that the snapshot is now complete
-	Complete()
+	// Complete signals that the snapshot is now complete, and replaces the
+	// database of the shard with it. Until then, the existing database is
+	// left untouched. The database must not be open when calling Complete.
+	Complete() error
 }
 
 type ComparisonType proto.KeyComparisonType
@@ -106,12 +110,26 @@ const (
 	ComparisonHigher
 )
 
+// Reader reads the records by key.
+type Reader interface {
+	Get(key string, comparisonType ComparisonType) (storedKey string, value []byte, closer io.Closer, err error)
+}
+
+// ReadSnapshot is a view of the data at the time it was taken, so that
+// several reads observe the same state. The values that it returns must be
+// released before it's closed.
+type ReadSnapshot interface {
+	io.Closer
+	Reader
+}
+
 type KV interface {
 	io.Closer
+	Reader
 
 	NewWriteBatch() WriteBatch
 
-	Get(key string, comparisonType ComparisonType) (storedKey string, value []byte, closer io.Closer, err error)
+	NewReadSnapshot() (ReadSnapshot, error)
 
 	KeyRangeScan(lowerBound, upperBound string) (KeyIterator, error)
 	KeyRangeScanReverse(lowerBound, upperBound string) (ReverseKeyIterator, error)
@@ -122,20 +140,91 @@ type KV interface {
 
 	Flush() error
 
+	// DiskUsage returns the size in bytes of the files of the database,
+	// including the ones that are obsolete and not deleted yet
+	DiskUsage() int64
+
+	// Compact rewrites all the data of the database, dropping the records
+	// that were overwritten or deleted, and returns the number of bytes by
+	// which the data shrank. Closing the database waits for the compaction
+	// in progress, if any.
+	Compact() (reclaimedBytes int64, err error)
+
 	Delete() error
 }
 type FactoryOptions struct {
-	DataDir     string
+	DataDir string
+
+	// The size of the block cache, which is shared by the databases of all
+	// the shards
 	CacheSizeMB int64
 
+	// The size of the memtable of each shard. 0 means the default
+	MemTableSizeMB int64
+
+	// Store the data files without compression, trading disk space for CPU
+	DisableCompression bool
+
+	// The max number of files that each shard keeps open. 0 means the default
+	MaxOpenFiles int
+
+	// The max number of compactions that each shard runs concurrently.
+	// 0 means the default
+	MaxConcurrentCompactions int
+
 	// Create a pure in-memory database. Used for unit-tests
 	InMemory bool
+
+	// Open the existing databases read-only, to inspect them. A database
+	// that is in use cannot be opened
+	ReadOnly bool
 }
 
 var DefaultFactoryOptions = &FactoryOptions{
-	DataDir:     "data",
-	CacheSizeMB: 100,
-	InMemory:    false,
+	DataDir:                  "data",
+	CacheSizeMB:              100,
+	MemTableSizeMB:           32,
+	DisableCompression:       false,
+	MaxOpenFiles:             1000,
+	MaxConcurrentCompactions: 1,
+	InMemory:                 false,
+}
+
+func (o *FactoryOptions) Validate() error {
+	if o.CacheSizeMB < 0 {
+		return errors.Errorf("invalid db cache size: %d", o.CacheSizeMB)
+	}
+	if o.MemTableSizeMB < 0 {
+		return errors.Errorf("invalid db memtable size: %d", o.MemTableSizeMB)
+	}
+	if o.MaxOpenFiles < 0 {
+		return errors.Errorf("invalid db max open files: %d", o.MaxOpenFiles)
+	}
+	if o.MaxConcurrentCompactions < 0 {
+		return errors.Errorf("invalid db max concurrent compactions: %d", o.MaxConcurrentCompactions)
+	}
+	return nil
+}
+
+// Replace the values that are not set with the defaults.
+func (o *FactoryOptions) withDefaults() *FactoryOptions {
+	res := *o
+	if res.DataDir == "" {
+		res.DataDir = DefaultFactoryOptions.DataDir
+	}
+	if res.CacheSizeMB == 0 {
+		res.CacheSizeMB = DefaultFactoryOptions.CacheSizeMB
+	}
+	if res.MemTableSizeMB == 0 {
+		res.MemTableSizeMB = DefaultFactoryOptions.MemTableSizeMB
+	}
+	if res.MaxOpenFiles == 0 {
+		res.MaxOpenFiles = DefaultFactoryOptions.MaxOpenFiles
+	}
+	if res.MaxConcurrentCompactions == 0 {
+		res.MaxConcurrentCompactions = DefaultFactoryOptions.MaxConcurrentCompactions
+	}
+	return &res
 }
 
 type Factory interface {
@@ -144,4 +233,8 @@ type Factory interface {
 	NewKV(namespace string, shardId int64) (KV, error)
 
 	NewSnapshotLoader(namespace string, shardId int64) (SnapshotLoader, error)
+
+	// ListShards returns the shards that have a database on the disk,
+	// by namespace
+	ListShards() (map[string][]int64, error)
 }
@@ -15,11 +15,13 @@
 package kv
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -56,27 +58,23 @@ type PebbleFactory struct {
 	cache   *pebble.Cache
 	options *FactoryOptions
 
-	gaugeCacheSize metrics.Gauge
+	gaugeCacheSize     metrics.Gauge
+	gaugeCacheUsedSize metrics.Gauge
 }
 
 func NewPebbleKVFactory(options *FactoryOptions) (Factory, error) {
 	if options == nil {
 		options = DefaultFactoryOptions
 	}
-	cacheSizeMB := options.CacheSizeMB
-	if cacheSizeMB == 0 {
-		cacheSizeMB = DefaultFactoryOptions.CacheSizeMB
-	}
-
-	dataDir := options.DataDir
-	if dataDir == "" {
-		dataDir = DefaultFactoryOptions.DataDir
+	if err := options.Validate(); err != nil {
+		return nil, err
 	}
+	options = options.withDefaults()
 
-	cache := pebble.NewCache(cacheSizeMB * 1024 * 1024)
+	cache := pebble.NewCache(options.CacheSizeMB * 1024 * 1024)
 
 	pf := &PebbleFactory{
-		dataDir: dataDir,
+		dataDir: options.DataDir,
 		options: options,
 
 		// Share a single cache instance across the databases for all the shards
@@ -87,9 +85,17 @@ func NewPebbleKVFactory(options *FactoryOptions) (Factory, error) {
 			metrics.Bytes, map[string]any{}, func() int64 {
 				return options.CacheSizeMB * 1024 * 1024
 			}),
+		gaugeCacheUsedSize: metrics.NewGauge("oxia_server_kv_pebble_cache_used",
+			"The size of the Pebble block cache used by the databases of all the shards",
+			metrics.Bytes, map[string]any{}, func() int64 {
+				return cache.Metrics().Size
+			}),
 	}
 
 	// Cleanup leftover snapshots from previous runs
+	if options.ReadOnly {
+		return pf, nil
+	}
 	if err := pf.cleanupSnapshots(); err != nil {
 		return nil, errors.Wrap(err, "failed to delete database snapshots")
 	}
@@ -113,10 +119,17 @@ func (p *PebbleFactory) cleanupSnapshots() error {
 
 func (p *PebbleFactory) Close() error {
 	p.gaugeCacheSize.Unregister()
+	p.gaugeCacheUsedSize.Unregister()
 	p.cache.Unref()
 	return nil
 }
 
+// CacheMetrics returns the metrics of the block cache that is shared by the
+// databases of all the shards.
+func (p *PebbleFactory) CacheMetrics() pebble.CacheMetrics {
+	return p.cache.Metrics()
+}
+
 func (p *PebbleFactory) NewKV(namespace string, shardId int64) (KV, error) {
 	return newKVPebble(p, namespace, shardId)
 }
@@ -125,6 +138,43 @@ func (p *PebbleFactory) NewSnapshotLoader(namespace string, shardId int64) (Snap
 	return newPebbleSnapshotLoader(p, namespace, shardId)
 }
 
+func (p *PebbleFactory) ListShards() (map[string][]int64, error) {
+	shards := map[string][]int64{}
+	if p.options.InMemory {
+		return shards, nil
+	}
+
+	namespaces, err := os.ReadDir(p.dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return shards, nil
+		}
+		return nil, err
+	}
+
+	for _, namespace := range namespaces {
+		if !namespace.IsDir() || namespace.Name() == "snapshots" {
+			continue
+		}
+
+		dirs, err := os.ReadDir(filepath.Join(p.dataDir, namespace.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dir := range dirs {
+			// Skip the snapshots that are being loaded
+			var shard int64
+			if n, _ := fmt.Sscanf(dir.Name(), "shard-%d", &shard); n != 1 ||
+				dir.Name() != fmt.Sprint("shard-", shard) || !dir.IsDir() {
+				continue
+			}
+			shards[namespace.Name()] = append(shards[namespace.Name()], shard)
+		}
+	}
+	return shards, nil
+}
+
 func (p *PebbleFactory) getKVPath(namespace string, shard int64) string {
 	if namespace == "" {
 		slog.Warn(
@@ -143,6 +193,7 @@ type Pebble struct {
 	shardId         int64
 	dataDir         string
 	db              *pebble.DB
+	lock            *pebble.Lock
 	snapshotCounter atomic.Int64
 
 	dbMetrics          func() *pebble.Metrics
@@ -159,6 +210,14 @@ type Pebble struct {
 
 	batchSizeHisto  metrics.Histogram
 	batchCountHisto metrics.Histogram
+
+	// The manual compactions run outside of the controller of the shard, so
+	// the database is only closed once they're done
+	compactionMutex     sync.Mutex
+	closed              bool
+	lastCompaction      atomic.Int64
+	compactionLatency   metrics.LatencyHistogram
+	compactionReclaimed metrics.Counter
 }
 
 func newKVPebble(factory *PebbleFactory, namespace string, shardId int64) (KV, error) {
@@ -190,12 +249,27 @@ func newKVPebble(factory *PebbleFactory, namespace string, shardId int64) (KV, e
 			"The size in bytes for a given batch", labels),
 		batchCountHisto: metrics.NewCountHistogram("oxia_server_kv_batch_count",
 			"The number of operations in a given batch", labels),
+
+		compactionLatency: metrics.NewLatencyHistogram("oxia_server_kv_compaction_latency",
+			"The time it takes to compact the whole database", labels),
+		compactionReclaimed: metrics.NewCounter("oxia_server_kv_compaction_reclaimed",
+			"The amount of bytes dropped from the database by the compactions", metrics.Bytes, labels),
+	}
+
+	options := factory.options
+	compression := pebble.ZstdCompression
+	if options.DisableCompression {
+		compression = pebble.NoCompression
 	}
 
 	pbOptions := &pebble.Options{
 		Cache:        factory.cache,
 		Comparer:     OxiaSlashSpanComparer,
-		MemTableSize: 32 * 1024 * 1024,
+		MemTableSize: uint64(options.MemTableSizeMB) * 1024 * 1024,
+		MaxOpenFiles: options.MaxOpenFiles,
+		MaxConcurrentCompactions: func() int {
+			return options.MaxConcurrentCompactions
+		},
 		Levels: []pebble.LevelOptions{
 			{
 				BlockSize:      64 * 1024,
@@ -205,13 +279,15 @@ func newKVPebble(factory *PebbleFactory, namespace string, shardId int64) (KV, e
 				FilterType:     pebble.TableFilter,
 			}, {
 				BlockSize:      64 * 1024,
-				Compression:    pebble.ZstdCompression,
+				Compression:    compression,
 				TargetFileSize: 64 * 1024 * 1024,
 				FilterPolicy:   bloom.FilterPolicy(10),
 				FilterType:     pebble.TableFilter,
 			},
 		},
-		FS:         vfs.Default,
+		FS: vfs.Default,
+
+		// The entries are already durable in the wal of the shard
 		DisableWAL: true,
 		Logger: &pebbleLogger{
 			slog.With(
@@ -228,8 +304,17 @@ func newKVPebble(factory *PebbleFactory, namespace string, shardId int64) (KV, e
 	}
 
 	dbPath := factory.getKVPath(namespace, shardId)
+	if options.ReadOnly {
+		if err := pb.lockReadOnly(dbPath, pbOptions); err != nil {
+			return nil, err
+		}
+	}
+
 	db, err := pebble.Open(dbPath, pbOptions)
 	if err != nil {
+		if pb.lock != nil {
+			_ = pb.lock.Close()
+		}
 		return nil, errors.Wrapf(err, "failed to open database at %s", dbPath)
 	}
 
@@ -241,6 +326,11 @@ func newKVPebble(factory *PebbleFactory, namespace string, shardId int64) (KV, e
 	}, 5*time.Second)
 
 	pb.gauges = []metrics.Gauge{
+		metrics.NewGauge("oxia_server_kv_last_compaction_timestamp",
+			"The time at which the last compaction of the whole database completed", metrics.Milliseconds, labels,
+			func() int64 {
+				return pb.lastCompaction.Load()
+			}),
 		metrics.NewGauge("oxia_server_kv_pebble_block_cache_used",
 			"The size of the block cache used by a given db shard",
 			metrics.Bytes, labels, func() int64 {
@@ -314,10 +404,8 @@ func newKVPebble(factory *PebbleFactory, namespace string, shardId int64) (KV, e
 	// Add the per-LSM level metrics
 	for i := 0; i < 7; i++ {
 		level := i
-		labels := map[string]any{
-			"shard": shardId,
-			"level": level,
-		}
+		labels := metrics.LabelsForShard(namespace, shardId)
+		labels["level"] = level
 
 		pb.gauges = append(pb.gauges,
 			metrics.NewGauge("oxia_server_kv_pebble_per_level_num_files",
@@ -341,11 +429,37 @@ func newKVPebble(factory *PebbleFactory, namespace string, shardId int64) (KV, e
 	return pb, nil
 }
 
+// lockReadOnly takes the lock of the database before opening it read-only,
+// since Pebble doesn't tell a database in use from other failures.
+func (p *Pebble) lockReadOnly(dbPath string, pbOptions *pebble.Options) error {
+	if _, err := os.Stat(dbPath); err != nil {
+		return errors.Wrapf(err, "failed to open database at %s", dbPath)
+	}
+
+	lock, err := pebble.LockDirectory(dbPath, pbOptions.FS)
+	if err != nil {
+		return errors.Wrapf(ErrDbLocked, "database %s: %v", dbPath, err)
+	}
+
+	p.lock = lock
+	pbOptions.Lock = lock
+	pbOptions.ReadOnly = true
+	return nil
+}
+
 func (p *Pebble) Close() error {
+	p.compactionMutex.Lock()
+	p.closed = true
+	p.compactionMutex.Unlock()
+
 	for _, g := range p.gauges {
 		g.Unregister()
 	}
 
+	if p.lock != nil {
+		return multierr.Append(p.db.Close(), p.lock.Close())
+	}
+
 	if err := p.db.Flush(); err != nil {
 		return err
 	}
@@ -363,36 +477,92 @@ func (p *Pebble) Flush() error {
 	return p.db.Flush()
 }
 
-func (p *Pebble) NewWriteBatch() WriteBatch {
-	return &PebbleBatch{p: p, b: p.db.NewIndexedBatch()}
+func (p *Pebble) DiskUsage() int64 {
+	return int64(p.dbMetrics().DiskSpaceUsage())
 }
 
-func (p *Pebble) getFloor(key string) (returnedKey string, value []byte, closer io.Closer, err error) {
-	// There is no <= comparison in Pebble
-	// We have to first check for == and then for <
-	value, closer, err = p.db.Get([]byte(key))
-	if err != nil && !errors.Is(err, pebble.ErrNotFound) {
-		return "", nil, nil, err
+func (p *Pebble) Compact() (reclaimedBytes int64, err error) {
+	p.compactionMutex.Lock()
+	defer p.compactionMutex.Unlock()
+
+	if p.closed {
+		return 0, ErrDbClosed
 	}
 
-	if err == nil {
-		// We found record with key ==
-		return key, value, closer, nil
+	timer := p.compactionLatency.Timer()
+
+	// The records in the memtable are flushed first, so that they're part of
+	// the compaction too
+	if err = p.db.Flush(); err != nil {
+		return 0, err
 	}
 
-	// Do < search
-	return p.getLower(key)
+	sizeBefore := p.db.Metrics().Total().Size
+	start, end, err := p.keyBounds()
+	if err != nil {
+		return 0, err
+	}
+	if end != nil {
+		if err = p.db.Compact(start, end, true); err != nil {
+			return 0, errors.Wrap(err, "failed to compact the database")
+		}
+	}
+
+	reclaimedBytes = max(sizeBefore-p.db.Metrics().Total().Size, 0)
+	timer.Done()
+	p.compactionReclaimed.Add(int(reclaimedBytes))
+	p.lastCompaction.Store(time.Now().UnixMilli())
+	return reclaimedBytes, nil
 }
 
-func (p *Pebble) getCeiling(key string) (returnedKey string, value []byte, closer io.Closer, err error) {
-	it, err := p.db.NewIter(&pebble.IterOptions{
-		LowerBound: []byte(key),
-	})
+// keyBounds returns the range of keys that covers all the tables of the
+// database, including the deletions, or nil if there are no tables. The end
+// of the range is exclusive.
+func (p *Pebble) keyBounds() (start, end []byte, err error) {
+	levels, err := p.db.SSTables()
 	if err != nil {
-		return "", nil, nil, err
+		return nil, nil, err
+	}
+
+	found := false
+	var smallest, largest []byte
+	for _, tables := range levels {
+		for _, t := range tables {
+			if !found || compare.CompareWithSlash(t.Smallest.UserKey, smallest) < 0 {
+				smallest = t.Smallest.UserKey
+			}
+			if !found || compare.CompareWithSlash(t.Largest.UserKey, largest) > 0 {
+				largest = t.Largest.UserKey
+			}
+			found = true
+		}
 	}
 
-	if !it.First() {
+	if !found {
+		return nil, nil, nil
+	}
+	return append([]byte{}, smallest...), append(bytes.Clone(largest), 0), nil
+}
+
+func (p *Pebble) NewWriteBatch() WriteBatch {
+	return &PebbleBatch{p: p, b: p.db.NewIndexedBatch()}
+}
+
+func isInternalKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(common.InternalKeyPrefix))
+}
+
+// skipInternalKeys moves the iterator past the internal keys of the db, since
+// they are not records and are not visible to the non-exact searches.
+func skipInternalKeys(it *pebble.Iterator, valid bool, move func() bool) bool {
+	for valid && isInternalKey(it.Key()) {
+		valid = move()
+	}
+	return valid
+}
+
+func iteratorResult(it *pebble.Iterator, valid bool) (returnedKey string, value []byte, closer io.Closer, err error) {
+	if !valid {
 		return "", nil, nil, multierr.Combine(it.Close(), pebble.ErrNotFound)
 	}
 
@@ -401,25 +571,49 @@ func (p *Pebble) getCeiling(key string) (returnedKey string, value []byte, close
 	return returnedKey, value, it, err
 }
 
-func (p *Pebble) getLower(key string) (returnedKey string, value []byte, closer io.Closer, err error) {
-	it, err := p.db.NewIter(&pebble.IterOptions{
-		UpperBound: []byte(key),
+func getFloor(r pebble.Reader, key string) (returnedKey string, value []byte, closer io.Closer, err error) {
+	// There is no <= comparison in Pebble
+	// We have to first check for == and then for <
+	if !isInternalKey([]byte(key)) {
+		value, closer, err = r.Get([]byte(key))
+		if err != nil && !errors.Is(err, pebble.ErrNotFound) {
+			return "", nil, nil, err
+		}
+
+		if err == nil {
+			// We found record with key ==
+			return key, value, closer, nil
+		}
+	}
+
+	// Do < search
+	return getLower(r, key)
+}
+
+func getCeiling(r pebble.Reader, key string) (returnedKey string, value []byte, closer io.Closer, err error) {
+	it, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: []byte(key),
 	})
 	if err != nil {
 		return "", nil, nil, err
 	}
 
-	if !it.Last() {
-		return "", nil, nil, multierr.Combine(it.Close(), pebble.ErrNotFound)
+	return iteratorResult(it, skipInternalKeys(it, it.First(), it.Next))
+}
+
+func getLower(r pebble.Reader, key string) (returnedKey string, value []byte, closer io.Closer, err error) {
+	it, err := r.NewIter(&pebble.IterOptions{
+		UpperBound: []byte(key),
+	})
+	if err != nil {
+		return "", nil, nil, err
 	}
 
-	returnedKey = string(it.Key())
-	value, err = it.ValueAndErr()
-	return returnedKey, value, it, err
+	return iteratorResult(it, skipInternalKeys(it, it.Last(), it.Prev))
 }
 
-func (p *Pebble) getHigher(key string) (returnedKey string, value []byte, closer io.Closer, err error) {
-	it, err := p.db.NewIter(&pebble.IterOptions{
+func getHigher(r pebble.Reader, key string) (returnedKey string, value []byte, closer io.Closer, err error) {
+	it, err := r.NewIter(&pebble.IterOptions{
 		LowerBound: []byte(key),
 	})
 	if err != nil {
@@ -428,39 +622,33 @@ func (p *Pebble) getHigher(key string) (returnedKey string, value []byte, closer
 
 	// The iterator might be positioned exactly on the key. Since we're looking for strict `x > y` comparison,
 	// we will have to skip to the next record
-	it.First()
-	if !it.First() {
-		return "", nil, nil, multierr.Combine(it.Close(), pebble.ErrNotFound)
+	valid := it.First()
+	if valid && string(it.Key()) == key {
+		valid = it.Next()
 	}
 
-	returnedKey = string(it.Key())
-	if returnedKey == key {
-		// We found the same key, skip it
-		if !it.Next() {
-			return "", nil, nil, multierr.Combine(it.Close(), pebble.ErrNotFound)
-		}
-		returnedKey = string(it.Key())
-	}
-
-	value, err = it.ValueAndErr()
-	return returnedKey, value, it, err
+	return iteratorResult(it, skipInternalKeys(it, valid, it.Next))
 }
 
 func (p *Pebble) Get(key string, comparisonType ComparisonType) (returnedKey string, value []byte, closer io.Closer, err error) {
+	return p.get(p.db, key, comparisonType)
+}
+
+func (p *Pebble) get(r pebble.Reader, key string, comparisonType ComparisonType) (returnedKey string, value []byte, closer io.Closer, err error) {
 	switch comparisonType {
 	case ComparisonEqual:
-		value, closer, err = p.db.Get([]byte(key))
+		value, closer, err = r.Get([]byte(key))
 		if err == nil {
 			returnedKey = key
 		}
 	case ComparisonFloor:
-		returnedKey, value, closer, err = p.getFloor(key)
+		returnedKey, value, closer, err = getFloor(r, key)
 	case ComparisonCeiling:
-		returnedKey, value, closer, err = p.getCeiling(key)
+		returnedKey, value, closer, err = getCeiling(r, key)
 	case ComparisonLower:
-		returnedKey, value, closer, err = p.getLower(key)
+		returnedKey, value, closer, err = getLower(r, key)
 	case ComparisonHigher:
-		returnedKey, value, closer, err = p.getHigher(key)
+		returnedKey, value, closer, err = getHigher(r, key)
 	}
 
 	if errors.Is(err, pebble.ErrNotFound) {
@@ -471,6 +659,25 @@ func (p *Pebble) Get(key string, comparisonType ComparisonType) (returnedKey str
 	return returnedKey, value, closer, err
 }
 
+func (p *Pebble) NewReadSnapshot() (ReadSnapshot, error) {
+	return &pebbleReadSnapshot{p: p, snapshot: p.db.NewSnapshot()}, nil
+}
+
+// pebbleReadSnapshot reads from a pebble snapshot, which doesn't see the
+// writes committed after it was taken.
+type pebbleReadSnapshot struct {
+	p        *Pebble
+	snapshot *pebble.Snapshot
+}
+
+func (s *pebbleReadSnapshot) Get(key string, comparisonType ComparisonType) (returnedKey string, value []byte, closer io.Closer, err error) {
+	return s.p.get(s.snapshot, key, comparisonType)
+}
+
+func (s *pebbleReadSnapshot) Close() error {
+	return s.snapshot.Close()
+}
+
 func (p *Pebble) KeyRangeScan(lowerBound, upperBound string) (KeyIterator, error) {
 	return p.RangeScan(lowerBound, upperBound)
 }
@@ -674,22 +881,29 @@ type pebbleSnapshotLoader struct {
 	dbPath    string
 	complete  bool
 	file      *os.File
+
+	// The files are downloaded next to the database, which is only
+	// replaced once the snapshot is complete
+	loadPath string
 }
 
 func newPebbleSnapshotLoader(pf *PebbleFactory, namespace string, shard int64) (SnapshotLoader, error) {
+	dbPath := pf.getKVPath(namespace, shard)
 	sl := &pebbleSnapshotLoader{
 		pf:        pf,
 		namespace: namespace,
 		shard:     shard,
-		dbPath:    pf.getKVPath(namespace, shard),
+		dbPath:    dbPath,
+		loadPath:  dbPath + ".loading",
 	}
 
-	if err := os.RemoveAll(sl.dbPath); err != nil {
-		return nil, errors.Wrap(err, "failed to remove existing database")
+	// Discard what was left by a previous attempt
+	if err := os.RemoveAll(sl.loadPath); err != nil {
+		return nil, errors.Wrap(err, "failed to remove previous snapshot files")
 	}
 
-	if err := os.MkdirAll(sl.dbPath, 0755); err != nil {
-		return nil, errors.Wrap(err, "failed to create database dir")
+	if err := os.MkdirAll(sl.loadPath, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create snapshot dir")
 	}
 
 	return sl, nil
@@ -701,7 +915,12 @@ func (sl *pebbleSnapshotLoader) Close() error {
 	}
 
 	// If we failed to successfully load, remove all intermediate files
-	return os.RemoveAll(sl.dbPath)
+	var err error
+	if sl.file != nil {
+		err = sl.file.Close()
+		sl.file = nil
+	}
+	return multierr.Append(err, os.RemoveAll(sl.loadPath))
 }
 
 func (sl *pebbleSnapshotLoader) AddChunk(fileName string, chunkIndex int32, chunkCount int32, content []byte) error {
@@ -710,7 +929,7 @@ func (sl *pebbleSnapshotLoader) AddChunk(fileName string, chunkIndex int32, chun
 		if sl.file != nil {
 			return errors.Errorf("Inconsistent snapshot: previous file not finished")
 		}
-		sl.file, err = os.OpenFile(filepath.Join(sl.dbPath, fileName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		sl.file, err = os.OpenFile(filepath.Join(sl.loadPath, fileName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 		if err != nil {
 			return err
 		}
@@ -723,7 +942,7 @@ func (sl *pebbleSnapshotLoader) AddChunk(fileName string, chunkIndex int32, chun
 		content = content[w:]
 	}
 	if chunkIndex == chunkCount-1 {
-		err = sl.file.Close()
+		err = multierr.Combine(sl.file.Sync(), sl.file.Close())
 		sl.file = nil
 		if err != nil {
 			return err
@@ -737,6 +956,19 @@ func (sl *pebbleSnapshotLoader) Load() (KV, error) {
 	return newKVPebble(sl.pf, sl.namespace, sl.shard)
 }
 
-func (sl *pebbleSnapshotLoader) Complete() {
+func (sl *pebbleSnapshotLoader) Complete() error {
+	if sl.file != nil {
+		return errors.New("Inconsistent snapshot: last file not finished")
+	}
+
+	if err := os.RemoveAll(sl.dbPath); err != nil {
+		return errors.Wrap(err, "failed to remove existing database")
+	}
+
+	if err := os.Rename(sl.loadPath, sl.dbPath); err != nil {
+		return errors.Wrap(err, "failed to move the snapshot into the database dir")
+	}
+
 	sl.complete = true
+	return nil
 }
@@ -16,10 +16,13 @@ package kv
 
 import (
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/cockroachdb/pebble"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/streamnative/oxia/common"
@@ -618,7 +621,7 @@ func TestPebbleSnapshot_Loader(t *testing.T) {
 		assert.NoError(t, loader.AddChunk(f.Name(), f.Index(), f.TotalCount(), f.Content()))
 	}
 
-	loader.Complete()
+	assert.NoError(t, loader.Complete())
 	assert.NoError(t, loader.Close())
 	assert.NoError(t, snapshot.Close())
 
@@ -1051,3 +1054,256 @@ func TestPebbleFindLowerInBatch(t *testing.T) {
 	assert.NoError(t, kv.Close())
 	assert.NoError(t, factory.Close())
 }
+
+func TestPebbleFactory_Options(t *testing.T) {
+	factory, err := NewPebbleKVFactory(&FactoryOptions{
+		DataDir:                  t.TempDir(),
+		CacheSizeMB:              8,
+		MemTableSizeMB:           64,
+		DisableCompression:       true,
+		MaxOpenFiles:             500,
+		MaxConcurrentCompactions: 4,
+	})
+	assert.NoError(t, err)
+	kv, err := factory.NewKV(common.DefaultNamespace, 1)
+	assert.NoError(t, err)
+
+	// The engine reports the options it was opened with
+	files, err := filepath.Glob(filepath.Join(factory.(*PebbleFactory).getKVPath(common.DefaultNamespace, 1), "OPTIONS-*"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	options := string(data)
+
+	assert.Contains(t, options, fmt.Sprintf("cache_size=%d\n", 8*1024*1024))
+	assert.Contains(t, options, fmt.Sprintf("mem_table_size=%d\n", 64*1024*1024))
+	assert.Contains(t, options, "max_open_files=500\n")
+	assert.Contains(t, options, "max_concurrent_compactions=4\n")
+	assert.Contains(t, options, "disable_wal=true\n")
+	assert.NotContains(t, options, "compression=ZSTD")
+
+	// The cache is shared by the databases of all the shards
+	kv2, err := factory.NewKV(common.DefaultNamespace, 2)
+	assert.NoError(t, err)
+	for i, k := range []KV{kv, kv2} {
+		wb := k.NewWriteBatch()
+		assert.NoError(t, wb.Put("a", make([]byte, 1024)))
+		assert.NoError(t, wb.Commit())
+		assert.NoError(t, wb.Close())
+		assert.NoError(t, k.Flush())
+
+		_, _, closer, err := k.Get("a", ComparisonEqual)
+		assert.NoError(t, err)
+		assert.NoError(t, closer.Close())
+
+		assert.Eventually(t, func() bool {
+			return factory.(*PebbleFactory).CacheMetrics().Count > int64(i)
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	assert.NoError(t, kv.Close())
+	assert.NoError(t, kv2.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestPebbleFactory_DefaultOptions(t *testing.T) {
+	factory, err := NewPebbleKVFactory(&FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	kv, err := factory.NewKV(common.DefaultNamespace, 1)
+	assert.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(factory.(*PebbleFactory).getKVPath(common.DefaultNamespace, 1), "OPTIONS-*"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	options := string(data)
+
+	assert.Contains(t, options, fmt.Sprintf("cache_size=%d\n", 100*1024*1024))
+	assert.Contains(t, options, fmt.Sprintf("mem_table_size=%d\n", 32*1024*1024))
+	assert.Contains(t, options, "max_open_files=1000\n")
+	assert.Contains(t, options, "max_concurrent_compactions=1\n")
+	assert.Contains(t, options, "compression=ZSTD")
+
+	assert.NoError(t, kv.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestPebbleFactory_InvalidOptions(t *testing.T) {
+	for _, options := range []*FactoryOptions{
+		{CacheSizeMB: -1},
+		{MemTableSizeMB: -1},
+		{MaxOpenFiles: -1},
+		{MaxConcurrentCompactions: -1},
+	} {
+		factory, err := NewPebbleKVFactory(options)
+		assert.Error(t, err)
+		assert.Nil(t, factory)
+	}
+}
+
+// Random reads over a data set of 32MB, with a block cache that is either
+// much smaller or larger than it.
+func BenchmarkPebbleCacheSize(b *testing.B) {
+	const keys = 32 * 1024
+	value := make([]byte, 1024)
+
+	for _, cacheSizeMB := range []int64{1, 64} {
+		b.Run(fmt.Sprintf("cache-%dMB", cacheSizeMB), func(b *testing.B) {
+			factory, err := NewPebbleKVFactory(&FactoryOptions{
+				DataDir:     b.TempDir(),
+				CacheSizeMB: cacheSizeMB,
+			})
+			assert.NoError(b, err)
+			kv, err := factory.NewKV(common.DefaultNamespace, 1)
+			assert.NoError(b, err)
+
+			wb := kv.NewWriteBatch()
+			for i := 0; i < keys; i++ {
+				assert.NoError(b, wb.Put(fmt.Sprintf("key-%06d", i), value))
+			}
+			assert.NoError(b, wb.Commit())
+			assert.NoError(b, wb.Close())
+
+			// Read from the files rather than from the memtable
+			assert.NoError(b, kv.Flush())
+
+			r := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _, closer, err := kv.Get(fmt.Sprintf("key-%06d", r.Intn(keys)), ComparisonEqual)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_ = closer.Close()
+			}
+			b.StopTimer()
+
+			assert.NoError(b, kv.Close())
+			assert.NoError(b, factory.Close())
+		})
+	}
+}
+
+func TestPebbleReadSnapshot(t *testing.T) {
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	kv, err := factory.NewKV(common.DefaultNamespace, 1)
+	assert.NoError(t, err)
+
+	wb := kv.NewWriteBatch()
+	assert.NoError(t, wb.Put("a", []byte("0")))
+	assert.NoError(t, wb.Put("c", []byte("2")))
+	assert.NoError(t, wb.Commit())
+	assert.NoError(t, wb.Close())
+
+	snapshot, err := kv.NewReadSnapshot()
+	assert.NoError(t, err)
+
+	wb = kv.NewWriteBatch()
+	assert.NoError(t, wb.Put("a", []byte("00")))
+	assert.NoError(t, wb.Put("b", []byte("1")))
+	assert.NoError(t, wb.Commit())
+	assert.NoError(t, wb.Close())
+
+	// The snapshot doesn't see the writes that came after it
+	key, res, closer, err := snapshot.Get("a", ComparisonEqual)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", key)
+	assert.Equal(t, "0", string(res))
+	assert.NoError(t, closer.Close())
+
+	_, _, _, err = snapshot.Get("b", ComparisonEqual)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	key, res, closer, err = snapshot.Get("b", ComparisonCeiling)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", key)
+	assert.Equal(t, "2", string(res))
+	assert.NoError(t, closer.Close())
+	assert.NoError(t, snapshot.Close())
+
+	key, res, closer, err = kv.Get("a", ComparisonEqual)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", key)
+	assert.Equal(t, "00", string(res))
+	assert.NoError(t, closer.Close())
+
+	assert.NoError(t, kv.Close())
+	assert.NoError(t, factory.Close())
+}
+
+func TestPebbleCompact(t *testing.T) {
+	factory, err := NewPebbleKVFactory(&FactoryOptions{DataDir: t.TempDir(), CacheSizeMB: 1})
+	assert.NoError(t, err)
+	kv, err := factory.NewKV(common.DefaultNamespace, 1)
+	assert.NoError(t, err)
+	p := kv.(*Pebble)
+
+	// Nothing to compact in an empty database
+	reclaimed, err := kv.Compact()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, reclaimed)
+
+	// Each round of writes goes into its own table, which keeps the
+	// overwritten and the deleted records until they are compacted
+	r := rand.New(rand.NewSource(1))
+	write := func(f func(wb WriteBatch, key string) error) {
+		wb := kv.NewWriteBatch()
+		for i := 0; i < 1000; i++ {
+			assert.NoError(t, f(wb, fmt.Sprintf("/key/%04d", i)))
+		}
+		assert.NoError(t, wb.Commit())
+		assert.NoError(t, wb.Close())
+		assert.NoError(t, kv.Flush())
+	}
+	put := func(wb WriteBatch, key string) error {
+		value := make([]byte, 1024)
+		r.Read(value)
+		return wb.Put(key, value)
+	}
+	write(put)
+	write(put)
+	write(func(wb WriteBatch, key string) error {
+		if key < "/key/0900" {
+			return wb.Delete(key)
+		}
+		return nil
+	})
+
+	// The engine counts the tombstones of the tables in the background
+	var before *pebble.Metrics
+	assert.Eventually(t, func() bool {
+		before = p.db.Metrics()
+		return before.Keys.TombstoneCount == 900
+	}, 10*time.Second, 10*time.Millisecond)
+
+	reclaimed, err = kv.Compact()
+	assert.NoError(t, err)
+
+	after := p.db.Metrics()
+	assert.EqualValues(t, 0, after.Keys.TombstoneCount)
+	// The engine might have compacted some of the tables on its own already
+	assert.Positive(t, reclaimed)
+	assert.LessOrEqual(t, reclaimed, int64(before.Total().Size-after.Total().Size))
+	// Only one out of the 20 values written is still live
+	assert.Less(t, after.Total().Size, before.Total().Size/10)
+	assert.NotZero(t, p.lastCompaction.Load())
+
+	// The records that were not deleted are still there
+	key, value, closer, err := kv.Get("/key/0900", ComparisonEqual)
+	assert.NoError(t, err)
+	assert.Equal(t, "/key/0900", key)
+	assert.Len(t, value, 1024)
+	assert.NoError(t, closer.Close())
+
+	_, _, _, err = kv.Get("/key/0000", ComparisonEqual)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	assert.NoError(t, kv.Close())
+
+	_, err = kv.Compact()
+	assert.ErrorIs(t, err, ErrDbClosed)
+	assert.NoError(t, factory.Close())
+}
@@ -58,7 +58,7 @@ func newNotifications(shardId int64, offset int64, timestamp uint64) *notificati
 	}
 }
 
-func (n *notifications) Modified(key string, versionId, modificationsCount int64) {
+func (n *notifications) Modified(key string, versionId, modificationsCount int64, createdTimestamp uint64) {
 	if strings.HasPrefix(key, common.InternalKeyPrefix) {
 		return
 	}
@@ -67,8 +67,9 @@ func (n *notifications) Modified(key string, versionId, modificationsCount int64
 		nType = proto.NotificationType_KEY_MODIFIED
 	}
 	n.batch.Notifications[key] = &proto.Notification{
-		Type:      nType,
-		VersionId: &versionId,
+		Type:             nType,
+		VersionId:        &versionId,
+		CreatedTimestamp: &createdTimestamp,
 	}
 }
 
@@ -164,10 +165,24 @@ func (nt *notificationsTracker) waitForNotifications(ctx context.Context, startO
 }
 
 func (nt *notificationsTracker) ReadNextNotifications(ctx context.Context, startOffset int64) ([]*proto.NotificationBatch, error) {
-	if err := nt.waitForNotifications(ctx, startOffset); err != nil {
-		return nil, err
+	for {
+		if err := nt.waitForNotifications(ctx, startOffset); err != nil {
+			return nil, err
+		}
+
+		lastOffset := nt.lastOffset.Load()
+		res, err := nt.readNotifications(startOffset)
+		if err != nil || len(res) > 0 {
+			return res, err
+		}
+
+		// All the notifications up to the last committed offset were
+		// already trimmed, wait for the next ones
+		startOffset = lastOffset + 1
 	}
+}
 
+func (nt *notificationsTracker) readNotifications(startOffset int64) ([]*proto.NotificationBatch, error) {
 	it, err := nt.kv.RangeScan(notificationKey(startOffset), lastNotificationKey)
 	if err != nil {
 		return nil, err
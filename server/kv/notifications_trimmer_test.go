@@ -41,7 +41,7 @@ func TestNotificationsTrimmer(t *testing.T) {
 				Key:   fmt.Sprintf("key-%d", i),
 				Value: []byte("0"),
 			}},
-		}, i, uint64(i), NoOpCallback)
+		}, 0, i, uint64(i), NoOpCallback)
 		assert.NoError(t, err)
 	}
 
@@ -75,10 +75,56 @@ func TestNotificationsTrimmer(t *testing.T) {
 	}, 10*time.Second, 1*time.Second)
 }
 
-func firstNotification(t *testing.T, db DB) int64 {
+func TestNotificationsTrimmer_ReadAfterTrimmed(t *testing.T) {
+	clock := &common.MockedClock{}
+
+	factory, err := NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	dbx, err := NewDB(common.DefaultNamespace, 1, factory, 10*time.Millisecond, clock)
+	assert.NoError(t, err)
+	defer dbx.Close()
+
+	for i := int64(0); i < 10; i++ {
+		_, err = dbx.ProcessWrite(&proto.WriteRequest{
+			Puts: []*proto.PutRequest{{Key: fmt.Sprintf("key-%d", i), Value: []byte("0")}},
+		}, 0, i, uint64(i), NoOpCallback)
+		assert.NoError(t, err)
+	}
+
+	clock.Set(100)
+	assert.Eventually(t, func() bool {
+		return firstNotification(t, dbx) == -1
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// A reader positioned before the trimmed notifications waits for the
+	// next ones
+	ch := make(chan []*proto.NotificationBatch, 1)
+	go func() {
+		notifications, err := dbx.ReadNextNotifications(context.Background(), 0)
+		assert.NoError(t, err)
+		ch <- notifications
+	}()
+
+	select {
+	case <-ch:
+		assert.Fail(t, "there are no notifications to read")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_, err = dbx.ProcessWrite(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "key-10", Value: []byte("0")}},
+	}, 0, 10, 100, NoOpCallback)
+	assert.NoError(t, err)
+
+	notifications := <-ch
+	assert.Len(t, notifications, 1)
+	assert.EqualValues(t, 10, notifications[0].Offset)
+}
+
+func firstNotification(t *testing.T, dbx DB) int64 {
 	t.Helper()
 
-	nextNotifications, err := db.ReadNextNotifications(context.Background(), 0)
+	nextNotifications, err := dbx.(*db).notificationsTracker.readNotifications(0)
 	assert.NoError(t, err)
 
 	if len(nextNotifications) == 0 {
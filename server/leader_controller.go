@@ -22,11 +22,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"google.golang.org/grpc/status"
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
-	pb "google.golang.org/protobuf/proto"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/common/metrics"
@@ -35,6 +35,18 @@ import (
 	"github.com/streamnative/oxia/server/wal"
 )
 
+const (
+	// After the new leader has caught up, the time the old leader keeps rejecting writes
+	// while waiting for the coordinator to complete the transfer
+	leadershipTransferTimeout       = 30 * time.Second
+	leadershipTransferCheckInterval = 10 * time.Millisecond
+
+	// How often the leader looks for expired records, and the max number of
+	// records that are deleted on each pass
+	expiredKeysSweepInterval = 1 * time.Second
+	maxExpiredKeysPerSweep   = 1000
+)
+
 type GetResult struct {
 	Response *proto.GetResponse
 	Err      error
@@ -58,11 +70,24 @@ type LeaderController interface {
 
 	AddFollower(request *proto.AddFollowerRequest) (*proto.AddFollowerResponse, error)
 
+	// RemoveFollower Stops replicating to a follower that is being removed from the ensemble
+	RemoveFollower(request *proto.RemoveFollowerRequest) (*proto.RemoveFollowerResponse, error)
+
+	// TransferLeadership Stops accepting writes and waits for the new leader to have all the entries
+	TransferLeadership(ctx context.Context, request *proto.TransferLeadershipRequest) (*proto.TransferLeadershipResponse, error)
+
+	// SplitShard Replicates the shard into the children shards and then stops accepting requests
+	SplitShard(ctx context.Context, request *proto.SplitShardRequest) (*proto.SplitShardResponse, error)
+
 	GetNotifications(req *proto.NotificationsRequest, stream proto.OxiaClient_GetNotificationsServer) error
 
 	GetStatus(request *proto.GetStatusRequest) (*proto.GetStatusResponse, error)
 	DeleteShard(request *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error)
 
+	// GetSnapshot sends a snapshot of the database of the shard, to be
+	// kept as a backup
+	GetSnapshot(req *proto.GetSnapshotRequest, hashRange *proto.Int32HashRange, stream proto.OxiaCoordination_GetSnapshotServer) error
+
 	// Term The current term of the leader
 	Term() int64
 
@@ -77,21 +102,31 @@ type LeaderController interface {
 type leaderController struct {
 	sync.RWMutex
 
-	namespace         string
-	shardId           int64
-	status            proto.ServingStatus
-	term              int64
-	replicationFactor uint32
-	quorumAckTracker  QuorumAckTracker
-	followers         map[string]FollowerCursor
+	namespace        string
+	shardId          int64
+	status           proto.ServingStatus
+	statusListener   func(proto.ServingStatus)
+	term             int64
+	quorumAckTracker QuorumAckTracker
+	followers        map[string]FollowerCursor
 
 	// This represents the last entry in the WAL at the time this node
 	// became leader. It's used in the logic for deciding where to
 	// truncate the followers.
 	leaderElectionHeadEntryId *proto.EntryId
 
+	// While the leadership is being transferred to another node, new
+	// writes are rejected, so that the new leader can catch up
+	transferringLeadership bool
+
+	// Once the split entry is appended, the shard is replaced by the
+	// children shards and all the requests are rejected
+	split       *proto.ShardSplit
+	splitOffset int64
+
 	ctx            context.Context
 	cancel         context.CancelFunc
+	sweeperWg      sync.WaitGroup
 	wal            wal.Wal
 	db             kv.DB
 	rpcClient      ReplicationRpcProvider
@@ -101,9 +136,14 @@ type leaderController struct {
 	writeLatencyHisto       metrics.LatencyHistogram
 	headOffsetGauge         metrics.Gauge
 	commitOffsetGauge       metrics.Gauge
+	termGauge               metrics.Gauge
 	followerAckOffsetGauges map[string]metrics.Gauge
+	diskUsage               *diskUsageTracker
+
+	// The values of the entries larger than this are compressed
+	entryCompressionThreshold int
 
-	notificationDispatchers map[int64]*notificationDispatcher
+	notificationDispatchers *notificationDispatchers
 }
 
 func NewLeaderController(config Config, namespace string, shardId int64, rpcClient ReplicationRpcProvider, walFactory wal.Factory, kvFactory kv.Factory) (LeaderController, error) {
@@ -115,11 +155,14 @@ func NewLeaderController(config Config, namespace string, shardId int64, rpcClie
 		quorumAckTracker:        nil,
 		rpcClient:               rpcClient,
 		followers:               make(map[string]FollowerCursor),
-		notificationDispatchers: make(map[int64]*notificationDispatcher),
+		notificationDispatchers: newNotificationDispatchers(shardId),
 
 		writeLatencyHisto: metrics.NewLatencyHistogram("oxia_server_leader_write_latency",
 			"Latency for write operations in the leader", labels),
 		followerAckOffsetGauges: map[string]metrics.Gauge{},
+		diskUsage:               newDiskUsageTracker(namespace, shardId),
+
+		entryCompressionThreshold: config.EntryCompressionThreshold,
 	}
 
 	lc.headOffsetGauge = metrics.NewGauge("oxia_server_leader_head_offset",
@@ -141,6 +184,11 @@ func NewLeaderController(config Config, namespace string, shardId int64, rpcClie
 			return -1
 		})
 
+	lc.termGauge = metrics.NewGauge("oxia_server_leader_term",
+		"The term of the leader controller", "count", labels, func() int64 {
+			return lc.Term()
+		})
+
 	lc.ctx, lc.cancel = context.WithCancel(context.Background())
 
 	lc.sessionManager = NewSessionManager(lc.ctx, namespace, shardId, lc)
@@ -159,10 +207,28 @@ func NewLeaderController(config Config, namespace string, shardId int64, rpcClie
 	}
 
 	if lc.term != wal.InvalidTerm {
-		lc.status = proto.ServingStatus_FENCED
+		lc.setStatus(proto.ServingStatus_FENCED)
 	}
 
 	lc.setLogger()
+
+	if err = lc.diskUsage.update(lc.wal, lc.db); err != nil {
+		return nil, err
+	}
+
+	lc.sweeperWg.Add(1)
+	go common.DoWithLabels(
+		lc.ctx,
+		map[string]string{
+			"oxia":  "expired-keys-sweeper",
+			"shard": fmt.Sprintf("%d", lc.shardId),
+		},
+		func() {
+			defer lc.sweeperWg.Done()
+			lc.sweepExpiredKeys()
+		},
+	)
+
 	lc.log.Info("Created leader controller")
 	return lc, nil
 }
@@ -182,6 +248,29 @@ func (lc *leaderController) Status() proto.ServingStatus {
 	return lc.status
 }
 
+// Update the serving status and notify the listener, if it changed. It must
+// be called with the mutex held.
+func (lc *leaderController) setStatus(status proto.ServingStatus) {
+	if lc.status == status {
+		return
+	}
+
+	lc.status = status
+	if lc.statusListener != nil {
+		lc.statusListener(status)
+	}
+}
+
+// The listener is invoked with the current status straight away, and then
+// every time the status changes.
+func (lc *leaderController) setStatusListener(listener func(proto.ServingStatus)) {
+	lc.Lock()
+	defer lc.Unlock()
+
+	lc.statusListener = listener
+	listener(lc.status)
+}
+
 func (lc *leaderController) Term() int64 {
 	lc.RLock()
 	defer lc.RUnlock()
@@ -230,11 +319,9 @@ func (lc *leaderController) NewTerm(req *proto.NewTermRequest) (*proto.NewTermRe
 
 	lc.term = req.Term
 	lc.setLogger()
-	lc.status = proto.ServingStatus_FENCED
-	lc.replicationFactor = 0
-
-	lc.headOffsetGauge.Unregister()
-	lc.commitOffsetGauge.Unregister()
+	lc.setStatus(proto.ServingStatus_FENCED)
+	lc.transferringLeadership = false
+	lc.split = nil
 
 	if lc.quorumAckTracker != nil {
 		if err := lc.quorumAckTracker.Close(); err != nil {
@@ -252,9 +339,10 @@ func (lc *leaderController) NewTerm(req *proto.NewTermRequest) (*proto.NewTermRe
 	for _, g := range lc.followerAckOffsetGauges {
 		g.Unregister()
 	}
+	lc.followerAckOffsetGauges = map[string]metrics.Gauge{}
 
 	lc.followers = nil
-	headEntryId, err := getLastEntryIdInWal(lc.wal)
+	headEntryId, err := getHeadEntryId(lc.wal, lc.db)
 	if err != nil {
 		return nil, err
 	}
@@ -315,12 +403,11 @@ func (lc *leaderController) BecomeLeader(ctx context.Context, req *proto.BecomeL
 		return nil, common.ErrorInvalidTerm
 	}
 
-	lc.status = proto.ServingStatus_LEADER
-	lc.replicationFactor = req.GetReplicationFactor()
+	lc.setStatus(proto.ServingStatus_LEADER)
 	lc.followers = make(map[string]FollowerCursor)
 
 	var err error
-	lc.leaderElectionHeadEntryId, err = getLastEntryIdInWal(lc.wal)
+	lc.leaderElectionHeadEntryId, err = getHeadEntryId(lc.wal, lc.db)
 	if err != nil {
 		return nil, err
 	}
@@ -333,12 +420,37 @@ func (lc *leaderController) BecomeLeader(ctx context.Context, req *proto.BecomeL
 	lc.quorumAckTracker = NewQuorumAckTracker(req.GetReplicationFactor(), lc.leaderElectionHeadEntryId.Offset, leaderCommitOffset)
 	lc.sessionManager = NewSessionManager(lc.ctx, lc.namespace, lc.shardId, lc)
 
+	unreachable := map[string]*proto.EntryId{}
 	for follower, followerHeadEntryId := range req.FollowerMaps {
-		if err := lc.addFollower(follower, followerHeadEntryId); err != nil { //nolint:contextcheck
-			return nil, err
+		if err := lc.addFollower(follower, followerHeadEntryId, false); err != nil { //nolint:contextcheck
+			unreachable[follower] = followerHeadEntryId
 		}
 	}
 
+	// Without enough followers the entries can never be committed, so the
+	// coordinator has to elect another leader
+	if requiredAcks := int(req.GetReplicationFactor() / 2); len(lc.followers) < requiredAcks {
+		return nil, errors.Wrapf(common.ErrorNotEnoughFollowers, "%d followers are attached, %d are required",
+			len(lc.followers), requiredAcks)
+	}
+
+	for follower, followerHeadEntryId := range unreachable {
+		// The follower might be temporarily unreachable. We can still reach
+		// the quorum with the other followers, so we keep trying to add it
+		// in background
+		go common.DoWithLabels(
+			lc.ctx,
+			map[string]string{
+				"oxia":     "add-follower",
+				"shard":    fmt.Sprintf("%d", lc.shardId),
+				"follower": follower,
+			},
+			func() {
+				lc.addFollowerWithRetries(req.Term, follower, followerHeadEntryId)
+			},
+		)
+	}
+
 	// We must wait until all the entries in the leader WAL are fully
 	// committed in the quorum, to avoid missing any entries in the DB
 	// by the moment we make the leader controller accepting new write/read
@@ -351,6 +463,10 @@ func (lc *leaderController) BecomeLeader(ctx context.Context, req *proto.BecomeL
 		return nil, err
 	}
 
+	if err = lc.loadShardSplit(); err != nil {
+		return nil, err
+	}
+
 	lc.log.Info(
 		"Started leading the shard",
 		slog.Int64("term", lc.term),
@@ -375,18 +491,162 @@ func (lc *leaderController) AddFollower(req *proto.AddFollowerRequest) (*proto.A
 		return nil, errors.Errorf("follower %s is already present", req.FollowerName)
 	}
 
-	if len(lc.followers) == int(lc.replicationFactor)-1 {
+	// The replication factor is only raised when a new member has caught up
+	replicationFactor := lc.quorumAckTracker.ReplicationFactor()
+	if req.ReplicationFactor > replicationFactor {
+		// The follower is a new member of the ensemble
+		if req.ReplicationFactor != replicationFactor+1 || lc.quorumAckTracker.HasLearners() {
+			return nil, errors.Wrapf(ErrInvalidReplicationFactor,
+				"cannot change replication factor from %d to %d: members must be added one at a time",
+				replicationFactor, req.ReplicationFactor)
+		}
+
+		if err := lc.addFollower(req.FollowerName, req.FollowerHeadEntryId, true); err != nil {
+			return nil, err
+		}
+
+		return &proto.AddFollowerResponse{}, nil
+	}
+
+	if len(lc.followers) >= int(replicationFactor)-1 {
 		return nil, errors.New("all followers are already attached")
 	}
 
-	if err := lc.addFollower(req.FollowerName, req.FollowerHeadEntryId); err != nil {
+	if err := lc.addFollower(req.FollowerName, req.FollowerHeadEntryId, false); err != nil {
 		return nil, err
 	}
 
 	return &proto.AddFollowerResponse{}, nil
 }
 
-func (lc *leaderController) addFollower(follower string, followerHeadEntryId *proto.EntryId) error {
+func (lc *leaderController) RemoveFollower(req *proto.RemoveFollowerRequest) (*proto.RemoveFollowerResponse, error) {
+	lc.Lock()
+	defer lc.Unlock()
+
+	if req.Term != lc.term {
+		return nil, common.ErrorInvalidTerm
+	}
+
+	if lc.status != proto.ServingStatus_LEADER {
+		return nil, errors.Wrap(common.ErrorInvalidStatus, "Node is not leader")
+	}
+
+	cursor, found := lc.followers[req.FollowerName]
+	if !found {
+		return nil, errors.Errorf("follower %s is not present", req.FollowerName)
+	}
+
+	// Once the cursor is removed from the quorum, the entries that were only
+	// waiting for this follower are committed with the remaining ones
+	if err := cursor.Remove(); err != nil {
+		lc.log.Warn(
+			"Failed to remove follower cursor",
+			slog.Any("error", err),
+			slog.String("follower", req.FollowerName),
+		)
+	}
+
+	delete(lc.followers, req.FollowerName)
+	if g, ok := lc.followerAckOffsetGauges[req.FollowerName]; ok {
+		g.Unregister()
+		delete(lc.followerAckOffsetGauges, req.FollowerName)
+	}
+
+	lc.log.Info(
+		"Removed follower",
+		slog.Int64("term", lc.term),
+		slog.String("follower", req.FollowerName),
+		slog.Int("replication-factor", int(lc.quorumAckTracker.ReplicationFactor())),
+	)
+	return &proto.RemoveFollowerResponse{}, nil
+}
+
+func (lc *leaderController) TransferLeadership(ctx context.Context, req *proto.TransferLeadershipRequest) (*proto.TransferLeadershipResponse, error) {
+	lc.Lock()
+
+	if req.Term != lc.term {
+		lc.Unlock()
+		return nil, common.ErrorInvalidTerm
+	}
+
+	if lc.status != proto.ServingStatus_LEADER {
+		lc.Unlock()
+		return nil, errors.Wrap(common.ErrorInvalidStatus, "Node is not leader")
+	}
+
+	cursor, found := lc.followers[req.NewLeader]
+	if !found {
+		lc.Unlock()
+		return nil, errors.Errorf("follower %s is not present", req.NewLeader)
+	}
+
+	// Only a follower that is keeping up with the committed entries can take
+	// over, otherwise the shard would stay unavailable while it catches up
+	if cursor.AckOffset() < lc.quorumAckTracker.CommitOffset() {
+		lc.Unlock()
+		return nil, errors.Errorf("follower %s is lagging behind: ack-offset %d, commit-offset %d",
+			req.NewLeader, cursor.AckOffset(), lc.quorumAckTracker.CommitOffset())
+	}
+
+	// From now on, no new entries are appended, so the pipeline can be flushed
+	lc.transferringLeadership = true
+	term := lc.term
+	headOffset := lc.wal.LastOffset()
+	lc.Unlock()
+
+	lc.log.Info(
+		"Transferring leadership",
+		slog.String("new-leader", req.NewLeader),
+		slog.Int64("head-offset", headOffset),
+	)
+
+	if err := waitForFollowerAck(ctx, cursor, headOffset); err != nil {
+		lc.stopTransferringLeadership(term)
+		return nil, errors.Wrapf(err, "follower %s has not caught up", req.NewLeader)
+	}
+
+	// If the coordinator doesn't complete the transfer, start accepting
+	// writes again
+	time.AfterFunc(leadershipTransferTimeout, func() {
+		lc.stopTransferringLeadership(term)
+	})
+
+	lc.log.Info(
+		"New leader has caught up",
+		slog.String("new-leader", req.NewLeader),
+		slog.Int64("head-offset", headOffset),
+	)
+	return &proto.TransferLeadershipResponse{
+		HeadEntryId: &proto.EntryId{Term: term, Offset: headOffset},
+	}, nil
+}
+
+func (lc *leaderController) stopTransferringLeadership(term int64) {
+	lc.Lock()
+	defer lc.Unlock()
+
+	if lc.term == term && lc.transferringLeadership {
+		lc.log.Warn("Leadership transfer was not completed, accepting writes again")
+		lc.transferringLeadership = false
+	}
+}
+
+func waitForFollowerAck(ctx context.Context, cursor FollowerCursor, offset int64) error {
+	ticker := time.NewTicker(leadershipTransferCheckInterval)
+	defer ticker.Stop()
+
+	for cursor.AckOffset() < offset {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (lc *leaderController) addFollower(follower string, followerHeadEntryId *proto.EntryId, learner bool) error {
 	followerHeadEntryId, err := lc.truncateFollowerIfNeeded(follower, followerHeadEntryId)
 	if err != nil {
 		lc.log.Error(
@@ -399,8 +659,14 @@ func (lc *leaderController) addFollower(follower string, followerHeadEntryId *pr
 		return err
 	}
 
-	cursor, err := NewFollowerCursor(follower, lc.term, lc.namespace, lc.shardId, lc.rpcClient, lc.quorumAckTracker, lc.wal, lc.db,
-		followerHeadEntryId.Offset)
+	return lc.attachFollower(follower, followerHeadEntryId, learner)
+}
+
+// attachFollower starts replicating to a follower whose log does not
+// need truncation.
+func (lc *leaderController) attachFollower(follower string, followerHeadEntryId *proto.EntryId, learner bool) error {
+	cursor, err := newFollowerCursor(follower, lc.term, lc.namespace, lc.shardId, lc.rpcClient, lc.quorumAckTracker, lc.wal, lc.db,
+		followerHeadEntryId.Offset, learner)
 	if err != nil {
 		lc.log.Error(
 			"Failed to create follower cursor",
@@ -420,35 +686,106 @@ func (lc *leaderController) addFollower(follower string, followerHeadEntryId *pr
 		slog.Int64("head-offset", lc.wal.LastOffset()),
 	)
 	lc.followers[follower] = cursor
-	lc.followerAckOffsetGauges[follower] = metrics.NewGauge("oxia_server_follower_ack_offset", "", "count",
-		map[string]any{
-			"shard":    lc.shardId,
-			"follower": follower,
-		}, func() int64 {
+	labels := metrics.LabelsForShard(lc.namespace, lc.shardId)
+	labels["follower"] = follower
+	lc.followerAckOffsetGauges[follower] = metrics.NewGauge("oxia_server_follower_ack_offset",
+		"The last offset acknowledged by the follower", "count", labels, func() int64 {
 			return cursor.AckOffset()
 		})
 	return nil
 }
 
+// addFollowerWithRetries keeps trying to add a follower that was not reachable
+// when the leader was elected. The follower is truncated without holding the
+// lock, since the RPC can take long with a follower that is still unreachable.
+func (lc *leaderController) addFollowerWithRetries(term int64, follower string, followerHeadEntryId *proto.EntryId) {
+	// Whether the follower still has to be added in the term
+	isMissing := func() (bool, error) {
+		if lc.isClosed() || lc.term != term || lc.status != proto.ServingStatus_LEADER {
+			// We're not leading anymore in the term
+			return false, backoff.Permanent(common.ErrorInvalidStatus)
+		}
+
+		// The follower might have been added in the meantime
+		_, followerAlreadyPresent := lc.followers[follower]
+		return !followerAlreadyPresent, nil
+	}
+
+	_ = backoff.RetryNotify(func() error {
+		lc.Lock()
+		missing, err := isMissing()
+		if !missing {
+			lc.Unlock()
+			return err
+		}
+
+		truncateTo, err := lc.followerTruncationEntry(followerHeadEntryId)
+		lc.Unlock()
+		if err != nil {
+			return err
+		}
+
+		headEntryId := followerHeadEntryId
+		if truncateTo != nil {
+			if headEntryId, err = lc.truncateFollower(term, follower, truncateTo); err != nil {
+				return err
+			}
+		}
+
+		lc.Lock()
+		defer lc.Unlock()
+
+		if missing, err = isMissing(); !missing {
+			return err
+		}
+
+		return lc.attachFollower(follower, headEntryId, false)
+	}, common.NewBackOff(lc.ctx), func(err error, duration time.Duration) {
+		lc.log.Warn(
+			"Failed to add follower, retrying later",
+			slog.String("follower", follower),
+			slog.Any("error", err),
+			slog.Duration("retry-after", duration),
+		)
+	})
+}
+
 func (lc *leaderController) applyAllEntriesIntoDBLoop(r wal.Reader) error {
+	decoder := newEntryDecoder()
+	defer decoder.close()
+
+	batch := lc.db.NewApplyBatch()
+	defer func() {
+		if err := batch.Close(); err != nil {
+			lc.log.Error(
+				"Error closing batch used for applying pending entries",
+				slog.Any("error", err),
+			)
+		}
+	}()
+
 	for r.HasNext() {
 		entry, err := r.ReadNext()
 		if err != nil {
 			return err
 		}
 
-		logEntryValue := &proto.LogEntryValue{}
-		if err = pb.Unmarshal(entry.Value, logEntryValue); err != nil {
+		logEntryValue, err := decoder.decode(entry)
+		if err != nil {
 			return err
 		}
-		for _, writeRequest := range logEntryValue.GetRequests().Writes {
-			if _, err = lc.db.ProcessWrite(writeRequest, entry.Offset, entry.Timestamp, SessionUpdateOperationCallback); err != nil {
+		if err = applyLogEntry(lc.db, batch, entry, logEntryValue); err != nil {
+			return err
+		}
+
+		if isApplyBatchFull(batch) {
+			if err = batch.Commit(); err != nil {
 				return err
 			}
 		}
 	}
 
-	return nil
+	return batch.Commit()
 }
 
 func (lc *leaderController) applyAllEntriesIntoDB() error {
@@ -498,10 +835,43 @@ func (lc *leaderController) truncateFollowerIfNeeded(follower string, followerHe
 		slog.Any("leader-head-entry", lc.leaderElectionHeadEntryId),
 		slog.Any("follower-head-entry", followerHeadEntryId),
 	)
+
+	truncateTo, err := lc.followerTruncationEntry(followerHeadEntryId)
+	if err != nil {
+		return nil, err
+	}
+
+	if truncateTo == nil {
+		lc.log.Debug(
+			"No need to truncate follower",
+			slog.Int64("term", lc.term),
+			slog.String("follower", follower),
+			slog.Any("follower-head-entry", followerHeadEntryId),
+		)
+		return followerHeadEntryId, nil
+	}
+
+	headEntryId, err := lc.truncateFollower(lc.term, follower, truncateTo)
+	if err != nil {
+		return nil, err
+	}
+
+	lc.log.Info(
+		"Truncated follower",
+		slog.Int64("term", lc.term),
+		slog.String("follower", follower),
+		slog.Any("follower-head-entry", headEntryId),
+	)
+	return headEntryId, nil
+}
+
+// followerTruncationEntry returns the entry the log of the follower must be
+// truncated to, or nil if the log of the follower is a prefix of the leader one.
+func (lc *leaderController) followerTruncationEntry(followerHeadEntryId *proto.EntryId) (*proto.EntryId, error) {
 	if followerHeadEntryId.Term == lc.leaderElectionHeadEntryId.Term &&
 		followerHeadEntryId.Offset <= lc.leaderElectionHeadEntryId.Offset {
 		// No need for truncation
-		return followerHeadEntryId, nil
+		return nil, nil
 	}
 
 	// Coordinator should never send us a follower with an invalid term.
@@ -519,34 +889,26 @@ func (lc *leaderController) truncateFollowerIfNeeded(follower string, followerHe
 		followerHeadEntryId.Offset <= lastEntryInFollowerTerm.Offset {
 		// If the follower is on a previous term, but we have the same entry,
 		// we don't need to truncate
-		lc.log.Debug(
-			"No need to truncate follower",
-			slog.Int64("term", lc.term),
-			slog.String("follower", follower),
-			slog.Any("last-entry-in-follower-term", lastEntryInFollowerTerm),
-			slog.Any("follower-head-entry", followerHeadEntryId),
-		)
-		return followerHeadEntryId, nil
+		return nil, nil
 	}
 
+	return lastEntryInFollowerTerm, nil
+}
+
+// truncateFollower sends the truncate request to the follower. It only uses
+// the fields of the controller that never change, so it can be called
+// without holding the lock.
+func (lc *leaderController) truncateFollower(term int64, follower string, headEntryId *proto.EntryId) (*proto.EntryId, error) {
 	tr, err := lc.rpcClient.Truncate(follower, &proto.TruncateRequest{
 		Namespace:   lc.namespace,
 		Shard:       lc.shardId,
-		Term:        lc.term,
-		HeadEntryId: lastEntryInFollowerTerm,
+		Term:        term,
+		HeadEntryId: headEntryId,
 	})
-
 	if err != nil {
 		return nil, err
 	}
 
-	lc.log.Info(
-		"Truncated follower",
-		slog.Int64("term", lc.term),
-		slog.String("follower", follower),
-		slog.Any("follower-head-entry", tr.HeadEntryId),
-	)
-
 	return tr.HeadEntryId, nil
 }
 
@@ -574,9 +936,7 @@ func getHighestEntryOfTerm(w wal.Wal, term int64) (*proto.EntryId, error) {
 func (lc *leaderController) Read(ctx context.Context, request *proto.ReadRequest) <-chan GetResult {
 	ch := make(chan GetResult)
 
-	lc.RLock()
-	err := checkStatusIsLeader(lc.status)
-	lc.RUnlock()
+	err := lc.checkAcceptsReads()
 	if err != nil {
 		go func() {
 			ch <- GetResult{Err: err}
@@ -600,11 +960,15 @@ func (lc *leaderController) read(ctx context.Context, request *proto.ReadRequest
 		func() {
 			lc.log.Debug("Received read request")
 
-			for _, get := range request.Gets {
-				response, err := lc.db.Get(get)
-				if err != nil {
-					return
-				}
+			// The gets of the request observe the same state of the shard
+			responses, err := lc.db.GetBatch(request.Gets)
+			if err != nil {
+				ch <- GetResult{Err: err}
+				close(ch)
+				return
+			}
+
+			for _, response := range responses {
 				ch <- GetResult{Response: response}
 				if ctx.Err() != nil {
 					ch <- GetResult{Err: ctx.Err()}
@@ -619,9 +983,7 @@ func (lc *leaderController) read(ctx context.Context, request *proto.ReadRequest
 func (lc *leaderController) List(ctx context.Context, request *proto.ListRequest) (<-chan string, error) {
 	ch := make(chan string)
 
-	lc.RLock()
-	err := checkStatusIsLeader(lc.status)
-	lc.RUnlock()
+	err := lc.checkAcceptsReads()
 	if err != nil {
 		return nil, err
 	}
@@ -640,35 +1002,39 @@ func (lc *leaderController) list(ctx context.Context, request *proto.ListRequest
 			"peer":  common.GetPeer(ctx),
 		},
 		func() {
-			lc.log.Debug("Received list request", slog.Any("request", request))
+			listFromDB(ctx, lc.db, request, ch, lc.log)
+		},
+	)
+}
 
-			it, err := lc.db.List(request)
-			if err != nil {
-				lc.log.Warn(
-					"Failed to process list request",
-					slog.Any("error", err),
-				)
-				close(ch)
-				return
-			}
+func listFromDB(ctx context.Context, db kv.DB, request *proto.ListRequest, ch chan<- string, log *slog.Logger) {
+	log.Debug("Received list request", slog.Any("request", request))
 
-			defer func() {
-				_ = it.Close()
-				// NOTE:
-				// we must close the channel after iterator is closed, to avoid the
-				// iterator keep open when caller is trying to process the next step (for example db.Close)
-				// because this is execute in another goroutine.
-				close(ch)
-			}()
+	it, err := db.List(request)
+	if err != nil {
+		log.Warn(
+			"Failed to process list request",
+			slog.Any("error", err),
+		)
+		close(ch)
+		return
+	}
 
-			for ; it.Valid(); it.Next() {
-				ch <- it.Key()
-				if ctx.Err() != nil {
-					break
-				}
-			}
-		},
-	)
+	defer func() {
+		_ = it.Close()
+		// NOTE:
+		// we must close the channel after iterator is closed, to avoid the
+		// iterator keep open when caller is trying to process the next step (for example db.Close)
+		// because this is execute in another goroutine.
+		close(ch)
+	}()
+
+	for ; it.Valid(); it.Next() {
+		ch <- it.Key()
+		if ctx.Err() != nil {
+			break
+		}
+	}
 }
 
 func (lc *leaderController) ListSliceNoMutex(ctx context.Context, request *proto.ListRequest) ([]string, error) {
@@ -692,9 +1058,7 @@ func (lc *leaderController) RangeScan(ctx context.Context, request *proto.RangeS
 	ch := make(chan *proto.GetResponse)
 	errCh := make(chan error)
 
-	lc.RLock()
-	err := checkStatusIsLeader(lc.status)
-	lc.RUnlock()
+	err := lc.checkAcceptsReads()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -713,44 +1077,48 @@ func (lc *leaderController) rangeScan(ctx context.Context, request *proto.RangeS
 			"peer":  common.GetPeer(ctx),
 		},
 		func() {
-			lc.log.Debug("Received list request", slog.Any("request", request))
+			rangeScanFromDB(ctx, lc.db, request, ch, errCh, lc.log)
+		},
+	)
+}
 
-			it, err := lc.db.RangeScan(request)
-			if err != nil {
-				lc.log.Warn(
-					"Failed to process range-scan request",
-					slog.Any("error", err),
-				)
-				errCh <- err
-				close(ch)
-				close(errCh)
-				return
-			}
+func rangeScanFromDB(ctx context.Context, db kv.DB, request *proto.RangeScanRequest, ch chan<- *proto.GetResponse, errCh chan<- error, log *slog.Logger) {
+	log.Debug("Received range-scan request", slog.Any("request", request))
 
-			defer func() {
-				_ = it.Close()
-				// NOTE:
-				// we must close the channel after iterator is closed, to avoid the
-				// iterator keep open when caller is trying to process the next step (for example db.Close)
-				// because this is execute in another goroutine.
-				close(ch)
-				close(errCh)
-			}()
-
-			for ; it.Valid(); it.Next() {
-				gr, err := it.Value()
-				if err != nil {
-					errCh <- err
-					return
-				}
+	it, err := db.RangeScan(request)
+	if err != nil {
+		log.Warn(
+			"Failed to process range-scan request",
+			slog.Any("error", err),
+		)
+		errCh <- err
+		close(ch)
+		close(errCh)
+		return
+	}
 
-				ch <- gr
-				if ctx.Err() != nil {
-					break
-				}
-			}
-		},
-	)
+	defer func() {
+		_ = it.Close()
+		// NOTE:
+		// we must close the channel after iterator is closed, to avoid the
+		// iterator keep open when caller is trying to process the next step (for example db.Close)
+		// because this is execute in another goroutine.
+		close(ch)
+		close(errCh)
+	}()
+
+	for ; it.Valid(); it.Next() {
+		gr, err := it.Value()
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		ch <- gr
+		if ctx.Err() != nil {
+			break
+		}
+	}
 }
 
 // Write
@@ -766,32 +1134,94 @@ func (lc *leaderController) Write(ctx context.Context, request *proto.WriteReque
 	return resp, err
 }
 
+// The expired records are deleted through the replicated log, in the same
+// way as the client deletes, so that all the replicas and the snapshots
+// stay consistent.
+func (lc *leaderController) sweepExpiredKeys() {
+	ticker := time.NewTicker(expiredKeysSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := lc.deleteExpiredKeys(); err != nil && !lc.isClosed() {
+				lc.log.Warn(
+					"Failed to delete expired keys",
+					slog.Any("error", err),
+				)
+			}
+		case <-lc.ctx.Done():
+			return
+		}
+	}
+}
+
+func (lc *leaderController) deleteExpiredKeys() error {
+	lc.RLock()
+	if lc.status != proto.ServingStatus_LEADER || lc.db == nil {
+		lc.RUnlock()
+		return nil
+	}
+
+	deletes, err := lc.db.ExpiredKeys(uint64(time.Now().UnixMilli()), maxExpiredKeysPerSweep)
+	lc.RUnlock()
+	if err != nil || len(deletes) == 0 {
+		return err
+	}
+
+	lc.log.Debug(
+		"Deleting expired keys",
+		slog.Int("count", len(deletes)),
+	)
+	_, err = lc.Write(lc.ctx, &proto.WriteRequest{
+		Shard:   &lc.shardId,
+		Deletes: deletes,
+	})
+	return err
+}
+
 func (lc *leaderController) write(ctx context.Context, request func(int64) *proto.WriteRequest) (int64, *proto.WriteResponse, error) {
 	timer := lc.writeLatencyHisto.Timer()
 	defer timer.Done() //nolint:contextcheck
 
 	lc.log.Debug("Write operation")
 
-	actualRequest, newOffset, timestamp, err := lc.appendToWal(ctx, request)
+	actualRequest, term, newOffset, timestamp, err := lc.appendToWal(ctx, request)
 	if err != nil {
 		return wal.InvalidOffset, nil, err
 	}
 
-	resp, err := lc.quorumAckTracker.WaitForCommitOffset(ctx, newOffset, func() (*proto.WriteResponse, error) {
-		return lc.db.ProcessWrite(actualRequest, newOffset, timestamp, SessionUpdateOperationCallback)
+	lc.RLock()
+	quorumAckTracker, db := lc.quorumAckTracker, lc.db
+	lc.RUnlock()
+	if quorumAckTracker == nil || db == nil {
+		return wal.InvalidOffset, nil, common.ErrorAlreadyClosed
+	}
+
+	resp, err := quorumAckTracker.WaitForCommitOffset(ctx, newOffset, func() (*proto.WriteResponse, error) {
+		return db.ProcessWrite(actualRequest, term, newOffset, timestamp, SessionUpdateOperationCallback)
 	})
 	return newOffset, resp, err
 }
 
-func (lc *leaderController) appendToWal(ctx context.Context, request func(int64) *proto.WriteRequest) (actualRequest *proto.WriteRequest, offset int64, timestamp uint64, err error) {
+func (lc *leaderController) appendToWal(ctx context.Context, request func(int64) *proto.WriteRequest) (actualRequest *proto.WriteRequest, term int64, offset int64, timestamp uint64, err error) {
 	lc.Lock()
 
-	if err := checkStatusIsLeader(lc.status); err != nil {
+	if err := lc.checkAcceptsWrites(); err != nil {
 		lc.Unlock()
-		return nil, wal.InvalidOffset, 0, err
+		return nil, wal.InvalidTerm, wal.InvalidOffset, 0, err
 	}
 
-	newOffset := lc.quorumAckTracker.NextOffset()
+	// The handles are captured under the lock, because the controller can be
+	// closed while the wal is synced
+	walObject, quorumAckTracker := lc.wal, lc.quorumAckTracker
+	if walObject == nil || quorumAckTracker == nil {
+		lc.Unlock()
+		return nil, wal.InvalidTerm, wal.InvalidOffset, 0, common.ErrorAlreadyClosed
+	}
+
+	term = lc.term
+	newOffset := quorumAckTracker.NextOffset()
 	timestamp = uint64(time.Now().UnixMilli())
 	actualRequest = request(newOffset)
 
@@ -811,29 +1241,29 @@ func (lc *leaderController) appendToWal(ctx context.Context, request func(int64)
 	value, err := logEntryValue.MarshalVT()
 	if err != nil {
 		lc.Unlock()
-		return actualRequest, wal.InvalidOffset, timestamp, err
+		return actualRequest, term, wal.InvalidOffset, timestamp, err
 	}
 	logEntry := &proto.LogEntry{
-		Term:      lc.term,
+		Term:      term,
 		Offset:    newOffset,
-		Value:     value,
 		Timestamp: timestamp,
 	}
+	compressLogEntryValue(logEntry, value, lc.entryCompressionThreshold)
 
-	if err = lc.wal.AppendAsync(logEntry); err != nil {
+	if err = walObject.AppendAsync(logEntry); err != nil {
 		lc.Unlock()
-		return actualRequest, wal.InvalidOffset, timestamp, errors.Wrap(err, "oxia: failed to append to wal")
+		return actualRequest, term, wal.InvalidOffset, timestamp, errors.Wrap(err, "oxia: failed to append to wal")
 	}
 
 	lc.Unlock()
 
 	// Sync the WAL outside the mutex, so that we can have multiple waiting
 	// sync requests
-	if err = lc.wal.Sync(ctx); err != nil {
-		return actualRequest, wal.InvalidOffset, timestamp, errors.Wrap(err, "oxia: failed to sync the wal")
+	if err = walObject.Sync(ctx); err != nil {
+		return actualRequest, term, wal.InvalidOffset, timestamp, errors.Wrap(err, "oxia: failed to sync the wal")
 	}
-	lc.quorumAckTracker.AdvanceHeadOffset(newOffset)
-	return actualRequest, newOffset, timestamp, nil
+	quorumAckTracker.AdvanceHeadOffset(newOffset)
+	return actualRequest, term, newOffset, timestamp, nil
 }
 
 func (lc *leaderController) WriteStream(stream proto.OxiaClient_WriteStreamServer) error {
@@ -880,23 +1310,23 @@ func (lc *leaderController) handleWriteStream(stream proto.OxiaClient_WriteStrea
 		slog.Debug("Got request in stream",
 			slog.Any("req", req))
 
-		lc.appendToWalStreamRequest(req, func(offset int64, timestamp uint64, err error) {
-			lc.handleWalSynced(stream, req, closeCh, offset, timestamp, err, timer)
+		lc.appendToWalStreamRequest(req, func(term int64, offset int64, timestamp uint64, err error) {
+			lc.handleWalSynced(stream, req, closeCh, term, offset, timestamp, err, timer)
 		})
 	}
 }
 
 func (lc *leaderController) handleWalSynced(stream proto.OxiaClient_WriteStreamServer,
 	req *proto.WriteRequest, closeCh chan error,
-	offset int64, timestamp uint64, err error, timer metrics.Timer) {
+	term int64, offset int64, timestamp uint64, err error, timer metrics.Timer) {
 	if err != nil {
 		timer.Done()
-		closeCh <- err
+		lc.closeWriteStream(closeCh, err)
 		return
 	}
 
 	lc.quorumAckTracker.WaitForCommitOffsetAsync(offset, func() (*proto.WriteResponse, error) {
-		return lc.db.ProcessWrite(req, offset, timestamp, SessionUpdateOperationCallback)
+		return lc.db.ProcessWrite(req, term, offset, timestamp, SessionUpdateOperationCallback)
 	}, func(response *proto.WriteResponse, err error) {
 		if err != nil {
 			timer.Done()
@@ -914,15 +1344,16 @@ func (lc *leaderController) handleWalSynced(stream proto.OxiaClient_WriteStreamS
 }
 
 func (lc *leaderController) appendToWalStreamRequest(request *proto.WriteRequest,
-	callback func(offset int64, timestamp uint64, err error)) {
+	callback func(term int64, offset int64, timestamp uint64, err error)) {
 	lc.Lock()
 
-	if err := checkStatusIsLeader(lc.status); err != nil {
+	if err := lc.checkAcceptsWrites(); err != nil {
 		lc.Unlock()
-		callback(wal.InvalidOffset, 0, err)
+		callback(wal.InvalidTerm, wal.InvalidOffset, 0, err)
 		return
 	}
 
+	term := lc.term
 	newOffset := lc.quorumAckTracker.NextOffset()
 	timestamp := uint64(time.Now().UnixMilli())
 
@@ -942,22 +1373,22 @@ func (lc *leaderController) appendToWalStreamRequest(request *proto.WriteRequest
 	value, err := logEntryValue.MarshalVT()
 	if err != nil {
 		lc.Unlock()
-		callback(wal.InvalidOffset, timestamp, err)
+		callback(term, wal.InvalidOffset, timestamp, err)
 		return
 	}
 	logEntry := &proto.LogEntry{
-		Term:      lc.term,
+		Term:      term,
 		Offset:    newOffset,
-		Value:     value,
 		Timestamp: timestamp,
 	}
+	compressLogEntryValue(logEntry, value, lc.entryCompressionThreshold)
 
 	lc.wal.AppendAndSync(logEntry, func(err error) {
 		if err != nil {
-			callback(wal.InvalidOffset, timestamp, errors.Wrap(err, "oxia: failed to append to wal"))
+			callback(term, wal.InvalidOffset, timestamp, errors.Wrap(err, "oxia: failed to append to wal"))
 		} else {
 			lc.quorumAckTracker.AdvanceHeadOffset(newOffset)
-			callback(newOffset, timestamp, nil)
+			callback(term, newOffset, timestamp, nil)
 		}
 	})
 	lc.Unlock()
@@ -966,7 +1397,27 @@ func (lc *leaderController) appendToWalStreamRequest(request *proto.WriteRequest
 // ////
 
 func (lc *leaderController) GetNotifications(req *proto.NotificationsRequest, stream proto.OxiaClient_GetNotificationsServer) error {
-	return startNotificationDispatcher(lc, req, stream)
+	lc.Lock()
+	if lc.isClosed() {
+		lc.Unlock()
+		return common.ErrorAlreadyClosed
+	}
+
+	qat := lc.quorumAckTracker
+	commitOffset := func() (int64, error) {
+		if qat == nil {
+			return wal.InvalidOffset, errors.New("leader is not yet ready")
+		}
+		return qat.CommitOffset(), nil
+	}
+
+	nd, err := lc.notificationDispatchers.startDispatcher(lc.db, commitOffset, lc.log, req, stream)
+	lc.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return nd.wait(lc.ctx)
 }
 
 func (lc *leaderController) isClosed() bool {
@@ -974,15 +1425,25 @@ func (lc *leaderController) isClosed() bool {
 }
 
 func (lc *leaderController) Close() error {
+	lc.stopSweeper()
+
 	lc.Lock()
 	defer lc.Unlock()
 	return lc.close()
 }
 
+// stopSweeper waits for the expired keys sweeper to exit, so that its writes
+// don't use the wal and the db after they are closed. It must be called
+// without holding the mutex, since the sweeper takes it.
+func (lc *leaderController) stopSweeper() {
+	lc.cancel()
+	lc.sweeperWg.Wait()
+}
+
 func (lc *leaderController) close() error {
 	lc.log.Info("Closing leader controller")
 
-	lc.status = proto.ServingStatus_NOT_MEMBER
+	lc.setStatus(proto.ServingStatus_NOT_MEMBER)
 	lc.cancel()
 
 	var err error
@@ -996,10 +1457,20 @@ func (lc *leaderController) close() error {
 	}
 	lc.followerAckOffsetGauges = map[string]metrics.Gauge{}
 
+	lc.headOffsetGauge.Unregister()
+	lc.commitOffsetGauge.Unregister()
+	lc.termGauge.Unregister()
+	lc.diskUsage.Close()
+
 	err = lc.sessionManager.Close()
 
-	for _, nd := range lc.notificationDispatchers {
-		nd.close()
+	lc.notificationDispatchers.close()
+
+	// The tracker is closed first, so that the acks of the followers that
+	// are still in flight don't apply the entries to a closed db
+	if lc.quorumAckTracker != nil {
+		err = multierr.Append(err, lc.quorumAckTracker.Close())
+		lc.quorumAckTracker = nil
 	}
 
 	if lc.wal != nil {
@@ -1012,29 +1483,35 @@ func (lc *leaderController) close() error {
 		lc.db = nil
 	}
 
-	if lc.quorumAckTracker != nil {
-		err = multierr.Append(err, lc.quorumAckTracker.Close())
-		lc.quorumAckTracker = nil
-	}
-
 	return err
 }
 
-func getLastEntryIdInWal(walObject wal.Wal) (*proto.EntryId, error) {
-	reader, err := walObject.NewReverseReader()
+// getHeadEntryId returns the id of the last entry that the node holds. After
+// a snapshot is installed the wal is empty, or behind the entries that were
+// already applied to the database, and the head is the last applied entry.
+func getHeadEntryId(walObject wal.Wal, db kv.DB) (*proto.EntryId, error) {
+	lastEntryId, err := getLastEntryIdInWal(walObject)
 	if err != nil {
 		return nil, err
 	}
 
-	if !reader.HasNext() {
-		return InvalidEntryId, nil
-	}
-
-	entry, err := reader.ReadNext()
+	commitEntryId, err := db.ReadCommitEntryId()
 	if err != nil {
 		return nil, err
 	}
-	return &proto.EntryId{Term: entry.Term, Offset: entry.Offset}, nil
+
+	if commitEntryId.Offset > lastEntryId.Offset {
+		return commitEntryId, nil
+	}
+	return lastEntryId, nil
+}
+
+func getLastEntryIdInWal(walObject wal.Wal) (*proto.EntryId, error) {
+	entryId, err := walObject.LastEntry()
+	if errors.Is(err, wal.ErrEmptyWal) {
+		return InvalidEntryId, nil
+	}
+	return entryId, err
 }
 
 func (lc *leaderController) CommitOffset() int64 {
@@ -1063,10 +1540,91 @@ func (lc *leaderController) GetStatus(_ *proto.GetStatusRequest) (*proto.GetStat
 		Status:       lc.status,
 		HeadOffset:   headOffset,
 		CommitOffset: commitOffset,
+		DiskUsage:    lc.diskUsage.Get(),
 	}, nil
 }
 
+func (lc *leaderController) refreshDiskUsage() error {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	if lc.wal == nil || lc.db == nil {
+		// The controller is closed
+		return nil
+	}
+	return lc.diskUsage.update(lc.wal, lc.db)
+}
+
+func (lc *leaderController) compactDb() (int64, error) {
+	lc.RLock()
+	if lc.isClosed() || lc.db == nil {
+		lc.RUnlock()
+		return 0, common.ErrorAlreadyClosed
+	}
+	if lc.status != proto.ServingStatus_LEADER {
+		// The leader is still being elected, and is replaying its wal
+		lc.RUnlock()
+		return 0, common.ErrorShardCatchingUp
+	}
+	db := lc.db
+	lc.RUnlock()
+
+	// The compaction doesn't hold the lock, since it can take a while. The
+	// database is not closed until it's done
+	reclaimed, err := db.Compact()
+	if errors.Is(err, kv.ErrDbClosed) {
+		return 0, common.ErrorAlreadyClosed
+	}
+	return reclaimed, err
+}
+
+func (lc *leaderController) debugStatus() shardDebugStatus {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	st := newShardDebugStatus(lc.namespace, lc.shardId, "leader")
+	st.Status = lc.status.String()
+	st.Term = lc.term
+	if lc.quorumAckTracker != nil {
+		st.HeadOffset = lc.quorumAckTracker.HeadOffset()
+		st.CommitOffset = lc.quorumAckTracker.CommitOffset()
+	}
+	if lc.db != nil {
+		if applied, err := lc.db.ReadCommitOffset(); err == nil {
+			st.AppliedOffset = applied
+		}
+	}
+	if lc.wal != nil {
+		st.WalFirstOffset = lc.wal.FirstOffset()
+		st.WalLastOffset = lc.wal.LastOffset()
+	}
+	return st
+}
+
+func (lc *leaderController) GetSnapshot(req *proto.GetSnapshotRequest, hashRange *proto.Int32HashRange,
+	stream proto.OxiaCoordination_GetSnapshotServer) error {
+	lc.RLock()
+	if lc.isClosed() {
+		lc.RUnlock()
+		return common.ErrorAlreadyClosed
+	}
+	snapshot, err := newBackupSnapshot(lc.db, lc.term)
+	lc.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	lc.log.Info(
+		"Sending a snapshot for a backup",
+		slog.Any("entry-id", snapshot.entryId),
+		slog.String("peer", common.GetPeer(stream.Context())),
+	)
+	return snapshot.send(hashRange, req.SkipFiles, stream.Send)
+}
+
 func (lc *leaderController) DeleteShard(request *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error) {
+	lc.stopSweeper()
+
 	lc.Lock()
 	defer lc.Unlock()
 
@@ -1109,6 +1667,18 @@ func (lc *leaderController) CloseSession(request *proto.CloseSessionRequest) (*p
 	return lc.sessionManager.CloseSession(request)
 }
 
+func (lc *leaderController) checkAcceptsWrites() error {
+	if err := checkStatusIsLeader(lc.status); err != nil {
+		return err
+	}
+
+	if lc.transferringLeadership {
+		// The client will retry once the new leader is elected
+		return common.ErrorNodeIsNotLeader
+	}
+	return lc.checkNotSplit()
+}
+
 func checkStatusIsLeader(actual proto.ServingStatus) error {
 	if actual != proto.ServingStatus_LEADER {
 		return status.Errorf(common.CodeInvalidStatus, "Received message in the wrong state. In %+v, should be %+v.", actual, proto.ServingStatus_LEADER)
@@ -16,9 +16,11 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -272,7 +274,7 @@ func TestLeaderController_BecomeLeader_RF2(t *testing.T) {
 	assert.Equal(t, proto.ServingStatus_LEADER, lc.Status())
 
 	go func() {
-		req := <-rpc.appendReqs
+		req := rpc.nextEntryAppend()
 
 		rpc.ackResps <- &proto.Ack{
 			Offset: req.Entry.Offset,
@@ -585,7 +587,7 @@ func TestLeaderController_AddFollower_Truncate(t *testing.T) {
 			Value:  value,
 		}))
 
-		_, err = db.ProcessWrite(wr, i-1, 0, kv.NoOpCallback)
+		_, err = db.ProcessWrite(wr, 5, i-1, 0, kv.NoOpCallback)
 		assert.NoError(t, err)
 	}
 
@@ -618,7 +620,7 @@ func TestLeaderController_AddFollower_Truncate(t *testing.T) {
 	// leader and f1
 	go func() {
 		for i := 0; i < 10; i++ {
-			req := <-rpcClient.appendReqs
+			req := rpcClient.nextEntryAppend()
 
 			rpcClient.ackResps <- &proto.Ack{
 				Offset: req.Entry.Offset,
@@ -668,6 +670,359 @@ func TestLeaderController_AddFollower_Truncate(t *testing.T) {
 	assert.NoError(t, walFactory.Close())
 }
 
+func TestLeaderController_BecomeLeader_CatchUpFollowers(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
+
+	// Prepare some data in the leader log & db
+	walObject, err := walFactory.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+	db, err := kv.NewDB(common.DefaultNamespace, shard, kvFactory, 1*time.Hour, common.SystemClock)
+	assert.NoError(t, err)
+
+	for i := int64(0); i < 10; i++ {
+		wr := &proto.WriteRequest{Puts: []*proto.PutRequest{{
+			Key:   "my-key",
+			Value: []byte(""),
+		}}}
+		value, err := pb.Marshal(wrapInLogEntryValue(wr))
+		assert.NoError(t, err)
+
+		assert.NoError(t, walObject.Append(&proto.LogEntry{
+			Term:   5,
+			Offset: i,
+			Value:  value,
+		}))
+
+		_, err = db.ProcessWrite(wr, 5, i-1, 0, kv.NoOpCallback)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, db.UpdateTerm(5))
+	assert.NoError(t, db.Close())
+	assert.NoError(t, walObject.Close())
+
+	rpcClient := newMockRpcClient()
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shard, rpcClient, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = lc.NewTerm(&proto.NewTermRequest{
+		Term:  6,
+		Shard: shard,
+	})
+	assert.NoError(t, err)
+
+	// f2 has entries from term 5 that were never committed, and it's not reachable
+	// when the leader tries to truncate it
+	rpcClient.truncateResps <- struct {
+		*proto.TruncateResponse
+		error
+	}{nil, errors.New("failed to connect")}
+
+	// f1 is behind and the quorum can be reached with it alone
+	go func() {
+		for i := 6; i < 10; i++ {
+			req := rpcClient.nextEntryAppend()
+			assert.EqualValues(t, i, req.Entry.Offset)
+
+			rpcClient.ackResps <- &proto.Ack{
+				Offset: req.Entry.Offset,
+			}
+		}
+	}()
+
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              6,
+		ReplicationFactor: 3,
+		FollowerMaps: map[string]*proto.EntryId{
+			"f1": {Term: 5, Offset: 5},
+			"f2": {Term: 5, Offset: 12},
+		},
+	})
+	assert.NoError(t, err)
+
+	trReq := <-rpcClient.truncateReqs
+	AssertProtoEqual(t, &proto.EntryId{Term: 5, Offset: 9}, trReq.HeadEntryId)
+
+	// The leader keeps retrying to truncate f2, until it's reachable
+	trReq = <-rpcClient.truncateReqs
+	AssertProtoEqual(t, &proto.EntryId{Term: 5, Offset: 9}, trReq.HeadEntryId)
+
+	// The leader is not locked while the truncate request is pending
+	statusDone := make(chan error)
+	go func() {
+		_, err := lc.GetStatus(&proto.GetStatusRequest{Shard: shard})
+		statusDone <- err
+	}()
+	select {
+	case err = <-statusDone:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "leader is locked while truncating the follower")
+	}
+
+	rpcClient.truncateResps <- struct {
+		*proto.TruncateResponse
+		error
+	}{&proto.TruncateResponse{HeadEntryId: &proto.EntryId{Term: 5, Offset: 9}}, nil}
+
+	// Both followers are eventually caught up with the leader
+	for _, follower := range []string{"f1", "f2"} {
+		assert.Eventually(t, func() bool {
+			lc.(*leaderController).RLock()
+			defer lc.(*leaderController).RUnlock()
+			cursor, ok := lc.(*leaderController).followers[follower]
+			return ok && cursor.AckOffset() == 9
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestLeaderController_BecomeLeader_NotEnoughFollowers(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	rpcClient := newMockRpcClient()
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shard, rpcClient, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	assert.NoError(t, err)
+
+	// f1 has entries the leader does not have and cannot be truncated
+	rpcClient.truncateResps <- struct {
+		*proto.TruncateResponse
+		error
+	}{nil, errors.New("failed to connect")}
+
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 3,
+		FollowerMaps: map[string]*proto.EntryId{
+			"f1": {Term: wal.InvalidTerm, Offset: 5},
+		},
+	})
+	assert.ErrorIs(t, err, common.ErrorNotEnoughFollowers)
+	assert.Len(t, rpcClient.truncateReqs, 1)
+	assert.Equal(t, common.CodeNotEnoughFollowers, status.Code(errors.Cause(err)))
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestLeaderController_AddAndRemoveMember(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	rpc := newMockRpcClient()
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shard, rpc, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = lc.NewTerm(&proto.NewTermRequest{
+		Shard: shard,
+		Term:  1,
+	})
+	assert.NoError(t, err)
+
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 1,
+		FollowerMaps:      map[string]*proto.EntryId{},
+	})
+	assert.NoError(t, err)
+
+	write := func() {
+		res, err := lc.Write(context.Background(), &proto.WriteRequest{
+			Shard: &shard,
+			Puts: []*proto.PutRequest{{
+				Key:   "a",
+				Value: []byte("value-a")}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, proto.Status_OK, res.Puts[0].Status)
+	}
+
+	// Members can only be added one at a time
+	_, err = lc.AddFollower(&proto.AddFollowerRequest{
+		Shard:               shard,
+		Term:                1,
+		FollowerName:        "f1",
+		FollowerHeadEntryId: InvalidEntryId,
+		ReplicationFactor:   3,
+	})
+	assert.Error(t, err)
+
+	_, err = lc.AddFollower(&proto.AddFollowerRequest{
+		Shard:               shard,
+		Term:                1,
+		FollowerName:        "f1",
+		FollowerHeadEntryId: InvalidEntryId,
+		ReplicationFactor:   2,
+	})
+	assert.NoError(t, err)
+
+	// The new member has nothing to catch up, so it's immediately part of the quorum
+	assert.EqualValues(t, 1, lc.(*leaderController).quorumAckTracker.(*quorumAckTracker).requiredAcks)
+
+	for i := 0; i < 2; i++ {
+		go func() {
+			req := rpc.nextEntryAppend()
+			rpc.ackResps <- &proto.Ack{Offset: req.Entry.Offset}
+		}()
+		write()
+	}
+
+	_, err = lc.RemoveFollower(&proto.RemoveFollowerRequest{
+		Shard:        shard,
+		Term:         2,
+		FollowerName: "f1",
+	})
+	assert.ErrorIs(t, err, common.ErrorInvalidTerm)
+
+	_, err = lc.RemoveFollower(&proto.RemoveFollowerRequest{
+		Shard:        shard,
+		Term:         1,
+		FollowerName: "f2",
+	})
+	assert.Error(t, err)
+
+	_, err = lc.RemoveFollower(&proto.RemoveFollowerRequest{
+		Shard:        shard,
+		Term:         1,
+		FollowerName: "f1",
+	})
+	assert.NoError(t, err)
+
+	// Writes don't need the acks of the removed member anymore
+	write()
+
+	status, err := lc.GetStatus(&proto.GetStatusRequest{Shard: shard})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, status.HeadOffset)
+	assert.EqualValues(t, 2, status.CommitOffset)
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestLeaderController_TransferLeadership(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	rpc := newMockRpcClient()
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shard, rpc, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = lc.NewTerm(&proto.NewTermRequest{
+		Shard: shard,
+		Term:  1,
+	})
+	assert.NoError(t, err)
+
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 3,
+		FollowerMaps: map[string]*proto.EntryId{
+			"f1": InvalidEntryId,
+		},
+	})
+	assert.NoError(t, err)
+
+	go func() {
+		for {
+			req := rpc.nextEntryAppend()
+			rpc.ackResps <- &proto.Ack{Offset: req.Entry.Offset}
+		}
+	}()
+
+	write := func() error {
+		_, err := lc.Write(context.Background(), &proto.WriteRequest{
+			Shard: &shard,
+			Puts: []*proto.PutRequest{{
+				Key:   "a",
+				Value: []byte("value-a")}},
+		})
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, write())
+	}
+
+	// f2 joins after the writes, and it needs a snapshot before catching up
+	_, err = lc.AddFollower(&proto.AddFollowerRequest{
+		Shard:               shard,
+		Term:                1,
+		FollowerName:        "f2",
+		FollowerHeadEntryId: InvalidEntryId,
+	})
+	assert.NoError(t, err)
+
+	_, err = lc.TransferLeadership(context.Background(), &proto.TransferLeadershipRequest{
+		Shard:     shard,
+		Term:      1,
+		NewLeader: "f3",
+	})
+	assert.Error(t, err)
+
+	// A lagging follower cannot take over
+	_, err = lc.TransferLeadership(context.Background(), &proto.TransferLeadershipRequest{
+		Shard:     shard,
+		Term:      1,
+		NewLeader: "f2",
+	})
+	assert.ErrorContains(t, err, "lagging")
+	assert.NoError(t, write())
+
+	res, err := lc.TransferLeadership(context.Background(), &proto.TransferLeadershipRequest{
+		Shard:     shard,
+		Term:      1,
+		NewLeader: "f1",
+	})
+	assert.NoError(t, err)
+	AssertProtoEqual(t, &proto.EntryId{Term: 1, Offset: 3}, res.HeadEntryId)
+
+	// The new leader has all the committed entries
+	assert.EqualValues(t, 3, lc.(*leaderController).followers["f1"].AckOffset())
+	assert.EqualValues(t, 3, lc.(*leaderController).quorumAckTracker.CommitOffset())
+
+	// Writes are rejected with a retriable error during the handover
+	err = write()
+	assert.Equal(t, common.CodeNodeIsNotLeader, status.Code(err))
+
+	// If the transfer is not completed, the leader accepts writes again
+	lc.(*leaderController).stopTransferringLeadership(1)
+	assert.NoError(t, write())
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
 func TestLeaderController_AddFollowerCheckTerm(t *testing.T) {
 	var shard int64 = 1
 
@@ -748,6 +1103,8 @@ func TestLeaderController_EntryVisibilityAfterBecomingLeader(t *testing.T) {
 		Offset: 0,
 		Value:  v,
 	}))
+	// The wal is opened again by the leader controller
+	assert.NoError(t, walObject.Close())
 
 	rpc := newMockRpcClient()
 
@@ -760,7 +1117,7 @@ func TestLeaderController_EntryVisibilityAfterBecomingLeader(t *testing.T) {
 
 	// Respond to replication flow to follower
 	go func() {
-		req := <-rpc.appendReqs
+		req := rpc.nextEntryAppend()
 
 		rpc.ackResps <- &proto.Ack{
 			Offset: req.Entry.Offset,
@@ -859,6 +1216,84 @@ func TestLeaderController_Notifications(t *testing.T) {
 	assert.NoError(t, walFactory.Close())
 }
 
+func TestLeaderController_NotificationsResumeWithKeyPrefix(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	lc, _ := NewLeaderController(Config{}, common.DefaultNamespace, shard, newMockRpcClient(), walFactory, kvFactory)
+	_, _ = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	_, _ = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 1,
+		FollowerMaps:      nil,
+	})
+
+	for _, key := range []string{"/a/0", "/b/0", "/a/1", "/b/1", "/a/2"} {
+		_, err := lc.Write(context.Background(), &proto.WriteRequest{
+			Shard: &shard,
+			Puts:  []*proto.PutRequest{{Key: key, Value: []byte(key)}},
+		})
+		assert.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newMockGetNotificationsServer(ctx)
+
+	closeCh := make(chan any)
+
+	// Resume after the first entry, only for the keys under "/a/"
+	startOffsetExclusive := int64(0)
+	go func() {
+		err := lc.GetNotifications(&proto.NotificationsRequest{
+			Shard:                shard,
+			StartOffsetExclusive: &startOffsetExclusive,
+			KeyPrefix:            "/a/",
+		}, stream)
+		assert.ErrorIs(t, err, context.Canceled)
+		close(closeCh)
+	}()
+
+	nb := <-stream.ch
+	assert.EqualValues(t, 2, nb.Offset)
+	assert.Equal(t, 1, len(nb.Notifications))
+	assert.Contains(t, nb.Notifications, "/a/1")
+
+	nb = <-stream.ch
+	assert.EqualValues(t, 4, nb.Offset)
+	assert.Equal(t, 1, len(nb.Notifications))
+	assert.Contains(t, nb.Notifications, "/a/2")
+
+	// New entries are filtered as well
+	_, _ = lc.Write(context.Background(), &proto.WriteRequest{
+		Shard: &shard,
+		Puts: []*proto.PutRequest{
+			{Key: "/b/2", Value: []byte("b")},
+			{Key: "/a/3", Value: []byte("a")},
+		},
+	})
+
+	nb = <-stream.ch
+	assert.EqualValues(t, 5, nb.Offset)
+	assert.Equal(t, 1, len(nb.Notifications))
+	assert.Equal(t, proto.NotificationType_KEY_CREATED, nb.Notifications["/a/3"].Type)
+
+	select {
+	case nb = <-stream.ch:
+		assert.Fail(t, "Unexpected notification", nb)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	<-closeCh
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
 func TestLeaderController_NotificationsCloseLeader(t *testing.T) {
 	var shard int64 = 1
 
@@ -1149,6 +1584,7 @@ func TestLeaderController_GetStatus(t *testing.T) {
 		Status:       proto.ServingStatus_LEADER,
 		HeadOffset:   1,
 		CommitOffset: 1,
+		DiskUsage:    res.DiskUsage,
 	}, res)
 
 	assert.NoError(t, lc.Close())
@@ -1156,6 +1592,67 @@ func TestLeaderController_GetStatus(t *testing.T) {
 	assert.NoError(t, walFactory.Close())
 }
 
+func TestLeaderController_BecomeLeaderWithRestoredDb(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
+
+	// The database was restored up to {term: 1, offset: 9}, while the wal is empty
+	db, err := kv.NewDB(common.DefaultNamespace, shard, kvFactory, 1*time.Hour, common.SystemClock)
+	assert.NoError(t, err)
+	for i := int64(0); i < 10; i++ {
+		_, err = db.ProcessWrite(&proto.WriteRequest{Puts: []*proto.PutRequest{{
+			Key:   fmt.Sprintf("key-%d", i),
+			Value: []byte("value"),
+		}}}, 1, i, 0, kv.NoOpCallback)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, db.UpdateTerm(1))
+	assert.NoError(t, db.Close())
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shard, newMockRpcClient(), walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	fr, err := lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 2})
+	assert.NoError(t, err)
+	AssertProtoEqual(t, &proto.EntryId{Term: 1, Offset: 9}, fr.HeadEntryId)
+
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              2,
+		ReplicationFactor: 1,
+		FollowerMaps:      nil,
+	})
+	assert.NoError(t, err)
+
+	// New entries are appended after the restored ones
+	_, err = lc.Write(context.Background(), &proto.WriteRequest{
+		Shard: &shard,
+		Puts: []*proto.PutRequest{{
+			Key:   "a",
+			Value: []byte("value-a")}},
+	})
+	assert.NoError(t, err)
+
+	res, err := lc.GetStatus(&proto.GetStatusRequest{Shard: shard})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, res.HeadOffset)
+	assert.EqualValues(t, 10, res.CommitOffset)
+
+	r := <-lc.Read(context.Background(), &proto.ReadRequest{
+		Shard: &shard,
+		Gets:  []*proto.GetRequest{{Key: "key-0"}},
+	})
+	assert.NoError(t, r.Err)
+	assert.Equal(t, proto.Status_OK, r.Response.Status)
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
 func TestLeaderController_WriteStream(t *testing.T) {
 	var shard int64 = 1
 
@@ -1262,3 +1759,243 @@ func TestLeaderController_WriteStream(t *testing.T) {
 	assert.NoError(t, kvFactory.Close())
 	assert.NoError(t, walFactory.Close())
 }
+
+func TestLeaderController_DeleteExpiredKeys(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shard, newMockRpcClient(), walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	assert.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 1,
+		FollowerMaps:      nil,
+	})
+	assert.NoError(t, err)
+
+	_, err = lc.Write(context.Background(), &proto.WriteRequest{
+		Shard: &shard,
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: []byte("a"), ExpireAfterMs: pb.Uint64(100)},
+			{Key: "b", Value: []byte("b")},
+		},
+	})
+	assert.NoError(t, err)
+
+	// The leader deletes the expired key through the log
+	assert.Eventually(t, func() bool {
+		return lc.(*leaderController).wal.LastOffset() == 1 && !hasPendingExpiration(t, lc.(*leaderController))
+	}, 10*time.Second, 10*time.Millisecond)
+
+	_, err = lc.Write(context.Background(), &proto.WriteRequest{
+		Shard: &shard,
+		Puts:  []*proto.PutRequest{{Key: "c", Value: []byte("c"), ExpireAfterMs: pb.Uint64(1500)}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, lc.Close())
+
+	// The key expires while there's no leader, the new leader must delete it
+	lc, err = NewLeaderController(Config{}, common.DefaultNamespace, shard, newMockRpcClient(), walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 2})
+	assert.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              2,
+		ReplicationFactor: 1,
+		FollowerMaps:      nil,
+	})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return lc.(*leaderController).wal.LastOffset() == 3 && !hasPendingExpiration(t, lc.(*leaderController))
+	}, 10*time.Second, 10*time.Millisecond)
+
+	r := <-lc.Read(context.Background(), &proto.ReadRequest{
+		Shard: &shard,
+		Gets:  []*proto.GetRequest{{Key: "b"}, {Key: "c"}},
+	})
+	assert.NoError(t, r.Err)
+	assert.Equal(t, proto.Status_OK, r.Response.Status)
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// The expired keys are only gone once the delete appended by the sweeper
+// is committed and applied to the database.
+func hasPendingExpiration(t *testing.T, lc *leaderController) bool {
+	t.Helper()
+
+	lc.RLock()
+	defer lc.RUnlock()
+	deletes, err := lc.db.ExpiredKeys(uint64(time.Now().Add(time.Hour).UnixMilli()), 10)
+	assert.NoError(t, err)
+	return len(deletes) > 0
+}
+
+func TestLeaderController_SplitShard(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	rpc := newMockRpcClient()
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shard, rpc, walFactory, kvFactory)
+	assert.NoError(t, err)
+
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	assert.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 1,
+		FollowerMaps:      nil,
+	})
+	assert.NoError(t, err)
+
+	write := func() error {
+		_, err := lc.Write(context.Background(), &proto.WriteRequest{
+			Shard: &shard,
+			Puts:  []*proto.PutRequest{{Key: "a", Value: []byte("value-a")}},
+		})
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, write())
+	}
+
+	// The child member receives a snapshot and then the split entry
+	rpc.sendSnapshotStream.response <- &proto.SnapshotResponse{AckOffset: 2}
+	go func() {
+		for {
+			req := rpc.nextEntryAppend()
+			rpc.ackResps <- &proto.Ack{Offset: req.Entry.Offset}
+		}
+	}()
+
+	req := &proto.SplitShardRequest{
+		Shard: shard,
+		Term:  1,
+		Children: []*proto.SplitShardChild{{
+			Shard:          2,
+			Int32HashRange: &proto.Int32HashRange{MinHashInclusive: 0, MaxHashInclusive: 100},
+			Ensemble:       []string{"c1"},
+		}},
+	}
+
+	_, err = lc.SplitShard(context.Background(), &proto.SplitShardRequest{Shard: shard, Term: 2})
+	assert.ErrorIs(t, err, common.ErrorInvalidTerm)
+
+	res, err := lc.SplitShard(context.Background(), req)
+	assert.NoError(t, err)
+	AssertProtoEqual(t, &proto.EntryId{Term: 1, Offset: 3}, res.SplitEntryId)
+
+	// The split entry doesn't count towards the quorum of the shard
+	assert.Empty(t, lc.(*leaderController).followers)
+	assert.EqualValues(t, 3, lc.(*leaderController).quorumAckTracker.CommitOffset())
+
+	assertShardSplitError := func(err error) {
+		t.Helper()
+
+		assert.Equal(t, common.CodeShardSplit, status.Code(err))
+		details := status.Convert(err).Details()
+		assert.Equal(t, 1, len(details))
+		assignments, ok := details[0].(*proto.NamespaceShardsAssignment)
+		assert.True(t, ok)
+		assert.EqualValues(t, 2, assignments.Assignments[0].Shard)
+	}
+
+	// All the requests are rejected after the split
+	assertShardSplitError(write())
+	r := <-lc.Read(context.Background(), &proto.ReadRequest{
+		Shard: &shard,
+		Gets:  []*proto.GetRequest{{Key: "a"}},
+	})
+	assertShardSplitError(r.Err)
+	_, err = lc.List(context.Background(), &proto.ListRequest{Shard: &shard})
+	assertShardSplitError(err)
+
+	// Retrying the split is fine, the child receives a new snapshot
+	rpc.sendSnapshotStream = newMockSendSnapshotClientStream(context.Background())
+	rpc.sendSnapshotStream.response <- &proto.SnapshotResponse{AckOffset: 3}
+	res, err = lc.SplitShard(context.Background(), req)
+	assert.NoError(t, err)
+	AssertProtoEqual(t, &proto.EntryId{Term: 1, Offset: 3}, res.SplitEntryId)
+
+	// The parent keeps all its records, and the split
+	split, err := lc.(*leaderController).db.ShardSplit()
+	assert.NoError(t, err)
+	assert.NotNil(t, split)
+	assert.NoError(t, lc.Close())
+
+	// A new leader keeps rejecting the requests
+	lc, err = NewLeaderController(Config{}, common.DefaultNamespace, shard, newMockRpcClient(), walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 2})
+	assert.NoError(t, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              2,
+		ReplicationFactor: 1,
+		FollowerMaps:      nil,
+	})
+	assert.NoError(t, err)
+
+	assertShardSplitError(write())
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// Writes through the leader of a shard without replicas, from the request
+// to the apply of the entry.
+func BenchmarkLeaderWrite(b *testing.B) {
+	setBenchmarkLogLevel(b)
+
+	var shard int64 = 1
+	kvFactory, err := kv.NewPebbleKVFactory(testKVOptions)
+	assert.NoError(b, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: b.TempDir()})
+
+	lc, err := NewLeaderController(Config{}, common.DefaultNamespace, shard, newMockRpcClient(), walFactory, kvFactory)
+	assert.NoError(b, err)
+	_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	assert.NoError(b, err)
+	_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+		Shard:             shard,
+		Term:              1,
+		ReplicationFactor: 1,
+	})
+	assert.NoError(b, err)
+
+	value := make([]byte, 1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := lc.Write(context.Background(), &proto.WriteRequest{
+			Shard: &shard,
+			Puts:  []*proto.PutRequest{{Key: "my-key", Value: value}},
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	assert.NoError(b, lc.Close())
+	assert.NoError(b, kvFactory.Close())
+	assert.NoError(b, walFactory.Close())
+}
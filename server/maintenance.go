@@ -0,0 +1,76 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"log/slog"
+	"sync"
+
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/streamnative/oxia/common/container"
+)
+
+// maintenanceMode tracks whether the node is in maintenance. While in
+// maintenance, the node reports it in the NewTerm and GetStatus responses,
+// so that the coordinator does not elect it as leader.
+//
+// The node can also ask the coordinator to move its current leaderships
+// away, by reporting the leadership health service as not serving.
+type maintenanceMode struct {
+	sync.Mutex
+
+	enabled      bool
+	healthServer *health.Server
+	log          *slog.Logger
+}
+
+func newMaintenanceMode(enabled bool, healthServer *health.Server) *maintenanceMode {
+	m := &maintenanceMode{
+		healthServer: healthServer,
+		log: slog.With(
+			slog.String("component", "maintenance-mode"),
+		),
+	}
+	m.Set(enabled, false)
+	return m
+}
+
+func (m *maintenanceMode) Enabled() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.enabled
+}
+
+func (m *maintenanceMode) Set(enabled bool, transferLeadership bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if enabled != m.enabled {
+		m.log.Info(
+			"Changed maintenance mode",
+			slog.Bool("enabled", enabled),
+			slog.Bool("transfer-leadership", transferLeadership),
+		)
+	}
+	m.enabled = enabled
+
+	leadershipStatus := grpc_health_v1.HealthCheckResponse_SERVING
+	if enabled && transferLeadership {
+		leadershipStatus = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	m.healthServer.SetServingStatus(container.LeadershipHealthService, leadershipStatus)
+}
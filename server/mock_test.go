@@ -16,6 +16,7 @@ package server
 
 import (
 	"context"
+	"sync/atomic"
 
 	"google.golang.org/grpc/metadata"
 
@@ -89,6 +90,16 @@ func (m *mockRpcClient) Send(request *proto.Append) error {
 	return nil
 }
 
+// Returns the next append that carries an entry, skipping the ones that
+// only advance the commit offset.
+func (m *mockRpcClient) nextEntryAppend() *proto.Append {
+	for {
+		if req := <-m.appendReqs; req.Entry != nil {
+			return req
+		}
+	}
+}
+
 func (m *mockRpcClient) Recv() (*proto.Ack, error) {
 	res := <-m.ackResps
 	return res, nil
@@ -182,12 +193,19 @@ type mockServerSendSnapshotStream struct {
 	mockBase
 	chunks    chan *proto.SnapshotChunk
 	responses chan *proto.SnapshotResponse
+	err       atomic.Pointer[error]
 }
 
 func (m *mockServerSendSnapshotStream) AddChunk(chunk *proto.SnapshotChunk) {
 	m.chunks <- chunk
 }
 
+// Fail makes Recv return err once all the chunks added so far are consumed.
+func (m *mockServerSendSnapshotStream) Fail(err error) {
+	m.err.Store(&err)
+	m.chunks <- nil
+}
+
 func (m *mockServerSendSnapshotStream) GetResponse() *proto.SnapshotResponse {
 	return <-m.responses
 }
@@ -198,7 +216,11 @@ func (m *mockServerSendSnapshotStream) SendAndClose(empty *proto.SnapshotRespons
 }
 
 func (m *mockServerSendSnapshotStream) Recv() (*proto.SnapshotChunk, error) {
-	return <-m.chunks, nil
+	chunk := <-m.chunks
+	if err := m.err.Load(); chunk == nil && err != nil {
+		return nil, *err
+	}
+	return chunk, nil
 }
 
 type mockGetNotificationsServer struct {
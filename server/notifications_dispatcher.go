@@ -18,20 +18,45 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/pkg/errors"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
 )
 
+// notificationDispatchers keeps track of the notification streams served by
+// a shard controller, so that they can be stopped before its database gets
+// closed or replaced.
+type notificationDispatchers struct {
+	sync.Mutex
+	shardId     int64
+	dispatchers map[int64]*notificationDispatcher
+	closed      bool
+}
+
+func newNotificationDispatchers(shardId int64) *notificationDispatchers {
+	return &notificationDispatchers{
+		shardId:     shardId,
+		dispatchers: make(map[int64]*notificationDispatcher),
+	}
+}
+
 type notificationDispatcher struct {
-	lc     *leaderController
+	nds    *notificationDispatchers
+	db     kv.DB
 	id     int64
 	req    *proto.NotificationsRequest
 	stream proto.OxiaClient_GetNotificationsServer
 
+	// Provides the offset a stream is positioned on, when the client
+	// doesn't specify a start offset
+	commitOffset func() (int64, error)
+
 	ctx    context.Context
 	cancel context.CancelFunc
 
@@ -41,19 +66,27 @@ type notificationDispatcher struct {
 
 var notificationDispatcherIdGen atomic.Int64
 
-func startNotificationDispatcher(lc *leaderController, req *proto.NotificationsRequest, stream proto.OxiaClient_GetNotificationsServer) error {
+func (nds *notificationDispatchers) startDispatcher(db kv.DB, commitOffset func() (int64, error), log *slog.Logger,
+	req *proto.NotificationsRequest, stream proto.OxiaClient_GetNotificationsServer) (*notificationDispatcher, error) {
+	nds.Lock()
+	defer nds.Unlock()
+
+	if nds.closed {
+		return nil, common.ErrorAlreadyClosed
+	}
+
 	nd := &notificationDispatcher{
-		lc:      lc,
-		id:      notificationDispatcherIdGen.Add(1),
-		req:     req,
-		stream:  stream,
-		log:     lc.log.With(slog.String("component", "notification-dispatcher")),
-		closeCh: make(chan any),
+		nds:          nds,
+		db:           db,
+		id:           notificationDispatcherIdGen.Add(1),
+		req:          req,
+		stream:       stream,
+		commitOffset: commitOffset,
+		log:          log.With(slog.String("component", "notification-dispatcher")),
+		closeCh:      make(chan any),
 	}
 
-	lc.Lock()
-	lc.notificationDispatchers[nd.id] = nd
-	lc.Unlock()
+	nds.dispatchers[nd.id] = nd
 
 	// Create a context for handling this stream
 	nd.ctx, nd.cancel = context.WithCancel(stream.Context())
@@ -62,7 +95,7 @@ func startNotificationDispatcher(lc *leaderController, req *proto.NotificationsR
 		nd.ctx,
 		map[string]string{
 			"oxia":  "dispatch-notifications",
-			"shard": fmt.Sprintf("%d", lc.shardId),
+			"shard": fmt.Sprintf("%d", nds.shardId),
 			"peer":  common.GetPeer(stream.Context()),
 		},
 		func() {
@@ -77,26 +110,55 @@ func startNotificationDispatcher(lc *leaderController, req *proto.NotificationsR
 
 			close(nd.closeCh)
 
-			// Clean up dispatcher for leader controller map
-			nd.lc.Lock()
-			delete(nd.lc.notificationDispatchers, nd.id)
-			nd.lc.Unlock()
+			// Clean up dispatcher from the shard controller map
+			nds.Lock()
+			delete(nds.dispatchers, nd.id)
+			nds.Unlock()
 		},
 	)
 
+	return nd, nil
+}
+
+// Stop all the active streams and wait until they're done. The clients will
+// reconnect and resume from the last notification they have received.
+func (nds *notificationDispatchers) drain() {
+	nds.Lock()
+	dispatchers := make([]*notificationDispatcher, 0, len(nds.dispatchers))
+	for _, nd := range nds.dispatchers {
+		dispatchers = append(dispatchers, nd)
+	}
+	nds.Unlock()
+
+	for _, nd := range dispatchers {
+		nd.cancel()
+		nd.close()
+	}
+}
+
+func (nds *notificationDispatchers) close() {
+	nds.Lock()
+	nds.closed = true
+	nds.Unlock()
+
+	nds.drain()
+}
+
+// Wait until the stream is done, or the shard controller is getting closed.
+func (nd *notificationDispatcher) wait(ctx context.Context) error {
 	select {
-	case <-lc.ctx.Done():
-		// Leader is getting closed
+	case <-ctx.Done():
+		// Shard controller is getting closed
 		nd.cancel()
-		return lc.ctx.Err()
+		return ctx.Err()
 
 	case <-nd.ctx.Done():
 		return nd.ctx.Err()
 
-	case <-stream.Context().Done():
+	case <-nd.stream.Context().Done():
 		// The stream is getting closed
 		nd.cancel()
-		return stream.Context().Err()
+		return nd.stream.Context().Err()
 	}
 }
 
@@ -104,20 +166,17 @@ func (nd *notificationDispatcher) dispatchNotifications() error {
 	nd.log.Debug(
 		"Dispatch notifications",
 		slog.Any("start-offset-exclusive", nd.req.StartOffsetExclusive),
+		slog.String("key-prefix", nd.req.KeyPrefix),
 	)
 
 	var offsetInclusive int64
 	if nd.req.StartOffsetExclusive != nil {
 		offsetInclusive = *nd.req.StartOffsetExclusive + 1
 	} else {
-		nd.lc.Lock()
-		qat := nd.lc.quorumAckTracker
-		nd.lc.Unlock()
-
-		if qat == nil {
-			return errors.New("leader is not yet ready")
+		commitOffset, err := nd.commitOffset()
+		if err != nil {
+			return err
 		}
-		commitOffset := qat.CommitOffset()
 
 		// The client is creating a new notification stream and wants to receive the notification from the next
 		// entry that will be written.
@@ -129,7 +188,7 @@ func (nd *notificationDispatcher) dispatchNotifications() error {
 			slog.Int64("commit-offset", commitOffset),
 		)
 		if err := nd.stream.Send(&proto.NotificationBatch{
-			Shard:         nd.lc.shardId,
+			Shard:         nd.nds.shardId,
 			Offset:        commitOffset,
 			Timestamp:     0,
 			Notifications: nil,
@@ -144,10 +203,9 @@ func (nd *notificationDispatcher) dispatchNotifications() error {
 }
 
 func (nd *notificationDispatcher) iterateOverNotifications(startOffsetInclusive int64) error {
-	lc := nd.lc
 	offsetInclusive := startOffsetInclusive
 	for nd.ctx.Err() == nil {
-		notifications, err := lc.db.ReadNextNotifications(nd.ctx, offsetInclusive)
+		notifications, err := nd.db.ReadNextNotifications(nd.ctx, offsetInclusive)
 		if err != nil {
 			return err
 		}
@@ -158,12 +216,20 @@ func (nd *notificationDispatcher) iterateOverNotifications(startOffsetInclusive
 		)
 
 		for _, n := range notifications {
+			if n = filterNotifications(n, nd.req.KeyPrefix); n == nil {
+				continue
+			}
+
 			if err := nd.stream.Send(n); err != nil {
 				return err
 			}
 		}
 
-		offsetInclusive += int64(len(notifications))
+		// The offsets of the batches can have gaps, like when a stream
+		// starts before the oldest batch that was not trimmed
+		if len(notifications) > 0 {
+			offsetInclusive = notifications[len(notifications)-1].Offset + 1
+		}
 	}
 
 	return nd.ctx.Err()
@@ -173,3 +239,32 @@ func (nd *notificationDispatcher) close() {
 	// Wait for dispatcher stream to be fully closed
 	<-nd.closeCh
 }
+
+// Only keep the notifications for the keys with the given prefix. Returns nil
+// if none of the notifications in the batch matches.
+func filterNotifications(nb *proto.NotificationBatch, keyPrefix string) *proto.NotificationBatch {
+	if keyPrefix == "" {
+		return nb
+	}
+
+	var filtered map[string]*proto.Notification
+	for key, n := range nb.Notifications {
+		if strings.HasPrefix(key, keyPrefix) {
+			if filtered == nil {
+				filtered = make(map[string]*proto.Notification)
+			}
+			filtered[key] = n
+		}
+	}
+
+	if filtered == nil {
+		return nil
+	}
+
+	return &proto.NotificationBatch{
+		Shard:         nb.Shard,
+		Offset:        nb.Offset,
+		Timestamp:     nb.Timestamp,
+		Notifications: filtered,
+	}
+}
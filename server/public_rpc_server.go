@@ -26,6 +26,8 @@ import (
 
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protowire"
 
@@ -45,23 +47,36 @@ type publicRpcServer struct {
 
 	shardsDirector       ShardsDirector
 	assignmentDispatcher ShardAssignmentsDispatcher
+	healthServer         *health.Server
+	writeRateLimiter     WriteRateLimiter
+	writeSizeLimits      WriteSizeLimits
+	diskWatermarks       *diskWatermarkMonitor
 	grpcServer           container.GrpcServer
 	log                  *slog.Logger
 }
 
 func newPublicRpcServer(provider container.GrpcProvider, bindAddress string, shardsDirector ShardsDirector, assignmentDispatcher ShardAssignmentsDispatcher,
-	tlsConf *tls.Config, options *auth.Options) (*publicRpcServer, error) {
+	healthServer *health.Server, writeRateLimiter WriteRateLimiter, writeSizeLimits WriteSizeLimits,
+	diskWatermarks *diskWatermarkMonitor, tlsConf *tls.Config, options *auth.Options) (*publicRpcServer, error) {
 	server := &publicRpcServer{
 		shardsDirector:       shardsDirector,
 		assignmentDispatcher: assignmentDispatcher,
+		healthServer:         healthServer,
+		writeRateLimiter:     writeRateLimiter,
+		writeSizeLimits:      writeSizeLimits,
+		diskWatermarks:       diskWatermarks,
 		log: slog.With(
 			slog.String("component", "public-rpc-server"),
 		),
 	}
 
+	// The budget of a shard is dropped when the shard leaves this node
+	shardsDirector.setShardRemovedListener(writeRateLimiter.RemoveShard)
+
 	var err error
 	server.grpcServer, err = provider.StartGrpcServer("public", bindAddress, func(registrar grpc.ServiceRegistrar) {
 		proto.RegisterOxiaClientServer(registrar, server)
+		grpc_health_v1.RegisterHealthServer(registrar, server.healthServer)
 	}, tlsConf, options)
 	if err != nil {
 		return nil, err
@@ -99,6 +114,15 @@ func (s *publicRpcServer) Write(ctx context.Context, write *proto.WriteRequest)
 		return nil, err
 	}
 
+	var namespace string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		namespace, _ = readHeader(md, common.MetadataNamespace)
+	}
+
+	if err := s.checkWrite(namespace, *write.Shard, write); err != nil {
+		return nil, err
+	}
+
 	wr, err := lc.Write(ctx, write)
 	if err != nil {
 		s.log.Warn(
@@ -141,7 +165,12 @@ func (s *publicRpcServer) WriteStream(stream proto.OxiaClient_WriteStreamServer)
 		return err
 	}
 
-	err = lc.WriteStream(stream)
+	err = lc.WriteStream(&checkedWriteStream{
+		OxiaClient_WriteStreamServer: stream,
+		check: func(write *proto.WriteRequest) error {
+			return s.checkWrite(namespace, shardId, write)
+		},
+	})
 	if err != nil &&
 		!errors.Is(err, io.EOF) &&
 		!errors.Is(err, context.Canceled) {
@@ -153,6 +182,38 @@ func (s *publicRpcServer) WriteStream(stream proto.OxiaClient_WriteStreamServer)
 	return err
 }
 
+// The writes that are too big, that arrive while the disk is full, or that
+// exceed the rate limit, are rejected before reaching the shard leader.
+func (s *publicRpcServer) checkWrite(namespace string, shard int64, write *proto.WriteRequest) error {
+	if err := s.writeSizeLimits.check(write); err != nil {
+		return err
+	}
+	if err := s.diskWatermarks.checkWrite(shard); err != nil {
+		return err
+	}
+	return s.writeRateLimiter.Allow(namespace, shard, write)
+}
+
+// The write requests received through a stream are checked one by one. A
+// rejected request terminates the stream.
+type checkedWriteStream struct {
+	proto.OxiaClient_WriteStreamServer
+
+	check func(*proto.WriteRequest) error
+}
+
+func (s *checkedWriteStream) Recv() (*proto.WriteRequest, error) {
+	req, err := s.OxiaClient_WriteStreamServer.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.check(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
 //nolint:revive
 func (s *publicRpcServer) Read(request *proto.ReadRequest, stream proto.OxiaClient_ReadServer) error {
 	s.log.Debug(
@@ -161,12 +222,12 @@ func (s *publicRpcServer) Read(request *proto.ReadRequest, stream proto.OxiaClie
 		slog.Any("req", request),
 	)
 
-	lc, err := s.getLeader(*request.Shard)
+	reader, err := s.getReader(request)
 	if err != nil {
 		return err
 	}
 
-	ch := lc.Read(stream.Context(), request)
+	ch := reader.Read(stream.Context(), request)
 
 	response := &proto.ReadResponse{}
 	var totalSize int
@@ -209,17 +270,18 @@ func (s *publicRpcServer) List(request *proto.ListRequest, stream proto.OxiaClie
 		slog.Any("req", request),
 	)
 
-	lc, err := s.getLeader(*request.Shard)
+	scanner, err := s.getScanner(*request.Shard, request.Consistency)
 	if err != nil {
 		return err
 	}
 
-	ch, err := lc.List(stream.Context(), request)
+	ch, err := scanner.List(stream.Context(), request)
 	if err != nil {
 		s.log.Warn(
 			"Failed to perform list operation",
 			slog.Any("error", err),
 		)
+		return err
 	}
 
 	response := &proto.ListResponse{}
@@ -260,17 +322,18 @@ func (s *publicRpcServer) RangeScan(request *proto.RangeScanRequest, stream prot
 		slog.Any("req", request),
 	)
 
-	lc, err := s.getLeader(*request.Shard)
+	scanner, err := s.getScanner(*request.Shard, request.Consistency)
 	if err != nil {
 		return err
 	}
 
-	ch, errCh, err := lc.RangeScan(stream.Context(), request)
+	ch, errCh, err := scanner.RangeScan(stream.Context(), request)
 	if err != nil {
 		s.log.Warn(
 			"Failed to perform range-scan operation",
 			slog.Any("error", err),
 		)
+		return err
 	}
 
 	response := &proto.RangeScanResponse{}
@@ -317,12 +380,12 @@ func (s *publicRpcServer) GetNotifications(req *proto.NotificationsRequest, stre
 		slog.Any("req", req),
 	)
 
-	lc, err := s.getLeader(req.Shard)
+	source, err := s.getNotificationsSource(req.Shard)
 	if err != nil {
 		return err
 	}
 
-	if err = lc.GetNotifications(req, stream); err != nil && !errors.Is(err, context.Canceled) {
+	if err = source.GetNotifications(req, stream); err != nil && !errors.Is(err, context.Canceled) {
 		s.log.Warn(
 			"Failed to handle notifications request",
 			slog.Any("error", err),
@@ -408,12 +471,84 @@ func (s *publicRpcServer) getLeader(shardId int64) (LeaderController, error) {
 				"Failed to get the leader controller",
 				slog.Any("error", err),
 			)
+			return nil, err
 		}
-		return nil, err
+		return nil, s.withLeaderHint(shardId, err)
 	}
 	return lc, nil
 }
 
+// withLeaderHint adds the assignment of the shard known by this node to the
+// error, so that the client can send the requests to the new leader before
+// its own assignments are updated.
+func (s *publicRpcServer) withLeaderHint(shardId int64, err error) error {
+	if s.assignmentDispatcher == nil {
+		return err
+	}
+
+	assignment := s.assignmentDispatcher.GetShardAssignment(shardId)
+	if assignment == nil || assignment.Leader == "" {
+		return err
+	}
+
+	st, detailsErr := status.Convert(err).WithDetails(assignment)
+	if detailsErr != nil {
+		return err
+	}
+	return st.Err()
+}
+
+type shardReader interface {
+	Read(ctx context.Context, request *proto.ReadRequest) <-chan GetResult
+}
+
+// Reads that don't require linearizable consistency can be served by the
+// follower, when this node is hosting one for the shard.
+func (s *publicRpcServer) getReader(request *proto.ReadRequest) (shardReader, error) {
+	if request.Consistency != proto.ReadConsistency_LINEARIZABLE {
+		if fc, err := s.shardsDirector.GetFollower(*request.Shard); err == nil {
+			return fc, nil
+		}
+	}
+
+	return s.getLeader(*request.Shard)
+}
+
+type shardScanner interface {
+	List(ctx context.Context, request *proto.ListRequest) (<-chan string, error)
+	RangeScan(ctx context.Context, request *proto.RangeScanRequest) (<-chan *proto.GetResponse, <-chan error, error)
+}
+
+// Same as the reads, the scans that don't require linearizable consistency
+// can be served by the follower.
+func (s *publicRpcServer) getScanner(shardId int64, consistency proto.ReadConsistency) (shardScanner, error) {
+	if consistency != proto.ReadConsistency_LINEARIZABLE {
+		if fc, err := s.shardsDirector.GetFollower(shardId); err == nil {
+			return fc, nil
+		}
+	}
+
+	return s.getLeader(shardId)
+}
+
+type notificationsSource interface {
+	GetNotifications(req *proto.NotificationsRequest, stream proto.OxiaClient_GetNotificationsServer) error
+}
+
+// The notifications are read from the entries applied in the database, so
+// they can be served by the follower when this node is not the leader.
+func (s *publicRpcServer) getNotificationsSource(shardId int64) (notificationsSource, error) {
+	lc, err := s.getLeader(shardId)
+	if err == nil {
+		return lc, nil
+	}
+
+	if fc, ferr := s.shardsDirector.GetFollower(shardId); ferr == nil {
+		return fc, nil
+	}
+	return nil, err
+}
+
 func (s *publicRpcServer) Close() error {
 	return s.grpcServer.Close()
 }
@@ -27,8 +27,10 @@ import (
 )
 
 var (
-	ErrTooManyCursors    = errors.New("too many cursors")
-	ErrInvalidHeadOffset = errors.New("invalid head offset")
+	ErrTooManyCursors           = errors.New("too many cursors")
+	ErrInvalidHeadOffset        = errors.New("invalid head offset")
+	ErrInvalidReplicationFactor = errors.New("invalid replication factor")
+	ErrCursorAckerNotFound      = errors.New("cursor acker not found")
 )
 
 // QuorumAckTracker
@@ -62,15 +64,42 @@ type QuorumAckTracker interface {
 	// Waits until the specified entry is written on the wal
 	WaitForHeadOffset(ctx context.Context, offset int64) error
 
+	// WaitForHeadOrCommitOffset
+	// Waits until either the specified entry is written on the wal, or the
+	// commit offset has reached the specified one
+	WaitForHeadOrCommitOffset(ctx context.Context, headOffset int64, commitOffset int64) error
+
 	// NewCursorAcker creates a tracker for a new cursor
 	// The `ackOffset` is the previous last-acked position for the cursor
 	NewCursorAcker(ackOffset int64) (CursorAcker, error)
+
+	// NewLearnerCursorAcker creates a tracker for the cursor of a follower that is
+	// joining the ensemble.
+	// The acks of a learner are not counted until it has caught up with the commit
+	// offset. At that point it becomes part of the ack quorum and the replication
+	// factor is raised by one.
+	NewLearnerCursorAcker(ackOffset int64) (CursorAcker, error)
+
+	// RemoveCursorAcker stops counting the acks of a cursor.
+	// If the cursor was part of the ack quorum, the replication factor is decreased
+	// and the pending entries are checked again against the smaller quorum.
+	RemoveCursorAcker(acker CursorAcker) error
+
+	// ReplicationFactor returns the current size of the ensemble, which
+	// includes the leader and the promoted learners
+	ReplicationFactor() uint32
+
+	// HasLearners returns whether there are learners that have not been
+	// promoted yet
+	HasLearners() bool
 }
 
 type quorumAckTracker struct {
 	sync.Mutex
-	waitingRequests   []waitingRequest
-	waitForHeadOffset common.ConditionContext
+	waitingRequests []waitingRequest
+
+	// Broadcast every time the head offset or the commit offset moves
+	waitForOffsets common.ConditionContext
 
 	replicationFactor uint32
 	requiredAcks      uint32
@@ -81,9 +110,13 @@ type quorumAckTracker struct {
 
 	// Keep track of the number of acks that each entry has received
 	// The bitset is used to handle duplicate acks from a single follower
-	tracker            map[int64]*util.BitSet
-	cursorIdxGenerator int
-	closed             bool
+	tracker map[int64]*util.BitSet
+
+	// The indexes assigned to the existing cursors, and the subset of them whose
+	// acks are counted in the quorum
+	cursors util.BitSet
+	voters  util.BitSet
+	closed  bool
 }
 
 type CursorAcker interface {
@@ -93,6 +126,10 @@ type CursorAcker interface {
 type cursorAcker struct {
 	quorumTracker *quorumAckTracker
 	cursorIdx     int
+
+	// Whether the cursor is not counted in the quorum until it has caught up
+	learner bool
+	removed bool
 }
 
 type waitingRequest struct {
@@ -119,7 +156,7 @@ func NewQuorumAckTracker(replicationFactor uint32, headOffset int64, commitOffse
 		q.tracker[offset] = &util.BitSet{}
 	}
 
-	q.waitForHeadOffset = common.NewConditionContext(q)
+	q.waitForOffsets = common.NewConditionContext(q)
 	return q
 }
 
@@ -132,7 +169,7 @@ func (q *quorumAckTracker) AdvanceHeadOffset(headOffset int64) {
 	}
 
 	q.headOffset.Store(headOffset)
-	q.waitForHeadOffset.Broadcast()
+	q.waitForOffsets.Broadcast()
 
 	if q.requiredAcks == 0 {
 		q.notifyCommitOffsetAdvanced(headOffset)
@@ -158,7 +195,20 @@ func (q *quorumAckTracker) WaitForHeadOffset(ctx context.Context, offset int64)
 	defer q.Unlock()
 
 	for !q.closed && q.headOffset.Load() < offset {
-		if err := q.waitForHeadOffset.Wait(ctx); err != nil {
+		if err := q.waitForOffsets.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *quorumAckTracker) WaitForHeadOrCommitOffset(ctx context.Context, headOffset int64, commitOffset int64) error {
+	q.Lock()
+	defer q.Unlock()
+
+	for !q.closed && q.headOffset.Load() < headOffset && q.commitOffset.Load() < commitOffset {
+		if err := q.waitForOffsets.Wait(ctx); err != nil {
 			return err
 		}
 	}
@@ -220,6 +270,7 @@ func (q *quorumAckTracker) WaitForCommitOffsetAsync(offset int64, f func() (*pro
 
 func (q *quorumAckTracker) notifyCommitOffsetAdvanced(commitOffset int64) {
 	q.commitOffset.Store(commitOffset)
+	q.waitForOffsets.Broadcast()
 
 	for _, r := range q.waitingRequests {
 		if r.minOffset > commitOffset {
@@ -236,7 +287,7 @@ func (q *quorumAckTracker) Close() error {
 	defer q.Unlock()
 
 	q.closed = true
-	q.waitForHeadOffset.Broadcast()
+	q.waitForOffsets.Broadcast()
 	return nil
 }
 
@@ -244,17 +295,61 @@ func (q *quorumAckTracker) NewCursorAcker(ackOffset int64) (CursorAcker, error)
 	q.Lock()
 	defer q.Unlock()
 
-	if uint32(q.cursorIdxGenerator) >= q.replicationFactor-1 {
+	if uint32(q.voters.Count()) >= q.replicationFactor-1 {
 		return nil, ErrTooManyCursors
 	}
 
+	return q.newCursorAcker(ackOffset, false)
+}
+
+func (q *quorumAckTracker) NewLearnerCursorAcker(ackOffset int64) (CursorAcker, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	return q.newCursorAcker(ackOffset, true)
+}
+
+func (q *quorumAckTracker) ReplicationFactor() uint32 {
+	q.Lock()
+	defer q.Unlock()
+	return q.replicationFactor
+}
+
+func (q *quorumAckTracker) HasLearners() bool {
+	q.Lock()
+	defer q.Unlock()
+	return q.hasLearners()
+}
+
+func (q *quorumAckTracker) hasLearners() bool {
+	return q.cursors.Count() > q.voters.Count()
+}
+
+func (q *quorumAckTracker) newCursorAcker(ackOffset int64, learner bool) (*cursorAcker, error) {
 	if ackOffset > q.headOffset.Load() {
 		return nil, ErrInvalidHeadOffset
 	}
 
+	cursorIdx := -1
+	for idx := 0; idx < util.MaxBitSetSize; idx++ {
+		if !q.cursors.IsSet(idx) {
+			cursorIdx = idx
+			break
+		}
+	}
+	if cursorIdx < 0 {
+		return nil, ErrTooManyCursors
+	}
+
 	qa := &cursorAcker{
 		quorumTracker: q,
-		cursorIdx:     q.cursorIdxGenerator,
+		cursorIdx:     cursorIdx,
+		learner:       learner,
+	}
+
+	q.cursors.Set(cursorIdx)
+	if !learner {
+		q.voters.Set(cursorIdx)
 	}
 
 	// If the new cursor is already past the current quorum commit offset, we have
@@ -263,15 +358,86 @@ func (q *quorumAckTracker) NewCursorAcker(ackOffset int64) (CursorAcker, error)
 		qa.ack(offset)
 	}
 
-	q.cursorIdxGenerator++
+	// A learner that is not behind can be counted immediately
+	if qa.learner && ackOffset >= q.commitOffset.Load() {
+		qa.promote()
+	}
+
 	return qa, nil
 }
 
+func (q *quorumAckTracker) RemoveCursorAcker(acker CursorAcker) error {
+	q.Lock()
+	defer q.Unlock()
+
+	c, ok := acker.(*cursorAcker)
+	if !ok || c.quorumTracker != q || c.removed {
+		return ErrCursorAckerNotFound
+	}
+
+	c.removed = true
+	q.cursors.Clear(c.cursorIdx)
+	for _, e := range q.tracker {
+		e.Clear(c.cursorIdx)
+	}
+
+	if !q.voters.IsSet(c.cursorIdx) {
+		// The learner was never counted in the quorum
+		return nil
+	}
+
+	q.voters.Clear(c.cursorIdx)
+	q.setReplicationFactor(q.replicationFactor - 1)
+	return nil
+}
+
+// Update the ack quorum and check whether the pending entries are now
+// committed under the new quorum.
+func (q *quorumAckTracker) setReplicationFactor(replicationFactor uint32) {
+	q.replicationFactor = replicationFactor
+	q.requiredAcks = replicationFactor / 2
+
+	// The entries are acked in order by each cursor, so we can stop at the
+	// first one that is still missing acks
+	for offset := q.commitOffset.Load() + 1; offset <= q.headOffset.Load(); offset++ {
+		e, found := q.tracker[offset]
+		if found && q.countVotes(e) < q.requiredAcks {
+			return
+		}
+
+		delete(q.tracker, offset)
+		q.notifyCommitOffsetAdvanced(offset)
+	}
+}
+
+func (q *quorumAckTracker) countVotes(e *util.BitSet) uint32 {
+	votes := e.Intersect(q.voters)
+	return uint32(votes.Count())
+}
+
 func (c *cursorAcker) Ack(offset int64) {
 	c.quorumTracker.Lock()
 	defer c.quorumTracker.Unlock()
 
+	if c.removed || c.quorumTracker.closed {
+		return
+	}
+
 	c.ack(offset)
+
+	if c.learner && offset >= c.quorumTracker.commitOffset.Load() {
+		c.promote()
+	}
+}
+
+// Make the learner part of the ack quorum. The replication factor is raised
+// from its current value, since other members might have been removed while
+// the learner was catching up.
+func (c *cursorAcker) promote() {
+	q := c.quorumTracker
+	q.voters.Set(c.cursorIdx)
+	c.learner = false
+	q.setReplicationFactor(q.replicationFactor + 1)
 }
 
 func (c *cursorAcker) ack(offset int64) {
@@ -284,9 +450,10 @@ func (c *cursorAcker) ack(offset int64) {
 		return
 	}
 
-	// Mark that this follower has acked the entry
+	// Mark that this follower has acked the entry. The acks of learners are
+	// recorded as well, though they only count after the promotion.
 	e.Set(c.cursorIdx)
-	if uint32(e.Count()) == q.requiredAcks {
+	if q.countVotes(e) >= q.requiredAcks {
 		delete(q.tracker, offset)
 
 		// Advance the commit offset
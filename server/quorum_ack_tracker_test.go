@@ -176,6 +176,51 @@ func TestQuorumAckTracker_WaitForHeadOffset(t *testing.T) {
 	}, 10*time.Second, 100*time.Millisecond)
 }
 
+func TestQuorumAckTracker_WaitForHeadOrCommitOffset(t *testing.T) {
+	at := NewQuorumAckTracker(3, 1, wal.InvalidOffset)
+	c1, err := at.NewCursorAcker(wal.InvalidOffset)
+	assert.NoError(t, err)
+
+	ch := make(chan error)
+
+	go func() {
+		ch <- at.WaitForHeadOrCommitOffset(context.Background(), 2, 0)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-ch:
+		assert.Fail(t, "should not be ready")
+	default:
+		// Expected. There should be nothing in the channel
+	}
+
+	// The commit offset moves, while the head offset stays the same
+	c1.Ack(0)
+	assert.EqualValues(t, 0, at.CommitOffset())
+	assert.EqualValues(t, 1, at.HeadOffset())
+
+	select {
+	case err := <-ch:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "should be ready")
+	}
+
+	// The head offset moves
+	go func() {
+		ch <- at.WaitForHeadOrCommitOffset(context.Background(), 2, 1)
+	}()
+
+	at.AdvanceHeadOffset(2)
+	select {
+	case err := <-ch:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "should be ready")
+	}
+}
+
 func TestQuorumAckTracker_WaitForCommitOffset(t *testing.T) {
 	at := NewQuorumAckTracker(3, 1, wal.InvalidOffset)
 
@@ -270,3 +315,71 @@ func TestQuorumAckTracker_AddingCursors_RF5(t *testing.T) {
 	assert.EqualValues(t, 10, at.HeadOffset())
 	assert.EqualValues(t, 7, at.CommitOffset())
 }
+
+func TestQuorumAckTracker_Learner(t *testing.T) {
+	at := NewQuorumAckTracker(3, 10, 8)
+
+	c1, err := at.NewCursorAcker(10)
+	assert.NoError(t, err)
+	_, err = at.NewCursorAcker(8)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, at.CommitOffset())
+
+	// The learner is behind and it's not counted in the quorum
+	learner, err := at.NewLearnerCursorAcker(5)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, at.ReplicationFactor())
+	assert.True(t, at.HasLearners())
+
+	at.AdvanceHeadOffset(11)
+	learner.Ack(9)
+	assert.EqualValues(t, 10, at.CommitOffset())
+	assert.EqualValues(t, 3, at.ReplicationFactor())
+
+	// Once caught up, it becomes part of the quorum
+	learner.Ack(10)
+	assert.EqualValues(t, 4, at.ReplicationFactor())
+	assert.False(t, at.HasLearners())
+
+	c1.Ack(11)
+	assert.EqualValues(t, 10, at.CommitOffset())
+	learner.Ack(11)
+	assert.EqualValues(t, 11, at.CommitOffset())
+}
+
+func TestQuorumAckTracker_LearnerPromotedAfterRemove(t *testing.T) {
+	at := NewQuorumAckTracker(3, 10, 10)
+
+	c1, err := at.NewCursorAcker(10)
+	assert.NoError(t, err)
+	c2, err := at.NewCursorAcker(10)
+	assert.NoError(t, err)
+
+	at.AdvanceHeadOffset(11)
+	learner, err := at.NewLearnerCursorAcker(5)
+	assert.NoError(t, err)
+
+	// A voter is removed before the learner has caught up
+	assert.NoError(t, at.RemoveCursorAcker(c2))
+	assert.EqualValues(t, 2, at.ReplicationFactor())
+	assert.EqualValues(t, 10, at.CommitOffset())
+
+	c1.Ack(11)
+	assert.EqualValues(t, 11, at.CommitOffset())
+
+	// The promotion raises the replication factor from the current one
+	learner.Ack(11)
+	assert.EqualValues(t, 3, at.ReplicationFactor())
+	assert.False(t, at.HasLearners())
+
+	at.AdvanceHeadOffset(12)
+	learner.Ack(12)
+	assert.EqualValues(t, 12, at.CommitOffset())
+
+	// A removed learner doesn't change the replication factor
+	l2, err := at.NewLearnerCursorAcker(5)
+	assert.NoError(t, err)
+	assert.NoError(t, at.RemoveCursorAcker(l2))
+	assert.EqualValues(t, 3, at.ReplicationFactor())
+	assert.ErrorIs(t, at.RemoveCursorAcker(l2), ErrCursorAckerNotFound)
+}
@@ -21,9 +21,11 @@ import (
 	"io"
 	"time"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
 	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/compression"
 	"github.com/streamnative/oxia/proto"
 )
 
@@ -37,13 +39,21 @@ type ReplicationRpcProvider interface {
 }
 
 type replicationRpcProvider struct {
-	pool common.ClientPool
+	pool        common.ClientPool
+	callOptions []grpc.CallOption
 }
 
-func NewReplicationRpcProvider(tlsConf *tls.Config) ReplicationRpcProvider {
-	return &replicationRpcProvider{
-		pool: common.NewClientPool(tlsConf, nil),
+func NewReplicationRpcProvider(tlsConf *tls.Config, replicationCompression string, grpcOptions common.GrpcOptions) ReplicationRpcProvider {
+	r := &replicationRpcProvider{
+		pool: common.NewPeerClientPool(tlsConf, grpcOptions),
 	}
+
+	// The followers decode the messages with any of the registered
+	// compressors, so only the leader side needs to pick one
+	if compressor := compression.GrpcCompressor(replicationCompression); compressor != "" {
+		r.callOptions = append(r.callOptions, grpc.UseCompressor(compressor))
+	}
+	return r
 }
 
 func (r *replicationRpcProvider) GetReplicateStream(ctx context.Context, follower string, namespace string, shard int64, term int64) (
@@ -57,7 +67,7 @@ func (r *replicationRpcProvider) GetReplicateStream(ctx context.Context, followe
 	ctx = metadata.AppendToOutgoingContext(ctx, common.MetadataShardId, fmt.Sprintf("%d", shard))
 	ctx = metadata.AppendToOutgoingContext(ctx, common.MetadataTerm, fmt.Sprintf("%d", term))
 
-	stream, err := rpc.Replicate(ctx)
+	stream, err := rpc.Replicate(ctx, r.callOptions...)
 	return stream, err
 }
 
@@ -23,8 +23,12 @@ import (
 
 	"go.uber.org/multierr"
 	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/compression"
 	"github.com/streamnative/oxia/common/container"
+	"github.com/streamnative/oxia/common/debug"
 	"github.com/streamnative/oxia/common/metrics"
 	"github.com/streamnative/oxia/server/kv"
 	"github.com/streamnative/oxia/server/wal"
@@ -38,16 +42,78 @@ type Config struct {
 	InternalServerTLS   *tls.Config
 	MetricsServiceAddr  string
 
+	// DebugServiceAddr is the bind address of the pprof, runtime stats and
+	// shards status endpoints. Disabled when empty
+	DebugServiceAddr string
+
 	AuthOptions auth.Options
 
 	DataDir string
 	WalDir  string
 
 	WalRetentionTime           time.Duration
-	WalSyncData                bool
+	WalSyncPolicy              wal.SyncPolicy
+	WalSyncInterval            time.Duration
 	NotificationsRetentionTime time.Duration
 
-	DbBlockCacheMB int64
+	DbBlockCacheMB             int64
+	DbMemTableSizeMB           int64
+	DbDisableCompression       bool
+	DbMaxOpenFiles             int
+	DbMaxConcurrentCompactions int
+
+	// DbCompaction schedules the compactions of the databases of the
+	// shards. Disabled by default
+	DbCompaction DbCompactionOptions
+
+	// DiskUsageRefreshInterval is how often the disk space taken by each
+	// shard is measured
+	DiskUsageRefreshInterval time.Duration
+
+	// ShardRecoveryParallelism is the number of shards that are recovered
+	// concurrently at startup. 0 means the number of CPUs
+	ShardRecoveryParallelism int
+
+	// ReplicationCompression is the compressor used on the replication
+	// streams to the followers: none, gzip or zstd
+	ReplicationCompression string
+
+	// EntryCompressionThreshold is the size in bytes above which the values
+	// of the log entries are compressed in the wal and in the replication
+	// streams. 0 means the entries are never compressed
+	EntryCompressionThreshold int
+
+	WriteRateLimit  WriteRateLimitOptions
+	WriteSizeLimits WriteSizeLimits
+
+	// DiskWatermarks stops the writes before the disk of the node is
+	// exhausted. Disabled by default
+	DiskWatermarks DiskWatermarkOptions
+
+	GrpcInterceptors container.InterceptorOptions
+
+	// Grpc are the keepalive and transport settings of the gRPC servers and
+	// of the connections to the other nodes
+	Grpc common.GrpcOptions
+
+	// Maintenance starts the node in maintenance mode, where it is not
+	// elected as leader of the shards
+	Maintenance bool
+
+	// SkipUnappliableEntries lets the followers skip the committed entries
+	// that they cannot apply, instead of stopping at them. The skipped
+	// entries are lost on the replica
+	SkipUnappliableEntries bool
+
+	// Locality is reported to the coordinator, to avoid placing the
+	// replicas of a shard on nodes that might fail together
+	Locality Locality
+}
+
+// Locality tells where the node runs.
+type Locality struct {
+	Zone string
+	Host string
 }
 
 type Server struct {
@@ -58,14 +124,28 @@ type Server struct {
 	shardAssignmentDispatcher ShardAssignmentsDispatcher
 	shardsDirector            ShardsDirector
 	metrics                   *metrics.PrometheusMetrics
+	debug                     *debug.Server
 	walFactory                wal.Factory
 	kvFactory                 kv.Factory
+	diskWatermarks            *diskWatermarkMonitor
 
 	healthServer *health.Server
 }
 
+func (c Config) kvFactoryOptions() *kv.FactoryOptions {
+	return &kv.FactoryOptions{
+		DataDir:                  c.DataDir,
+		CacheSizeMB:              c.DbBlockCacheMB,
+		MemTableSizeMB:           c.DbMemTableSizeMB,
+		DisableCompression:       c.DbDisableCompression,
+		MaxOpenFiles:             c.DbMaxOpenFiles,
+		MaxConcurrentCompactions: c.DbMaxConcurrentCompactions,
+	}
+}
+
 func New(config Config) (*Server, error) {
-	return NewWithGrpcProvider(config, container.Default, NewReplicationRpcProvider(config.PeerTLS))
+	return NewWithGrpcProvider(config, container.NewGrpcProvider(config.GrpcInterceptors, config.Grpc),
+		NewReplicationRpcProvider(config.PeerTLS, config.ReplicationCompression, config.Grpc))
 }
 
 func NewWithGrpcProvider(config Config, provider container.GrpcProvider, replicationRpcProvider ReplicationRpcProvider) (*Server, error) {
@@ -74,10 +154,23 @@ func NewWithGrpcProvider(config Config, provider container.GrpcProvider, replica
 		slog.Any("config", config),
 	)
 
-	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{
-		DataDir:     config.DataDir,
-		CacheSizeMB: config.DbBlockCacheMB,
-	})
+	if err := config.WriteSizeLimits.Validate(); err != nil {
+		return nil, err
+	}
+	if err := compression.ValidateGrpcCompressor(config.ReplicationCompression); err != nil {
+		return nil, err
+	}
+	if err := config.DiskWatermarks.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.DbCompaction.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Grpc.Validate(); err != nil {
+		return nil, err
+	}
+
+	kvFactory, err := kv.NewPebbleKVFactory(config.kvFactoryOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -85,26 +178,49 @@ func NewWithGrpcProvider(config Config, provider container.GrpcProvider, replica
 	s := &Server{
 		replicationRpcProvider: replicationRpcProvider,
 		walFactory: wal.NewWalFactory(&wal.FactoryOptions{
-			BaseWalDir:  config.WalDir,
-			Retention:   config.WalRetentionTime,
-			SegmentSize: wal.DefaultFactoryOptions.SegmentSize,
-			SyncData:    true,
+			BaseWalDir:   config.WalDir,
+			Retention:    config.WalRetentionTime,
+			SegmentSize:  wal.DefaultFactoryOptions.SegmentSize,
+			SyncPolicy:   config.WalSyncPolicy,
+			SyncInterval: config.WalSyncInterval,
 		}),
 		kvFactory:    kvFactory,
 		healthServer: health.NewServer(),
 	}
 
-	s.shardsDirector = NewShardsDirector(config, s.walFactory, s.kvFactory, replicationRpcProvider)
+	// The node is not serving until all the services are started
+	s.healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	// The debug endpoints are available before the shards are recovered, so
+	// that a slow startup can be looked into
+	if config.DebugServiceAddr != "" {
+		if s.debug, err = debug.Start(config.DebugServiceAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	s.shardsDirector = NewShardsDirector(config, s.walFactory, s.kvFactory, replicationRpcProvider, s.healthServer)
+	if s.debug != nil {
+		s.debug.Handle("/debug/shards", newShardsDebugHandler(s.shardsDirector))
+	}
 	s.shardAssignmentDispatcher = NewShardAssignmentDispatcher(s.healthServer)
 
+	s.diskWatermarks, err = newDiskWatermarkMonitor(config.DiskWatermarks, []string{config.WalDir, config.DataDir},
+		s.healthServer, fsDiskStats)
+	if err != nil {
+		return nil, err
+	}
+
 	s.internalRpcServer, err = newInternalRpcServer(provider, config.InternalServiceAddr,
-		s.shardsDirector, s.shardAssignmentDispatcher, s.healthServer, config.InternalServerTLS)
+		s.shardsDirector, s.shardAssignmentDispatcher, s.healthServer, newMaintenanceMode(config.Maintenance, s.healthServer),
+		s.diskWatermarks, config.Locality, config.InternalServerTLS)
 	if err != nil {
 		return nil, err
 	}
 
 	s.publicRpcServer, err = newPublicRpcServer(provider, config.PublicServiceAddr, s.shardsDirector,
-		s.shardAssignmentDispatcher, config.ServerTLS, &config.AuthOptions)
+		s.shardAssignmentDispatcher, s.healthServer, NewWriteRateLimiter(config.WriteRateLimit), config.WriteSizeLimits,
+		s.diskWatermarks, config.ServerTLS, &config.AuthOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +231,8 @@ func NewWithGrpcProvider(config Config, provider container.GrpcProvider, replica
 			return nil, err
 		}
 	}
+
+	s.healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
 	return s, nil
 }
 
@@ -134,6 +252,7 @@ func (s *Server) Close() error {
 		s.shardsDirector.Close(),
 		s.publicRpcServer.Close(),
 		s.internalRpcServer.Close(),
+		s.diskWatermarks.Close(),
 		s.kvFactory.Close(),
 		s.walFactory.Close(),
 		s.replicationRpcProvider.Close(),
@@ -142,6 +261,9 @@ func (s *Server) Close() error {
 	if s.metrics != nil {
 		err = multierr.Append(err, s.metrics.Close())
 	}
+	if s.debug != nil {
+		err = multierr.Append(err, s.debug.Close())
+	}
 
 	return err
 }
@@ -48,3 +48,30 @@ func TestNewServer(t *testing.T) {
 	// Looks like exposition format
 	assert.Equal(t, "# HELP ", string(body[0:7]))
 }
+
+func TestNewServer_DebugEndpoints(t *testing.T) {
+	config := Config{
+		InternalServiceAddr: "localhost:0",
+		PublicServiceAddr:   "localhost:0",
+		DebugServiceAddr:    "localhost:0",
+		DataDir:             t.TempDir(),
+		WalDir:              t.TempDir(),
+	}
+
+	server, err := New(config)
+	assert.NoError(t, err)
+
+	for path, contentType := range map[string]string{
+		"/debug/pprof/": "text/html; charset=utf-8",
+		"/debug/vars":   "application/json; charset=utf-8",
+		"/debug/shards": "application/json",
+	} {
+		response, err := http.Get(fmt.Sprintf("http://localhost:%d%s", server.debug.Port(), path))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode, path)
+		assert.Equal(t, contentType, response.Header.Get("Content-Type"), path)
+		assert.NoError(t, response.Body.Close())
+	}
+
+	assert.NoError(t, server.Close())
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/streamnative/oxia/common"
 
 	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
 )
 
 // --- Session
@@ -124,9 +125,12 @@ func (s *session) delete() error {
 		}
 	}
 
-	// Delete the base session metadata
+	// Delete the base session metadata and the responses retained to
+	// deduplicate the requests of the session
 	deletes = append(deletes, &proto.DeleteRequest{
 		Key: sessionKey,
+	}, &proto.DeleteRequest{
+		Key: kv.SessionWritesKey(int64(s.id)),
 	})
 	_, err = s.sm.leaderController.Write(context.Background(), &proto.WriteRequest{
 		Shard:   &s.shardId,
@@ -168,10 +172,21 @@ func (s *session) waitForHeartbeats() {
 				return
 			}
 			timer.Reset(s.timeout)
+		case <-s.ctx.Done():
+			// The session was closed before it could start waiting on the
+			// heartbeats channel
+			timer.Stop()
+			return
 		case <-timeoutCh:
-			s.log.Warn("Session expired")
-
 			s.Lock()
+			if s.ctx.Err() != nil {
+				// The session manager is closing, the next leader will
+				// take care of the session
+				s.Unlock()
+				return
+			}
+
+			s.log.Warn("Session expired")
 			s.closeChannels()
 			err := s.delete()
 
@@ -307,8 +307,21 @@ type updateCallback struct{}
 
 var SessionUpdateOperationCallback kv.UpdateOperationCallback = &updateCallback{}
 
-func (*updateCallback) OnPutWithinSession(batch kv.WriteBatch, request *proto.PutRequest, _ *proto.StorageEntry) (proto.Status, error) {
-	var _, closer, err = batch.Get(SessionKey(SessionId(*request.SessionId)))
+func (c *updateCallback) OnPutWithinSession(batch kv.WriteBatch, request *proto.PutRequest, _ *proto.StorageEntry) (proto.Status, error) {
+	if status, err := c.OnSessionWrite(batch, *request.SessionId); status != proto.Status_OK || err != nil {
+		return status, err
+	}
+	// Create the session shadow entry
+	err := batch.Put(ShadowKey(SessionId(*request.SessionId), request.Key), []byte{})
+	if err != nil {
+		return proto.Status_SESSION_DOES_NOT_EXIST, err
+	}
+
+	return proto.Status_OK, nil
+}
+
+func (*updateCallback) OnSessionWrite(batch kv.WriteBatch, sessionId int64) (proto.Status, error) {
+	var _, closer, err = batch.Get(SessionKey(SessionId(sessionId)))
 	if err != nil {
 		if errors.Is(err, kv.ErrKeyNotFound) {
 			return proto.Status_SESSION_DOES_NOT_EXIST, nil
@@ -318,12 +331,6 @@ func (*updateCallback) OnPutWithinSession(batch kv.WriteBatch, request *proto.Pu
 	if err = closer.Close(); err != nil {
 		return proto.Status_SESSION_DOES_NOT_EXIST, err
 	}
-	// Create the session shadow entry
-	err = batch.Put(ShadowKey(SessionId(*request.SessionId), request.Key), []byte{})
-	if err != nil {
-		return proto.Status_SESSION_DOES_NOT_EXIST, err
-	}
-
 	return proto.Status_OK, nil
 }
 
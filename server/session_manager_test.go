@@ -482,6 +482,168 @@ func TestSessionManagerReopening(t *testing.T) {
 	assert.NoError(t, walf.Close())
 }
 
+func TestSessionManager_ExpiryAfterFailover(t *testing.T) {
+	shardId := int64(1)
+	kvf, walf, sManager, lc := createSessionManager(t)
+
+	createResp, err := sManager.createSession(&proto.CreateSessionRequest{
+		Shard:            shardId,
+		SessionTimeoutMs: uint32(1000),
+	}, 0)
+	assert.NoError(t, err)
+	sessionId := createResp.SessionId
+
+	_, err = lc.Write(context.Background(), &proto.WriteRequest{
+		Shard: &shardId,
+		Puts: []*proto.PutRequest{{
+			Key:       "/ephemeral",
+			Value:     []byte("hello"),
+			SessionId: &sessionId,
+		}},
+	})
+	assert.NoError(t, err)
+
+	// The new leader takes over the session from the replicated state,
+	// without expiring it right away
+	lc = reopenLeaderController(t, kvf, walf, lc)
+	assert.NotNil(t, getSessionMetadata(t, lc, sessionId))
+	assert.Equal(t, "hello", getData(t, lc, "/ephemeral"))
+
+	// With no heartbeats, the session expires on the new leader
+	assert.Eventually(t, func() bool {
+		return getSessionMetadata(t, lc, sessionId) == nil
+	}, 10*time.Second, 30*time.Millisecond)
+	assert.Equal(t, "", getData(t, lc, "/ephemeral"))
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvf.Close())
+	assert.NoError(t, walf.Close())
+}
+
+func TestSessionManager_KeepAliveAfterFailover(t *testing.T) {
+	shardId := int64(1)
+	kvf, walf, sManager, lc := createSessionManager(t)
+
+	createResp, err := sManager.createSession(&proto.CreateSessionRequest{
+		Shard:            shardId,
+		SessionTimeoutMs: uint32(1000),
+	}, 0)
+	assert.NoError(t, err)
+	sessionId := createResp.SessionId
+
+	_, err = lc.Write(context.Background(), &proto.WriteRequest{
+		Shard: &shardId,
+		Puts: []*proto.PutRequest{{
+			Key:       "/ephemeral",
+			Value:     []byte("hello"),
+			SessionId: &sessionId,
+		}},
+	})
+	assert.NoError(t, err)
+
+	lc = reopenLeaderController(t, kvf, walf, lc)
+	sManager = lc.sessionManager.(*sessionManager)
+
+	// The client reconnects to the new leader and keeps the session alive
+	// for longer than its timeout
+	for i := 0; i < 10; i++ {
+		time.Sleep(200 * time.Millisecond)
+		assert.NoError(t, sManager.KeepAlive(sessionId))
+	}
+	assert.NotNil(t, getSessionMetadata(t, lc, sessionId))
+	assert.Equal(t, "hello", getData(t, lc, "/ephemeral"))
+
+	assert.Eventually(t, func() bool {
+		return getSessionMetadata(t, lc, sessionId) == nil
+	}, 10*time.Second, 30*time.Millisecond)
+	assert.Equal(t, "", getData(t, lc, "/ephemeral"))
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvf.Close())
+	assert.NoError(t, walf.Close())
+}
+
+func TestSessionManager_WriteDeduplicationAfterFailover(t *testing.T) {
+	shardId := int64(1)
+	kvf, walf, sManager, lc := createSessionManager(t)
+
+	createResp, err := sManager.CreateSession(&proto.CreateSessionRequest{
+		Shard:            shardId,
+		SessionTimeoutMs: 5 * 1000,
+	})
+	assert.NoError(t, err)
+	sessionId := createResp.SessionId
+
+	requests := []*proto.WriteRequest{{
+		Shard:     &shardId,
+		Puts:      []*proto.PutRequest{{Key: "/a", Value: []byte("a")}},
+		SessionId: &sessionId,
+		Sequence:  pb.Uint64(1),
+	}, {
+		Shard:     &shardId,
+		Puts:      []*proto.PutRequest{{Key: "/b", Value: []byte("b")}},
+		Deletes:   []*proto.DeleteRequest{{Key: "/a"}},
+		SessionId: &sessionId,
+		Sequence:  pb.Uint64(2),
+	}}
+
+	var responses []*proto.WriteResponse
+	for _, req := range requests {
+		res, err := lc.Write(context.Background(), req)
+		assert.NoError(t, err)
+		responses = append(responses, res)
+	}
+
+	// The retries on the new leader get the original responses and are
+	// not applied again
+	lc = reopenLeaderController(t, kvf, walf, lc)
+
+	for i, req := range requests {
+		res, err := lc.Write(context.Background(), req)
+		assert.NoError(t, err)
+		assert.True(t, pb.Equal(responses[i], res))
+	}
+
+	assert.Equal(t, "", getData(t, lc, "/a"))
+	gr, err := lc.db.Get(&proto.GetRequest{Key: "/b"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, gr.Version.ModificationsCount)
+	assert.Equal(t, responses[1].Puts[0].Version.VersionId, gr.Version.VersionId)
+
+	// A new request of the session is applied
+	res, err := lc.Write(context.Background(), &proto.WriteRequest{
+		Shard:     &shardId,
+		Puts:      []*proto.PutRequest{{Key: "/a", Value: []byte("a2")}},
+		SessionId: &sessionId,
+		Sequence:  pb.Uint64(3),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, res.Puts[0].Status)
+	assert.Equal(t, "a2", getData(t, lc, "/a"))
+
+	// The retained responses go away with the session
+	sManager = lc.sessionManager.(*sessionManager)
+	_, err = sManager.CloseSession(&proto.CloseSessionRequest{
+		Shard:     shardId,
+		SessionId: sessionId,
+	})
+	assert.NoError(t, err)
+
+	gr, err = lc.db.Get(&proto.GetRequest{Key: kv.SessionWritesKey(sessionId)})
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_KEY_NOT_FOUND, gr.Status)
+
+	// Once the session is gone, its requests are rejected
+	res, err = lc.Write(context.Background(), requests[0])
+	assert.NoError(t, err)
+	assert.Equal(t, proto.Status_SESSION_DOES_NOT_EXIST, res.Puts[0].Status)
+	assert.Equal(t, "a2", getData(t, lc, "/a"))
+
+	assert.NoError(t, lc.Close())
+	assert.NoError(t, kvf.Close())
+	assert.NoError(t, walf.Close())
+}
+
 func getData(t *testing.T, lc *leaderController, key string) string {
 	t.Helper()
 
@@ -0,0 +1,321 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+const splitCheckInterval = 100 * time.Millisecond
+
+// Once a shard is split, all the requests are rejected with an error that
+// carries the shards replacing it, so that the clients know which shard
+// assignments they have to wait for.
+func newShardSplitError(split *proto.ShardSplit) error {
+	assignments := &proto.NamespaceShardsAssignment{
+		ShardKeyRouter: proto.ShardKeyRouter_XXHASH3,
+	}
+	for shard, hashRange := range split.Children {
+		assignments.Assignments = append(assignments.Assignments, &proto.ShardAssignment{
+			Shard:           shard,
+			ShardBoundaries: &proto.ShardAssignment_Int32HashRange{Int32HashRange: hashRange},
+		})
+	}
+	sort.Slice(assignments.Assignments, func(i, j int) bool {
+		return assignments.Assignments[i].Shard < assignments.Assignments[j].Shard
+	})
+
+	st, err := status.Convert(common.ErrorShardSplit).WithDetails(assignments)
+	if err != nil {
+		return common.ErrorShardSplit
+	}
+	return st.Err()
+}
+
+// The cursors that replicate the parent log to the children shards must not
+// take part in the quorum of the parent shard.
+type splitAckTracker struct {
+	QuorumAckTracker
+}
+
+func (*splitAckTracker) NewCursorAcker(int64) (CursorAcker, error) {
+	return &splitCursorAcker{}, nil
+}
+
+func (*splitAckTracker) NewLearnerCursorAcker(int64) (CursorAcker, error) {
+	return &splitCursorAcker{}, nil
+}
+
+func (*splitAckTracker) RemoveCursorAcker(CursorAcker) error {
+	return nil
+}
+
+type splitCursorAcker struct{}
+
+func (*splitCursorAcker) Ack(int64) {}
+
+// SplitShard replicates the shard to the members of the children shards
+// and then appends the split entry, after which no more writes are accepted.
+//
+// The children receive a snapshot of the parent, followed by all the
+// entries that are written in the meantime, including the split entry, so
+// that they end up with all the acknowledged writes. The response is sent
+// once a majority of each child has acknowledged the split entry.
+func (lc *leaderController) SplitShard(ctx context.Context, req *proto.SplitShardRequest) (*proto.SplitShardResponse, error) {
+	lc.Lock()
+
+	if req.Term != lc.term {
+		lc.Unlock()
+		return nil, common.ErrorInvalidTerm
+	}
+
+	if lc.status != proto.ServingStatus_LEADER {
+		lc.Unlock()
+		return nil, errors.Wrap(common.ErrorInvalidStatus, "Node is not leader")
+	}
+
+	split := &proto.ShardSplit{Children: map[int64]*proto.Int32HashRange{}}
+	for _, child := range req.Children {
+		split.Children[child.Shard] = child.Int32HashRange
+	}
+
+	if lc.split != nil && !pb.Equal(lc.split, split) {
+		lc.Unlock()
+		return nil, errors.Errorf("shard was already split into different shards: %v", lc.split)
+	}
+
+	term := lc.term
+	cursors, err := lc.newSplitCursors(req.Children)
+	lc.Unlock()
+	defer func() {
+		for _, childCursors := range cursors {
+			for _, cursor := range childCursors {
+				_ = cursor.Close()
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	lc.log.Info(
+		"Splitting shard",
+		slog.Any("children", split.Children),
+	)
+
+	// Let the children catch up while the shard is still accepting writes,
+	// to keep short the time in which the writes are rejected
+	if err = waitForSplitChildrenAck(ctx, cursors, lc.quorumAckTracker.CommitOffset()); err != nil {
+		return nil, errors.Wrap(err, "children shards have not caught up")
+	}
+
+	splitOffset, err := lc.appendShardSplit(ctx, term, split)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = waitForSplitChildrenAck(ctx, cursors, splitOffset); err != nil {
+		return nil, errors.Wrap(err, "children shards have not received the split entry")
+	}
+
+	lc.log.Info(
+		"Shard was split",
+		slog.Any("children", split.Children),
+		slog.Int64("split-offset", splitOffset),
+	)
+	return &proto.SplitShardResponse{
+		SplitEntryId: &proto.EntryId{Term: term, Offset: splitOffset},
+	}, nil
+}
+
+func (lc *leaderController) newSplitCursors(children []*proto.SplitShardChild) (map[int64][]FollowerCursor, error) {
+	cursors := make(map[int64][]FollowerCursor)
+	for _, child := range children {
+		for _, member := range child.Ensemble {
+			// The children start from an empty state, so they receive
+			// a snapshot of the parent first
+			cursor, err := newFollowerCursor(member, lc.term, lc.namespace, child.Shard, lc.rpcClient,
+				&splitAckTracker{lc.quorumAckTracker}, lc.wal, lc.db, wal.InvalidOffset, false)
+			if err != nil {
+				return cursors, errors.Wrapf(err, "failed to create cursor for shard %d on %s", child.Shard, member)
+			}
+
+			cursors[child.Shard] = append(cursors[child.Shard], cursor)
+		}
+	}
+	return cursors, nil
+}
+
+// Append the split entry and apply it to the database, once committed. From
+// the moment it's appended, the leader stops accepting requests.
+func (lc *leaderController) appendShardSplit(ctx context.Context, term int64, split *proto.ShardSplit) (int64, error) {
+	lc.Lock()
+
+	if lc.status != proto.ServingStatus_LEADER || lc.term != term {
+		lc.Unlock()
+		return wal.InvalidOffset, common.ErrorInvalidTerm
+	}
+
+	if lc.split != nil {
+		// The split entry was already appended by a previous request
+		splitOffset := lc.splitOffset
+		lc.Unlock()
+
+		_, err := lc.quorumAckTracker.WaitForCommitOffset(ctx, splitOffset, nil)
+		return splitOffset, err
+	}
+
+	if lc.transferringLeadership {
+		lc.Unlock()
+		return wal.InvalidOffset, common.ErrorNodeIsNotLeader
+	}
+
+	newOffset := lc.quorumAckTracker.NextOffset()
+	timestamp := uint64(time.Now().UnixMilli())
+
+	value, err := (&proto.LogEntryValue{
+		Value: &proto.LogEntryValue_Split{Split: split},
+	}).MarshalVT()
+	if err != nil {
+		lc.Unlock()
+		return wal.InvalidOffset, err
+	}
+
+	logEntry := &proto.LogEntry{
+		Term:      term,
+		Offset:    newOffset,
+		Timestamp: timestamp,
+	}
+	compressLogEntryValue(logEntry, value, lc.entryCompressionThreshold)
+
+	if err = lc.wal.AppendAsync(logEntry); err != nil {
+		lc.Unlock()
+		return wal.InvalidOffset, errors.Wrap(err, "oxia: failed to append to wal")
+	}
+
+	lc.split = split
+	lc.splitOffset = newOffset
+	lc.Unlock()
+
+	if err = lc.wal.Sync(ctx); err != nil {
+		return wal.InvalidOffset, errors.Wrap(err, "oxia: failed to sync the wal")
+	}
+	lc.quorumAckTracker.AdvanceHeadOffset(newOffset)
+
+	_, err = lc.quorumAckTracker.WaitForCommitOffset(ctx, newOffset, func() (*proto.WriteResponse, error) {
+		return nil, lc.db.ProcessShardSplit(split, term, newOffset, timestamp, SessionUpdateOperationCallback)
+	})
+	return newOffset, err
+}
+
+// A write stream that is rejected because of the split gets closed only after
+// the responses to the writes accepted before the split are sent, otherwise
+// the client would retry them on the children shards.
+func (lc *leaderController) closeWriteStream(closeCh chan error, err error) {
+	if status.Code(err) != common.CodeShardSplit {
+		closeCh <- err
+		return
+	}
+
+	lc.RLock()
+	splitOffset := lc.splitOffset
+	lc.RUnlock()
+
+	lc.quorumAckTracker.WaitForCommitOffsetAsync(splitOffset, nil, func(*proto.WriteResponse, error) {
+		select {
+		case closeCh <- err:
+		default:
+		}
+	})
+}
+
+// Load the split that was applied in a previous term, if any.
+func (lc *leaderController) loadShardSplit() error {
+	split, err := lc.db.ShardSplit()
+	if err != nil || split == nil {
+		return err
+	}
+
+	// Nothing gets appended after the split entry
+	commitOffset, err := lc.db.ReadCommitOffset()
+	if err != nil {
+		return err
+	}
+
+	lc.split = split
+	lc.splitOffset = commitOffset
+	return nil
+}
+
+func (lc *leaderController) checkNotSplit() error {
+	if lc.split != nil {
+		return newShardSplitError(lc.split)
+	}
+	return nil
+}
+
+func (lc *leaderController) checkAcceptsReads() error {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	if err := checkStatusIsLeader(lc.status); err != nil {
+		return err
+	}
+	return lc.checkNotSplit()
+}
+
+// Wait until a majority of the members of each child has acknowledged the
+// offset.
+func waitForSplitChildrenAck(ctx context.Context, cursors map[int64][]FollowerCursor, offset int64) error {
+	ticker := time.NewTicker(splitCheckInterval)
+	defer ticker.Stop()
+
+	for !splitChildrenHaveAcked(cursors, offset) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func splitChildrenHaveAcked(cursors map[int64][]FollowerCursor, offset int64) bool {
+	for _, childCursors := range cursors {
+		acked := 0
+		for _, cursor := range childCursors {
+			if cursor.AckOffset() >= offset {
+				acked++
+			}
+		}
+
+		if acked < len(childCursors)/2+1 {
+			return false
+		}
+	}
+	return true
+}
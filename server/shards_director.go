@@ -15,11 +15,18 @@
 package server
 
 import (
+	"context"
 	"io"
 	"log/slog"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/multierr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/status"
 
 	"github.com/streamnative/oxia/common"
@@ -39,6 +46,35 @@ type ShardsDirector interface {
 	GetOrCreateFollower(namespace string, shardId int64, term int64) (FollowerController, error)
 
 	DeleteShard(req *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error)
+
+	// AssignShard creates an empty follower controller for the shard, which
+	// can then be fenced and caught up by the shard leader.
+	AssignShard(req *proto.AssignShardRequest) (*proto.AssignShardResponse, error)
+
+	// UnassignShard closes the controller of the shard, if any, and
+	// optionally deletes the shard data from the node.
+	UnassignShard(req *proto.UnassignShardRequest) (*proto.UnassignShardResponse, error)
+
+	// CompactShard compacts the database of the shard, once a compaction
+	// slot is available.
+	CompactShard(ctx context.Context, req *proto.CompactShardRequest) (*proto.CompactShardResponse, error)
+
+	// setShardRemovedListener sets the function called when a shard is no
+	// longer hosted on this node, after it's deleted or unassigned.
+	setShardRemovedListener(listener func(shardId int64))
+}
+
+// The operations that leader and follower controllers have in common.
+type shardController interface {
+	io.Closer
+
+	Term() int64
+	DeleteShard(req *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error)
+}
+
+// The controllers notify the changes in their serving status.
+type statusNotifier interface {
+	setStatusListener(listener func(proto.ServingStatus))
 }
 
 type shardsDirector struct {
@@ -51,21 +87,36 @@ type shardsDirector struct {
 	kvFactory              kv.Factory
 	walFactory             wal.Factory
 	replicationRpcProvider ReplicationRpcProvider
+	healthServer           *health.Server
+	shardRemovedListener   func(shardId int64)
 	closed                 bool
+	ctx                    context.Context
+	cancel                 context.CancelFunc
 	log                    *slog.Logger
 
 	leadersCounter   metrics.UpDownCounter
 	followersCounter metrics.UpDownCounter
+	shardsGauges     []metrics.Gauge
+	recoveryLatency  metrics.LatencyHistogram
+
+	// The shards whose controller is being recovered at startup
+	recovering map[int64]chan struct{}
+	recoveryWg sync.WaitGroup
+
+	compactions *dbCompactionScheduler
 }
 
-func NewShardsDirector(config Config, walFactory wal.Factory, kvFactory kv.Factory, provider ReplicationRpcProvider) ShardsDirector {
+func NewShardsDirector(config Config, walFactory wal.Factory, kvFactory kv.Factory, provider ReplicationRpcProvider,
+	healthServer *health.Server) ShardsDirector {
 	sd := &shardsDirector{
 		config:                 config,
 		walFactory:             walFactory,
 		kvFactory:              kvFactory,
 		leaders:                make(map[int64]LeaderController),
 		followers:              make(map[int64]FollowerController),
+		recovering:             make(map[int64]chan struct{}),
 		replicationRpcProvider: provider,
+		healthServer:           healthServer,
 		log: slog.With(
 			slog.String("component", "shards-director"),
 		),
@@ -74,11 +125,294 @@ func NewShardsDirector(config Config, walFactory wal.Factory, kvFactory kv.Facto
 			"The number of leader controllers in a server", "count", map[string]any{}),
 		followersCounter: metrics.NewUpDownCounter("oxia_server_followers_count",
 			"The number of follower controllers in a server", "count", map[string]any{}),
+		recoveryLatency: metrics.NewLatencyHistogram("oxia_server_shard_recovery_latency",
+			"The time it takes to recover the controller of a shard at startup", map[string]any{}),
+	}
+
+	for _, servingStatus := range []proto.ServingStatus{
+		proto.ServingStatus_LEADER,
+		proto.ServingStatus_FOLLOWER,
+		proto.ServingStatus_FENCED,
+		proto.ServingStatus_NOT_MEMBER,
+	} {
+		servingStatus := servingStatus
+		sd.shardsGauges = append(sd.shardsGauges, metrics.NewGauge("oxia_server_shards",
+			"The number of shards hosted in a server, by status", "count",
+			map[string]any{"status": strings.ToLower(servingStatus.String())}, func() int64 {
+				return sd.countShards(servingStatus)
+			}))
 	}
 
+	sd.ctx, sd.cancel = context.WithCancel(context.Background())
+	go common.DoWithLabels(
+		sd.ctx,
+		map[string]string{
+			"oxia": "disk-usage-refresh",
+		},
+		sd.refreshDiskUsageLoop,
+	)
+
+	sd.compactions = newDbCompactionScheduler(config.DbCompaction, common.SystemClock, sd.dbCompactors)
+	go common.DoWithLabels(
+		sd.ctx,
+		map[string]string{
+			"oxia": "db-compaction",
+		},
+		func() {
+			sd.compactions.run(sd.ctx)
+		},
+	)
+
+	sd.recoverShards()
+
 	return sd
 }
 
+type shardToRecover struct {
+	namespace string
+	shardId   int64
+}
+
+// Open the controllers of the shards that have data on the disk, so that
+// they are ready when the coordinator reaches out to the node. The shards
+// are recovered in the background by a pool of workers, and each shard is
+// available as soon as its controller is created. A shard that fails to
+// recover is left out, without affecting the others.
+func (s *shardsDirector) recoverShards() {
+	shards, err := s.kvFactory.ListShards()
+	if err != nil {
+		s.log.Error(
+			"Failed to list the shards to recover",
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	var pending []shardToRecover
+	for namespace, shardIds := range shards {
+		for _, shardId := range shardIds {
+			pending = append(pending, shardToRecover{namespace, shardId})
+			s.recovering[shardId] = make(chan struct{})
+		}
+	}
+
+	count := len(pending)
+	if count == 0 {
+		return
+	}
+
+	queue := make(chan shardToRecover, count)
+	for _, shard := range pending {
+		queue <- shard
+	}
+	close(queue)
+
+	parallelism := s.config.ShardRecoveryParallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+	parallelism = min(parallelism, count)
+
+	s.log.Info(
+		"Recovering shards",
+		slog.Int("shards", count),
+		slog.Int("parallelism", parallelism),
+	)
+
+	start := time.Now()
+	var failed atomic.Int64
+	s.recoveryWg.Add(parallelism)
+	for i := 0; i < parallelism; i++ {
+		go common.DoWithLabels(
+			s.ctx,
+			map[string]string{
+				"oxia": "shard-recovery",
+			},
+			func() {
+				defer s.recoveryWg.Done()
+
+				for shard := range queue {
+					if err := s.recoverShard(shard); err != nil {
+						failed.Add(1)
+					}
+				}
+			},
+		)
+	}
+
+	go func() {
+		s.recoveryWg.Wait()
+		s.log.Info(
+			"Recovered shards",
+			slog.Int("shards", count),
+			slog.Int64("failed", failed.Load()),
+			slog.Duration("elapsed-time", time.Since(start)),
+		)
+	}()
+}
+
+func (s *shardsDirector) recoverShard(shard shardToRecover) error {
+	log := s.log.With(
+		slog.String("namespace", shard.namespace),
+		slog.Int64("shard", shard.shardId),
+	)
+
+	var fc FollowerController
+	var err error
+	start := time.Now()
+	if err = s.ctx.Err(); err == nil {
+		timer := s.recoveryLatency.Timer()
+		if fc, err = NewFollowerController(s.config, shard.namespace, shard.shardId, s.walFactory, s.kvFactory); err == nil {
+			timer.Done()
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// Let the requests for the shard go ahead
+	close(s.recovering[shard.shardId])
+	delete(s.recovering, shard.shardId)
+
+	if err != nil {
+		log.Error(
+			"Failed to recover shard",
+			slog.Any("error", err),
+		)
+		return err
+	}
+
+	s.followers[shard.shardId] = fc
+	s.followersCounter.Inc()
+	s.reportShardHealth(shard.namespace, shard.shardId, fc.(statusNotifier))
+
+	log.Info(
+		"Recovered shard",
+		slog.Duration("elapsed-time", time.Since(start)),
+		slog.Any("status", fc.Status()),
+	)
+	return nil
+}
+
+// Wait until the shard is not being recovered anymore, so that a request
+// doesn't open the shard a second time. It must be called with the lock
+// held, which is released while waiting.
+func (s *shardsDirector) waitForRecovery(shardId int64) {
+	for {
+		done, ok := s.recovering[shardId]
+		if !ok {
+			return
+		}
+
+		s.Unlock()
+		<-done
+		s.Lock()
+	}
+}
+
+// Measure the disk usage of the shards periodically, rather than at each
+// metrics scrape or status request.
+func (s *shardsDirector) refreshDiskUsageLoop() {
+	interval := s.config.DiskUsageRefreshInterval
+	if interval <= 0 {
+		interval = DefaultDiskUsageRefreshInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshDiskUsage()
+		}
+	}
+}
+
+func (s *shardsDirector) refreshDiskUsage() {
+	// The controllers are refreshed without holding the lock, since going
+	// through the files of a shard can take a while
+	s.RLock()
+	reporters := make(map[int64]diskUsageReporter, len(s.leaders)+len(s.followers))
+	for shardId, leader := range s.leaders {
+		reporters[shardId] = leader.(diskUsageReporter)
+	}
+	for shardId, follower := range s.followers {
+		reporters[shardId] = follower.(diskUsageReporter)
+	}
+	s.RUnlock()
+
+	for shardId, reporter := range reporters {
+		if err := reporter.refreshDiskUsage(); err != nil {
+			s.log.Warn(
+				"Failed to measure the disk usage of the shard",
+				slog.Int64("shard", shardId),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+func (s *shardsDirector) dbCompactors() map[int64]dbCompactor {
+	s.RLock()
+	defer s.RUnlock()
+
+	compactors := make(map[int64]dbCompactor, len(s.leaders)+len(s.followers))
+	for shardId, leader := range s.leaders {
+		compactors[shardId] = leader.(dbCompactor)
+	}
+	for shardId, follower := range s.followers {
+		compactors[shardId] = follower.(dbCompactor)
+	}
+	return compactors
+}
+
+// Keep the health status of the shard in sync with the status of its
+// controller.
+func (s *shardsDirector) reportShardHealth(namespace string, shardId int64, controller statusNotifier) {
+	controller.setStatusListener(func(status proto.ServingStatus) {
+		s.setShardHealth(namespace, shardId, status)
+	})
+}
+
+func (s *shardsDirector) setShardHealth(namespace string, shardId int64, status proto.ServingStatus) {
+	s.healthServer.SetServingStatus(ShardHealthService(namespace, shardId), shardHealthStatus(status))
+}
+
+func (s *shardsDirector) setShardRemovedListener(listener func(shardId int64)) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.shardRemovedListener = listener
+}
+
+func (s *shardsDirector) shardRemoved(namespace string, shardId int64) {
+	s.setShardHealth(namespace, shardId, proto.ServingStatus_NOT_MEMBER)
+	if s.shardRemovedListener != nil {
+		s.shardRemovedListener(shardId)
+	}
+}
+
+func (s *shardsDirector) countShards(servingStatus proto.ServingStatus) int64 {
+	s.RLock()
+	defer s.RUnlock()
+
+	var count int64
+	for _, leader := range s.leaders {
+		if leader.Status() == servingStatus {
+			count++
+		}
+	}
+	for _, follower := range s.followers {
+		if follower.Status() == servingStatus {
+			count++
+		}
+	}
+	return count
+}
+
 func (s *shardsDirector) GetLeader(shardId int64) (LeaderController, error) {
 	s.RLock()
 	defer s.RUnlock()
@@ -122,6 +456,7 @@ func (s *shardsDirector) GetFollower(shardId int64) (FollowerController, error)
 func (s *shardsDirector) GetOrCreateLeader(namespace string, shardId int64) (LeaderController, error) {
 	s.Lock()
 	defer s.Unlock()
+	s.waitForRecovery(shardId)
 
 	if s.closed {
 		return nil, common.ErrorAlreadyClosed
@@ -152,12 +487,14 @@ func (s *shardsDirector) GetOrCreateLeader(namespace string, shardId int64) (Lea
 
 	s.leaders[shardId] = lc
 	s.leadersCounter.Inc()
+	s.reportShardHealth(namespace, shardId, lc.(statusNotifier))
 	return lc, nil
 }
 
 func (s *shardsDirector) GetOrCreateFollower(namespace string, shardId int64, term int64) (FollowerController, error) {
 	s.Lock()
 	defer s.Unlock()
+	s.waitForRecovery(shardId)
 
 	if s.closed {
 		return nil, common.ErrorAlreadyClosed
@@ -192,12 +529,14 @@ func (s *shardsDirector) GetOrCreateFollower(namespace string, shardId int64, te
 
 	s.followers[shardId] = fc
 	s.followersCounter.Inc()
+	s.reportShardHealth(namespace, shardId, fc.(statusNotifier))
 	return fc, nil
 }
 
 func (s *shardsDirector) DeleteShard(req *proto.DeleteShardRequest) (*proto.DeleteShardResponse, error) {
 	s.Lock()
 	defer s.Unlock()
+	s.waitForRecovery(req.Shard)
 
 	if leader, ok := s.leaders[req.Shard]; ok {
 		resp, err := leader.DeleteShard(req)
@@ -207,6 +546,7 @@ func (s *shardsDirector) DeleteShard(req *proto.DeleteShardRequest) (*proto.Dele
 
 		delete(s.leaders, req.Shard)
 		s.leadersCounter.Dec()
+		s.shardRemoved(req.Namespace, req.Shard)
 		return resp, nil
 	}
 
@@ -218,6 +558,7 @@ func (s *shardsDirector) DeleteShard(req *proto.DeleteShardRequest) (*proto.Dele
 
 		delete(s.followers, req.Shard)
 		s.followersCounter.Dec()
+		s.shardRemoved(req.Namespace, req.Shard)
 		return resp, nil
 	}
 
@@ -228,13 +569,139 @@ func (s *shardsDirector) DeleteShard(req *proto.DeleteShardRequest) (*proto.Dele
 	return fc.DeleteShard(req)
 }
 
+func (s *shardsDirector) AssignShard(req *proto.AssignShardRequest) (*proto.AssignShardResponse, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.waitForRecovery(req.Shard)
+
+	if s.closed {
+		return nil, common.ErrorAlreadyClosed
+	}
+
+	_, isLeader := s.leaders[req.Shard]
+	_, isFollower := s.followers[req.Shard]
+	if isLeader || isFollower {
+		return nil, common.ErrorShardAlreadyAssigned
+	}
+
+	fc, err := NewFollowerController(s.config, req.Namespace, req.Shard, s.walFactory, s.kvFactory)
+	if err != nil {
+		return nil, err
+	}
+
+	s.followers[req.Shard] = fc
+	s.followersCounter.Inc()
+	s.reportShardHealth(req.Namespace, req.Shard, fc.(statusNotifier))
+
+	s.log.Info(
+		"Assigned shard",
+		slog.String("namespace", req.Namespace),
+		slog.Int64("shard", req.Shard),
+	)
+	return &proto.AssignShardResponse{}, nil
+}
+
+func (s *shardsDirector) UnassignShard(req *proto.UnassignShardRequest) (*proto.UnassignShardResponse, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.waitForRecovery(req.Shard)
+
+	if s.closed {
+		return nil, common.ErrorAlreadyClosed
+	}
+
+	var controller shardController
+	if leader, ok := s.leaders[req.Shard]; ok {
+		controller = leader
+		delete(s.leaders, req.Shard)
+		s.leadersCounter.Dec()
+	} else if follower, ok := s.followers[req.Shard]; ok {
+		controller = follower
+		delete(s.followers, req.Shard)
+		s.followersCounter.Dec()
+	}
+
+	s.shardRemoved(req.Namespace, req.Shard)
+
+	if !req.DeleteData {
+		if controller != nil {
+			// Closing the controller also terminates the replication
+			// stream, after the entry being appended is done
+			if err := controller.Close(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if controller == nil {
+			// The shard data might still be on the disk
+			fc, err := NewFollowerController(s.config, req.Namespace, req.Shard, s.walFactory, s.kvFactory)
+			if err != nil {
+				return nil, err
+			}
+			controller = fc
+		}
+
+		if _, err := controller.DeleteShard(&proto.DeleteShardRequest{
+			Namespace: req.Namespace,
+			Shard:     req.Shard,
+			Term:      controller.Term(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	s.log.Info(
+		"Unassigned shard",
+		slog.String("namespace", req.Namespace),
+		slog.Int64("shard", req.Shard),
+		slog.Bool("delete-data", req.DeleteData),
+	)
+	return &proto.UnassignShardResponse{}, nil
+}
+
+func (s *shardsDirector) CompactShard(ctx context.Context, req *proto.CompactShardRequest) (*proto.CompactShardResponse, error) {
+	s.Lock()
+	s.waitForRecovery(req.Shard)
+
+	if s.closed {
+		s.Unlock()
+		return nil, common.ErrorAlreadyClosed
+	}
+
+	var compactor dbCompactor
+	if leader, ok := s.leaders[req.Shard]; ok {
+		compactor = leader.(dbCompactor)
+	} else if follower, ok := s.followers[req.Shard]; ok {
+		compactor = follower.(dbCompactor)
+	}
+	s.Unlock()
+
+	if compactor == nil {
+		return nil, status.Errorf(codes.NotFound, "oxia: node is not hosting shard %d", req.Shard)
+	}
+
+	reclaimed, err := s.compactions.compactShard(ctx, req.Shard, compactor)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.CompactShardResponse{ReclaimedBytes: reclaimed}, nil
+}
+
 func (s *shardsDirector) Close() error {
+	// Stop recovering shards, and wait for the controllers being created
+	s.cancel()
+	s.recoveryWg.Wait()
+
 	s.Lock()
 	defer s.Unlock()
 
 	s.closed = true
 	var err error
 
+	for _, g := range s.shardsGauges {
+		g.Unregister()
+	}
+
 	for _, leader := range s.leaders {
 		err = multierr.Append(err, leader.Close())
 	}
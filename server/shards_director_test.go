@@ -16,13 +16,20 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/status"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/proto"
 	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
 )
 
 func TestShardsDirector_DeleteShardLeader(t *testing.T) {
@@ -31,7 +38,7 @@ func TestShardsDirector_DeleteShardLeader(t *testing.T) {
 	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
 	walFactory := newTestWalFactory(t)
 
-	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient())
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), health.NewServer())
 
 	lc, _ := sd.GetOrCreateLeader(common.DefaultNamespace, shard)
 	_, _ = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
@@ -69,7 +76,7 @@ func TestShardsDirector_GetOrCreateFollower(t *testing.T) {
 	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
 	walFactory := newTestWalFactory(t)
 
-	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient())
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), health.NewServer())
 
 	lc, _ := sd.GetOrCreateLeader(common.DefaultNamespace, shard)
 	_, _ = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 2})
@@ -100,3 +107,324 @@ func TestShardsDirector_GetOrCreateFollower(t *testing.T) {
 	assert.NoError(t, lc.Close())
 	assert.NoError(t, walFactory.Close())
 }
+
+func TestShardsDirector_AssignUnassignShard(t *testing.T) {
+	var shard int64 = 1
+
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), health.NewServer())
+
+	var removed []int64
+	sd.setShardRemovedListener(func(shardId int64) {
+		removed = append(removed, shardId)
+	})
+
+	assignReq := &proto.AssignShardRequest{Namespace: common.DefaultNamespace, Shard: shard}
+	_, err = sd.AssignShard(assignReq)
+	assert.NoError(t, err)
+
+	// The same shard cannot be assigned twice
+	_, err = sd.AssignShard(assignReq)
+	assert.ErrorIs(t, err, common.ErrorShardAlreadyAssigned)
+
+	fc, err := sd.GetFollower(shard)
+	assert.NoError(t, err)
+	assert.Equal(t, proto.ServingStatus_NOT_MEMBER, fc.Status())
+
+	_, err = fc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+	assert.NoError(t, err)
+	_, err = fc.Truncate(&proto.TruncateRequest{
+		Shard:       shard,
+		Term:        1,
+		HeadEntryId: &proto.EntryId{Term: 1, Offset: wal.InvalidOffset},
+	})
+	assert.NoError(t, err)
+
+	stream := newMockServerReplicateStream()
+	replicateDone := make(chan error, 1)
+	go func() {
+		replicateDone <- fc.Replicate(stream)
+	}()
+
+	stream.AddRequest(createAddRequest(t, 1, 0, map[string]string{"a": "0"}, wal.InvalidOffset))
+	assert.EqualValues(t, 0, stream.GetResponse().Offset)
+	stream.AddRequest(createAddRequest(t, 1, 1, map[string]string{"b": "1"}, 0))
+	assert.EqualValues(t, 1, stream.GetResponse().Offset)
+
+	// Unassigning the shard closes the active replication stream
+	_, err = sd.UnassignShard(&proto.UnassignShardRequest{Namespace: common.DefaultNamespace, Shard: shard})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, <-replicateDone, context.Canceled)
+	assert.Equal(t, []int64{shard}, removed)
+
+	_, err = sd.GetFollower(shard)
+	assert.Equal(t, common.CodeNodeIsNotFollower, status.Code(err))
+
+	// When reassigned, the shard keeps the data that was replicated
+	_, err = sd.AssignShard(assignReq)
+	assert.NoError(t, err)
+
+	fc, err = sd.GetFollower(shard)
+	assert.NoError(t, err)
+	st, err := fc.GetStatus(&proto.GetStatusRequest{Shard: shard})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, st.Term)
+	assert.EqualValues(t, 1, st.HeadOffset)
+	assert.EqualValues(t, 0, st.CommitOffset)
+
+	// After the data is deleted, the shard is reassigned empty
+	_, err = sd.UnassignShard(&proto.UnassignShardRequest{Namespace: common.DefaultNamespace, Shard: shard, DeleteData: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{shard, shard}, removed)
+
+	_, err = sd.AssignShard(assignReq)
+	assert.NoError(t, err)
+
+	fc, err = sd.GetFollower(shard)
+	assert.NoError(t, err)
+	st, err = fc.GetStatus(&proto.GetStatusRequest{Shard: shard})
+	assert.NoError(t, err)
+	assert.EqualValues(t, wal.InvalidTerm, st.Term)
+	assert.EqualValues(t, wal.InvalidOffset, st.HeadOffset)
+
+	assert.NoError(t, sd.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func TestShardsDirector_MetricsPerShard(t *testing.T) {
+	namespace := "metrics-per-shard"
+
+	kvFactory, _ := kv.NewPebbleKVFactory(testKVOptions)
+	walFactory := newTestWalFactory(t)
+
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), health.NewServer())
+
+	// Drive a different number of writes through each shard
+	for shard, writes := range map[int64]int{1: 3, 2: 7} {
+		lc, err := sd.GetOrCreateLeader(namespace, shard)
+		assert.NoError(t, err)
+		_, err = lc.NewTerm(&proto.NewTermRequest{Namespace: namespace, Shard: shard, Term: shard})
+		assert.NoError(t, err)
+		_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+			Namespace:         namespace,
+			Shard:             shard,
+			Term:              shard,
+			ReplicationFactor: 1,
+		})
+		assert.NoError(t, err)
+
+		for i := 0; i < writes; i++ {
+			_, err = lc.Write(context.Background(), &proto.WriteRequest{
+				Shard: &shard,
+				Puts:  []*proto.PutRequest{{Key: "k", Value: []byte("v")}},
+			})
+			assert.NoError(t, err)
+		}
+	}
+
+	_, err := sd.GetOrCreateFollower(namespace, 3, 1)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, 2, sd.(*shardsDirector).countShards(proto.ServingStatus_LEADER))
+	assert.EqualValues(t, 1, sd.(*shardsDirector).countShards(proto.ServingStatus_NOT_MEMBER))
+	assert.EqualValues(t, 0, sd.(*shardsDirector).countShards(proto.ServingStatus_FOLLOWER))
+
+	commitOffsets := gatherShardGauges(t, "oxia_server_leader_commit_offset", namespace)
+	assert.Equal(t, map[string]float64{"1": 2, "2": 6}, commitOffsets)
+
+	terms := gatherShardGauges(t, "oxia_server_leader_term", namespace)
+	assert.Equal(t, map[string]float64{"1": 1, "2": 2}, terms)
+
+	assert.NoError(t, sd.Close())
+
+	// The series of the closed controllers are removed
+	assert.Empty(t, gatherShardGauges(t, "oxia_server_leader_commit_offset", namespace))
+	assert.Empty(t, gatherShardGauges(t, "oxia_server_leader_term", namespace))
+
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+func gatherShardGauges(t *testing.T, name string, namespace string) map[string]float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["oxia_namespace"] == namespace {
+				values[labels["shard"]] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+func TestShardsDirector_DiskUsage(t *testing.T) {
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), health.NewServer())
+
+	// The second shard receives 10x the data of the first one
+	value := make([]byte, 1024)
+	for shard, count := range map[int64]int{1: 100, 2: 1000} {
+		shard := shard
+		lc, err := sd.GetOrCreateLeader(common.DefaultNamespace, shard)
+		assert.NoError(t, err)
+		_, err = lc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+		assert.NoError(t, err)
+		_, err = lc.BecomeLeader(context.Background(), &proto.BecomeLeaderRequest{
+			Shard:             shard,
+			Term:              1,
+			ReplicationFactor: 1,
+		})
+		assert.NoError(t, err)
+
+		for i := 0; i < count; i++ {
+			_, err = lc.Write(context.Background(), &proto.WriteRequest{
+				Shard: &shard,
+				Puts:  []*proto.PutRequest{{Key: fmt.Sprintf("key-%d", i), Value: value}},
+			})
+			assert.NoError(t, err)
+		}
+	}
+
+	getDiskUsage := func(shard int64) *proto.DiskUsage {
+		lc, err := sd.GetLeader(shard)
+		assert.NoError(t, err)
+		res, err := lc.GetStatus(&proto.GetStatusRequest{Shard: shard})
+		assert.NoError(t, err)
+		return res.DiskUsage
+	}
+
+	// The usage is measured when the controllers are created, and then only
+	// at the next refresh
+	assert.EqualValues(t, getDiskUsage(1), getDiskUsage(2))
+
+	sd.(*shardsDirector).refreshDiskUsage()
+
+	usage1 := getDiskUsage(1)
+	usage2 := getDiskUsage(2)
+	assert.Greater(t, usage1.WalBytes, int64(100*1024))
+	assert.Greater(t, usage2.WalBytes, int64(1000*1024))
+	assert.Greater(t, usage2.WalBytes, 5*usage1.WalBytes)
+
+	assert.NoError(t, sd.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
+
+// Slows down the opening of the wal of the shards, or fails it.
+type slowWalFactory struct {
+	wal.Factory
+	delays   map[int64]time.Duration
+	failures map[int64]error
+}
+
+func (f *slowWalFactory) NewWal(namespace string, shard int64, provider wal.CommitOffsetProvider) (wal.Wal, error) {
+	time.Sleep(f.delays[shard])
+	if err := f.failures[shard]; err != nil {
+		return nil, err
+	}
+	return f.Factory.NewWal(namespace, shard, provider)
+}
+
+func TestShardsDirector_RecoverShards(t *testing.T) {
+	const shards = 8
+	const delay = 100 * time.Millisecond
+
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := newTestWalFactory(t)
+
+	sd := NewShardsDirector(Config{}, walFactory, kvFactory, newMockRpcClient(), health.NewServer())
+	for shard := int64(0); shard < shards; shard++ {
+		fc, err := sd.GetOrCreateFollower(common.DefaultNamespace, shard, wal.InvalidTerm)
+		assert.NoError(t, err)
+		_, err = fc.NewTerm(&proto.NewTermRequest{Shard: shard, Term: 1})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, sd.Close())
+
+	recoverShards := func(parallelism int, slowWalFactory *slowWalFactory) (ShardsDirector, time.Time) {
+		slowWalFactory.Factory = walFactory
+		return NewShardsDirector(Config{ShardRecoveryParallelism: parallelism}, slowWalFactory, kvFactory,
+			newMockRpcClient(), health.NewServer()), time.Now()
+	}
+
+	isAvailable := func(sd ShardsDirector, shard int64) bool {
+		fc, err := sd.GetFollower(shard)
+		return err == nil && fc.Status() == proto.ServingStatus_FENCED && fc.Term() == 1
+	}
+
+	allAvailable := func(sd ShardsDirector, shards ...int64) func() bool {
+		return func() bool {
+			for _, shard := range shards {
+				if !isAvailable(sd, shard) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	allShards := []int64{0, 1, 2, 3, 4, 5, 6, 7}
+	delays := map[int64]time.Duration{}
+	for _, shard := range allShards {
+		delays[shard] = delay
+	}
+
+	// Recovering the shards one at a time
+	sd, start := recoverShards(1, &slowWalFactory{delays: delays})
+	assert.Eventually(t, allAvailable(sd, allShards...), 10*time.Second, 10*time.Millisecond)
+	sequential := time.Since(start)
+	assert.GreaterOrEqual(t, sequential, shards*delay)
+	assert.NoError(t, sd.Close())
+
+	// Recovering all the shards at once
+	sd, start = recoverShards(shards, &slowWalFactory{delays: delays})
+	assert.Eventually(t, allAvailable(sd, allShards...), 10*time.Second, 10*time.Millisecond)
+	parallel := time.Since(start)
+	assert.Less(t, parallel, sequential/2)
+	assert.NoError(t, sd.Close())
+
+	// A slow shard doesn't hold back the others, and a failing one doesn't
+	// prevent them from being recovered
+	sd, _ = recoverShards(2, &slowWalFactory{
+		delays:   map[int64]time.Duration{0: 2 * time.Second},
+		failures: map[int64]error{1: errors.New("failed to open wal")},
+	})
+	assert.Eventually(t, allAvailable(sd, 2, 3, 4, 5, 6, 7), 10*time.Second, 10*time.Millisecond)
+	assert.False(t, isAvailable(sd, 0))
+	assert.False(t, isAvailable(sd, 1))
+
+	// The requests for a shard wait for its recovery, instead of opening it
+	// a second time
+	fc, err := sd.GetOrCreateFollower(common.DefaultNamespace, 0, 1)
+	assert.NoError(t, err)
+	assert.True(t, isAvailable(sd, 0))
+	recovered, err := sd.GetFollower(0)
+	assert.NoError(t, err)
+	assert.Same(t, recovered, fc)
+
+	assert.NoError(t, sd.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
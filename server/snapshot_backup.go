@@ -0,0 +1,125 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"path/filepath"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+)
+
+// backupSnapshot is a snapshot of the database of a shard, taken to be
+// streamed to a backup rather than to a follower.
+type backupSnapshot struct {
+	snapshot kv.Snapshot
+	term     int64
+	entryId  *proto.EntryId
+}
+
+// Take the snapshot, along with the last entry it includes. It must be called
+// with the mutex of the controller held, so that the database is not replaced
+// in the meantime, while the snapshot can be sent without it.
+func newBackupSnapshot(db kv.DB, term int64) (*backupSnapshot, error) {
+	if db == nil {
+		return nil, common.ErrorInvalidStatus
+	}
+
+	entryId, err := db.ReadCommitEntryId()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := db.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return &backupSnapshot{
+		snapshot: snapshot,
+		term:     term,
+		entryId:  entryId,
+	}, nil
+}
+
+// Send the chunks of the snapshot, with the same checksums as the snapshots
+// sent to the followers. The content of the table files that the receiver
+// already has is omitted, since a table file never changes once it's written.
+func (s *backupSnapshot) send(hashRange *proto.Int32HashRange, skipFiles []string, send func(*proto.SnapshotChunk) error) error {
+	defer s.snapshot.Close()
+
+	skip := common.NewSet[string]()
+	for _, name := range skipFiles {
+		if filepath.Ext(name) == ".sst" {
+			skip.Add(name)
+		}
+	}
+
+	encoder := newSnapshotEncoder(s.term, s.entryId)
+	first := true
+	for s.snapshot.Valid() {
+		chunk, err := s.snapshot.Chunk()
+		if err != nil {
+			return err
+		}
+
+		omitted := skip.Contains(chunk.Name())
+		if omitted {
+			chunk = omittedSnapshotChunk{chunk}
+		}
+
+		res := encoder.encode(chunk, !s.snapshot.Next())
+		res.Omitted = omitted
+		if first && hashRange != nil {
+			res.Int32HashRange = hashRange
+		}
+		first = false
+
+		if err := send(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type omittedSnapshotChunk struct {
+	kv.SnapshotChunk
+}
+
+func (omittedSnapshotChunk) Content() []byte {
+	return nil
+}
+
+// snapshotRangeCheck refuses the snapshots restored from the backup of a
+// shard with a different hash range. The snapshots sent by the leaders don't
+// carry the hash range and are not checked.
+type snapshotRangeCheck struct {
+	proto.OxiaLogReplication_SendSnapshotServer
+
+	hashRange *proto.Int32HashRange
+}
+
+func (s *snapshotRangeCheck) Recv() (*proto.SnapshotChunk, error) {
+	chunk, err := s.OxiaLogReplication_SendSnapshotServer.Recv()
+	if err != nil || chunk == nil || chunk.Int32HashRange == nil {
+		return chunk, err
+	}
+
+	if s.hashRange == nil || !chunk.Int32HashRange.EqualVT(s.hashRange) {
+		return nil, common.ErrorSnapshotMismatch
+	}
+	return chunk, nil
+}
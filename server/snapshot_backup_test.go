@@ -0,0 +1,140 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+)
+
+func TestBackupSnapshot(t *testing.T) {
+	factory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	db, err := kv.NewDB(common.DefaultNamespace, 1, factory, 0, common.SystemClock)
+	assert.NoError(t, err)
+
+	for i := int64(0); i < 5; i++ {
+		_, err = db.ProcessWrite(&proto.WriteRequest{
+			Puts: []*proto.PutRequest{{Key: fmt.Sprintf("key-%d", i), Value: []byte("value")}},
+		}, 2, i, 1000, kv.NoOpCallback)
+		assert.NoError(t, err)
+	}
+
+	send := func(skipFiles []string) []*proto.SnapshotChunk {
+		snapshot, err := newBackupSnapshot(db, 3)
+		assert.NoError(t, err)
+
+		var chunks []*proto.SnapshotChunk
+		assert.NoError(t, snapshot.send(&proto.Int32HashRange{MinHashInclusive: 0, MaxHashInclusive: 100}, skipFiles,
+			func(chunk *proto.SnapshotChunk) error {
+				chunks = append(chunks, chunk)
+				return nil
+			}))
+		return chunks
+	}
+
+	chunks := send(nil)
+	assert.EqualValues(t, 3, chunks[0].Term)
+	assert.EqualValues(t, 4, chunks[0].EntryId.Offset)
+	assert.EqualValues(t, 100, chunks[0].Int32HashRange.MaxHashInclusive)
+	assert.NotNil(t, chunks[len(chunks)-1].Digest)
+
+	// The snapshot is the same as the ones sent to the followers
+	verifier := newSnapshotVerifier(-1, slog.Default())
+	var files []string
+	for _, chunk := range chunks {
+		assert.False(t, chunk.Omitted)
+		assert.NoError(t, verifier.verify(chunk))
+		files = append(files, chunk.Name)
+	}
+	assert.NoError(t, verifier.verifyComplete())
+
+	// Only the content of the table files is omitted
+	chunks = send(files)
+	omitted := 0
+	for _, chunk := range chunks {
+		if filepath.Ext(chunk.Name) == ".sst" {
+			assert.True(t, chunk.Omitted)
+			assert.Empty(t, chunk.Content)
+			omitted++
+		} else {
+			assert.False(t, chunk.Omitted)
+		}
+	}
+	assert.Positive(t, omitted)
+
+	// A follower never installs a snapshot without all its content
+	verifier = newSnapshotVerifier(-1, slog.Default())
+	var err2 error
+	for _, chunk := range chunks {
+		if err2 = verifier.verify(chunk); err2 != nil {
+			break
+		}
+	}
+	assert.ErrorIs(t, err2, common.ErrorSnapshotCorrupted)
+
+	assert.NoError(t, db.Close())
+	assert.NoError(t, factory.Close())
+}
+
+type testSendSnapshotServer struct {
+	proto.OxiaLogReplication_SendSnapshotServer
+
+	chunks []*proto.SnapshotChunk
+}
+
+func (s *testSendSnapshotServer) Recv() (*proto.SnapshotChunk, error) {
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func TestSnapshotRangeCheck(t *testing.T) {
+	hashRange := &proto.Int32HashRange{MinHashInclusive: 0, MaxHashInclusive: 100}
+
+	for _, test := range []struct {
+		name        string
+		expected    *proto.Int32HashRange
+		chunk       *proto.SnapshotChunk
+		expectedErr error
+	}{
+		{"from a leader", hashRange, &proto.SnapshotChunk{Name: "000001.sst"}, nil},
+		{"same range", hashRange, &proto.SnapshotChunk{Int32HashRange: hashRange}, nil},
+		{"different range", hashRange, &proto.SnapshotChunk{
+			Int32HashRange: &proto.Int32HashRange{MinHashInclusive: 101, MaxHashInclusive: 200},
+		}, common.ErrorSnapshotMismatch},
+		{"unknown shard", nil, &proto.SnapshotChunk{Int32HashRange: hashRange}, common.ErrorSnapshotMismatch},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stream := &snapshotRangeCheck{&testSendSnapshotServer{chunks: []*proto.SnapshotChunk{test.chunk}}, test.expected}
+			chunk, err := stream.Recv()
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				assert.Nil(t, chunk)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.chunk, chunk)
+			}
+		})
+	}
+}
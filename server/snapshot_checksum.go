@@ -0,0 +1,174 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"log/slog"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+)
+
+var snapshotCrcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func newSnapshotDigest() hash.Hash {
+	return sha256.New()
+}
+
+func addToSnapshotDigest(digest hash.Hash, name string, content []byte) {
+	// Writing into a hash never fails
+	_, _ = digest.Write([]byte(name))
+	_, _ = digest.Write(content)
+}
+
+// snapshotEncoder converts the chunks of a database snapshot into the
+// messages of the snapshot stream, adding the checksums that let the
+// follower verify the transfer.
+type snapshotEncoder struct {
+	term    int64
+	entryId *proto.EntryId
+	digest  hash.Hash
+	count   int
+}
+
+func newSnapshotEncoder(term int64, entryId *proto.EntryId) *snapshotEncoder {
+	return &snapshotEncoder{
+		term:    term,
+		entryId: entryId,
+		digest:  newSnapshotDigest(),
+	}
+}
+
+// Encode the next chunk of the snapshot. The digest is attached to the chunk
+// when it's the last one.
+func (e *snapshotEncoder) encode(chunk kv.SnapshotChunk, last bool) *proto.SnapshotChunk {
+	content := chunk.Content()
+	addToSnapshotDigest(e.digest, chunk.Name(), content)
+
+	res := &proto.SnapshotChunk{
+		Term:       e.term,
+		Name:       chunk.Name(),
+		ChunkIndex: chunk.Index(),
+		ChunkCount: chunk.TotalCount(),
+		Content:    content,
+		Crc:        crc32.Checksum(content, snapshotCrcTable),
+	}
+
+	if e.count == 0 {
+		res.EntryId = e.entryId
+	}
+	if last {
+		res.Digest = e.digest.Sum(nil)
+	}
+
+	e.count++
+	return res
+}
+
+// snapshotVerifier checks the chunks received from the leader, before they
+// are written into the new database.
+type snapshotVerifier struct {
+	// The entries applied by the follower, that the snapshot must include
+	appliedOffset int64
+
+	digest   hash.Hash
+	count    int
+	entryId  *proto.EntryId
+	complete bool
+	log      *slog.Logger
+}
+
+func newSnapshotVerifier(appliedOffset int64, log *slog.Logger) *snapshotVerifier {
+	return &snapshotVerifier{
+		appliedOffset: appliedOffset,
+		digest:        newSnapshotDigest(),
+		log:           log,
+	}
+}
+
+func (v *snapshotVerifier) verify(chunk *proto.SnapshotChunk) error {
+	if v.complete {
+		v.log.Warn("Received a chunk after the end of the snapshot")
+		return common.ErrorSnapshotCorrupted
+	}
+
+	if chunk.Omitted {
+		v.log.Warn(
+			"Received a snapshot chunk without its content",
+			slog.String("chunk-name", chunk.Name),
+		)
+		return common.ErrorSnapshotCorrupted
+	}
+
+	if crc := crc32.Checksum(chunk.Content, snapshotCrcTable); crc != chunk.Crc {
+		v.log.Warn(
+			"Snapshot chunk failed the checksum verification",
+			slog.String("chunk-name", chunk.Name),
+			slog.Int("chunk-index", int(chunk.ChunkIndex)),
+			slog.Any("expected-crc", chunk.Crc),
+			slog.Any("actual-crc", crc),
+		)
+		return common.ErrorSnapshotCorrupted
+	}
+
+	if v.count == 0 {
+		if chunk.EntryId == nil {
+			v.log.Warn("The snapshot is missing the id of its last entry")
+			return common.ErrorSnapshotCorrupted
+		}
+
+		if chunk.EntryId.Offset < v.appliedOffset {
+			v.log.Warn(
+				"Refusing a snapshot that is behind the applied entries",
+				slog.Any("snapshot-entry-id", chunk.EntryId),
+				slog.Int64("applied-offset", v.appliedOffset),
+			)
+			return common.ErrorSnapshotOutdated
+		}
+		v.entryId = chunk.EntryId
+	}
+	v.count++
+
+	addToSnapshotDigest(v.digest, chunk.Name, chunk.Content)
+
+	if chunk.Digest != nil {
+		if digest := v.digest.Sum(nil); !bytes.Equal(digest, chunk.Digest) {
+			v.log.Warn(
+				"Snapshot failed the digest verification",
+				slog.Int("chunks-count", v.count),
+			)
+			return common.ErrorSnapshotCorrupted
+		}
+		v.complete = true
+	}
+	return nil
+}
+
+// Check that the whole snapshot was received, once the stream is over.
+func (v *snapshotVerifier) verifyComplete() error {
+	if !v.complete {
+		v.log.Warn(
+			"The snapshot stream ended before the digest",
+			slog.Int("chunks-count", v.count),
+		)
+		return common.ErrorSnapshotCorrupted
+	}
+	return nil
+}
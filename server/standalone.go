@@ -17,11 +17,15 @@ package server
 import (
 	"context"
 	"log/slog"
+	"os"
 	"path/filepath"
 
+	"github.com/pkg/errors"
+
 	"github.com/streamnative/oxia/server/auth"
 
 	"go.uber.org/multierr"
+	"google.golang.org/grpc/health"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/common/container"
@@ -34,15 +38,43 @@ import (
 type StandaloneConfig struct {
 	Config
 
+	// NumShards is the number of shards hosted by the process. 0 means 1
 	NumShards uint32
+
+	// ReplicationFactor can only be 1, since there are no other servers to
+	// replicate the data to. 0 means 1
+	ReplicationFactor uint32
+
+	// InMemory keeps the database in memory and the write-ahead-log in a
+	// temporary directory. All the data is lost when the standalone is closed
+	InMemory bool
+}
+
+func (c *StandaloneConfig) validate() error {
+	if c.ReplicationFactor > 1 {
+		return errors.Errorf("replication factor %d requires a coordinator, standalone only supports 1", c.ReplicationFactor)
+	}
+	if err := c.DiskWatermarks.Validate(); err != nil {
+		return err
+	}
+	if err := c.DbCompaction.Validate(); err != nil {
+		return err
+	}
+	return c.WriteSizeLimits.Validate()
 }
 
 type Standalone struct {
 	rpc                       *publicRpcServer
+	internalRpc               *internalRpcServer
 	kvFactory                 kv.Factory
 	walFactory                wal.Factory
 	shardsDirector            ShardsDirector
 	shardAssignmentDispatcher ShardAssignmentsDispatcher
+	healthServer              *health.Server
+	diskWatermarks            *diskWatermarkMonitor
+
+	// The directories created for the data, removed on close
+	tempDirs []string
 
 	metrics *metrics.PrometheusMetrics
 }
@@ -66,28 +98,56 @@ func NewStandalone(config StandaloneConfig) (*Standalone, error) {
 		slog.Any("config", config),
 	)
 
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	if config.NumShards == 0 {
+		config.NumShards = 1
+	}
+
 	s := &Standalone{}
 
-	kvOptions := kv.FactoryOptions{DataDir: config.DataDir}
+	var err error
+	if config.InMemory || config.WalDir == "" {
+		if config.WalDir, err = s.createTempDir("oxia-standalone-wal"); err != nil {
+			return nil, err
+		}
+	}
+	if !config.InMemory && config.DataDir == "" {
+		if config.DataDir, err = s.createTempDir("oxia-standalone-db"); err != nil {
+			return nil, err
+		}
+	}
+
+	kvOptions := config.kvFactoryOptions()
+	kvOptions.InMemory = config.InMemory
 	s.walFactory = wal.NewWalFactory(&wal.FactoryOptions{
-		BaseWalDir:  config.WalDir,
-		Retention:   config.WalRetentionTime,
-		SegmentSize: wal.DefaultFactoryOptions.SegmentSize,
-		SyncData:    config.WalSyncData,
+		BaseWalDir:   config.WalDir,
+		Retention:    config.WalRetentionTime,
+		SegmentSize:  wal.DefaultFactoryOptions.SegmentSize,
+		SyncPolicy:   config.WalSyncPolicy,
+		SyncInterval: config.WalSyncInterval,
 	})
-	var err error
-	if s.kvFactory, err = kv.NewPebbleKVFactory(&kvOptions); err != nil {
+	if s.kvFactory, err = kv.NewPebbleKVFactory(kvOptions); err != nil {
 		return nil, err
 	}
 
-	s.shardsDirector = NewShardsDirector(config.Config, s.walFactory, s.kvFactory, newNoOpReplicationRpcProvider())
+	s.healthServer = health.NewServer()
+	s.shardsDirector = NewShardsDirector(config.Config, s.walFactory, s.kvFactory, newNoOpReplicationRpcProvider(), s.healthServer)
 
 	if err := s.initializeShards(config.NumShards); err != nil {
 		return nil, err
 	}
 
-	s.rpc, err = newPublicRpcServer(container.Default, config.PublicServiceAddr, s.shardsDirector,
-		nil, config.ServerTLS, &auth.Disabled)
+	if s.diskWatermarks, err = newDiskWatermarkMonitor(config.DiskWatermarks, []string{config.WalDir, config.DataDir},
+		s.healthServer, fsDiskStats); err != nil {
+		return nil, err
+	}
+
+	s.rpc, err = newPublicRpcServer(container.NewGrpcProvider(config.GrpcInterceptors, config.Grpc), config.PublicServiceAddr, s.shardsDirector,
+		nil, s.healthServer, NewWriteRateLimiter(config.WriteRateLimit), config.WriteSizeLimits, s.diskWatermarks,
+		config.ServerTLS, &auth.Disabled)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +156,16 @@ func NewStandalone(config StandaloneConfig) (*Standalone, error) {
 
 	s.rpc.assignmentDispatcher = s.shardAssignmentDispatcher
 
+	// The internal service is only used to take the backups of the shards
+	if config.InternalServiceAddr != "" {
+		s.internalRpc, err = newInternalRpcServer(container.NewGrpcProvider(config.GrpcInterceptors, config.Grpc), config.InternalServiceAddr,
+			s.shardsDirector, s.shardAssignmentDispatcher, s.healthServer, newMaintenanceMode(false, s.healthServer),
+			s.diskWatermarks, config.Locality, config.InternalServerTLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if config.MetricsServiceAddr != "" {
 		s.metrics, err = metrics.Start(config.MetricsServiceAddr)
 	}
@@ -106,6 +176,15 @@ func NewStandalone(config StandaloneConfig) (*Standalone, error) {
 	return s, nil
 }
 
+func (s *Standalone) createTempDir(pattern string) (string, error) {
+	dir, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	s.tempDirs = append(s.tempDirs, dir)
+	return dir, nil
+}
+
 func (s *Standalone) initializeShards(numShards uint32) error {
 	var err error
 	for i := int64(0); i < int64(numShards); i++ {
@@ -140,19 +219,42 @@ func (s *Standalone) RpcPort() int {
 	return s.rpc.Port()
 }
 
+// InternalPort is the port of the internal service, or 0 if it's disabled.
+func (s *Standalone) InternalPort() int {
+	if s.internalRpc == nil {
+		return 0
+	}
+	return s.internalRpc.grpcServer.Port()
+}
+
+// Close stops serving the requests and closes the shards, flushing the
+// write-ahead-log to disk.
 func (s *Standalone) Close() error {
+	s.healthServer.Shutdown()
+
 	var err error
-	if s.metrics != nil {
-		err = s.metrics.Close()
+	if s.internalRpc != nil {
+		err = s.internalRpc.Close()
 	}
 
-	return multierr.Combine(
+	err = multierr.Combine(
 		err,
-		s.shardsDirector.Close(),
 		s.shardAssignmentDispatcher.Close(),
+		s.shardsDirector.Close(),
 		s.rpc.Close(),
+		s.diskWatermarks.Close(),
 		s.kvFactory.Close(),
+		s.walFactory.Close(),
 	)
+
+	if s.metrics != nil {
+		err = multierr.Append(err, s.metrics.Close())
+	}
+
+	for _, dir := range s.tempDirs {
+		err = multierr.Append(err, os.RemoveAll(dir))
+	}
+	return err
 }
 
 type noOpReplicationRpcProvider struct {
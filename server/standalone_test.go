@@ -0,0 +1,140 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+type standaloneTestClient struct {
+	cnx    *grpc.ClientConn
+	client proto.OxiaClientClient
+	ctx    context.Context
+}
+
+func newStandaloneTestClient(t *testing.T, standalone *Standalone) *standaloneTestClient {
+	t.Helper()
+
+	cnx, err := grpc.NewClient(fmt.Sprintf("localhost:%d", standalone.RpcPort()),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	return &standaloneTestClient{
+		cnx:    cnx,
+		client: proto.NewOxiaClientClient(cnx),
+		ctx:    metadata.AppendToOutgoingContext(context.Background(), common.MetadataNamespace, common.DefaultNamespace),
+	}
+}
+
+func (c *standaloneTestClient) put(t *testing.T, shard int64, key string, value string) {
+	t.Helper()
+
+	res, err := c.client.Write(c.ctx, &proto.WriteRequest{
+		Shard: &shard,
+		Puts:  []*proto.PutRequest{{Key: key, Value: []byte(value)}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, proto.Status_OK, res.Puts[0].Status)
+}
+
+func (c *standaloneTestClient) get(t *testing.T, shard int64, key string) *proto.GetResponse {
+	t.Helper()
+
+	stream, err := c.client.Read(c.ctx, &proto.ReadRequest{
+		Shard: &shard,
+		Gets:  []*proto.GetRequest{{Key: key, IncludeValue: true}},
+	})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	return res.Gets[0]
+}
+
+func TestStandalone_Restart(t *testing.T) {
+	config := NewTestConfig(t.TempDir())
+	config.NumShards = 2
+
+	standalone, err := NewStandalone(config)
+	require.NoError(t, err)
+	client := newStandaloneTestClient(t, standalone)
+	client.put(t, 0, "a", "0")
+	client.put(t, 1, "b", "1")
+	assert.NoError(t, client.cnx.Close())
+	assert.NoError(t, standalone.Close())
+
+	// The data is still there after a clean shutdown
+	standalone, err = NewStandalone(config)
+	require.NoError(t, err)
+	client = newStandaloneTestClient(t, standalone)
+
+	res := client.get(t, 0, "a")
+	assert.Equal(t, proto.Status_OK, res.Status)
+	assert.Equal(t, []byte("0"), res.Value)
+	res = client.get(t, 1, "b")
+	assert.Equal(t, proto.Status_OK, res.Status)
+	assert.Equal(t, []byte("1"), res.Value)
+
+	assert.NoError(t, client.cnx.Close())
+	assert.NoError(t, standalone.Close())
+}
+
+func TestStandalone_InMemory(t *testing.T) {
+	config := NewTestConfig("")
+	config.DataDir = ""
+	config.WalDir = ""
+	config.NumShards = 0
+	config.InMemory = true
+
+	standalone, err := NewStandalone(config)
+	require.NoError(t, err)
+	client := newStandaloneTestClient(t, standalone)
+
+	client.put(t, 0, "a", "0")
+	res := client.get(t, 0, "a")
+	assert.Equal(t, proto.Status_OK, res.Status)
+	assert.Equal(t, []byte("0"), res.Value)
+
+	tempDirs := standalone.tempDirs
+	assert.Len(t, tempDirs, 1)
+
+	assert.NoError(t, client.cnx.Close())
+	assert.NoError(t, standalone.Close())
+
+	// The temporary directories are removed on close
+	for _, dir := range tempDirs {
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestStandalone_RejectReplicationFactor(t *testing.T) {
+	config := NewTestConfig(t.TempDir())
+	config.ReplicationFactor = 3
+
+	_, err := NewStandalone(config)
+	assert.Error(t, err)
+}
@@ -37,3 +37,22 @@ func (bs *BitSet) Set(idx int) {
 	}
 	bs.bits |= 1 << idx
 }
+
+func (bs *BitSet) Clear(idx int) {
+	if idx < 0 || idx >= MaxBitSetSize {
+		panic(fmt.Sprintf("invalid index: %d", idx))
+	}
+	bs.bits &^= 1 << idx
+}
+
+func (bs *BitSet) IsSet(idx int) bool {
+	if idx < 0 || idx >= MaxBitSetSize {
+		panic(fmt.Sprintf("invalid index: %d", idx))
+	}
+	return bs.bits&(1<<idx) != 0
+}
+
+// Intersect returns a new BitSet with the bits that are set in both sets.
+func (bs *BitSet) Intersect(other BitSet) BitSet {
+	return BitSet{bits: bs.bits & other.bits}
+}
@@ -36,6 +36,33 @@ func TestBitSet(t *testing.T) {
 
 	bs.Set(2)
 	assert.Equal(t, 3, bs.Count())
+
+	assert.True(t, bs.IsSet(1))
+	bs.Clear(1)
+	assert.False(t, bs.IsSet(1))
+	assert.Equal(t, 2, bs.Count())
+
+	bs.Clear(1)
+	assert.Equal(t, 2, bs.Count())
+}
+
+func TestBitSetIntersect(t *testing.T) {
+	a := BitSet{}
+	a.Set(0)
+	a.Set(1)
+	a.Set(3)
+
+	b := BitSet{}
+	b.Set(1)
+	b.Set(2)
+	b.Set(3)
+
+	i := a.Intersect(b)
+	assert.Equal(t, 2, i.Count())
+	assert.False(t, i.IsSet(0))
+	assert.True(t, i.IsSet(1))
+	assert.False(t, i.IsSet(2))
+	assert.True(t, i.IsSet(3))
 }
 
 func TestBitSetPanic(t *testing.T) {
@@ -52,4 +79,12 @@ func TestBitSetPanic(t *testing.T) {
 	assert.Panics(t, func() {
 		bs.Set(20)
 	})
+
+	assert.Panics(t, func() {
+		bs.Clear(16)
+	})
+
+	assert.Panics(t, func() {
+		bs.IsSet(-1)
+	})
 }
@@ -29,23 +29,75 @@ var (
 	ErrOffsetOutOfBounds = errors.New("oxia: offset out of bounds")
 	ErrReaderClosed      = errors.New("oxia: reader already closed")
 	ErrInvalidNextOffset = errors.New("oxia: invalid next offset in wal")
+	ErrWalCorrupted      = errors.New("oxia: wal is corrupted")
+	ErrUnsupportedFormat = errors.New("oxia: unsupported wal segment format")
+	ErrEmptyWal          = errors.New("oxia: wal is empty")
+	ErrEntryTrimmed      = errors.New("oxia: entry was trimmed from the wal")
+	ErrReaderTruncated   = errors.New("oxia: wal was truncated before the position of the reader")
 
 	InvalidTerm   int64 = -1
 	InvalidOffset int64 = -1
 )
 
+// SyncPolicy defines when the data appended to the wal is synced on disk.
+// The zero value syncs the data before acknowledging the entries.
+type SyncPolicy int
+
+const (
+	// SyncPolicyAlways syncs the data before acknowledging the entries
+	SyncPolicyAlways SyncPolicy = iota
+
+	// SyncPolicyInterval acknowledges the entries right away and syncs the
+	// data in background. The entries acknowledged after the last sync can
+	// be lost if the node crashes
+	SyncPolicyInterval
+
+	// SyncPolicyNever leaves it to the OS to write back the data. Any
+	// acknowledged entry can be lost if the node crashes
+	SyncPolicyNever
+)
+
+var syncPolicyNames = map[SyncPolicy]string{
+	SyncPolicyAlways:   "always",
+	SyncPolicyInterval: "interval",
+	SyncPolicyNever:    "never",
+}
+
+func (p *SyncPolicy) String() string {
+	return syncPolicyNames[*p]
+}
+
+func (p *SyncPolicy) Set(s string) error {
+	for policy, name := range syncPolicyNames {
+		if name == s {
+			*p = policy
+			return nil
+		}
+	}
+	return errors.Errorf("invalid wal sync policy %q: must be one of always, interval or never", s)
+}
+
+func (*SyncPolicy) Type() string {
+	return "sync-policy"
+}
+
 type FactoryOptions struct {
 	BaseWalDir  string
 	Retention   time.Duration
 	SegmentSize int32
-	SyncData    bool
+	SyncPolicy  SyncPolicy
+
+	// SyncInterval is the interval of the background sync, with the
+	// SyncPolicyInterval policy
+	SyncInterval time.Duration
 }
 
 var DefaultFactoryOptions = &FactoryOptions{
-	BaseWalDir:  "data/wal",
-	Retention:   1 * time.Hour,
-	SegmentSize: 64 * 1024 * 1024,
-	SyncData:    true,
+	BaseWalDir:   "data/wal",
+	Retention:    1 * time.Hour,
+	SegmentSize:  64 * 1024 * 1024,
+	SyncPolicy:   SyncPolicyAlways,
+	SyncInterval: 1 * time.Second,
 }
 
 type Factory interface {
@@ -54,13 +106,21 @@ type Factory interface {
 }
 
 // Reader reads the Wal sequentially. It is not synchronized itself.
+//
+// A forward reader can be advanced incrementally while entries are
+// appended: once it reaches the last synced entry, HasNext returns false
+// until more entries are synced. If the entry it has to read next is
+// trimmed, ReadNext returns [ErrEntryTrimmed]. If the log is truncated
+// before its position, every following ReadNext returns
+// [ErrReaderTruncated] and the reader has to be recreated.
 type Reader interface {
 	io.Closer
 	// ReadNext returns the next entry in the log according to the Reader's direction.
-	// If a forward/reverse WalReader has passed the end/beginning of the log, it returns [ErrorEntryNotFound].
+	// If a forward/reverse WalReader has passed the end/beginning of the log, it returns [ErrEntryNotFound].
 	// To avoid this error, use HasNext.
 	ReadNext() (*proto.LogEntry, error)
-	// HasNext returns true if there is an entry to read.
+	// HasNext returns true if there is an entry to read, or if ReadNext is
+	// going to report that the reader was invalidated.
 	HasNext() bool
 }
 
@@ -86,6 +146,7 @@ type Wal interface {
 	TruncateLog(lastSafeEntry int64) (int64, error)
 
 	// NewReader returns a new WalReader to traverse the log from the entry after `after` towards the log end
+	// Return ErrEntryTrimmed if the entry after `after` was already trimmed
 	NewReader(after int64) (Reader, error)
 	// NewReverseReader returns a new WalReader to traverse the log from the last entry towards the beginning
 	NewReverseReader() (Reader, error)
@@ -98,6 +159,17 @@ type Wal interface {
 	// Return InvalidOffset if the WAL is empty
 	FirstOffset() int64
 
+	// LastEntry Return the id of the last entry committed to the WAL
+	// Return an id with InvalidTerm and InvalidOffset, and ErrEmptyWal if the WAL is empty
+	LastEntry() (*proto.EntryId, error)
+
+	// FirstEntry Return the id of the first valid entry that is present in the WAL
+	// Return an id with InvalidTerm and InvalidOffset, and ErrEmptyWal if the WAL is empty
+	FirstEntry() (*proto.EntryId, error)
+
+	// DiskUsage returns the size in bytes of all the segment files
+	DiskUsage() (int64, error)
+
 	// Clear removes all the entries in the WAL
 	Clear() error
 
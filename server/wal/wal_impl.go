@@ -17,6 +17,8 @@ package wal
 import (
 	"context"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -26,7 +28,6 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 	"golang.org/x/exp/slices"
-	pb "google.golang.org/protobuf/proto"
 
 	"github.com/streamnative/oxia/common"
 	"github.com/streamnative/oxia/common/metrics"
@@ -52,14 +53,19 @@ func (*walFactory) Close() error {
 	return nil
 }
 
+// openReadWriteSegment opens the segment the wal is appending to. It's
+// replaced in the tests to inject faults.
+var openReadWriteSegment = newReadWriteSegment
+
 type wal struct {
 	sync.RWMutex
 	walPath     string
+	lock        *os.File
 	namespace   string
 	shard       int64
 	firstOffset atomic.Int64
 	segmentSize uint32
-	syncData    bool
+	syncPolicy  SyncPolicy
 
 	currentSegment   ReadWriteSegment
 	readOnlySegments ReadOnlySegmentsGroup
@@ -74,7 +80,12 @@ type wal struct {
 	cancel       context.CancelFunc
 	syncRequests chan func(error)
 
-	trimmer Trimmer
+	trimmer   Trimmer
+	readCache *readCache
+
+	// The open forward readers, that get invalidated when the log is
+	// truncated before their position
+	readers map[*forwardReader]struct{}
 
 	appendLatency metrics.LatencyHistogram
 	appendBytes   metrics.Counter
@@ -83,6 +94,9 @@ type wal struct {
 	trimOps       metrics.Counter
 	readErrors    metrics.Counter
 	writeErrors   metrics.Counter
+	corruptions   metrics.Counter
+	cacheHits     metrics.Counter
+	cacheMisses   metrics.Counter
 	activeEntries metrics.Gauge
 	syncLatency   metrics.LatencyHistogram
 }
@@ -103,7 +117,9 @@ func newWal(namespace string, shard int64, options *FactoryOptions, commitOffset
 		namespace:   namespace,
 		shard:       shard,
 		segmentSize: uint32(options.SegmentSize),
-		syncData:    options.SyncData,
+		syncPolicy:  options.SyncPolicy,
+		readCache:   newReadCache(readCacheMaxSize),
+		readers:     make(map[*forwardReader]struct{}),
 
 		appendLatency: metrics.NewLatencyHistogram("oxia_server_wal_append_latency",
 			"The time it takes to append entries to the WAL", labels),
@@ -119,12 +135,23 @@ func newWal(namespace string, shard int64, options *FactoryOptions, commitOffset
 			"The number of IO errors in the WAL read operations", "count", labels),
 		writeErrors: metrics.NewCounter("oxia_server_wal_write_errors",
 			"The number of IO errors in the WAL read operations", "count", labels),
+		corruptions: metrics.NewCounter("oxia_server_wal_corruptions",
+			"The number of corrupted records detected in the WAL", "count", labels),
+		cacheHits: metrics.NewCounter("oxia_server_wal_read_cache_hits",
+			"The number of WAL reads served by the read cache", "count", labels),
+		cacheMisses: metrics.NewCounter("oxia_server_wal_read_cache_misses",
+			"The number of WAL reads that had to access the segments", "count", labels),
 		syncLatency: metrics.NewLatencyHistogram("oxia_server_wal_sync_latency",
 			"The time it takes to fsync the wal data on disk", labels),
 	}
 
 	var err error
+	if w.lock, err = lockWal(w.walPath); err != nil {
+		return nil, err
+	}
+
 	if w.readOnlySegments, err = newReadOnlySegmentsGroup(w.walPath); err != nil {
+		_ = w.lock.Close()
 		return nil, err
 	}
 
@@ -137,12 +164,18 @@ func newWal(namespace string, shard int64, options *FactoryOptions, commitOffset
 		})
 
 	if err := w.recoverWal(); err != nil {
+		if errors.Is(err, ErrWalCorrupted) {
+			w.corruptions.Inc()
+		}
+		w.activeEntries.Unregister()
+		_ = w.lock.Close()
 		return nil, errors.Wrapf(err, "failed to recover wal for shard %s / %d", namespace, shard)
 	}
 
 	w.trimmer = newTrimmer(namespace, shard, w, options.Retention, trimmerCheckInterval, clock, commitOffsetProvider)
 
-	if options.SyncData {
+	switch options.SyncPolicy {
+	case SyncPolicyAlways:
 		go common.DoWithLabels(
 			w.ctx,
 			map[string]string{
@@ -152,18 +185,85 @@ func newWal(namespace string, shard int64, options *FactoryOptions, commitOffset
 			},
 			w.runSync,
 		)
+	case SyncPolicyInterval:
+		syncInterval := options.SyncInterval
+		if syncInterval <= 0 {
+			syncInterval = DefaultFactoryOptions.SyncInterval
+		}
+		go common.DoWithLabels(
+			w.ctx,
+			map[string]string{
+				"oxia":      "wal-periodic-sync",
+				"namespace": namespace,
+				"shard":     fmt.Sprintf("%d", shard),
+			},
+			func() { w.runPeriodicSync(syncInterval) },
+		)
+	case SyncPolicyNever:
+		// The data is only written back by the OS
 	}
 
 	return w, nil
 }
 
+// The entries larger than this are not kept in the pool, so that an
+// occasional large entry doesn't pin its memory
+const maxPooledEntrySize = 1024 * 1024
+
+// entryBuffers holds the buffers of the serialized entries that are only
+// needed for the duration of a read or an append: the record copies of the
+// reads that miss the cache, and the appends that the cache doesn't retain.
+var entryBuffers = sync.Pool{
+	New: func() any {
+		return new([]byte)
+	},
+}
+
+// getEntryBuffer returns an empty buffer of the pool. The caller owns it
+// until it's passed to putEntryBuffer, and must not retain any slice of it
+// after that.
+func getEntryBuffer() *[]byte {
+	buf := entryBuffers.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+func putEntryBuffer(buf *[]byte) {
+	if cap(*buf) <= maxPooledEntrySize {
+		entryBuffers.Put(buf)
+	}
+}
+
 func (t *wal) readAtIndex(index int64) (*proto.LogEntry, error) {
 	t.RLock()
 	defer t.RUnlock()
 
+	return t.readAtIndexLocked(index)
+}
+
+func (t *wal) readAtIndexLocked(index int64) (*proto.LogEntry, error) {
 	timer := t.readLatency.Timer()
 	defer timer.Done()
 
+	if index < t.firstOffset.Load() {
+		return nil, ErrEntryTrimmed
+	}
+
+	if val, ok := t.readCache.get(index); ok {
+		t.cacheHits.Inc()
+		// The cached entries are never modified, so the value of the entry
+		// can point into the cache instead of being copied
+		entry := &proto.LogEntry{}
+		if err := entry.UnmarshalVTUnsafe(val); err != nil {
+			t.readErrors.Inc()
+			return nil, err
+		}
+		t.readBytes.Add(len(val))
+		return entry, nil
+	}
+
+	t.cacheMisses.Inc()
+
 	var err error
 	var rc common.RefCount[ReadOnlySegment]
 	var segment ReadOnlySegment
@@ -181,10 +281,18 @@ func (t *wal) readAtIndex(index int64) (*proto.LogEntry, error) {
 		segment = rc.Get()
 	}
 
+	// The entry copies its value out of the record, so the record can be
+	// read into a pooled buffer
+	buf := getEntryBuffer()
+	defer putEntryBuffer(buf)
+
 	var val []byte
-	if val, err = segment.Read(index); err != nil {
+	if val, err = segment.Read(index, *buf); err != nil {
 		t.readErrors.Inc()
-		return nil, err
+		if errors.Is(err, ErrWalCorrupted) {
+			t.corruptions.Inc()
+		}
+		return nil, errors.Wrapf(err, "failed to read entry at offset %d", index)
 	}
 
 	entry := &proto.LogEntry{}
@@ -192,6 +300,7 @@ func (t *wal) readAtIndex(index int64) (*proto.LogEntry, error) {
 		t.readErrors.Inc()
 		return nil, err
 	}
+	*buf = val
 	t.readBytes.Add(len(val))
 	return entry, err
 }
@@ -204,8 +313,53 @@ func (t *wal) FirstOffset() int64 {
 	return t.firstOffset.Load()
 }
 
+func (t *wal) LastEntry() (*proto.EntryId, error) {
+	return t.entryIdAt(t.LastOffset())
+}
+
+func (t *wal) FirstEntry() (*proto.EntryId, error) {
+	return t.entryIdAt(t.FirstOffset())
+}
+
+func (t *wal) DiskUsage() (int64, error) {
+	t.RLock()
+	defer t.RUnlock()
+
+	var size int64
+	err := filepath.WalkDir(t.walPath, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func (t *wal) entryIdAt(offset int64) (*proto.EntryId, error) {
+	if offset == InvalidOffset {
+		return &proto.EntryId{Term: InvalidTerm, Offset: InvalidOffset}, ErrEmptyWal
+	}
+
+	entry, err := t.readAtIndex(offset)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.EntryId{Term: entry.Term, Offset: entry.Offset}, nil
+}
+
 func (t *wal) trim(firstOffset int64) error {
-	if firstOffset <= t.firstOffset.Load() {
+	// Readers hold the read lock while accessing a segment, so we
+	// cannot delete a segment that is being read
+	t.Lock()
+	defer t.Unlock()
+
+	if firstOffset <= t.firstOffset.Load() || firstOffset > t.lastSyncedOffset.Load() {
 		return nil
 	}
 
@@ -213,6 +367,7 @@ func (t *wal) trim(firstOffset int64) error {
 		return err
 	}
 
+	t.readCache.trim(firstOffset)
 	t.trimOps.Inc()
 	t.firstOffset.Store(firstOffset)
 	return nil
@@ -237,6 +392,7 @@ func (t *wal) close() error {
 		t.trimmer.Close(),
 		t.currentSegment.Close(),
 		t.readOnlySegments.Close(),
+		t.lock.Close(),
 	)
 }
 
@@ -260,7 +416,19 @@ func (t *wal) AppendAsync(entry *proto.LogEntry) error {
 		return err
 	}
 
-	val, err := pb.Marshal(entry)
+	// The entry is serialized into a pooled buffer when the cache doesn't
+	// retain it, since the segment keeps its own copy
+	size := entry.SizeVT()
+	var val []byte
+	if t.readCache.retains(size) {
+		val = make([]byte, size)
+	} else {
+		buf := getEntryBuffer()
+		defer putEntryBuffer(buf)
+		*buf = slices.Grow(*buf, size)
+		val = (*buf)[:size]
+	}
+	_, err := entry.MarshalToSizedBufferVT(val)
 	if err != nil {
 		t.writeErrors.Inc()
 		return err
@@ -273,7 +441,7 @@ func (t *wal) AppendAsync(entry *proto.LogEntry) error {
 			return err
 		}
 
-		if t.currentSegment, err = newReadWriteSegment(t.walPath, entry.Offset, t.segmentSize); err != nil {
+		if t.currentSegment, err = openReadWriteSegment(t.walPath, entry.Offset, t.segmentSize); err != nil {
 			t.writeErrors.Inc()
 			return err
 		}
@@ -290,6 +458,7 @@ func (t *wal) AppendAsync(entry *proto.LogEntry) error {
 		t.writeErrors.Inc()
 		return err
 	}
+	t.readCache.put(entry.Offset, val)
 	t.lastAppendedOffset.Store(entry.Offset)
 	t.firstOffset.CompareAndSwap(InvalidOffset, entry.Offset)
 
@@ -307,14 +476,22 @@ func (t *wal) AppendAndSync(entry *proto.LogEntry, callback func(err error)) {
 }
 
 func (t *wal) rolloverSegment() error {
+	// The segment is not flushed when closed, and the syncs only flush
+	// the current segment
 	var err error
+	if t.syncPolicy != SyncPolicyNever {
+		if err = t.currentSegment.Flush(); err != nil {
+			return err
+		}
+	}
+
 	if err = t.currentSegment.Close(); err != nil {
 		return err
 	}
 
 	t.readOnlySegments.AddedNewSegment(t.currentSegment.BaseOffset())
 
-	if t.currentSegment, err = newReadWriteSegment(t.walPath, t.lastAppendedOffset.Load()+1, t.segmentSize); err != nil {
+	if t.currentSegment, err = openReadWriteSegment(t.walPath, t.lastAppendedOffset.Load()+1, t.segmentSize); err != nil {
 		return err
 	}
 
@@ -374,8 +551,43 @@ func (t *wal) runSync() {
 	}
 }
 
+func (t *wal) runPeriodicSync(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			// Wal is closing, exit the go routine
+			return
+
+		case <-ticker.C:
+			// Entries are already acknowledged when they get here, so we
+			// hold the lock to prevent the segment from being closed by a
+			// rollover while flushing it
+			t.Lock()
+			if t.ctx.Err() == nil {
+				timer := t.syncLatency.Timer()
+				if err := t.currentSegment.Flush(); err != nil {
+					t.writeErrors.Inc()
+					slog.Warn(
+						"Failed to sync wal",
+						slog.String("component", "wal"),
+						slog.String("namespace", t.namespace),
+						slog.Int64("shard", t.shard),
+						slog.Any("error", err),
+					)
+				} else {
+					timer.Done()
+				}
+			}
+			t.Unlock()
+		}
+	}
+}
+
 func (t *wal) doSync(callback func(error)) {
-	if !t.syncData {
+	if t.syncPolicy != SyncPolicyAlways {
 		t.lastSyncedOffset.Store(t.lastAppendedOffset.Load())
 		callback(nil)
 		return
@@ -416,6 +628,13 @@ func (t *wal) Clear() error {
 	t.Lock()
 	defer t.Unlock()
 
+	return t.clear()
+}
+
+func (t *wal) clear() error {
+	t.truncateReaders(InvalidOffset)
+	t.readCache.clear()
+
 	err := multierr.Combine(
 		t.currentSegment.Close(),
 		t.readOnlySegments.Close(),
@@ -427,7 +646,7 @@ func (t *wal) Clear() error {
 		return errors.Wrap(err, "failed to clear wal")
 	}
 
-	if t.currentSegment, err = newReadWriteSegment(t.walPath, 0, t.segmentSize); err != nil {
+	if t.currentSegment, err = openReadWriteSegment(t.walPath, 0, t.segmentSize); err != nil {
 		return err
 	}
 
@@ -448,6 +667,7 @@ func (t *wal) Delete() error {
 	return multierr.Combine(
 		t.close(),
 		os.RemoveAll(t.walPath),
+		os.Remove(lockPath(t.walPath)),
 	)
 }
 
@@ -468,6 +688,9 @@ func (t *wal) TruncateLog(lastSafeOffset int64) (int64, error) { //nolint:revive
 		return InvalidOffset, nil
 	}
 
+	t.truncateReaders(lastSafeOffset)
+	t.readCache.truncate(lastSafeOffset)
+
 	if lastSafeOffset >= t.currentSegment.BaseOffset() {
 		// Truncation is only affecting the
 		if err := t.currentSegment.Truncate(lastSafeOffset); err != nil {
@@ -486,7 +709,7 @@ func (t *wal) TruncateLog(lastSafeOffset int64) (int64, error) { //nolint:revive
 				return InvalidOffset, err
 			case segment == nil:
 				// There are no segments left
-				if err := t.Clear(); err != nil {
+				if err := t.clear(); err != nil {
 					return InvalidOffset, err
 				}
 				return t.LastOffset(), nil
@@ -497,17 +720,16 @@ func (t *wal) TruncateLog(lastSafeOffset int64) (int64, error) { //nolint:revive
 					return InvalidOffset, err
 				}
 
-				if t.currentSegment, err = newReadWriteSegment(t.walPath, segment.Get().BaseOffset(), t.segmentSize); err != nil {
-					err = multierr.Append(err, segment.Close())
+				if t.currentSegment, err = openReadWriteSegment(t.walPath, segment.Get().BaseOffset(), t.segmentSize); err != nil {
 					return InvalidOffset, err
 				}
 				if err := t.currentSegment.Truncate(lastSafeOffset); err != nil {
-					err = multierr.Append(err, segment.Close())
 					return InvalidOffset, err
 				}
 
-				err = segment.Close()
-				return lastSafeOffset, err
+				t.lastAppendedOffset.Store(lastSafeOffset)
+				t.lastSyncedOffset.Store(lastSafeOffset)
+				return lastSafeOffset, nil
 			default:
 				// The entire segment can be discarded
 				if err := segment.Get().Delete(); err != nil {
@@ -540,7 +762,7 @@ func (t *wal) recoverWal() error {
 		lastSegment = 0
 	}
 
-	if t.currentSegment, err = newReadWriteSegment(t.walPath, lastSegment, t.segmentSize); err != nil {
+	if t.currentSegment, err = openReadWriteSegment(t.walPath, lastSegment, t.segmentSize); err != nil {
 		return err
 	}
 
@@ -0,0 +1,274 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/edsrzf/mmap-go"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/streamnative/oxia/proto"
+)
+
+// Inspector gives read-only access to the wal of a shard, to troubleshoot it
+// offline. The wal is locked while it's inspected, so it cannot be inspected
+// while it's in use by a server, nor opened by a server in the meantime.
+type Inspector interface {
+	io.Closer
+
+	// FirstOffset returns the offset of the first entry, or InvalidOffset if
+	// the wal is empty.
+	FirstOffset() int64
+
+	// LastOffset returns the offset of the last entry, or InvalidOffset if
+	// the wal is empty.
+	LastOffset() int64
+
+	// Read returns the entry at the given offset, after having verified its
+	// checksum.
+	Read(offset int64) (*proto.LogEntry, error)
+
+	// Verify walks through all the records, checking their framing and their
+	// checksums, and that the offsets and terms of the entries are in sequence.
+	// It returns the number of valid entries before the first corruption, and
+	// the corruption, if any.
+	Verify() (int64, error)
+
+	// IncompleteTail tells whether the last record was only partially written,
+	// eg: after a crash. It's not a corruption, since the record was never
+	// acknowledged, and it is discarded when the wal is opened.
+	IncompleteTail() bool
+}
+
+type inspector struct {
+	lock     *os.File
+	segments []*inspectedSegment
+}
+
+// NewInspector opens the wal of the shard read-only. It fails with
+// ErrWalLocked if the wal is in use.
+func NewInspector(baseWalDir string, namespace string, shard int64) (Inspector, error) {
+	path := walPath(baseWalDir, namespace, shard)
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.Wrapf(err, "failed to open wal %s", path)
+	}
+
+	lock, err := lockWal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &inspector{lock: lock}
+	segments, err := listAllSegments(path)
+	if err != nil {
+		return nil, multierr.Append(err, i.Close())
+	}
+
+	for _, baseOffset := range segments {
+		s, err := openInspectedSegment(path, baseOffset)
+		if err != nil {
+			return nil, multierr.Append(err, i.Close())
+		}
+		i.segments = append(i.segments, s)
+	}
+	return i, nil
+}
+
+func (i *inspector) FirstOffset() int64 {
+	if i.LastOffset() == InvalidOffset {
+		return InvalidOffset
+	}
+	return i.segments[0].baseOffset
+}
+
+func (i *inspector) LastOffset() int64 {
+	if len(i.segments) == 0 {
+		return InvalidOffset
+	}
+
+	// The last segment is empty right after a roll over
+	lastOffset := i.segments[len(i.segments)-1].lastOffset()
+	if lastOffset < i.segments[0].baseOffset {
+		return InvalidOffset
+	}
+	return lastOffset
+}
+
+func (i *inspector) IncompleteTail() bool {
+	return len(i.segments) > 0 && i.segments[len(i.segments)-1].torn
+}
+
+func (i *inspector) Read(offset int64) (*proto.LogEntry, error) {
+	// Find the last segment starting at or before the offset
+	n := sort.Search(len(i.segments), func(n int) bool {
+		return i.segments[n].baseOffset > offset
+	})
+	if n == 0 || offset > i.segments[n-1].lastOffset() {
+		return nil, ErrEntryNotFound
+	}
+
+	return i.segments[n-1].read(offset)
+}
+
+func (i *inspector) Verify() (int64, error) {
+	var entries int64
+	lastOffset := InvalidOffset
+	lastTerm := InvalidTerm
+
+	for n, s := range i.segments {
+		if n > 0 && s.baseOffset != lastOffset+1 {
+			return entries, errors.Wrapf(ErrWalCorrupted, "missing entries between offsets %d and %d",
+				lastOffset, s.baseOffset)
+		}
+
+		for offset := s.baseOffset; offset <= s.lastOffset(); offset++ {
+			entry, err := s.read(offset)
+			if err != nil {
+				return entries, err
+			}
+			if entry.Offset != offset {
+				return entries, errors.Wrapf(ErrWalCorrupted, "entry at offset %d has offset %d", offset, entry.Offset)
+			}
+			if entry.Term < lastTerm {
+				return entries, errors.Wrapf(ErrWalCorrupted, "entry at offset %d has term %d, lower than the term %d of the previous entry",
+					offset, entry.Term, lastTerm)
+			}
+			lastTerm = entry.Term
+			entries++
+		}
+		lastOffset = s.lastOffset()
+
+		if err := s.verify(n == len(i.segments)-1); err != nil {
+			return entries, err
+		}
+	}
+
+	return entries, nil
+}
+
+func (i *inspector) Close() error {
+	var err error
+	for _, s := range i.segments {
+		err = multierr.Append(err, s.close())
+	}
+	return multierr.Append(err, i.lock.Close())
+}
+
+// inspectedSegment is a segment opened read-only, whose records are scanned
+// as when the wal is recovered, rather than trusting the index file.
+type inspectedSegment struct {
+	path          string
+	baseOffset    int64
+	txnFile       *os.File
+	txnMappedFile mmap.MMap
+
+	idx  []byte
+	end  uint32
+	torn bool
+
+	// The corruption found while scanning the records
+	err error
+}
+
+func openInspectedSegment(basePath string, baseOffset int64) (*inspectedSegment, error) {
+	s := &inspectedSegment{
+		path:       segmentPath(basePath, baseOffset),
+		baseOffset: baseOffset,
+	}
+
+	txnPath := s.path + txnExtension
+	var err error
+	if s.txnFile, err = os.Open(txnPath); err != nil {
+		return nil, errors.Wrapf(err, "failed to open segment txn file %s", txnPath)
+	}
+
+	stat, err := s.txnFile.Stat()
+	if err != nil {
+		return nil, multierr.Append(errors.Wrapf(err, "failed to stat segment txn file %s", txnPath), s.close())
+	}
+
+	if stat.Size() > 0 {
+		if s.txnMappedFile, err = mmap.MapRegion(s.txnFile, -1, mmap.RDONLY, 0, 0); err != nil {
+			return nil, multierr.Append(errors.Wrapf(err, "failed to map segment txn file %s", txnPath), s.close())
+		}
+	}
+
+	if !isEmptySegment(s.txnMappedFile) {
+		if s.err = checkSegmentHeader(s.txnMappedFile, txnPath); s.err != nil {
+			return s, nil
+		}
+	}
+
+	validSize := uint32(min(len(s.txnMappedFile), math.MaxUint32))
+	s.idx, s.end, s.torn, s.err = scanRecords(s.txnMappedFile, validSize)
+	if s.err != nil {
+		s.err = errors.Wrapf(s.err, "segment %s", txnPath)
+	}
+	return s, nil
+}
+
+func (s *inspectedSegment) lastOffset() int64 {
+	return s.baseOffset + int64(len(s.idx)/4) - 1
+}
+
+func (s *inspectedSegment) read(offset int64) (*proto.LogEntry, error) {
+	data, err := readRecord(nil, s.txnMappedFile, fileOffset(s.idx, s.baseOffset, offset))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read entry at offset %d", offset)
+	}
+
+	entry := &proto.LogEntry{}
+	if err = entry.UnmarshalVT(data); err != nil {
+		return nil, errors.Wrapf(ErrWalCorrupted, "failed to decode entry at offset %d: %v", offset, err)
+	}
+	return entry, nil
+}
+
+// verify reports the corruption found while scanning the records. A segment
+// that was rolled over must be complete and have an index matching its
+// records, while the last one can end with a partially written record.
+func (s *inspectedSegment) verify(last bool) error {
+	if s.err != nil || last {
+		return s.err
+	}
+
+	if s.torn {
+		return errors.Wrapf(ErrWalCorrupted, "segment %s: invalid record at position %d",
+			s.path+txnExtension, s.end)
+	}
+
+	idx, err := os.ReadFile(s.path + idxExtension)
+	if err != nil {
+		return errors.Wrapf(ErrWalCorrupted, "segment %s: failed to read the index: %v", s.path+txnExtension, err)
+	}
+	if !bytes.Equal(idx, s.idx) {
+		return errors.Wrapf(ErrWalCorrupted, "segment %s: the index does not match the records", s.path+txnExtension)
+	}
+	return nil
+}
+
+func (s *inspectedSegment) close() error {
+	var err error
+	if s.txnMappedFile != nil {
+		err = s.txnMappedFile.Unmap()
+	}
+	return multierr.Append(err, s.txnFile.Close())
+}
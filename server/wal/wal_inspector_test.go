@@ -0,0 +1,170 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+)
+
+func TestInspector(t *testing.T) {
+	f, w := createWal(t)
+	baseWalDir := f.(*walFactory).options.BaseWalDir
+	entries := appendLargeEntries(t, w, 300)
+
+	// The wal cannot be inspected while it's in use
+	i, err := NewInspector(baseWalDir, common.DefaultNamespace, shard)
+	assert.ErrorIs(t, err, ErrWalLocked)
+	assert.Nil(t, i)
+	assert.NoError(t, w.Close())
+
+	i, err = NewInspector(baseWalDir, common.DefaultNamespace, shard)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, i.FirstOffset())
+	assert.EqualValues(t, 299, i.LastOffset())
+	assert.False(t, i.IncompleteTail())
+
+	for _, offset := range []int64{0, 150, 299} {
+		le, err := i.Read(offset)
+		assert.NoError(t, err)
+		assert.Equal(t, entries[offset].Value, le.Value)
+		assert.Equal(t, entries[offset].Offset, le.Offset)
+	}
+
+	le, err := i.Read(300)
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+	assert.Nil(t, le)
+
+	count, err := i.Verify()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 300, count)
+
+	// The wal cannot be opened while it's inspected
+	_, err = f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.ErrorIs(t, err, ErrWalLocked)
+	assert.NoError(t, i.Close())
+
+	w, err = f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 299, w.LastOffset())
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestInspector_NotFound(t *testing.T) {
+	i, err := NewInspector(t.TempDir(), common.DefaultNamespace, shard)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Nil(t, i)
+}
+
+func TestInspector_Corruption(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		// Returns the segment and the position in it to corrupt
+		position         func(segments []int64, recordSize int64) (int64, int64)
+		expectedEntries  int64
+		expectCorruption bool
+		incompleteTail   bool
+	}{
+		{
+			name: "read-only-segment",
+			position: func(_ []int64, recordSize int64) (int64, int64) {
+				return 0, segmentHeaderSize + 5*recordSize + recordHeaderSize + 10
+			},
+			expectedEntries:  5,
+			expectCorruption: true,
+		},
+		{
+			name: "last-segment",
+			position: func(segments []int64, recordSize int64) (int64, int64) {
+				return segments[len(segments)-1], segmentHeaderSize + 5*recordSize + 5
+			},
+			expectedEntries:  -1, // 5 entries in the last segment
+			expectCorruption: true,
+		},
+		{
+			name: "tail-record",
+			position: func(segments []int64, recordSize int64) (int64, int64) {
+				lastSegment := segments[len(segments)-1]
+				return lastSegment, segmentHeaderSize + (299-lastSegment)*recordSize + recordHeaderSize + 10
+			},
+			expectedEntries: 299,
+			incompleteTail:  true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			f, w := createWal(t)
+			entries := appendLargeEntries(t, w, 300)
+			assert.NoError(t, w.Close())
+			assert.NoError(t, f.Close())
+
+			baseWalDir := f.(*walFactory).options.BaseWalDir
+			dir := walPath(baseWalDir, common.DefaultNamespace, shard)
+			segments, err := listAllSegments(dir)
+			assert.NoError(t, err)
+			assert.Greater(t, len(segments), 1)
+
+			recordSize := int64(recordHeaderSize + entries[segments[len(segments)-1]].SizeVT())
+			segment, position := test.position(segments, recordSize)
+			flipByte(t, segmentPath(dir, segment)+txnExtension, position)
+
+			expectedEntries := test.expectedEntries
+			if expectedEntries < 0 {
+				expectedEntries = segments[len(segments)-1] + 5
+			}
+
+			i, err := NewInspector(baseWalDir, common.DefaultNamespace, shard)
+			assert.NoError(t, err)
+
+			count, err := i.Verify()
+			if test.expectCorruption {
+				assert.ErrorIs(t, err, ErrWalCorrupted)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, expectedEntries, count)
+			assert.Equal(t, test.incompleteTail, i.IncompleteTail())
+
+			// The entries before the corruption are still readable
+			le, err := i.Read(expectedEntries - 1)
+			assert.NoError(t, err)
+			assert.Equal(t, entries[expectedEntries-1].Value, le.Value)
+
+			assert.NoError(t, i.Close())
+		})
+	}
+}
+
+func TestInspector_MissingIndex(t *testing.T) {
+	f, w := createWal(t)
+	appendLargeEntries(t, w, 300)
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+
+	baseWalDir := f.(*walFactory).options.BaseWalDir
+	assert.NoError(t, os.Remove(segmentPath(walPath(baseWalDir, common.DefaultNamespace, shard), 0)+idxExtension))
+
+	i, err := NewInspector(baseWalDir, common.DefaultNamespace, shard)
+	assert.NoError(t, err)
+
+	count, err := i.Verify()
+	assert.ErrorIs(t, err, ErrWalCorrupted)
+	assert.NotZero(t, count)
+	assert.NoError(t, i.Close())
+}
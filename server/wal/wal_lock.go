@@ -0,0 +1,54 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+var ErrWalLocked = errors.New("oxia: wal is in use")
+
+// lockPath is next to the directory of the wal, so that the lock is kept
+// while the directory is cleared.
+func lockPath(walPath string) string {
+	return walPath + ".lock"
+}
+
+// lockWal takes an exclusive lock on the wal of a shard, so that it is not
+// opened by another server, or inspected while it's in use. The lock is
+// released when the returned file is closed, or when the process exits.
+func lockWal(walPath string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(walPath), 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create wal directory %s", filepath.Dir(walPath))
+	}
+
+	f, err := os.OpenFile(lockPath(walPath), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open wal lock file %s", lockPath(walPath))
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errors.Wrapf(ErrWalLocked, "wal %s", walPath)
+		}
+		return nil, errors.Wrapf(err, "failed to lock wal %s", walPath)
+	}
+	return f, nil
+}
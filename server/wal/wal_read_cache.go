@@ -0,0 +1,110 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+// The max amount of entries data kept in the read cache of each wal
+const readCacheMaxSize = 8 * 1024 * 1024
+
+// readCache keeps the serialized form of the most recently appended
+// entries, so that readers close to the head of the log, like a follower
+// that is catching up, don't need to go through the segment files.
+//
+// The cache is not synchronized itself: it relies on the wal lock, with
+// the mutations happening under the write lock.
+type readCache struct {
+	// entries[i] is the entry at offset firstOffset + i
+	entries     [][]byte
+	firstOffset int64
+	size        int
+	maxSize     int
+}
+
+func newReadCache(maxSize int) *readCache {
+	return &readCache{
+		firstOffset: InvalidOffset,
+		maxSize:     maxSize,
+	}
+}
+
+func (c *readCache) lastOffset() int64 {
+	return c.firstOffset + int64(len(c.entries)) - 1
+}
+
+// retains returns whether a value of the given size is kept by put. The
+// values that are not kept are not referenced after put returns.
+func (c *readCache) retains(size int) bool {
+	return size <= c.maxSize
+}
+
+func (c *readCache) put(offset int64, value []byte) {
+	if !c.retains(len(value)) {
+		c.clear()
+		return
+	}
+
+	if len(c.entries) == 0 || offset != c.lastOffset()+1 {
+		c.clear()
+		c.firstOffset = offset
+	}
+
+	c.entries = append(c.entries, value)
+	c.size += len(value)
+
+	for c.size > c.maxSize {
+		c.size -= len(c.entries[0])
+		c.entries[0] = nil
+		c.entries = c.entries[1:]
+		c.firstOffset++
+	}
+}
+
+func (c *readCache) get(offset int64) ([]byte, bool) {
+	if len(c.entries) == 0 || offset < c.firstOffset || offset > c.lastOffset() {
+		return nil, false
+	}
+
+	return c.entries[offset-c.firstOffset], true
+}
+
+// Discard the entries after lastSafeOffset.
+func (c *readCache) truncate(lastSafeOffset int64) {
+	if lastSafeOffset < c.firstOffset {
+		c.clear()
+		return
+	}
+
+	for c.lastOffset() > lastSafeOffset {
+		last := len(c.entries) - 1
+		c.size -= len(c.entries[last])
+		c.entries[last] = nil
+		c.entries = c.entries[:last]
+	}
+}
+
+// Discard the entries before firstOffset.
+func (c *readCache) trim(firstOffset int64) {
+	for len(c.entries) > 0 && c.firstOffset < firstOffset {
+		c.size -= len(c.entries[0])
+		c.entries[0] = nil
+		c.entries = c.entries[1:]
+		c.firstOffset++
+	}
+}
+
+func (c *readCache) clear() {
+	c.entries = nil
+	c.firstOffset = InvalidOffset
+	c.size = 0
+}
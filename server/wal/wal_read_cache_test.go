@@ -0,0 +1,218 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+func TestReadCache(t *testing.T) {
+	c := newReadCache(10)
+
+	_, found := c.get(0)
+	assert.False(t, found)
+
+	c.put(0, []byte("a"))
+	c.put(1, []byte("bb"))
+	c.put(2, []byte("ccc"))
+
+	v, found := c.get(1)
+	assert.True(t, found)
+	assert.Equal(t, "bb", string(v))
+	assert.EqualValues(t, 0, c.firstOffset)
+	assert.EqualValues(t, 2, c.lastOffset())
+
+	// Evict the oldest entries to stay within the max size
+	c.put(3, []byte("dddd"))
+	c.put(4, []byte("e"))
+	assert.EqualValues(t, 1, c.firstOffset)
+	assert.Equal(t, 10, c.size)
+	_, found = c.get(0)
+	assert.False(t, found)
+
+	c.truncate(2)
+	assert.EqualValues(t, 2, c.lastOffset())
+	_, found = c.get(3)
+	assert.False(t, found)
+	assert.Equal(t, 5, c.size)
+
+	c.trim(2)
+	assert.EqualValues(t, 2, c.firstOffset)
+	_, found = c.get(1)
+	assert.False(t, found)
+	v, found = c.get(2)
+	assert.True(t, found)
+	assert.Equal(t, "ccc", string(v))
+
+	// A gap in the offsets resets the cache
+	c.put(10, []byte("x"))
+	assert.EqualValues(t, 10, c.firstOffset)
+	assert.EqualValues(t, 10, c.lastOffset())
+	_, found = c.get(2)
+	assert.False(t, found)
+
+	// Entries bigger than the cache are not kept
+	c.put(11, []byte("01234567890"))
+	_, found = c.get(11)
+	assert.False(t, found)
+	_, found = c.get(10)
+	assert.False(t, found)
+
+	c.truncate(InvalidOffset)
+	assert.Equal(t, 0, c.size)
+	assert.EqualValues(t, InvalidOffset, c.firstOffset)
+}
+
+func TestReadCacheWithTruncations(t *testing.T) {
+	f, w := createWal(t)
+	// Use a small cache, so that reads are served both from the
+	// cache and from the segments
+	w.(*wal).readCache = newReadCache(20 * 1024)
+
+	r := rand.New(rand.NewSource(1))
+	var values []string
+	term := int64(0)
+
+	for i := 0; i < 2000; i++ {
+		switch {
+		case len(values) > 0 && r.Intn(100) < 5:
+			lastSafeOffset := int64(r.Intn(len(values))) - 1
+			_, err := w.TruncateLog(lastSafeOffset)
+			assert.NoError(t, err)
+			values = values[:lastSafeOffset+1]
+			term++
+
+		default:
+			value := make([]byte, 1024)
+			copy(value, fmt.Sprintf("entry-%d-%d", term, len(values)))
+			assert.NoError(t, w.Append(&proto.LogEntry{
+				Term:   term,
+				Offset: int64(len(values)),
+				Value:  value,
+			}))
+			values = append(values, string(value))
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		// Read a random range of the log
+		from := int64(r.Intn(len(values)))
+		reader, err := w.NewReader(from - 1)
+		assert.NoError(t, err)
+		for offset := from; offset < int64(len(values)); offset++ {
+			assert.True(t, reader.HasNext())
+			le, err := reader.ReadNext()
+			assert.NoError(t, err)
+			assert.EqualValues(t, offset, le.Offset)
+			assert.Equal(t, values[offset], string(le.Value))
+		}
+		assert.False(t, reader.HasNext())
+		assert.NoError(t, reader.Close())
+	}
+
+	assert.NoError(t, w.Close())
+
+	// The entries read after a restart must match as well
+	w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+	reader, err := w.NewReader(InvalidOffset)
+	assert.NoError(t, err)
+	for offset := 0; offset < len(values); offset++ {
+		le, err := reader.ReadNext()
+		assert.NoError(t, err)
+		assert.Equal(t, values[offset], string(le.Value))
+	}
+	assert.False(t, reader.HasNext())
+	assert.NoError(t, reader.Close())
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+// Appends the entries without syncing them, to measure the memory that is
+// allocated for each entry.
+func BenchmarkAppendAsync(b *testing.B) {
+	for _, cacheSize := range []int{0, readCacheMaxSize} {
+		b.Run(fmt.Sprintf("cache-size-%d", cacheSize), func(b *testing.B) {
+			f := NewWalFactory(&FactoryOptions{BaseWalDir: b.TempDir()})
+			w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+			assert.NoError(b, err)
+			w.(*wal).readCache = newReadCache(cacheSize)
+
+			entry := &proto.LogEntry{Term: 1, Value: make([]byte, 1024)}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				entry.Offset = int64(i)
+				if err := w.AppendAsync(entry); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			assert.NoError(b, w.Close())
+			assert.NoError(b, f.Close())
+		})
+	}
+}
+
+func BenchmarkCatchUpRead(b *testing.B) {
+	for _, cacheSize := range []int{0, readCacheMaxSize} {
+		b.Run(fmt.Sprintf("cache-size-%d", cacheSize), func(b *testing.B) {
+			f := NewWalFactory(&FactoryOptions{
+				BaseWalDir:  b.TempDir(),
+				SegmentSize: 1024 * 1024,
+			})
+			w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+			assert.NoError(b, err)
+			w.(*wal).readCache = newReadCache(cacheSize)
+
+			value := make([]byte, 1024)
+			for i := int64(0); i < 10_000; i++ {
+				assert.NoError(b, w.AppendAsync(&proto.LogEntry{Term: 1, Offset: i, Value: value}))
+			}
+			assert.NoError(b, w.Sync(context.Background()))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			// A follower being a few thousand entries behind
+			for i := 0; i < b.N; i++ {
+				reader, err := w.NewReader(10_000 - 5_000 - 1)
+				assert.NoError(b, err)
+				for reader.HasNext() {
+					if _, err := reader.ReadNext(); err != nil {
+						b.Fatal(err)
+					}
+				}
+				assert.NoError(b, reader.Close())
+			}
+
+			assert.NoError(b, w.Close())
+			assert.NoError(b, f.Close())
+		})
+	}
+}
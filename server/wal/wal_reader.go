@@ -23,8 +23,11 @@ import (
 func (t *wal) NewReader(after int64) (Reader, error) {
 	firstOffset := after + 1
 
+	t.Lock()
+	defer t.Unlock()
+
 	if firstOffset < t.FirstOffset() {
-		return nil, ErrEntryNotFound
+		return nil, ErrEntryTrimmed
 	}
 
 	r := &forwardReader{
@@ -35,9 +38,21 @@ func (t *wal) NewReader(after int64) (Reader, error) {
 		},
 	}
 
+	t.readers[r] = struct{}{}
 	return r, nil
 }
 
+// Invalidate the readers that are positioned after the last entry that
+// survives the truncation. The wal lock must be held, so that no reader
+// is advancing in the meantime.
+func (t *wal) truncateReaders(lastSafeOffset int64) {
+	for r := range t.readers {
+		if r.nextOffset > lastSafeOffset+1 {
+			r.truncated = true
+		}
+	}
+}
+
 func (t *wal) NewReverseReader() (Reader, error) {
 	r := &reverseReader{reader{
 		wal:        t,
@@ -59,6 +74,10 @@ type reader struct {
 type forwardReader struct {
 	reader
 	sync.Mutex
+
+	// Set, under the wal lock, when the log is truncated before the
+	// position of the reader
+	truncated bool
 }
 
 type reverseReader struct {
@@ -71,6 +90,7 @@ func (r *forwardReader) Close() error {
 	r.wal.Lock()
 	defer r.wal.Unlock()
 	r.closed = true
+	delete(r.wal.readers, r)
 	return nil
 }
 
@@ -90,8 +110,21 @@ func (r *forwardReader) ReadNext() (*proto.LogEntry, error) {
 		return nil, ErrReaderClosed
 	}
 
-	index := r.nextOffset
-	entry, err := r.wal.readAtIndex(index)
+	// The entry is read and the reader advanced under the wal lock, so that
+	// a truncation either happens before the read or sees the new position
+	r.wal.RLock()
+	defer r.wal.RUnlock()
+
+	if r.truncated {
+		return nil, ErrReaderTruncated
+	}
+
+	if r.nextOffset > r.wal.LastOffset() {
+		// The entries after the last synced one are not readable yet
+		return nil, ErrEntryNotFound
+	}
+
+	entry, err := r.wal.readAtIndexLocked(r.nextOffset)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +141,11 @@ func (r *forwardReader) HasNext() bool {
 		return false
 	}
 
-	return r.nextOffset <= r.wal.LastOffset()
+	r.wal.RLock()
+	defer r.wal.RUnlock()
+
+	// A truncated reader reports the error on the next read
+	return r.truncated || r.nextOffset <= r.wal.LastOffset()
 }
 
 func (r *reverseReader) ReadNext() (*proto.LogEntry, error) {
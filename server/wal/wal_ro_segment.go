@@ -17,6 +17,7 @@ package wal
 import (
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
@@ -39,10 +40,75 @@ func segmentPath(basePath string, firstOffset int64) string {
 	return filepath.Join(basePath, fmt.Sprintf("%d", firstOffset))
 }
 
+// Each txn file starts with a header: [magic uint32][format version uint32]
+const (
+	segmentHeaderSize    = 8
+	segmentMagic         = 0x4f58574c // "OXWL"
+	segmentFormatVersion = 1
+)
+
+// Each record in the txn file is framed as: [size uint32][crc32c uint32][data]
+const recordHeaderSize = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
 func readInt(b []byte, offset uint32) uint32 {
 	return binary.BigEndian.Uint32(b[offset : offset+4])
 }
 
+func writeSegmentHeader(b []byte) {
+	binary.BigEndian.PutUint32(b, segmentMagic)
+	binary.BigEndian.PutUint32(b[4:], segmentFormatVersion)
+}
+
+// A segment that was created without a header being written, which can
+// only happen when crashing right after its creation. It has no records.
+func isEmptySegment(b []byte) bool {
+	return len(b) < segmentHeaderSize || binary.BigEndian.Uint64(b) == 0
+}
+
+// Checks that the txn file was written with the current format. The segments
+// written before the format header was introduced don't have a checksum for
+// their records and cannot be read.
+func checkSegmentHeader(b []byte, txnPath string) error {
+	if len(b) < segmentHeaderSize {
+		return errors.Wrapf(ErrUnsupportedFormat, "segment %s is missing the format header", txnPath)
+	}
+
+	if magic := readInt(b, 0); magic != segmentMagic {
+		return errors.Wrapf(ErrUnsupportedFormat,
+			"segment %s was written by an older version and must be removed before upgrading", txnPath)
+	}
+
+	if version := readInt(b, 4); version != segmentFormatVersion {
+		return errors.Wrapf(ErrUnsupportedFormat, "segment %s has format version %d, expected %d",
+			txnPath, version, segmentFormatVersion)
+	}
+	return nil
+}
+
+func writeRecord(b []byte, offset uint32, data []byte) {
+	binary.BigEndian.PutUint32(b[offset:], uint32(len(data)))
+	binary.BigEndian.PutUint32(b[offset+4:], crc32.Checksum(data, crcTable))
+	copy(b[offset+recordHeaderSize:], data)
+}
+
+// Returns a copy of the data for the record at the given file offset,
+// after having verified its checksum. The data is copied into buf, which is
+// grown if it's too small, or into a new slice if buf is nil.
+func readRecord(buf []byte, b []byte, offset uint32) ([]byte, error) {
+	entryLen := readInt(b, offset)
+	if uint64(offset)+recordHeaderSize+uint64(entryLen) > uint64(len(b)) {
+		return nil, errors.Wrapf(ErrWalCorrupted, "invalid record size %d at position %d", entryLen, offset)
+	}
+
+	entry := append(buf[:0], b[offset+recordHeaderSize:offset+recordHeaderSize+entryLen]...)
+	if crc32.Checksum(entry, crcTable) != readInt(b, offset+4) {
+		return nil, errors.Wrapf(ErrWalCorrupted, "checksum mismatch for record at position %d", offset)
+	}
+	return entry, nil
+}
+
 func fileOffset(idx []byte, firstOffset, offset int64) uint32 {
 	return readInt(idx, uint32((offset-firstOffset)*4))
 }
@@ -53,7 +119,9 @@ type ReadOnlySegment interface {
 	BaseOffset() int64
 	LastOffset() int64
 
-	Read(offset int64) ([]byte, error)
+	// Read returns a copy of the data of the entry at the offset, reusing
+	// the memory of buf when it's large enough.
+	Read(offset int64, buf []byte) ([]byte, error)
 
 	Delete() error
 
@@ -93,6 +161,10 @@ func newReadOnlySegment(basePath string, baseOffset int64) (ReadOnlySegment, err
 		return nil, errors.Wrapf(err, "failed to map segment txn file %s", ms.txnPath)
 	}
 
+	if err = checkSegmentHeader(ms.txnMappedFile, ms.txnPath); err != nil {
+		return nil, multierr.Combine(err, ms.txnMappedFile.Unmap(), ms.txnFile.Close())
+	}
+
 	if ms.idxFile, err = os.OpenFile(ms.idxPath, os.O_RDONLY, 0); err != nil {
 		return nil, errors.Wrapf(err, "failed to open segment index file %s", ms.idxPath)
 	}
@@ -113,17 +185,12 @@ func (ms *readonlySegment) LastOffset() int64 {
 	return ms.lastOffset
 }
 
-func (ms *readonlySegment) Read(offset int64) ([]byte, error) {
+func (ms *readonlySegment) Read(offset int64, buf []byte) ([]byte, error) {
 	if offset < ms.baseOffset || offset > ms.lastOffset {
 		return nil, ErrOffsetOutOfBounds
 	}
 
-	fileOffset := fileOffset(ms.idxMappedFile, ms.baseOffset, offset)
-	entryLen := readInt(ms.txnMappedFile, fileOffset)
-	entry := make([]byte, entryLen)
-	copy(entry, ms.txnMappedFile[fileOffset+4:fileOffset+4+entryLen])
-
-	return entry, nil
+	return readRecord(buf, ms.txnMappedFile, fileOffset(ms.idxMappedFile, ms.baseOffset, offset))
 }
 
 func (ms *readonlySegment) Close() error {
@@ -277,7 +344,7 @@ func (r *readOnlySegmentsGroup) TrimSegments(offset int64) error {
 			err = multierr.Append(err, segment.Get().Delete())
 			r.openSegments.Remove(s)
 		} else {
-			if segment, err2 := newReadOnlySegment(r.basePath, s); err != nil {
+			if segment, err2 := newReadOnlySegment(r.basePath, s); err2 != nil {
 				err = multierr.Append(err, err2)
 			} else {
 				err = multierr.Append(err, segment.Delete())
@@ -300,7 +367,9 @@ func (r *readOnlySegmentsGroup) PollHighestSegment() (common.RefCount[ReadOnlySe
 	r.allSegments.Remove(offset)
 	segment, found := r.openSegments.Get(offset)
 	if found {
-		return segment.Acquire(), nil
+		// Hand over the reference held by the cache
+		r.openSegments.Remove(offset)
+		return segment, nil
 	}
 
 	roSegment, err := newReadOnlySegment(r.basePath, offset)
@@ -38,16 +38,16 @@ func TestReadOnlySegment(t *testing.T) {
 	assert.EqualValues(t, 9, ro.LastOffset())
 
 	for i := int64(0); i < 10; i++ {
-		data, err := ro.Read(i)
+		data, err := ro.Read(i, nil)
 		assert.NoError(t, err)
 		assert.Equal(t, fmt.Sprintf("entry-%d", i), string(data))
 	}
 
-	data, err := ro.Read(100)
+	data, err := ro.Read(100, nil)
 	assert.Nil(t, data)
 	assert.ErrorIs(t, err, ErrOffsetOutOfBounds)
 
-	data, err = ro.Read(-1)
+	data, err = ro.Read(-1, nil)
 	assert.Nil(t, data)
 	assert.ErrorIs(t, err, ErrOffsetOutOfBounds)
 
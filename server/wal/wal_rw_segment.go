@@ -16,6 +16,7 @@ package wal
 
 import (
 	"encoding/binary"
+	"hash/crc32"
 	"os"
 	"sync"
 	"time"
@@ -68,20 +69,25 @@ func newReadWriteSegment(basePath string, baseOffset int64, segmentSize uint32)
 
 	txnPath := ms.path + txnExtension
 
-	var segmentExists bool
+	// The portion of the file that can contain valid entries
+	validSize := segmentSize
 
-	if _, err = os.Stat(txnPath); os.IsNotExist(err) {
+	if stat, err := os.Stat(txnPath); os.IsNotExist(err) {
 		// The segment file does not exist yet, create file and initialize it
-		segmentExists = false
-	} else {
-		segmentExists = true
+		validSize = 0
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to stat segment file %s", txnPath)
+	} else if stat.Size() < int64(segmentSize) {
+		// The file was cut short (eg: partially copied or the disk got full). Any
+		// entry crossing the end of the file is incomplete
+		validSize = uint32(stat.Size())
 	}
 
 	if ms.txnFile, err = os.OpenFile(txnPath, os.O_CREATE|os.O_RDWR, 0644); err != nil {
 		return nil, errors.Wrapf(err, "failed to open segment file %s", txnPath)
 	}
 
-	if !segmentExists {
+	if validSize < segmentSize {
 		if err = initFileWithZeroes(ms.txnFile, segmentSize); err != nil {
 			return nil, err
 		}
@@ -91,7 +97,15 @@ func newReadWriteSegment(basePath string, baseOffset int64, segmentSize uint32)
 		return nil, errors.Wrapf(err, "failed to map segment file %s", txnPath)
 	}
 
-	if err = ms.rebuildIdx(); err != nil {
+	if err = ms.initHeader(validSize); err != nil {
+		_ = ms.txnMappedFile.Unmap()
+		_ = ms.txnFile.Close()
+		return nil, err
+	}
+
+	if err = ms.rebuildIdx(validSize); err != nil {
+		_ = ms.txnMappedFile.Unmap()
+		_ = ms.txnFile.Close()
 		return nil, errors.Wrapf(err, "failed to rebuild index for segment file %s", txnPath)
 	}
 
@@ -109,20 +123,15 @@ func (ms *readWriteSegment) LastOffset() int64 {
 	return ms.lastOffset
 }
 
-func (ms *readWriteSegment) Read(offset int64) ([]byte, error) {
+func (ms *readWriteSegment) Read(offset int64, buf []byte) ([]byte, error) {
 	ms.Lock()
 	defer ms.Unlock()
 
-	fileOffset := fileOffset(ms.writingIdx, ms.baseOffset, offset)
-	entryLen := readInt(ms.txnMappedFile, fileOffset)
-	entry := make([]byte, entryLen)
-	copy(entry, ms.txnMappedFile[fileOffset+4:fileOffset+4+entryLen])
-
-	return entry, nil
+	return readRecord(buf, ms.txnMappedFile, fileOffset(ms.writingIdx, ms.baseOffset, offset))
 }
 
 func (ms *readWriteSegment) HasSpace(l int) bool {
-	return ms.currentFileOffset+4+uint32(l) <= ms.segmentSize
+	return ms.currentFileOffset+recordHeaderSize+uint32(l) <= ms.segmentSize
 }
 
 func (ms *readWriteSegment) Append(offset int64, data []byte) error {
@@ -134,10 +143,8 @@ func (ms *readWriteSegment) Append(offset int64, data []byte) error {
 	}
 
 	entryOffset := ms.currentFileOffset
-	entrySize := uint32(len(data))
-	binary.BigEndian.PutUint32(ms.txnMappedFile[ms.currentFileOffset:], entrySize)
-	copy(ms.txnMappedFile[ms.currentFileOffset+4:], data)
-	ms.currentFileOffset += 4 + entrySize
+	writeRecord(ms.txnMappedFile, ms.currentFileOffset, data)
+	ms.currentFileOffset += recordHeaderSize + uint32(len(data))
 	ms.lastOffset = offset
 
 	ms.writingIdx = binary.BigEndian.AppendUint32(ms.writingIdx, entryOffset)
@@ -148,25 +155,37 @@ func (ms *readWriteSegment) Flush() error {
 	return ms.txnMappedFile.Flush()
 }
 
-//nolint:unparam
-func (ms *readWriteSegment) rebuildIdx() error {
-	// Scan the mapped file and rebuild the index
+// Writes the format header in a new segment, or checks the one of an
+// existing segment.
+func (ms *readWriteSegment) initHeader(validSize uint32) error {
+	if !isEmptySegment(ms.txnMappedFile[:validSize]) {
+		return checkSegmentHeader(ms.txnMappedFile, ms.path+txnExtension)
+	}
+
+	writeSegmentHeader(ms.txnMappedFile)
+	return ms.Flush()
+}
 
-	entryOffset := ms.baseOffset
+func (ms *readWriteSegment) rebuildIdx(validSize uint32) error {
+	idx, end, torn, err := scanRecords(ms.txnMappedFile, validSize)
+	if err != nil {
+		return err
+	}
 
-	for ms.currentFileOffset < ms.segmentSize {
-		size := readInt(ms.txnMappedFile, ms.currentFileOffset)
-		if size == 0 || size > (ms.segmentSize-ms.currentFileOffset) {
-			break
-		}
+	ms.writingIdx = idx
+	ms.currentFileOffset = end
+	ms.lastOffset = ms.baseOffset + int64(len(idx)/4) - 1
 
-		ms.writingIdx = binary.BigEndian.AppendUint32(ms.writingIdx, ms.currentFileOffset)
-		ms.currentFileOffset += 4 + size
-		entryOffset++
+	if !torn {
+		return nil
 	}
 
-	ms.lastOffset = entryOffset - 1
-	return nil
+	// Discard the partially written entry at the tail, so that it
+	// cannot be mistaken for a valid one after the next appends
+	for i := ms.currentFileOffset; i < validSize; i++ {
+		ms.txnMappedFile[i] = 0
+	}
+	return ms.Flush()
 }
 
 func (*readWriteSegment) OpenTimestamp() time.Time {
@@ -197,7 +216,7 @@ func (ms *readWriteSegment) Delete() error {
 func (ms *readWriteSegment) writeIndex() error {
 	idxPath := ms.path + idxExtension
 
-	idxFile, err := os.OpenFile(idxPath, os.O_CREATE|os.O_RDWR, 0644)
+	idxFile, err := os.OpenFile(idxPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
 	if err != nil {
 		return errors.Wrapf(err, "failed to open index file %s", idxPath)
 	}
@@ -217,7 +236,7 @@ func (ms *readWriteSegment) Truncate(lastSafeOffset int64) error {
 	// Write zeroes in the section to clear
 	fileLastSafeOffset := fileOffset(ms.writingIdx, ms.baseOffset, lastSafeOffset)
 	entryLen := readInt(ms.txnMappedFile, fileLastSafeOffset)
-	fileEndOffset := fileLastSafeOffset + 4 + entryLen
+	fileEndOffset := fileLastSafeOffset + recordHeaderSize + entryLen
 	for i := fileEndOffset; i < ms.currentFileOffset; i++ {
 		ms.txnMappedFile[i] = 0
 	}
@@ -230,6 +249,100 @@ func (ms *readWriteSegment) Truncate(lastSafeOffset int64) error {
 	return ms.Flush()
 }
 
+// Scans the valid portion of a segment file and rebuilds its index, returning
+// the file offset where the written data ends and whether the last record was
+// only partially written. In case of corruption, the records before it are
+// still returned.
+func scanRecords(b []byte, validSize uint32) (idx []byte, end uint32, torn bool, err error) {
+	end = segmentHeaderSize
+	for end < validSize {
+		size, valid := checkRecord(b, end, validSize)
+		if size == 0 && valid {
+			// Reached the end of the written data
+			break
+		} else if !valid {
+			// A corrupted record is only acceptable at the tail of the
+			// log, as the result of an incomplete write. Since the size
+			// itself might be corrupted, any later record is searched.
+			if next, found := findValidRecord(b, end+1, validSize); found {
+				return idx, end, false, errors.Wrapf(ErrWalCorrupted,
+					"invalid record at position %d, followed by a valid record at position %d", end, next)
+			}
+
+			return idx, end, true, nil
+		}
+
+		idx = binary.BigEndian.AppendUint32(idx, end)
+		end += recordHeaderSize + size
+	}
+
+	return idx, end, false, nil
+}
+
+// Searches a valid record starting after the given file offset. The
+// segments are filled with zeroes when created, so the written data ends at
+// the last non-zero byte. A valid record must be the start of a chain of
+// records that covers the rest of the written data, which rules out most of
+// the positions before verifying the checksum.
+func findValidRecord(b []byte, from uint32, validSize uint32) (uint32, bool) {
+	dataEnd := validSize
+	for dataEnd > from && b[dataEnd-1] == 0 {
+		dataEnd--
+	}
+
+	for pos := from; pos < dataEnd; pos++ {
+		if !isRecordChain(b, pos, dataEnd, validSize) {
+			continue
+		}
+
+		if size, valid := checkRecord(b, pos, validSize); valid && size > 0 {
+			return pos, true
+		}
+	}
+
+	return 0, false
+}
+
+func isRecordChain(b []byte, pos uint32, dataEnd uint32, validSize uint32) bool {
+	for next := uint64(pos); next < uint64(dataEnd); {
+		if next+recordHeaderSize > uint64(validSize) {
+			return false
+		}
+
+		size := readInt(b, uint32(next))
+		if size == 0 {
+			return false
+		}
+		next += recordHeaderSize + uint64(size)
+		if next > uint64(validSize) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks the record at the given file offset, returning its size and
+// whether it's fully contained in the valid portion of the file with
+// a matching checksum. A zero size record is the end of the data.
+func checkRecord(b []byte, fileOffset uint32, validSize uint32) (size uint32, valid bool) {
+	if fileOffset+recordHeaderSize > validSize {
+		return 0, false
+	}
+
+	size = readInt(b, fileOffset)
+	if size == 0 {
+		return 0, readInt(b, fileOffset+4) == 0
+	}
+
+	if size > validSize-fileOffset-recordHeaderSize {
+		return size, false
+	}
+
+	data := b[fileOffset+recordHeaderSize : fileOffset+recordHeaderSize+size]
+	return size, crc32.Checksum(data, crcTable) == readInt(b, fileOffset+4)
+}
+
 func initFileWithZeroes(f *os.File, size uint32) error {
 	if _, err := f.Seek(int64(size), 0); err != nil {
 		return err
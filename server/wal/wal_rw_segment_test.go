@@ -15,6 +15,9 @@
 package wal
 
 import (
+	"encoding/binary"
+	"fmt"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,11 +50,11 @@ func TestReadWriteSegment(t *testing.T) {
 	assert.EqualValues(t, 0, rw.BaseOffset())
 	assert.EqualValues(t, 1, rw.LastOffset())
 
-	data, err := rw.Read(0)
+	data, err := rw.Read(0, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "entry-0", string(data))
 
-	data, err = rw.Read(1)
+	data, err = rw.Read(1, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "entry-1", string(data))
 
@@ -98,14 +101,102 @@ func TestReadWriteSegment_HasSpace(t *testing.T) {
 	rw, err := newReadWriteSegment(t.TempDir(), 0, 1024)
 	assert.NoError(t, err)
 
+	// The segment header and the record header take 8 bytes each
 	assert.True(t, rw.HasSpace(10))
 	assert.False(t, rw.HasSpace(1024))
-	assert.True(t, rw.HasSpace(1020))
-	assert.False(t, rw.HasSpace(1021))
+	assert.True(t, rw.HasSpace(1008))
+	assert.False(t, rw.HasSpace(1009))
 
 	assert.NoError(t, rw.Append(0, make([]byte, 100)))
 	assert.True(t, rw.HasSpace(10))
-	assert.False(t, rw.HasSpace(1020))
-	assert.False(t, rw.HasSpace(1020-100))
-	assert.True(t, rw.HasSpace(1020-100-4))
+	assert.False(t, rw.HasSpace(1008))
+	assert.False(t, rw.HasSpace(1008-100))
+	assert.True(t, rw.HasSpace(1008-100-8))
+}
+
+func TestReadWriteSegment_TornTail(t *testing.T) {
+	// Each entry takes the record header + 7 bytes of data
+	const entries = 5
+	const entrySize = recordHeaderSize + 7
+
+	for cut := 0; cut <= segmentHeaderSize+entries*entrySize; cut++ {
+		t.Run(fmt.Sprintf("cut-%d", cut), func(t *testing.T) {
+			path := t.TempDir()
+
+			rw, err := newReadWriteSegment(path, 0, 128*1024)
+			assert.NoError(t, err)
+			for i := int64(0); i < entries; i++ {
+				assert.NoError(t, rw.Append(i, []byte(fmt.Sprintf("entry-%d", i))))
+			}
+			assert.NoError(t, rw.Close())
+
+			// Simulate a crash in the middle of writing the tail of the segment
+			assert.NoError(t, os.Truncate(segmentPath(path, 0)+txnExtension, int64(cut)))
+
+			rw, err = newReadWriteSegment(path, 0, 128*1024)
+			assert.NoError(t, err)
+
+			fullEntries := int64(max(cut-segmentHeaderSize, 0) / entrySize)
+			assert.EqualValues(t, fullEntries-1, rw.LastOffset())
+			for i := int64(0); i < fullEntries; i++ {
+				data, err := rw.Read(i, nil)
+				assert.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("entry-%d", i), string(data))
+			}
+
+			// The segment is still writable after the recovery
+			assert.NoError(t, rw.Append(fullEntries, []byte("entry-x")))
+			assert.NoError(t, rw.Close())
+
+			rw, err = newReadWriteSegment(path, 0, 128*1024)
+			assert.NoError(t, err)
+			assert.EqualValues(t, fullEntries, rw.LastOffset())
+			data, err := rw.Read(fullEntries, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "entry-x", string(data))
+			assert.NoError(t, rw.Close())
+		})
+	}
+}
+
+func TestReadWriteSegment_TruncateAndReopen(t *testing.T) {
+	path := t.TempDir()
+
+	rw, err := newReadWriteSegment(path, 0, 128*1024)
+	assert.NoError(t, err)
+	for i := int64(0); i < 10; i++ {
+		assert.NoError(t, rw.Append(i, []byte(fmt.Sprintf("entry-%d", i))))
+	}
+	assert.NoError(t, rw.Close())
+
+	rw, err = newReadWriteSegment(path, 0, 128*1024)
+	assert.NoError(t, err)
+	assert.NoError(t, rw.Truncate(4))
+	assert.NoError(t, rw.Close())
+
+	// The index written on close must not keep the truncated entries
+	ro, err := newReadOnlySegment(path, 0)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, ro.LastOffset())
+	assert.NoError(t, ro.Close())
+}
+
+func TestReadWriteSegment_UnsupportedFormat(t *testing.T) {
+	path := t.TempDir()
+
+	// A segment written before the format header, with [size][data] records
+	data := make([]byte, 1024)
+	binary.BigEndian.PutUint32(data, 7)
+	copy(data[4:], "entry-0")
+	txnPath := segmentPath(path, 0) + txnExtension
+	assert.NoError(t, os.WriteFile(txnPath, data, 0644))
+
+	rw, err := newReadWriteSegment(path, 0, 1024)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	assert.Nil(t, rw)
+
+	// The existing data is left untouched
+	current, err := os.ReadFile(txnPath)
+	assert.NoError(t, err)
+	assert.Equal(t, data, current)
 }
@@ -17,6 +17,9 @@ package wal
 import (
 	"context"
 	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -37,7 +40,7 @@ func NewTestWalFactory(t *testing.T) Factory {
 		BaseWalDir:  dir,
 		Retention:   1 * time.Hour,
 		SegmentSize: 128 * 1024,
-		SyncData:    true,
+		SyncPolicy:  SyncPolicyAlways,
 	})
 }
 
@@ -208,6 +211,57 @@ func TestAppendAsync(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestFirstAndLastEntry(t *testing.T) {
+	f, w := createWal(t)
+
+	invalidEntryId := &proto.EntryId{Term: InvalidTerm, Offset: InvalidOffset}
+
+	entryId, err := w.FirstEntry()
+	assert.ErrorIs(t, err, ErrEmptyWal)
+	assert.Equal(t, invalidEntryId, entryId)
+	entryId, err = w.LastEntry()
+	assert.ErrorIs(t, err, ErrEmptyWal)
+	assert.Equal(t, invalidEntryId, entryId)
+
+	for i := int64(0); i < 5; i++ {
+		assert.NoError(t, w.Append(&proto.LogEntry{
+			Term:   1 + i/2,
+			Offset: i,
+			Value:  []byte(fmt.Sprintf("entry-%d", i)),
+		}))
+	}
+
+	entryId, err = w.FirstEntry()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), entryId.Term)
+	assert.Equal(t, int64(0), entryId.Offset)
+	entryId, err = w.LastEntry()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), entryId.Term)
+	assert.Equal(t, int64(4), entryId.Offset)
+
+	lastOffset, err := w.TruncateLog(2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), lastOffset)
+
+	entryId, err = w.LastEntry()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), entryId.Term)
+	assert.Equal(t, int64(2), entryId.Offset)
+
+	assert.NoError(t, w.Clear())
+
+	entryId, err = w.FirstEntry()
+	assert.ErrorIs(t, err, ErrEmptyWal)
+	assert.Equal(t, invalidEntryId, entryId)
+	entryId, err = w.LastEntry()
+	assert.ErrorIs(t, err, ErrEmptyWal)
+	assert.Equal(t, invalidEntryId, entryId)
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
 func TestRollover(t *testing.T) {
 	f, w := createWal(t)
 
@@ -357,6 +411,226 @@ func TestReopen(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestRecoverTornTail(t *testing.T) {
+	for _, partial := range []int64{0, 1, 3, 4, 100, 1000} {
+		t.Run(fmt.Sprintf("partial-%d", partial), func(t *testing.T) {
+			f, w := createWal(t)
+			entries := appendLargeEntries(t, w, 300)
+			assert.NoError(t, w.Close())
+
+			segments, err := listAllSegments(walPath(f.(*walFactory).options.BaseWalDir, common.DefaultNamespace, shard))
+			assert.NoError(t, err)
+			assert.Greater(t, len(segments), 1)
+			lastSegment := segments[len(segments)-1]
+
+			// Keep 10 entries in the last segment, plus a partially written one
+			recordSize := int64(recordHeaderSize + entries[lastSegment].SizeVT())
+			txnPath := segmentPath(walPath(f.(*walFactory).options.BaseWalDir, common.DefaultNamespace, shard), lastSegment) + txnExtension
+			assert.NoError(t, os.Truncate(txnPath, segmentHeaderSize+10*recordSize+partial))
+
+			w, err = f.NewWal(common.DefaultNamespace, shard, nil)
+			assert.NoError(t, err)
+
+			expectedLastOffset := lastSegment + 9
+			assert.EqualValues(t, 0, w.FirstOffset())
+			assert.EqualValues(t, expectedLastOffset, w.LastOffset())
+
+			r, err := w.NewReader(InvalidOffset)
+			assert.NoError(t, err)
+			for i := int64(0); i <= expectedLastOffset; i++ {
+				assert.True(t, r.HasNext())
+				le, err := r.ReadNext()
+				assert.NoError(t, err)
+				assert.Equal(t, entries[i].Value, le.Value)
+			}
+			assert.False(t, r.HasNext())
+			assert.NoError(t, r.Close())
+
+			// The wal accepts new entries after the recovered tail
+			assert.NoError(t, w.Append(&proto.LogEntry{Term: 2, Offset: expectedLastOffset + 1, Value: []byte("new")}))
+			assert.NoError(t, w.Close())
+
+			w, err = f.NewWal(common.DefaultNamespace, shard, nil)
+			assert.NoError(t, err)
+			assert.EqualValues(t, expectedLastOffset+1, w.LastOffset())
+
+			assert.NoError(t, w.Close())
+			assert.NoError(t, f.Close())
+		})
+	}
+}
+
+func TestCorruptedRecords(t *testing.T) {
+	type testCase struct {
+		name string
+		// Returns the segment and the position in it to corrupt
+		position func(segments []int64, recordSize int64) (int64, int64)
+		// The expected last offset after reopening, or InvalidOffset if
+		// the wal should not be opened
+		expectedLastOffset func(segments []int64) int64
+	}
+
+	lastRecordInLastSegment := func(segments []int64, recordSize int64) (int64, int64) {
+		lastSegment := segments[len(segments)-1]
+		return lastSegment, segmentHeaderSize + (299-lastSegment)*recordSize + recordHeaderSize + 10
+	}
+
+	for _, test := range []testCase{
+		{
+			name:               "tail-record-data",
+			position:           lastRecordInLastSegment,
+			expectedLastOffset: func([]int64) int64 { return 298 },
+		},
+		{
+			name: "tail-record-checksum",
+			position: func(segments []int64, recordSize int64) (int64, int64) {
+				lastSegment := segments[len(segments)-1]
+				return lastSegment, segmentHeaderSize + (299-lastSegment)*recordSize + 5
+			},
+			expectedLastOffset: func([]int64) int64 { return 298 },
+		},
+		{
+			name: "tail-record-size",
+			position: func(segments []int64, recordSize int64) (int64, int64) {
+				lastSegment := segments[len(segments)-1]
+				return lastSegment, segmentHeaderSize + (299-lastSegment)*recordSize + 1
+			},
+			expectedLastOffset: func([]int64) int64 { return 298 },
+		},
+		{
+			name: "interior-record-data",
+			position: func(segments []int64, recordSize int64) (int64, int64) {
+				return segments[len(segments)-1], segmentHeaderSize + 5*recordSize + recordHeaderSize + 10
+			},
+			expectedLastOffset: func([]int64) int64 { return InvalidOffset },
+		},
+		{
+			// The size points past the end of the segment
+			name: "interior-record-size",
+			position: func(segments []int64, recordSize int64) (int64, int64) {
+				return segments[len(segments)-1], segmentHeaderSize + 5*recordSize + 1
+			},
+			expectedLastOffset: func([]int64) int64 { return InvalidOffset },
+		},
+		{
+			// The size points in the middle of the next records
+			name: "interior-record-size-low-byte",
+			position: func(segments []int64, recordSize int64) (int64, int64) {
+				return segments[len(segments)-1], segmentHeaderSize + 5*recordSize + 3
+			},
+			expectedLastOffset: func([]int64) int64 { return InvalidOffset },
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			f, w := createWal(t)
+			entries := appendLargeEntries(t, w, 300)
+			assert.NoError(t, w.Close())
+
+			dir := walPath(f.(*walFactory).options.BaseWalDir, common.DefaultNamespace, shard)
+			segments, err := listAllSegments(dir)
+			assert.NoError(t, err)
+			assert.Greater(t, len(segments), 1)
+
+			recordSize := int64(recordHeaderSize + entries[segments[len(segments)-1]].SizeVT())
+			segment, position := test.position(segments, recordSize)
+			flipByte(t, segmentPath(dir, segment)+txnExtension, position)
+
+			w, err = f.NewWal(common.DefaultNamespace, shard, nil)
+			expectedLastOffset := test.expectedLastOffset(segments)
+			if expectedLastOffset == InvalidOffset {
+				assert.ErrorIs(t, err, ErrWalCorrupted)
+				assert.Nil(t, w)
+				assert.NoError(t, f.Close())
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.EqualValues(t, expectedLastOffset, w.LastOffset())
+
+			r, err := w.NewReader(InvalidOffset)
+			assert.NoError(t, err)
+			for i := int64(0); i <= expectedLastOffset; i++ {
+				le, err := r.ReadNext()
+				assert.NoError(t, err)
+				assert.Equal(t, entries[i].Value, le.Value)
+			}
+			assert.False(t, r.HasNext())
+			assert.NoError(t, r.Close())
+
+			assert.NoError(t, w.Close())
+			assert.NoError(t, f.Close())
+		})
+	}
+}
+
+func TestCorruptedReadOnlySegment(t *testing.T) {
+	f, w := createWal(t)
+	entries := appendLargeEntries(t, w, 300)
+	assert.NoError(t, w.Close())
+
+	dir := walPath(f.(*walFactory).options.BaseWalDir, common.DefaultNamespace, shard)
+	segments, err := listAllSegments(dir)
+	assert.NoError(t, err)
+	assert.Greater(t, len(segments), 1)
+
+	// Corrupt the data of the entry at offset 5, in the first segment
+	recordSize := int64(recordHeaderSize + entries[5].SizeVT())
+	flipByte(t, segmentPath(dir, 0)+txnExtension, segmentHeaderSize+5*recordSize+recordHeaderSize+10)
+
+	// The read-only segments are not scanned when opening the wal
+	w, err = f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 299, w.LastOffset())
+
+	r, err := w.NewReader(InvalidOffset)
+	assert.NoError(t, err)
+	for i := int64(0); i < 5; i++ {
+		le, err := r.ReadNext()
+		assert.NoError(t, err)
+		assert.Equal(t, entries[i].Value, le.Value)
+	}
+
+	le, err := r.ReadNext()
+	assert.ErrorIs(t, err, ErrWalCorrupted)
+	assert.Nil(t, le)
+	assert.NoError(t, r.Close())
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+func appendLargeEntries(t *testing.T, w Wal, count int) []*proto.LogEntry {
+	t.Helper()
+
+	entries := make([]*proto.LogEntry, count)
+	for i := 0; i < count; i++ {
+		value := make([]byte, 1024)
+		copy(value, fmt.Sprintf("entry-%d", i))
+		entries[i] = &proto.LogEntry{
+			Term:   1,
+			Offset: int64(i),
+			Value:  value,
+		}
+		assert.NoError(t, w.Append(entries[i]))
+	}
+	return entries
+}
+
+func flipByte(t *testing.T, path string, position int64) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	assert.NoError(t, err)
+
+	b := make([]byte, 1)
+	_, err = file.ReadAt(b, position)
+	assert.NoError(t, err)
+	b[0] ^= 0xff
+	_, err = file.WriteAt(b, position)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+}
+
 func TestClear(t *testing.T) {
 	f, w := createWal(t)
 
@@ -482,9 +756,223 @@ func TestTrim(t *testing.T) {
 
 	// Test reading a trimmed offset
 	r, err = w.NewReader(48)
-	assert.ErrorIs(t, err, ErrEntryNotFound)
+	assert.ErrorIs(t, err, ErrEntryTrimmed)
+	assert.Nil(t, r)
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestTrimAndReopen(t *testing.T) {
+	f, w := createWal(t)
+
+	for i := 0; i < 300; i++ {
+		value := make([]byte, 1024)
+		copy(value, fmt.Sprintf("entry-%d", i))
+		assert.NoError(t, w.Append(&proto.LogEntry{
+			Term:   1,
+			Offset: int64(i),
+			Value:  value,
+		}))
+	}
+
+	assert.NoError(t, w.(*wal).trim(200))
+	assert.EqualValues(t, 200, w.FirstOffset())
+	assert.NoError(t, w.Close())
+
+	w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+
+	// After the restart, the wal starts from the first segment that was kept
+	firstOffset := w.FirstOffset()
+	assert.Greater(t, firstOffset, int64(0))
+	assert.LessOrEqual(t, firstOffset, int64(200))
+	assert.EqualValues(t, 299, w.LastOffset())
+
+	r, err := w.NewReader(InvalidOffset)
+	assert.ErrorIs(t, err, ErrEntryTrimmed)
 	assert.Nil(t, r)
 
+	r, err = w.NewReader(firstOffset - 1)
+	assert.NoError(t, err)
+	for i := firstOffset; i < 300; i++ {
+		assert.True(t, r.HasNext())
+		le, err := r.ReadNext()
+		assert.NoError(t, err)
+		assert.EqualValues(t, i, le.Offset)
+	}
+	assert.False(t, r.HasNext())
+	assert.NoError(t, r.Close())
+
+	// Truncating must still work when the log does not start at 0
+	lastOffset, err := w.TruncateLog(250)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 250, lastOffset)
+	assert.EqualValues(t, firstOffset, w.FirstOffset())
+	assert.EqualValues(t, 250, w.LastOffset())
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestTrimWithConcurrentReads(t *testing.T) {
+	f, w := createWal(t)
+
+	for i := 0; i < 1000; i++ {
+		value := make([]byte, 1024)
+		copy(value, fmt.Sprintf("entry-%d", i))
+		assert.NoError(t, w.Append(&proto.LogEntry{
+			Term:   1,
+			Offset: int64(i),
+			Value:  value,
+		}))
+	}
+
+	done := make(chan any)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			r, err := w.NewReader(w.FirstOffset() - 1)
+			if errors.Is(err, ErrEntryTrimmed) {
+				// The wal was trimmed in the meantime
+				continue
+			}
+			assert.NoError(t, err)
+
+			for r.HasNext() {
+				le, err := r.ReadNext()
+				if errors.Is(err, ErrEntryTrimmed) {
+					break
+				}
+				assert.NoError(t, err)
+				value := make([]byte, 1024)
+				copy(value, fmt.Sprintf("entry-%d", le.Offset))
+				assert.Equal(t, value, le.Value)
+			}
+			assert.NoError(t, r.Close())
+		}
+	}()
+
+	for i := int64(0); i < 1000; i += 50 {
+		assert.NoError(t, w.(*wal).trim(i))
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	close(done)
+	wg.Wait()
+
+	assert.EqualValues(t, 950, w.FirstOffset())
+	assert.EqualValues(t, 999, w.LastOffset())
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestReaderWithConcurrentAppends(t *testing.T) {
+	f, w := createWal(t)
+
+	r, err := w.NewReader(InvalidOffset)
+	assert.NoError(t, err)
+
+	// At the tail, the reader waits for more entries to be synced
+	assert.False(t, r.HasNext())
+	_, err = r.ReadNext()
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+
+	const count = 1000
+	go func() {
+		for i := 0; i < count; i++ {
+			assert.NoError(t, w.Append(&proto.LogEntry{
+				Term:   1,
+				Offset: int64(i),
+				Value:  []byte(fmt.Sprintf("entry-%d", i)),
+			}))
+		}
+	}()
+
+	for i := 0; i < count; i++ {
+		assert.Eventually(t, r.HasNext, 10*time.Second, 1*time.Millisecond)
+		le, err := r.ReadNext()
+		assert.NoError(t, err)
+		assert.EqualValues(t, i, le.Offset)
+		assert.Equal(t, fmt.Sprintf("entry-%d", i), string(le.Value))
+	}
+
+	assert.False(t, r.HasNext())
+	assert.NoError(t, r.Close())
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestReaderTruncated(t *testing.T) {
+	f, w := createWal(t)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, w.Append(&proto.LogEntry{
+			Term:   1,
+			Offset: int64(i),
+			Value:  []byte(fmt.Sprintf("entry-%d", i)),
+		}))
+	}
+
+	// A reader that has not gone past the truncation point is not affected
+	behind, err := w.NewReader(InvalidOffset)
+	assert.NoError(t, err)
+	for i := 0; i <= 5; i++ {
+		le, err := behind.ReadNext()
+		assert.NoError(t, err)
+		assert.EqualValues(t, i, le.Offset)
+	}
+
+	ahead, err := w.NewReader(6)
+	assert.NoError(t, err)
+	le, err := ahead.ReadNext()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, le.Offset)
+
+	lastOffset, err := w.TruncateLog(5)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, lastOffset)
+
+	// The entries replaced in the new term must not be read as a
+	// continuation of the old ones
+	for i := 6; i < 10; i++ {
+		assert.NoError(t, w.Append(&proto.LogEntry{
+			Term:   2,
+			Offset: int64(i),
+			Value:  []byte(fmt.Sprintf("new-entry-%d", i)),
+		}))
+	}
+
+	assert.True(t, ahead.HasNext())
+	_, err = ahead.ReadNext()
+	assert.ErrorIs(t, err, ErrReaderTruncated)
+	_, err = ahead.ReadNext()
+	assert.ErrorIs(t, err, ErrReaderTruncated)
+	assert.NoError(t, ahead.Close())
+
+	assertReaderReads(t, behind, []string{"new-entry-6", "new-entry-7", "new-entry-8", "new-entry-9"})
+	assert.NoError(t, behind.Close())
+
+	// Clearing the wal invalidates the readers as well
+	r, err := w.NewReader(7)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Clear())
+	_, err = r.ReadNext()
+	assert.ErrorIs(t, err, ErrReaderTruncated)
+	assert.NoError(t, r.Close())
+
 	assert.NoError(t, w.Close())
 	assert.NoError(t, f.Close())
 }
@@ -514,3 +1002,331 @@ func TestDelete(t *testing.T) {
 	assert.NoError(t, w.Close())
 	assert.NoError(t, f.Close())
 }
+
+func TestAppendAndSyncGroupCommit(t *testing.T) {
+	f, w := createWal(t)
+
+	n := 500
+	acks := make(chan int64, n)
+	for i := 0; i < n; i++ {
+		offset := int64(i)
+		w.AppendAndSync(&proto.LogEntry{
+			Term:   1,
+			Offset: offset,
+			Value:  []byte(fmt.Sprintf("entry-%d", i)),
+		}, func(err error) {
+			assert.NoError(t, err)
+
+			// An entry is acked only once it's covered by a sync
+			assert.LessOrEqual(t, offset, w.LastOffset())
+			acks <- offset
+		})
+	}
+
+	for i := 0; i < n; i++ {
+		<-acks
+	}
+	assert.EqualValues(t, n-1, w.LastOffset())
+	assert.NoError(t, w.Close())
+
+	// All the acked entries must be there after reopening
+	w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, n-1, w.LastOffset())
+
+	r, err := w.NewReader(InvalidOffset)
+	assert.NoError(t, err)
+	for i := 0; i < n; i++ {
+		assert.True(t, r.HasNext())
+		e, err := r.ReadNext()
+		assert.NoError(t, err)
+		assert.EqualValues(t, i, e.Offset)
+	}
+	assert.False(t, r.HasNext())
+	assert.NoError(t, r.Close())
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+func BenchmarkAppend(b *testing.B) {
+	f := NewWalFactory(&FactoryOptions{BaseWalDir: b.TempDir(), SyncPolicy: SyncPolicyAlways})
+	w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(b, err)
+	value := make([]byte, 1024)
+	b.ResetTimer()
+
+	// One sync for each entry
+	for i := 0; i < b.N; i++ {
+		assert.NoError(b, w.Append(&proto.LogEntry{Term: 1, Offset: int64(i), Value: value}))
+	}
+
+	b.StopTimer()
+	assert.NoError(b, w.Close())
+	assert.NoError(b, f.Close())
+}
+
+func BenchmarkAppendAndSync(b *testing.B) {
+	f := NewWalFactory(&FactoryOptions{BaseWalDir: b.TempDir(), SyncPolicy: SyncPolicyAlways})
+	w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(b, err)
+	value := make([]byte, 1024)
+	wg := sync.WaitGroup{}
+	wg.Add(b.N)
+	b.ResetTimer()
+
+	// Pending sync requests are coalesced into a single sync
+	for i := 0; i < b.N; i++ {
+		w.AppendAndSync(&proto.LogEntry{Term: 1, Offset: int64(i), Value: value}, func(err error) {
+			assert.NoError(b, err)
+			wg.Done()
+		})
+	}
+	wg.Wait()
+
+	b.StopTimer()
+	assert.NoError(b, w.Close())
+	assert.NoError(b, f.Close())
+}
+
+func TestPeriodicSync(t *testing.T) {
+	f := NewWalFactory(&FactoryOptions{
+		BaseWalDir:   t.TempDir(),
+		SegmentSize:  128 * 1024,
+		SyncPolicy:   SyncPolicyInterval,
+		SyncInterval: 10 * time.Millisecond,
+	})
+	w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+
+	// Sync returns immediately, without waiting for the data to be
+	// flushed on disk
+	input := []string{"A", "B", "C", "D", "E"}
+	for i, s := range input {
+		assert.NoError(t, w.AppendAsync(&proto.LogEntry{
+			Term:   1,
+			Offset: int64(i),
+			Value:  []byte(s),
+		}))
+		assert.NoError(t, w.Sync(context.Background()))
+		assert.EqualValues(t, i, w.LastOffset())
+	}
+
+	// Let the background sync run a few times
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, w.Close())
+
+	w, err = f.NewWal(common.DefaultNamespace, shard, nil)
+	assert.NoError(t, err)
+
+	fr, err := w.NewReader(InvalidOffset)
+	assert.NoError(t, err)
+	assertReaderReads(t, fr, input)
+	assert.NoError(t, fr.Close())
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+// faultySegment tracks the data flushed in the segment, so that the
+// data that was not flushed can be discarded to simulate a crash.
+type faultySegment struct {
+	ReadWriteSegment
+	txnPath string
+	crashed *atomic.Bool
+
+	// The file positions up to which the data was written and flushed
+	written atomic.Int64
+	flushed atomic.Int64
+}
+
+func (s *faultySegment) Append(offset int64, data []byte) error {
+	if err := s.ReadWriteSegment.Append(offset, data); err != nil {
+		return err
+	}
+	s.written.Add(recordHeaderSize + int64(len(data)))
+	return nil
+}
+
+func (s *faultySegment) Flush() error {
+	written := s.written.Load()
+	if err := s.ReadWriteSegment.Flush(); err != nil {
+		return err
+	}
+	if !s.crashed.Load() {
+		s.flushed.Store(written)
+	}
+	return nil
+}
+
+type faultInjector struct {
+	sync.Mutex
+	crashed  atomic.Bool
+	segments []*faultySegment
+}
+
+// injectFaults wraps the segments opened by the wal until the end of the
+// test. The wal must be new, since the segments are expected to be empty.
+func injectFaults(t *testing.T) *faultInjector {
+	t.Helper()
+
+	fi := &faultInjector{}
+	open := openReadWriteSegment
+	openReadWriteSegment = func(basePath string, baseOffset int64, segmentSize uint32) (ReadWriteSegment, error) {
+		segment, err := open(basePath, baseOffset, segmentSize)
+		if err != nil {
+			return nil, err
+		}
+
+		fs := &faultySegment{
+			ReadWriteSegment: segment,
+			txnPath:          segmentPath(basePath, baseOffset) + txnExtension,
+			crashed:          &fi.crashed,
+		}
+		fs.written.Store(segmentHeaderSize)
+		fs.flushed.Store(segmentHeaderSize)
+
+		fi.Lock()
+		defer fi.Unlock()
+		fi.segments = append(fi.segments, fs)
+		return fs, nil
+	}
+	t.Cleanup(func() {
+		openReadWriteSegment = open
+	})
+	return fi
+}
+
+// crash closes the wal, discarding all the data that was not flushed.
+func (fi *faultInjector) crash(t *testing.T, w Wal) {
+	t.Helper()
+
+	fi.crashed.Store(true)
+	assert.NoError(t, w.Close())
+
+	fi.Lock()
+	defer fi.Unlock()
+	for _, s := range fi.segments {
+		info, err := os.Stat(s.txnPath)
+		assert.NoError(t, err)
+
+		f, err := os.OpenFile(s.txnPath, os.O_WRONLY, 0)
+		assert.NoError(t, err)
+		_, err = f.WriteAt(make([]byte, info.Size()-s.flushed.Load()), s.flushed.Load())
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+	}
+}
+
+func (fi *faultInjector) lastSegment() *faultySegment {
+	fi.Lock()
+	defer fi.Unlock()
+	return fi.segments[len(fi.segments)-1]
+}
+
+func TestCrashRecovery(t *testing.T) {
+	const entries = 30
+
+	for _, test := range []struct {
+		name         string
+		syncPolicy   SyncPolicy
+		syncInterval time.Duration
+		segmentSize  int32
+
+		// Whether to wait for the background sync before crashing
+		waitForSync bool
+
+		// The entries that must survive the crash
+		expectedLastOffset func(fi *faultInjector) int64
+	}{
+		{
+			// All the acknowledged entries are recovered
+			name:        "always",
+			syncPolicy:  SyncPolicyAlways,
+			segmentSize: 1024,
+			expectedLastOffset: func(*faultInjector) int64 {
+				return entries - 1
+			},
+		},
+		{
+			// The entries are only flushed when the segments are rolled over
+			name:         "interval-before-sync",
+			syncPolicy:   SyncPolicyInterval,
+			syncInterval: 1 * time.Hour,
+			segmentSize:  1024,
+			expectedLastOffset: func(fi *faultInjector) int64 {
+				return fi.lastSegment().BaseOffset() - 1
+			},
+		},
+		{
+			name:         "interval-after-sync",
+			syncPolicy:   SyncPolicyInterval,
+			syncInterval: 10 * time.Millisecond,
+			segmentSize:  1024,
+			waitForSync:  true,
+			expectedLastOffset: func(*faultInjector) int64 {
+				return entries - 1
+			},
+		},
+		{
+			// None of the entries were flushed
+			name:        "never",
+			syncPolicy:  SyncPolicyNever,
+			segmentSize: 128 * 1024,
+			expectedLastOffset: func(*faultInjector) int64 {
+				return InvalidOffset
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fi := injectFaults(t)
+			f := NewWalFactory(&FactoryOptions{
+				BaseWalDir:   t.TempDir(),
+				SegmentSize:  test.segmentSize,
+				SyncPolicy:   test.syncPolicy,
+				SyncInterval: test.syncInterval,
+			})
+			w, err := f.NewWal(common.DefaultNamespace, shard, nil)
+			assert.NoError(t, err)
+
+			var input []string
+			for i := 0; i < entries; i++ {
+				input = append(input, fmt.Sprintf("entry-%d-%0100d", i, 0))
+				assert.NoError(t, w.Append(&proto.LogEntry{
+					Term:   1,
+					Offset: int64(i),
+					Value:  []byte(input[i]),
+				}))
+			}
+
+			if test.waitForSync {
+				assert.Eventually(t, func() bool {
+					s := fi.lastSegment()
+					return s.flushed.Load() == s.written.Load()
+				}, 10*time.Second, 10*time.Millisecond)
+			}
+
+			expectedLastOffset := test.expectedLastOffset(fi)
+			fi.crash(t, w)
+
+			w, err = f.NewWal(common.DefaultNamespace, shard, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedLastOffset, w.LastOffset())
+
+			r, err := w.NewReader(InvalidOffset)
+			assert.NoError(t, err)
+			assertReaderReads(t, r, input[:expectedLastOffset+1])
+			assert.NoError(t, r.Close())
+
+			// The wal can be appended to after the recovery
+			assert.NoError(t, w.Append(&proto.LogEntry{
+				Term:   2,
+				Offset: expectedLastOffset + 1,
+				Value:  []byte("entry-x"),
+			}))
+
+			assert.NoError(t, w.Close())
+			assert.NoError(t, f.Close())
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/streamnative/oxia/common/metrics"
+	"github.com/streamnative/oxia/proto"
+)
+
+// WriteRateLimitOptions configures the budget of the write requests received
+// on the public service. A value of 0 means that there is no limit.
+type WriteRateLimitOptions struct {
+	// The budget shared by all the shards in the server
+	RequestsPerSecond float64
+	BytesPerSecond    float64
+
+	// The budget of each shard
+	ShardRequestsPerSecond float64
+	ShardBytesPerSecond    float64
+}
+
+func (o WriteRateLimitOptions) enabled() bool {
+	return o.RequestsPerSecond > 0 || o.BytesPerSecond > 0 ||
+		o.ShardRequestsPerSecond > 0 || o.ShardBytesPerSecond > 0
+}
+
+// WriteRateLimiter decides whether a write request can be accepted, before
+// it's appended to the shard log.
+type WriteRateLimiter interface {
+	// Allow returns an error with code ResourceExhausted if the write cannot
+	// be accepted now. The error carries a hint of when to retry.
+	Allow(namespace string, shard int64, write *proto.WriteRequest) error
+
+	// RemoveShard drops the budget and the metrics of a shard that is no
+	// longer hosted on this node.
+	RemoveShard(shard int64)
+}
+
+// NewWriteRateLimiter creates a token-bucket rate limiter, or a limiter that
+// accepts every write if no limit is configured.
+func NewWriteRateLimiter(options WriteRateLimitOptions) WriteRateLimiter {
+	if !options.enabled() {
+		return &noOpWriteRateLimiter{}
+	}
+
+	return &writeRateLimiter{
+		options:  options,
+		requests: newTokenBucket(options.RequestsPerSecond),
+		bytes:    newTokenBucket(options.BytesPerSecond),
+		shards:   make(map[int64]*shardRateLimiter),
+	}
+}
+
+type noOpWriteRateLimiter struct{}
+
+func (*noOpWriteRateLimiter) Allow(string, int64, *proto.WriteRequest) error {
+	return nil
+}
+
+func (*noOpWriteRateLimiter) RemoveShard(int64) {}
+
+type shardRateLimiter struct {
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+
+	rejectedCounter metrics.RemovableCounter
+}
+
+type writeRateLimiter struct {
+	sync.Mutex
+
+	options  WriteRateLimitOptions
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+	shards   map[int64]*shardRateLimiter
+}
+
+func (r *writeRateLimiter) Allow(namespace string, shard int64, write *proto.WriteRequest) error {
+	size := write.SizeVT()
+
+	r.Lock()
+	defer r.Unlock()
+
+	sl := r.getShardLimiter(namespace, shard)
+
+	// The write must fit in all the budgets, or none of them is consumed
+	now := time.Now()
+	reservations := []*rate.Reservation{
+		reserve(r.requests, now, 1),
+		reserve(r.bytes, now, size),
+		reserve(sl.requests, now, 1),
+		reserve(sl.bytes, now, size),
+	}
+
+	var retryAfter time.Duration
+	for _, reservation := range reservations {
+		retryAfter = max(retryAfter, reservation.DelayFrom(now))
+	}
+
+	if retryAfter == 0 {
+		return nil
+	}
+
+	for _, reservation := range reservations {
+		reservation.CancelAt(now)
+	}
+
+	sl.rejectedCounter.Inc()
+	return newRateLimitedError(shard, retryAfter)
+}
+
+func (r *writeRateLimiter) getShardLimiter(namespace string, shard int64) *shardRateLimiter {
+	sl, ok := r.shards[shard]
+	if !ok {
+		sl = &shardRateLimiter{
+			requests: newTokenBucket(r.options.ShardRequestsPerSecond),
+			bytes:    newTokenBucket(r.options.ShardBytesPerSecond),
+			rejectedCounter: metrics.NewRemovableCounter("oxia_server_write_rate_limited",
+				"The number of write requests rejected by the rate limiter", "count",
+				metrics.LabelsForShard(namespace, shard)),
+		}
+		r.shards[shard] = sl
+	}
+	return sl
+}
+
+func (r *writeRateLimiter) RemoveShard(shard int64) {
+	r.Lock()
+	defer r.Unlock()
+
+	if sl, ok := r.shards[shard]; ok {
+		sl.rejectedCounter.Unregister()
+		delete(r.shards, shard)
+	}
+}
+
+// The bucket can hold one second worth of tokens.
+func newTokenBucket(perSecond float64) *rate.Limiter {
+	if perSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	return rate.NewLimiter(rate.Limit(perSecond), int(math.Max(1, perSecond)))
+}
+
+// A request bigger than the bucket can only be accepted when the bucket is
+// full, otherwise it would never fit.
+func reserve(limiter *rate.Limiter, now time.Time, n int) *rate.Reservation {
+	return limiter.ReserveN(now, min(n, limiter.Burst()))
+}
+
+func newRateLimitedError(shard int64, retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("oxia: write rate limit exceeded on shard %d", shard))
+	if withDetails, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/proto"
+)
+
+func TestWriteRateLimiter_NoOp(t *testing.T) {
+	rl := NewWriteRateLimiter(WriteRateLimitOptions{})
+	assert.IsType(t, &noOpWriteRateLimiter{}, rl)
+
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, rl.Allow(common.DefaultNamespace, 0, &proto.WriteRequest{}))
+	}
+}
+
+func TestWriteRateLimiter_ShardRequests(t *testing.T) {
+	rl := NewWriteRateLimiter(WriteRateLimitOptions{ShardRequestsPerSecond: 10})
+
+	rejected := 0
+	for i := 0; i < 100; i++ {
+		err := rl.Allow(common.DefaultNamespace, 0, &proto.WriteRequest{})
+		if err != nil {
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+			assertRetryInfo(t, err)
+			rejected++
+		}
+	}
+
+	// Only the requests that fit in the bucket are accepted
+	assert.GreaterOrEqual(t, rejected, 80)
+
+	// The other shards have their own budget
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, rl.Allow(common.DefaultNamespace, 1, &proto.WriteRequest{}))
+	}
+}
+
+func TestWriteRateLimiter_Bytes(t *testing.T) {
+	rl := NewWriteRateLimiter(WriteRateLimitOptions{BytesPerSecond: 1024})
+
+	write := &proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "a", Value: make([]byte, 600)}},
+	}
+
+	assert.NoError(t, rl.Allow(common.DefaultNamespace, 0, write))
+
+	// The server budget is shared by all the shards
+	err := rl.Allow(common.DefaultNamespace, 1, write)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// A request bigger than the bucket has to wait for the bucket to be full
+	big := &proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "a", Value: make([]byte, 4096)}},
+	}
+	err = rl.Allow(common.DefaultNamespace, 1, big)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assertRetryInfo(t, err)
+}
+
+func TestWriteRateLimiter_RemoveShard(t *testing.T) {
+	namespace := "rate-limiter-remove-shard"
+	rl := NewWriteRateLimiter(WriteRateLimitOptions{ShardRequestsPerSecond: 1})
+
+	for shard := int64(0); shard < 2; shard++ {
+		assert.NoError(t, rl.Allow(namespace, shard, &proto.WriteRequest{}))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(rl.Allow(namespace, shard, &proto.WriteRequest{})))
+	}
+	assert.Equal(t, map[string]float64{"0": 1, "1": 1}, gatherShardCounters(t, "oxia_server_write_rate_limited_total", namespace))
+
+	// The removed shard has no budget nor series left
+	rl.RemoveShard(0)
+	assert.Len(t, rl.(*writeRateLimiter).shards, 1)
+	assert.Equal(t, map[string]float64{"1": 1}, gatherShardCounters(t, "oxia_server_write_rate_limited_total", namespace))
+
+	// A shard that comes back starts with a new budget
+	assert.NoError(t, rl.Allow(namespace, 0, &proto.WriteRequest{}))
+
+	rl.RemoveShard(0)
+	rl.RemoveShard(1)
+	assert.Empty(t, rl.(*writeRateLimiter).shards)
+	assert.Empty(t, gatherShardCounters(t, "oxia_server_write_rate_limited_total", namespace))
+}
+
+func TestWriteRateLimiter_PublicService(t *testing.T) {
+	config := NewTestConfig(t.TempDir())
+	config.NumShards = 2
+	config.WriteRateLimit = WriteRateLimitOptions{ShardRequestsPerSecond: 5}
+
+	standalone, err := NewStandalone(config)
+	assert.NoError(t, err)
+
+	cnx, err := grpc.NewClient(fmt.Sprintf("localhost:%d", standalone.RpcPort()),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	client := proto.NewOxiaClientClient(cnx)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), common.MetadataNamespace, common.DefaultNamespace)
+	write := func(shard int64) error {
+		_, err := client.Write(ctx, &proto.WriteRequest{
+			Shard: &shard,
+			Puts:  []*proto.PutRequest{{Key: "key", Value: []byte("value")}},
+		})
+		return err
+	}
+
+	// Sustained over-limit traffic on shard 0 is rejected
+	rejected := 0
+	for i := 0; i < 50; i++ {
+		if err := write(0); err != nil {
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+			rejected++
+		}
+	}
+	assert.Greater(t, rejected, 0)
+
+	// Shard 1 is not affected
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, write(1))
+	}
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, standalone.Close())
+}
+
+func gatherShardCounters(t *testing.T, name string, namespace string) map[string]float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["oxia_namespace"] == namespace {
+				values[labels["shard"]] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+func assertRetryInfo(t *testing.T, err error) {
+	t.Helper()
+
+	details := status.Convert(err).Details()
+	assert.Len(t, details, 1)
+	if retryInfo, ok := details[0].(*errdetails.RetryInfo); assert.True(t, ok) {
+		assert.Positive(t, retryInfo.RetryDelay.AsDuration())
+	}
+}
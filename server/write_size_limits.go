@@ -0,0 +1,122 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common/container"
+	"github.com/streamnative/oxia/proto"
+)
+
+const (
+	DefaultMaxKeySize = 64 * 1024
+
+	// A write request is stored in a log entry, together with the fields
+	// added by the leader, and the entry is sent to the followers in an
+	// append message, which has to fit in a gRPC frame.
+	logEntryOverhead    = 64 * 1024
+	replicationOverhead = 1024 * 1024
+)
+
+// MaxWriteRequestSizeLimit is the largest write request that can be
+// replicated, given the max size of the gRPC messages.
+const MaxWriteRequestSizeLimit = container.MaxGrpcFrameSize - replicationOverhead
+
+// WriteSizeLimits are the max sizes of the write requests accepted on the
+// public service. A value of 0 means that the default is used.
+type WriteSizeLimits struct {
+	MaxKeySize          int
+	MaxValueSize        int
+	MaxWriteRequestSize int
+}
+
+func (l WriteSizeLimits) withDefaults() WriteSizeLimits {
+	if l.MaxWriteRequestSize == 0 {
+		l.MaxWriteRequestSize = MaxWriteRequestSizeLimit
+	}
+	if l.MaxKeySize == 0 {
+		l.MaxKeySize = min(DefaultMaxKeySize, l.MaxWriteRequestSize)
+	}
+	if l.MaxValueSize == 0 {
+		l.MaxValueSize = l.MaxWriteRequestSize
+	}
+	return l
+}
+
+// Validate checks that the limits are consistent with each other and with
+// the max size of the gRPC messages.
+func (l WriteSizeLimits) Validate() error {
+	l = l.withDefaults()
+
+	switch {
+	case l.MaxKeySize < 0 || l.MaxValueSize < 0 || l.MaxWriteRequestSize < 0:
+		return errors.New("write size limits cannot be negative")
+	case l.MaxWriteRequestSize > MaxWriteRequestSizeLimit:
+		return errors.Errorf("max write request size %d exceeds the limit of %d", l.MaxWriteRequestSize, MaxWriteRequestSizeLimit)
+	case l.MaxKeySize > l.MaxWriteRequestSize:
+		return errors.Errorf("max key size %d exceeds the max write request size %d", l.MaxKeySize, l.MaxWriteRequestSize)
+	case l.MaxValueSize > l.MaxWriteRequestSize:
+		return errors.Errorf("max value size %d exceeds the max write request size %d", l.MaxValueSize, l.MaxWriteRequestSize)
+	}
+	return nil
+}
+
+// The largest log entry that a follower accepts from the leader.
+func (l WriteSizeLimits) maxLogEntrySize() int {
+	return l.withDefaults().MaxWriteRequestSize + logEntryOverhead
+}
+
+func (l WriteSizeLimits) check(write *proto.WriteRequest) error {
+	l = l.withDefaults()
+
+	if size := write.SizeVT(); size > l.MaxWriteRequestSize {
+		return status.Errorf(codes.InvalidArgument, "oxia: write request size %d exceeds the max of %d", size, l.MaxWriteRequestSize)
+	}
+
+	for _, put := range write.Puts {
+		if err := l.checkKey(put.Key); err != nil {
+			return err
+		}
+		if len(put.Value) > l.MaxValueSize {
+			return status.Errorf(codes.InvalidArgument, "oxia: value size %d exceeds the max of %d", len(put.Value), l.MaxValueSize)
+		}
+	}
+
+	for _, del := range write.Deletes {
+		if err := l.checkKey(del.Key); err != nil {
+			return err
+		}
+	}
+
+	for _, deleteRange := range write.DeleteRanges {
+		if err := l.checkKey(deleteRange.StartInclusive); err != nil {
+			return err
+		}
+		if err := l.checkKey(deleteRange.EndExclusive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l WriteSizeLimits) checkKey(key string) error {
+	if len(key) > l.MaxKeySize {
+		return status.Errorf(codes.InvalidArgument, "oxia: key size %d exceeds the max of %d", len(key), l.MaxKeySize)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/common/container"
+	"github.com/streamnative/oxia/proto"
+	"github.com/streamnative/oxia/server/kv"
+	"github.com/streamnative/oxia/server/wal"
+)
+
+func TestWriteSizeLimits_Defaults(t *testing.T) {
+	l := WriteSizeLimits{}.withDefaults()
+	assert.Equal(t, DefaultMaxKeySize, l.MaxKeySize)
+	assert.Equal(t, MaxWriteRequestSizeLimit, l.MaxValueSize)
+	assert.Equal(t, MaxWriteRequestSizeLimit, l.MaxWriteRequestSize)
+
+	// The entries built from the largest write request still fit in a gRPC frame
+	assert.Less(t, WriteSizeLimits{}.maxLogEntrySize(), container.MaxGrpcFrameSize)
+}
+
+func TestWriteSizeLimits_Validate(t *testing.T) {
+	assert.NoError(t, WriteSizeLimits{}.Validate())
+	assert.NoError(t, WriteSizeLimits{MaxWriteRequestSize: MaxWriteRequestSizeLimit}.Validate())
+	assert.NoError(t, WriteSizeLimits{MaxKeySize: 10, MaxValueSize: 100, MaxWriteRequestSize: 100}.Validate())
+
+	assert.Error(t, WriteSizeLimits{MaxWriteRequestSize: MaxWriteRequestSizeLimit + 1}.Validate())
+	assert.Error(t, WriteSizeLimits{MaxKeySize: -1}.Validate())
+	assert.Error(t, WriteSizeLimits{MaxKeySize: 101, MaxWriteRequestSize: 100}.Validate())
+	assert.Error(t, WriteSizeLimits{MaxValueSize: 101, MaxWriteRequestSize: 100}.Validate())
+}
+
+func TestWriteSizeLimits_Key(t *testing.T) {
+	l := WriteSizeLimits{MaxKeySize: 10}
+
+	assert.NoError(t, l.check(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: strings.Repeat("k", 10)}},
+	}))
+
+	for _, write := range []*proto.WriteRequest{
+		{Puts: []*proto.PutRequest{{Key: strings.Repeat("k", 11)}}},
+		{Deletes: []*proto.DeleteRequest{{Key: strings.Repeat("k", 11)}}},
+		{DeleteRanges: []*proto.DeleteRangeRequest{{StartInclusive: "a", EndExclusive: strings.Repeat("k", 11)}}},
+	} {
+		err := l.check(write)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestWriteSizeLimits_Value(t *testing.T) {
+	l := WriteSizeLimits{MaxValueSize: 100}
+
+	assert.NoError(t, l.check(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "a", Value: make([]byte, 100)}},
+	}))
+
+	err := l.check(&proto.WriteRequest{
+		Puts: []*proto.PutRequest{{Key: "a", Value: make([]byte, 101)}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWriteSizeLimits_WriteRequest(t *testing.T) {
+	write := &proto.WriteRequest{
+		Puts: []*proto.PutRequest{
+			{Key: "a", Value: make([]byte, 100)},
+			{Key: "b", Value: make([]byte, 100)},
+		},
+	}
+	size := write.SizeVT()
+
+	assert.NoError(t, WriteSizeLimits{MaxWriteRequestSize: size}.check(write))
+
+	err := WriteSizeLimits{MaxWriteRequestSize: size - 1}.check(write)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWriteSizeLimits_PublicService(t *testing.T) {
+	config := NewTestConfig(t.TempDir())
+	config.WriteSizeLimits = WriteSizeLimits{MaxKeySize: 16, MaxValueSize: 1024}
+
+	standalone, err := NewStandalone(config)
+	assert.NoError(t, err)
+
+	cnx, err := grpc.NewClient(fmt.Sprintf("localhost:%d", standalone.RpcPort()),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	client := proto.NewOxiaClientClient(cnx)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), common.MetadataNamespace, common.DefaultNamespace)
+	write := func(key string, value []byte) error {
+		var shard int64
+		_, err := client.Write(ctx, &proto.WriteRequest{
+			Shard: &shard,
+			Puts:  []*proto.PutRequest{{Key: key, Value: value}},
+		})
+		return err
+	}
+
+	assert.NoError(t, write(strings.Repeat("k", 16), make([]byte, 1024)))
+
+	err = write(strings.Repeat("k", 17), []byte("value"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = write("key", make([]byte, 1025))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	assert.NoError(t, cnx.Close())
+	assert.NoError(t, standalone.Close())
+}
+
+func TestFollower_RejectEntryTooLarge(t *testing.T) {
+	var shardId int64
+	kvFactory, err := kv.NewPebbleKVFactory(&kv.FactoryOptions{DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	walFactory := wal.NewWalFactory(&wal.FactoryOptions{BaseWalDir: t.TempDir()})
+
+	config := Config{WriteSizeLimits: WriteSizeLimits{MaxWriteRequestSize: 1024}}
+	fc, err := NewFollowerController(config, common.DefaultNamespace, shardId, walFactory, kvFactory)
+	assert.NoError(t, err)
+	_, err = fc.NewTerm(&proto.NewTermRequest{Term: 1})
+	assert.NoError(t, err)
+
+	maxEntrySize := fc.(*followerController).maxEntrySize
+	stream := newMockServerReplicateStream()
+	req := createAddRequest(t, 1, 0, map[string]string{"a": strings.Repeat("v", maxEntrySize)}, wal.InvalidOffset)
+	assert.Greater(t, req.Entry.SizeVT(), maxEntrySize)
+	stream.AddRequest(req)
+
+	err = fc.Replicate(stream)
+	assert.Equal(t, common.CodeEntryTooLarge, status.Code(err))
+	assert.Equal(t, wal.InvalidOffset, fc.(*followerController).wal.LastOffset())
+
+	assert.NoError(t, fc.Close())
+	assert.NoError(t, kvFactory.Close())
+	assert.NoError(t, walFactory.Close())
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var duration = flag.Duration("chaos.duration", 10*time.Second, "Duration of the workload of the chaos test")
+
+func TestChaos(t *testing.T) {
+	c, err := NewCluster(ClusterOptions{
+		Dir:               t.TempDir(),
+		Servers:           3,
+		Shards:            2,
+		ReplicationFactor: 3,
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration+time.Minute)
+	defer cancel()
+	require.NoError(t, c.WaitForSteadyState(ctx))
+
+	options := WorkloadOptions{
+		Clients:        4,
+		Keys:           5,
+		Duration:       *duration,
+		Nemeses:        []Nemesis{KillLeader(), DropReplicationStream(), PauseFollower()},
+		FaultDuration:  time.Second,
+		FaultInterval:  time.Second,
+		RequestTimeout: 2 * time.Second,
+	}
+	h, err := Run(ctx, c, options)
+	require.NoError(t, err)
+
+	acknowledged := 0
+	for _, op := range h.Operations() {
+		if op.Kind != OpGet && op.Outcome == OutcomeOk {
+			acknowledged++
+		}
+	}
+	t.Logf("Ran %d operations, with %d acknowledged writes and %d faults",
+		len(h.Operations()), acknowledged, len(h.Faults()))
+	assert.Positive(t, acknowledged)
+	assert.NotEmpty(t, h.Faults())
+
+	for _, v := range Check(h, options.Clients) {
+		t.Error(v.Format(h))
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// ViolationLostWrite is an acknowledged write that is missing from the
+	// final state of the cluster
+	ViolationLostWrite = "lost-write"
+
+	// ViolationStaleRead is a linearizable read that doesn't observe a
+	// write, or a read, that completed before it started
+	ViolationStaleRead = "stale-read"
+
+	// ViolationVersionRegression is a write that got a version lower than
+	// the version of a write that completed before it started
+	ViolationVersionRegression = "version-regression"
+
+	// ViolationInvalidRead is a read of a value that was never written, of
+	// a write that failed, or with a version different from the one
+	// returned to the writer
+	ViolationInvalidRead = "invalid-read"
+
+	// ViolationConflictingWrites are two conditional writes that both
+	// succeeded on the same version of a record
+	ViolationConflictingWrites = "conflicting-writes"
+)
+
+// Violation is a fragment of the history that is not allowed by the model.
+type Violation struct {
+	Kind    string
+	Key     string
+	Message string
+
+	// Ops are the operations on the key involved in the violation, sorted
+	// by their start time
+	Ops []Operation
+
+	// Faults are the faults that were injected while the operations were
+	// running
+	Faults []Fault
+}
+
+func (v Violation) Format(h *History) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%s on key %q: %s", v.Kind, v.Key, v.Message)
+	for _, op := range v.Ops {
+		fmt.Fprintf(sb, "\n  %s", h.Format(op))
+	}
+	for _, f := range v.Faults {
+		fmt.Fprintf(sb, "\n  %s", h.FormatFault(f))
+	}
+	return sb.String()
+}
+
+// Check verifies the history against the model of a linearizable key-value
+// store, where the version ids of a shard only grow. That is, every write
+// gets a version id higher than the ones of the writes that were applied
+// before it, even after the record is deleted and created again.
+//
+// The final reads of the workload are gets issued by process finalProcess,
+// after all the faults were healed, and their violations are reported as
+// lost writes.
+func Check(h *History, finalProcess int) []Violation {
+	ops := h.Operations()
+	byKey := map[string][]Operation{}
+	for _, op := range ops {
+		byKey[op.Key] = append(byKey[op.Key], op)
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	c := &checker{faults: h.Faults(), finalProcess: finalProcess}
+	for _, key := range keys {
+		c.checkKey(key, byKey[key])
+	}
+	return c.violations
+}
+
+type checker struct {
+	faults       []Fault
+	finalProcess int
+	violations   []Violation
+}
+
+func (c *checker) checkKey(key string, ops []Operation) {
+	writes := map[string]Operation{}
+	for _, op := range ops {
+		if op.Kind == OpPut {
+			writes[op.Value] = op
+		}
+	}
+
+	c.checkReads(key, ops, writes)
+	c.checkConditions(key, ops)
+	c.checkOrder(key, ops, writes)
+}
+
+// checkReads verifies that every read returns a value that was written, with
+// the version that the writer got.
+func (c *checker) checkReads(key string, ops []Operation, writes map[string]Operation) {
+	for _, op := range ops {
+		if op.Kind != OpGet || op.Outcome != OutcomeOk || !op.Found {
+			continue
+		}
+
+		w, ok := writes[op.Value]
+		switch {
+		case !ok:
+			c.report(ViolationInvalidRead, key, fmt.Sprintf("read value %s, which was never written", op.Value), op)
+		case w.Outcome == OutcomeFail:
+			c.report(ViolationInvalidRead, key, fmt.Sprintf("read value %s of a write that failed", op.Value), w, op)
+		case w.Outcome == OutcomeOk && w.Version != op.Version:
+			c.report(ViolationInvalidRead, key,
+				fmt.Sprintf("read value %s with version %d, while the write got version %d", op.Value, op.Version, w.Version), w, op)
+		}
+	}
+}
+
+// checkConditions verifies that at most one conditional write succeeds on
+// each version of the record.
+func (c *checker) checkConditions(key string, ops []Operation) {
+	winners := map[int64]Operation{}
+	for _, op := range ops {
+		if op.Kind == OpGet || op.Outcome != OutcomeOk || op.ExpectedVersion == NotExists {
+			continue
+		}
+
+		if w, ok := winners[op.ExpectedVersion]; ok {
+			c.report(ViolationConflictingWrites, key,
+				fmt.Sprintf("two conditional writes succeeded on version %d", op.ExpectedVersion), w, op)
+			continue
+		}
+		winners[op.ExpectedVersion] = op
+	}
+}
+
+// checkOrder verifies that every operation observes a state that is at least
+// as recent as the one observed by the operations that completed before it
+// started.
+func (c *checker) checkOrder(key string, ops []Operation, writes map[string]Operation) {
+	byEnd := make([]Operation, 0, len(ops))
+	for _, op := range ops {
+		if observesVersion(op) || isDeleted(op) {
+			byEnd = append(byEnd, op)
+		}
+	}
+	sort.SliceStable(byEnd, func(i, j int) bool { return byEnd[i].End.Before(byEnd[j].End) })
+
+	var latest, latestDelete *Operation
+	next := 0
+	for _, op := range ops {
+		// Advance over the operations that completed before this one started
+		for ; next < len(byEnd) && byEnd[next].End.Before(op.Start); next++ {
+			prev := &byEnd[next]
+			if observesVersion(*prev) && (latest == nil || prev.Version > latest.Version) {
+				latest = prev
+			}
+			if isDeleted(*prev) && (latestDelete == nil || prev.ExpectedVersion > latestDelete.ExpectedVersion) {
+				latestDelete = prev
+			}
+		}
+
+		switch {
+		case op.Outcome != OutcomeOk:
+			continue
+		case op.Kind == OpPut:
+			if latest != nil && op.Version <= latest.Version {
+				c.reportOrder(ViolationVersionRegression, key, ops,
+					fmt.Sprintf("write got version %d, after version %d was observed", op.Version, latest.Version), *latest, op)
+			}
+		case op.Kind == OpGet && op.Linearizable && op.Found:
+			switch {
+			case latest != nil && op.Version < latest.Version:
+				c.reportOrder(c.staleKind(op), key, ops,
+					fmt.Sprintf("read version %d, after version %d was observed", op.Version, latest.Version), *latest, op)
+			case latestDelete != nil && op.Version <= latestDelete.ExpectedVersion:
+				c.reportOrder(c.staleKind(op), key, ops,
+					fmt.Sprintf("read version %d, after version %d was deleted", op.Version, latestDelete.ExpectedVersion), *latestDelete, op)
+			}
+		case op.Kind == OpGet && op.Linearizable:
+			if latest != nil && !c.canBeDeleted(ops, *latest, op) {
+				c.reportOrder(c.staleKind(op), key, ops,
+					fmt.Sprintf("record not found, after version %d was observed and never deleted", latest.Version), *latest, op)
+			}
+		}
+	}
+}
+
+// canBeDeleted tells whether a delete of the version observed by prev, or of
+// a later version, might have been applied before the read completed.
+func (*checker) canBeDeleted(ops []Operation, prev Operation, read Operation) bool {
+	for _, op := range ops {
+		if op.Kind == OpDelete && op.Outcome != OutcomeFail &&
+			op.ExpectedVersion >= prev.Version && op.Start.Before(read.End) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) staleKind(read Operation) string {
+	if read.Process == c.finalProcess {
+		return ViolationLostWrite
+	}
+	return ViolationStaleRead
+}
+
+// reportOrder reports a violation between the operation prev, which completed
+// before op started, along with all the writes on the key that overlap
+// them, since these are the ones that could explain the state observed by op.
+func (c *checker) reportOrder(kind string, key string, ops []Operation, message string, prev Operation, op Operation) {
+	fragment := []Operation{prev}
+	for _, w := range ops {
+		if w.Kind == OpGet || w == prev || w == op {
+			continue
+		}
+		if !w.End.Before(prev.Start) && w.Start.Before(op.End) {
+			fragment = append(fragment, w)
+		}
+	}
+	fragment = append(fragment, op)
+	c.report(kind, key, message, fragment...)
+}
+
+func (c *checker) report(kind string, key string, message string, ops ...Operation) {
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Start.Before(ops[j].Start) })
+
+	start, end := ops[0].Start, ops[0].End
+	for _, op := range ops {
+		if op.End.After(end) {
+			end = op.End
+		}
+	}
+
+	var faults []Fault
+	for _, f := range c.faults {
+		if f.Start.Before(end) && (f.End.IsZero() || f.End.After(start)) {
+			faults = append(faults, f)
+		}
+	}
+
+	c.violations = append(c.violations, Violation{
+		Kind:    kind,
+		Key:     key,
+		Message: message,
+		Ops:     ops,
+		Faults:  faults,
+	})
+}
+
+func observesVersion(op Operation) bool {
+	return op.Outcome == OutcomeOk &&
+		(op.Kind == OpPut || (op.Kind == OpGet && op.Found))
+}
+
+func isDeleted(op Operation) bool {
+	return op.Outcome == OutcomeOk && op.Kind == OpDelete
+}
@@ -0,0 +1,184 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const finalProcess = 9
+
+type historyBuilder struct {
+	h *History
+}
+
+func newHistoryBuilder() *historyBuilder {
+	return &historyBuilder{h: NewHistory()}
+}
+
+func (b *historyBuilder) at(start, end int) (time.Time, time.Time) {
+	return b.h.start.Add(time.Duration(start) * time.Millisecond), b.h.start.Add(time.Duration(end) * time.Millisecond)
+}
+
+func (b *historyBuilder) put(process int, start, end int, value string, expected int64, outcome Outcome, version int64) *historyBuilder {
+	s, e := b.at(start, end)
+	b.h.Add(Operation{Process: process, Kind: OpPut, Key: "k", Value: value, ExpectedVersion: expected,
+		Start: s, End: e, Outcome: outcome, Version: version})
+	return b
+}
+
+func (b *historyBuilder) del(process int, start, end int, expected int64, outcome Outcome) *historyBuilder {
+	s, e := b.at(start, end)
+	b.h.Add(Operation{Process: process, Kind: OpDelete, Key: "k", ExpectedVersion: expected,
+		Start: s, End: e, Outcome: outcome})
+	return b
+}
+
+func (b *historyBuilder) get(process int, start, end int, value string, version int64) *historyBuilder {
+	s, e := b.at(start, end)
+	b.h.Add(Operation{Process: process, Kind: OpGet, Key: "k", Linearizable: true, Value: value,
+		Start: s, End: e, Found: value != "", Version: version})
+	return b
+}
+
+func (b *historyBuilder) fault(start, end int) *historyBuilder {
+	s, e := b.at(start, end)
+	b.h.AddFault(Fault{Nemesis: "kill-leader", Description: "stopped server", Start: s, End: e})
+	return b
+}
+
+func kinds(violations []Violation) []string {
+	res := make([]string, 0)
+	for _, v := range violations {
+		res = append(res, v.Kind)
+	}
+	return res
+}
+
+func TestCheck_Valid(t *testing.T) {
+	b := newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeOk, 1).
+		get(1, 5, 15, "", 0). // Concurrent with the put
+		get(1, 20, 30, "a", 1).
+		put(1, 40, 50, "b", 1, OutcomeOk, 4).
+		put(0, 45, 55, "c", 1, OutcomeFail, 0).
+		put(0, 60, 200, "d", 4, OutcomeUnknown, 0).
+		get(1, 100, 110, "d", 7). // The write with unknown outcome was applied
+		del(0, 120, 130, 7, OutcomeOk).
+		get(1, 140, 150, "", 0).
+		put(0, 150, 160, "e", NotExists, OutcomeOk, 9).
+		get(finalProcess, 300, 310, "e", 9)
+
+	assert.Empty(t, Check(b.h, finalProcess))
+}
+
+func TestCheck_LostWrite(t *testing.T) {
+	b := newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeOk, 1).
+		put(1, 20, 30, "b", 1, OutcomeOk, 4).
+		fault(25, 50).
+		get(finalProcess, 300, 310, "a", 1)
+
+	violations := Check(b.h, finalProcess)
+	assert.Equal(t, []string{ViolationLostWrite}, kinds(violations))
+
+	v := violations[0]
+	assert.Equal(t, "k", v.Key)
+	assert.Equal(t, "read version 1, after version 4 was observed", v.Message)
+	assert.Len(t, v.Ops, 2)
+	assert.Equal(t, "b", v.Ops[0].Value)
+	assert.Equal(t, finalProcess, v.Ops[1].Process)
+	assert.Len(t, v.Faults, 1)
+
+	report := v.Format(b.h)
+	assert.Contains(t, report, "lost-write on key \"k\"")
+	assert.Contains(t, report, "client 1 put k = b expected=1 -> ok version=4")
+	assert.Contains(t, report, "client 9 get k -> ok a version=1")
+	assert.Contains(t, report, "nemesis kill-leader: stopped server")
+}
+
+func TestCheck_LostWriteNotFound(t *testing.T) {
+	b := newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeOk, 1).
+		del(1, 20, 30, 0, OutcomeUnknown). // Deletes an older version
+		get(finalProcess, 300, 310, "", 0)
+
+	violations := Check(b.h, finalProcess)
+	assert.Equal(t, []string{ViolationLostWrite}, kinds(violations))
+	assert.Len(t, violations[0].Ops, 3)
+
+	// A delete that might have been applied explains the missing record
+	b.del(1, 40, 50, 1, OutcomeUnknown)
+	assert.Empty(t, Check(b.h, finalProcess))
+}
+
+func TestCheck_StaleRead(t *testing.T) {
+	b := newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeOk, 1).
+		get(1, 20, 30, "a", 1).
+		del(0, 40, 50, 1, OutcomeOk).
+		get(1, 60, 70, "a", 1)
+
+	violations := Check(b.h, finalProcess)
+	assert.Equal(t, []string{ViolationStaleRead}, kinds(violations))
+	assert.Equal(t, "read version 1, after version 1 was deleted", violations[0].Message)
+
+	// The reads that allow stale records are not checked
+	b = newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeOk, 1).
+		put(0, 20, 30, "b", 1, OutcomeOk, 2)
+	s, e := b.at(40, 50)
+	b.h.Add(Operation{Process: 1, Kind: OpGet, Key: "k", Value: "a", Found: true, Version: 1, Start: s, End: e})
+	assert.Empty(t, Check(b.h, finalProcess))
+}
+
+func TestCheck_VersionRegression(t *testing.T) {
+	b := newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeOk, 5).
+		get(1, 20, 30, "a", 5).
+		put(1, 40, 50, "b", NotExists, OutcomeOk, 3)
+
+	assert.Equal(t, []string{ViolationVersionRegression}, kinds(Check(b.h, finalProcess)))
+}
+
+func TestCheck_InvalidRead(t *testing.T) {
+	b := newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeFail, 0).
+		get(1, 20, 30, "a", 1).
+		get(1, 20, 30, "x", 1)
+
+	assert.Equal(t, []string{ViolationInvalidRead, ViolationInvalidRead}, kinds(Check(b.h, finalProcess)))
+
+	b = newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeOk, 1).
+		get(1, 20, 30, "a", 2)
+	violations := Check(b.h, finalProcess)
+	assert.Equal(t, []string{ViolationInvalidRead}, kinds(violations))
+	assert.Equal(t, "read value a with version 2, while the write got version 1", violations[0].Message)
+}
+
+func TestCheck_ConflictingWrites(t *testing.T) {
+	b := newHistoryBuilder().
+		put(0, 0, 10, "a", NotExists, OutcomeOk, 1).
+		put(1, 20, 30, "b", 1, OutcomeOk, 2).
+		del(2, 25, 35, 1, OutcomeOk)
+
+	violations := Check(b.h, finalProcess)
+	assert.Equal(t, []string{ViolationConflictingWrites}, kinds(violations))
+	assert.Len(t, violations[0].Ops, 2)
+}
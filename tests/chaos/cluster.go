@@ -0,0 +1,244 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/streamnative/oxia/common"
+	"github.com/streamnative/oxia/coordinator/impl"
+	"github.com/streamnative/oxia/coordinator/model"
+	"github.com/streamnative/oxia/server"
+)
+
+type ClusterOptions struct {
+	// Dir is where the data and the WAL of the servers are stored
+	Dir string
+
+	Servers           int
+	Shards            uint32
+	ReplicationFactor uint32
+}
+
+// Node is a server of the cluster. Its internal service is reached through
+// a proxy, which is where the network faults are injected.
+type Node struct {
+	sync.Mutex
+
+	Address model.ServerAddress
+
+	config server.Config
+	server *server.Server
+	proxy  *faultProxy
+}
+
+// Cluster is a cluster of servers, with their coordinator, running in the
+// current process.
+type Cluster struct {
+	Nodes []*Node
+
+	coordinator impl.Coordinator
+	clientPool  common.ClientPool
+}
+
+func NewCluster(options ClusterOptions) (*Cluster, error) {
+	c := &Cluster{
+		clientPool: common.NewClientPool(nil, nil),
+	}
+
+	clusterConfig := model.ClusterConfig{
+		Namespaces: []model.NamespaceConfig{{
+			Name:              common.DefaultNamespace,
+			ReplicationFactor: options.ReplicationFactor,
+			InitialShardCount: options.Shards,
+		}},
+	}
+
+	for i := 0; i < options.Servers; i++ {
+		n, err := startNode(filepath.Join(options.Dir, fmt.Sprintf("server-%d", i)))
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+		c.Nodes = append(c.Nodes, n)
+		clusterConfig.Servers = append(clusterConfig.Servers, n.Address)
+	}
+
+	var err error
+	c.coordinator, err = impl.NewCoordinator(impl.NewMetadataProviderMemory(),
+		func() (model.ClusterConfig, error) { return clusterConfig, nil },
+		nil, impl.NewRpcProvider(c.clientPool),
+		impl.FailureDetectorOptions{
+			ProbeInterval:    100 * time.Millisecond,
+			ProbeTimeout:     time.Second,
+			FailureThreshold: 3,
+			ElectionBackoff:  50 * time.Millisecond,
+		}, impl.RebalanceOptions{})
+	if err != nil {
+		_ = c.Close()
+		return nil, errors.Wrap(err, "failed to start the coordinator")
+	}
+
+	return c, nil
+}
+
+func startNode(dir string) (*Node, error) {
+	n := &Node{
+		config: server.Config{
+			PublicServiceAddr:          "localhost:0",
+			InternalServiceAddr:        "localhost:0",
+			DataDir:                    filepath.Join(dir, "db"),
+			WalDir:                     filepath.Join(dir, "wal"),
+			NotificationsRetentionTime: time.Minute,
+		},
+	}
+
+	var err error
+	if n.server, err = server.New(n.config); err != nil {
+		return nil, errors.Wrap(err, "failed to start the server")
+	}
+
+	// The ports are kept when the server is restarted
+	n.config.PublicServiceAddr = fmt.Sprintf("localhost:%d", n.server.PublicPort())
+	n.config.InternalServiceAddr = fmt.Sprintf("localhost:%d", n.server.InternalPort())
+
+	if n.proxy, err = newFaultProxy(n.config.InternalServiceAddr); err != nil {
+		_ = n.server.Close()
+		return nil, err
+	}
+
+	n.Address = model.ServerAddress{
+		Public:   n.config.PublicServiceAddr,
+		Internal: n.proxy.Addr(),
+	}
+	return n, nil
+}
+
+// Stop closes the server, which drops all its connections, as if its
+// process was killed.
+func (n *Node) Stop() error {
+	n.Lock()
+	defer n.Unlock()
+	if n.server == nil {
+		return nil
+	}
+
+	err := n.server.Close()
+	n.server = nil
+	n.proxy.DropConnections()
+	return err
+}
+
+// Start restarts a stopped server, with the same data and addresses.
+func (n *Node) Start() error {
+	n.Lock()
+	defer n.Unlock()
+	if n.server != nil {
+		return nil
+	}
+
+	s, err := server.New(n.config)
+	if err != nil {
+		return errors.Wrapf(err, "failed to restart the server %s", n.Address.Public)
+	}
+	n.server = s
+	return nil
+}
+
+func (n *Node) Close() error {
+	return multierr.Combine(
+		n.Stop(),
+		n.proxy.Close(),
+	)
+}
+
+// ServiceAddress is the address the clients connect to.
+func (c *Cluster) ServiceAddress() string {
+	return c.Nodes[0].Address.Public
+}
+
+func (c *Cluster) Node(addr model.ServerAddress) *Node {
+	for _, n := range c.Nodes {
+		if n.Address == addr {
+			return n
+		}
+	}
+	return nil
+}
+
+// Shards returns the status of the shards of the default namespace.
+func (c *Cluster) Shards() map[int64]model.ShardMetadata {
+	return c.coordinator.ClusterStatus().Namespaces[common.DefaultNamespace].Shards
+}
+
+// WaitForSteadyState waits until all the shards have a leader, with all the
+// servers up.
+func (c *Cluster) WaitForSteadyState(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if c.isSteady() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "the cluster didn't reach the steady state")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Cluster) isSteady() bool {
+	for _, s := range c.coordinator.NodesStatus() {
+		if s != impl.Running {
+			return false
+		}
+	}
+
+	shards := c.Shards()
+	if len(shards) == 0 {
+		return false
+	}
+	for _, shard := range shards {
+		if shard.Status != model.ShardStatusSteadyState || shard.Leader == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cluster) Close() error {
+	var err error
+	if c.coordinator != nil {
+		err = c.coordinator.Close()
+	}
+
+	for _, n := range c.Nodes {
+		err = multierr.Append(err, n.Close())
+	}
+
+	slog.Debug("Closed the cluster")
+	return multierr.Append(err, c.clientPool.Close())
+}
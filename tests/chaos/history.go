@@ -0,0 +1,204 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type OpKind int
+
+const (
+	OpGet OpKind = iota
+	OpPut
+	OpDelete
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpGet:
+		return "get"
+	case OpPut:
+		return "put"
+	case OpDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+type Outcome int
+
+const (
+	// OutcomeOk is an operation that was acknowledged
+	OutcomeOk Outcome = iota
+
+	// OutcomeFail is an operation that was rejected, and had no effect
+	OutcomeFail
+
+	// OutcomeUnknown is a write that failed without a definite answer,
+	// eg: because of a timeout. It might have been applied or not
+	OutcomeUnknown
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeOk:
+		return "ok"
+	case OutcomeFail:
+		return "fail"
+	case OutcomeUnknown:
+		return "unknown"
+	}
+	return "invalid"
+}
+
+// NotExists is the expected version of the conditional puts of records that
+// must not exist yet.
+const NotExists int64 = -1
+
+// Operation is an operation of the workload, with its outcome.
+type Operation struct {
+	// Process is the client that issued the operation
+	Process int
+	Kind    OpKind
+	Key     string
+
+	// Value is the unique value written by a put, or the value returned
+	// by a get
+	Value string
+
+	// ExpectedVersion is the condition of a put or a delete
+	ExpectedVersion int64
+
+	// Linearizable is set on the gets served by the leader of the shard
+	Linearizable bool
+
+	Start time.Time
+	End   time.Time
+
+	Outcome Outcome
+
+	// Found is set on the gets that returned a record
+	Found bool
+
+	// Version is the version id returned by a get or a put
+	Version int64
+
+	Error string
+}
+
+// Fault is a fault injected by a nemesis, between Start and End.
+type Fault struct {
+	Nemesis     string
+	Description string
+	Start       time.Time
+	End         time.Time
+}
+
+// History records the operations of the workload and the faults, as they
+// happen.
+type History struct {
+	sync.Mutex
+
+	start  time.Time
+	ops    []Operation
+	faults []Fault
+}
+
+func NewHistory() *History {
+	return &History{start: time.Now()}
+}
+
+func (h *History) Add(op Operation) {
+	h.Lock()
+	defer h.Unlock()
+	h.ops = append(h.ops, op)
+}
+
+func (h *History) AddFault(f Fault) {
+	h.Lock()
+	defer h.Unlock()
+	h.faults = append(h.faults, f)
+}
+
+// Operations returns the operations sorted by their start time.
+func (h *History) Operations() []Operation {
+	h.Lock()
+	defer h.Unlock()
+	ops := make([]Operation, len(h.ops))
+	copy(ops, h.ops)
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Start.Before(ops[j].Start) })
+	return ops
+}
+
+func (h *History) Faults() []Fault {
+	h.Lock()
+	defer h.Unlock()
+	faults := make([]Fault, len(h.faults))
+	copy(faults, h.faults)
+	return faults
+}
+
+// Format prints an operation, with its times relative to the start of
+// the history.
+func (h *History) Format(op Operation) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "[%9s - %9s] client %d %s %s", h.since(op.Start), h.since(op.End), op.Process, op.Kind, op.Key)
+	switch op.Kind {
+	case OpGet:
+		if !op.Linearizable {
+			sb.WriteString(" (stale allowed)")
+		}
+	case OpPut:
+		fmt.Fprintf(sb, " = %s expected=%s", op.Value, formatVersion(op.ExpectedVersion))
+	case OpDelete:
+		fmt.Fprintf(sb, " expected=%s", formatVersion(op.ExpectedVersion))
+	}
+
+	fmt.Fprintf(sb, " -> %s", op.Outcome)
+	switch {
+	case op.Outcome != OutcomeOk:
+		fmt.Fprintf(sb, " (%s)", op.Error)
+	case op.Kind == OpGet && !op.Found:
+		sb.WriteString(" not found")
+	case op.Kind == OpGet:
+		fmt.Fprintf(sb, " %s version=%d", op.Value, op.Version)
+	case op.Kind == OpPut:
+		fmt.Fprintf(sb, " version=%d", op.Version)
+	}
+	return sb.String()
+}
+
+func (h *History) FormatFault(f Fault) string {
+	return fmt.Sprintf("[%9s - %9s] nemesis %s: %s", h.since(f.Start), h.since(f.End), f.Nemesis, f.Description)
+}
+
+func (h *History) since(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Sub(h.start).Round(time.Millisecond).String()
+}
+
+func formatVersion(version int64) string {
+	if version == NotExists {
+		return "not-exists"
+	}
+	return fmt.Sprint(version)
+}
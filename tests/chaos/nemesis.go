@@ -0,0 +1,167 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/pkg/errors"
+
+	"github.com/streamnative/oxia/coordinator/model"
+)
+
+var errNoTarget = errors.New("no server to inject the fault into")
+
+// Nemesis injects a fault into the cluster, which lasts until it's healed.
+type Nemesis interface {
+	Name() string
+
+	// Inject starts the fault and returns its description
+	Inject(c *Cluster) (string, error)
+
+	// Heal stops the fault started by the last Inject
+	Heal(c *Cluster) error
+}
+
+// KillLeader stops the server that is the leader of a random shard, and
+// starts it again when healed.
+func KillLeader() Nemesis {
+	return &killLeader{}
+}
+
+type killLeader struct {
+	node *Node
+}
+
+func (*killLeader) Name() string {
+	return "kill-leader"
+}
+
+func (k *killLeader) Inject(c *Cluster) (string, error) {
+	shard, leader, err := randomLeader(c)
+	if err != nil {
+		return "", err
+	}
+
+	k.node = c.Node(leader)
+	if k.node == nil {
+		return "", errors.Errorf("unknown server %s", leader.Internal)
+	}
+	return fmt.Sprintf("stopped %s, leader of shard %d", leader.Public, shard), k.node.Stop()
+}
+
+func (k *killLeader) Heal(*Cluster) error {
+	if k.node == nil {
+		return nil
+	}
+	err := k.node.Start()
+	k.node = nil
+	return err
+}
+
+// DropReplicationStream closes the connections to a follower of a random
+// shard, which breaks the replication stream from its leader. It has
+// nothing to heal, as the leader opens a new stream.
+func DropReplicationStream() Nemesis {
+	return &dropReplicationStream{}
+}
+
+type dropReplicationStream struct{}
+
+func (*dropReplicationStream) Name() string {
+	return "drop-replication-stream"
+}
+
+func (*dropReplicationStream) Inject(c *Cluster) (string, error) {
+	shard, follower, err := randomFollower(c)
+	if err != nil {
+		return "", err
+	}
+
+	c.Node(follower).proxy.DropConnections()
+	return fmt.Sprintf("dropped the connections to %s, follower of shard %d", follower.Public, shard), nil
+}
+
+func (*dropReplicationStream) Heal(*Cluster) error {
+	return nil
+}
+
+// PauseFollower stops forwarding any data to and from a follower of a random
+// shard, until healed, as if the follower was frozen.
+func PauseFollower() Nemesis {
+	return &pauseFollower{}
+}
+
+type pauseFollower struct {
+	node *Node
+}
+
+func (*pauseFollower) Name() string {
+	return "pause-follower"
+}
+
+func (p *pauseFollower) Inject(c *Cluster) (string, error) {
+	shard, follower, err := randomFollower(c)
+	if err != nil {
+		return "", err
+	}
+
+	p.node = c.Node(follower)
+	p.node.proxy.Pause()
+	return fmt.Sprintf("paused %s, follower of shard %d", follower.Public, shard), nil
+}
+
+func (p *pauseFollower) Heal(*Cluster) error {
+	if p.node != nil {
+		p.node.proxy.Resume()
+		p.node = nil
+	}
+	return nil
+}
+
+func randomLeader(c *Cluster) (int64, model.ServerAddress, error) {
+	var candidates []int64
+	shards := c.Shards()
+	for id, shard := range shards {
+		if shard.Leader != nil {
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, model.ServerAddress{}, errNoTarget
+	}
+
+	shard := candidates[rand.Intn(len(candidates))]
+	return shard, *shards[shard].Leader, nil
+}
+
+func randomFollower(c *Cluster) (int64, model.ServerAddress, error) {
+	shard, leader, err := randomLeader(c)
+	if err != nil {
+		return 0, model.ServerAddress{}, err
+	}
+
+	var followers []model.ServerAddress
+	for _, addr := range c.Shards()[shard].Ensemble {
+		if addr != leader && c.Node(addr) != nil {
+			followers = append(followers, addr)
+		}
+	}
+	if len(followers) == 0 {
+		return 0, model.ServerAddress{}, errNoTarget
+	}
+	return shard, followers[rand.Intn(len(followers))], nil
+}
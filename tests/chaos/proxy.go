@@ -0,0 +1,192 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// faultProxy forwards the connections to the internal service of a server,
+// so that the replication streams and the requests of the coordinator can
+// be dropped or paused.
+type faultProxy struct {
+	sync.Mutex
+
+	listener net.Listener
+	target   string
+	conns    map[*proxiedConn]struct{}
+
+	// resumed is closed when the proxy is not paused
+	resumed chan struct{}
+	closed  bool
+}
+
+func newFaultProxy(target string) (*faultProxy, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &faultProxy{
+		listener: listener,
+		target:   target,
+		conns:    map[*proxiedConn]struct{}{},
+		resumed:  make(chan struct{}),
+	}
+	close(p.resumed)
+
+	go p.accept()
+	return p, nil
+}
+
+func (p *faultProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *faultProxy) accept() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go p.forward(conn)
+	}
+}
+
+func (p *faultProxy) forward(conn net.Conn) {
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		slog.Debug("Failed to connect to the proxied server", slog.String("target", p.target), slog.Any("error", err))
+		_ = conn.Close()
+		return
+	}
+
+	pc := &proxiedConn{client: conn, upstream: upstream, dropped: make(chan struct{})}
+	p.Lock()
+	if p.closed {
+		p.Unlock()
+		pc.drop()
+		return
+	}
+	p.conns[pc] = struct{}{}
+	p.Unlock()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go p.copy(pc, upstream, conn, &wg)
+	go p.copy(pc, conn, upstream, &wg)
+	wg.Wait()
+
+	p.Lock()
+	delete(p.conns, pc)
+	p.Unlock()
+}
+
+// copy forwards the data, holding it while the proxy is paused. Both the
+// connections are closed when either side is closed.
+func (p *faultProxy) copy(pc *proxiedConn, dst net.Conn, src net.Conn, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer pc.drop()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			p.Lock()
+			resumed := p.resumed
+			p.Unlock()
+
+			select {
+			case <-resumed:
+			case <-pc.dropped:
+				return
+			}
+
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				slog.Debug("Proxied connection failed", slog.String("target", p.target), slog.Any("error", err))
+			}
+			return
+		}
+	}
+}
+
+// Pause holds the data sent in both directions, until Resume is called. The
+// connections stay open, as with a node that stopped responding.
+func (p *faultProxy) Pause() {
+	p.Lock()
+	defer p.Unlock()
+	select {
+	case <-p.resumed:
+		p.resumed = make(chan struct{})
+	default:
+	}
+}
+
+func (p *faultProxy) Resume() {
+	p.Lock()
+	defer p.Unlock()
+	select {
+	case <-p.resumed:
+	default:
+		close(p.resumed)
+	}
+}
+
+// DropConnections closes all the connections, which are then opened again
+// by the clients.
+func (p *faultProxy) DropConnections() {
+	p.Lock()
+	defer p.Unlock()
+	for pc := range p.conns {
+		pc.drop()
+	}
+}
+
+func (p *faultProxy) Close() error {
+	p.Lock()
+	p.closed = true
+	p.Unlock()
+
+	p.Resume()
+	err := p.listener.Close()
+	p.DropConnections()
+	return err
+}
+
+type proxiedConn struct {
+	client   net.Conn
+	upstream net.Conn
+
+	dropOnce sync.Once
+	dropped  chan struct{}
+}
+
+func (pc *proxiedConn) drop() {
+	pc.dropOnce.Do(func() {
+		close(pc.dropped)
+		_ = pc.client.Close()
+		_ = pc.upstream.Close()
+	})
+}
@@ -0,0 +1,313 @@
+// Copyright 2023 StreamNative, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/streamnative/oxia/oxia"
+)
+
+type WorkloadOptions struct {
+	Clients  int
+	Keys     int
+	Duration time.Duration
+
+	// Nemeses are the faults injected in turn, each one lasting for
+	// FaultDuration, with FaultInterval between them
+	Nemeses       []Nemesis
+	FaultDuration time.Duration
+	FaultInterval time.Duration
+
+	RequestTimeout time.Duration
+}
+
+// Run runs a workload of conditional puts, gets and deletes against the
+// cluster, while injecting the faults. After all the faults are healed, the
+// keys are read one last time, by the process with id Clients, so that the
+// lost writes are detected.
+func Run(ctx context.Context, c *Cluster, options WorkloadOptions) (*History, error) {
+	h := NewHistory()
+	keys := make([]string, options.Keys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, options.Duration)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	var clientsErr error
+	mutex := sync.Mutex{}
+	for i := 0; i < options.Clients; i++ {
+		wg.Add(1)
+		go func(process int) {
+			defer wg.Done()
+			if err := runClient(runCtx, c, h, process, keys, options); err != nil {
+				mutex.Lock()
+				clientsErr = multierr.Append(clientsErr, err)
+				mutex.Unlock()
+			}
+		}(i)
+	}
+
+	nemesisErr := runNemeses(runCtx, c, h, options)
+	wg.Wait()
+	if err := multierr.Append(clientsErr, nemesisErr); err != nil {
+		return h, err
+	}
+
+	if err := c.WaitForSteadyState(ctx); err != nil {
+		return h, err
+	}
+	return h, finalReads(ctx, c, h, options.Clients, keys, options)
+}
+
+func newClient(c *Cluster, options WorkloadOptions) (oxia.SyncClient, error) {
+	return oxia.NewSyncClient(c.ServiceAddress(),
+		oxia.WithRequestTimeout(options.RequestTimeout),
+		oxia.WithBatchLinger(0))
+}
+
+type clientState struct {
+	process int
+	seq     int
+
+	// versions are the last versions observed by the client, which are
+	// used as the condition of the writes
+	versions map[string]int64
+}
+
+func runClient(ctx context.Context, c *Cluster, h *History, process int, keys []string, options WorkloadOptions) error {
+	client, err := newClient(c, options)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create client %d", process)
+	}
+	defer client.Close()
+
+	s := &clientState{process: process, versions: map[string]int64{}}
+	for ctx.Err() == nil {
+		key := keys[rand.Intn(len(keys))]
+		switch r := rand.Intn(10); {
+		case r < 4:
+			h.Add(s.get(client, key, true, options.RequestTimeout))
+		case r < 5:
+			h.Add(s.get(client, key, false, options.RequestTimeout))
+		case r < 8:
+			h.Add(s.put(client, key, options.RequestTimeout))
+		default:
+			h.Add(s.delete(client, key, options.RequestTimeout))
+		}
+	}
+	return nil
+}
+
+func (s *clientState) expectedVersion(key string) int64 {
+	if v, ok := s.versions[key]; ok {
+		return v
+	}
+	return NotExists
+}
+
+func (s *clientState) get(client oxia.SyncClient, key string, linearizable bool, timeout time.Duration) Operation {
+	op := Operation{Process: s.process, Kind: OpGet, Key: key, Linearizable: linearizable, Start: time.Now()}
+
+	consistency := oxia.ConsistencyLinearizable()
+	if !linearizable {
+		consistency = oxia.ConsistencyBoundedStaleness()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, value, version, err := client.Get(ctx, key, consistency)
+	op.End = time.Now()
+
+	switch {
+	case err == nil:
+		op.Found = true
+		op.Value = string(value)
+		op.Version = version.VersionId
+		if linearizable {
+			s.versions[key] = version.VersionId
+		}
+	case errors.Is(err, oxia.ErrKeyNotFound):
+		if linearizable {
+			delete(s.versions, key)
+		}
+	default:
+		// The reads that failed have no effect
+		op.Outcome = OutcomeFail
+		op.Error = err.Error()
+	}
+	return op
+}
+
+func (s *clientState) put(client oxia.SyncClient, key string, timeout time.Duration) Operation {
+	s.seq++
+	op := Operation{
+		Process:         s.process,
+		Kind:            OpPut,
+		Key:             key,
+		Value:           fmt.Sprintf("c%d-%d", s.process, s.seq),
+		ExpectedVersion: s.expectedVersion(key),
+		Start:           time.Now(),
+	}
+
+	condition := oxia.ExpectedRecordNotExists()
+	if op.ExpectedVersion != NotExists {
+		condition = oxia.ExpectedVersionId(op.ExpectedVersion)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, version, err := client.Put(ctx, key, []byte(op.Value), condition)
+	op.End = time.Now()
+
+	op.Outcome, op.Error = writeOutcome(err)
+	if op.Outcome == OutcomeOk {
+		op.Version = version.VersionId
+		s.versions[key] = version.VersionId
+	}
+	return op
+}
+
+func (s *clientState) delete(client oxia.SyncClient, key string, timeout time.Duration) Operation {
+	op := Operation{
+		Process:         s.process,
+		Kind:            OpDelete,
+		Key:             key,
+		ExpectedVersion: s.expectedVersion(key),
+		Start:           time.Now(),
+	}
+
+	if op.ExpectedVersion == NotExists {
+		// There is no version to delete, the record is read instead
+		return s.get(client, key, true, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := client.Delete(ctx, key, oxia.ExpectedVersionId(op.ExpectedVersion))
+	op.End = time.Now()
+
+	op.Outcome, op.Error = writeOutcome(err)
+	if op.Outcome == OutcomeOk {
+		delete(s.versions, key)
+	}
+	return op
+}
+
+// writeOutcome tells whether a write was applied. The writes that failed
+// their condition were not, while the other errors, eg: timeouts, leave it
+// unknown.
+func writeOutcome(err error) (Outcome, string) {
+	switch {
+	case err == nil:
+		return OutcomeOk, ""
+	case errors.Is(err, oxia.ErrUnexpectedVersionId), errors.Is(err, oxia.ErrKeyNotFound):
+		return OutcomeFail, err.Error()
+	default:
+		return OutcomeUnknown, err.Error()
+	}
+}
+
+func runNemeses(ctx context.Context, c *Cluster, h *History, options WorkloadOptions) error {
+	if len(options.Nemeses) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	if err := c.WaitForSteadyState(ctx); err != nil {
+		return err
+	}
+
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(options.FaultInterval):
+		}
+
+		n := options.Nemeses[i%len(options.Nemeses)]
+		f := Fault{Nemesis: n.Name(), Start: time.Now()}
+		description, err := n.Inject(c)
+		if errors.Is(err, errNoTarget) {
+			continue
+		}
+
+		f.Description = description
+		if err != nil {
+			f.Description = fmt.Sprintf("%s: %v", description, err)
+		}
+		slog.Info("Injected fault", slog.String("nemesis", f.Nemesis), slog.String("fault", f.Description))
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(options.FaultDuration):
+		}
+
+		err = multierr.Append(err, n.Heal(c))
+		f.End = time.Now()
+		h.AddFault(f)
+		if err != nil {
+			return errors.Wrapf(err, "nemesis %s failed", n.Name())
+		}
+	}
+}
+
+// finalReads reads every key, retrying until the read succeeds.
+func finalReads(ctx context.Context, c *Cluster, h *History, process int, keys []string, options WorkloadOptions) error {
+	var client oxia.SyncClient
+	for {
+		var err error
+		if client, err = newClient(c, options); err == nil {
+			break
+		}
+
+		// The restarted servers take a while to receive the shard assignments
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "failed to create the client for the final reads")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	defer client.Close()
+
+	s := &clientState{process: process, versions: map[string]int64{}}
+	for _, key := range keys {
+		for {
+			op := s.get(client, key, true, options.RequestTimeout)
+			if op.Outcome == OutcomeOk {
+				h.Add(op)
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return errors.Wrapf(ctx.Err(), "failed to read key %s after the faults", key)
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+	return nil
+}
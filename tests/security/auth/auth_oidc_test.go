@@ -17,6 +17,7 @@ package auth
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -107,7 +108,7 @@ func newOxiaClusterWithAuth(t *testing.T, issueURL string, audiences string) (ad
 
 	coordinator, err := impl.NewCoordinator(metadataProvider,
 		func() (model.ClusterConfig, error) { return clusterConfig, nil },
-		nil, impl.NewRpcProvider(clientPool))
+		nil, impl.NewRpcProvider(clientPool), impl.FailureDetectorOptions{}, impl.RebalanceOptions{})
 	assert.NoError(t, err)
 
 	return s1Addr.Public, func() {
@@ -197,6 +198,60 @@ func TestOIDCWithStaticToken(t *testing.T) {
 	client.Close()
 }
 
+func TestOIDCWithRefreshedToken(t *testing.T) {
+	mockOIDC, err := mockoidc.Run()
+	assert.NoError(t, err)
+	defer func(mockOIDC *mockoidc.MockOIDC) {
+		_ = mockOIDC.Shutdown()
+	}(mockOIDC)
+
+	audience := generateRandomStr(t)
+	addr, clusterCloseFunc := newOxiaClusterWithAuth(t, mockOIDC.Issuer(), audience)
+	defer clusterCloseFunc()
+
+	// The tokens expire quickly, so the client has to get new ones
+	var refreshes atomic.Int32
+	authentication := clientauth.NewTokenAuthenticationWithRefresh(func(context.Context) (string, time.Time, error) {
+		refreshes.Add(1)
+		expiresAt := time.Now().Add(3 * time.Second)
+		token, err := mockOIDC.Keypair.SignJWT(&jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        generateRandomStr(t),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    mockOIDC.Issuer(),
+			NotBefore: jwt.NewNumericDate(time.Time{}),
+			Subject:   generateRandomStr(t),
+		})
+		return token, expiresAt, err
+	}, false)
+
+	client, err := oxia.NewSyncClient(addr, oxia.WithAuthentication(authentication))
+	assert.NoError(t, err)
+	ctx := context.Background()
+	_, _, err = client.Put(ctx, "key", []byte("value-1"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, refreshes.Load())
+
+	// The first token has expired
+	time.Sleep(4 * time.Second)
+	_, value, _, err := client.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value-1"), value)
+	notifications, err := client.GetNotifications()
+	assert.NoError(t, err)
+	assert.NoError(t, notifications.Close())
+	assert.EqualValues(t, 2, refreshes.Load())
+	assert.NoError(t, client.Close())
+
+	// The token can't be refreshed
+	failing := clientauth.NewTokenAuthenticationWithRefresh(func(context.Context) (string, time.Time, error) {
+		return "", time.Time{}, errors.New("token provider unavailable")
+	}, false)
+	_, err = oxia.NewSyncClient(addr, oxia.WithAuthentication(failing), oxia.WithRequestTimeout(1*time.Second))
+	assert.Equal(t, codes.Unauthenticated, status.Code(errors.Unwrap(err)))
+}
+
 func generateRandomStr(t *testing.T) string {
 	t.Helper()
 	random, err := uuid.NewRandom()
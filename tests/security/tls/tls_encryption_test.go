@@ -15,6 +15,7 @@
 package tls
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -135,7 +136,7 @@ func TestClusterHandshakeSuccess(t *testing.T) {
 	clientPool := common.NewClientPool(tlsConf, nil)
 	defer clientPool.Close()
 
-	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool))
+	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool), impl.FailureDetectorOptions{}, impl.RebalanceOptions{})
 	assert.NoError(t, err)
 	defer coordinator.Close()
 }
@@ -165,7 +166,7 @@ func TestClientHandshakeFailByNoTlsConfig(t *testing.T) {
 	clientPool := common.NewClientPool(tlsConf, nil)
 	defer clientPool.Close()
 
-	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool))
+	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool), impl.FailureDetectorOptions{}, impl.RebalanceOptions{})
 	assert.NoError(t, err)
 	defer coordinator.Close()
 
@@ -199,7 +200,7 @@ func TestClientHandshakeByAuthFail(t *testing.T) {
 	clientPool := common.NewClientPool(tlsConf, nil)
 	defer clientPool.Close()
 
-	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool))
+	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool), impl.FailureDetectorOptions{}, impl.RebalanceOptions{})
 	assert.NoError(t, err)
 	defer coordinator.Close()
 
@@ -239,7 +240,7 @@ func TestClientHandshakeWithInsecure(t *testing.T) {
 	clientPool := common.NewClientPool(tlsConf, nil)
 	defer clientPool.Close()
 
-	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool))
+	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool), impl.FailureDetectorOptions{}, impl.RebalanceOptions{})
 	assert.NoError(t, err)
 	defer coordinator.Close()
 
@@ -280,7 +281,7 @@ func TestClientHandshakeSuccess(t *testing.T) {
 	clientPool := common.NewClientPool(tlsConf, nil)
 	defer clientPool.Close()
 
-	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool))
+	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool), impl.FailureDetectorOptions{}, impl.RebalanceOptions{})
 	assert.NoError(t, err)
 	defer coordinator.Close()
 
@@ -317,7 +318,7 @@ func TestOnlyEnablePublicTls(t *testing.T) {
 	clientPool := common.NewClientPool(nil, nil)
 	defer clientPool.Close()
 
-	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool))
+	coordinator, err := impl.NewCoordinator(metadataProvider, func() (model.ClusterConfig, error) { return clusterConfig, nil }, nil, impl.NewRpcProvider(clientPool), impl.FailureDetectorOptions{}, impl.RebalanceOptions{})
 	assert.NoError(t, err)
 	defer coordinator.Close()
 
@@ -336,3 +337,55 @@ func TestOnlyEnablePublicTls(t *testing.T) {
 	assert.NoError(t, err)
 	client.Close()
 }
+
+func TestClientTLSFromFiles(t *testing.T) {
+	option, err := getPeerTLSOption()
+	assert.NoError(t, err)
+	serverTLSConf, err := option.MakeServerTLSConf()
+	assert.NoError(t, err)
+
+	config := server.NewTestConfig(t.TempDir())
+	config.NumShards = 2
+	config.ServerTLS = serverTLSConf
+	standaloneServer, err := server.NewStandalone(config)
+	assert.NoError(t, err)
+	defer standaloneServer.Close()
+	serviceAddress := fmt.Sprintf("localhost:%d", standaloneServer.RpcPort())
+
+	clientOption, err := getClientTLSOption()
+	assert.NoError(t, err)
+
+	// The connections to all the shards, and the notifications, use TLS
+	client, err := oxia.NewSyncClient(serviceAddress,
+		oxia.WithTLSFromFiles(clientOption.TrustedCaFile, clientOption.CertFile, clientOption.KeyFile))
+	assert.NoError(t, err)
+	notifications, err := client.GetNotifications()
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, _, err = client.Put(context.Background(), fmt.Sprintf("/key-%d", i), []byte("value"))
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 10; i++ {
+		n := <-notifications.Ch()
+		assert.Equal(t, oxia.KeyCreated, n.Type)
+	}
+	assert.NoError(t, notifications.Close())
+	assert.NoError(t, client.Close())
+
+	// The client certificate is optional, since the server doesn't require it
+	client, err = oxia.NewSyncClient(serviceAddress, oxia.WithTLSFromFiles(clientOption.TrustedCaFile, "", ""))
+	assert.NoError(t, err)
+	keys, err := client.List(context.Background(), "/key-", "/key-/")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 10)
+	assert.NoError(t, client.Close())
+
+	// The server certificate is not signed by the trusted CA
+	client, err = oxia.NewSyncClient(serviceAddress, oxia.WithTLSFromFiles(clientOption.CertFile, "", ""),
+		oxia.WithRequestTimeout(1*time.Second))
+	assert.Error(t, err)
+	assert.Nil(t, client)
+
+	_, err = oxia.NewSyncClient(serviceAddress, oxia.WithTLSFromFiles(clientOption.TrustedCaFile, clientOption.CertFile, ""))
+	assert.ErrorIs(t, err, security.ErrInvalidTLSKeyFile)
+}